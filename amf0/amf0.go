@@ -0,0 +1,167 @@
+// Package amf0 implements encoding and decoding of the AMF0 serialization
+// format, as defined in Adobe's "Action Message Format -- AMF 0"
+// specification.
+//
+// AMF0 is the format of the commands, data messages, and status objects sent
+// over RTMP. Marshal and Unmarshal convert between AMF0 values and Go values in
+// much the same way as the encoding/json package does for JSON, which allows
+// custom commands to be built without reimplementing AMF0.
+package amf0
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Marker is a single-byte value that prefixes each AMF0 value, and determines
+// its type.
+type Marker byte
+
+const (
+	NumberMarker      Marker = 0x00
+	BooleanMarker     Marker = 0x01
+	StringMarker      Marker = 0x02
+	ObjectMarker      Marker = 0x03
+	NullMarker        Marker = 0x05
+	UndefinedMarker   Marker = 0x06
+	ReferenceMarker   Marker = 0x07
+	ECMAArrayMarker   Marker = 0x08
+	ObjectEndMarker   Marker = 0x09
+	StrictArrayMarker Marker = 0x0a
+	DateMarker        Marker = 0x0b
+	LongStringMarker  Marker = 0x0c
+	TypedObjectMarker Marker = 0x10
+)
+
+const (
+	// maxDepth is the maximum depth of nested objects and arrays that may
+	// be encoded or decoded.
+	maxDepth = 512
+)
+
+// Undefined represents the AMF0 undefined value.
+type Undefined struct{}
+
+// ECMAArray represents an AMF0 ECMA array: an associative array which, unlike
+// an object, is prefixed with a count of its entries. It is most commonly seen
+// carrying the metadata of the onMetaData data message.
+//
+// ECMA arrays are decoded as an ECMAArray, rather than a
+// map[string]interface{}, so that they may be written back out as ECMA arrays.
+type ECMAArray map[string]interface{}
+
+// TypedObject represents an AMF0 typed object: an object which carries the
+// name of the class that it is an instance of, as registered by the sender
+// (for instance, with registerClassAlias in ActionScript).
+//
+// Typed objects are decoded as a TypedObject, so that their class name is
+// kept, and may be written back out as typed objects. They may also be decoded
+// into structs and maps, as objects are, in which case the class name is
+// discarded.
+type TypedObject struct {
+	// ClassName is the name of the class of the object.
+	ClassName string
+	// Properties holds the properties of the object, keyed by their name.
+	Properties map[string]interface{}
+}
+
+var (
+	ErrNonPointer   = errors.New("rtmp/amf0: Unmarshal requires a non-nil pointer")
+	ErrTrailingData = errors.New("rtmp/amf0: trailing data after value")
+	ErrMaxDepth     = errors.New("rtmp/amf0: value is nested too deeply")
+	ErrKeyTooLong   = errors.New("rtmp/amf0: key is longer than 65535 bytes")
+	// ErrStringTooLong is returned when encoding a string longer than
+	// 65535 bytes, if long strings are disabled (see
+	// Encoder.SetLongStrings).
+	ErrStringTooLong = errors.New("rtmp/amf0: string is longer than 65535 bytes")
+)
+
+// UnknownMarker is an error returned when a marker that is not supported by
+// the Decoder is read.
+type UnknownMarker byte
+
+var _ error = new(UnknownMarker)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e UnknownMarker) Error() string {
+	return fmt.Sprintf("rtmp/amf0: unknown marker (%#x)", byte(e))
+}
+
+// InvalidReference is an error returned when a reference is read which does not
+// point to a value read earlier by the Decoder.
+type InvalidReference uint16
+
+var _ error = new(InvalidReference)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e InvalidReference) Error() string {
+	return fmt.Sprintf("rtmp/amf0: invalid reference (%v)", uint16(e))
+}
+
+// UnsupportedTypeError is returned when a Go value which has no AMF0
+// equivalent is encoded.
+type UnsupportedTypeError struct {
+	// Type is the type of the value that could not be encoded.
+	Type reflect.Type
+}
+
+var _ error = new(UnsupportedTypeError)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("rtmp/amf0: unsupported type: %v", e.Type)
+}
+
+// UnmarshalTypeError is returned when an AMF0 value cannot be decoded into a
+// Go value of the given type.
+type UnmarshalTypeError struct {
+	// Value describes the AMF0 value, such as "string" or "object".
+	Value string
+	// Type is the type of the Go value that could not be decoded into.
+	Type reflect.Type
+}
+
+var _ error = new(UnmarshalTypeError)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("rtmp/amf0: cannot unmarshal %v into Go value of type %v",
+		e.Value, e.Type)
+}
+
+// ParseError is returned when AMF0 data, or a command encoded in AMF0, cannot
+// be parsed. It records where in the payload parsing failed, and what was found
+// there, so that malformed messages sent by a misbehaving peer may be
+// diagnosed.
+type ParseError struct {
+	// Offset is the offset, in bytes from the start of the payload, at
+	// which parsing failed.
+	Offset int64
+	// Expected describes what was being parsed, such as "string" or
+	// "command header". It is empty if unknown.
+	Expected string
+	// Actual describes what was found instead, such as "marker 0x07" or
+	// "end of data". It is empty if unknown.
+	Actual string
+	// Err is the underlying error.
+	Err error
+}
+
+var _ error = new(ParseError)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("rtmp/amf0: parse error at offset %d", e.Offset)
+	if e.Expected != "" {
+		msg += fmt.Sprintf(", expected %s", e.Expected)
+	}
+	if e.Actual != "" {
+		msg += fmt.Sprintf(", got %s", e.Actual)
+	}
+
+	return fmt.Sprintf("%s: %v", msg, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error { return e.Err }
@@ -0,0 +1,94 @@
+package amf0_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/amf0"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValuesRoundTrip(t *testing.T) {
+	for _, v := range []interface{}{
+		nil,
+		amf0.Undefined{},
+		true,
+		1.5,
+		"foo",
+		time.Unix(1000000000, 0).UTC(),
+		map[string]interface{}{},
+		map[string]interface{}{"a": 1.0, "": "empty key"},
+		amf0.ECMAArray{"duration": 0.0, "encoder": "obs-output module"},
+		[]interface{}{},
+		[]interface{}{nil, 1.0, []interface{}{"nested"}},
+		map[string]interface{}{
+			"array": amf0.ECMAArray{"object": map[string]interface{}{}},
+		},
+	} {
+		b, err := amf0.Marshal(v)
+		assert.Nil(t, err)
+
+		var decoded interface{}
+		err = amf0.Unmarshal(b, &decoded)
+
+		assert.Nil(t, err)
+		assert.Equal(t, v, decoded)
+	}
+}
+
+// checkRoundTrip decodes the given data, and if it is a valid AMF0 value,
+// checks that it may be encoded, and that encoding is stable across another
+// decode.
+func checkRoundTrip(t *testing.T, data []byte) {
+	var v interface{}
+	if err := amf0.Unmarshal(data, &v); err != nil {
+		return
+	}
+
+	encoded, err := amf0.Marshal(v)
+	if !assert.Nil(t, err, "%#v", data) {
+		return
+	}
+
+	var w interface{}
+	if !assert.Nil(t, amf0.Unmarshal(encoded, &w), "%#v", data) {
+		return
+	}
+
+	reencoded, err := amf0.Marshal(w)
+
+	assert.Nil(t, err, "%#v", data)
+	assert.True(t, bytes.Equal(encoded, reencoded), "%#v", data)
+}
+
+func TestRandomDataRoundTrips(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		data := make([]byte, r.Intn(32))
+		for j := range data {
+			// Bias towards small values, so that markers and
+			// lengths are frequently valid.
+			if r.Intn(2) == 0 {
+				data[j] = byte(r.Intn(0x0d))
+			} else {
+				data[j] = byte(r.Intn(0x100))
+			}
+		}
+
+		checkRoundTrip(t, data)
+	}
+}
+
+func TestDeeplyNestedDataIsRejected(t *testing.T) {
+	data := bytes.Repeat([]byte{0x0a, 0x00, 0x00, 0x00, 0x01}, 1000)
+	data = append(data, 0x05)
+
+	var v interface{}
+	err := amf0.Unmarshal(data, &v)
+
+	assert.True(t, errors.Is(err, amf0.ErrMaxDepth))
+}
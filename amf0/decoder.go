@@ -0,0 +1,654 @@
+package amf0
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/WatchBeam/rtmp/spec"
+)
+
+// Decoder decodes AMF0 values from an io.Reader.
+//
+// The Decoder never reads past the end of the value that it is decoding, so
+// that a sequence of values (such as the name, transaction ID, and arguments of
+// a command) may be decoded one at a time.
+//
+// References (which point back to an object, ECMA array, strict array, or typed
+// object read earlier) are resolved against every value decoded by the Decoder,
+// since they may span the values of a single message. A Decoder should
+// therefore not be reused across messages.
+type Decoder struct {
+	r *offsetReader
+
+	// refs is the reference table: each object, ECMA array, strict array,
+	// and typed object decoded so far, in the order in which they began.
+	refs []interface{}
+}
+
+// offsetReader is an io.Reader that keeps track of the number of bytes read
+// through it, so that errors may be reported along with their offset.
+type offsetReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements the io.Reader.Read function.
+func (o *offsetReader) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	o.n += int64(n)
+
+	return n, err
+}
+
+// NewDecoder returns a new *Decoder reading from the given io.Reader.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: &offsetReader{r: r}}
+}
+
+// Unmarshal decodes the single AMF0 value held in b into the value pointed to
+// by v. See Decoder.Decode for details. If b holds any data after the value,
+// ErrTrailingData is returned, wrapped in a *ParseError.
+func Unmarshal(b []byte, v interface{}) error {
+	d := NewDecoder(bytes.NewReader(b))
+
+	if err := d.Decode(v); err == io.EOF {
+		return &ParseError{
+			Expected: "value",
+			Actual:   "end of data",
+			Err:      io.ErrUnexpectedEOF,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if d.r.n < int64(len(b)) {
+		return &ParseError{
+			Offset:   d.r.n,
+			Expected: "end of data",
+			Actual:   Marker(b[d.r.n]).String(),
+			Err:      ErrTrailingData,
+		}
+	}
+
+	return nil
+}
+
+// Decode reads the next AMF0 value, and stores it in the value pointed to by v.
+// If v is not a non-nil pointer, ErrNonPointer is returned.
+//
+// When decoding into an empty interface, AMF0 values are decoded as follows:
+//
+//	number                 float64
+//	boolean                bool
+//	string, long string    string
+//	object                 map[string]interface{}
+//	null                   nil
+//	undefined              Undefined
+//	ECMA array             ECMAArray
+//	strict array           []interface{}
+//	date                   time.Time, in UTC
+//	typed object           TypedObject
+//	reference              the value that it refers to
+//
+// The timezone of dates is reserved by the AMF0 specification, and is read, but
+// ignored, since the number of milliseconds that a date carries is always
+// measured in UTC.
+//
+// A reference decodes as the value that it refers to, which is shared with
+// every other reference to it: decoding into an empty interface yields the same
+// map (or slice) for each. References to a value from within itself resolve to
+// nil, so that decoded values never form cycles. A reference which does not
+// point to a value read earlier results in an InvalidReference error, and any
+// other marker results in an UnknownMarker error.
+//
+// Otherwise, numbers may be decoded into any integer or float (if they fit),
+// objects, typed objects, and ECMA arrays into structs or maps with string keys
+// (as well as typed objects into a TypedObject), and strict arrays into slices
+// or arrays. Struct fields are matched by the same names that Encode uses,
+// preferring an exact match, but accepting a case-insensitive one. Properties
+// without a matching field are ignored. Null and undefined set the value to its
+// zero value. Any other mismatch results in an *UnmarshalTypeError.
+//
+// If there are no more values to decode, io.EOF is returned. Any other error
+// encountered while decoding is returned as a *ParseError, whose offset counts
+// the bytes read by this Decoder, and whose underlying error is one of those
+// described above, or the error returned by the io.Reader.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrNonPointer
+	}
+
+	offset := d.r.n
+
+	val, err := d.value(0)
+	if err != nil {
+		return err
+	}
+
+	if err := assign(rv.Elem(), val); err != nil {
+		perr := &ParseError{Offset: offset, Err: err}
+		if terr, ok := err.(*UnmarshalTypeError); ok {
+			perr.Expected = terr.Type.String()
+			perr.Actual = terr.Value
+		}
+
+		return perr
+	}
+
+	return nil
+}
+
+// value reads the next AMF0 value. If there is no next value, io.EOF is
+// returned as-is, otherwise any error is returned as a *ParseError.
+func (d *Decoder) value(depth int) (interface{}, error) {
+	m, err := spec.ReadByte(d.r)
+	if err == io.EOF {
+		return nil, err
+	} else if err != nil {
+		return nil, &ParseError{Offset: d.r.n, Expected: "marker", Err: err}
+	}
+
+	return d.valueOf(Marker(m), depth)
+}
+
+// valueOf reads the AMF0 value of the type given by the marker, which has
+// already been read. Any error is returned as a *ParseError.
+func (d *Decoder) valueOf(m Marker, depth int) (interface{}, error) {
+	offset := d.r.n - 1
+
+	if depth > maxDepth {
+		return nil, &ParseError{
+			Offset: offset,
+			Actual: m.String(),
+			Err:    ErrMaxDepth,
+		}
+	}
+
+	v, err := d.readValue(m, depth)
+	if err == nil {
+		return v, nil
+	}
+
+	if _, ok := err.(*ParseError); ok {
+		return nil, err
+	}
+
+	if _, ok := err.(UnknownMarker); ok {
+		return nil, &ParseError{
+			Offset:   offset,
+			Expected: "value",
+			Actual:   m.String(),
+			Err:      err,
+		}
+	}
+
+	perr := &ParseError{Offset: d.r.n, Expected: m.String(), Err: err}
+	if err == io.ErrUnexpectedEOF {
+		perr.Actual = "end of data"
+	}
+
+	return nil, perr
+}
+
+// readValue reads the AMF0 value of the type given by the marker, as valueOf
+// does, without wrapping the errors that it encounters.
+func (d *Decoder) readValue(m Marker, depth int) (interface{}, error) {
+	switch m {
+	case NumberMarker:
+		return d.readNumber()
+	case BooleanMarker:
+		b, err := d.readByte()
+		return b != 0, err
+	case StringMarker:
+		return d.readUTF8(2)
+	case LongStringMarker:
+		return d.readUTF8(4)
+	case ObjectMarker:
+		ref := d.reserve()
+
+		obj := make(map[string]interface{})
+		if err := d.readPairs(obj, depth); err != nil {
+			return nil, err
+		}
+
+		d.refs[ref] = obj
+		return obj, nil
+	case NullMarker:
+		return nil, nil
+	case UndefinedMarker:
+		return Undefined{}, nil
+	case ECMAArrayMarker:
+		ref := d.reserve()
+
+		// The count of entries is only a hint, as the entries are
+		// terminated by an object end marker regardless.
+		if _, err := d.readN(4); err != nil {
+			return nil, err
+		}
+
+		arr := make(ECMAArray)
+		if err := d.readPairs(arr, depth); err != nil {
+			return nil, err
+		}
+
+		d.refs[ref] = arr
+		return arr, nil
+	case StrictArrayMarker:
+		ref := d.reserve()
+
+		arr, err := d.readStrictArray(depth)
+		if err != nil {
+			return nil, err
+		}
+
+		d.refs[ref] = arr
+		return arr, nil
+	case DateMarker:
+		return d.readDate()
+	case TypedObjectMarker:
+		ref := d.reserve()
+
+		className, err := d.readUTF8(2)
+		if err != nil {
+			return nil, err
+		}
+
+		obj := TypedObject{
+			ClassName:  className,
+			Properties: make(map[string]interface{}),
+		}
+		if err := d.readPairs(obj.Properties, depth); err != nil {
+			return nil, err
+		}
+
+		d.refs[ref] = obj
+		return obj, nil
+	case ReferenceMarker:
+		buf, err := d.readN(2)
+		if err != nil {
+			return nil, err
+		}
+
+		ref := spec.Uint16(buf)
+		if int(ref) >= len(d.refs) {
+			return nil, InvalidReference(ref)
+		}
+
+		return d.refs[ref], nil
+	}
+
+	return nil, UnknownMarker(m)
+}
+
+// reserve adds an entry to the reference table for an object, ECMA array,
+// strict array, or typed object which has begun, returning its index. The
+// entry is nil until the value is complete, so that a reference to a value from
+// within itself resolves to nil, rather than forming a cycle.
+func (d *Decoder) reserve() int {
+	d.refs = append(d.refs, nil)
+	return len(d.refs) - 1
+}
+
+// readByte reads a single byte, treating io.EOF as io.ErrUnexpectedEOF, since
+// it is only called part-way through a value.
+func (d *Decoder) readByte() (byte, error) {
+	b, err := spec.ReadByte(d.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return b, err
+}
+
+// readN reads exactly n bytes. Unlike spec.ReadBytes, the buffer grows as bytes
+// are read, so a corrupt length does not cause a large allocation up front.
+func (d *Decoder) readN(n uint32) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, err := io.CopyN(buf, d.r, int64(n)); err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (d *Decoder) readNumber() (float64, error) {
+	buf, err := d.readN(8)
+	if err != nil {
+		return 0, err
+	}
+
+	return math.Float64frombits(spec.Uint64(buf)), nil
+}
+
+// readUTF8 reads a string prefixed by its length, which is `size` bytes long.
+func (d *Decoder) readUTF8(size uint32) (string, error) {
+	buf, err := d.readN(size)
+	if err != nil {
+		return "", err
+	}
+
+	var n uint32
+	if size == 2 {
+		n = uint32(spec.Uint16(buf))
+	} else {
+		n = spec.Uint32(buf)
+	}
+
+	s, err := d.readN(n)
+	return string(s), err
+}
+
+// readPairs reads the properties of an object or ECMA array into the given map,
+// until the empty key and object end marker which terminate them.
+func (d *Decoder) readPairs(m map[string]interface{}, depth int) error {
+	for {
+		key, err := d.readUTF8(2)
+		if err != nil {
+			return err
+		}
+
+		mk, err := d.readByte()
+		if err != nil {
+			return err
+		}
+
+		if key == "" && Marker(mk) == ObjectEndMarker {
+			return nil
+		}
+
+		val, err := d.valueOf(Marker(mk), depth+1)
+		if err != nil {
+			return err
+		}
+
+		m[key] = val
+	}
+}
+
+func (d *Decoder) readStrictArray(depth int) ([]interface{}, error) {
+	buf, err := d.readN(4)
+	if err != nil {
+		return nil, err
+	}
+
+	n := spec.Uint32(buf)
+
+	// The array is grown as values are read, rather than allocated up
+	// front, so that a corrupt count does not cause a large allocation.
+	arr := make([]interface{}, 0)
+	for i := uint32(0); i < n; i++ {
+		val, err := d.value(depth + 1)
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+
+		arr = append(arr, val)
+	}
+
+	return arr, nil
+}
+
+func (d *Decoder) readDate() (time.Time, error) {
+	millis, err := d.readNumber()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The timezone is reserved, and is ignored.
+	if _, err := d.readN(2); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, int64(millis)*int64(time.Millisecond)).UTC(), nil
+}
+
+// String returns the name of the AMF0 type that the marker introduces, or its
+// value for unknown markers.
+func (m Marker) String() string {
+	switch m {
+	case NumberMarker:
+		return "number"
+	case BooleanMarker:
+		return "boolean"
+	case StringMarker:
+		return "string"
+	case ObjectMarker:
+		return "object"
+	case NullMarker:
+		return "null"
+	case UndefinedMarker:
+		return "undefined"
+	case ECMAArrayMarker:
+		return "ECMA array"
+	case StrictArrayMarker:
+		return "strict array"
+	case DateMarker:
+		return "date"
+	case LongStringMarker:
+		return "long string"
+	case TypedObjectMarker:
+		return "typed object"
+	case ReferenceMarker:
+		return "reference"
+	}
+
+	return fmt.Sprintf("marker %#x", byte(m))
+}
+
+// describe returns the name of the AMF0 type that the decoded value v was read
+// as, for use in an *UnmarshalTypeError.
+func describe(v interface{}) string {
+	switch v.(type) {
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case ECMAArray:
+		return "ECMA array"
+	case []interface{}:
+		return "strict array"
+	case time.Time:
+		return "date"
+	case TypedObject:
+		return "typed object"
+	}
+
+	return "value"
+}
+
+// assign stores the decoded value src in dst.
+func assign(dst reflect.Value, src interface{}) error {
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		if src == nil {
+			dst.Set(reflect.Zero(dst.Type()))
+		} else {
+			dst.Set(reflect.ValueOf(src))
+		}
+
+		return nil
+	}
+
+	if _, ok := src.(Undefined); ok || src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+
+		return assign(dst.Elem(), src)
+	}
+
+	mismatch := &UnmarshalTypeError{describe(src), dst.Type()}
+
+	if dst.Type() == timeType {
+		t, ok := src.(time.Time)
+		if !ok {
+			return mismatch
+		}
+
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if dst.Type() == typedObjectType {
+		obj, ok := src.(TypedObject)
+		if !ok {
+			return mismatch
+		}
+
+		dst.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	switch src := src.(type) {
+	case float64:
+		return assignNumber(dst, src, mismatch)
+	case bool:
+		if dst.Kind() != reflect.Bool {
+			return mismatch
+		}
+
+		dst.SetBool(src)
+	case string:
+		if dst.Kind() != reflect.String {
+			return mismatch
+		}
+
+		dst.SetString(src)
+	case map[string]interface{}:
+		return assignPairs(dst, src, mismatch)
+	case ECMAArray:
+		return assignPairs(dst, src, mismatch)
+	case TypedObject:
+		return assignPairs(dst, src.Properties, mismatch)
+	case []interface{}:
+		return assignArray(dst, src, mismatch)
+	default:
+		return mismatch
+	}
+
+	return nil
+}
+
+func assignNumber(dst reflect.Value, n float64, mismatch error) error {
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+
+		i := int64(n)
+		if float64(i) != n || dst.OverflowInt(i) {
+			return mismatch
+		}
+
+		dst.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+
+		u := uint64(n)
+		if n < 0 || float64(u) != n || dst.OverflowUint(u) {
+			return mismatch
+		}
+
+		dst.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(n)
+	default:
+		return mismatch
+	}
+
+	return nil
+}
+
+func assignPairs(dst reflect.Value, src map[string]interface{}, mismatch error) error {
+	switch dst.Kind() {
+	case reflect.Map:
+		if dst.Type().Key().Kind() != reflect.String {
+			return mismatch
+		}
+
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+
+		for k, v := range src {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := assign(elem, v); err != nil {
+				return err
+			}
+
+			key := reflect.ValueOf(k).Convert(dst.Type().Key())
+			dst.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		for _, f := range fields(dst.Type()) {
+			v, ok := lookup(src, f.name)
+			if !ok {
+				continue
+			}
+
+			if err := assign(dst.Field(f.index), v); err != nil {
+				return err
+			}
+		}
+	default:
+		return mismatch
+	}
+
+	return nil
+}
+
+// lookup returns the value of the given key in m, preferring an exact match,
+// but accepting a case-insensitive one.
+func lookup(m map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+func assignArray(dst reflect.Value, src []interface{}, mismatch error) error {
+	switch dst.Kind() {
+	case reflect.Slice:
+		dst.Set(reflect.MakeSlice(dst.Type(), len(src), len(src)))
+	case reflect.Array:
+		dst.Set(reflect.Zero(dst.Type()))
+	default:
+		return mismatch
+	}
+
+	for i, v := range src {
+		if i >= dst.Len() {
+			break
+		}
+
+		if err := assign(dst.Index(i), v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
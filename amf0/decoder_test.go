@@ -0,0 +1,345 @@
+package amf0_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/amf0"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalDecodesSimpleValues(t *testing.T) {
+	for _, c := range []struct {
+		Bytes    []byte
+		Expected interface{}
+	}{
+		{[]byte{0x05}, nil},
+		{[]byte{0x06}, amf0.Undefined{}},
+		{[]byte{0x01, 0x01}, true},
+		{[]byte{0x00, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 1.5},
+		{[]byte{0x02, 0x00, 0x03, 0x66, 0x6f, 0x6f}, "foo"},
+		{[]byte{0x0c, 0x00, 0x00, 0x00, 0x03, 0x66, 0x6f, 0x6f}, "foo"},
+		{[]byte{
+			0x0b, 0x42, 0x6d, 0x1a, 0x94, 0xa2, 0x00, 0x00, 0x00,
+			0x00, 0x00,
+		}, time.Unix(1000000000, 0).UTC()},
+	} {
+		var v interface{}
+		err := amf0.Unmarshal(c.Bytes, &v)
+
+		assert.Nil(t, err)
+		assert.Equal(t, c.Expected, v)
+	}
+}
+
+func TestUnmarshalDistinguishesObjectsFromECMAArrays(t *testing.T) {
+	var obj, arr interface{}
+
+	assert.Nil(t, amf0.Unmarshal([]byte{
+		0x03, 0x00, 0x01, 0x61, 0x05, 0x00, 0x00, 0x09,
+	}, &obj))
+	assert.Nil(t, amf0.Unmarshal([]byte{
+		0x08, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x01, 0x61, 0x05, 0x00, 0x00, 0x09,
+	}, &arr))
+
+	assert.Equal(t, map[string]interface{}{"a": nil}, obj)
+	assert.Equal(t, amf0.ECMAArray{"a": nil}, arr)
+}
+
+func TestUnmarshalIgnoresTheECMAArrayCount(t *testing.T) {
+	var arr amf0.ECMAArray
+
+	err := amf0.Unmarshal([]byte{
+		0x08, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x01, 0x61, 0x01, 0x01,
+		0x00, 0x01, 0x62, 0x01, 0x00,
+		0x00, 0x00, 0x09,
+	}, &arr)
+
+	assert.Nil(t, err)
+	assert.Equal(t, amf0.ECMAArray{"a": true, "b": false}, arr)
+}
+
+func TestUnmarshalDecodesIntoStructs(t *testing.T) {
+	var v struct {
+		Level       string `amf0:"level"`
+		Code        string
+		Description *string
+		Count       int
+		Ignored     bool `amf0:"-"`
+	}
+
+	b, _ := amf0.Marshal(map[string]interface{}{
+		"level":   "status",
+		"code":    "NetStream.Play.Start",
+		"Count":   3,
+		"Ignored": true,
+		"unknown": []int{1},
+	})
+
+	err := amf0.Unmarshal(b, &v)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "status", v.Level)
+	assert.Equal(t, "NetStream.Play.Start", v.Code)
+	assert.Nil(t, v.Description)
+	assert.Equal(t, 3, v.Count)
+	assert.False(t, v.Ignored)
+}
+
+func TestUnmarshalDecodesIntoSlicesAndMaps(t *testing.T) {
+	var s []int
+	var a [1]string
+	var m map[string]float64
+
+	b, _ := amf0.Marshal([]int{1, 2})
+	assert.Nil(t, amf0.Unmarshal(b, &s))
+	assert.Equal(t, []int{1, 2}, s)
+
+	b, _ = amf0.Marshal([]string{"a", "b"})
+	assert.Nil(t, amf0.Unmarshal(b, &a))
+	assert.Equal(t, [1]string{"a"}, a)
+
+	b, _ = amf0.Marshal(amf0.ECMAArray{"width": 1280})
+	assert.Nil(t, amf0.Unmarshal(b, &m))
+	assert.Equal(t, map[string]float64{"width": 1280}, m)
+}
+
+func TestUnmarshalRejectsMismatchedTypes(t *testing.T) {
+	for _, c := range []struct {
+		Value interface{}
+		Into  interface{}
+	}{
+		{"foo", new(int)},
+		{1.5, new(int)},
+		{-1, new(uint)},
+		{256, new(uint8)},
+		{true, new(string)},
+		{[]int{1}, new(map[string]int)},
+		{map[string]int{}, new([]int)},
+	} {
+		b, _ := amf0.Marshal(c.Value)
+
+		err := amf0.Unmarshal(b, c.Into)
+
+		var terr *amf0.UnmarshalTypeError
+		assert.True(t, errors.As(err, &terr), "%#v", c.Value)
+	}
+}
+
+func TestUnmarshalRequiresPointers(t *testing.T) {
+	var v interface{}
+
+	assert.Equal(t, amf0.ErrNonPointer, amf0.Unmarshal([]byte{0x05}, v))
+	assert.Equal(t, amf0.ErrNonPointer,
+		amf0.Unmarshal([]byte{0x05}, (*int)(nil)))
+}
+
+func TestUnmarshalRejectsMalformedData(t *testing.T) {
+	for _, c := range []struct {
+		Bytes []byte
+		Err   *amf0.ParseError
+	}{
+		{[]byte{}, &amf0.ParseError{
+			0, "value", "end of data", io.ErrUnexpectedEOF}},
+		{[]byte{0x00, 0x3f}, &amf0.ParseError{
+			2, "number", "end of data", io.ErrUnexpectedEOF}},
+		{[]byte{0x02, 0x00, 0x03, 0x66}, &amf0.ParseError{
+			4, "string", "end of data", io.ErrUnexpectedEOF}},
+		{[]byte{0x03, 0x00, 0x01, 0x61}, &amf0.ParseError{
+			4, "object", "end of data", io.ErrUnexpectedEOF}},
+		{[]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0x05}, &amf0.ParseError{
+			6, "strict array", "end of data", io.ErrUnexpectedEOF}},
+		{[]byte{0x0d}, &amf0.ParseError{
+			0, "value", "marker 0xd", amf0.UnknownMarker(0x0d)}},
+		{[]byte{0x07, 0x00}, &amf0.ParseError{
+			2, "reference", "end of data", io.ErrUnexpectedEOF}},
+		{[]byte{0x07, 0x00, 0x00}, &amf0.ParseError{
+			3, "reference", "", amf0.InvalidReference(0)}},
+		{[]byte{0x05, 0x05}, &amf0.ParseError{
+			1, "end of data", "null", amf0.ErrTrailingData}},
+	} {
+		var v interface{}
+		err := amf0.Unmarshal(c.Bytes, &v)
+
+		assert.Equal(t, c.Err, err, "%#v", c.Bytes)
+	}
+}
+
+func TestParseErrorsReportOffsetsOfNestedValues(t *testing.T) {
+	b, _ := amf0.Marshal(map[string]interface{}{
+		"a": []interface{}{1.0, "foo"},
+	})
+	// Replace the marker of "foo" with an unknown one.
+	b[18] = 0x0d
+
+	var v interface{}
+	err := amf0.Unmarshal(b, &v)
+
+	perr, ok := err.(*amf0.ParseError)
+	assert.True(t, ok)
+	assert.EqualValues(t, 18, perr.Offset)
+	assert.Equal(t, "marker 0xd", perr.Actual)
+	assert.True(t, errors.Is(err, amf0.UnknownMarker(0x0d)))
+	assert.Equal(t, "rtmp/amf0: parse error at offset 18, expected value, "+
+		"got marker 0xd: rtmp/amf0: unknown marker (0xd)", err.Error())
+}
+
+func TestParseErrorsDescribeMismatchedTypes(t *testing.T) {
+	buf := new(bytes.Buffer)
+	e := amf0.NewEncoder(buf)
+	e.Encode("onStatus")
+	e.Encode("foo")
+
+	var name string
+	var id float64
+
+	d := amf0.NewDecoder(buf)
+
+	assert.Nil(t, d.Decode(&name))
+	err := d.Decode(&id)
+
+	assert.Equal(t, &amf0.ParseError{
+		Offset:   11,
+		Expected: "float64",
+		Actual:   "string",
+		Err:      &amf0.UnmarshalTypeError{"string", reflect.TypeOf(id)},
+	}, err)
+}
+
+func TestDecoderDecodesSequencesOfValues(t *testing.T) {
+	buf := new(bytes.Buffer)
+	e := amf0.NewEncoder(buf)
+	e.Encode("_result")
+	e.Encode(1)
+	e.Encode(nil)
+
+	var name string
+	var id float64
+	var props interface{}
+
+	d := amf0.NewDecoder(buf)
+
+	assert.Nil(t, d.Decode(&name))
+	assert.Nil(t, d.Decode(&id))
+	assert.Nil(t, d.Decode(&props))
+	assert.Equal(t, io.EOF, d.Decode(&props))
+
+	assert.Equal(t, "_result", name)
+	assert.Equal(t, 1.0, id)
+	assert.Nil(t, props)
+}
+
+var (
+	// TypedObject is a typed object of the "Foo" class, with a single
+	// "name" property.
+	TypedObject = []byte{
+		0x10, 0x00, 0x03, 0x46, 0x6f, 0x6f,
+		0x00, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+		0x02, 0x00, 0x03, 0x62, 0x61, 0x72,
+		0x00, 0x00, 0x09,
+	}
+)
+
+func TestUnmarshalIgnoresTheTimezoneOfDates(t *testing.T) {
+	var v interface{}
+	err := amf0.Unmarshal([]byte{
+		0x0b, 0x42, 0x6d, 0x1a, 0x94, 0xa2, 0x00, 0x00, 0x00,
+		0x01, 0xe0, // UTC+8, in minutes
+	}, &v)
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Unix(1000000000, 0).UTC(), v)
+}
+
+func TestUnmarshalDecodesTypedObjects(t *testing.T) {
+	expected := amf0.TypedObject{
+		ClassName:  "Foo",
+		Properties: map[string]interface{}{"name": "bar"},
+	}
+
+	var v interface{}
+	assert.Nil(t, amf0.Unmarshal(TypedObject, &v))
+	assert.Equal(t, expected, v)
+
+	var obj amf0.TypedObject
+	assert.Nil(t, amf0.Unmarshal(TypedObject, &obj))
+	assert.Equal(t, expected, obj)
+
+	var s struct{ Name string }
+	assert.Nil(t, amf0.Unmarshal(TypedObject, &s))
+	assert.Equal(t, "bar", s.Name)
+
+	var m map[string]string
+	assert.Nil(t, amf0.Unmarshal(TypedObject, &m))
+	assert.Equal(t, map[string]string{"name": "bar"}, m)
+}
+
+func TestUnmarshalRejectsTypedObjectsOfOtherTypes(t *testing.T) {
+	var obj amf0.TypedObject
+	err := amf0.Unmarshal([]byte{0x03, 0x00, 0x00, 0x09}, &obj)
+
+	if assert.IsType(t, new(amf0.ParseError), err) {
+		assert.Equal(t, "object", err.(*amf0.ParseError).Actual)
+	}
+}
+
+var (
+	// BackReference is an object whose "b" property is a reference back to
+	// the object held by its "a" property.
+	BackReference = []byte{
+		0x03,
+		0x00, 0x01, 0x61,
+		0x03, 0x00, 0x01, 0x78, 0x01, 0x01, 0x00, 0x00, 0x09,
+		0x00, 0x01, 0x62, 0x07, 0x00, 0x01,
+		0x00, 0x00, 0x09,
+	}
+)
+
+func TestUnmarshalResolvesReferences(t *testing.T) {
+	var v map[string]interface{}
+	assert.Nil(t, amf0.Unmarshal(BackReference, &v))
+
+	expected := map[string]interface{}{"x": true}
+	assert.Equal(t, map[string]interface{}{
+		"a": expected,
+		"b": expected,
+	}, v)
+
+	var s struct {
+		A struct{ X bool }
+		B map[string]bool
+	}
+	assert.Nil(t, amf0.Unmarshal(BackReference, &s))
+	assert.True(t, s.A.X)
+	assert.Equal(t, map[string]bool{"x": true}, s.B)
+}
+
+func TestDecoderResolvesReferencesAcrossValues(t *testing.T) {
+	d := amf0.NewDecoder(bytes.NewReader([]byte{
+		0x0a, 0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x01, 0x61,
+		0x07, 0x00, 0x00,
+	}))
+
+	var first, second []string
+	assert.Nil(t, d.Decode(&first))
+	assert.Nil(t, d.Decode(&second))
+
+	assert.Equal(t, []string{"a"}, first)
+	assert.Equal(t, first, second)
+}
+
+func TestUnmarshalResolvesSelfReferencesToNil(t *testing.T) {
+	var v interface{}
+	assert.Nil(t, amf0.Unmarshal([]byte{
+		0x03, 0x00, 0x04, 0x73, 0x65, 0x6c, 0x66, 0x07, 0x00, 0x00,
+		0x00, 0x00, 0x09,
+	}, &v))
+
+	assert.Equal(t, map[string]interface{}{"self": nil}, v)
+}
@@ -0,0 +1,379 @@
+package amf0
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/WatchBeam/rtmp/spec"
+)
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	undefinedType   = reflect.TypeOf(Undefined{})
+	ecmaArrayType   = reflect.TypeOf(ECMAArray{})
+	typedObjectType = reflect.TypeOf(TypedObject{})
+)
+
+// Encoder encodes Go values as AMF0 to an io.Writer.
+type Encoder struct {
+	w io.Writer
+
+	// longStrings is whether or not strings longer than 65535 bytes are
+	// encoded as long strings, rather than rejected.
+	longStrings bool
+}
+
+// encodeBuffer holds the encoding of a value until it is written out by
+// Encoder.Encode.
+type encodeBuffer struct {
+	bytes.Buffer
+
+	// longStrings is the setting of the Encoder (see SetLongStrings).
+	longStrings bool
+}
+
+// NewEncoder returns a new *Encoder writing to the given io.Writer, which
+// encodes strings longer than 65535 bytes as long strings.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, longStrings: true}
+}
+
+// SetLongStrings sets whether or not strings longer than 65535 bytes, whose
+// length does not fit the 16-bit length of an AMF0 string, are encoded as long
+// strings, which have a 32-bit length, as they are by default. If disabled, for
+// peers which do not support long strings, such strings cause Encode to return
+// ErrStringTooLong instead.
+//
+// Strings of 65535 bytes or fewer are always encoded as strings.
+func (e *Encoder) SetLongStrings(longStrings bool) {
+	e.longStrings = longStrings
+}
+
+// Marshal returns the AMF0 encoding of v. See Encoder.Encode for details.
+func Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Encode writes the AMF0 encoding of v. Go values are encoded as follows:
+//
+//	nil, nil pointers, maps, and slices    null
+//	Undefined                              undefined
+//	bool                                   boolean
+//	integers and floats                    number
+//	string                                 string, or long string if longer
+//	                                       than 65535 bytes (see
+//	                                       SetLongStrings)
+//	time.Time                              date
+//	ECMAArray                              ECMA array
+//	TypedObject                            typed object, in order of its keys
+//	map[string]T                           object, in order of its keys
+//	struct                                 object, in order of its fields
+//	slice, array                           strict array
+//
+// Pointers and interfaces are encoded as the value that they point to. Any
+// other type results in an *UnsupportedTypeError.
+//
+// Struct fields are encoded using their name, unless it is overridden by an
+// `amf0:"name"` tag. As in encoding/json, a tag of "-" skips the field, and the
+// "omitempty" option skips the field if it holds an empty value. Unexported
+// fields are skipped.
+//
+// Nothing is written unless the whole value is encoded successfully.
+func (e *Encoder) Encode(v interface{}) error {
+	buf := &encodeBuffer{longStrings: e.longStrings}
+	if err := encode(buf, reflect.ValueOf(v), 0); err != nil {
+		return err
+	}
+
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+func encode(buf *encodeBuffer, v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return ErrMaxDepth
+	}
+
+	if !v.IsValid() {
+		return buf.WriteByte(byte(NullMarker))
+	}
+
+	switch v.Type() {
+	case timeType:
+		return encodeDate(buf, v.Interface().(time.Time))
+	case undefinedType:
+		return buf.WriteByte(byte(UndefinedMarker))
+	case typedObjectType:
+		return encodeTypedObject(buf, v.Interface().(TypedObject), depth)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return buf.WriteByte(byte(NullMarker))
+		}
+
+		return encode(buf, v.Elem(), depth+1)
+	case reflect.Bool:
+		b := byte(0)
+		if v.Bool() {
+			b = 1
+		}
+
+		buf.Write([]byte{byte(BooleanMarker), b})
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+
+		return encodeNumber(buf, float64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+
+		return encodeNumber(buf, float64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return encodeNumber(buf, v.Float())
+	case reflect.String:
+		return encodeString(buf, v.String())
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return &UnsupportedTypeError{v.Type()}
+		}
+
+		if v.IsNil() {
+			return buf.WriteByte(byte(NullMarker))
+		}
+
+		return encodeMap(buf, v, depth)
+	case reflect.Struct:
+		return encodeStruct(buf, v, depth)
+	case reflect.Slice:
+		if v.IsNil() {
+			return buf.WriteByte(byte(NullMarker))
+		}
+
+		return encodeArray(buf, v, depth)
+	case reflect.Array:
+		return encodeArray(buf, v, depth)
+	}
+
+	return &UnsupportedTypeError{v.Type()}
+}
+
+func encodeNumber(buf *encodeBuffer, n float64) error {
+	buf.WriteByte(byte(NumberMarker))
+	_, err := spec.PutUint64(math.Float64bits(n), buf)
+
+	return err
+}
+
+func encodeString(buf *encodeBuffer, s string) error {
+	if len(s) > math.MaxUint16 {
+		if !buf.longStrings {
+			return ErrStringTooLong
+		}
+
+		buf.WriteByte(byte(LongStringMarker))
+		spec.PutUint32(uint32(len(s)), buf)
+	} else {
+		buf.WriteByte(byte(StringMarker))
+		spec.PutUint16(uint16(len(s)), buf)
+	}
+
+	_, err := buf.WriteString(s)
+	return err
+}
+
+func encodeDate(buf *encodeBuffer, t time.Time) error {
+	millis := float64(t.UnixNano() / int64(time.Millisecond))
+
+	buf.WriteByte(byte(DateMarker))
+	spec.PutUint64(math.Float64bits(millis), buf)
+
+	// The timezone is reserved, and should be set to 0x0000.
+	_, err := spec.PutUint16(0, buf)
+	return err
+}
+
+// encodeKey writes the UTF-8 encoded key of an object or ECMA array property.
+func encodeKey(buf *encodeBuffer, key string) error {
+	if len(key) > math.MaxUint16 {
+		return ErrKeyTooLong
+	}
+
+	spec.PutUint16(uint16(len(key)), buf)
+	_, err := buf.WriteString(key)
+
+	return err
+}
+
+// encodeEnd writes the empty key and object end marker which terminate the
+// properties of an object or ECMA array.
+func encodeEnd(buf *encodeBuffer) error {
+	_, err := buf.Write([]byte{0x00, 0x00, byte(ObjectEndMarker)})
+	return err
+}
+
+func encodeMap(buf *encodeBuffer, v reflect.Value, depth int) error {
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	if v.Type() == ecmaArrayType {
+		buf.WriteByte(byte(ECMAArrayMarker))
+		spec.PutUint32(uint32(len(keys)), buf)
+	} else {
+		buf.WriteByte(byte(ObjectMarker))
+	}
+
+	return encodePairs(buf, v, keys, depth)
+}
+
+// encodeTypedObject writes the given TypedObject: its class name, followed by
+// its properties in order of their keys. The class name is subject to the same
+// limit on its length as keys are.
+func encodeTypedObject(buf *encodeBuffer, obj TypedObject, depth int) error {
+	buf.WriteByte(byte(TypedObjectMarker))
+	if err := encodeKey(buf, obj.ClassName); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(obj.Properties))
+	for k := range obj.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return encodePairs(buf, reflect.ValueOf(obj.Properties), keys, depth)
+}
+
+// encodePairs writes the values of the given map under each of the given keys,
+// in order, followed by the end of the properties.
+func encodePairs(buf *encodeBuffer, v reflect.Value, keys []string, depth int) error {
+	for _, k := range keys {
+		if err := encodeKey(buf, k); err != nil {
+			return err
+		}
+
+		key := reflect.ValueOf(k).Convert(v.Type().Key())
+		if err := encode(buf, v.MapIndex(key), depth+1); err != nil {
+			return err
+		}
+	}
+
+	return encodeEnd(buf)
+}
+
+func encodeStruct(buf *encodeBuffer, v reflect.Value, depth int) error {
+	buf.WriteByte(byte(ObjectMarker))
+
+	for _, f := range fields(v.Type()) {
+		fv := v.Field(f.index)
+		if f.omitEmpty && isEmpty(fv) {
+			continue
+		}
+
+		if err := encodeKey(buf, f.name); err != nil {
+			return err
+		}
+
+		if err := encode(buf, fv, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return encodeEnd(buf)
+}
+
+func encodeArray(buf *encodeBuffer, v reflect.Value, depth int) error {
+	buf.WriteByte(byte(StrictArrayMarker))
+	spec.PutUint32(uint32(v.Len()), buf)
+
+	for i := 0; i < v.Len(); i++ {
+		if err := encode(buf, v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// field describes a single encoded field of a struct.
+type field struct {
+	// name is the key that the field is encoded with.
+	name string
+	// index is the index of the field within its struct.
+	index int
+	// omitEmpty is true if the field is skipped when it is empty.
+	omitEmpty bool
+}
+
+// fields returns the encoded fields of the given struct type, in order.
+func fields(t reflect.Type) []field {
+	var fs []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		f := field{name: sf.Name, index: i}
+
+		if tag := sf.Tag.Get("amf0"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				f.name = parts[0]
+			}
+
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					f.omitEmpty = true
+				}
+			}
+		}
+
+		fs = append(fs, f)
+	}
+
+	return fs
+}
+
+// isEmpty returns whether or not v holds an empty value, as defined by the
+// "omitempty" option.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
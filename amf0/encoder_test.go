@@ -0,0 +1,203 @@
+package amf0_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/amf0"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalEncodesSimpleValues(t *testing.T) {
+	for _, c := range []struct {
+		Value    interface{}
+		Expected []byte
+	}{
+		{nil, []byte{0x05}},
+		{amf0.Undefined{}, []byte{0x06}},
+		{true, []byte{0x01, 0x01}},
+		{false, []byte{0x01, 0x00}},
+		{1.5, []byte{0x00, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{uint8(1), []byte{0x00, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{-2, []byte{0x00, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"foo", []byte{0x02, 0x00, 0x03, 0x66, 0x6f, 0x6f}},
+		{(*int)(nil), []byte{0x05}},
+		{[]int(nil), []byte{0x05}},
+		{time.Unix(1000000000, 0), []byte{
+			0x0b, 0x42, 0x6d, 0x1a, 0x94, 0xa2, 0x00, 0x00, 0x00,
+			0x00, 0x00,
+		}},
+	} {
+		b, err := amf0.Marshal(c.Value)
+
+		assert.Nil(t, err)
+		assert.Equal(t, c.Expected, b, "%#v", c.Value)
+	}
+}
+
+func TestMarshalEncodesLongStrings(t *testing.T) {
+	s := strings.Repeat("a", 0x10000)
+
+	b, err := amf0.Marshal(s)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x0c, 0x00, 0x01, 0x00, 0x00}, b[:5])
+	assert.Len(t, b, 5+len(s))
+}
+
+func TestLongStringsRoundTripAtTheBoundary(t *testing.T) {
+	for _, c := range []struct {
+		Length int
+		Prefix []byte
+	}{
+		{0xffff, []byte{0x02, 0xff, 0xff}},
+		{0x10000, []byte{0x0c, 0x00, 0x01, 0x00, 0x00}},
+	} {
+		s := strings.Repeat("a", c.Length)
+		in := map[string]interface{}{"s": s}
+
+		b, err := amf0.Marshal(in)
+		assert.Nil(t, err)
+		assert.Equal(t, c.Prefix, b[4:4+len(c.Prefix)], "%d", c.Length)
+
+		var out interface{}
+		assert.Nil(t, amf0.Unmarshal(b, &out))
+		assert.Equal(t, in, out, "%d", c.Length)
+	}
+}
+
+func TestEncoderRejectsLongStringsWhenDisabled(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := amf0.NewEncoder(buf)
+	enc.SetLongStrings(false)
+
+	assert.Nil(t, enc.Encode(strings.Repeat("a", 0xffff)))
+	assert.Len(t, buf.Bytes(), 3+0xffff)
+
+	buf.Reset()
+	err := enc.Encode([]string{strings.Repeat("a", 0x10000)})
+
+	assert.Equal(t, amf0.ErrStringTooLong, err)
+	assert.Empty(t, buf.Bytes())
+}
+
+func TestMarshalEncodesMapsInKeyOrder(t *testing.T) {
+	b, err := amf0.Marshal(map[string]interface{}{"b": true, "a": nil})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0x03,
+		0x00, 0x01, 0x61, 0x05, // "a": null
+		0x00, 0x01, 0x62, 0x01, 0x01, // "b": true
+		0x00, 0x00, 0x09,
+	}, b)
+}
+
+func TestMarshalEncodesECMAArrays(t *testing.T) {
+	b, err := amf0.Marshal(amf0.ECMAArray{"a": false})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0x08, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x01, 0x61, 0x01, 0x00, // "a": false
+		0x00, 0x00, 0x09,
+	}, b)
+}
+
+func TestMarshalEncodesStrictArrays(t *testing.T) {
+	b, err := amf0.Marshal([]interface{}{"a", false})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0x0a, 0x00, 0x00, 0x00, 0x02,
+		0x02, 0x00, 0x01, 0x61,
+		0x01, 0x00,
+	}, b)
+}
+
+func TestMarshalEncodesStructsInFieldOrder(t *testing.T) {
+	b, err := amf0.Marshal(&struct {
+		Level   string `amf0:"level"`
+		Code    string `amf0:"code,omitempty"`
+		Skipped bool   `amf0:"-"`
+		skipped bool
+		Empty   *int `amf0:",omitempty"`
+		Flag    bool
+	}{Level: "status", Flag: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0x03,
+		0x00, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, // "level"
+		0x02, 0x00, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+		0x00, 0x04, 0x46, 0x6c, 0x61, 0x67, 0x01, 0x01, // "Flag": true
+		0x00, 0x00, 0x09,
+	}, b)
+}
+
+func TestMarshalRejectsUnsupportedTypes(t *testing.T) {
+	for _, v := range []interface{}{
+		make(chan int),
+		map[int]string{1: "a"},
+		[]interface{}{func() {}},
+	} {
+		b, err := amf0.Marshal(v)
+
+		assert.Nil(t, b)
+		assert.IsType(t, new(amf0.UnsupportedTypeError), err)
+	}
+}
+
+func TestMarshalRejectsCyclicValues(t *testing.T) {
+	v := []interface{}{nil}
+	v[0] = v
+
+	_, err := amf0.Marshal(v)
+
+	assert.Equal(t, amf0.ErrMaxDepth, err)
+}
+
+func TestEncoderWritesNothingOnError(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	err := amf0.NewEncoder(buf).Encode([]interface{}{1, make(chan int)})
+
+	assert.NotNil(t, err)
+	assert.Empty(t, buf.Bytes())
+}
+
+func TestMarshalEncodesTypedObjects(t *testing.T) {
+	obj := amf0.TypedObject{
+		ClassName:  "Foo",
+		Properties: map[string]interface{}{"name": "bar"},
+	}
+
+	b, err := amf0.Marshal(&obj)
+
+	assert.Nil(t, err)
+	assert.Equal(t, TypedObject, b)
+}
+
+func TestTypedObjectsAndDatesRoundTrip(t *testing.T) {
+	in := []interface{}{
+		amf0.TypedObject{
+			ClassName: "flex.messaging.io.ArrayCollection",
+			Properties: map[string]interface{}{
+				"created": time.Unix(1000000000, 0).UTC(),
+				"nested": amf0.TypedObject{
+					ClassName:  "Bar",
+					Properties: map[string]interface{}{},
+				},
+			},
+		},
+	}
+
+	b, err := amf0.Marshal(in)
+	assert.Nil(t, err)
+
+	var out interface{}
+	assert.Nil(t, amf0.Unmarshal(b, &out))
+	assert.Equal(t, in, out)
+}
@@ -0,0 +1,37 @@
+//go:build gofuzz
+// +build gofuzz
+
+package amf0
+
+import "bytes"
+
+// Fuzz is the entry point used by go-fuzz. Any value which can be decoded must
+// be encodable again, and encoding the result of decoding that encoding must
+// produce the same bytes.
+func Fuzz(data []byte) int {
+	var v interface{}
+	if err := Unmarshal(data, &v); err != nil {
+		return 0
+	}
+
+	encoded, err := Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+
+	var w interface{}
+	if err := Unmarshal(encoded, &w); err != nil {
+		panic(err)
+	}
+
+	reencoded, err := Marshal(w)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(encoded, reencoded) {
+		panic("amf0: encoding is not stable")
+	}
+
+	return 1
+}
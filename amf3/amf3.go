@@ -0,0 +1,77 @@
+// Package amf3 implements a decoder for the AMF3 serialization format, as
+// defined in Adobe's "Action Message Format -- AMF 3" specification.
+//
+// AMF3 values are most commonly seen in RTMP embedded within AMF0 payloads,
+// where they are prefixed with the AMF0 "avmplus-object" marker (0x11). The
+// Transcode function rewrites such payloads into pure AMF0, so that they may be
+// consumed by AMF0-only decoders.
+package amf3
+
+// Marker is a single-byte value that prefixes each AMF3 value, and determines
+// its type.
+type Marker byte
+
+const (
+	UndefinedMarker Marker = 0x00
+	NullMarker      Marker = 0x01
+	FalseMarker     Marker = 0x02
+	TrueMarker      Marker = 0x03
+	IntegerMarker   Marker = 0x04
+	DoubleMarker    Marker = 0x05
+	StringMarker    Marker = 0x06
+	XMLDocMarker    Marker = 0x07
+	DateMarker      Marker = 0x08
+	ArrayMarker     Marker = 0x09
+	ObjectMarker    Marker = 0x0a
+	XMLMarker       Marker = 0x0b
+	ByteArrayMarker Marker = 0x0c
+)
+
+// Undefined represents the AMF3 undefined value.
+type Undefined struct{}
+
+// XML represents an AMF3 XMLDocument or XML value, holding its serialized
+// form.
+type XML string
+
+// Pair is a single key-value pair belonging to either an Object, or the
+// associative portion of an Array.
+type Pair struct {
+	// Key is the name of the pair.
+	Key string
+	// Value is the decoded value of the pair.
+	Value interface{}
+}
+
+// Array represents an AMF3 array, which has both a dense (ordinal) portion and
+// an associative portion.
+type Array struct {
+	// Associative holds the key-value pairs of the array, in the order in
+	// which they were read.
+	Associative []Pair
+	// Dense holds the values of the dense portion of the array.
+	Dense []interface{}
+}
+
+// Object represents an AMF3 object, which is an optionally named collection
+// of both sealed and dynamic properties.
+type Object struct {
+	// Class is the class name of the object, or an empty string if the
+	// object is anonymous.
+	Class string
+	// Properties holds the sealed properties of the object, followed by
+	// its dynamic properties, in the order in which they were read.
+	Properties []Pair
+}
+
+// Get returns the value of the property with the given key, and whether or not
+// it was present.
+func (o *Object) Get(key string) (interface{}, bool) {
+	for _, p := range o.Properties {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+
+	return nil, false
+}
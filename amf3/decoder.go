@@ -0,0 +1,388 @@
+package amf3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/WatchBeam/rtmp/spec"
+)
+
+var (
+	// ErrExternalizable is returned when an externalizable object is read.
+	// The serialized form of externalizable objects is determined by their
+	// class, and cannot be decoded generically.
+	ErrExternalizable = errors.New(
+		"rtmp/amf3: externalizable objects are not supported")
+)
+
+// UnknownMarker is an error returned when a marker that is not supported by
+// the Decoder is read.
+type UnknownMarker byte
+
+var _ error = new(UnknownMarker)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e UnknownMarker) Error() string {
+	return fmt.Sprintf("rtmp/amf3: unknown marker (%#x)", byte(e))
+}
+
+// InvalidReference is an error returned when a reference is read which points
+// outside of its reference table.
+type InvalidReference uint32
+
+var _ error = new(InvalidReference)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e InvalidReference) Error() string {
+	return fmt.Sprintf("rtmp/amf3: invalid reference (%v)", uint32(e))
+}
+
+// traits describes the layout of an AMF3 object.
+type traits struct {
+	class          string
+	externalizable bool
+	dynamic        bool
+	members        []string
+}
+
+// Decoder decodes AMF3 values from an io.Reader. Each Decoder maintains its own
+// string, object, and traits reference tables, which are shared between all
+// values that it decodes.
+//
+// The Decoder never reads past the end of the value that it is decoding.
+type Decoder struct {
+	r io.Reader
+
+	strings []string
+	objects []interface{}
+	traits  []*traits
+}
+
+// NewDecoder returns a new *Decoder reading from the given io.Reader, with
+// empty reference tables.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode decodes a single AMF3 value from the given io.Reader using a new
+// Decoder.
+func Decode(r io.Reader) (interface{}, error) {
+	return NewDecoder(r).Decode()
+}
+
+// Decode reads and returns the next AMF3 value. Values are decoded as follows:
+//
+//	undefined      Undefined
+//	null           nil
+//	false, true    bool
+//	integer        int32
+//	double         float64
+//	string         string
+//	XMLDocument    XML
+//	XML            XML
+//	date           time.Time
+//	array          *Array
+//	object         *Object
+//	ByteArray      []byte
+//
+// Any other marker results in an UnknownMarker error.
+func (d *Decoder) Decode() (interface{}, error) {
+	m, err := spec.ReadByte(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch Marker(m) {
+	case UndefinedMarker:
+		return Undefined{}, nil
+	case NullMarker:
+		return nil, nil
+	case FalseMarker:
+		return false, nil
+	case TrueMarker:
+		return true, nil
+	case IntegerMarker:
+		return d.readInteger()
+	case DoubleMarker:
+		return d.readDouble()
+	case StringMarker:
+		return d.readString()
+	case XMLDocMarker, XMLMarker:
+		return d.readXML()
+	case DateMarker:
+		return d.readDate()
+	case ArrayMarker:
+		return d.readArray()
+	case ObjectMarker:
+		return d.readObject()
+	case ByteArrayMarker:
+		return d.readByteArray()
+	}
+
+	return nil, UnknownMarker(m)
+}
+
+// readU29 reads a variable-length, 29-bit unsigned integer.
+func (d *Decoder) readU29() (uint32, error) {
+	var n uint32
+	for i := 0; i < 4; i++ {
+		b, err := spec.ReadByte(d.r)
+		if err != nil {
+			return 0, err
+		}
+
+		if i == 3 {
+			return n<<8 | uint32(b), nil
+		}
+
+		n = n<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	return n, nil
+}
+
+func (d *Decoder) readInteger() (int32, error) {
+	n, err := d.readU29()
+	if err != nil {
+		return 0, err
+	}
+
+	if n&0x10000000 != 0 {
+		return int32(n) - (1 << 29), nil
+	}
+
+	return int32(n), nil
+}
+
+func (d *Decoder) readDouble() (float64, error) {
+	buf, err := spec.ReadBytes(d.r, 8)
+	if err != nil {
+		return 0, err
+	}
+
+	return math.Float64frombits(spec.Uint64(buf)), nil
+}
+
+func (d *Decoder) readString() (string, error) {
+	ref, err := d.readU29()
+	if err != nil {
+		return "", err
+	}
+
+	if ref&1 == 0 {
+		if int(ref>>1) >= len(d.strings) {
+			return "", InvalidReference(ref >> 1)
+		}
+
+		return d.strings[ref>>1], nil
+	}
+
+	buf, err := spec.ReadBytes(d.r, int(ref>>1))
+	if err != nil {
+		return "", err
+	}
+
+	s := string(buf)
+	if len(s) > 0 {
+		d.strings = append(d.strings, s)
+	}
+
+	return s, nil
+}
+
+// readObjectRef reads the U29 header shared by all values stored in the object
+// reference table. If the header is a reference, the referenced value is
+// returned along with ok=false. Otherwise, the remaining bits of the header are
+// returned along with ok=true.
+func (d *Decoder) readObjectRef() (v interface{}, n uint32, ok bool, err error) {
+	ref, err := d.readU29()
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	if ref&1 == 0 {
+		if int(ref>>1) >= len(d.objects) {
+			return nil, 0, false, InvalidReference(ref >> 1)
+		}
+
+		return d.objects[ref>>1], 0, false, nil
+	}
+
+	return nil, ref >> 1, true, nil
+}
+
+func (d *Decoder) readXML() (interface{}, error) {
+	v, n, ok, err := d.readObjectRef()
+	if err != nil || !ok {
+		return v, err
+	}
+
+	buf, err := spec.ReadBytes(d.r, int(n))
+	if err != nil {
+		return nil, err
+	}
+
+	x := XML(buf)
+	d.objects = append(d.objects, x)
+
+	return x, nil
+}
+
+func (d *Decoder) readByteArray() (interface{}, error) {
+	v, n, ok, err := d.readObjectRef()
+	if err != nil || !ok {
+		return v, err
+	}
+
+	buf, err := spec.ReadBytes(d.r, int(n))
+	if err != nil {
+		return nil, err
+	}
+
+	d.objects = append(d.objects, buf)
+
+	return buf, nil
+}
+
+func (d *Decoder) readDate() (interface{}, error) {
+	v, _, ok, err := d.readObjectRef()
+	if err != nil || !ok {
+		return v, err
+	}
+
+	millis, err := d.readDouble()
+	if err != nil {
+		return nil, err
+	}
+
+	t := time.Unix(0, int64(millis*float64(time.Millisecond))).UTC()
+	d.objects = append(d.objects, t)
+
+	return t, nil
+}
+
+func (d *Decoder) readArray() (interface{}, error) {
+	v, n, ok, err := d.readObjectRef()
+	if err != nil || !ok {
+		return v, err
+	}
+
+	arr := new(Array)
+	d.objects = append(d.objects, arr)
+
+	for {
+		key, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(key) == 0 {
+			break
+		}
+
+		val, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+
+		arr.Associative = append(arr.Associative, Pair{key, val})
+	}
+
+	arr.Dense = make([]interface{}, n)
+	for i := range arr.Dense {
+		if arr.Dense[i], err = d.Decode(); err != nil {
+			return nil, err
+		}
+	}
+
+	return arr, nil
+}
+
+func (d *Decoder) readTraits(n uint32) (*traits, error) {
+	if n&1 == 0 {
+		if int(n>>1) >= len(d.traits) {
+			return nil, InvalidReference(n >> 1)
+		}
+
+		return d.traits[n>>1], nil
+	}
+
+	t := &traits{
+		externalizable: n&2 != 0,
+		dynamic:        n&4 != 0,
+		members:        make([]string, n>>3),
+	}
+
+	var err error
+	if t.class, err = d.readString(); err != nil {
+		return nil, err
+	}
+
+	for i := range t.members {
+		if t.members[i], err = d.readString(); err != nil {
+			return nil, err
+		}
+	}
+
+	d.traits = append(d.traits, t)
+
+	return t, nil
+}
+
+func (d *Decoder) readObject() (interface{}, error) {
+	v, n, ok, err := d.readObjectRef()
+	if err != nil || !ok {
+		return v, err
+	}
+
+	t, err := d.readTraits(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.externalizable {
+		return nil, ErrExternalizable
+	}
+
+	obj := &Object{Class: t.class}
+	d.objects = append(d.objects, obj)
+
+	for _, member := range t.members {
+		val, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+
+		obj.Properties = append(obj.Properties, Pair{member, val})
+	}
+
+	if !t.dynamic {
+		return obj, nil
+	}
+
+	for {
+		key, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(key) == 0 {
+			break
+		}
+
+		val, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+
+		obj.Properties = append(obj.Properties, Pair{key, val})
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,123 @@
+package amf3_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/amf3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoderDecodesSimpleValues(t *testing.T) {
+	for _, c := range []struct {
+		Bytes    []byte
+		Expected interface{}
+	}{
+		{[]byte{0x00}, amf3.Undefined{}},
+		{[]byte{0x01}, nil},
+		{[]byte{0x02}, false},
+		{[]byte{0x03}, true},
+		{[]byte{0x04, 0x7f}, int32(127)},
+		{[]byte{0x04, 0x81, 0x00}, int32(128)},
+		{[]byte{0x04, 0xff, 0xff, 0xff, 0xff}, int32(-1)},
+		{[]byte{0x04, 0xbf, 0xff, 0xff, 0xff}, int32(0x0fffffff)},
+		{[]byte{
+			0x05, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		}, float64(1.5)},
+		{[]byte{0x06, 0x07, 0x66, 0x6f, 0x6f}, "foo"},
+		{[]byte{0x06, 0x01}, ""},
+		{[]byte{0x0b, 0x07, 0x3c, 0x61, 0x2f}, amf3.XML("<a/")},
+		{[]byte{
+			0x08, 0x01, 0x42, 0x6d, 0x1a, 0x94, 0xa2, 0x00, 0x00, 0x00,
+		}, time.Unix(1000000000, 0).UTC()},
+		{[]byte{0x0c, 0x05, 0x01, 0x02}, []byte{0x01, 0x02}},
+	} {
+		v, err := amf3.Decode(bytes.NewReader(c.Bytes))
+
+		assert.Nil(t, err)
+		assert.Equal(t, c.Expected, v)
+	}
+}
+
+func TestDecoderDecodesArrays(t *testing.T) {
+	v, err := amf3.Decode(bytes.NewReader([]byte{
+		0x09, 0x05, // array with 2 dense values
+		0x03, 0x61, 0x04, 0x01, // "a": 1
+		0x01,             // end of associative portion
+		0x06, 0x03, 0x62, // "b"
+		0x06, 0x00, // reference to "a"
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &amf3.Array{
+		Associative: []amf3.Pair{{"a", int32(1)}},
+		Dense:       []interface{}{"b", "a"},
+	}, v)
+}
+
+func TestDecoderDecodesObjects(t *testing.T) {
+	d := amf3.NewDecoder(bytes.NewReader([]byte{
+		// dynamic object, with 1 sealed member
+		0x0a, 0x1b, 0x07, 0x46, 0x6f, 0x6f, 0x03, 0x61,
+		0x04, 0x01, // a: 1
+		0x03, 0x62, 0x03, // b: true
+		0x01, // end of dynamic members
+		// object re-using the traits above
+		0x0a, 0x01, 0x04, 0x02, 0x01,
+		// reference to the first object
+		0x0a, 0x00,
+	}))
+
+	first, err := d.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, &amf3.Object{
+		Class: "Foo",
+		Properties: []amf3.Pair{
+			{"a", int32(1)},
+			{"b", true},
+		},
+	}, first)
+
+	second, err := d.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, &amf3.Object{
+		Class:      "Foo",
+		Properties: []amf3.Pair{{"a", int32(2)}},
+	}, second)
+
+	third, err := d.Decode()
+	assert.Nil(t, err)
+	assert.True(t, first == third)
+
+	b, ok := first.(*amf3.Object).Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, true, b)
+}
+
+func TestDecoderRejectsExternalizableObjects(t *testing.T) {
+	_, err := amf3.Decode(bytes.NewReader([]byte{0x0a, 0x07, 0x01}))
+
+	assert.Equal(t, amf3.ErrExternalizable, err)
+}
+
+func TestDecoderRejectsInvalidReferences(t *testing.T) {
+	_, err := amf3.Decode(bytes.NewReader([]byte{0x06, 0x02}))
+
+	assert.Equal(t, amf3.InvalidReference(1), err)
+	assert.Equal(t, "rtmp/amf3: invalid reference (1)", err.Error())
+}
+
+func TestDecoderRejectsUnknownMarkers(t *testing.T) {
+	_, err := amf3.Decode(bytes.NewReader([]byte{0x11}))
+
+	assert.Equal(t, amf3.UnknownMarker(0x11), err)
+	assert.Equal(t, "rtmp/amf3: unknown marker (0x11)", err.Error())
+}
+
+func TestDecoderReturnsReadErrors(t *testing.T) {
+	_, err := amf3.Decode(bytes.NewReader([]byte{0x06, 0x07, 0x66}))
+
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
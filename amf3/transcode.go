@@ -0,0 +1,381 @@
+package amf3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/WatchBeam/rtmp/spec"
+)
+
+const (
+	// AVMPlusObjectMarker is the AMF0 marker which signals that the value
+	// following it is encoded using AMF3.
+	AVMPlusObjectMarker byte = 0x11
+)
+
+// AMF0 markers, as defined in Adobe's "Action Message Format -- AMF 0"
+// specification.
+const (
+	amf0Number      byte = 0x00
+	amf0Boolean     byte = 0x01
+	amf0String      byte = 0x02
+	amf0Object      byte = 0x03
+	amf0Null        byte = 0x05
+	amf0Undefined   byte = 0x06
+	amf0Reference   byte = 0x07
+	amf0EcmaArray   byte = 0x08
+	amf0ObjectEnd   byte = 0x09
+	amf0StrictArray byte = 0x0a
+	amf0Date        byte = 0x0b
+	amf0LongString  byte = 0x0c
+	amf0Unsupported byte = 0x0d
+	amf0XMLDocument byte = 0x0f
+	amf0TypedObject byte = 0x10
+)
+
+var (
+	// ErrCyclicValue is returned by Transcode when an AMF3 value refers to
+	// itself, and therefore cannot be written as AMF0.
+	ErrCyclicValue = errors.New("rtmp/amf3: cannot transcode cyclic value")
+)
+
+// UnknownAMF0Marker is an error returned by Transcode when an AMF0 marker that
+// it does not understand is read.
+type UnknownAMF0Marker byte
+
+var _ error = new(UnknownAMF0Marker)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e UnknownAMF0Marker) Error() string {
+	return fmt.Sprintf("rtmp/amf3: unknown AMF0 marker (%#x)", byte(e))
+}
+
+// Transcode reads a sequence of AMF0 values from src until io.EOF, and writes
+// them to dst. AMF0 values are copied verbatim, except for AMF3 values
+// (prefixed with the AVMPlusObjectMarker), which are decoded and written as
+// their AMF0 equivalent:
+//
+//	Undefined      undefined
+//	nil            null
+//	bool           boolean
+//	int32, float64 number
+//	string         string, or long string if longer than 65535 bytes
+//	XML            XML document
+//	time.Time      date
+//	*Array         strict array, or ECMA array if it has associative pairs
+//	*Object        anonymous object (the class name is dropped)
+//
+// ByteArrays have no AMF0 equivalent, and result in an error.
+func Transcode(dst io.Writer, src io.Reader) error {
+	t := &transcoder{src: src, dst: dst}
+
+	for {
+		m, err := spec.ReadByte(src)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err = t.value(m); err != nil {
+			return err
+		}
+	}
+}
+
+// transcoder copies AMF0 values from src to dst, converting embedded AMF3
+// values along the way.
+type transcoder struct {
+	src io.Reader
+	dst io.Writer
+}
+
+// copy copies exactly n bytes from src to dst.
+func (t *transcoder) copy(n int64) error {
+	_, err := io.CopyN(t.dst, t.src, n)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// copyLength copies a big-endian length of the given size (in bytes) and the
+// number of bytes it describes, returning the length.
+func (t *transcoder) copyLength(size int) (uint32, error) {
+	buf, err := spec.ReadBytes(t.src, size)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = t.dst.Write(buf); err != nil {
+		return 0, err
+	}
+
+	n := spec.Uint32(buf)
+	return n, t.copy(int64(n))
+}
+
+func (t *transcoder) value(m byte) error {
+	if m == AVMPlusObjectMarker {
+		v, err := Decode(t.src)
+		if err != nil {
+			return err
+		}
+
+		return writeAMF0(t.dst, v, make(map[interface{}]bool))
+	}
+
+	if _, err := t.dst.Write([]byte{m}); err != nil {
+		return err
+	}
+
+	switch m {
+	case amf0Number:
+		return t.copy(8)
+	case amf0Boolean:
+		return t.copy(1)
+	case amf0String:
+		_, err := t.copyLength(2)
+		return err
+	case amf0Object:
+		return t.pairs()
+	case amf0Null, amf0Undefined, amf0Unsupported:
+		return nil
+	case amf0Reference:
+		return t.copy(2)
+	case amf0EcmaArray:
+		if err := t.copy(4); err != nil {
+			return err
+		}
+
+		return t.pairs()
+	case amf0StrictArray:
+		buf, err := spec.ReadBytes(t.src, 4)
+		if err != nil {
+			return err
+		}
+
+		if _, err = t.dst.Write(buf); err != nil {
+			return err
+		}
+
+		for i := uint32(0); i < spec.Uint32(buf); i++ {
+			m, err := spec.ReadByte(t.src)
+			if err != nil {
+				return err
+			}
+
+			if err = t.value(m); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case amf0Date:
+		return t.copy(10)
+	case amf0LongString, amf0XMLDocument:
+		_, err := t.copyLength(4)
+		return err
+	case amf0TypedObject:
+		if _, err := t.copyLength(2); err != nil {
+			return err
+		}
+
+		return t.pairs()
+	}
+
+	return UnknownAMF0Marker(m)
+}
+
+// pairs copies the key-value pairs of an AMF0 object or ECMA array, up to and
+// including the object-end marker.
+func (t *transcoder) pairs() error {
+	for {
+		n, err := t.copyLength(2)
+		if err != nil {
+			return err
+		}
+
+		m, err := spec.ReadByte(t.src)
+		if err != nil {
+			return err
+		}
+
+		if n == 0 && m == amf0ObjectEnd {
+			_, err = t.dst.Write([]byte{m})
+			return err
+		}
+
+		if err = t.value(m); err != nil {
+			return err
+		}
+	}
+}
+
+// writeAMF0 writes the decoded AMF3 value v to w as AMF0. The seen map holds
+// the arrays and objects that are currently being written, and is used to
+// detect cycles.
+func writeAMF0(w io.Writer, v interface{}, seen map[interface{}]bool) error {
+	switch v := v.(type) {
+	case nil:
+		_, err := w.Write([]byte{amf0Null})
+		return err
+	case Undefined:
+		_, err := w.Write([]byte{amf0Undefined})
+		return err
+	case bool:
+		b := byte(0)
+		if v {
+			b = 1
+		}
+
+		_, err := w.Write([]byte{amf0Boolean, b})
+		return err
+	case int32:
+		return writeNumber(w, float64(v))
+	case float64:
+		return writeNumber(w, v)
+	case string:
+		return writeString(w, v)
+	case XML:
+		if _, err := w.Write([]byte{amf0XMLDocument}); err != nil {
+			return err
+		}
+
+		return writeUTF8(w, string(v), 4)
+	case time.Time:
+		if _, err := w.Write([]byte{amf0Date}); err != nil {
+			return err
+		}
+
+		millis := float64(v.UnixNano()) / float64(time.Millisecond)
+		if _, err := spec.PutUint64(math.Float64bits(millis), w); err != nil {
+			return err
+		}
+
+		_, err := spec.PutUint16(0, w)
+		return err
+	case *Array:
+		if seen[v] {
+			return ErrCyclicValue
+		}
+		seen[v] = true
+		defer delete(seen, v)
+
+		return writeArray(w, v, seen)
+	case *Object:
+		if seen[v] {
+			return ErrCyclicValue
+		}
+		seen[v] = true
+		defer delete(seen, v)
+
+		if _, err := w.Write([]byte{amf0Object}); err != nil {
+			return err
+		}
+
+		return writePairs(w, v.Properties, seen)
+	}
+
+	return fmt.Errorf("rtmp/amf3: cannot transcode %T to AMF0", v)
+}
+
+func writeNumber(w io.Writer, n float64) error {
+	if _, err := w.Write([]byte{amf0Number}); err != nil {
+		return err
+	}
+
+	_, err := spec.PutUint64(math.Float64bits(n), w)
+	return err
+}
+
+func writeString(w io.Writer, s string) error {
+	if len(s) > math.MaxUint16 {
+		if _, err := w.Write([]byte{amf0LongString}); err != nil {
+			return err
+		}
+
+		return writeUTF8(w, s, 4)
+	}
+
+	if _, err := w.Write([]byte{amf0String}); err != nil {
+		return err
+	}
+
+	return writeUTF8(w, s, 2)
+}
+
+// writeUTF8 writes s to w, prefixed by its length as a big-endian integer of
+// the given size (in bytes).
+func writeUTF8(w io.Writer, s string, size int) error {
+	var err error
+	if size == 2 {
+		_, err = spec.PutUint16(uint16(len(s)), w)
+	} else {
+		_, err = spec.PutUint32(uint32(len(s)), w)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, s)
+	return err
+}
+
+func writeArray(w io.Writer, a *Array, seen map[interface{}]bool) error {
+	if len(a.Associative) == 0 {
+		if _, err := w.Write([]byte{amf0StrictArray}); err != nil {
+			return err
+		}
+
+		if _, err := spec.PutUint32(uint32(len(a.Dense)), w); err != nil {
+			return err
+		}
+
+		for _, v := range a.Dense {
+			if err := writeAMF0(w, v, seen); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	pairs := make([]Pair, 0, len(a.Dense)+len(a.Associative))
+	for i, v := range a.Dense {
+		pairs = append(pairs, Pair{strconv.Itoa(i), v})
+	}
+	pairs = append(pairs, a.Associative...)
+
+	if _, err := w.Write([]byte{amf0EcmaArray}); err != nil {
+		return err
+	}
+
+	if _, err := spec.PutUint32(uint32(len(pairs)), w); err != nil {
+		return err
+	}
+
+	return writePairs(w, pairs, seen)
+}
+
+// writePairs writes the given pairs to w, followed by the object-end marker.
+func writePairs(w io.Writer, pairs []Pair, seen map[interface{}]bool) error {
+	for _, p := range pairs {
+		if err := writeUTF8(w, p.Key, 2); err != nil {
+			return err
+		}
+
+		if err := writeAMF0(w, p.Value, seen); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{0x00, 0x00, amf0ObjectEnd})
+	return err
+}
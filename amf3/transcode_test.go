@@ -0,0 +1,80 @@
+package amf3_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/amf3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranscodeCopiesAMF0Verbatim(t *testing.T) {
+	amf0 := []byte{
+		0x02, 0x00, 0x04, 0x70, 0x6c, 0x61, 0x79, // "play"
+		0x00, 0x40, 0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // 4.25
+		0x05,                                                 // null
+		0x03, 0x00, 0x01, 0x61, 0x01, 0x01, 0x00, 0x00, 0x09, // {a: true}
+		0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x09, // ECMA array
+		0x0a, 0x00, 0x00, 0x00, 0x01, 0x06, // [undefined]
+	}
+
+	buf := new(bytes.Buffer)
+	err := amf3.Transcode(buf, bytes.NewReader(amf0))
+
+	assert.Nil(t, err)
+	assert.Equal(t, amf0, buf.Bytes())
+}
+
+func TestTranscodeRewritesAMF3Values(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := amf3.Transcode(buf, bytes.NewReader([]byte{
+		0x11, 0x06, 0x07, 0x66, 0x6f, 0x6f, // "foo"
+		0x11, 0x04, 0x05, // 5
+		0x11, 0x0a, 0x0b, 0x01, // anonymous, dynamic object
+		0x03, 0x61, 0x03, // a: true
+		0x01,
+		0x11, 0x09, 0x03, 0x01, 0x01, // [null]
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0x02, 0x00, 0x03, 0x66, 0x6f, 0x6f,
+		0x00, 0x40, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x03, 0x00, 0x01, 0x61, 0x01, 0x01, 0x00, 0x00, 0x09,
+		0x0a, 0x00, 0x00, 0x00, 0x01, 0x05,
+	}, buf.Bytes())
+}
+
+func TestTranscodeWritesAssociativeArraysAsECMAArrays(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := amf3.Transcode(buf, bytes.NewReader([]byte{
+		0x11, 0x09, 0x03, // array with 1 dense value
+		0x03, 0x61, 0x02, // a: false
+		0x01,
+		0x03, // true
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0x08, 0x00, 0x00, 0x00, 0x02,
+		0x00, 0x01, 0x30, 0x01, 0x01, // 0: true
+		0x00, 0x01, 0x61, 0x01, 0x00, // a: false
+		0x00, 0x00, 0x09,
+	}, buf.Bytes())
+}
+
+func TestTranscodeRejectsCyclicValues(t *testing.T) {
+	err := amf3.Transcode(new(bytes.Buffer), bytes.NewReader([]byte{
+		0x11, 0x0a, 0x0b, 0x01, // anonymous, dynamic object
+		0x03, 0x61, 0x0a, 0x00, // a: reference to itself
+		0x01,
+	}))
+
+	assert.Equal(t, amf3.ErrCyclicValue, err)
+}
+
+func TestTranscodeRejectsUnknownAMF0Markers(t *testing.T) {
+	err := amf3.Transcode(new(bytes.Buffer), bytes.NewReader([]byte{0x04}))
+
+	assert.Equal(t, amf3.UnknownAMF0Marker(0x04), err)
+}
@@ -0,0 +1,33 @@
+package chunk
+
+import (
+	"bytes"
+
+	"github.com/WatchBeam/rtmp/spec"
+)
+
+const (
+	// AbortMessageTypeId is the message type ID of the Abort Message
+	// protocol control message.
+	AbortMessageTypeId byte = 0x02
+)
+
+// NewAbortMessage returns a new chunk containing the Abort Message protocol
+// control message, informing the peer that the partially sent message on the
+// chunk stream identified by `streamId` will not be completed, and should be
+// discarded.
+func NewAbortMessage(streamId uint32) *Chunk {
+	data := new(bytes.Buffer)
+	spec.PutUint32(streamId, data)
+
+	return &Chunk{
+		Header: &Header{
+			BasicHeader: BasicHeader{0, ControlChunkStreamId},
+			MessageHeader: MessageHeader{
+				Length: uint32(data.Len()),
+				TypeId: AbortMessageTypeId,
+			},
+		},
+		Data: data.Bytes(),
+	}
+}
@@ -0,0 +1,55 @@
+package chunk_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAbortMessageConstructsAControlChunk(t *testing.T) {
+	c := chunk.NewAbortMessage(18)
+
+	assert.Equal(t, chunk.ControlChunkStreamId, c.StreamId())
+	assert.Equal(t, chunk.AbortMessageTypeId, c.TypeId())
+	assert.Equal(t, uint32(4), c.Header.MessageHeader.Length)
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x12}, c.Data)
+}
+
+func TestReaderDiscardsAbortedMessages(t *testing.T) {
+	aborted := &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader:   chunk.BasicHeader{0, 18},
+			MessageHeader: chunk.MessageHeader{0, 1234, false, 8, 2, 3},
+		},
+		Data: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	next := &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader:   chunk.BasicHeader{0, 18},
+			MessageHeader: chunk.MessageHeader{0, 1234, false, 8, 2, 3},
+		},
+		Data: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+	}
+
+	half := new(bytes.Buffer)
+	chunk.NewWriter(half, 4).Write(aborted)
+
+	b := new(bytes.Buffer)
+	// Only the first chunk of the aborted message: a one-byte basic
+	// header, an eleven-byte message header, and four bytes of payload.
+	b.Write(half.Bytes()[:1+11+4])
+	chunk.NewWriter(b, 4).Write(chunk.NewAbortMessage(18))
+	chunk.NewWriter(b, 4).Write(next)
+
+	r := chunk.NewReader(b, 4, chunk.NoopNormalizer)
+	go r.Recv()
+
+	abort := <-r.Chunks()
+	read := <-r.Chunks()
+
+	assert.Equal(t, 0, len(r.Errs()))
+	assert.Equal(t, chunk.AbortMessageTypeId, abort.TypeId())
+	assert.Equal(t, next, read)
+}
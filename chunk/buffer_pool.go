@@ -0,0 +1,45 @@
+package chunk
+
+import "sync"
+
+// BufferPool is a pool of buffers for the payloads of chunks read by a
+// DefaultReader (see DefaultReader.SetBufferPool), backed by a sync.Pool. It
+// saves a high-throughput server from allocating (and collecting) a new buffer
+// for each message it receives.
+//
+// A chunk whose payload is taken from a BufferPool returns it once the consumer
+// of the chunk calls Release. A consumer which never calls Release simply
+// leaves the buffer to the garbage collector, as though it were not pooled.
+//
+// A BufferPool is safe for concurrent use, and may be shared by many readers.
+type BufferPool struct {
+	// pool holds *[]byte, to avoid allocating when a slice is put back.
+	pool sync.Pool
+}
+
+// NewBufferPool returns a new, empty *BufferPool.
+func NewBufferPool() *BufferPool {
+	return new(BufferPool)
+}
+
+// get returns a buffer of length n from the pool, allocating a new one if the
+// pool holds none, or the one it holds is too small.
+func (p *BufferPool) get(n int) *[]byte {
+	if b, ok := p.pool.Get().(*[]byte); ok {
+		if cap(*b) >= n {
+			*b = (*b)[:n]
+			return b
+		}
+
+		p.pool.Put(b)
+	}
+
+	b := make([]byte, n)
+	return &b
+}
+
+// put returns the given buffer to the pool.
+func (p *BufferPool) put(b *[]byte) {
+	*b = (*b)[:0]
+	p.pool.Put(b)
+}
@@ -0,0 +1,103 @@
+package chunk_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPooledReadersReadChunksIntact(t *testing.T) {
+	c := newTestChunk(6, 9, 1, 0, 1000)
+
+	b := new(bytes.Buffer)
+	chunk.NewWriter(b, chunk.DefaultReadSize).Write(c)
+	chunk.NewWriter(b, chunk.DefaultReadSize).Write(c)
+
+	r := chunk.NewReaderWithOptions(b, chunk.NoopNormalizer,
+		chunk.PayloadPool(chunk.NewBufferPool()))
+	go r.Recv()
+
+	first := <-r.Chunks()
+	assert.Equal(t, c.Data, first.Data)
+	first.Release()
+
+	second := <-r.Chunks()
+	assert.Equal(t, c.Data, second.Data)
+	assert.Equal(t, c.Header.MessageHeader, second.Header.MessageHeader)
+}
+
+func TestReleaseClearsPooledPayloads(t *testing.T) {
+	b := new(bytes.Buffer)
+	chunk.NewWriter(b, chunk.DefaultReadSize).Write(newTestChunk(6, 9, 1, 0, 16))
+
+	r := chunk.NewReaderWithOptions(b, chunk.NoopNormalizer,
+		chunk.PayloadPool(chunk.NewBufferPool()))
+	go r.Recv()
+
+	c := <-r.Chunks()
+	c.Release()
+
+	assert.Nil(t, c.Data)
+	assert.NotPanics(t, c.Release)
+}
+
+func TestReleaseDoesNothingToUnpooledPayloads(t *testing.T) {
+	c := newTestChunk(6, 9, 1, 0, 16)
+	data := c.Data
+
+	c.Release()
+
+	assert.Equal(t, data, c.Data)
+}
+
+// benchmarkPooledReader reads b.N messages of `length` bytes, releasing each
+// one if `pooled` is true.
+func benchmarkPooledReader(b *testing.B, pooled bool, length int) {
+	c := newTestChunk(6, 9, 1, 0, length)
+
+	src := new(bytes.Buffer)
+	w := chunk.NewWriter(src, 4096)
+	for i := 0; i < b.N; i++ {
+		w.Write(c)
+	}
+
+	opts := []chunk.ReaderOption{chunk.InitialReadSize(4096)}
+	if pooled {
+		opts = append(opts, chunk.PayloadPool(chunk.NewBufferPool()))
+	}
+	r := chunk.NewReaderWithOptions(src, chunk.NoopNormalizer, opts...)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-r.Errs():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	b.SetBytes(int64(length))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	go r.Recv()
+	for i := 0; i < b.N; i++ {
+		(<-r.Chunks()).Release()
+	}
+
+	b.StopTimer()
+
+	r.Close()
+	close(done)
+}
+
+// BenchmarkReaderPayloadPool compares the allocations made while reading
+// messages with and without a BufferPool, in bytes per operation.
+func BenchmarkReaderPayloadPool(b *testing.B) {
+	b.Run("unpooled", func(b *testing.B) { benchmarkPooledReader(b, false, 16384) })
+	b.Run("pooled", func(b *testing.B) { benchmarkPooledReader(b, true, 16384) })
+}
@@ -0,0 +1,88 @@
+package chunk
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// Flusher is implemented by Writers that buffer chunks before writing them to
+// their destination, such as the BufferedWriter.
+type Flusher interface {
+	// Flush writes any buffered chunks to the underlying io.Writer,
+	// returning any error that it encountered along the way.
+	Flush() error
+}
+
+// BufferedWriter is an implementation of the Writer interface which buffers
+// chunks in memory, rather than writing each one to the underlying io.Writer
+// as soon as it is written. This allows callers to batch several chunks (for
+// instance, the audio and video of a single frame) into a single write, by
+// calling Flush once per frame boundary.
+//
+// Chunks are written to the underlying io.Writer when the buffer fills up, or
+// when Flush is called. Each chunk is buffered atomically, so a BufferedWriter
+// may be shared between the data, control, and NetStream streams of a
+// connection.
+type BufferedWriter struct {
+	*DefaultWriter
+
+	// buf is the buffer which chunks are written into.
+	buf *lockedBuffer
+}
+
+var _ Writer = new(BufferedWriter)
+var _ Flusher = new(BufferedWriter)
+
+// NewBufferedWriter returns a new *BufferedWriter which writes chunks with the
+// given writeSize into a buffer of `size` bytes, before writing them to `dest`.
+func NewBufferedWriter(dest io.Writer, size, writeSize int) *BufferedWriter {
+	buf := &lockedBuffer{w: bufio.NewWriterSize(dest, size)}
+
+	return &BufferedWriter{
+		DefaultWriter: &DefaultWriter{
+			dest:      buf,
+			writeSize: writeSize,
+		},
+		buf: buf,
+	}
+}
+
+// Flush implements Flusher.Flush. If a write timeout is set (see
+// DefaultWriter.SetWriteTimeout), it applies to the write of the whole buffer.
+func (w *BufferedWriter) Flush() error {
+	w.extendDeadline()
+	return w.timeout(w.buf.Flush())
+}
+
+// Buffered returns the number of bytes which have been written into the buffer,
+// but not yet to the underlying io.Writer.
+func (w *BufferedWriter) Buffered() int { return w.buf.Buffered() }
+
+// lockedBuffer is a *bufio.Writer that is safe for concurrent use.
+type lockedBuffer struct {
+	// bmu guards w
+	bmu sync.Mutex
+	w   *bufio.Writer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.bmu.Lock()
+	defer b.bmu.Unlock()
+
+	return b.w.Write(p)
+}
+
+func (b *lockedBuffer) Flush() error {
+	b.bmu.Lock()
+	defer b.bmu.Unlock()
+
+	return b.w.Flush()
+}
+
+func (b *lockedBuffer) Buffered() int {
+	b.bmu.Lock()
+	defer b.bmu.Unlock()
+
+	return b.w.Buffered()
+}
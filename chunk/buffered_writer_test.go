@@ -0,0 +1,85 @@
+package chunk_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingWriter counts the number of calls made to Write.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestBufferedWriterImplementsWriter(t *testing.T) {
+	w := chunk.NewBufferedWriter(new(bytes.Buffer), 4096, 128)
+
+	assert.Implements(t, (*chunk.Writer)(nil), w)
+	assert.Implements(t, (*chunk.Flusher)(nil), w)
+	assert.Equal(t, 128, w.WriteSize())
+}
+
+func TestBufferedWriterBatchesChunksUntilFlushed(t *testing.T) {
+	dest := new(countingWriter)
+	w := chunk.NewBufferedWriter(dest, 4096, 128)
+
+	assert.Nil(t, w.Write(newTestChunk(4, 9, 1, 1234, 8)))
+	assert.Nil(t, w.Write(newTestChunk(5, 9, 1, 1234, 8)))
+
+	assert.Equal(t, 0, dest.writes)
+	assert.NotZero(t, w.Buffered())
+
+	assert.Nil(t, w.Flush())
+
+	expected := new(bytes.Buffer)
+	unbuffered := chunk.NewWriter(expected, 128)
+	unbuffered.Write(newTestChunk(4, 9, 1, 1234, 8))
+	unbuffered.Write(newTestChunk(5, 9, 1, 1234, 8))
+
+	assert.Equal(t, 1, dest.writes)
+	assert.Equal(t, 0, w.Buffered())
+	assert.Equal(t, expected.Bytes(), dest.Bytes())
+}
+
+func TestBufferedWriterWritesWhenTheBufferIsFull(t *testing.T) {
+	dest := new(countingWriter)
+	w := chunk.NewBufferedWriter(dest, 16, 128)
+
+	assert.Nil(t, w.Write(newTestChunk(4, 9, 1, 1234, 8)))
+	assert.Nil(t, w.Write(newTestChunk(5, 9, 1, 1234, 8)))
+
+	assert.NotZero(t, dest.writes)
+}
+
+func TestBufferedWriterBuffersSetChunkSize(t *testing.T) {
+	dest := new(bytes.Buffer)
+	w := chunk.NewBufferedWriter(dest, 4096, 128)
+
+	assert.Nil(t, w.SetChunkSize(4096))
+	assert.Empty(t, dest.Bytes())
+	assert.Equal(t, 4096, w.WriteSize())
+
+	assert.Nil(t, w.Flush())
+	assert.NotEmpty(t, dest.Bytes())
+}
+
+func TestBufferedWriterFlushesTimeOut(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	w := chunk.NewBufferedWriter(conn, 4096, 128)
+	w.SetWriteTimeout(conn, 10*time.Millisecond)
+
+	assert.Nil(t, w.Write(newTestChunk(4, 9, 1, 1234, 8)))
+	assert.Equal(t, chunk.ErrWriteTimeout, w.Flush())
+}
@@ -32,6 +32,13 @@ type Builder struct {
 	lmu sync.Mutex
 	// left returns the number of bytes left in the chunk.
 	left int
+
+	// pool is the BufferPool that the payloads read, and the built chunk's
+	// payload, are taken from, or nil if they are allocated.
+	pool *BufferPool
+	// pooled holds the payloads taken from the pool by Read, which are
+	// returned to it by Build.
+	pooled []*[]byte
 }
 
 // NewBuilder allocates and returns a pointer to a new instance of the Builder
@@ -48,23 +55,62 @@ func NewBuilder(h *Header) *Builder {
 // Build builds a returns a Chunk formed by using the given header for the
 // chunk's header, and the concatenation of all of the received payloads as the
 // chunk body.
+//
+// If the Builder reads from a BufferPool (see DefaultReader.SetBufferPool),
+// the chunk body is taken from it as well, and may be returned with
+// Chunk.Release. The payloads read are returned to the pool, so they must not
+// be used once the chunk has been built.
 func (b *Builder) Build() *Chunk {
 	b.pmu.Lock()
 	defer b.pmu.Unlock()
 
-	var payload []byte
+	if b.pool == nil {
+		var payload []byte
+		for _, partial := range b.Payloads {
+			payload = append(payload, partial...)
+		}
+
+		return New(b.Header, payload)
+	}
+
+	var n int
+	for _, partial := range b.Payloads {
+		n += len(partial)
+	}
+
+	buf := b.pool.get(n)
+	payload := (*buf)[:0]
 	for _, partial := range b.Payloads {
 		payload = append(payload, partial...)
 	}
 
-	return New(b.Header, payload)
+	for _, partial := range b.pooled {
+		b.pool.put(partial)
+	}
+	b.pooled = nil
+
+	c := New(b.Header, payload)
+	c.pool, c.buf = b.pool, buf
+
+	return c
 }
 
 // Read reads the given number of bytes from the specified io.Reader, and
 // appends them as a slice. It returns the number of bytes read, and any error
 // encountered (if applicable).
 func (b *Builder) Read(r io.Reader, n int) (int, error) {
-	buf := make([]byte, n)
+	var buf []byte
+	if b.pool == nil {
+		buf = make([]byte, n)
+	} else {
+		p := b.pool.get(n)
+		b.pmu.Lock()
+		b.pooled = append(b.pooled, p)
+		b.pmu.Unlock()
+
+		buf = *p
+	}
+
 	n, err := io.ReadFull(r, buf)
 	if err != nil {
 		return n, err
@@ -8,6 +8,12 @@ type Chunk struct {
 	// Data is the chunk's payload, and has a length equal to `Length` field
 	// given in the Header.MessageHeader.
 	Data []byte
+
+	// pool is the BufferPool that the payload was taken from, or nil if it
+	// is not pooled.
+	pool *BufferPool
+	// buf is the pooled buffer holding the payload, if any.
+	buf *[]byte
 }
 
 // New returns a new Chunk initialized with the given Header and Data fields.
@@ -18,8 +24,76 @@ func New(header *Header, data []byte) *Chunk {
 	}
 }
 
+// Release returns the payload of this Chunk to the BufferPool that it was taken
+// from (see DefaultReader.SetBufferPool), and sets Data to nil. It should be
+// called once the consumer of the Chunk is done with it, and nothing holds on
+// to its payload, so that the buffer may be reused for another chunk. Parsers
+// which keep their result around after the Chunk is released must copy what
+// they need out of the payload, rather than referencing it.
+//
+// Release does nothing if the payload is not pooled, so it is always safe to
+// call on a Chunk that is not used afterwards. It is not safe to call
+// concurrently, nor on more than one copy of the same Chunk.
+func (c *Chunk) Release() {
+	if c.pool == nil {
+		return
+	}
+
+	c.pool.put(c.buf)
+	c.Data, c.pool, c.buf = nil, nil, nil
+}
+
 // StreamId returns the ID of the RTMP chunk stream that this Chunk belongs to.
 func (c *Chunk) StreamId() uint32 { return c.Header.BasicHeader.StreamId }
 
 // TypeId returns the TypeId of this chunk's MessageHeader.
 func (c *Chunk) TypeId() byte { return c.Header.MessageHeader.TypeId }
+
+// Timestamp returns the absolute timestamp of the message that this Chunk
+// carries. Chunks received from a Reader always hold absolute timestamps, even
+// when they were sent with a Type 1, 2, or 3 header.
+func (c *Chunk) Timestamp() uint32 { return c.Header.Timestamp() }
+
+// SetTimestamp sets the absolute timestamp of the message that this Chunk
+// carries to `ts`. Since only Type 0 headers carry an absolute timestamp, the
+// Chunk's header is made a Type 0 header.
+func (c *Chunk) SetTimestamp(ts uint32) {
+	c.Header.BasicHeader.FormatId = 0
+	c.Header.MessageHeader.FormatId = 0
+	c.Header.MessageHeader.TimestampDelta = false
+	c.Header.SetTimestamp(ts)
+}
+
+// WireLength returns the number of bytes that this Chunk occupies once written
+// by a DefaultWriter with the given write size: its header, its payload, and
+// the Type 3 header (and ExtendedTimestamp, if any) preceding each continuation
+// chunk. A write size of zero or less counts the payload as a single chunk.
+func (c *Chunk) WireLength(writeSize int) int {
+	if c.Header == nil {
+		return len(c.Data)
+	}
+
+	n := new(byteCounter)
+	c.Header.Write(n)
+
+	if writeSize > 0 && len(c.Data) > writeSize {
+		cont := new(byteCounter)
+		(&BasicHeader{FormatId: 3, StreamId: c.StreamId()}).Write(cont)
+		if c.Header.MessageHeader.HasExtendedTimestamp() {
+			c.Header.ExtendedTimestamp.Write(cont)
+		}
+
+		*n += *cont * byteCounter((len(c.Data)-1)/writeSize)
+	}
+
+	return int(*n) + len(c.Data)
+}
+
+// byteCounter is an io.Writer which counts the bytes written to it.
+type byteCounter int
+
+// Write implements the io.Writer.Write function.
+func (b *byteCounter) Write(p []byte) (int, error) {
+	*b += byteCounter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,59 @@
+package chunk
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/WatchBeam/rtmp/spec"
+)
+
+const (
+	// MinChunkSize is the smallest chunk size permitted by the RTMP
+	// specification.
+	MinChunkSize uint32 = 1
+	// MaxChunkSize is the largest chunk size permitted by the RTMP
+	// specification.
+	MaxChunkSize uint32 = 0xffffff
+
+	// SetChunkSizeTypeId is the message type ID of the Set Chunk Size
+	// protocol control message.
+	SetChunkSizeTypeId byte = 0x01
+	// ControlChunkStreamId is the chunk stream ID over which protocol
+	// control messages are sent.
+	ControlChunkStreamId uint32 = 2
+)
+
+var (
+	// ErrInvalidChunkSize is returned when a chunk size outside of the
+	// range [MinChunkSize, MaxChunkSize] is sent or received.
+	ErrInvalidChunkSize = errors.New("rtmp/chunk: invalid chunk size")
+)
+
+// ValidateChunkSize returns ErrInvalidChunkSize if the given chunk size is
+// outside of the range permitted by the RTMP specification, or nil otherwise.
+func ValidateChunkSize(size uint32) error {
+	if size < MinChunkSize || size > MaxChunkSize {
+		return ErrInvalidChunkSize
+	}
+
+	return nil
+}
+
+// NewSetChunkSize returns a new chunk containing the Set Chunk Size protocol
+// control message, informing the peer that subsequent chunks will be sent
+// with a maximum payload size of `size`.
+func NewSetChunkSize(size uint32) *Chunk {
+	data := new(bytes.Buffer)
+	spec.PutUint32(size&0x7fffffff, data)
+
+	return &Chunk{
+		Header: &Header{
+			BasicHeader: BasicHeader{0, ControlChunkStreamId},
+			MessageHeader: MessageHeader{
+				Length: uint32(data.Len()),
+				TypeId: SetChunkSizeTypeId,
+			},
+		},
+		Data: data.Bytes(),
+	}
+}
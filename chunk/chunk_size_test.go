@@ -0,0 +1,115 @@
+package chunk_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateChunkSizeAcceptsSizesWithinRange(t *testing.T) {
+	assert.Nil(t, chunk.ValidateChunkSize(chunk.MinChunkSize))
+	assert.Nil(t, chunk.ValidateChunkSize(4096))
+	assert.Nil(t, chunk.ValidateChunkSize(chunk.MaxChunkSize))
+}
+
+func TestValidateChunkSizeRejectsSizesOutOfRange(t *testing.T) {
+	assert.Equal(t, chunk.ErrInvalidChunkSize, chunk.ValidateChunkSize(0))
+	assert.Equal(t, chunk.ErrInvalidChunkSize,
+		chunk.ValidateChunkSize(chunk.MaxChunkSize+1))
+}
+
+func TestNewSetChunkSizeConstructsAControlChunk(t *testing.T) {
+	c := chunk.NewSetChunkSize(4096)
+
+	assert.Equal(t, chunk.ControlChunkStreamId, c.StreamId())
+	assert.Equal(t, chunk.SetChunkSizeTypeId, c.TypeId())
+	assert.Equal(t, uint32(4), c.Header.MessageHeader.Length)
+	assert.Equal(t, []byte{0x00, 0x00, 0x10, 0x00}, c.Data)
+}
+
+func TestWriterSetChunkSizeWritesControlAndAppliesSize(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := chunk.NewWriter(buf, chunk.DefaultReadSize)
+
+	err := w.SetChunkSize(4096)
+
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(
+		chunk.NewSetChunkSize(4096))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4096, w.WriteSize())
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestWriterSetChunkSizeRejectsInvalidSizes(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := chunk.NewWriter(buf, chunk.DefaultReadSize)
+
+	err := w.SetChunkSize(0)
+
+	assert.Equal(t, chunk.ErrInvalidChunkSize, err)
+	assert.Equal(t, chunk.DefaultReadSize, w.WriteSize())
+	assert.Empty(t, buf.Bytes())
+}
+
+func TestReaderAppliesIncomingSetChunkSize(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	w := chunk.NewWriter(buf, chunk.DefaultReadSize)
+	w.SetChunkSize(4)
+	w.Write(&chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader:   chunk.BasicHeader{0, 18},
+			MessageHeader: chunk.MessageHeader{0, 1234, false, 8, 2, 3},
+		},
+		Data: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+	})
+
+	r := NewReader(buf)
+	go r.Recv()
+
+	c := <-r.Chunks()
+
+	assert.Equal(t, 4, r.ReadSize())
+	assert.Equal(t, []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+		c.Data)
+}
+
+func TestReaderRejectsInvalidIncomingSetChunkSize(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := chunk.NewSetChunkSize(0)
+	chunk.NewWriter(buf, chunk.DefaultReadSize).Write(c)
+
+	r := NewReader(buf)
+	go r.Recv()
+
+	assert.Equal(t, chunk.ErrInvalidChunkSize, <-r.Errs())
+	assert.Equal(t, chunk.DefaultReadSize, r.ReadSize())
+}
+
+func TestReaderClosesWhileAnInvalidSetChunkSizeIsUnread(t *testing.T) {
+	buf := new(bytes.Buffer)
+	chunk.NewWriter(buf, chunk.DefaultReadSize).Write(chunk.NewSetChunkSize(0))
+
+	r := NewReader(buf)
+	go r.Recv()
+
+	// Give Recv time to block on passing the error along.
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		r.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("chunk: Close blocked on an unread error")
+	}
+}
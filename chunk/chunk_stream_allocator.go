@@ -0,0 +1,193 @@
+package chunk
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	// CommandChunkStreamId is the chunk stream ID reserved for commands.
+	CommandChunkStreamId uint32 = 3
+	// MaxChunkStreamId is the largest chunk stream ID that can be encoded
+	// in a BasicHeader.
+	MaxChunkStreamId uint32 = 65599
+)
+
+var (
+	// ErrNoChunkStreamIds is returned by a ChunkStreamAllocator when every
+	// chunk stream ID has been allocated.
+	ErrNoChunkStreamIds = errors.New("rtmp/chunk: no chunk stream IDs left to allocate")
+)
+
+// ChunkStreamAllocator allocates the chunk stream IDs of outgoing messages. The
+// header of each chunk is compressed against the last header written over the
+// same chunk stream, so messages which do not belong together (such as the
+// audio and video of a stream, or the video of two streams) must be written
+// over distinct chunk streams, or the peer reconstructs their headers
+// incorrectly. It is safe for concurrent use.
+//
+// Chunk stream IDs 2 and 3 are reserved for protocol control messages and
+// commands (see ControlChunkStreamId and CommandChunkStreamId, and For), so
+// allocated IDs start at 4.
+type ChunkStreamAllocator struct {
+	// amu guards used and tracks.
+	amu sync.Mutex
+	// used is the set of IDs that are currently allocated.
+	used map[uint32]struct{}
+	// tracks maps each track to the ID allocated to it by For.
+	tracks map[track]uint32
+}
+
+// track is a kind of message written over a single message stream, which is
+// given a chunk stream of its own by For.
+type track struct {
+	// streamId is the ID of the message stream.
+	streamId uint32
+	// typeId is the message type ID.
+	typeId byte
+}
+
+// NewChunkStreamAllocator returns a new *ChunkStreamAllocator with no allocated
+// IDs.
+func NewChunkStreamAllocator() *ChunkStreamAllocator {
+	return &ChunkStreamAllocator{
+		used:   make(map[uint32]struct{}),
+		tracks: make(map[track]uint32),
+	}
+}
+
+// Allocate returns the lowest chunk stream ID that is neither reserved nor
+// currently allocated, and marks it as allocated. Callers writing several
+// tracks of their own (such as a relay of many streams) should allocate an ID
+// for each. If every ID has been allocated, ErrNoChunkStreamIds is returned.
+func (a *ChunkStreamAllocator) Allocate() (uint32, error) {
+	a.amu.Lock()
+	defer a.amu.Unlock()
+
+	return a.allocate()
+}
+
+// Release marks the given chunk stream ID as no longer allocated, so that it
+// may be handed out again, forgetting any track that it was allocated to.
+func (a *ChunkStreamAllocator) Release(id uint32) {
+	a.amu.Lock()
+	defer a.amu.Unlock()
+
+	delete(a.used, id)
+	for t, tid := range a.tracks {
+		if tid == id {
+			delete(a.tracks, t)
+		}
+	}
+}
+
+// For returns the chunk stream ID that messages of the given type should be
+// written over, over the message stream with the given ID. Protocol control
+// messages are always written over ControlChunkStreamId, and commands over
+// CommandChunkStreamId. Other messages are written over a chunk stream of
+// their own for each message stream and type, which is allocated the first
+// time that For is called for them, and reused thereafter.
+func (a *ChunkStreamAllocator) For(streamId uint32, typeId byte) (uint32, error) {
+	switch typeId {
+	case 0x01, 0x02, 0x03, 0x04, 0x05, 0x06:
+		return ControlChunkStreamId, nil
+	case 0x11, 0x14:
+		return CommandChunkStreamId, nil
+	}
+
+	a.amu.Lock()
+	defer a.amu.Unlock()
+
+	t := track{streamId, typeId}
+	if id, ok := a.tracks[t]; ok {
+		return id, nil
+	}
+
+	id, err := a.allocate()
+	if err != nil {
+		return 0, err
+	}
+	a.tracks[t] = id
+
+	return id, nil
+}
+
+// ReleaseStream releases the chunk stream IDs allocated by For to the tracks of
+// the message stream with the given ID, once that message stream is deleted.
+func (a *ChunkStreamAllocator) ReleaseStream(streamId uint32) {
+	a.amu.Lock()
+	defer a.amu.Unlock()
+
+	for t, id := range a.tracks {
+		if t.streamId == streamId {
+			delete(a.tracks, t)
+			delete(a.used, id)
+		}
+	}
+}
+
+// allocate implements Allocate. The caller must hold amu.
+func (a *ChunkStreamAllocator) allocate() (uint32, error) {
+	for id := CommandChunkStreamId + 1; id <= MaxChunkStreamId; id++ {
+		if _, ok := a.used[id]; !ok {
+			a.used[id] = struct{}{}
+			return id, nil
+		}
+	}
+
+	return 0, ErrNoChunkStreamIds
+}
+
+// AllocatingWriter is an implementation of the Writer interface which writes
+// each chunk over the chunk stream allocated to its message stream and type by
+// a ChunkStreamAllocator (see ChunkStreamAllocator.For), regardless of the
+// chunk stream ID that it was given. Continuation chunks are written over the
+// same chunk stream as the message they continue. Since the chunk stream may
+// differ from the one a chunk was given, each chunk is written with a Type 0
+// header, rather than one compressed against the headers of that chunk stream.
+//
+// Chunks given to Write are not modified, so the same chunk may be written to
+// many AllocatingWriters.
+type AllocatingWriter struct {
+	Writer
+
+	// ids is the ChunkStreamAllocator that chunk stream IDs are taken from.
+	ids *ChunkStreamAllocator
+}
+
+var _ Writer = new(AllocatingWriter)
+
+// NewAllocatingWriter returns a new *AllocatingWriter which writes to the given
+// Writer, over the chunk streams allocated by the given ChunkStreamAllocator.
+// If it is nil, a new one is used.
+func NewAllocatingWriter(w Writer, ids *ChunkStreamAllocator) *AllocatingWriter {
+	if ids == nil {
+		ids = NewChunkStreamAllocator()
+	}
+
+	return &AllocatingWriter{Writer: w, ids: ids}
+}
+
+// ChunkStreams returns the ChunkStreamAllocator used by this writer, which
+// callers writing to the same connection by other means should share, so that
+// their chunk streams do not collide.
+func (w *AllocatingWriter) ChunkStreams() *ChunkStreamAllocator { return w.ids }
+
+// Write implements the Write function defined in the Writer interface. It
+// writes a copy of the given chunk, holding the allocated chunk stream ID and a
+// Type 0 header, to the underlying Writer, returning ErrNoChunkStreamIds if none
+// could be allocated.
+func (w *AllocatingWriter) Write(c *Chunk) error {
+	id, err := w.ids.For(c.Header.MessageHeader.StreamId, c.TypeId())
+	if err != nil {
+		return err
+	}
+
+	h := *c.Header
+	h.BasicHeader.StreamId = id
+
+	out := &Chunk{Header: &h, Data: c.Data}
+	out.SetTimestamp(c.Timestamp())
+
+	return w.Writer.Write(out)
+}
@@ -0,0 +1,113 @@
+package chunk_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestChunkStreamAllocatorSkipsReservedIds(t *testing.T) {
+	a := chunk.NewChunkStreamAllocator()
+
+	first, err := a.Allocate()
+	assert.Nil(t, err)
+	second, err := a.Allocate()
+	assert.Nil(t, err)
+
+	assert.EqualValues(t, 4, first)
+	assert.EqualValues(t, 5, second)
+}
+
+func TestChunkStreamAllocatorReusesReleasedIds(t *testing.T) {
+	a := chunk.NewChunkStreamAllocator()
+
+	first, _ := a.Allocate()
+	a.Allocate()
+	a.Release(first)
+
+	id, err := a.Allocate()
+
+	assert.Nil(t, err)
+	assert.Equal(t, first, id)
+}
+
+func TestChunkStreamAllocatorUsesReservedIdsForControlAndCommands(t *testing.T) {
+	a := chunk.NewChunkStreamAllocator()
+
+	for _, test := range []struct {
+		TypeId byte
+		Id     uint32
+	}{
+		{0x01, chunk.ControlChunkStreamId},
+		{0x05, chunk.ControlChunkStreamId},
+		{0x14, chunk.CommandChunkStreamId},
+		{0x11, chunk.CommandChunkStreamId},
+	} {
+		id, err := a.For(1, test.TypeId)
+
+		assert.Nil(t, err)
+		assert.Equal(t, test.Id, id)
+	}
+}
+
+func TestChunkStreamAllocatorGivesEachTrackItsOwnId(t *testing.T) {
+	a := chunk.NewChunkStreamAllocator()
+
+	video, _ := a.For(1, 0x09)
+	audio, _ := a.For(1, 0x08)
+	other, _ := a.For(2, 0x09)
+	again, _ := a.For(1, 0x09)
+
+	assert.EqualValues(t, 4, video)
+	assert.EqualValues(t, 5, audio)
+	assert.EqualValues(t, 6, other)
+	assert.Equal(t, video, again)
+
+	a.ReleaseStream(1)
+	id, _ := a.Allocate()
+	assert.EqualValues(t, 4, id)
+}
+
+func TestAllocatingWriterWritesOverAllocatedChunkStreams(t *testing.T) {
+	mw := new(MockWriter)
+	mw.On("Write", mock.Anything).Return(nil)
+
+	w := chunk.NewAllocatingWriter(mw, nil)
+
+	// Both chunks claim chunk stream 6, which would corrupt the header
+	// compression of either.
+	video := newTestChunk(6, 9, 1, 0, 4)
+	audio := newTestChunk(6, 9, 1, 0, 4)
+	audio.Header.MessageHeader.TypeId = 0x08
+
+	assert.Nil(t, w.Write(video))
+	assert.Nil(t, w.Write(audio))
+	assert.Nil(t, w.Write(video))
+
+	var ids []uint32
+	for _, call := range mw.Calls {
+		ids = append(ids, call.Arguments.Get(0).(*chunk.Chunk).StreamId())
+	}
+
+	assert.Equal(t, []uint32{4, 5, 4}, ids)
+	assert.EqualValues(t, 6, video.StreamId())
+}
+
+func TestAllocatingWriterWritesType0Headers(t *testing.T) {
+	mw := new(MockWriter)
+	mw.On("Write", mock.Anything).Return(nil)
+
+	c := newTestChunk(6, 9, 1, 1000, 4)
+	c.Header.BasicHeader.FormatId = 1
+	c.Header.MessageHeader.FormatId = 1
+
+	assert.Nil(t, chunk.NewAllocatingWriter(mw, nil).Write(c))
+
+	written := mw.Calls[0].Arguments.Get(0).(*chunk.Chunk)
+	assert.EqualValues(t, 0, written.Header.BasicHeader.FormatId)
+	assert.EqualValues(t, 0, written.Header.MessageHeader.FormatId)
+	assert.EqualValues(t, 1000, written.Timestamp())
+	assert.EqualValues(t, 1, c.Header.BasicHeader.FormatId)
+}
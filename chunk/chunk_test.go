@@ -0,0 +1,56 @@
+package chunk_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestChunk returns a chunk with a Type 0 header on the given chunk stream,
+// carrying a message of the given type and message stream ID, timestamped at
+// `ts`, with a payload of `length` bytes counting up from zero.
+func newTestChunk(chunkStreamId uint32, typeId byte, streamId, ts uint32, length int) *chunk.Chunk {
+	data := make([]byte, length)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	c := &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader: chunk.BasicHeader{0, chunkStreamId},
+			MessageHeader: chunk.MessageHeader{
+				Length: uint32(length), TypeId: typeId, StreamId: streamId,
+			},
+		},
+		Data: data,
+	}
+	c.SetTimestamp(ts)
+
+	return c
+}
+
+func TestChunkTimestampsUseExtendedTimestamps(t *testing.T) {
+	c := &chunk.Chunk{Header: new(chunk.Header)}
+
+	c.SetTimestamp(0x01000000)
+
+	assert.Equal(t, uint32(0x01000000), c.Timestamp())
+	assert.Equal(t, uint32(0xffffff), c.Header.MessageHeader.Timestamp)
+}
+
+func TestSetTimestampMakesType0Headers(t *testing.T) {
+	c := &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader:   chunk.BasicHeader{1, 4},
+			MessageHeader: chunk.MessageHeader{1, 10, true, 8, 9, 1},
+		},
+	}
+
+	c.SetTimestamp(1234)
+
+	assert.Equal(t, uint32(1234), c.Timestamp())
+	assert.EqualValues(t, 0, c.Header.BasicHeader.FormatId)
+	assert.EqualValues(t, 0, c.Header.MessageHeader.FormatId)
+	assert.False(t, c.Header.MessageHeader.TimestampDelta)
+}
@@ -0,0 +1,161 @@
+// Package chunktest provides fake implementations of the chunk.Stream and
+// chunk.Writer interfaces, for unit-testing code that consumes chunks, such as
+// control and command handlers, without a network connection.
+package chunktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+)
+
+// FakeStream is an implementation of the chunk.Stream interface whose chunks
+// are injected by the test.
+type FakeStream struct {
+	// C is the channel returned by In(). Chunks sent over it are received
+	// by the consumer of the FakeStream, and closing it closes the stream.
+	C chan *chunk.Chunk
+}
+
+var _ chunk.Stream = new(FakeStream)
+
+// NewFakeStream returns a new *FakeStream, whose channel holds up to `size`
+// chunks before sending to it blocks.
+func NewFakeStream(size int) *FakeStream {
+	return &FakeStream{
+		C: make(chan *chunk.Chunk, size),
+	}
+}
+
+// In implements the chunk.Stream.In function.
+func (s *FakeStream) In() <-chan *chunk.Chunk { return s.C }
+
+// Send sends the given chunks over the stream, in order.
+func (s *FakeStream) Send(chunks ...*chunk.Chunk) {
+	for _, c := range chunks {
+		s.C <- c
+	}
+}
+
+// Close closes the stream, as if the connection had been closed.
+func (s *FakeStream) Close() { close(s.C) }
+
+// RecordingWriter is an implementation of the chunk.Writer interface which
+// records each chunk written to it, rather than writing it to a connection. It
+// is safe for concurrent use.
+type RecordingWriter struct {
+	// mu guards chunks, writeSize, err and written.
+	mu sync.Mutex
+	// chunks are the chunks that have been written, in order.
+	chunks []*chunk.Chunk
+	// writeSize is the write size of this RecordingWriter.
+	writeSize int
+	// err is the error returned by Write, if any.
+	err error
+	// written is signalled whenever a chunk is written.
+	written chan struct{}
+}
+
+var _ chunk.Writer = new(RecordingWriter)
+
+// NewRecordingWriter returns a new *RecordingWriter, with a write size of
+// chunk.DefaultReadSize.
+func NewRecordingWriter() *RecordingWriter {
+	return &RecordingWriter{
+		writeSize: chunk.DefaultReadSize,
+		written:   make(chan struct{}, 1),
+	}
+}
+
+// Write implements the chunk.Writer.Write function. The chunk is recorded,
+// unless an error has been set with SetError, in which case that error is
+// returned instead.
+func (w *RecordingWriter) Write(c *chunk.Chunk) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	w.chunks = append(w.chunks, c)
+
+	select {
+	case w.written <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// WriteSize implements the chunk.Writer.WriteSize function.
+func (w *RecordingWriter) WriteSize() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.writeSize
+}
+
+// SetWriteSize implements the chunk.Writer.SetWriteSize function.
+func (w *RecordingWriter) SetWriteSize(writeSize int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeSize = writeSize
+}
+
+// SetChunkSize implements the chunk.Writer.SetChunkSize function, as the
+// chunk.DefaultWriter does: the Set Chunk Size message is recorded, and the
+// write size is changed.
+func (w *RecordingWriter) SetChunkSize(size uint32) error {
+	if err := chunk.ValidateChunkSize(size); err != nil {
+		return err
+	}
+
+	if err := w.Write(chunk.NewSetChunkSize(size)); err != nil {
+		return err
+	}
+
+	w.SetWriteSize(int(size))
+
+	return nil
+}
+
+// SetError sets the error returned by all subsequent writes. If it is nil,
+// writes succeed again.
+func (w *RecordingWriter) SetError(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.err = err
+}
+
+// Chunks returns the chunks that have been written so far, in order.
+func (w *RecordingWriter) Chunks() []*chunk.Chunk {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([]*chunk.Chunk(nil), w.chunks...)
+}
+
+// Wait blocks until at least `n` chunks have been written, and returns them
+// (see Chunks). If fewer than `n` chunks have been written once the timeout
+// elapses, nil is returned instead. It is useful when chunks are written from
+// another goroutine.
+func (w *RecordingWriter) Wait(n int, timeout time.Duration) []*chunk.Chunk {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		if chunks := w.Chunks(); len(chunks) >= n {
+			return chunks
+		}
+
+		select {
+		case <-w.written:
+		case <-deadline.C:
+			return nil
+		}
+	}
+}
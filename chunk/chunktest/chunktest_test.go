@@ -0,0 +1,69 @@
+package chunktest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/chunk/chunktest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeStreamDeliversSentChunks(t *testing.T) {
+	s := chunktest.NewFakeStream(1)
+	c := &chunk.Chunk{Data: []byte{0x01}}
+
+	s.Send(c)
+
+	assert.Equal(t, c, <-s.In())
+}
+
+func TestFakeStreamCloseClosesIn(t *testing.T) {
+	s := chunktest.NewFakeStream(0)
+	s.Close()
+
+	_, ok := <-s.In()
+
+	assert.False(t, ok)
+}
+
+func TestRecordingWriterRecordsWrittenChunks(t *testing.T) {
+	w := chunktest.NewRecordingWriter()
+	c1 := &chunk.Chunk{Data: []byte{0x01}}
+	c2 := &chunk.Chunk{Data: []byte{0x02}}
+
+	assert.Nil(t, w.Write(c1))
+	assert.Nil(t, w.Write(c2))
+
+	assert.Equal(t, []*chunk.Chunk{c1, c2}, w.Chunks())
+}
+
+func TestRecordingWriterReturnsTheSetError(t *testing.T) {
+	w := chunktest.NewRecordingWriter()
+	err := errors.New("test")
+	w.SetError(err)
+
+	assert.Equal(t, err, w.Write(new(chunk.Chunk)))
+	assert.Empty(t, w.Chunks())
+}
+
+func TestRecordingWriterSetChunkSizeRecordsTheControl(t *testing.T) {
+	w := chunktest.NewRecordingWriter()
+
+	assert.Nil(t, w.SetChunkSize(4096))
+	assert.Equal(t, chunk.ErrInvalidChunkSize, w.SetChunkSize(0))
+
+	assert.Equal(t, 4096, w.WriteSize())
+	assert.Equal(t, []*chunk.Chunk{chunk.NewSetChunkSize(4096)}, w.Chunks())
+}
+
+func TestRecordingWriterWaitsForWrites(t *testing.T) {
+	w := chunktest.NewRecordingWriter()
+	c := new(chunk.Chunk)
+
+	go w.Write(c)
+
+	assert.Equal(t, []*chunk.Chunk{c}, w.Wait(1, time.Second))
+	assert.Nil(t, w.Wait(2, 10*time.Millisecond))
+}
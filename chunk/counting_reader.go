@@ -0,0 +1,54 @@
+package chunk
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// CountingReader is an io.Reader that keeps track of the number of bytes read
+// through it from an underlying io.Reader. It is useful for implementing the
+// byte accounting necessary to send Acknowledgement control sequences, as
+// defined in the RTMP specification.
+type CountingReader struct {
+	// src is the io.Reader that bytes are read from.
+	src io.Reader
+	// notify is called after each read with the number of bytes read, if
+	// it is non-nil.
+	notify func(n int) error
+
+	// n is the total number of bytes read. It must be accessed atomically.
+	n uint64
+}
+
+var _ io.Reader = new(CountingReader)
+
+// NewCountingReader returns a new *CountingReader reading from `src`. If
+// `notify` is non-nil, it is called after every read with the number of bytes
+// that were read. Any error returned by `notify` is returned from Read.
+func NewCountingReader(src io.Reader, notify func(n int) error) *CountingReader {
+	return &CountingReader{
+		src:    src,
+		notify: notify,
+	}
+}
+
+// Read implements the io.Reader.Read function.
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.src.Read(p)
+	if n > 0 {
+		atomic.AddUint64(&c.n, uint64(n))
+
+		if c.notify != nil {
+			if nerr := c.notify(n); nerr != nil && err == nil {
+				err = nerr
+			}
+		}
+	}
+
+	return n, err
+}
+
+// BytesRead returns the total number of bytes read through this reader.
+func (c *CountingReader) BytesRead() uint64 {
+	return atomic.LoadUint64(&c.n)
+}
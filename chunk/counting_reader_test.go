@@ -0,0 +1,44 @@
+package chunk_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountingReaderCountsBytesRead(t *testing.T) {
+	r := chunk.NewCountingReader(bytes.NewReader(make([]byte, 10)), nil)
+
+	_, err := ioutil.ReadAll(r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(10), r.BytesRead())
+}
+
+func TestCountingReaderNotifiesOfEachRead(t *testing.T) {
+	var total int
+	r := chunk.NewCountingReader(bytes.NewReader(make([]byte, 10)),
+		func(n int) error {
+			total += n
+			return nil
+		})
+
+	r.Read(make([]byte, 4))
+	r.Read(make([]byte, 4))
+
+	assert.Equal(t, 8, total)
+}
+
+func TestCountingReaderReturnsNotifyErrors(t *testing.T) {
+	r := chunk.NewCountingReader(bytes.NewReader(make([]byte, 10)),
+		func(n int) error { return errors.New("foo") })
+
+	n, err := r.Read(make([]byte, 4))
+
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "foo", err.Error())
+}
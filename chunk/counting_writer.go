@@ -0,0 +1,68 @@
+package chunk
+
+import "sync/atomic"
+
+// CountingWriter is an implementation of the Writer interface which keeps track
+// of the number of bytes that the chunks written through it occupy on the wire
+// (see Chunk.WireLength), including their headers, before writing them to
+// another Writer. It is useful for per-stream byte accounting, where several
+// streams share the Writer of a single connection.
+type CountingWriter struct {
+	Writer
+
+	// notify is called after each successful write with the number of
+	// bytes written, if it is non-nil.
+	notify func(n int)
+
+	// n is the total number of bytes written. It must be accessed
+	// atomically.
+	n uint64
+}
+
+var _ Writer = new(CountingWriter)
+
+// NewCountingWriter returns a new *CountingWriter which writes chunks to `w`.
+// If `notify` is non-nil, it is called after every successful write with the
+// number of bytes that were written.
+func NewCountingWriter(w Writer, notify func(n int)) *CountingWriter {
+	return &CountingWriter{
+		Writer: w,
+		notify: notify,
+	}
+}
+
+// Write implements the Write function defined in the Writer interface.
+func (w *CountingWriter) Write(c *Chunk) error {
+	n := c.WireLength(w.WriteSize())
+	if err := w.Writer.Write(c); err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&w.n, uint64(n))
+	if w.notify != nil {
+		w.notify(n)
+	}
+
+	return nil
+}
+
+// SetChunkSize implements the SetChunkSize function defined in the Writer
+// interface, such that the Set Chunk Size message is counted as well.
+func (w *CountingWriter) SetChunkSize(size uint32) error {
+	if err := ValidateChunkSize(size); err != nil {
+		return err
+	}
+
+	if err := w.Write(NewSetChunkSize(size)); err != nil {
+		return err
+	}
+
+	w.SetWriteSize(int(size))
+
+	return nil
+}
+
+// BytesWritten returns the total number of bytes written through this writer.
+func (w *CountingWriter) BytesWritten() uint64 {
+	return atomic.LoadUint64(&w.n)
+}
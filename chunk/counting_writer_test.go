@@ -0,0 +1,57 @@
+package chunk_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/chunk/chunktest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountingWriterCountsWireBytes(t *testing.T) {
+	for _, c := range []*chunk.Chunk{
+		newTestChunk(4, 9, 1, 1234, 10),
+		newTestChunk(4, 9, 1, 1234, 300),
+		newTestChunk(4, 9, 1, 0x1000000, 300),
+	} {
+		buf := new(bytes.Buffer)
+		w := chunk.NewCountingWriter(
+			chunk.NewWriter(buf, chunk.DefaultReadSize), nil)
+
+		assert.Nil(t, w.Write(c))
+		assert.Equal(t, uint64(buf.Len()), w.BytesWritten())
+	}
+}
+
+func TestCountingWriterNotifiesOfEachWrite(t *testing.T) {
+	var total int
+	w := chunk.NewCountingWriter(chunktest.NewRecordingWriter(),
+		func(n int) { total += n })
+
+	w.Write(newTestChunk(4, 9, 1, 0, 10))
+	w.Write(newTestChunk(4, 9, 1, 0, 10))
+
+	assert.Equal(t, 2*(12+10), total)
+	assert.Equal(t, uint64(total), w.BytesWritten())
+}
+
+func TestCountingWriterDoesNotCountFailedWrites(t *testing.T) {
+	rw := chunktest.NewRecordingWriter()
+	rw.SetError(errors.New("test"))
+	w := chunk.NewCountingWriter(rw, nil)
+
+	assert.NotNil(t, w.Write(newTestChunk(4, 9, 1, 0, 10)))
+	assert.Equal(t, uint64(0), w.BytesWritten())
+}
+
+func TestCountingWriterCountsSetChunkSize(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := chunk.NewCountingWriter(
+		chunk.NewWriter(buf, chunk.DefaultReadSize), nil)
+
+	assert.Nil(t, w.SetChunkSize(4096))
+	assert.Equal(t, 4096, w.WriteSize())
+	assert.Equal(t, uint64(buf.Len()), w.BytesWritten())
+}
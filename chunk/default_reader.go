@@ -2,8 +2,11 @@ package chunk
 
 import (
 	"encoding/binary"
+	"errors"
 	"io"
+	"net"
 	"sync"
+	"time"
 
 	"github.com/WatchBeam/rtmp/spec"
 )
@@ -11,8 +14,31 @@ import (
 const (
 	// DefaultReadSize is the RTMP-defined default for chunk size, in byter.
 	DefaultReadSize int = 128
+	// DefaultMaxMessageSize is the default largest message length, in
+	// bytes, that a DefaultReader accepts (see SetMaxMessageSize). It is
+	// kept below 0xffffff, the largest length that the 24-bit message
+	// length field can declare, so that the limit takes effect by default.
+	DefaultMaxMessageSize int = 8 * 1024 * 1024
 )
 
+var (
+	// ErrIdleTimeout is returned over the Errs() channel when no chunks
+	// were received within the idle timeout (see SetIdleTimeout).
+	ErrIdleTimeout = errors.New("rtmp/chunk: no chunks received within the idle timeout")
+	// ErrMessageTooLarge is returned over the Errs() channel when the peer
+	// declares a message longer than the maximum message size (see
+	// SetMaxMessageSize).
+	ErrMessageTooLarge = errors.New("rtmp/chunk: message too large")
+)
+
+// ReadDeadliner is implemented by connections which support read deadlines,
+// such as net.Conn.
+type ReadDeadliner interface {
+	// SetReadDeadline sets the time after which reads fail with an
+	// error whose Timeout method returns true.
+	SetReadDeadline(t time.Time) error
+}
+
 // DefaultReader provides an RTMP-compliant implementation to the Reader
 // interface.
 type DefaultReader struct {
@@ -28,6 +54,36 @@ type DefaultReader struct {
 	// normalizer is the Normalizer used to normalize incoming headers.
 	normalizer Normalizer
 
+	// tmu guards timestamps
+	tmu sync.Mutex
+	// timestamps maps the chunk stream ID to the timestamp state of the
+	// last message received over that chunk stream.
+	timestamps map[uint32]*timestamp
+
+	// imu guards deadliner, idleTimeout, and deadline
+	imu sync.Mutex
+	// deadliner is the connection whose read deadline is pushed back each
+	// time a chunk is read, if idleTimeout is non-zero.
+	deadliner ReadDeadliner
+	// idleTimeout is the amount of time that may pass without reading a
+	// chunk before ErrIdleTimeout is returned.
+	idleTimeout time.Duration
+	// deadline is the time after which no more chunks are read, or the
+	// zero time if there is none (see SetReadDeadline).
+	deadline time.Time
+
+	// mmu guards maxMessageSize
+	mmu sync.Mutex
+	// maxMessageSize is the largest message length accepted, or zero if
+	// there is no limit.
+	maxMessageSize int
+
+	// pmu guards pool
+	pmu sync.Mutex
+	// pool is the BufferPool that payloads are taken from, or nil if they
+	// are allocated.
+	pool *BufferPool
+
 	// rmu guards readSize
 	rmu sync.Mutex
 	// readSize refers to the maximum amount of bytes that can be read at
@@ -41,18 +97,40 @@ type DefaultReader struct {
 	errs chan error
 	// closer is a non-buffered channel used to pass closing signal around.
 	closer chan struct{}
+	// done is closed when Recv returns.
+	done chan struct{}
 }
 
 var _ Reader = new(DefaultReader)
 
+// timestamp holds the information necessary to reconstruct the absolute
+// timestamp of messages sent over a single chunk stream.
+type timestamp struct {
+	// absolute is the absolute timestamp of the last message.
+	absolute uint32
+	// delta is the last timestamp delta, which is re-used by Type 3
+	// headers that begin a new message.
+	delta uint32
+	// extended is true when the last Type 0, 1, or 2 header indicated the
+	// presence of an ExtendedTimestamp, in which case Type 3 headers carry
+	// one as well.
+	extended bool
+}
+
 // Chunks implements the `Chunks` func in the Reader interface.
 func (r *DefaultReader) Chunks() <-chan *Chunk { return r.chunks }
 
 // Errs implements the `Errs` func in the Reader interface.
 func (r *DefaultReader) Errs() <-chan error { return r.errs }
 
-// Close implements the `Close` func in the Reader interface.
-func (r *DefaultReader) Close() { r.closer <- struct{}{} }
+// Close implements the `Close` func in the Reader interface. It does not block
+// if Recv has already returned because of an idle timeout.
+func (r *DefaultReader) Close() {
+	select {
+	case r.closer <- struct{}{}:
+	case <-r.done:
+	}
+}
 
 // ReadSize implements the `ReadSize` func in the Reader interface.
 func (r *DefaultReader) ReadSize() int {
@@ -70,58 +148,397 @@ func (r *DefaultReader) SetReadSize(size int) {
 	r.readSize = size
 }
 
+// SetBufferPool sets the BufferPool that the payloads of the chunks read are
+// taken from. If it is nil (as it is by default), a new payload is allocated for
+// each chunk. Otherwise, consumers should call Chunk.Release once they are done
+// with each chunk, so that its payload may be reused. Chunks consumed by the
+// reader itself, such as Set Chunk Size messages, are released by it.
+//
+// Since a released payload is overwritten by the next chunk to reuse it, a
+// pool must only be set when every consumer of the chunks either releases them
+// once done, or never releases them at all.
+func (r *DefaultReader) SetBufferPool(p *BufferPool) {
+	r.pmu.Lock()
+	defer r.pmu.Unlock()
+
+	r.pool = p
+}
+
+// bufferPool returns the BufferPool set with SetBufferPool, if any.
+func (r *DefaultReader) bufferPool() *BufferPool {
+	r.pmu.Lock()
+	defer r.pmu.Unlock()
+
+	return r.pool
+}
+
+// MaxMessageSize returns the largest message length, in bytes, that is accepted
+// from the peer, or zero if there is no limit.
+func (r *DefaultReader) MaxMessageSize() int {
+	r.mmu.Lock()
+	defer r.mmu.Unlock()
+
+	return r.maxMessageSize
+}
+
+// SetMaxMessageSize sets the largest message length, in bytes, that is accepted
+// from the peer. Since the payload of a message is buffered until it has been
+// read completely, this keeps a malicious peer from exhausting memory by
+// declaring huge messages. Once a message longer than `size` is declared,
+// ErrMessageTooLarge is returned over the Errs() channel, and Recv returns, as
+// the remainder of the connection can no longer be read. The connection should
+// then be closed.
+//
+// A size of zero or less disables the limit. It defaults to
+// DefaultMaxMessageSize.
+func (r *DefaultReader) SetMaxMessageSize(size int) {
+	r.mmu.Lock()
+	defer r.mmu.Unlock()
+
+	if size < 0 {
+		size = 0
+	}
+	r.maxMessageSize = size
+}
+
+// tooLarge returns whether or not the given header declares a message longer
+// than the maximum message size.
+func (r *DefaultReader) tooLarge(h *Header) bool {
+	max := r.MaxMessageSize()
+
+	return max > 0 && int(h.MessageHeader.Length) > max
+}
+
+// SetIdleTimeout sets the amount of time that may pass without reading a chunk
+// before the connection is considered dead. The read deadline of `conn`, which
+// should be the connection that chunks are read from, is pushed back by
+// `timeout` before each chunk is read. Once it passes, ErrIdleTimeout is
+// returned over the Errs() channel, and Recv returns, so that a peer which
+// vanishes without closing its connection does not leave Recv blocked forever.
+//
+// A timeout of zero or less disables the idle timeout, and resets the read
+// deadline of `conn` to the one set by SetReadDeadline, if any.
+func (r *DefaultReader) SetIdleTimeout(conn ReadDeadliner, timeout time.Duration) {
+	r.imu.Lock()
+	defer r.imu.Unlock()
+
+	r.deadliner = conn
+	r.idleTimeout = timeout
+
+	if conn != nil && timeout <= 0 {
+		conn.SetReadDeadline(r.deadline)
+	}
+}
+
+// SetReadDeadline sets the time after which no more chunks are read from
+// `conn`, which should be the connection that chunks are read from, for
+// instance to bound the length of a session. Once it passes, the error
+// returned by the connection (such as os.ErrDeadlineExceeded) is returned over
+// the Errs() channel, and Recv returns. The zero time clears the deadline.
+//
+// The deadline takes precedence over the idle timeout (see SetIdleTimeout),
+// which never pushes the read deadline of `conn` past it.
+func (r *DefaultReader) SetReadDeadline(conn ReadDeadliner, t time.Time) {
+	r.imu.Lock()
+	defer r.imu.Unlock()
+
+	r.deadliner = conn
+	r.deadline = t
+
+	if conn != nil {
+		conn.SetReadDeadline(r.nextDeadline())
+	}
+}
+
+// extendDeadline pushes back the read deadline of the connection by the idle
+// timeout, if there is one.
+func (r *DefaultReader) extendDeadline() {
+	r.imu.Lock()
+	defer r.imu.Unlock()
+
+	if r.deadliner != nil && r.idleTimeout > 0 {
+		r.deadliner.SetReadDeadline(r.nextDeadline())
+	}
+}
+
+// nextDeadline returns the read deadline of the connection for the next chunk:
+// the idle timeout from now, if there is one, unless the deadline set by
+// SetReadDeadline comes first. It must be called with imu held.
+func (r *DefaultReader) nextDeadline() time.Time {
+	if r.idleTimeout <= 0 {
+		return r.deadline
+	}
+
+	idle := time.Now().Add(r.idleTimeout)
+	if !r.deadline.IsZero() && r.deadline.Before(idle) {
+		return r.deadline
+	}
+	return idle
+}
+
+// isIdle returns whether or not the given error was caused by the idle timeout
+// passing, rather than the deadline set by SetReadDeadline.
+func (r *DefaultReader) isIdle(err error) bool {
+	r.imu.Lock()
+	defer r.imu.Unlock()
+
+	if r.deadliner == nil || r.idleTimeout <= 0 || r.pastDeadline() {
+		return false
+	}
+
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+// isPastDeadline returns whether or not the given error was caused by the
+// deadline set by SetReadDeadline passing.
+func (r *DefaultReader) isPastDeadline(err error) bool {
+	r.imu.Lock()
+	defer r.imu.Unlock()
+
+	if r.deadliner == nil || !r.pastDeadline() {
+		return false
+	}
+
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+// pastDeadline returns whether or not the deadline set by SetReadDeadline has
+// passed. It must be called with imu held.
+func (r *DefaultReader) pastDeadline() bool {
+	return !r.deadline.IsZero() && !time.Now().Before(r.deadline)
+}
+
+// fail passes along the given error, which was encountered while reading from
+// the connection, and returns whether or not Recv should return. If the error
+// was caused by the idle timeout passing, ErrIdleTimeout is passed along
+// instead, and Recv should return. If it was caused by the deadline set by
+// SetReadDeadline passing, it is passed along, and Recv should return. Recv
+// should also return if the reader is closed while the error is waiting to be
+// received.
+func (r *DefaultReader) fail(err error) bool {
+	if r.isPastDeadline(err) {
+		select {
+		case r.errs <- err:
+		case <-r.closer:
+		}
+		return true
+	}
+
+	if !r.isIdle(err) {
+		select {
+		case r.errs <- err:
+			return false
+		case <-r.closer:
+			return true
+		}
+	}
+
+	select {
+	case r.errs <- ErrIdleTimeout:
+	case <-r.closer:
+	}
+
+	return true
+}
+
 // Recv implements the `Recv` func in the Reader interface.
 func (r *DefaultReader) Recv() {
+	defer close(r.done)
+
 	for {
 		select {
 		case <-r.closer:
 			return
 		default:
+			r.extendDeadline()
+
 			header := new(Header)
 			if err := header.Read(r.src); err != nil {
-				r.errs <- err
+				if r.fail(err) {
+					return
+				}
 				continue
 			}
 			header = r.normalizer.Normalize(header)
 
-			builder := r.builder(header)
+			streamId := header.BasicHeader.StreamId
+			first := !r.hasBuilder(streamId)
+
+			if first && r.tooLarge(header) {
+				select {
+				case r.errs <- ErrMessageTooLarge:
+				case <-r.closer:
+				}
+				return
+			}
+
+			absolute, err := r.readTimestamp(header, first)
+			if err != nil {
+				if r.fail(err) {
+					return
+				}
+				continue
+			}
+
+			builder := r.builder(header, absolute)
 			n := spec.Min(builder.BytesLeft(), r.ReadSize())
 
 			if _, err := builder.Read(r.src, n); err != nil {
-				r.errs <- err
+				if r.fail(err) {
+					return
+				}
 				continue
 			}
 
 			if builder.BytesLeft() == 0 {
 				chunk := builder.Build()
+				r.removeBuilder(header.BasicHeader.StreamId)
+				r.abort(chunk)
 
-				if !r.updateChunkSize(chunk) {
-					r.chunks <- chunk
-				}
+				if ok, err := r.updateChunkSize(chunk); err != nil {
+					chunk.Release()
 
-				r.removeBuilder(header.BasicHeader.StreamId)
+					select {
+					case r.errs <- err:
+					case <-r.closer:
+						return
+					}
+				} else if ok {
+					chunk.Release()
+				} else {
+					select {
+					case r.chunks <- chunk:
+					case <-r.closer:
+						chunk.Release()
+						return
+					}
+				}
 			}
 		}
 	}
 }
 
-func (r *DefaultReader) updateChunkSize(c *Chunk) bool {
-	if c.TypeId() != byte(0x01) {
-		return false
+// abort discards the partially read message on the chunk stream identified by
+// the given chunk, if it is an Abort Message protocol control message, so that
+// subsequent chunks on that chunk stream begin a new message. The Abort Message
+// itself is still passed along, so that it may be observed over the control
+// stream.
+func (r *DefaultReader) abort(c *Chunk) {
+	if c.TypeId() != AbortMessageTypeId || len(c.Data) < 4 {
+		return
+	}
+
+	r.removeBuilder(binary.BigEndian.Uint32(c.Data))
+}
+
+// updateChunkSize applies the chunk size sent in a Set Chunk Size protocol
+// control message to this reader, such that subsequent chunks are read using
+// the new size. It returns true if the given chunk was such a message, and
+// ErrInvalidChunkSize if the size it carried was outside of the range permitted
+// by the RTMP specification, in which case the read size is left unchanged.
+func (r *DefaultReader) updateChunkSize(c *Chunk) (bool, error) {
+	if c.TypeId() != SetChunkSizeTypeId {
+		return false, nil
+	}
+
+	if len(c.Data) < 4 {
+		return true, ErrInvalidChunkSize
+	}
+
+	size := binary.BigEndian.Uint32(c.Data) & 0x7fffffff
+	if err := ValidateChunkSize(size); err != nil {
+		return true, err
 	}
 
-	r.SetReadSize(int(binary.BigEndian.Uint32(c.Data)))
+	r.SetReadSize(int(size))
 
-	return true
+	return true, nil
 }
 
-func (r *DefaultReader) builder(header *Header) *Builder {
+// readTimestamp updates the timestamp state of the chunk stream that the given
+// header belongs to, and returns the absolute timestamp of the message that
+// the header is a part of.
+//
+// Type 3 headers carry an ExtendedTimestamp whenever the last Type 0, 1, or 2
+// header on the same chunk stream did. Since it cannot be known whether or not
+// one is present while reading the header itself, it is read here instead.
+//
+// If `first` is true, the header begins a new message, and its timestamp (or
+// delta) is applied. Otherwise, the header is a continuation of a message
+// already in progress, and the timestamp is left as-is.
+func (r *DefaultReader) readTimestamp(h *Header, first bool) (uint32, error) {
+	r.tmu.Lock()
+	defer r.tmu.Unlock()
+
+	streamId := h.BasicHeader.StreamId
+
+	ts := r.timestamps[streamId]
+	if ts == nil {
+		ts = new(timestamp)
+		r.timestamps[streamId] = ts
+	}
+
+	switch h.BasicHeader.FormatId {
+	case 0:
+		ts.extended = h.MessageHeader.HasExtendedTimestamp()
+		ts.delta = h.Timestamp()
+		if first {
+			ts.absolute = ts.delta
+		}
+	case 1, 2:
+		ts.extended = h.MessageHeader.HasExtendedTimestamp()
+		ts.delta = h.Timestamp()
+		if first {
+			ts.absolute += ts.delta
+		}
+	case 3:
+		if ts.extended {
+			if err := h.ExtendedTimestamp.Read(r.src); err != nil {
+				return 0, err
+			}
+		}
+
+		if first {
+			if ts.extended {
+				ts.delta = h.ExtendedTimestamp.Delta
+			}
+
+			ts.absolute += ts.delta
+		}
+	}
+
+	return ts.absolute, nil
+}
+
+// hasBuilder returns whether or not a message is currently being built over
+// the given chunk stream.
+func (r *DefaultReader) hasBuilder(streamId uint32) bool {
+	r.bmu.Lock()
+	defer r.bmu.Unlock()
+
+	_, ok := r.builders[streamId]
+	return ok
+}
+
+// builder returns the Builder associated with the given header's chunk stream,
+// creating one if it does not exist. New builders are initialized with a copy
+// of the given header, holding the absolute timestamp of the message.
+func (r *DefaultReader) builder(header *Header, absolute uint32) *Builder {
 	r.bmu.Lock()
 	defer r.bmu.Unlock()
 
 	streamId := header.BasicHeader.StreamId
 	if r.builders[streamId] == nil {
-		r.builders[streamId] = NewBuilder(header)
+		h := *header
+		h.SetTimestamp(absolute)
+		h.MessageHeader.TimestampDelta = false
+
+		b := NewBuilder(&h)
+		b.pool = r.bufferPool()
+
+		r.builders[streamId] = b
 	}
 
 	return r.builders[streamId]
@@ -2,8 +2,13 @@ package chunk_test
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"net"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/WatchBeam/rtmp/chunk"
 	"github.com/stretchr/testify/assert"
@@ -158,3 +163,280 @@ func TestReadMultiChunkMultiPass(t *testing.T) {
 	assert.Equal(t, c1, r1)
 	assert.Equal(t, c2, r2)
 }
+
+func TestReadReconstructsExtendedTimestamps(t *testing.T) {
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{
+		// Message 1, Part 1: Type 0, extended timestamp 0x01000000
+		4, 0xff, 0xff, 0xff, 0, 0, 8, 9, 1, 0, 0, 0,
+		0x01, 0x00, 0x00, 0x00,
+		0, 1, 2, 3,
+		// Message 1, Part 2: Type 3, repeating the extended timestamp
+		byte((3 << 6) | 4),
+		0x01, 0x00, 0x00, 0x00,
+		4, 5, 6, 7,
+		// Message 2: Type 1, extended timestamp delta 0x01000005
+		byte((1 << 6) | 4), 0xff, 0xff, 0xff, 0, 0, 4, 9,
+		0x01, 0x00, 0x00, 0x05,
+		8, 9, 10, 11,
+		// Message 3: Type 3, re-using the extended timestamp delta
+		byte((3 << 6) | 4),
+		0x01, 0x00, 0x00, 0x05,
+		12, 13, 14, 15,
+	})
+
+	r := chunk.NewReader(buf, 4, chunk.NewNormalizer())
+	go r.Recv()
+
+	c1 := <-r.Chunks()
+	c2 := <-r.Chunks()
+	c3 := <-r.Chunks()
+
+	assert.Equal(t, uint32(0x01000000), c1.Header.Timestamp())
+	assert.Equal(t, []byte{0, 1, 2, 3, 4, 5, 6, 7}, c1.Data)
+
+	assert.Equal(t, uint32(0x02000005), c2.Header.Timestamp())
+	assert.Equal(t, []byte{8, 9, 10, 11}, c2.Data)
+
+	assert.Equal(t, uint32(0x0300000a), c3.Header.Timestamp())
+	assert.Equal(t, []byte{12, 13, 14, 15}, c3.Data)
+}
+
+func TestReadAccumulatesTimestampDeltas(t *testing.T) {
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{
+		// Type 0, timestamp 1000
+		4, 0, 0x03, 0xe8, 0, 0, 1, 9, 1, 0, 0, 0, 0,
+		// Type 2, timestamp delta 40
+		byte((2 << 6) | 4), 0, 0, 40, 1,
+	})
+
+	r := chunk.NewReader(buf, chunk.DefaultReadSize, chunk.NewNormalizer())
+	go r.Recv()
+
+	c1 := <-r.Chunks()
+	c2 := <-r.Chunks()
+
+	assert.Equal(t, uint32(1000), c1.Header.Timestamp())
+	assert.Equal(t, uint32(1040), c2.Header.Timestamp())
+	assert.False(t, c2.Header.MessageHeader.TimestampDelta)
+}
+
+func TestReaderOptionsDefaultToTheRTMPChunkSize(t *testing.T) {
+	r := chunk.NewReaderWithOptions(new(bytes.Buffer), chunk.NoopNormalizer)
+
+	assert.Equal(t, chunk.DefaultReadSize, r.ReadSize())
+}
+
+func TestReaderOptionsSetTheInitialReadSize(t *testing.T) {
+	b := new(bytes.Buffer)
+	c := &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader:   chunk.BasicHeader{0, 18},
+			MessageHeader: chunk.MessageHeader{0, 1234, false, 8, 2, 3},
+		},
+		Data: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+	}
+
+	chunk.NewWriter(b, 4).Write(c)
+
+	for _, size := range []int{0, 1, 4096} {
+		r := chunk.NewReaderWithOptions(bytes.NewReader(b.Bytes()),
+			chunk.NoopNormalizer,
+			chunk.ReadBufferSize(size), chunk.InitialReadSize(4))
+		go r.Recv()
+
+		assert.Equal(t, 4, r.ReadSize())
+		assert.Equal(t, c, <-r.Chunks(), fmt.Sprintf("buffer size %v", size))
+	}
+}
+
+// benchmarkReader reads b.N messages of `length` bytes, written with the given
+// chunk size, through a Reader with the given buffer size.
+func benchmarkReader(b *testing.B, bufferSize, chunkSize, length int) {
+	c := &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader: chunk.BasicHeader{0, 6},
+			MessageHeader: chunk.MessageHeader{
+				Length: uint32(length), TypeId: 9, StreamId: 1,
+			},
+		},
+		Data: make([]byte, length),
+	}
+
+	src := new(bytes.Buffer)
+	w := chunk.NewWriter(src, chunkSize)
+	for i := 0; i < b.N; i++ {
+		w.Write(c)
+	}
+
+	r := chunk.NewReaderWithOptions(src, chunk.NoopNormalizer,
+		chunk.ReadBufferSize(bufferSize),
+		chunk.InitialReadSize(chunkSize))
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-r.Errs():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	b.SetBytes(int64(length))
+	b.ResetTimer()
+
+	go r.Recv()
+	for i := 0; i < b.N; i++ {
+		<-r.Chunks()
+	}
+
+	b.StopTimer()
+
+	r.Close()
+	close(done)
+}
+
+func BenchmarkReaderBufferSizes(b *testing.B) {
+	for _, chunkSize := range []int{chunk.DefaultReadSize, 4096} {
+		for _, bufferSize := range []int{0, 4096, 65536} {
+			b.Run(fmt.Sprintf("chunk=%v/buffer=%v", chunkSize, bufferSize),
+				func(b *testing.B) {
+					benchmarkReader(b, bufferSize, chunkSize, 16384)
+				})
+		}
+	}
+}
+
+func TestReaderReturnsErrIdleTimeoutWhenNoChunksArrive(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	r := chunk.NewReaderWithOptions(conn, chunk.NoopNormalizer,
+		chunk.IdleTimeout(conn, 10*time.Millisecond))
+	go r.Recv()
+
+	assert.Equal(t, chunk.ErrIdleTimeout, <-r.Errs())
+
+	r.Close()
+}
+
+func TestReaderIdleTimeoutsResetOnEachChunk(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	r := chunk.NewReaderWithOptions(conn, chunk.NoopNormalizer,
+		chunk.ReadBufferSize(0),
+		chunk.IdleTimeout(conn, 50*time.Millisecond))
+	go r.Recv()
+	defer r.Close()
+
+	c := &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader:   chunk.BasicHeader{0, 18},
+			MessageHeader: chunk.MessageHeader{0, 1234, false, 8, 2, 3},
+		},
+		Data: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+	}
+
+	go func() {
+		w := chunk.NewWriter(peer, chunk.DefaultReadSize)
+		for i := 0; i < 5; i++ {
+			time.Sleep(20 * time.Millisecond)
+			w.Write(c)
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		select {
+		case read := <-r.Chunks():
+			assert.Equal(t, c, read)
+		case err := <-r.Errs():
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestReaderStopsAtTheReadDeadline(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	r := chunk.NewReaderWithOptions(conn, chunk.NoopNormalizer,
+		chunk.IdleTimeout(conn, time.Second)).(*chunk.DefaultReader)
+	r.SetReadDeadline(conn, time.Now().Add(10*time.Millisecond))
+	go r.Recv()
+
+	err := <-r.Errs()
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded), "%v", err)
+
+	r.Close()
+}
+
+func TestReaderIdleTimeoutsPassBeforeTheReadDeadline(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	r := chunk.NewReaderWithOptions(conn, chunk.NoopNormalizer,
+		chunk.IdleTimeout(conn, 10*time.Millisecond)).(*chunk.DefaultReader)
+	r.SetReadDeadline(conn, time.Now().Add(time.Second))
+	go r.Recv()
+
+	assert.Equal(t, chunk.ErrIdleTimeout, <-r.Errs())
+
+	r.Close()
+}
+
+func TestReaderReturnsErrMessageTooLargeForOversizedMessages(t *testing.T) {
+	h := &chunk.Header{
+		BasicHeader: chunk.BasicHeader{0, 4},
+		MessageHeader: chunk.MessageHeader{
+			Length:   0xffffff,
+			TypeId:   9,
+			StreamId: 1,
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	h.Write(buf)
+
+	r := chunk.NewReaderWithOptions(buf, chunk.NoopNormalizer,
+		chunk.MaxMessageSize(1024))
+	go r.Recv()
+
+	assert.Equal(t, chunk.ErrMessageTooLarge, <-r.Errs())
+
+	r.Close()
+}
+
+func TestReadersLimitMessageSizesByDefault(t *testing.T) {
+	r := chunk.NewReader(new(bytes.Buffer), chunk.DefaultReadSize,
+		chunk.NoopNormalizer).(*chunk.DefaultReader)
+
+	assert.Equal(t, chunk.DefaultMaxMessageSize, r.MaxMessageSize())
+
+	r.SetMaxMessageSize(-1)
+	assert.Equal(t, 0, r.MaxMessageSize())
+}
+
+func TestReadersRejectOversizedMessagesByDefault(t *testing.T) {
+	h := &chunk.Header{
+		BasicHeader: chunk.BasicHeader{0, 4},
+		MessageHeader: chunk.MessageHeader{
+			Length:   0xffffff,
+			TypeId:   9,
+			StreamId: 1,
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	h.Write(buf)
+
+	r := chunk.NewReader(buf, chunk.DefaultReadSize, chunk.NoopNormalizer)
+	go r.Recv()
+
+	assert.Equal(t, chunk.ErrMessageTooLarge, <-r.Errs())
+
+	r.Close()
+}
@@ -2,12 +2,29 @@ package chunk
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"net"
 	"sync"
+	"time"
 
 	"github.com/WatchBeam/rtmp/spec"
 )
 
+var (
+	// ErrWriteTimeout is returned by Write when a chunk could not be
+	// written within the write timeout (see SetWriteTimeout).
+	ErrWriteTimeout = errors.New("rtmp/chunk: chunk not written within the write timeout")
+)
+
+// WriteDeadliner is implemented by connections which support write deadlines,
+// such as net.Conn.
+type WriteDeadliner interface {
+	// SetWriteDeadline sets the time after which writes fail with an
+	// error whose Timeout method returns true.
+	SetWriteDeadline(t time.Time) error
+}
+
 // DefaultWriter provides a default implementation of chunk.Writer interface.
 type DefaultWriter struct {
 	// dest is the io.Writer where chunks are written to.
@@ -18,6 +35,18 @@ type DefaultWriter struct {
 	// writeSize is the maximum payload length of a single chunk that can
 	// be written without haveing to write multiple chunks.
 	writeSize int
+
+	// dmu guards deadliner, writeTimeout, and deadline.
+	dmu sync.Mutex
+	// deadliner is the connection whose write deadline is set before each
+	// chunk is written, if writeTimeout is non-zero.
+	deadliner WriteDeadliner
+	// writeTimeout is the amount of time that writing a single chunk may
+	// take before ErrWriteTimeout is returned.
+	writeTimeout time.Duration
+	// deadline is the time after which no more chunks are written, or the
+	// zero time if there is none (see SetWriteDeadline).
+	deadline time.Time
 }
 
 var _ Writer = new(DefaultWriter)
@@ -38,6 +67,114 @@ func (w *DefaultWriter) SetWriteSize(writeSize int) {
 	w.writeSize = writeSize
 }
 
+// SetChunkSize implements the SetChunkSize function defined in the Writer
+// interface.
+func (w *DefaultWriter) SetChunkSize(size uint32) error {
+	if err := ValidateChunkSize(size); err != nil {
+		return err
+	}
+
+	if err := w.Write(NewSetChunkSize(size)); err != nil {
+		return err
+	}
+
+	w.SetWriteSize(int(size))
+
+	return nil
+}
+
+// SetWriteTimeout sets the amount of time that writing a single chunk may take
+// before Write gives up and returns ErrWriteTimeout. The write deadline of
+// `conn`, which should be the connection that chunks are written to, is set
+// before each chunk is written. This keeps a peer which has stopped reading
+// (and whose TCP send buffer has filled) from blocking the writing goroutine
+// forever.
+//
+// Once a write has timed out, the chunk may have been partially written, so
+// the connection should be torn down.
+//
+// A timeout of zero or less disables the write timeout, and resets the write
+// deadline of `conn` to the one set by SetWriteDeadline, if any.
+func (w *DefaultWriter) SetWriteTimeout(conn WriteDeadliner, timeout time.Duration) {
+	w.dmu.Lock()
+	defer w.dmu.Unlock()
+
+	w.deadliner = conn
+	w.writeTimeout = timeout
+
+	if conn != nil && timeout <= 0 {
+		conn.SetWriteDeadline(w.deadline)
+	}
+}
+
+// SetWriteDeadline sets the time after which no more chunks are written to
+// `conn`, which should be the connection that chunks are written to, for
+// instance to bound the length of a session. Once it passes, writes fail with
+// the error returned by the connection (such as os.ErrDeadlineExceeded). The
+// zero time clears the deadline.
+//
+// The deadline takes precedence over the write timeout (see SetWriteTimeout),
+// which never pushes the write deadline of `conn` past it.
+func (w *DefaultWriter) SetWriteDeadline(conn WriteDeadliner, t time.Time) {
+	w.dmu.Lock()
+	defer w.dmu.Unlock()
+
+	w.deadliner = conn
+	w.deadline = t
+
+	if conn != nil {
+		conn.SetWriteDeadline(w.nextDeadline())
+	}
+}
+
+// extendDeadline sets the write deadline of the connection to the write
+// timeout from now, if there is one.
+func (w *DefaultWriter) extendDeadline() {
+	w.dmu.Lock()
+	defer w.dmu.Unlock()
+
+	if w.deadliner != nil && w.writeTimeout > 0 {
+		w.deadliner.SetWriteDeadline(w.nextDeadline())
+	}
+}
+
+// nextDeadline returns the write deadline of the connection for the next
+// chunk: the write timeout from now, if there is one, unless the deadline set
+// by SetWriteDeadline comes first. It must be called with dmu held.
+func (w *DefaultWriter) nextDeadline() time.Time {
+	if w.writeTimeout <= 0 {
+		return w.deadline
+	}
+
+	timeout := time.Now().Add(w.writeTimeout)
+	if !w.deadline.IsZero() && w.deadline.Before(timeout) {
+		return w.deadline
+	}
+	return timeout
+}
+
+// timeout returns ErrWriteTimeout if the given error was caused by the write
+// timeout passing, or the error itself otherwise, including when it was
+// caused by the deadline set by SetWriteDeadline passing.
+func (w *DefaultWriter) timeout(err error) error {
+	w.dmu.Lock()
+	defer w.dmu.Unlock()
+
+	if w.deadliner == nil || w.writeTimeout <= 0 {
+		return err
+	}
+
+	if !w.deadline.IsZero() && !time.Now().Before(w.deadline) {
+		return err
+	}
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return ErrWriteTimeout
+	}
+
+	return err
+}
+
 // Write implements the Write function defined in the Writer interface.
 func (w *DefaultWriter) Write(c *Chunk) error {
 	payload := bytes.NewBuffer(c.Data)
@@ -49,15 +186,26 @@ func (w *DefaultWriter) Write(c *Chunk) error {
 			w.WriteSize())))
 
 		if payload.Len() > 0 {
-			out.Write([]byte{byte(
-				(3 << 6) | (c.Header.BasicHeader.StreamId & 63)),
-			})
+			w.writeContinuation(out, c.Header)
 		}
 	}
 
+	w.extendDeadline()
 	if _, err := io.Copy(w.dest, out); err != nil {
-		return err
+		return w.timeout(err)
 	}
 
 	return nil
 }
+
+// writeContinuation writes the Type 3 header preceding each continuation chunk
+// of a message with the given header. Per the RTMP specification, the
+// ExtendedTimestamp is repeated when the message's header carries one.
+func (w *DefaultWriter) writeContinuation(out io.Writer, h *Header) {
+	cont := &BasicHeader{FormatId: 3, StreamId: h.BasicHeader.StreamId}
+	cont.Write(out)
+
+	if h.MessageHeader.HasExtendedTimestamp() {
+		h.ExtendedTimestamp.Write(out)
+	}
+}
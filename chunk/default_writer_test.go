@@ -2,8 +2,13 @@ package chunk_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/WatchBeam/rtmp/chunk"
 	"github.com/stretchr/testify/assert"
@@ -66,3 +71,80 @@ func TestMultipleWrites(t *testing.T) {
 		fmt.Sprintf("test: slice should be equal (%v, %v)", expected.Bytes(),
 			buf.Bytes()))
 }
+
+func TestMultipleWritesRepeatExtendedTimestamps(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader:       chunk.BasicHeader{0, 18},
+			MessageHeader:     chunk.MessageHeader{0, 0xffffff, false, 8, 2, 3},
+			ExtendedTimestamp: chunk.ExtendedTimestamp{0x01000000},
+		},
+		Data: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+	}
+
+	err := chunk.NewWriter(buf, 4).Write(c)
+
+	assert.Nil(t, err)
+
+	expected := new(bytes.Buffer)
+	(&chunk.BasicHeader{0, 18}).Write(expected)
+	(&chunk.MessageHeader{0, 0xffffff, false, 8, 2, 3}).Write(expected)
+	expected.Write([]byte{0x01, 0x00, 0x00, 0x00})
+	expected.Write([]byte{0x00, 0x01, 0x02, 0x03})
+	expected.Write([]byte{byte((3 << 6) | 18&63)})
+	expected.Write([]byte{0x01, 0x00, 0x00, 0x00})
+	expected.Write([]byte{0x04, 0x05, 0x06, 0x07})
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestWritesTimeOutWhenThePeerStopsReading(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	w := chunk.NewWriter(conn, chunk.DefaultReadSize).(*chunk.DefaultWriter)
+	w.SetWriteTimeout(conn, 10*time.Millisecond)
+
+	err := w.Write(&chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader:   chunk.BasicHeader{0, 18},
+			MessageHeader: chunk.MessageHeader{0, 1234, false, 4, 2, 3},
+		},
+		Data: []byte{0x00, 0x01, 0x02, 0x03},
+	})
+
+	assert.Equal(t, chunk.ErrWriteTimeout, err)
+}
+
+func TestWritesDoNotTimeOutWithoutAWriteTimeout(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	w := chunk.NewWriter(conn, chunk.DefaultReadSize).(*chunk.DefaultWriter)
+	w.SetWriteTimeout(conn, 10*time.Millisecond)
+	w.SetWriteTimeout(conn, 0)
+
+	errs := make(chan error)
+	go func() { errs <- w.SetChunkSize(4096) }()
+
+	time.Sleep(20 * time.Millisecond)
+	go ioutil.ReadAll(peer)
+
+	assert.Nil(t, <-errs)
+}
+
+func TestWritesFailOnceTheWriteDeadlinePasses(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	w := chunk.NewWriter(conn, chunk.DefaultReadSize).(*chunk.DefaultWriter)
+	w.SetWriteTimeout(conn, time.Second)
+	w.SetWriteDeadline(conn, time.Now().Add(10*time.Millisecond))
+
+	start := time.Now()
+	err := w.SetChunkSize(4096)
+
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded), "%v", err)
+	assert.True(t, time.Since(start) < time.Second)
+}
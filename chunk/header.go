@@ -57,3 +57,28 @@ func (h *Header) Write(w io.Writer) error {
 
 	return nil
 }
+
+// Timestamp returns the complete timestamp (or timestamp delta, depending on
+// the format of this header). If the MessageHeader's timestamp field is
+// saturated (0xffffff), the value held in the ExtendedTimestamp is returned
+// instead.
+func (h *Header) Timestamp() uint32 {
+	if h.MessageHeader.HasExtendedTimestamp() {
+		return h.ExtendedTimestamp.Delta
+	}
+
+	return h.MessageHeader.Timestamp
+}
+
+// SetTimestamp sets the complete timestamp (or timestamp delta) of this header
+// to `ts`, using the ExtendedTimestamp to encode it if it does not fit within
+// the MessageHeader's 3-byte timestamp field.
+func (h *Header) SetTimestamp(ts uint32) {
+	if ts >= 0xffffff {
+		h.MessageHeader.Timestamp = 0xffffff
+		h.ExtendedTimestamp.Delta = ts
+	} else {
+		h.MessageHeader.Timestamp = ts
+		h.ExtendedTimestamp.Delta = 0
+	}
+}
@@ -35,3 +35,32 @@ func TestHeaderReading(t *testing.T) {
 	assert.Equal(t, uint32(0xffffff), h.MessageHeader.Timestamp)
 	assert.Equal(t, uint32(1234), h.ExtendedTimestamp.Delta)
 }
+
+func TestHeaderTimestampReadsExtendedTimestamps(t *testing.T) {
+	h := &chunk.Header{
+		MessageHeader:     chunk.MessageHeader{Timestamp: 0xffffff},
+		ExtendedTimestamp: chunk.ExtendedTimestamp{Delta: 0x01000000},
+	}
+
+	assert.Equal(t, uint32(0x01000000), h.Timestamp())
+}
+
+func TestHeaderTimestampReadsMessageHeaderTimestamps(t *testing.T) {
+	h := &chunk.Header{
+		MessageHeader: chunk.MessageHeader{Timestamp: 1234},
+	}
+
+	assert.Equal(t, uint32(1234), h.Timestamp())
+}
+
+func TestHeaderSetTimestampUsesExtendedTimestampWhenNecessary(t *testing.T) {
+	h := new(chunk.Header)
+
+	h.SetTimestamp(0x01000000)
+	assert.Equal(t, uint32(0xffffff), h.MessageHeader.Timestamp)
+	assert.Equal(t, uint32(0x01000000), h.ExtendedTimestamp.Delta)
+
+	h.SetTimestamp(1234)
+	assert.Equal(t, uint32(1234), h.MessageHeader.Timestamp)
+	assert.Equal(t, uint32(0), h.ExtendedTimestamp.Delta)
+}
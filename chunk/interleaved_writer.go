@@ -0,0 +1,203 @@
+package chunk
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAudioBias is the head start given to audio chunks by an
+	// InterleavedWriter unless configured otherwise (see SetAudioBias).
+	DefaultAudioBias = 50 * time.Millisecond
+
+	// audioTypeId is the message type ID of audio messages.
+	audioTypeId byte = 0x08
+)
+
+// InterleavedWriter is an implementation of the Writer interface which queues
+// the audio, video, and script data chunks submitted over each message stream
+// (see Submit), and writes them to another Writer in order of their timestamps
+// (see Flush), rather than in the order they were submitted. This keeps a relay
+// from starving audio behind a burst of video, such as a large keyframe, which
+// would otherwise put audio and video out of sync on the viewer's end.
+//
+// Audio is given a head start (see SetAudioBias): an audio chunk is written
+// before any video or data chunk whose timestamp is less than the bias later
+// than its own. Chunks of the same type over the same message stream are always
+// written in the order they were submitted.
+//
+// Chunks given to Write bypass the queues, and are written immediately. This is
+// meant for protocol control messages and commands, which are not interleaved.
+type InterleavedWriter struct {
+	Writer
+
+	// fmu serializes calls to Flush, so that chunks are written in order.
+	fmu sync.Mutex
+
+	// qmu guards queues, pending, and bias.
+	qmu sync.Mutex
+	// queues holds the chunks submitted over each message stream, by
+	// message stream ID and type ID, in the order they were submitted.
+	queues map[interleavedKey][]*Chunk
+	// pending is the total number of chunks held in queues.
+	pending int
+	// bias is the head start given to audio chunks, in milliseconds.
+	bias uint32
+}
+
+var _ Writer = new(InterleavedWriter)
+var _ Flusher = new(InterleavedWriter)
+
+// interleavedKey identifies a queue of an InterleavedWriter.
+type interleavedKey struct {
+	// streamId is the ID of the message stream that the chunks belong to.
+	streamId uint32
+	// typeId is the message type ID of the chunks.
+	typeId byte
+}
+
+// NewInterleavedWriter returns a new *InterleavedWriter which writes
+// interleaved chunks to the given Writer, with the DefaultAudioBias.
+func NewInterleavedWriter(w Writer) *InterleavedWriter {
+	iw := &InterleavedWriter{
+		Writer: w,
+		queues: make(map[interleavedKey][]*Chunk),
+	}
+	iw.SetAudioBias(DefaultAudioBias)
+
+	return iw
+}
+
+// SetAudioBias sets the head start given to audio chunks over video and data
+// chunks. A bias of zero or less interleaves all chunks strictly by timestamp.
+func (w *InterleavedWriter) SetAudioBias(bias time.Duration) {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+
+	if bias < 0 {
+		bias = 0
+	}
+	w.bias = uint32(bias / time.Millisecond)
+}
+
+// Submit queues the given chunk to be written over its message stream by the
+// next call to Flush. The chunk is not modified, nor copied, so it must not be
+// modified until it has been written.
+func (w *InterleavedWriter) Submit(c *Chunk) {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+
+	key := interleavedKey{c.Header.MessageHeader.StreamId, c.TypeId()}
+	w.queues[key] = append(w.queues[key], c)
+	w.pending++
+}
+
+// Pending returns the number of chunks which have been submitted, but not yet
+// written.
+func (w *InterleavedWriter) Pending() int {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+
+	return w.pending
+}
+
+// Flush implements Flusher.Flush. It writes each submitted chunk to the
+// underlying Writer in interleaved order (see InterleavedWriter), and then
+// flushes the underlying Writer, if it is a Flusher. If writing a chunk fails,
+// the error is returned, and the chunks not yet written remain queued.
+func (w *InterleavedWriter) Flush() error {
+	w.fmu.Lock()
+	defer w.fmu.Unlock()
+
+	for {
+		c := w.next()
+		if c == nil {
+			break
+		}
+
+		if err := w.Writer.Write(c); err != nil {
+			return err
+		}
+		w.pop(c)
+	}
+
+	if f, ok := w.Writer.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// next returns the chunk at the head of the queue which should be written
+// next, or nil if there are none.
+func (w *InterleavedWriter) next() *Chunk {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+
+	var (
+		next    *Chunk
+		nextKey interleavedKey
+	)
+
+	for key, q := range w.queues {
+		if len(q) == 0 {
+			continue
+		}
+
+		if next == nil || w.before(q[0], key, next, nextKey) {
+			next, nextKey = q[0], key
+		}
+	}
+
+	return next
+}
+
+// pop removes the given chunk from the head of its queue, once it has been
+// written.
+func (w *InterleavedWriter) pop(c *Chunk) {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+
+	key := interleavedKey{c.Header.MessageHeader.StreamId, c.TypeId()}
+	if q := w.queues[key]; len(q) > 0 && q[0] == c {
+		q[0] = nil
+		if len(q) == 1 {
+			delete(w.queues, key)
+		} else {
+			w.queues[key] = q[1:]
+		}
+		w.pending--
+	}
+}
+
+// before returns whether or not the chunk `a` should be written before the
+// chunk `b`, given the keys of their queues. Ties are broken in favor of audio,
+// and then by message stream ID and type ID, so that the order does not depend
+// on the iteration order of the queues. The caller must hold qmu.
+func (w *InterleavedWriter) before(a *Chunk, ak interleavedKey,
+	b *Chunk, bk interleavedKey) bool {
+
+	at, bt := w.priority(a), w.priority(b)
+	if at != bt {
+		return at < bt
+	}
+
+	if aa, ba := ak.typeId == audioTypeId, bk.typeId == audioTypeId; aa != ba {
+		return aa
+	}
+	if ak.streamId != bk.streamId {
+		return ak.streamId < bk.streamId
+	}
+	return ak.typeId < bk.typeId
+}
+
+// priority returns the timestamp used to order the given chunk, which is its
+// own timestamp, less the audio bias if it is an audio chunk. The caller must
+// hold qmu.
+func (w *InterleavedWriter) priority(c *Chunk) int64 {
+	ts := int64(c.Timestamp())
+	if c.TypeId() == audioTypeId {
+		ts -= int64(w.bias)
+	}
+
+	return ts
+}
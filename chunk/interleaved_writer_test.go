@@ -0,0 +1,93 @@
+package chunk_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestInterleavedWriterImplementsWriterAndFlusher(t *testing.T) {
+	w := chunk.NewInterleavedWriter(chunk.NoopWriter)
+
+	assert.Implements(t, (*chunk.Writer)(nil), w)
+	assert.Implements(t, (*chunk.Flusher)(nil), w)
+}
+
+func TestInterleavedWriterWritesChunksInTimestampOrder(t *testing.T) {
+	mw := new(MockWriter)
+	mw.On("Write", mock.Anything).Return(nil)
+
+	w := chunk.NewInterleavedWriter(mw)
+	w.SetAudioBias(0)
+	for _, c := range []*chunk.Chunk{
+		newTestChunk(4, 0x09, 1, 0, 1),
+		newTestChunk(4, 0x09, 1, 40, 1),
+		newTestChunk(4, 0x09, 2, 10, 1),
+		newTestChunk(4, 0x08, 1, 20, 1),
+		newTestChunk(4, 0x08, 1, 40, 1),
+	} {
+		w.Submit(c)
+	}
+
+	assert.Equal(t, 5, w.Pending())
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, 0, w.Pending())
+
+	assert.Equal(t, []uint32{0, 10, 20, 40, 40}, written(mw))
+	// Audio is written first when timestamps are tied.
+	assert.EqualValues(t, 0x08, mw.Calls[3].Arguments.Get(0).(*chunk.Chunk).TypeId())
+}
+
+func TestInterleavedWriterGivesAudioAHeadStart(t *testing.T) {
+	mw := new(MockWriter)
+	mw.On("Write", mock.Anything).Return(nil)
+
+	w := chunk.NewInterleavedWriter(mw)
+	w.SetAudioBias(50 * time.Millisecond)
+	for _, c := range []*chunk.Chunk{
+		newTestChunk(4, 0x09, 1, 0, 1),
+		newTestChunk(4, 0x09, 1, 10, 1),
+		newTestChunk(4, 0x09, 1, 100, 1),
+		newTestChunk(4, 0x08, 1, 30, 1),
+	} {
+		w.Submit(c)
+	}
+
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, []uint32{30, 0, 10, 100}, written(mw))
+}
+
+func TestInterleavedWriterKeepsChunksQueuedWhenWritingFails(t *testing.T) {
+	err := errors.New("write failed")
+
+	mw := new(MockWriter)
+	mw.On("Write", mock.Anything).Return(err).Once()
+	mw.On("Write", mock.Anything).Return(nil)
+
+	w := chunk.NewInterleavedWriter(mw)
+	w.Submit(newTestChunk(4, 0x09, 1, 0, 1))
+	w.Submit(newTestChunk(4, 0x09, 1, 10, 1))
+
+	assert.Equal(t, err, w.Flush())
+	assert.Equal(t, 2, w.Pending())
+
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, 0, w.Pending())
+	assert.Equal(t, []uint32{0, 0, 10}, written(mw))
+}
+
+func TestInterleavedWriterWritesDirectlyWithoutQueueing(t *testing.T) {
+	mw := new(MockWriter)
+	mw.On("Write", mock.Anything).Return(nil)
+
+	w := chunk.NewInterleavedWriter(mw)
+	w.Submit(newTestChunk(4, 0x09, 1, 10, 1))
+
+	assert.Nil(t, w.Write(newTestChunk(4, 0x14, 0, 20, 1)))
+	assert.Equal(t, []uint32{20}, written(mw))
+	assert.Equal(t, 1, w.Pending())
+}
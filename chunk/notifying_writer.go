@@ -0,0 +1,38 @@
+package chunk
+
+import "io"
+
+// NotifyingWriter is an io.Writer which calls a function with the number of
+// bytes written by each write to an underlying io.Writer. It is the counterpart
+// of the CountingReader, and is typically placed between a connection and its
+// Writer, so that a control stream may estimate bandwidth from the bytes that
+// are sent (see control.Stream.Sent).
+type NotifyingWriter struct {
+	// dest is the io.Writer that bytes are written to.
+	dest io.Writer
+	// notify is called after each write with the number of bytes written,
+	// if it is non-nil.
+	notify func(n int)
+}
+
+var _ io.Writer = new(NotifyingWriter)
+
+// NewNotifyingWriter returns a new *NotifyingWriter writing to `dest`. If
+// `notify` is non-nil, it is called after every write with the number of bytes
+// that were written, even if the write failed part way through.
+func NewNotifyingWriter(dest io.Writer, notify func(n int)) *NotifyingWriter {
+	return &NotifyingWriter{
+		dest:   dest,
+		notify: notify,
+	}
+}
+
+// Write implements the io.Writer.Write function.
+func (w *NotifyingWriter) Write(p []byte) (int, error) {
+	n, err := w.dest.Write(p)
+	if w.notify != nil {
+		w.notify(n)
+	}
+
+	return n, err
+}
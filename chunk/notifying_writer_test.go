@@ -0,0 +1,33 @@
+package chunk_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyingWriterNotifiesOfEachWrite(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	var sizes []int
+	w := chunk.NewNotifyingWriter(buf, func(n int) {
+		sizes = append(sizes, n)
+	})
+
+	w.Write([]byte{0x01, 0x02})
+	w.Write([]byte{0x03})
+
+	assert.Equal(t, []int{2, 1}, sizes)
+	assert.Equal(t, []byte{0x01, 0x02, 0x03}, buf.Bytes())
+}
+
+func TestNotifyingWriterMayNotNotify(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	n, err := chunk.NewNotifyingWriter(buf, nil).Write([]byte{0x01})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
@@ -36,8 +36,12 @@ type Parser struct {
 
 	// smu guards streams
 	smu sync.Mutex
-	// wg waits for the Recv loop to complete itself.
-	wg sync.WaitGroup
+	// rmu guards running and closed
+	rmu sync.Mutex
+	// running is whether or not the Recv loop has been started.
+	running bool
+	// closed is whether or not Close has been called.
+	closed bool
 	// streams maps chunk stream IDs (contained in the basic header of all
 	// chunks) to their appropriate chunk Stream
 	streams map[uint32]*stream
@@ -45,9 +49,12 @@ type Parser struct {
 	// errs holds a channel of all errors encountered during the read/write
 	// process.
 	errs chan error
-	// closer holds a channel that closes the Stream when anything is
-	// written to it.
+	// closer is closed when the Parser is closed.
 	closer chan struct{}
+	// closeOnce guards closer from being closed more than once.
+	closeOnce sync.Once
+	// done is closed once the Recv loop has completed itself.
+	done chan struct{}
 }
 
 // NewParser allocates and returns a pointer to a new instance of the Parser
@@ -61,6 +68,7 @@ func NewParser(reader Reader) *Parser {
 		streams: make(map[uint32]*stream),
 		errs:    make(chan error),
 		closer:  make(chan struct{}),
+		done:    make(chan struct{}),
 	}
 }
 
@@ -117,8 +125,24 @@ func (p *Parser) Stream(ids ...uint32) (Stream, error) {
 func (p *Parser) Errs() <-chan error { return p.errs }
 
 // Close halts the read/normalize process from all chunk streams and closes each
-// "child" input channel of all `Stream`s.
-func (p *Parser) Close() { p.closer <- struct{}{}; p.wg.Wait() }
+// "child" input channel of all `Stream`s. It does not block on chunks or errors
+// which have yet to be received. If Recv is running, Close blocks until it has
+// stopped. Otherwise, the channels are closed right away, and Recv will return
+// immediately if it is called later on. Calling Close more than once is a no-op.
+func (p *Parser) Close() {
+	p.closeOnce.Do(func() { close(p.closer) })
+
+	p.rmu.Lock()
+	running, closed := p.running, p.closed
+	p.closed = true
+	p.rmu.Unlock()
+
+	if running {
+		<-p.done
+	} else if !closed {
+		p.closeStreams()
+	}
+}
 
 // Recv is responsible for processing the chunks coming off of the underlying
 // chunk.Reader. It first normalizes them and then places them onto the
@@ -128,36 +152,72 @@ func (p *Parser) Close() { p.closer <- struct{}{}; p.wg.Wait() }
 //
 // Recv runs within its own goroutine.
 func (p *Parser) Recv() {
-	p.wg.Add(1)
-	defer p.wg.Done()
+	p.rmu.Lock()
+	if p.closed {
+		p.rmu.Unlock()
+		return
+	}
+	p.running = true
+	p.rmu.Unlock()
+
+	defer close(p.done)
 
 	go p.reader.Recv()
 
+	defer p.cleanup()
+
 	for {
 		select {
 		case in := <-p.reader.Chunks():
 			s, err := p.Stream(in.StreamId())
 			if err != nil {
-				p.errs <- err
+				if !p.send(err) {
+					return
+				}
 				continue
 			}
 
-			s.(*stream).in <- in
+			select {
+			case s.(*stream).in <- in:
+			case <-p.closer:
+				return
+			}
 		case err := <-p.reader.Errs():
-			p.errs <- err
+			if !p.send(err) {
+				return
+			}
 		case <-p.closer:
-			p.reader.Close()
+			return
+		}
+	}
+}
 
-			close(p.errs)
-			close(p.closer)
+// send passes the given error along over the Errs() channel, returning whether
+// or not it was received before the Parser was closed.
+func (p *Parser) send(err error) bool {
+	select {
+	case p.errs <- err:
+		return true
+	case <-p.closer:
+		return false
+	}
+}
 
-			p.smu.Lock()
-			for _, stream := range p.streams {
-				close(stream.in)
-			}
-			p.smu.Unlock()
+// cleanup stops the Reader, and closes the Errs() channel, as well as the
+// channel of each chunk stream, once Recv returns.
+func (p *Parser) cleanup() {
+	p.reader.Close()
+	p.closeStreams()
+}
 
-			return
-		}
+// closeStreams closes the Errs() channel, as well as the channel of each chunk
+// stream.
+func (p *Parser) closeStreams() {
+	close(p.errs)
+
+	p.smu.Lock()
+	for _, stream := range p.streams {
+		close(stream.in)
 	}
+	p.smu.Unlock()
 }
@@ -10,12 +10,15 @@ package chunk_test
 // this bug becomes fixed.
 
 import (
+	"bytes"
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/WatchBeam/rtmp/chunk"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
 )
 
 func TestNewStreamReturnsNewStreams(t *testing.T) {
@@ -65,6 +68,57 @@ func TestStreamClosesAfterSignalSent(t *testing.T) {
 	// reader.AssertExpectations(t)
 }
 
+func TestParserClosesWhileAChunkIsInFlight(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	buf := new(bytes.Buffer)
+	w := chunk.NewWriter(buf, chunk.DefaultReadSize)
+	for i := 0; i < 3; i++ {
+		w.Write(newTestChunk(6, 9, 1, 0, 8))
+	}
+
+	p := chunk.NewParser(NewReader(buf))
+	go p.Recv()
+
+	// Give the Parser and its Reader time to block on passing chunks along
+	// which are never read.
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		p.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("chunk: Close blocked on an unread chunk")
+	}
+}
+
+func TestParserClosesStreamsWithoutRecv(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	p := chunk.NewParser(&MockReader{})
+	s, err := p.Stream(4)
+	assert.Nil(t, err)
+	_, err = p.Stream(5, 6)
+	assert.Nil(t, err)
+
+	p.Close()
+	p.Close()
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+	_, ok = <-p.Errs()
+	assert.False(t, ok)
+
+	// Recv returns right away, without starting the Reader.
+	p.Recv()
+}
+
 func TestParserReturnsNewSingleChunkStreams(t *testing.T) {
 	parser := chunk.NewParser(nil)
 
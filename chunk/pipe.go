@@ -0,0 +1,109 @@
+package chunk
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Pipe returns a Reader and a Writer connected by an in-memory buffer, such
+// that each chunk written to the Writer is read by the Reader, as though they
+// were the two ends of a connection. Writing never blocks, since the buffer
+// grows as needed, so a crafted chunk stream may be written in full before the
+// Reader's Recv goroutine is started. This makes it possible to benchmark the
+// parsing and marshaling of chunks at full speed, without a socket.
+//
+// Both ends use the DefaultReadSize, and the Reader is buffered as it would be
+// by NewReaderWithOptions. A Set Chunk Size message written with
+// Writer.SetChunkSize applies to the Reader once it is read, as it would over
+// a connection. Closing the Reader closes the pipe, after which chunks written
+// to the Writer are discarded.
+func Pipe() (Reader, Writer) {
+	p := newPipeBuffer()
+
+	r := &pipeReader{
+		DefaultReader: NewReaderWithOptions(p, NewNormalizer()).(*DefaultReader),
+		buf:           p,
+	}
+
+	return r, NewWriter(p, DefaultReadSize)
+}
+
+// pipeReader is the Reader returned by Pipe.
+type pipeReader struct {
+	*DefaultReader
+
+	// buf is the buffer that chunks are read from.
+	buf *pipeBuffer
+}
+
+// Close implements the Reader.Close function. It closes the pipe, so that the
+// Recv goroutine is not left blocked on reading from it, and then closes the
+// DefaultReader.
+func (r *pipeReader) Close() {
+	r.buf.Close()
+	r.DefaultReader.Close()
+}
+
+// pipeBuffer is an in-memory buffer whose reads block until it has been
+// written to, or closed. It is safe for concurrent use.
+type pipeBuffer struct {
+	// mu guards buf and closed, and is held by cond.
+	mu sync.Mutex
+	// cond is signaled each time that buf is written to, or closed.
+	cond *sync.Cond
+	// buf holds the bytes written, but not yet read.
+	buf bytes.Buffer
+	// closed is true once Close has been called.
+	closed bool
+}
+
+// newPipeBuffer returns a new, empty *pipeBuffer.
+func newPipeBuffer() *pipeBuffer {
+	p := new(pipeBuffer)
+	p.cond = sync.NewCond(&p.mu)
+
+	return p
+}
+
+// Read implements io.Reader. It blocks until some bytes have been written, and
+// returns io.EOF once the buffer is closed.
+func (p *pipeBuffer) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.buf.Len() == 0 && !p.closed {
+		p.cond.Wait()
+	}
+
+	if p.closed {
+		return 0, io.EOF
+	}
+
+	return p.buf.Read(b)
+}
+
+// Write implements io.Writer. Bytes written once the buffer is closed are
+// discarded.
+func (p *pipeBuffer) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.closed {
+		p.buf.Write(b)
+		p.cond.Broadcast()
+	}
+
+	return len(b), nil
+}
+
+// Close implements io.Closer, waking any pending Read.
+func (p *pipeBuffer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.cond.Broadcast()
+
+	return nil
+}
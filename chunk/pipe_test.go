@@ -0,0 +1,56 @@
+package chunk_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeConnectsTheWriterToTheReader(t *testing.T) {
+	r, w := chunk.Pipe()
+	defer r.Close()
+
+	c := newTestChunk(6, 9, 1, 0, 1000)
+	assert.Nil(t, w.Write(c))
+	assert.Nil(t, w.Write(c))
+
+	go r.Recv()
+
+	for i := 0; i < 2; i++ {
+		read := <-r.Chunks()
+		assert.Equal(t, c.Data, read.Data)
+		assert.Equal(t, c.Header.MessageHeader, read.Header.MessageHeader)
+	}
+}
+
+func TestPipeAppliesSetChunkSizeToTheReader(t *testing.T) {
+	r, w := chunk.Pipe()
+	defer r.Close()
+	go r.Recv()
+
+	assert.Nil(t, w.SetChunkSize(4096))
+	assert.Nil(t, w.Write(newTestChunk(6, 9, 1, 0, 1000)))
+
+	<-r.Chunks()
+	assert.Equal(t, 4096, r.ReadSize())
+}
+
+func TestClosingThePipeStopsTheReader(t *testing.T) {
+	r, _ := chunk.Pipe()
+
+	done := make(chan struct{})
+	go func() {
+		r.Recv()
+		close(done)
+	}()
+
+	r.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Recv did not return once the pipe was closed")
+	}
+}
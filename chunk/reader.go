@@ -1,6 +1,10 @@
 package chunk
 
-import "io"
+import (
+	"bufio"
+	"io"
+	"time"
+)
 
 // Reader is an interface representing a type capable of reading multiplexed
 // chunks in the RTMP format over a io.Reader.
@@ -12,7 +16,10 @@ type Reader interface {
 	// encountering another chunk.
 	//
 	// If a chunk has been completely read, it is built and pushed over the
-	// channel.
+	// channel. The header of each built chunk carries the absolute
+	// timestamp of its message (see Header.Timestamp), reconstructed from
+	// the timestamp deltas and extended timestamps received over its chunk
+	// stream.
 	//
 	// Recv runs within its own goroutine.
 	Recv()
@@ -45,8 +52,121 @@ func NewReader(src io.Reader, readSize int, normalizer Normalizer) Reader {
 		readSize:   readSize,
 		normalizer: normalizer,
 		builders:   make(map[uint32]*Builder),
+		timestamps: make(map[uint32]*timestamp),
 		chunks:     make(chan *Chunk),
 		errs:       make(chan error),
 		closer:     make(chan struct{}),
+		done:       make(chan struct{}),
+
+		maxMessageSize: DefaultMaxMessageSize,
+	}
+}
+
+const (
+	// DefaultReadBufferSize is the default size, in bytes, of the buffer
+	// that NewReaderWithOptions places in front of its io.Reader. It is large
+	// enough to hold many chunks of the default size, such that reading
+	// each chunk's header and payload does not cost a read from the
+	// underlying connection.
+	DefaultReadBufferSize int = 4096
+)
+
+// ReaderOption configures a Reader constructed by NewReaderWithOptions.
+type ReaderOption func(*readerOptions)
+
+// readerOptions holds the values configured by ReaderOptions.
+type readerOptions struct {
+	// bufferSize is the size of the buffer placed in front of the
+	// io.Reader, or zero if it is unbuffered.
+	bufferSize int
+	// readSize is the initial maximum chunk size.
+	readSize int
+
+	// deadliner is the connection given to SetIdleTimeout, if any.
+	deadliner ReadDeadliner
+	// idleTimeout is the timeout given to SetIdleTimeout.
+	idleTimeout time.Duration
+
+	// maxMessageSize is the largest message length accepted.
+	maxMessageSize int
+
+	// pool is the BufferPool that payloads are taken from, if any.
+	pool *BufferPool
+}
+
+// ReadBufferSize sets the size, in bytes, of the buffer that is placed in front
+// of the Reader's io.Reader. Larger buffers suit high-bitrate ingest, where
+// chunks arrive back to back, while smaller buffers save memory when serving
+// many low-bitrate connections. A size of zero or less disables buffering
+// entirely. It defaults to DefaultReadBufferSize.
+//
+// Since the buffer reads ahead of the chunks that have been parsed, nothing
+// else should read from the same io.Reader once the Reader is constructed.
+func ReadBufferSize(size int) ReaderOption {
+	return func(o *readerOptions) { o.bufferSize = size }
+}
+
+// InitialReadSize sets the maximum chunk size that the Reader assumes before
+// the peer sends a Set Chunk Size protocol control message. It defaults to
+// DefaultReadSize, as required by the RTMP specification, and should only be
+// changed when the peer is known to use a different size from the start.
+func InitialReadSize(size int) ReaderOption {
+	return func(o *readerOptions) { o.readSize = size }
+}
+
+// IdleTimeout sets the idle timeout of the Reader, pushing back the read
+// deadline of the given connection before each chunk is read. See
+// DefaultReader.SetIdleTimeout for details. There is no idle timeout by
+// default.
+func IdleTimeout(conn ReadDeadliner, timeout time.Duration) ReaderOption {
+	return func(o *readerOptions) {
+		o.deadliner = conn
+		o.idleTimeout = timeout
 	}
 }
+
+// MaxMessageSize sets the largest message length, in bytes, that the Reader
+// accepts from the peer. See DefaultReader.SetMaxMessageSize for details. It
+// defaults to DefaultMaxMessageSize.
+func MaxMessageSize(size int) ReaderOption {
+	return func(o *readerOptions) { o.maxMessageSize = size }
+}
+
+// PayloadPool sets the BufferPool that the payloads of the chunks read by the
+// Reader are taken from. See DefaultReader.SetBufferPool for details. Payloads
+// are not pooled by default.
+func PayloadPool(p *BufferPool) ReaderOption {
+	return func(o *readerOptions) { o.pool = p }
+}
+
+// NewReaderWithOptions returns a new Reader, like NewReader, which reads chunks
+// from `src` through a buffer, normalizing their headers using the given
+// Normalizer. The size of the buffer and the initial maximum chunk size can be
+// configured independently with the given ReaderOptions (see ReadBufferSize and
+// InitialReadSize).
+func NewReaderWithOptions(src io.Reader, normalizer Normalizer,
+	opts ...ReaderOption) Reader {
+
+	o := &readerOptions{
+		bufferSize: DefaultReadBufferSize,
+		readSize:   DefaultReadSize,
+
+		maxMessageSize: DefaultMaxMessageSize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.bufferSize > 0 {
+		src = bufio.NewReaderSize(src, o.bufferSize)
+	}
+
+	r := NewReader(src, o.readSize, normalizer).(*DefaultReader)
+	r.SetMaxMessageSize(o.maxMessageSize)
+	r.SetBufferPool(o.pool)
+	if o.deadliner != nil {
+		r.SetIdleTimeout(o.deadliner, o.idleTimeout)
+	}
+
+	return r
+}
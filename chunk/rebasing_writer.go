@@ -0,0 +1,93 @@
+package chunk
+
+import "sync"
+
+// RebasingWriter is an implementation of the Writer interface which rewrites
+// the timestamps of outgoing chunks before writing them to another Writer. It
+// is useful for relays, where a viewer joining part-way through a stream should
+// see timestamps beginning near zero, rather than wherever the publisher's
+// timestamps happened to be.
+//
+// The absolute timestamp of the first chunk written over each message stream is
+// taken as that message stream's base, and subtracted from the timestamps of
+// all chunks written over it thereafter, so that audio and video remain in
+// sync. Timestamps are also kept monotonically increasing over each chunk
+// stream: a chunk which would be written with an earlier timestamp than the
+// last chunk over the same chunk stream is written with the last timestamp
+// instead.
+//
+// Chunks given to Write are not modified, so the same chunk may be written to
+// many RebasingWriters.
+type RebasingWriter struct {
+	Writer
+
+	// tmu guards bases and last.
+	tmu sync.Mutex
+	// bases maps message stream IDs to the absolute timestamp of the first
+	// chunk written over them.
+	bases map[uint32]uint32
+	// last maps chunk stream IDs to the rebased timestamp of the last chunk
+	// written over them.
+	last map[uint32]uint32
+}
+
+var _ Writer = new(RebasingWriter)
+
+// NewRebasingWriter returns a new *RebasingWriter which writes rebased chunks
+// to the given Writer.
+func NewRebasingWriter(w Writer) *RebasingWriter {
+	return &RebasingWriter{
+		Writer: w,
+		bases:  make(map[uint32]uint32),
+		last:   make(map[uint32]uint32),
+	}
+}
+
+// Write implements the Write function defined in the Writer interface. It
+// writes a copy of the given chunk, holding the rebased timestamp, to the
+// underlying Writer.
+func (w *RebasingWriter) Write(c *Chunk) error {
+	h := *c.Header
+
+	rebased := &Chunk{Header: &h, Data: c.Data}
+	rebased.SetTimestamp(w.rebase(c))
+
+	return w.Writer.Write(rebased)
+}
+
+// Reset forgets the base of each message stream, such that the next chunk
+// written over each is rebased to zero again.
+func (w *RebasingWriter) Reset() {
+	w.tmu.Lock()
+	defer w.tmu.Unlock()
+
+	w.bases = make(map[uint32]uint32)
+	w.last = make(map[uint32]uint32)
+}
+
+// rebase returns the rebased timestamp of the given chunk, recording it as the
+// last timestamp written over its chunk stream.
+func (w *RebasingWriter) rebase(c *Chunk) uint32 {
+	w.tmu.Lock()
+	defer w.tmu.Unlock()
+
+	ts := c.Timestamp()
+
+	base, ok := w.bases[c.Header.MessageHeader.StreamId]
+	if !ok {
+		base = ts
+		w.bases[c.Header.MessageHeader.StreamId] = base
+	}
+
+	var rebased uint32
+	if ts > base {
+		rebased = ts - base
+	}
+
+	if last := w.last[c.StreamId()]; rebased < last {
+		rebased = last
+	}
+	w.last[c.StreamId()] = rebased
+
+	return rebased
+}
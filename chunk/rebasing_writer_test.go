@@ -0,0 +1,81 @@
+package chunk_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// written returns the timestamps of each chunk written to the given
+// *MockWriter, in order.
+func written(w *MockWriter) []uint32 {
+	var ts []uint32
+	for _, call := range w.Calls {
+		ts = append(ts, call.Arguments.Get(0).(*chunk.Chunk).Timestamp())
+	}
+
+	return ts
+}
+
+func TestRebasingWriterImplementsWriter(t *testing.T) {
+	assert.Implements(t, (*chunk.Writer)(nil),
+		chunk.NewRebasingWriter(chunk.NoopWriter))
+}
+
+func TestRebasingWriterRebasesToZero(t *testing.T) {
+	mw := new(MockWriter)
+	mw.On("Write", mock.Anything).Return(nil)
+
+	w := chunk.NewRebasingWriter(mw)
+	for _, c := range []*chunk.Chunk{
+		newTestChunk(6, 9, 1, 5000, 4),
+		newTestChunk(4, 9, 1, 5010, 4),
+		newTestChunk(6, 9, 1, 5040, 4),
+		newTestChunk(8, 9, 2, 100, 4),
+	} {
+		assert.Nil(t, w.Write(c))
+	}
+
+	assert.Equal(t, []uint32{0, 10, 40, 0}, written(mw))
+}
+
+func TestRebasingWriterKeepsTimestampsMonotonic(t *testing.T) {
+	mw := new(MockWriter)
+	mw.On("Write", mock.Anything).Return(nil)
+
+	w := chunk.NewRebasingWriter(mw)
+	for _, c := range []*chunk.Chunk{
+		newTestChunk(6, 9, 1, 5000, 4),
+		newTestChunk(6, 9, 1, 5040, 4),
+		newTestChunk(6, 9, 1, 5020, 4),
+		newTestChunk(4, 9, 1, 4990, 4),
+	} {
+		assert.Nil(t, w.Write(c))
+	}
+
+	assert.Equal(t, []uint32{0, 40, 40, 0}, written(mw))
+}
+
+func TestRebasingWriterDoesNotModifyChunks(t *testing.T) {
+	mw := new(MockWriter)
+	mw.On("Write", mock.Anything).Return(nil)
+
+	c := newTestChunk(6, 9, 1, 5000, 4)
+	chunk.NewRebasingWriter(mw).Write(c)
+
+	assert.Equal(t, uint32(5000), c.Timestamp())
+}
+
+func TestRebasingWriterResets(t *testing.T) {
+	mw := new(MockWriter)
+	mw.On("Write", mock.Anything).Return(nil)
+
+	w := chunk.NewRebasingWriter(mw)
+	w.Write(newTestChunk(6, 9, 1, 5000, 4))
+	w.Reset()
+	w.Write(newTestChunk(6, 9, 1, 9000, 4))
+
+	assert.Equal(t, []uint32{0, 0}, written(mw))
+}
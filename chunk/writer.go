@@ -29,6 +29,12 @@ type Writer interface {
 	WriteSize() int
 	// SetWriteSize changes the write size of this particular Writer.
 	SetWriteSize(writeSize int)
+	// SetChunkSize negotiates a new chunk size with the peer by writing a
+	// Set Chunk Size protocol control message, and then applying the new
+	// size to all subsequently written chunks. If the size is outside of
+	// the range permitted by the RTMP specification, ErrInvalidChunkSize
+	// is returned, and nothing is written.
+	SetChunkSize(size uint32) error
 }
 
 // NewWriter returns a default implementation of the Writer interface.
@@ -24,3 +24,8 @@ func (w *MockWriter) WriteSize() int {
 func (w *MockWriter) SetWriteSize(size int) {
 	w.Called(size)
 }
+
+func (w *MockWriter) SetChunkSize(size uint32) error {
+	args := w.Called(size)
+	return args.Error(0)
+}
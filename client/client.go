@@ -1,54 +1,179 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"io"
+	"net"
+	"sync"
+	"time"
 
 	"github.com/WatchBeam/rtmp/chunk"
 	"github.com/WatchBeam/rtmp/cmd"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/WatchBeam/rtmp/cmd/stream"
 	"github.com/WatchBeam/rtmp/control"
 	"github.com/WatchBeam/rtmp/handshake"
+	"github.com/WatchBeam/rtmp/tracing"
+)
+
+var (
+	// ErrNoDeadlines is returned by SetIdleTimeout, SetWriteTimeout,
+	// SetReadDeadline, SetWriteDeadline, and SetDeadline when the Client's
+	// connection does not support deadlines.
+	ErrNoDeadlines = errors.New("rtmp/client: connection does not support deadlines")
 )
 
 // Client represents a client connected to a RTMP server (see
 // github.com/WatchBeam/rtmp/server for more). Clients are able to be written to
 // and read from, and may have additional metadata attached to them in the
 // future.
+//
+// A Client moves through the following lifecycle:
+//
+//  1. It is constructed over a connection with New or NewWithContext (the
+//     server does so for each connection it accepts, see
+//     server.Server.Clients). Its substreams (see Controls, NetStream, and
+//     DataStream) exist from then on, but nothing is read from the connection.
+//  2. Handshake is called, after which chunks are read from the connection
+//     and handed to the control stream, and to the *cmd.Manager returned by
+//     Net.
+//  3. The caller starts the control stream (control.Stream.Recv) and the
+//     *cmd.Manager (cmd.Manager.Dispatch), and consumes the commands and data
+//     of the session from the NetStream and DataStream.
+//  4. Close is called, canceling the context of the connection, which stops
+//     each of the substreams, and closing the connection.
 type Client struct {
 	chunks *chunk.Parser
+	// reader is the *chunk.DefaultReader that chunks are read from.
+	reader *chunk.DefaultReader
+	// netChunks is the stream of chunks handed to the cmdManager.
+	netChunks chunk.Stream
+	// writer is the chunk.Writer shared by the control and command
+	// streams.
+	writer *chunk.DefaultWriter
 
 	controlStream *control.Stream
 	cmdManager    *cmd.Manager
 
-	// Conn represents the readable and writeable connection that links to
+	// handshaken is true once the handshake has completed successfully.
+	handshaken bool
+
+	// ctx is the context of the connection, which is canceled by cancel
+	// when the Client is closed.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// closeOnce ensures that the connection is closed only once, and
+	// closeErr is the error encountered while doing so.
+	closeOnce sync.Once
+	closeErr  error
+
+	// conn represents the readable and writeable connection that links to
 	// the client. This may be a net.Conn, or even just a bytes.Buffer.
-	Conn io.ReadWriter
+	conn io.ReadWriter
 }
 
 // New instantiates and returns a pointer to a new instance of type Client. The
 // client is initialized with the given connection.
 func New(conn io.ReadWriter) *Client {
-	chunkWriter := chunk.NewWriter(conn, 4096)
-	chunks := chunk.NewParser(chunk.NewReader(
-		conn, chunk.DefaultReadSize, chunk.NewNormalizer(),
-	))
+	return NewWithContext(context.Background(), conn)
+}
+
+// NewWithContext behaves the same as New, but derives the context of the
+// connection from `ctx` (see Context). The context is handed to the control
+// stream, NetStream, and DataStream of the Client, so that values carried by it
+// reach their handlers, and they stop once it is done.
+func NewWithContext(ctx context.Context, conn io.ReadWriter) *Client {
+	var controlStream *control.Stream
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	chunkWriter := chunk.NewWriter(chunk.NewNotifyingWriter(conn, func(n int) {
+		controlStream.Sent(n)
+	}), 4096).(*chunk.DefaultWriter)
+	reader := chunk.NewReader(
+		chunk.NewCountingReader(conn, func(n int) error {
+			return controlStream.Received(n)
+		}),
+		chunk.DefaultReadSize, chunk.NewNormalizer(),
+	).(*chunk.DefaultReader)
+	chunks := chunk.NewParser(reader)
 
 	controlChunks, _ := chunks.Stream(2)
 	netChunks, _ := chunks.Stream(3, 4, 5, 8)
 
+	controlStream = control.NewStream(
+		controlChunks,
+		chunkWriter,
+		control.NewParser(),
+		control.NewChunker(),
+	)
+	controlStream.SetContext(ctx)
+
+	cmdManager := cmd.New(netChunks, chunkWriter)
+	cmdManager.SetContext(ctx)
+
 	return &Client{
-		chunks: chunks,
+		chunks:    chunks,
+		reader:    reader,
+		netChunks: netChunks,
+		writer:    chunkWriter,
+
+		controlStream: controlStream,
+
+		cmdManager: cmdManager,
+
+		ctx:    ctx,
+		cancel: cancel,
+
+		conn: conn,
+	}
+}
 
-		controlStream: control.NewStream(
-			controlChunks,
-			chunkWriter,
-			control.NewParser(),
-			control.NewChunker(),
-		),
+// Conn returns the connection that links to the client, if it is a net.Conn, or
+// nil otherwise (see ReadWriter).
+func (c *Client) Conn() net.Conn {
+	conn, _ := c.conn.(net.Conn)
+	return conn
+}
 
-		cmdManager: cmd.New(netChunks, chunkWriter),
+// ReadWriter returns the connection that links to the client, as given to New
+// or NewWithContext.
+func (c *Client) ReadWriter() io.ReadWriter { return c.conn }
 
-		Conn: conn,
+// RemoteAddr returns the address of the client, if its connection is a
+// net.Conn, or nil otherwise.
+func (c *Client) RemoteAddr() net.Addr {
+	if conn := c.Conn(); conn != nil {
+		return conn.RemoteAddr()
 	}
+	return nil
+}
+
+// Context returns the context of the connection. It carries the values of the
+// context given to NewWithContext, and is canceled once the Client is closed.
+func (c *Client) Context() context.Context { return c.ctx }
+
+// Close tears down the Client. It cancels the context of the connection (see
+// Context), stopping the control stream, the *cmd.Manager and its NetConn,
+// NetStream, and DataStream, and closes the connection if it is an io.Closer,
+// returning any error encountered while doing so. It then stops reading chunks
+// from the connection, waiting for the goroutine doing so to return if the
+// handshake has completed, such that no goroutine started by the Client
+// outlives it, even if it was never handshaked. Subsequent calls return the
+// same error.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.cancel()
+
+		if closer, ok := c.conn.(io.Closer); ok {
+			c.closeErr = closer.Close()
+		}
+
+		c.chunks.Close()
+	})
+
+	return c.closeErr
 }
 
 // Handshake preforms the handshake operation against the connecting client. If
@@ -56,15 +181,23 @@ func New(conn io.ReadWriter) *Client {
 // returned immediately.
 //
 // If no error is encounterd while handshaking, the chunk reading process will
-// begin.
+// begin. Calling Handshake again after a successful handshake is a no-op.
 //
 // See github.com/WatchBeam/RTMP/handshake for details.
 func (c *Client) Handshake() error {
+	if c.handshaken {
+		return nil
+	}
+
+	_, span := tracing.Start(c.ctx, tracing.SpanHandshake)
 	if err := handshake.With(&handshake.Param{
-		Conn: c.Conn,
+		Conn: c.conn,
 	}).Handshake(); err != nil {
+		span.End(err)
 		return err
 	}
+	span.End(nil)
+	c.handshaken = true
 
 	go c.chunks.Recv()
 
@@ -78,3 +211,158 @@ func (c *Client) Controls() *control.Stream { return c.controlStream }
 // Net returns the *cmd.Manager responsible for handling the NetConnection,
 // NetStrema, and DataStream exchanged with this client.
 func (c *Client) Net() *cmd.Manager { return c.cmdManager }
+
+// NetStream returns the NetStream over which commands are exchanged with this
+// client, as returned by Net().NetStream(). It is available as soon as the
+// Client is constructed, but receives nothing until the handshake has completed
+// and the *cmd.Manager is dispatching (see Client).
+func (c *Client) NetStream() *stream.NetStream { return c.cmdManager.NetStream() }
+
+// DataStream returns the DataStream over which audio, video, and script data
+// are exchanged with this client, as returned by Net().DataStream(). Like the
+// NetStream, it receives nothing until the *cmd.Manager is dispatching.
+func (c *Client) DataStream() *data.Stream { return c.cmdManager.DataStream() }
+
+// SendConnectPreamble sends the control sequences that servers conventionally
+// send right after accepting the connect command of the client (see
+// stream.NetStream.AcceptConnect), in order: a WindowAckSize of `windowSize`
+// bytes, a SetPeerBandwidth of `peerBandwidth` bytes with a dynamic limit, and
+// a SetChunkSize of `chunkSize` bytes, which is then applied to the chunks
+// written to the client.
+//
+// If the chunk size is outside of the range permitted by the RTMP
+// specification, chunk.ErrInvalidChunkSize is returned, and nothing is sent.
+// Otherwise, the first error encountered while sending is returned.
+func (c *Client) SendConnectPreamble(windowSize, peerBandwidth, chunkSize uint32) error {
+	if err := chunk.ValidateChunkSize(chunkSize); err != nil {
+		return err
+	}
+
+	if err := c.controlStream.Send(&control.WindowAckSize{
+		WindowAckSize: windowSize,
+	}); err != nil {
+		return err
+	}
+
+	if err := c.controlStream.SendPeerBandwidth(peerBandwidth,
+		control.LimitTypeDynamic); err != nil {
+
+		return err
+	}
+
+	return c.writer.SetChunkSize(chunkSize)
+}
+
+// Errs returns the channel of errors encountered while reading chunks from the
+// connected client.
+func (c *Client) Errs() <-chan error { return c.chunks.Errs() }
+
+// SetIdleTimeout stops reading chunks from the client if none are received for
+// the given amount of time, so that a client which vanishes without closing its
+// connection is noticed. When it fires, chunk.ErrIdleTimeout is returned over
+// the Errs() channel, and the Client should be torn down. A timeout of zero or
+// less disables the idle timeout. See chunk.DefaultReader.SetIdleTimeout for
+// details.
+//
+// If the Client's connection does not support read deadlines (as a net.Conn
+// does), ErrNoDeadlines is returned.
+func (c *Client) SetIdleTimeout(timeout time.Duration) error {
+	conn, ok := c.conn.(chunk.ReadDeadliner)
+	if !ok {
+		return ErrNoDeadlines
+	}
+
+	c.reader.SetIdleTimeout(conn, timeout)
+
+	return nil
+}
+
+// SetMaxMessageSize sets the largest message length, in bytes, accepted from
+// the client, so that a malicious client cannot exhaust memory by declaring
+// huge messages. When a longer message is declared, chunk.ErrMessageTooLarge is
+// returned over the Errs() channel, and the Client should be torn down. A size
+// of zero or less disables the limit. See chunk.DefaultReader.SetMaxMessageSize
+// for details.
+func (c *Client) SetMaxMessageSize(size int) {
+	c.reader.SetMaxMessageSize(size)
+}
+
+// SetWriteTimeout causes writes to the client which take longer than the given
+// amount of time to fail with chunk.ErrWriteTimeout, so that a client which has
+// stopped reading does not block the goroutine writing to it forever. A
+// timeout of zero or less disables the write timeout. See
+// chunk.DefaultWriter.SetWriteTimeout for details.
+//
+// If the Client's connection does not support write deadlines (as a net.Conn
+// does), ErrNoDeadlines is returned.
+func (c *Client) SetWriteTimeout(timeout time.Duration) error {
+	conn, ok := c.conn.(chunk.WriteDeadliner)
+	if !ok {
+		return ErrNoDeadlines
+	}
+
+	c.writer.SetWriteTimeout(conn, timeout)
+
+	return nil
+}
+
+// SetReadDeadline stops reading chunks from the client once the given time
+// passes, for instance to enforce a maximum publishing duration. When it does,
+// the error returned by the connection (such as os.ErrDeadlineExceeded) is
+// returned over the Errs() channel, and the Client should be torn down. The
+// zero time clears the deadline. See chunk.DefaultReader.SetReadDeadline for
+// details.
+//
+// The deadline takes precedence over the idle timeout (see SetIdleTimeout): if
+// both are set, reading stops at whichever passes first, and ErrIdleTimeout is
+// only returned if the idle timeout passed before the deadline.
+//
+// If the Client's connection does not support read deadlines (as a net.Conn
+// does), ErrNoDeadlines is returned.
+func (c *Client) SetReadDeadline(t time.Time) error {
+	conn, ok := c.conn.(chunk.ReadDeadliner)
+	if !ok {
+		return ErrNoDeadlines
+	}
+
+	c.reader.SetReadDeadline(conn, t)
+
+	return nil
+}
+
+// SetWriteDeadline causes writes to the client to fail once the given time
+// passes, with the error returned by the connection (such as
+// os.ErrDeadlineExceeded). The zero time clears the deadline. See
+// chunk.DefaultWriter.SetWriteDeadline for details.
+//
+// The deadline takes precedence over the write timeout (see SetWriteTimeout),
+// which never extends the write deadline of the connection past it.
+//
+// If the Client's connection does not support write deadlines (as a net.Conn
+// does), ErrNoDeadlines is returned.
+func (c *Client) SetWriteDeadline(t time.Time) error {
+	conn, ok := c.conn.(chunk.WriteDeadliner)
+	if !ok {
+		return ErrNoDeadlines
+	}
+
+	c.writer.SetWriteDeadline(conn, t)
+
+	return nil
+}
+
+// SetDeadline sets both the read and write deadlines of the Client (see
+// SetReadDeadline and SetWriteDeadline). If the Client's connection does not
+// support both, ErrNoDeadlines is returned, and neither is set.
+func (c *Client) SetDeadline(t time.Time) error {
+	_, r := c.conn.(chunk.ReadDeadliner)
+	_, w := c.conn.(chunk.WriteDeadliner)
+	if !r || !w {
+		return ErrNoDeadlines
+	}
+
+	c.SetReadDeadline(t)
+	c.SetWriteDeadline(t)
+
+	return nil
+}
@@ -2,10 +2,23 @@ package client_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/WatchBeam/rtmp/chunk"
 	"github.com/WatchBeam/rtmp/client"
+	"github.com/WatchBeam/rtmp/control"
+	"github.com/WatchBeam/rtmp/handshake"
+	"github.com/WatchBeam/rtmp/tracing"
+	"github.com/WatchBeam/rtmp/tracing/tracingtest"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
 )
 
 func TestNewConstructsNewClients(t *testing.T) {
@@ -13,5 +26,261 @@ func TestNewConstructsNewClients(t *testing.T) {
 	c := client.New(b)
 
 	assert.IsType(t, &client.Client{}, c)
-	assert.Equal(t, b, c.Conn)
+	assert.Equal(t, b, c.ReadWriter())
+	assert.Nil(t, c.Conn())
+	assert.Nil(t, c.RemoteAddr())
+}
+
+func TestClientExposesItsNetConn(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	c := client.New(local)
+	defer c.Close()
+
+	assert.Equal(t, local, c.Conn())
+	assert.Equal(t, local, c.ReadWriter())
+	assert.Equal(t, local.RemoteAddr(), c.RemoteAddr())
+}
+
+func TestClientExposesItsSubstreams(t *testing.T) {
+	c := client.New(new(bytes.Buffer))
+
+	assert.NotNil(t, c.NetStream())
+	assert.NotNil(t, c.DataStream())
+	assert.Equal(t, c.Net().NetStream(), c.NetStream())
+	assert.Equal(t, c.Net().DataStream(), c.DataStream())
+}
+
+type contextKey struct{}
+
+func TestNewWithContextDerivesTheContextOfTheClient(t *testing.T) {
+	ctx := context.WithValue(context.Background(), contextKey{}, "foo")
+	c := client.NewWithContext(ctx, new(bytes.Buffer))
+
+	assert.Equal(t, "foo", c.Context().Value(contextKey{}))
+	assert.Nil(t, c.Context().Err())
+}
+
+func TestCloseCancelsTheContextOfTheClient(t *testing.T) {
+	c := client.New(new(bytes.Buffer))
+
+	assert.Nil(t, c.Close())
+	assert.Equal(t, context.Canceled, c.Context().Err())
+}
+
+func TestCloseClosesTheConnection(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	c := client.New(local)
+
+	assert.Nil(t, c.Close())
+	assert.Nil(t, c.Close())
+
+	_, err := local.Write([]byte{0x0})
+	assert.Equal(t, io.ErrClosedPipe, err)
+}
+
+func TestCloseTearsDownEverySubstream(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	peerDone := make(chan struct{})
+	go func() {
+		defer close(peerDone)
+
+		peer, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		defer peer.Close()
+
+		if err := handshake.Dial(peer); err != nil {
+			return
+		}
+		io.Copy(ioutil.Discard, peer)
+	}()
+
+	conn, err := l.Accept()
+	assert.Nil(t, err)
+	l.Close()
+
+	c := client.New(conn)
+	assert.Nil(t, c.Handshake())
+
+	go c.Controls().Recv()
+	go c.Net().Dispatch(true)
+
+	assert.Nil(t, c.Close())
+	assert.Nil(t, c.Close())
+
+	<-peerDone
+}
+
+func TestCloseTearsDownClientsWhichWereNeverHandshaked(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	c := client.New(local)
+
+	assert.Nil(t, c.Close())
+	assert.NotNil(t, c.Handshake())
+}
+
+func TestHandshakeRecordsAFailedHandshakeSpan(t *testing.T) {
+	r := tracingtest.NewRecorder()
+	ctx := tracing.NewContext(context.Background(), r)
+
+	c := client.NewWithContext(ctx, new(bytes.Buffer))
+	assert.NotNil(t, c.Handshake())
+
+	span := r.Wait(tracing.SpanHandshake, time.Second)
+	if assert.NotNil(t, span) {
+		_, err := span.Ended()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestSetIdleTimeoutRequiresDeadlines(t *testing.T) {
+	c := client.New(new(bytes.Buffer))
+
+	assert.Equal(t, client.ErrNoDeadlines, c.SetIdleTimeout(time.Second))
+}
+
+func TestSetIdleTimeoutTearsDownIdleClients(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	go func() {
+		peer, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+
+		handshake.Dial(peer)
+	}()
+
+	conn, err := l.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	c := client.New(conn)
+	assert.Nil(t, c.SetIdleTimeout(10*time.Millisecond))
+	assert.Nil(t, c.Handshake())
+
+	assert.Equal(t, chunk.ErrIdleTimeout, <-c.Errs())
+}
+
+func TestSetWriteTimeoutRequiresDeadlines(t *testing.T) {
+	c := client.New(new(bytes.Buffer))
+
+	assert.Equal(t, client.ErrNoDeadlines, c.SetWriteTimeout(time.Second))
+}
+
+func TestSetDeadlinesRequireDeadlines(t *testing.T) {
+	c := client.New(new(bytes.Buffer))
+
+	assert.Equal(t, client.ErrNoDeadlines, c.SetReadDeadline(time.Now()))
+	assert.Equal(t, client.ErrNoDeadlines, c.SetWriteDeadline(time.Now()))
+	assert.Equal(t, client.ErrNoDeadlines, c.SetDeadline(time.Now()))
+}
+
+func TestSetReadDeadlineTearsDownClientsOnceItPasses(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	go func() {
+		peer, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+
+		handshake.Dial(peer)
+	}()
+
+	conn, err := l.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	c := client.New(conn)
+	assert.Nil(t, c.SetIdleTimeout(time.Second))
+	assert.Nil(t, c.Handshake())
+	assert.Nil(t, c.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+
+	err = <-c.Errs()
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded), "%v", err)
+}
+
+func TestSetDeadlineFailsWritesOverAPipe(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	c := client.New(local)
+	defer c.Close()
+
+	assert.Nil(t, c.SetWriteTimeout(time.Second))
+	assert.Nil(t, c.SetDeadline(time.Now().Add(10*time.Millisecond)))
+
+	err := c.Controls().Send(&control.WindowAckSize{WindowAckSize: 1})
+
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded), "%v", err)
+}
+
+func TestSendConnectPreambleSendsControlSequencesInOrder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := client.New(buf)
+
+	assert.Nil(t, c.SendConnectPreamble(2500000, 2500000, 4096))
+
+	expected := new(bytes.Buffer)
+	w := chunk.NewWriter(expected, chunk.DefaultReadSize)
+	chunker := control.NewChunker()
+	for _, ctrl := range []control.Control{
+		&control.WindowAckSize{WindowAckSize: 2500000},
+		&control.SetPeerBandwidth{
+			AckWindowSize: 2500000,
+			LimitType:     control.LimitTypeDynamic,
+		},
+	} {
+		ch, _ := chunker.Chunk(ctrl)
+		w.Write(ch)
+	}
+	w.Write(chunk.NewSetChunkSize(4096))
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestSendConnectPreambleAppliesTheChunkSize(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := client.New(buf)
+
+	assert.Nil(t, c.SendConnectPreamble(2500000, 2500000, 256))
+	buf.Reset()
+
+	assert.Nil(t, c.WriteMedia(1, &chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: 0x09},
+		},
+		Data: make([]byte, 300),
+	}))
+
+	// The message is split after 256 bytes, and the rest is preceded by
+	// the one byte basic header of a continuation chunk.
+	assert.Equal(t, 12+256+1+44, buf.Len())
+}
+
+func TestSendConnectPreambleRejectsInvalidChunkSizes(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := client.New(buf)
+
+	assert.Equal(t, chunk.ErrInvalidChunkSize,
+		c.SendConnectPreamble(2500000, 2500000, 0))
+	assert.Empty(t, buf.Bytes())
 }
@@ -0,0 +1,252 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/handshake"
+)
+
+const (
+	// DefaultPort is the port dialed when an rtmp:// URL does not specify
+	// one.
+	DefaultPort = "1935"
+
+	// FlashVer is the flashVer property sent in the connect command.
+	FlashVer = "FMLE/3.0 (compatible; FMSc/1.0)"
+
+	// commandTypeId is the message type ID of AMF0 commands.
+	commandTypeId byte = 0x14
+	// connectTransactionId is the transaction ID of the connect command,
+	// which is always the first command sent.
+	connectTransactionId float64 = 1
+)
+
+// ConnectError is returned when the server responds to the connect command
+// with an "_error".
+type ConnectError struct {
+	// Code is the status code sent by the server, such as
+	// "NetConnection.Connect.Rejected".
+	Code string
+	// Description is the description sent by the server, if any.
+	Description string
+}
+
+var _ error = new(ConnectError)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("rtmp/client: connect failed: %v (%v)",
+		e.Code, e.Description)
+}
+
+// DialOption configures the connection originated by Dial or DialContext.
+type DialOption func(*dialOptions)
+
+// dialOptions holds the values configured by DialOptions.
+type dialOptions struct {
+	// noDelay determines whether Nagle's algorithm is disabled.
+	noDelay bool
+}
+
+// NoDelay determines whether Nagle's algorithm is disabled on the connection
+// (see net.TCPConn.SetNoDelay). It is disabled (`noDelay` is true) by default,
+// since RTMP already batches data into chunks, and delaying small chunks only
+// adds latency. Enabling it trades that latency for fewer, larger packets.
+func NoDelay(noDelay bool) DialOption {
+	return func(o *dialOptions) {
+		o.noDelay = noDelay
+	}
+}
+
+// Dial originates a connection to the RTMP server at the given
+// rtmp://host[:port]/app[/instance][/streamKey] URL. See DialContext for
+// details.
+func Dial(rawurl string, opts ...DialOption) (*Client, error) {
+	return DialContext(context.Background(), rawurl, opts...)
+}
+
+// DialContext originates a connection to the RTMP server at the given
+// rtmp://host[:port]/app[/instance][/streamKey] URL (see ParseURL). It connects
+// over TCP, preforms the client side of the handshake, and sends the connect
+// command for the URL's app, returning
+// once the server has accepted it. If the given context is done before then,
+// the connection is closed and the context's error is returned.
+//
+// The context of the returned Client is derived from the given context (see
+// NewWithContext), so the Client stops once it is done.
+//
+// The returned Client has already been handshaken, and its control stream is
+// already receiving, so neither Handshake nor Controls().Recv should be called.
+// Any control messages received before the connect command was accepted are
+// discarded.
+//
+// The connection is configured by the given DialOptions.
+func DialContext(ctx context.Context, rawurl string, opts ...DialOption) (*Client, error) {
+	u, err := ParseURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &dialOptions{noDelay: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", u.Addr())
+	if err != nil {
+		return nil, err
+	}
+
+	if tc, ok := conn.(*net.TCPConn); ok {
+		if err := tc.SetNoDelay(o.noDelay); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	c, err := connect(ctx, conn, u)
+
+	close(done)
+	<-exited
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+
+	if err != nil {
+		if c != nil {
+			c.Close()
+		} else {
+			conn.Close()
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// connect preforms the handshake over the given connection, and then sends
+// and awaits the response to the connect command. Once the handshake has
+// succeeded, the Client is returned even if sending the connect command
+// fails, so that the caller may close it.
+func connect(ctx context.Context, conn net.Conn, u *URL) (*Client, error) {
+	if err := handshake.Dial(conn); err != nil {
+		return nil, err
+	}
+
+	c := NewWithContext(ctx, conn)
+	c.handshaken = true
+
+	go c.chunks.Recv()
+	go c.controlStream.Recv()
+
+	// The server assumes the default chunk size until told otherwise.
+	if err := c.writer.SetChunkSize(uint32(c.writer.WriteSize())); err != nil {
+		return c, err
+	}
+
+	if err := c.sendConnect(u); err != nil {
+		return c, err
+	}
+
+	if err := c.await(connectResult); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// connectProperties is the command object of the connect command, whose
+// properties are sent in the order of its fields.
+type connectProperties struct {
+	App      string `amf0:"app"`
+	Type     string `amf0:"type"`
+	FlashVer string `amf0:"flashVer"`
+	TcURL    string `amf0:"tcUrl"`
+}
+
+// sendConnect sends the connect command for the app in the given URL.
+func (c *Client) sendConnect(u *URL) error {
+	return c.sendCommand(chunk.BasicHeader{0, 3}, 0,
+		"connect", connectTransactionId, &connectProperties{
+			App:      u.App,
+			Type:     "nonprivate",
+			FlashVer: FlashVer,
+			TcURL:    u.TcURL(),
+		})
+}
+
+// await waits for the server's response to a command, as identified by
+// `match`, which returns whether or not the given chunk is the response, and if
+// so, an error if the command was rejected. await returns that error, or an
+// error if the connection failed first.
+func (c *Client) await(match func(*chunk.Chunk) (bool, error)) error {
+	for {
+		select {
+		case ch, ok := <-c.netChunks.In():
+			if !ok {
+				return io.EOF
+			}
+
+			if done, err := match(ch); done {
+				return err
+			}
+		case <-c.controlStream.In():
+		case err := <-c.controlStream.Errs():
+			return err
+		case err := <-c.chunks.Errs():
+			return err
+		}
+	}
+}
+
+// connectResult returns whether or not the given chunk is the response to the
+// connect command, and if so, a *ConnectError if it was rejected.
+func connectResult(c *chunk.Chunk) (bool, error) {
+	name, dec, ok := decodeResponse(c, connectTransactionId)
+	if !ok {
+		return false, nil
+	}
+
+	switch name {
+	case "_result":
+		return true, nil
+	case "_error":
+		// Skip the properties, which are usually null.
+		skip(dec)
+
+		info := decodeInfo(dec)
+
+		return true, &ConnectError{
+			Code:        stringProperty(info, "code"),
+			Description: stringProperty(info, "description"),
+		}
+	}
+
+	return false, nil
+}
+
+// stringProperty returns the string value of the given key in o, or an empty
+// string if it is missing or not a string.
+func stringProperty(o map[string]interface{}, key string) string {
+	s, _ := o[key].(string)
+	return s
+}
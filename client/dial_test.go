@@ -0,0 +1,162 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amf "github.com/WatchBeam/rtmp/amf0"
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/client"
+	"github.com/WatchBeam/rtmp/handshake"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+// newServer listens for a single connection, preforms the handshake, and
+// passes the first chunk that it receives to respond, closing the connection
+// once it returns.
+func newServer(t *testing.T, respond func(w chunk.Writer, c *chunk.Chunk)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	go func() {
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if err := handshake.Accept(conn); err != nil {
+			return
+		}
+
+		r := chunk.NewReader(conn, chunk.DefaultReadSize, chunk.NewNormalizer())
+		go r.Recv()
+		defer r.Close()
+
+		respond(chunk.NewWriter(conn, chunk.DefaultReadSize), <-r.Chunks())
+	}()
+
+	return "rtmp://" + l.Addr().String() + "/live"
+}
+
+func writeCommand(w chunk.Writer, name string, info map[string]interface{}) {
+	respond(w, name, 1, map[string]interface{}{}, info)
+}
+
+func TestDialConnectsToServers(t *testing.T) {
+	connects := make(chan *chunk.Chunk, 1)
+	url := newServer(t, func(w chunk.Writer, c *chunk.Chunk) {
+		connects <- c
+
+		// Window Acknowledgement Size
+		w.Write(&chunk.Chunk{
+			Header: &chunk.Header{
+				BasicHeader: chunk.BasicHeader{0, 2},
+				MessageHeader: chunk.MessageHeader{
+					Length: 4,
+					TypeId: 0x05,
+				},
+			},
+			Data: []byte{0x00, 0x26, 0x25, 0xa0},
+		})
+		writeCommand(w, "_result", map[string]interface{}{})
+	})
+
+	c, err := client.Dial(url)
+
+	assert.Nil(t, err)
+	assert.IsType(t, new(client.Client), c)
+	assert.Nil(t, c.Handshake())
+
+	connect := <-connects
+	assert.Equal(t, byte(0x14), connect.Header.MessageHeader.TypeId)
+
+	dec := amf.NewDecoder(bytes.NewReader(connect.Data))
+
+	var name string
+	var id float64
+	var props map[string]interface{}
+	assert.Nil(t, dec.Decode(&name))
+	assert.Nil(t, dec.Decode(&id))
+	assert.Nil(t, dec.Decode(&props))
+
+	assert.Equal(t, "connect", name)
+	assert.Equal(t, float64(1), id)
+	assert.Equal(t, "live", props["app"])
+	assert.Equal(t, url, props["tcUrl"])
+}
+
+func TestDialReturnsRejectedConnects(t *testing.T) {
+	url := newServer(t, func(w chunk.Writer, c *chunk.Chunk) {
+		writeCommand(w, "_error", map[string]interface{}{
+			"code":        "NetConnection.Connect.Rejected",
+			"description": "bad app",
+		})
+	})
+
+	c, err := client.Dial(url)
+
+	assert.Nil(t, c)
+	assert.Equal(t, &client.ConnectError{
+		Code:        "NetConnection.Connect.Rejected",
+		Description: "bad app",
+	}, err)
+}
+
+func TestDialContextHonorsCancellation(t *testing.T) {
+	url := newServer(t, func(w chunk.Writer, c *chunk.Chunk) {
+		time.Sleep(time.Second)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	c, err := client.DialContext(ctx, url)
+
+	assert.Nil(t, c)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+// cancelledContext is a context.Context which is never done, but whose Err
+// reports that it was cancelled once `cancelled` is set.
+type cancelledContext struct {
+	context.Context
+
+	cancelled int32
+}
+
+func (c *cancelledContext) Err() error {
+	if atomic.LoadInt32(&c.cancelled) != 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestDialContextClosesClientsWhichConnectedAfterCancellation(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ctx := &cancelledContext{Context: context.Background()}
+	url := newServer(t, func(w chunk.Writer, c *chunk.Chunk) {
+		atomic.StoreInt32(&ctx.cancelled, 1)
+		writeCommand(w, "_result", map[string]interface{}{})
+	})
+
+	c, err := client.DialContext(ctx, url)
+
+	assert.Nil(t, c)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestDialRejectsOtherSchemes(t *testing.T) {
+	c, err := client.Dial("http://127.0.0.1/live")
+
+	assert.Nil(t, c)
+	assert.Equal(t, client.ErrUnsupportedScheme, err)
+}
@@ -0,0 +1,239 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+
+	amf "github.com/WatchBeam/rtmp/amf0"
+	"github.com/WatchBeam/rtmp/chunk"
+)
+
+const (
+	// PublishStartCode is the status code sent by the server once it has
+	// accepted a publish command.
+	PublishStartCode = "NetStream.Publish.Start"
+
+	// publishType is the type of stream requested by the publish command.
+	publishType = "live"
+	// createStreamTransactionId is the transaction ID of the createStream
+	// command.
+	createStreamTransactionId float64 = 2
+	// publishTransactionId is the transaction ID of the publish command.
+	publishTransactionId float64 = 3
+	// publishChunkStreamId is the chunk stream ID that the publish command,
+	// and the media published along with it, are sent over.
+	publishChunkStreamId uint32 = 4
+)
+
+// PublishError is returned when the server responds to the publish command with
+// a status other than "NetStream.Publish.Start", or responds to the
+// createStream command preceding it with an "_error".
+type PublishError struct {
+	// Code is the status code sent by the server, such as
+	// "NetStream.Publish.BadName".
+	Code string
+	// Description is the description sent by the server, if any.
+	Description string
+}
+
+var _ error = new(PublishError)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("rtmp/client: publish failed: %v (%v)",
+		e.Code, e.Description)
+}
+
+// Publish asks the server to create a message stream, and to publish the live
+// stream with the given name (usually the stream key) over it, returning the ID
+// of that message stream once the server has responded with the
+// "NetStream.Publish.Start" status. If the server rejects either request, a
+// *PublishError is returned.
+//
+// Media frames published afterwards should be sent over the returned message
+// stream (see WriteMedia). Publish must only be called on a Client returned by
+// Dial or DialContext, and not concurrently with any other call.
+func (c *Client) Publish(name string) (uint32, error) {
+	if err := c.sendCommand(chunk.BasicHeader{0, 3}, 0,
+		"createStream", createStreamTransactionId, nil); err != nil {
+		return 0, err
+	}
+
+	var streamId uint32
+	if err := c.await(func(ch *chunk.Chunk) (bool, error) {
+		id, done, err := createStreamResult(ch)
+		streamId = id
+
+		return done, err
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := c.sendCommand(chunk.BasicHeader{0, publishChunkStreamId}, streamId,
+		"publish", publishTransactionId, nil, name, publishType); err != nil {
+		return 0, err
+	}
+
+	if err := c.await(publishStatus); err != nil {
+		return 0, err
+	}
+
+	return streamId, nil
+}
+
+// WriteMedia writes the given chunk, which carries a frame of audio, video, or
+// script data, over the message stream with the given ID (see Publish). The
+// header of the chunk is copied, rather than modified.
+func (c *Client) WriteMedia(streamId uint32, ch *chunk.Chunk) error {
+	var header chunk.Header
+	if ch.Header != nil {
+		header = *ch.Header
+	}
+	header.BasicHeader.StreamId = publishChunkStreamId
+	header.MessageHeader.StreamId = streamId
+	header.MessageHeader.Length = uint32(len(ch.Data))
+
+	return c.writer.Write(&chunk.Chunk{
+		Header: &header,
+		Data:   ch.Data,
+	})
+}
+
+// sendCommand marshals the given values of a command (its name, transaction ID,
+// and arguments) in turn, and writes them over the given chunk stream and
+// message stream.
+func (c *Client) sendCommand(bh chunk.BasicHeader, streamId uint32,
+	values ...interface{}) error {
+
+	buf := new(bytes.Buffer)
+	enc := amf.NewEncoder(buf)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	body := buf.Bytes()
+
+	return c.writer.Write(&chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader: bh,
+			MessageHeader: chunk.MessageHeader{
+				Length:   uint32(len(body)),
+				TypeId:   commandTypeId,
+				StreamId: streamId,
+			},
+		},
+		Data: body,
+	})
+}
+
+// createStreamResult returns whether or not the given chunk is the response to
+// the createStream command, and if so, the ID of the created message stream, or
+// a *PublishError if it was rejected.
+func createStreamResult(c *chunk.Chunk) (uint32, bool, error) {
+	name, dec, ok := decodeResponse(c, createStreamTransactionId)
+	if !ok {
+		return 0, false, nil
+	}
+
+	// Skip the properties, which are usually null.
+	skip(dec)
+
+	switch name {
+	case "_result":
+		var id interface{}
+		if err := dec.Decode(&id); err == nil {
+			if n, ok := id.(float64); ok {
+				return uint32(n), true, nil
+			}
+		}
+
+		return 0, true, &PublishError{
+			Code:        "createStream",
+			Description: "missing stream ID",
+		}
+	case "_error":
+		return 0, true, infoError(dec)
+	}
+
+	return 0, false, nil
+}
+
+// publishStatus returns whether or not the given chunk is the onStatus response
+// to the publish command, and if so, a *PublishError if it was rejected.
+func publishStatus(c *chunk.Chunk) (bool, error) {
+	if c.Header.MessageHeader.TypeId != commandTypeId {
+		return false, nil
+	}
+
+	dec := amf.NewDecoder(bytes.NewReader(c.Data))
+
+	var name string
+	if err := dec.Decode(&name); err != nil || name != "onStatus" {
+		return false, nil
+	}
+
+	// Skip the transaction ID, and the properties, which are usually zero
+	// and null, respectively.
+	skip(dec)
+	skip(dec)
+
+	err := infoError(dec)
+	if err.Code == PublishStartCode {
+		return true, nil
+	}
+
+	return true, err
+}
+
+// decodeResponse decodes the name of the given command chunk, returning it along
+// with a decoder positioned after the transaction ID, if the transaction ID is
+// `txnId`. Otherwise, false is returned.
+func decodeResponse(c *chunk.Chunk, txnId float64) (string, *amf.Decoder, bool) {
+	if c.Header.MessageHeader.TypeId != commandTypeId {
+		return "", nil, false
+	}
+
+	dec := amf.NewDecoder(bytes.NewReader(c.Data))
+
+	var name string
+	if err := dec.Decode(&name); err != nil {
+		return "", nil, false
+	}
+
+	var id float64
+	if err := dec.Decode(&id); err != nil || id != txnId {
+		return "", nil, false
+	}
+
+	return name, dec, true
+}
+
+// skip decodes and discards the next value read by dec, if any.
+func skip(dec *amf.Decoder) {
+	var v interface{}
+	dec.Decode(&v)
+}
+
+// decodeInfo decodes the information object read by dec. If it is missing, or
+// is not an object, nil is returned.
+func decodeInfo(dec *amf.Decoder) map[string]interface{} {
+	var info interface{}
+	if err := dec.Decode(&info); err != nil {
+		return nil
+	}
+
+	o, _ := info.(map[string]interface{})
+	return o
+}
+
+// infoError decodes the information object read by dec into a *PublishError.
+// If it is missing, the returned error is empty.
+func infoError(dec *amf.Decoder) *PublishError {
+	info := decodeInfo(dec)
+
+	return &PublishError{
+		Code:        stringProperty(info, "code"),
+		Description: stringProperty(info, "description"),
+	}
+}
@@ -0,0 +1,187 @@
+package client_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	amf "github.com/WatchBeam/rtmp/amf0"
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/client"
+	"github.com/WatchBeam/rtmp/handshake"
+	"github.com/stretchr/testify/assert"
+)
+
+// publishServer is a fake RTMP server which accepts the connect, createStream,
+// and publish commands (unless it is told to reject the latter), and passes
+// along each media chunk it receives.
+type publishServer struct {
+	l net.Listener
+
+	// badName is the stream name rejected by the server, if any.
+	badName string
+
+	// conns receives each accepted connection.
+	conns chan net.Conn
+	// media receives each media chunk received.
+	media chan *chunk.Chunk
+}
+
+// newPublishServer starts a new *publishServer, accepting connections until the
+// test is over.
+func newPublishServer(t *testing.T) *publishServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	s := &publishServer{
+		l:     l,
+		conns: make(chan net.Conn, 8),
+		media: make(chan *chunk.Chunk, 64),
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.conns <- conn
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+// URL returns the URL of the server, with the given stream key.
+func (s *publishServer) URL(key string) string {
+	return "rtmp://" + s.l.Addr().String() + "/live/" + key
+}
+
+// serve responds to the commands received over the given connection, until
+// reading from it fails.
+func (s *publishServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	if err := handshake.Accept(conn); err != nil {
+		return
+	}
+
+	r := chunk.NewReader(conn, chunk.DefaultReadSize, chunk.NewNormalizer())
+	go r.Recv()
+	defer r.Close()
+	w := chunk.NewWriter(conn, chunk.DefaultReadSize)
+
+	for {
+		var c *chunk.Chunk
+		select {
+		case c = <-r.Chunks():
+		case <-r.Errs():
+			return
+		}
+
+		switch c.Header.MessageHeader.TypeId {
+		case 0x08, 0x09:
+			s.media <- c
+		case 0x14:
+			dec := amf.NewDecoder(bytes.NewReader(c.Data))
+
+			var name, arg string
+			var txn float64
+			var props interface{}
+			dec.Decode(&name)
+			dec.Decode(&txn)
+			dec.Decode(&props)
+			dec.Decode(&arg)
+
+			switch name {
+			case "connect":
+				respond(w, "_result", txn, map[string]interface{}{},
+					map[string]interface{}{})
+			case "createStream":
+				respond(w, "_result", txn, nil, 1)
+			case "publish":
+				info := map[string]interface{}{"code": client.PublishStartCode}
+				if arg == s.badName {
+					info = map[string]interface{}{
+						"code":        "NetStream.Publish.BadName",
+						"description": "bad name",
+					}
+				}
+
+				respond(w, "onStatus", 0, nil, info)
+			}
+		}
+	}
+}
+
+func respond(w chunk.Writer, vs ...interface{}) {
+	buf := new(bytes.Buffer)
+	enc := amf.NewEncoder(buf)
+	for _, v := range vs {
+		enc.Encode(v)
+	}
+
+	w.Write(&chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader: chunk.BasicHeader{0, 3},
+			MessageHeader: chunk.MessageHeader{
+				Length: uint32(buf.Len()),
+				TypeId: 0x14,
+			},
+		},
+		Data: buf.Bytes(),
+	})
+}
+
+func TestPublishReturnsTheCreatedStreamId(t *testing.T) {
+	s := newPublishServer(t)
+
+	c, err := client.Dial(s.URL("foo"))
+	assert.Nil(t, err)
+	defer c.Close()
+
+	id, err := c.Publish("foo")
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, id)
+}
+
+func TestPublishReturnsRejectedPublishes(t *testing.T) {
+	s := newPublishServer(t)
+	s.badName = "foo"
+
+	c, err := client.Dial(s.URL("foo"))
+	assert.Nil(t, err)
+	defer c.Close()
+
+	_, err = c.Publish("foo")
+
+	assert.Equal(t, &client.PublishError{
+		Code:        "NetStream.Publish.BadName",
+		Description: "bad name",
+	}, err)
+}
+
+func TestWriteMediaWritesOverThePublishedStream(t *testing.T) {
+	s := newPublishServer(t)
+
+	c, err := client.Dial(s.URL("foo"))
+	assert.Nil(t, err)
+	defer c.Close()
+
+	id, err := c.Publish("foo")
+	assert.Nil(t, err)
+
+	assert.Nil(t, c.WriteMedia(id, &chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: 0x09},
+		},
+		Data: []byte{0x17, 0x01},
+	}))
+
+	media := <-s.media
+	assert.Equal(t, id, media.Header.MessageHeader.StreamId)
+	assert.Equal(t, []byte{0x17, 0x01}, media.Data)
+}
@@ -0,0 +1,376 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WatchBeam/rtmp/cmd/data"
+)
+
+var (
+	// ErrMissingStreamKey is returned by NewResilientClient when the given
+	// URL has no stream key to publish.
+	ErrMissingStreamKey = errors.New("rtmp/client: URL has no stream key to publish")
+
+	// errConnectionDropped is the cause of reconnecting when the current
+	// connection was dropped before the frame was written.
+	errConnectionDropped = errors.New("rtmp/client: connection dropped")
+)
+
+// Backoff configures the delay between the attempts of a ResilientClient to
+// reconnect, which grows exponentially from Initial, by a factor of Multiplier,
+// up to Max.
+type Backoff struct {
+	// Initial is the delay before the first attempt to reconnect.
+	Initial time.Duration
+	// Max is the greatest delay between two attempts to reconnect.
+	Max time.Duration
+	// Multiplier is the factor by which the delay grows after each failed
+	// attempt. Values less than one are treated as one.
+	Multiplier float64
+	// MaxRetries is the number of consecutive failed attempts to reconnect
+	// after which the ResilientClient gives up. If it is zero or less, it
+	// never gives up.
+	MaxRetries int
+}
+
+var (
+	// DefaultBackoff is the Backoff used by a ResilientClient unless
+	// configured otherwise (see ReconnectBackoff).
+	DefaultBackoff = Backoff{
+		Initial:    500 * time.Millisecond,
+		Max:        30 * time.Second,
+		Multiplier: 2,
+		MaxRetries: 10,
+	}
+)
+
+// Delay returns the delay before the given attempt to reconnect, where the
+// first attempt is 1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	m := b.Multiplier
+	if m < 1 {
+		m = 1
+	}
+
+	d := float64(b.Initial)
+	for i := 1; i < attempt && d < float64(b.Max); i++ {
+		d *= m
+	}
+
+	if d > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
+
+// ReconnectError is returned by a ResilientClient once it has given up
+// reconnecting, after the maximum number of retries (see Backoff.MaxRetries).
+type ReconnectError struct {
+	// Retries is the number of attempts made to reconnect.
+	Retries int
+	// Err is the error encountered by the last attempt.
+	Err error
+}
+
+var _ error = new(ReconnectError)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e *ReconnectError) Error() string {
+	return fmt.Sprintf("rtmp/client: gave up reconnecting after %d retries: %v",
+		e.Retries, e.Err)
+}
+
+// Unwrap returns the error encountered by the last attempt to reconnect.
+func (e *ReconnectError) Unwrap() error { return e.Err }
+
+// ResilientOption configures a ResilientClient constructed by
+// NewResilientClient.
+type ResilientOption func(*resilientOptions)
+
+// resilientOptions holds the values configured by ResilientOptions.
+type resilientOptions struct {
+	// backoff is the Backoff between attempts to reconnect.
+	backoff Backoff
+	// onReconnect is called with each new Client, if non-nil.
+	onReconnect func(*Client)
+	// dialOpts are the DialOptions used to originate each connection.
+	dialOpts []DialOption
+}
+
+// ReconnectBackoff sets the Backoff between the attempts of a ResilientClient to
+// reconnect. It defaults to DefaultBackoff.
+func ReconnectBackoff(b Backoff) ResilientOption {
+	return func(o *resilientOptions) {
+		o.backoff = b
+	}
+}
+
+// OnReconnect sets a function called with the new Client each time that a
+// ResilientClient has successfully reconnected, and published its stream
+// again. It is called from the goroutine calling Write.
+func OnReconnect(fn func(*Client)) ResilientOption {
+	return func(o *resilientOptions) {
+		o.onReconnect = fn
+	}
+}
+
+// ReconnectDialOptions sets the DialOptions used to originate each connection
+// of a ResilientClient.
+func ReconnectDialOptions(opts ...DialOption) ResilientOption {
+	return func(o *resilientOptions) {
+		o.dialOpts = opts
+	}
+}
+
+// ResilientClient publishes a stream to an RTMP server (such as the ingest of a
+// CDN), and reconnects with exponential backoff when the connection is
+// dropped, sending the connect and publish commands again, and resuming from
+// the next frame written. Frames written while disconnected are dropped.
+//
+// It is safe for concurrent use.
+type ResilientClient struct {
+	// url is the URL of the server, and the stream published to it.
+	url string
+	// streamKey is the name of the stream published.
+	streamKey string
+	// o holds the configured ResilientOptions.
+	o *resilientOptions
+
+	// ctx bounds all connections, and is canceled by cancel when the
+	// ResilientClient is closed.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards client, streamId, dropped and err.
+	mu sync.Mutex
+	// client is the current connection.
+	client *Client
+	// streamId is the ID of the message stream that the current connection
+	// publishes over.
+	streamId uint32
+	// dropped is closed once the current connection has been dropped.
+	dropped chan struct{}
+	// err is the terminal error, once the ResilientClient has given up.
+	err error
+
+	// reconnects is the number of successful reconnections. It must be
+	// accessed atomically.
+	reconnects uint64
+}
+
+// NewResilientClient connects to the RTMP server at the given
+// rtmp://host[:port]/app[/instance]/streamKey URL (see DialContext), and
+// publishes the stream named by its stream key (see Client.Publish). If either
+// fails, the error is returned immediately, without retrying. Once connected,
+// subsequent disconnections are retried (see ResilientClient).
+//
+// The given context bounds the lifetime of the ResilientClient: once it is
+// done, the connection is closed, and Write returns its error.
+func NewResilientClient(ctx context.Context, rawurl string,
+	opts ...ResilientOption) (*ResilientClient, error) {
+
+	u, err := ParseURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.StreamKey == "" {
+		return nil, ErrMissingStreamKey
+	}
+
+	o := &resilientOptions{backoff: DefaultBackoff}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	r := &ResilientClient{
+		url:       rawurl,
+		streamKey: u.StreamKey,
+		o:         o,
+
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	if err := r.connect(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Write writes the given frame of data over the current connection. If the
+// connection has been dropped, the frame is dropped, and Write reconnects
+// before returning, such that the next frame is written over the new
+// connection.
+//
+// Write returns a *ReconnectError once the maximum number of attempts to
+// reconnect (see Backoff) have failed in a row, or the error of the context
+// once it is done, and returns that same error from then on.
+func (r *ResilientClient) Write(d data.Data) error {
+	c, err := d.Marshal()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		return r.err
+	}
+
+	select {
+	case <-r.dropped:
+		return r.reconnect(errConnectionDropped)
+	default:
+	}
+
+	if err := r.client.WriteMedia(r.streamId, c); err != nil {
+		return r.reconnect(err)
+	}
+
+	return nil
+}
+
+// Client returns the current connection. It is replaced each time that the
+// ResilientClient reconnects.
+func (r *ResilientClient) Client() *Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.client
+}
+
+// Reconnects returns the number of times that the ResilientClient has
+// successfully reconnected.
+func (r *ResilientClient) Reconnects() uint64 {
+	return atomic.LoadUint64(&r.reconnects)
+}
+
+// Close closes the current connection, and stops the ResilientClient from
+// reconnecting. Subsequent calls to Write return context.Canceled.
+func (r *ResilientClient) Close() error {
+	r.cancel()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Once it has given up, the current connection is already closed.
+	if r.err != nil {
+		return nil
+	}
+	r.err = context.Canceled
+
+	return r.client.Close()
+}
+
+// reconnect closes the current connection, and attempts to connect again
+// according to the Backoff, returning nil once connected, or the terminal error
+// otherwise. `cause` is the error which dropped the connection. The caller
+// must hold mu.
+func (r *ResilientClient) reconnect(cause error) error {
+	r.client.Close()
+
+	b := r.o.backoff
+	for attempt := 1; b.MaxRetries <= 0 || attempt <= b.MaxRetries; attempt++ {
+		t := time.NewTimer(b.Delay(attempt))
+
+		select {
+		case <-t.C:
+		case <-r.ctx.Done():
+			t.Stop()
+
+			r.err = r.ctx.Err()
+			return r.err
+		}
+
+		if cause = r.connect(); cause == nil {
+			atomic.AddUint64(&r.reconnects, 1)
+			if r.o.onReconnect != nil {
+				r.o.onReconnect(r.client)
+			}
+
+			return nil
+		}
+	}
+
+	r.err = &ReconnectError{Retries: b.MaxRetries, Err: cause}
+	return r.err
+}
+
+// connect dials the server, and publishes the stream, replacing the current
+// connection once done. The caller must hold mu, if the ResilientClient has
+// been returned by NewResilientClient.
+func (r *ResilientClient) connect() error {
+	c, err := DialContext(r.ctx, r.url, r.o.dialOpts...)
+	if err != nil {
+		return err
+	}
+
+	// Closing the connection once the context is done also interrupts the
+	// publish command. Once the connection is dropped, there is nothing
+	// left to close.
+	dropped := make(chan struct{})
+	go func() {
+		select {
+		case <-r.ctx.Done():
+			c.Close()
+		case <-dropped:
+		}
+	}()
+
+	streamId, err := c.Publish(r.streamKey)
+
+	if ctxErr := r.ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+
+	if err != nil {
+		c.Close()
+		close(dropped)
+		return err
+	}
+
+	go watch(c, dropped)
+
+	r.client, r.streamId, r.dropped = c, streamId, dropped
+
+	return nil
+}
+
+// watch discards the messages received over the given connection, which the
+// ResilientClient has no use for, and closes `dropped` once reading from it
+// fails.
+func watch(c *Client, dropped chan struct{}) {
+	defer close(dropped)
+
+	for {
+		select {
+		case _, ok := <-c.netChunks.In():
+			if !ok {
+				return
+			}
+		case _, ok := <-c.controlStream.In():
+			if !ok {
+				return
+			}
+		case <-c.controlStream.Errs():
+		case <-c.Errs():
+			return
+		case <-c.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/client"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+// testBackoff retries quickly, so that tests do not wait on it.
+var testBackoff = client.Backoff{
+	Initial:    time.Millisecond,
+	Max:        10 * time.Millisecond,
+	Multiplier: 2,
+	MaxRetries: 3,
+}
+
+func newFrame() data.Data {
+	v := new(data.Video)
+	v.Read(&chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: data.VideoTypeId},
+		},
+		Data: []byte{0x17, 0x01},
+	})
+
+	return v
+}
+
+func TestBackoffGrowsExponentiallyUpToMax(t *testing.T) {
+	b := client.Backoff{
+		Initial:    time.Second,
+		Max:        5 * time.Second,
+		Multiplier: 2,
+	}
+
+	assert.Equal(t, time.Second, b.Delay(1))
+	assert.Equal(t, 2*time.Second, b.Delay(2))
+	assert.Equal(t, 4*time.Second, b.Delay(3))
+	assert.Equal(t, 5*time.Second, b.Delay(4))
+	assert.Equal(t, 5*time.Second, b.Delay(100))
+}
+
+func TestNewResilientClientRequiresAStreamKey(t *testing.T) {
+	r, err := client.NewResilientClient(context.Background(),
+		"rtmp://127.0.0.1/live")
+
+	assert.Nil(t, r)
+	assert.Equal(t, client.ErrMissingStreamKey, err)
+}
+
+func TestResilientClientReconnectsWhenTheConnectionDrops(t *testing.T) {
+	s := newPublishServer(t)
+
+	reconnected := make(chan *client.Client, 1)
+	r, err := client.NewResilientClient(context.Background(), s.URL("foo"),
+		client.ReconnectBackoff(testBackoff),
+		client.OnReconnect(func(c *client.Client) { reconnected <- c }))
+	assert.Nil(t, err)
+	defer r.Close()
+
+	assert.Nil(t, r.Write(newFrame()))
+	<-s.media
+
+	// Drop the first connection, and write until a frame arrives over the
+	// second.
+	(<-s.conns).Close()
+
+	deadline := time.After(time.Second)
+	for len(s.conns) == 0 || len(s.media) == 0 {
+		assert.Nil(t, r.Write(newFrame()))
+
+		select {
+		case <-deadline:
+			t.Fatal("client: no frame was written after reconnecting")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	assert.Equal(t, r.Client(), <-reconnected)
+	assert.EqualValues(t, 1, r.Reconnects())
+}
+
+func TestResilientClientGivesUpAfterMaxRetries(t *testing.T) {
+	s := newPublishServer(t)
+
+	r, err := client.NewResilientClient(context.Background(), s.URL("foo"),
+		client.ReconnectBackoff(testBackoff))
+	assert.Nil(t, err)
+	defer r.Close()
+
+	// Refuse all further connections, and drop the first.
+	s.l.Close()
+	(<-s.conns).Close()
+
+	deadline := time.After(time.Second)
+	for {
+		err := r.Write(newFrame())
+		if err != nil {
+			var rerr *client.ReconnectError
+			if assert.True(t, errors.As(err, &rerr)) {
+				assert.Equal(t, testBackoff.MaxRetries, rerr.Retries)
+			}
+			assert.Equal(t, err, r.Write(newFrame()))
+
+			break
+		}
+
+		select {
+		case <-deadline:
+			t.Fatal("client: did not give up reconnecting")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	assert.EqualValues(t, 0, r.Reconnects())
+}
+
+func TestResilientClientStopsOnceClosed(t *testing.T) {
+	s := newPublishServer(t)
+
+	r, err := client.NewResilientClient(context.Background(), s.URL("foo"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, r.Close())
+	assert.Equal(t, context.Canceled, r.Write(newFrame()))
+}
+
+func TestResilientClientDoesNotLeakGoroutinesOnceClosed(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	s := newPublishServer(t)
+	defer s.l.Close()
+
+	r, err := client.NewResilientClient(context.Background(), s.URL("foo"),
+		client.ReconnectBackoff(testBackoff))
+	assert.Nil(t, err)
+
+	assert.Nil(t, r.Write(newFrame()))
+	<-s.media
+
+	assert.Nil(t, r.Close())
+}
@@ -0,0 +1,44 @@
+package client_test
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// noDelay returns the value of the TCP_NODELAY option on the connection.
+func noDelay(t *testing.T, conn net.Conn) int {
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	assert.Nil(t, err)
+
+	var v int
+	raw.Control(func(fd uintptr) {
+		v, err = syscall.GetsockoptInt(int(fd),
+			syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+	})
+	assert.Nil(t, err)
+
+	return v
+}
+
+func acceptConnect(w chunk.Writer, c *chunk.Chunk) {
+	writeCommand(w, "_result", map[string]interface{}{})
+}
+
+func TestDialDisablesNagleByDefault(t *testing.T) {
+	c, err := client.Dial(newServer(t, acceptConnect))
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, noDelay(t, c.Conn()))
+}
+
+func TestDialMayEnableNagle(t *testing.T) {
+	c, err := client.Dial(newServer(t, acceptConnect), client.NoDelay(false))
+	assert.Nil(t, err)
+
+	assert.Equal(t, 0, noDelay(t, c.Conn()))
+}
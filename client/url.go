@@ -0,0 +1,119 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Scheme is the URL scheme of RTMP URLs.
+	Scheme = "rtmp"
+)
+
+var (
+	ErrUnsupportedScheme = errors.New("rtmp/client: unsupported URL scheme")
+	ErrMissingHost       = errors.New("rtmp/client: URL is missing a host")
+	ErrMissingApp        = errors.New("rtmp/client: URL is missing an app")
+)
+
+// URL is a parsed rtmp://host[:port]/app[/instance]/streamKey URL.
+type URL struct {
+	// Host is the host name or IP address of the server, without a port.
+	Host string
+	// Port is the port of the server, which is DefaultPort unless given.
+	Port string
+	// App is the application (and instance, if given) that is connected
+	// to, such as "live" or "live/instance".
+	App string
+	// StreamKey is the name of the stream that is published or played, if
+	// given.
+	StreamKey string
+	// Query holds the query parameters of the URL, which some servers use
+	// to pass authentication tokens.
+	Query url.Values
+}
+
+// ParseURL parses the given rtmp:// URL. The last segment of its path is the
+// stream key, and all preceding segments are the app, unless there is only a
+// single segment, in which case it is the app.
+func ParseURL(s string) (*URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != Scheme {
+		return nil, ErrUnsupportedScheme
+	}
+
+	if u.Hostname() == "" {
+		return nil, ErrMissingHost
+	}
+
+	parsed := &URL{
+		Host:  u.Hostname(),
+		Port:  u.Port(),
+		Query: u.Query(),
+	}
+	if parsed.Port == "" {
+		parsed.Port = DefaultPort
+	}
+
+	path := strings.Trim(u.Path, "/")
+	if path == "" {
+		return nil, ErrMissingApp
+	}
+
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		parsed.App, parsed.StreamKey = path[:i], path[i+1:]
+	} else {
+		parsed.App = path
+	}
+
+	return parsed, nil
+}
+
+// Addr returns the host:port address of the server.
+func (u *URL) Addr() string { return net.JoinHostPort(u.Host, u.Port) }
+
+// TcURL returns the URL of the app, without the stream key or query, as sent
+// in the tcUrl property of the connect command.
+func (u *URL) TcURL() string {
+	return (&url.URL{
+		Scheme: Scheme,
+		Host:   u.host(),
+		Path:   "/" + u.App,
+	}).String()
+}
+
+// String returns the URL in the same form that ParseURL accepts. The port is
+// omitted if it is the DefaultPort.
+func (u *URL) String() string {
+	path := "/" + u.App
+	if u.StreamKey != "" {
+		path += "/" + u.StreamKey
+	}
+
+	return (&url.URL{
+		Scheme:   Scheme,
+		Host:     u.host(),
+		Path:     path,
+		RawQuery: u.Query.Encode(),
+	}).String()
+}
+
+// host returns the host of the URL, including the port unless it is the
+// DefaultPort.
+func (u *URL) host() string {
+	if u.Port == DefaultPort {
+		if strings.Contains(u.Host, ":") {
+			return "[" + u.Host + "]"
+		}
+
+		return u.Host
+	}
+
+	return u.Addr()
+}
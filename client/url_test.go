@@ -0,0 +1,106 @@
+package client_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURLParsesURLs(t *testing.T) {
+	for _, c := range []struct {
+		URL      string
+		Expected *client.URL
+	}{
+		{
+			"rtmp://example.com/live",
+			&client.URL{
+				Host:  "example.com",
+				Port:  "1935",
+				App:   "live",
+				Query: url.Values{},
+			},
+		},
+		{
+			"rtmp://example.com:1936/live/key",
+			&client.URL{
+				Host:      "example.com",
+				Port:      "1936",
+				App:       "live",
+				StreamKey: "key",
+				Query:     url.Values{},
+			},
+		},
+		{
+			"rtmp://127.0.0.1/live/instance/key?token=abc",
+			&client.URL{
+				Host:      "127.0.0.1",
+				Port:      "1935",
+				App:       "live/instance",
+				StreamKey: "key",
+				Query:     url.Values{"token": {"abc"}},
+			},
+		},
+		{
+			"rtmp://[::1]:1936/live/key",
+			&client.URL{
+				Host:      "::1",
+				Port:      "1936",
+				App:       "live",
+				StreamKey: "key",
+				Query:     url.Values{},
+			},
+		},
+	} {
+		u, err := client.ParseURL(c.URL)
+
+		assert.Nil(t, err)
+		assert.Equal(t, c.Expected, u)
+	}
+}
+
+func TestParseURLRejectsInvalidURLs(t *testing.T) {
+	for _, c := range []struct {
+		URL string
+		Err error
+	}{
+		{"http://example.com/live", client.ErrUnsupportedScheme},
+		{"rtmp:///live", client.ErrMissingHost},
+		{"rtmp://example.com", client.ErrMissingApp},
+		{"rtmp://example.com/", client.ErrMissingApp},
+	} {
+		u, err := client.ParseURL(c.URL)
+
+		assert.Nil(t, u)
+		assert.Equal(t, c.Err, err, c.URL)
+	}
+}
+
+func TestURLsRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		"rtmp://example.com/live",
+		"rtmp://example.com:1936/live/key",
+		"rtmp://127.0.0.1/live/instance/key?token=abc",
+		"rtmp://[::1]/live/key",
+		"rtmp://[::1]:1936/live/key",
+	} {
+		u, err := client.ParseURL(s)
+
+		assert.Nil(t, err)
+		assert.Equal(t, s, u.String())
+	}
+}
+
+func TestURLsOmitTheDefaultPort(t *testing.T) {
+	u, _ := client.ParseURL("rtmp://example.com:1935/live/key")
+
+	assert.Equal(t, "rtmp://example.com/live/key", u.String())
+	assert.Equal(t, "example.com:1935", u.Addr())
+}
+
+func TestURLsProduceTcURLs(t *testing.T) {
+	u, _ := client.ParseURL("rtmp://example.com:1936/live/instance/key?token=abc")
+
+	assert.Equal(t, "rtmp://example.com:1936/live/instance", u.TcURL())
+}
@@ -20,8 +20,8 @@ func NewChunker(sid uint32) Chunker {
 }
 
 // Chunk implements the Chunk method on Chunker.Chunk. It marshals the data in
-// the given ConnSendable, using the amf0/encoding package, and writes it into a
-// chunk to be sent over the correct chunk stream ID.
+// the given ConnSendable, using its Marshal method, and writes it into a chunk
+// to be sent over the correct chunk stream ID.
 func (c *DefaultChunker) Chunk(m Marshallable) (*chunk.Chunk, error) {
 	data, err := m.Marshal()
 	if err != nil {
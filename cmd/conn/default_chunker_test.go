@@ -3,7 +3,6 @@ package conn_test
 import (
 	"testing"
 
-	"github.com/WatchBeam/amf0"
 	"github.com/WatchBeam/rtmp/cmd/conn"
 	"github.com/stretchr/testify/assert"
 )
@@ -17,8 +16,8 @@ func TestNewChunkerReturnsNewChunkers(t *testing.T) {
 func TestChunkersMarshalChunks(t *testing.T) {
 	crsp := &conn.ConnectResponse{
 		TransactionId: 12,
-		Properties:    amf0.Object{amf0.NewPaired()},
-		Information:   amf0.Object{amf0.NewPaired()},
+		Properties:    map[string]interface{}{},
+		Information:   map[string]interface{}{},
 	}
 
 	marshalled, _ := crsp.Marshal()
@@ -3,36 +3,31 @@ package conn
 import (
 	"fmt"
 	"io"
+	"reflect"
 
-	"github.com/WatchBeam/amf0"
-	"github.com/WatchBeam/amf0/encoding"
+	amf "github.com/WatchBeam/rtmp/amf0"
+	"github.com/WatchBeam/rtmp/chunk"
 )
 
 var (
 	// DefaultParser is the primary singleton instance of the Parser type.
 	// It comes preloaded with all RTMP-related Receivable types, which
-	// currently include the connect, createStream, releaseStream, and
-	// FCPublish packets.
+	// currently include the connect, createStream, releaseStream,
+	// FCPublish, and FCUnpublish packets.
 	//
 	// It is recommended that this be used as the primary parcer in any type
 	// that requires it.
 	DefaultParser Parser = NewParser(map[string]ReceviableFactory{
-		"connect": func() Receivable {
-			return &ConnectCommand{
-				Metadata: amf0.NewObject(),
-			}
-		},
+		"connect": func() Receivable { return new(ConnectCommand) },
 
-		"createStream": func() Receivable {
-			return &CreateStreamCommand{
-				Metadata: amf0.NewObject(),
-			}
-		},
+		"createStream": func() Receivable { return new(CreateStreamCommand) },
 
 		"releaseStream": func() Receivable { return new(ReleaseCommand) },
 
 		"FCPublish": func() Receivable { return new(FCPublishCommand) },
 
+		"FCUnpublish": func() Receivable { return new(FCUnpublishCommand) },
+
 		"getStreamLength": func() Receivable {
 			return new(GetStreamLength)
 		},
@@ -72,13 +67,13 @@ func NewParser(typs map[string]ReceviableFactory) *SimpleParser {
 // error in the following cases:
 //
 //   1) no corresponding command could be found
-//   2) an error occured during unmarshalling (see WatchBeam/rtmp)
+//   2) an error occured during unmarshalling (see WatchBeam/rtmp), which is
+//      returned as an *amf0.ParseError (from the github.com/WatchBeam/rtmp/amf0
+//      package), whose offset is relative to the start of `r`
 //
 // Otherwise the Receivable type is returned succesfully, and no error is
 // returned.
-func (p *SimpleParser) Parse(name *amf0.String, r io.Reader) (Receivable, error) {
-	str := string(*name)
-
+func (p *SimpleParser) Parse(str string, r io.Reader) (Receivable, error) {
 	factory := p.typs[str]
 	if factory == nil {
 		return nil, fmt.Errorf(
@@ -86,9 +81,40 @@ func (p *SimpleParser) Parse(name *amf0.String, r io.Reader) (Receivable, error)
 	}
 
 	v := factory()
-	if err := encoding.Unmarshal(r, v); err != nil {
-		return nil, err
+	cr := chunk.NewCountingReader(r, nil)
+	if err := unmarshal(amf.NewDecoder(cr), v); err != nil {
+		perr := &amf.ParseError{
+			Offset:   int64(cr.BytesRead()),
+			Expected: fmt.Sprintf("%s arguments", str),
+			Err:      err,
+		}
+		if inner, ok := err.(*amf.ParseError); ok {
+			// Report where the argument which failed to parse
+			// started, and what was found there.
+			perr.Offset = inner.Offset
+			perr.Actual = inner.Actual
+			perr.Err = inner.Err
+		}
+
+		return nil, perr
 	}
 
 	return v, nil
 }
+
+// unmarshal decodes each of the exported fields of the struct pointed to by v in
+// turn, as the arguments of a command are laid out.
+func unmarshal(d *amf.Decoder, v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		if rv.Type().Field(i).PkgPath != "" {
+			continue
+		}
+
+		if err := d.Decode(rv.Field(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
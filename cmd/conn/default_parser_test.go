@@ -5,7 +5,7 @@ import (
 	"io"
 	"testing"
 
-	"github.com/WatchBeam/amf0"
+	amf "github.com/WatchBeam/rtmp/amf0"
 	"github.com/WatchBeam/rtmp/cmd/conn"
 	"github.com/stretchr/testify/assert"
 )
@@ -30,7 +30,7 @@ func TestParseParsesReadersIntoReceivables(t *testing.T) {
 	})
 
 	r, err := p.Parse(
-		amf0.NewString("createStream"),
+		"createStream",
 		bytes.NewReader(CreatePayload),
 	)
 
@@ -50,7 +50,7 @@ func TestParseFailsWhenNoMatchingCommandIsPresent(t *testing.T) {
 	// No types
 	})
 
-	r, err := p.Parse(amf0.NewString("not-a-type"), new(bytes.Buffer))
+	r, err := p.Parse("not-a-type", new(bytes.Buffer))
 
 	assert.Nil(t, r)
 	assert.Equal(t, "rtmp/cmd/conn: unknown command name: not-a-type",
@@ -65,8 +65,11 @@ func TestParseFailsWhenDataIsUnmarshalable(t *testing.T) {
 	})
 
 	// Error: use an empty buffer to ensure that an EOF is thrown
-	r, err := p.Parse(amf0.NewString("createStream"), new(bytes.Buffer))
+	r, err := p.Parse("createStream", new(bytes.Buffer))
 
 	assert.Nil(t, r)
-	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, &amf.ParseError{
+		Expected: "createStream arguments",
+		Err:      io.EOF,
+	}, err)
 }
@@ -1,8 +1,9 @@
 package conn
 
 import (
-	"github.com/WatchBeam/amf0"
-	"github.com/WatchBeam/amf0/encoding"
+	"bytes"
+
+	amf "github.com/WatchBeam/rtmp/amf0"
 )
 
 const (
@@ -19,25 +20,39 @@ type Marshallable interface {
 type CreateStreamResponse struct {
 	ResponseType  string
 	TransactionId float64
-	_             *amf0.Null
 	StreamID      float64
 }
 
 type ConnectResponse struct {
 	ResponseType  string
 	TransactionId float64
-	Properties    amf0.Object
-	Information   amf0.Object
+	Properties    map[string]interface{}
+	Information   map[string]interface{}
 }
 
 // Marshal implements Marshallable.Marshal.
 func (r *CreateStreamResponse) Marshal() ([]byte, error) {
 	r.ResponseType = SuccessfulResponseType
-	return encoding.Marshal(r)
+	return marshal(r.ResponseType, r.TransactionId, nil, r.StreamID)
 }
 
 // Marshal implements Marshallable.Marshal.
 func (r *ConnectResponse) Marshal() ([]byte, error) {
 	r.ResponseType = SuccessfulResponseType
-	return encoding.Marshal(r)
+	return marshal(r.ResponseType, r.TransactionId, r.Properties,
+		r.Information)
+}
+
+// marshal encodes each of the given values in turn, as the fields of a response
+// are laid out.
+func marshal(values ...interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := amf.NewEncoder(buf)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
 }
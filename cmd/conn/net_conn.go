@@ -2,9 +2,9 @@ package conn
 
 import (
 	"bytes"
-	"fmt"
+	"reflect"
 
-	"github.com/WatchBeam/amf0"
+	amf "github.com/WatchBeam/rtmp/amf0"
 	"github.com/WatchBeam/rtmp/chunk"
 )
 
@@ -98,25 +98,64 @@ func (n *NetConn) Listen() {
 		case c := <-n.chunkStream:
 			buf := bytes.NewBuffer(c.Data)
 
-			name, err := amf0.Decode(buf)
-			if err != nil {
-				n.errs <- err
+			var v interface{}
+			if err := amf.NewDecoder(buf).Decode(&v); err != nil {
+				if !n.send(err) {
+					return
+				}
 				continue
 			}
 
-			nameStr, ok := name.(*amf0.String)
+			name, ok := v.(string)
 			if !ok {
-				n.errs <- fmt.Errorf("rtmp/conn: wrong type for AMF header: %T (expected amf0.String)", name)
+				// Decoding into a string would accept null and
+				// undefined as an empty name, so the type is
+				// checked here instead.
+				actual := amf.Marker(c.Data[0]).String()
+				if !n.send(&amf.ParseError{
+					Expected: "string",
+					Actual:   actual,
+					Err: &amf.UnmarshalTypeError{
+						Value: actual,
+						Type:  reflect.TypeOf(name),
+					},
+				}) {
+					return
+				}
 				continue
 			}
 
-			if r, err := n.parser.Parse(nameStr, buf); err != nil {
-				n.errs <- err
+			offset := int64(len(c.Data) - buf.Len())
+			if r, err := n.parser.Parse(name, buf); err != nil {
+				if perr, ok := err.(*amf.ParseError); ok {
+					// Report the offset from the start of the
+					// message, rather than the end of its name.
+					perr.Offset += offset
+				}
+
+				if !n.send(err) {
+					return
+				}
 			} else {
-				n.in <- r
+				select {
+				case n.in <- r:
+				case <-n.closer:
+					return
+				}
 			}
 		case <-n.closer:
 			return
 		}
 	}
 }
+
+// send passes the given error along over the Errs() channel, returning whether
+// or not it was received before the NetConn was closed.
+func (n *NetConn) send(err error) bool {
+	select {
+	case n.errs <- err:
+		return true
+	case <-n.closer:
+		return false
+	}
+}
@@ -3,9 +3,10 @@ package conn
 import (
 	"bytes"
 	"errors"
+	"io"
 	"testing"
 
-	"github.com/WatchBeam/amf0"
+	amf "github.com/WatchBeam/rtmp/amf0"
 	"github.com/WatchBeam/rtmp/chunk"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -37,7 +38,6 @@ func TestNetConnectionReceivablesAreWrittenOut(t *testing.T) {
 	assert.Equal(t, 0, len(nc.Errs()))
 	assert.Equal(t, &CreateStreamCommand{
 		TransactionId: 4,
-		Metadata:      amf0.NewObject(),
 	}, <-nc.In())
 }
 
@@ -66,9 +66,11 @@ func TestWronglyTypedNamesPropogateErrors(t *testing.T) {
 	nc := NewNetConnection(chunks, nil)
 	go nc.Listen()
 
-	assert.Equal(t,
-		"rtmp/conn: wrong type for AMF header: *amf0.Null (expected amf0.String)",
-		(<-nc.Errs()).Error())
+	err := <-nc.Errs()
+
+	assert.IsType(t, new(amf.ParseError), err)
+	assert.Equal(t, "string", err.(*amf.ParseError).Expected)
+	assert.Equal(t, "null", err.(*amf.ParseError).Actual)
 }
 
 func TestParserErrorsArePropogated(t *testing.T) {
@@ -89,6 +91,29 @@ func TestParserErrorsArePropogated(t *testing.T) {
 
 }
 
+func TestParseErrorOffsetsIncludeTheCommandName(t *testing.T) {
+	chunks := make(chan *chunk.Chunk, 1)
+	chunks <- &chunk.Chunk{
+		Data: []byte{
+			0x02, 0x00, 0x03, 0x66, 0x6f, 0x6f, // "foo"
+			0x00, 0x40, // <truncated number>
+		},
+	}
+
+	p := new(MockParser)
+	p.On("Parse", mock.Anything, mock.Anything).Return(
+		nil, &amf.ParseError{Offset: 2, Err: io.ErrUnexpectedEOF}).Once()
+
+	nc := NewNetConnection(chunks, nil)
+	nc.parser = p
+	go nc.Listen()
+
+	err := <-nc.Errs()
+
+	assert.IsType(t, new(amf.ParseError), err)
+	assert.EqualValues(t, 8, err.(*amf.ParseError).Offset)
+}
+
 func TestSendablesAreWrittenToChunkStream(t *testing.T) {
 	buf := new(bytes.Buffer)
 
@@ -1,10 +1,6 @@
 package conn
 
-import (
-	"io"
-
-	"github.com/WatchBeam/amf0"
-)
+import "io"
 
 // Parser is a functional interface responsible for parsing a body of data out
 // of an io.Reader into a Receivable identified by the given `name`.
@@ -12,5 +8,5 @@ type Parser interface {
 	// Parse parses a Receivable identified by `name` and with data living
 	// on the io.Reader `r` into a Receivable, or an error, if the data was
 	// incorrect, or not parse-able.
-	Parse(name *amf0.String, r io.Reader) (Receivable, error)
+	Parse(name string, r io.Reader) (Receivable, error)
 }
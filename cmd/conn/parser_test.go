@@ -3,7 +3,6 @@ package conn
 import (
 	"io"
 
-	"github.com/WatchBeam/amf0"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -13,7 +12,7 @@ type MockParser struct {
 
 var _ Parser = new(MockParser)
 
-func (p *MockParser) Parse(name *amf0.String, r io.Reader) (Receivable, error) {
+func (p *MockParser) Parse(name string, r io.Reader) (Receivable, error) {
 	args := p.Called(name, r)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -1,7 +1,5 @@
 package conn
 
-import "github.com/WatchBeam/amf0"
-
 // Type Receviable is used to tag certain Commands as being able to be received.
 type Receivable interface {
 	CanReceive() bool
@@ -9,35 +7,35 @@ type Receivable interface {
 
 type ConnectCommand struct {
 	TransactionId float64
-	Metadata      *amf0.Object
+	Metadata      map[string]interface{}
 }
 
 type CreateStreamCommand struct {
 	TransactionId float64
-	Metadata      *amf0.Object
+	Metadata      map[string]interface{}
 }
 
 type ReleaseCommand struct {
 	TransactionId float64
-	Nil           *amf0.Null
+	Nil           interface{}
 	StreamKey     string
 }
 
 type FCPublishCommand struct {
 	TransactionId float64
-	Nil           *amf0.Null
+	Nil           interface{}
 	StreamKey     string
 }
 
 type FCUnpublishCommand struct {
 	TransactionId float64
-	Nil           *amf0.Null
+	Nil           interface{}
 	StreamKey     string
 }
 
 type GetStreamLength struct {
 	StreamId float64
-	Nil      *amf0.Null
+	Nil      interface{}
 	PlayPath string
 }
 
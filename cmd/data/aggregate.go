@@ -0,0 +1,127 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/spec"
+)
+
+const (
+	// AggregateTypeId is the message type ID of an aggregate message, which
+	// packs several audio, video, or script data sub-messages into one.
+	AggregateTypeId byte = 0x16
+
+	// aggregateHeaderLength is the length of the header preceding each
+	// sub-message: its type ID (1 byte), payload length (3 bytes),
+	// timestamp (3 bytes, and 1 byte holding its upper 8 bits), and
+	// message stream ID (3 bytes).
+	aggregateHeaderLength = 11
+	// aggregateBackPointerLength is the length of the back pointer
+	// following each sub-message, which holds the length of the
+	// sub-message, including its header.
+	aggregateBackPointerLength = 4
+)
+
+var (
+	// ErrMalformedAggregate is returned by SplitAggregate when a
+	// sub-message runs past the end of the aggregate message.
+	ErrMalformedAggregate = errors.New("rtmp/data: malformed aggregate message")
+)
+
+// SplitAggregate splits the given aggregate message (see AggregateTypeId) into
+// a chunk for each of its sub-messages, in order. The timestamps of the
+// sub-messages are relative to one another, so each chunk is given the
+// timestamp of the aggregate message, offset by the difference between the
+// timestamp of its sub-message and that of the first sub-message. Each chunk is
+// sent over the same chunk stream and message stream as the aggregate message.
+//
+// If a sub-message runs past the end of the aggregate message,
+// ErrMalformedAggregate is returned, along with no chunks.
+func SplitAggregate(c *chunk.Chunk) ([]*chunk.Chunk, error) {
+	var (
+		chunks []*chunk.Chunk
+		first  uint32
+	)
+
+	for b := c.Data; len(b) > 0; {
+		if len(b) < aggregateHeaderLength {
+			return nil, ErrMalformedAggregate
+		}
+
+		length := int(spec.Uint32(b[1:4]))
+		end := aggregateHeaderLength + length
+		if len(b) < end {
+			return nil, ErrMalformedAggregate
+		}
+
+		ts := spec.Uint32(b[4:7]) | uint32(b[7])<<24
+		if len(chunks) == 0 {
+			first = ts
+		}
+
+		header := &chunk.Header{
+			BasicHeader: chunk.BasicHeader{StreamId: c.StreamId()},
+			MessageHeader: chunk.MessageHeader{
+				Length:   uint32(length),
+				TypeId:   b[0],
+				StreamId: c.Header.MessageHeader.StreamId,
+			},
+		}
+		header.SetTimestamp(c.Timestamp() + ts - first)
+
+		chunks = append(chunks, &chunk.Chunk{
+			Header: header,
+			Data:   b[aggregateHeaderLength:end],
+		})
+
+		// The back pointer may be omitted after the last sub-message.
+		if end += aggregateBackPointerLength; end > len(b) {
+			end = len(b)
+		}
+		b = b[end:]
+	}
+
+	return chunks, nil
+}
+
+// PackAggregate packs the given chunks into a single aggregate message (see
+// AggregateTypeId), which is the inverse of SplitAggregate. The aggregate
+// message is sent over the chunk stream and message stream of the first chunk,
+// with its timestamp, and the timestamp of each sub-message is that of its
+// chunk. If no chunks are given, nil is returned.
+func PackAggregate(chunks ...*chunk.Chunk) *chunk.Chunk {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	buf := new(bytes.Buffer)
+	for _, c := range chunks {
+		ts := c.Timestamp()
+
+		spec.PutUint8(c.TypeId(), buf)
+		spec.PutUint24(uint32(len(c.Data)), buf)
+		spec.PutUint24(ts&0xffffff, buf)
+		spec.PutUint8(byte(ts>>24), buf)
+		spec.PutUint24(c.Header.MessageHeader.StreamId, buf)
+		buf.Write(c.Data)
+		spec.PutUint32(uint32(aggregateHeaderLength+len(c.Data)), buf)
+	}
+
+	first := chunks[0]
+	header := &chunk.Header{
+		BasicHeader: chunk.BasicHeader{StreamId: first.StreamId()},
+		MessageHeader: chunk.MessageHeader{
+			Length:   uint32(buf.Len()),
+			TypeId:   AggregateTypeId,
+			StreamId: first.Header.MessageHeader.StreamId,
+		},
+	}
+	header.SetTimestamp(first.Timestamp())
+
+	return &chunk.Chunk{
+		Header: header,
+		Data:   buf.Bytes(),
+	}
+}
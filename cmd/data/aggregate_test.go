@@ -0,0 +1,88 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// Aggregate is an aggregate message packing a video keyframe at 1000ms,
+	// an audio frame at 1020ms, and a video interframe at 1040ms, the last
+	// of which omits its back pointer.
+	Aggregate = []byte{
+		0x09, 0x00, 0x00, 0x02, 0x00, 0x03, 0xe8, 0x00, 0x00, 0x00, 0x00,
+		0x17, 0x01,
+		0x00, 0x00, 0x00, 0x0d,
+		0x08, 0x00, 0x00, 0x02, 0x00, 0x03, 0xfc, 0x00, 0x00, 0x00, 0x00,
+		0xaf, 0x01,
+		0x00, 0x00, 0x00, 0x0d,
+		0x09, 0x00, 0x00, 0x02, 0x00, 0x04, 0x10, 0x00, 0x00, 0x00, 0x00,
+		0x27, 0x01,
+	}
+)
+
+func TestSplitAggregateSplitsSubMessages(t *testing.T) {
+	c := newDataChunk(data.AggregateTypeId, Aggregate)
+	c.Header.BasicHeader.StreamId = 4
+	c.Header.MessageHeader.StreamId = 1
+	c.Header.SetTimestamp(5000)
+
+	chunks, err := data.SplitAggregate(c)
+
+	assert.Nil(t, err)
+	assert.Len(t, chunks, 3)
+
+	for i, expected := range []struct {
+		TypeId    byte
+		Timestamp uint32
+		Data      []byte
+	}{
+		{data.VideoTypeId, 5000, []byte{0x17, 0x01}},
+		{data.AudioTypeId, 5020, []byte{0xaf, 0x01}},
+		{data.VideoTypeId, 5040, []byte{0x27, 0x01}},
+	} {
+		assert.Equal(t, expected.TypeId, chunks[i].TypeId())
+		assert.Equal(t, expected.Timestamp, chunks[i].Timestamp())
+		assert.Equal(t, expected.Data, chunks[i].Data)
+		assert.EqualValues(t, 2, chunks[i].Header.MessageHeader.Length)
+		assert.EqualValues(t, 4, chunks[i].StreamId())
+		assert.EqualValues(t, 1, chunks[i].Header.MessageHeader.StreamId)
+	}
+}
+
+func TestSplitAggregateRejectsTruncatedSubMessages(t *testing.T) {
+	c := newDataChunk(data.AggregateTypeId, Aggregate[:len(Aggregate)-1])
+
+	chunks, err := data.SplitAggregate(c)
+
+	assert.Nil(t, chunks)
+	assert.Equal(t, data.ErrMalformedAggregate, err)
+}
+
+func TestPackAggregateIsTheInverseOfSplitAggregate(t *testing.T) {
+	first := newDataChunk(data.VideoTypeId, Keyframe)
+	first.SetTimestamp(0x01000010)
+	second := newDataChunk(data.AudioTypeId, AudioFrame)
+	second.SetTimestamp(0x01000020)
+
+	c := data.PackAggregate(first, second)
+
+	assert.Equal(t, data.AggregateTypeId, c.TypeId())
+	assert.EqualValues(t, len(c.Data), c.Header.MessageHeader.Length)
+	assert.EqualValues(t, 0x01000010, c.Timestamp())
+
+	chunks, err := data.SplitAggregate(c)
+
+	assert.Nil(t, err)
+	assert.Len(t, chunks, 2)
+	assert.EqualValues(t, 0x01000010, chunks[0].Timestamp())
+	assert.Equal(t, Keyframe, chunks[0].Data)
+	assert.EqualValues(t, 0x01000020, chunks[1].Timestamp())
+	assert.Equal(t, AudioFrame, chunks[1].Data)
+}
+
+func TestPackAggregateReturnsNilWithoutChunks(t *testing.T) {
+	assert.Nil(t, data.PackAggregate())
+}
@@ -4,6 +4,17 @@ const (
 	AudioTypeId byte = 0x08
 )
 
+const (
+	// aacCodecId is the codec ID carried in the high nibble of the control
+	// byte of AAC payloads.
+	aacCodecId byte = 10
+	// exHeaderCodecId is the codec ID carried in the high nibble of the
+	// control byte of Enhanced RTMP payloads, whose codec is identified by
+	// the FourCC following the control byte, and whose packet type is
+	// carried in the low nibble.
+	exHeaderCodecId byte = 9
+)
+
 const (
 	UncompressedAudioCodec AudioCodec = iota
 	ADPCMAudioCodec
@@ -18,11 +29,37 @@ const (
 	SPEEXAudioCodec
 )
 
+const (
+	// UnknownAudioCodec is the codec of audio sent with an Enhanced RTMP
+	// extended header, which is identified by its FourCC instead (see
+	// Audio.FourCC).
+	UnknownAudioCodec AudioCodec = 0xff
+)
+
 const (
 	MonoAudioType AudioType = iota
 	StereoAudioType
 )
 
+const (
+	// SequenceStartAudioPacketType is the packet type of a sequence
+	// header, carrying the decoder configuration (such as the
+	// AudioSpecificConfig of AAC, or the identification header of Opus).
+	SequenceStartAudioPacketType AudioPacketType = 0
+	// CodedFramesAudioPacketType is the packet type of coded frames.
+	CodedFramesAudioPacketType AudioPacketType = 1
+	// SequenceEndAudioPacketType is the packet type marking the end of a
+	// sequence.
+	SequenceEndAudioPacketType AudioPacketType = 2
+	// MultichannelConfigAudioPacketType is the packet type of the channel
+	// layout of multichannel audio.
+	MultichannelConfigAudioPacketType AudioPacketType = 4
+	// MultitrackAudioPacketType is the packet type of payloads carrying
+	// one or more tracks (see Audio.IsMultitrack), whose packet type
+	// follows in the next byte.
+	MultitrackAudioPacketType AudioPacketType = 5
+)
+
 type (
 	// AudioCodec represents a singleton definition of the Codec assosciated
 	// with a specific frame of audio.
@@ -31,6 +68,10 @@ type (
 	// AudioType represents a singleton definition of the audio Type
 	// assosicated with a specific frame of Audio.
 	AudioType byte
+
+	// AudioPacketType is the kind of packet carried by a frame of Audio:
+	// either a sequence header, coded frames, or the end of a sequence.
+	AudioPacketType byte
 )
 
 // Audio implements the Data interface for a frame of Audio.
@@ -41,8 +82,82 @@ var _ Data = new(Audio)
 // Id implements the Data.Id function.
 func (a *Audio) Id() byte { return AudioTypeId }
 
-// Codec retrns the AudioCodec assosciated with this frame of audio.
-func (a *Audio) Codec() AudioCodec { return AudioCodec((a.Control() & 0xf0) >> 4) }
+// Kind implements the Data.Kind function.
+func (a *Audio) Kind() Kind { return AudioKind }
+
+// IsKeyframe implements the Data.IsKeyframe function. Audio is never a
+// keyframe.
+func (a *Audio) IsKeyframe() bool { return false }
+
+// Clone implements the Data.Clone function.
+func (a *Audio) Clone() Data { return &Audio{a.data.clone()} }
+
+// Codec retrns the AudioCodec assosciated with this frame of audio. Frames sent
+// with an Enhanced RTMP extended header are identified by their FourCC (see
+// FourCC) instead, and are reported as UnknownAudioCodec.
+func (a *Audio) Codec() AudioCodec {
+	if a.IsEnhanced() {
+		return UnknownAudioCodec
+	}
+
+	return AudioCodec((a.Control() & 0xf0) >> 4)
+}
+
+// IsEnhanced returns whether or not this frame of Audio was sent with an
+// Enhanced RTMP extended header, in which case its codec is identified by its
+// FourCC, and the Rate, Size, and Type of the audio are not given by its
+// control byte.
+func (a *Audio) IsEnhanced() bool {
+	return len(a.data.data) > 0 && a.Control()>>4 == exHeaderCodecId
+}
+
+// IsMultitrack returns whether or not this frame of Audio was sent with an
+// Enhanced RTMP extended header carrying one or more tracks, rather than a
+// single, implicit track.
+func (a *Audio) IsMultitrack() bool {
+	return a.IsEnhanced() &&
+		AudioPacketType(a.Control()&0x0f) == MultitrackAudioPacketType
+}
+
+// FourCC returns the FourCC identifying the codec of this frame of Audio, and
+// whether or not it carries one at all: only frames sent with an Enhanced RTMP
+// extended header do (see IsEnhanced). Of multitrack frames whose tracks each
+// carry their own FourCC, the FourCC of the first track is returned.
+func (a *Audio) FourCC() (FourCC, bool) {
+	if !a.IsEnhanced() {
+		return "", false
+	}
+
+	if a.IsMultitrack() {
+		// The FourCC follows the multitrack type and packet type, and
+		// is either shared by all tracks, or that of the first.
+		return fourCCAt(a.data.data, 2)
+	}
+
+	return fourCCAt(a.data.data, 1)
+}
+
+// PacketType returns the AudioPacketType of this frame of Audio. Of frames sent
+// with an Enhanced RTMP extended header, it is carried in the low nibble of the
+// control byte, or, for multitrack frames, in the byte following it. Of AAC
+// frames sent without one, it is the packet type following the control byte,
+// whose values for sequence headers and coded frames are the same. Frames
+// encoded with any other codec carry only coded frames.
+func (a *Audio) PacketType() AudioPacketType {
+	switch {
+	case a.IsMultitrack():
+		if len(a.data.data) < 2 {
+			return MultitrackAudioPacketType
+		}
+		return AudioPacketType(a.data.data[1] & 0x0f)
+	case a.IsEnhanced():
+		return AudioPacketType(a.Control() & 0x0f)
+	case len(a.data.data) > 1 && a.Control()>>4 == aacCodecId:
+		return AudioPacketType(a.data.data[1])
+	}
+
+	return CodedFramesAudioPacketType
+}
 
 // Rate returns the rate of audio contained in this frame in units of kHz.
 func (a *Audio) Rate() float32 {
@@ -56,3 +171,16 @@ func (a *Audio) Size() int {
 
 // Type returns the AudioType assosciated with this frame of Audio.
 func (a *Audio) Type() AudioType { return AudioType(a.Control() & 0x01) }
+
+// isSequenceHeader returns whether or not this frame of Audio is a sequence
+// header, either of AAC audio, carrying the AudioSpecificConfig, or of audio
+// sent with an Enhanced RTMP extended header (see PacketType).
+func (a *Audio) isSequenceHeader() bool {
+	if a.IsEnhanced() {
+		_, ok := a.FourCC()
+		return ok && a.PacketType() == SequenceStartAudioPacketType
+	}
+
+	return len(a.data.data) > 1 && a.Control()>>4 == aacCodecId &&
+		a.PacketType() == SequenceStartAudioPacketType
+}
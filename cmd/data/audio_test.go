@@ -26,7 +26,8 @@ func TestAudioDeterminesCorrectCodec(t *testing.T) {
 		{0x60, NellymoserAudioCodec},
 		{0x70, G711AAudioCodec},
 		{0x80, G711UAudioCodec},
-		{0x90, HE_AACAudioCodec},
+		// 0x9 is the Enhanced RTMP extended header.
+		{0x90, UnknownAudioCodec},
 		{0xa0, SPEEXAudioCodec},
 	} {
 		a := new(Audio)
@@ -64,3 +65,76 @@ func TestAudioDeterminesCorrectType(t *testing.T) {
 		assert.Equal(t, c.Type, a.Type())
 	}
 }
+
+var (
+	// OpusSequenceHeader is an Opus sequence header signaled by an
+	// Enhanced RTMP extended header, carrying the identification header
+	// of a stereo stream at 48kHz.
+	OpusSequenceHeader = []byte{
+		0x90, 0x4f, 0x70, 0x75, 0x73, 0x4f, 0x70, 0x75, 0x73, 0x48,
+		0x65, 0x61, 0x64, 0x01, 0x02, 0x38, 0x01, 0x80, 0xbb, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	}
+
+	// OpusFrame is a single coded Opus frame, carrying 20ms of silence.
+	OpusFrame = []byte{
+		0x91, 0x4f, 0x70, 0x75, 0x73, 0xfc, 0xff, 0xfe,
+	}
+)
+
+func TestAudioDecodesEnhancedHeaders(t *testing.T) {
+	for _, c := range []struct {
+		Payload          []byte
+		FourCC           FourCC
+		PacketType       AudioPacketType
+		IsSequenceHeader bool
+	}{
+		{OpusSequenceHeader, OpusFourCC,
+			SequenceStartAudioPacketType, true},
+		{OpusFrame, OpusFourCC, CodedFramesAudioPacketType, false},
+		{[]byte{0x91, 'a', 'c', '-', '3', 0x0b, 0x77}, AC3FourCC,
+			CodedFramesAudioPacketType, false},
+		// Multitrack FLAC sequence start, in one track.
+		{[]byte{0x95, 0x00, 'f', 'L', 'a', 'C', 0x00, 0x66}, FLACFourCC,
+			SequenceStartAudioPacketType, true},
+	} {
+		a := new(Audio)
+		a.data.data = c.Payload
+
+		f, ok := a.FourCC()
+
+		assert.True(t, a.IsEnhanced(), "%x", c.Payload)
+		assert.True(t, ok, "%x", c.Payload)
+		assert.Equal(t, c.FourCC, f, "%x", c.Payload)
+		assert.Equal(t, UnknownAudioCodec, a.Codec(), "%x", c.Payload)
+		assert.Equal(t, c.PacketType, a.PacketType(), "%x", c.Payload)
+		assert.Equal(t, c.IsSequenceHeader, a.isSequenceHeader(),
+			"%x", c.Payload)
+	}
+}
+
+func TestAudioFallsBackToTheLegacyCodec(t *testing.T) {
+	for _, c := range []struct {
+		Payload          []byte
+		PacketType       AudioPacketType
+		IsSequenceHeader bool
+	}{
+		// AAC sequence header, and coded frame.
+		{[]byte{0xaf, 0x00, 0x12, 0x10}, SequenceStartAudioPacketType, true},
+		{[]byte{0xaf, 0x01, 0x21}, CodedFramesAudioPacketType, false},
+		// MP3 frame.
+		{[]byte{0x2f, 0xff, 0xfb}, CodedFramesAudioPacketType, false},
+	} {
+		a := new(Audio)
+		a.data.data = c.Payload
+
+		_, ok := a.FourCC()
+
+		assert.False(t, a.IsEnhanced(), "%x", c.Payload)
+		assert.False(t, ok, "%x", c.Payload)
+		assert.Equal(t, AudioCodec(c.Payload[0]>>4), a.Codec(), "%x", c.Payload)
+		assert.Equal(t, c.PacketType, a.PacketType(), "%x", c.Payload)
+		assert.Equal(t, c.IsSequenceHeader, a.isSequenceHeader(),
+			"%x", c.Payload)
+	}
+}
@@ -0,0 +1,12 @@
+package data
+
+import "github.com/WatchBeam/rtmp/chunk"
+
+// Chunker is an interface representing a type responsible for turning a frame
+// of Data into an RTMP chunk, capable of being written to a chunk.Writer (which
+// splits it up according to the negotiated chunk size).
+type Chunker interface {
+	// Chunk marshals the given Data into an RTMP chunk, returning any
+	// error encountered along the way.
+	Chunk(Data) (*chunk.Chunk, error)
+}
@@ -0,0 +1,22 @@
+package data_test
+
+import (
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockChunker struct {
+	mock.Mock
+}
+
+var _ data.Chunker = new(MockChunker)
+
+func (c *MockChunker) Chunk(d data.Data) (*chunk.Chunk, error) {
+	args := c.Called(d)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*chunk.Chunk), args.Error(1)
+}
@@ -0,0 +1,147 @@
+package data
+
+import (
+	"io"
+	"sync"
+
+	"github.com/WatchBeam/rtmp/chunk"
+)
+
+// conn is the io.ReadWriteCloser returned by Stream.Conn.
+type conn struct {
+	// stream is the *Stream that is read from and written to.
+	stream *Stream
+
+	// rmu guards buf.
+	rmu sync.Mutex
+	// buf holds the unread remainder of the last payload that was read.
+	buf []byte
+
+	// hmu guards header.
+	hmu sync.Mutex
+	// header is the header of the last message that was read, which is
+	// used as a template for the messages that are written.
+	header chunk.Header
+
+	// closeOnce ensures that closed is only closed once.
+	closeOnce sync.Once
+	// closed is closed when the conn is closed.
+	closed chan struct{}
+}
+
+var _ io.ReadWriteCloser = new(conn)
+
+// Conn returns an io.ReadWriteCloser which treats this Stream as a pipe of
+// bytes, such that it may be used by code which knows nothing of RTMP.
+//
+// Reads consume Data from the In() channel, and return the concatenation of
+// their payloads, blocking until Data arrives. Any parsing error received over
+// the Errs() channel is returned from Read. Once the Stream or the Conn is
+// closed, Read returns io.EOF.
+//
+// Each call to Write is sent as a single message, which is chunked by the
+// Stream's chunk.Writer. Messages are written with the type, chunk stream, and
+// message stream of the last message that was read, or as audio messages over
+// message stream 1 if nothing has been read yet.
+//
+// Closing the Conn does not close the Stream.
+func (s *Stream) Conn() io.ReadWriteCloser {
+	return &conn{
+		stream: s,
+		header: chunk.Header{
+			BasicHeader: chunk.BasicHeader{0, 4},
+			MessageHeader: chunk.MessageHeader{
+				TypeId:   AudioTypeId,
+				StreamId: 1,
+			},
+		},
+		closed: make(chan struct{}),
+	}
+}
+
+// Read implements io.Reader.Read.
+func (c *conn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	for len(c.buf) == 0 {
+		select {
+		case <-c.closed:
+			return 0, io.EOF
+		case d, ok := <-c.stream.In():
+			if !ok {
+				return 0, io.EOF
+			}
+
+			ch, err := d.Marshal()
+			if err != nil {
+				return 0, err
+			}
+
+			c.setHeader(ch.Header)
+			c.buf = ch.Data
+		case err, ok := <-c.stream.Errs():
+			if !ok {
+				return 0, io.EOF
+			}
+
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+
+	return n, nil
+}
+
+// Write implements io.Writer.Write.
+func (c *conn) Write(p []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	h := c.getHeader()
+	h.MessageHeader.Length = uint32(len(p))
+
+	ch := &chunk.Chunk{Header: &h, Data: p}
+	ch.SetTimestamp(0)
+
+	if err := c.stream.writer.Write(ch); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close implements io.Closer.Close. It causes any blocked and subsequent Reads
+// to return io.EOF, and subsequent Writes to return io.ErrClosedPipe.
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+
+	return nil
+}
+
+// setHeader copies the given header to be used as a template for the messages
+// that are written.
+func (c *conn) setHeader(h *chunk.Header) {
+	if h == nil {
+		return
+	}
+
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+
+	c.header = *h
+}
+
+// getHeader returns a copy of the header used as a template for the messages
+// that are written.
+func (c *conn) getHeader() chunk.Header {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+
+	return c.header
+}
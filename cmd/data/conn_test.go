@@ -0,0 +1,95 @@
+package data_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func newConnTestChunk(payload ...byte) *chunk.Chunk {
+	return &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader: chunk.BasicHeader{0, 6},
+			MessageHeader: chunk.MessageHeader{
+				Length:   uint32(len(payload)),
+				TypeId:   data.VideoTypeId,
+				StreamId: 3,
+			},
+		},
+		Data: payload,
+	}
+}
+
+func TestConnReadsConcatenatePayloads(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	go s.Recv()
+	defer s.Close()
+
+	go func() {
+		s.Chunks() <- newConnTestChunk(0x17, 0x01)
+		s.Chunks() <- newConnTestChunk(0x27, 0x02, 0x03)
+	}()
+
+	buf := make([]byte, 5)
+	n, err := io.ReadFull(s.Conn(), buf)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, []byte{0x17, 0x01, 0x27, 0x02, 0x03}, buf)
+}
+
+func TestConnReadsReturnEOFAfterClose(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	go s.Recv()
+	defer s.Close()
+
+	c := s.Conn()
+	go c.Close()
+
+	n, err := c.Read(make([]byte, 1))
+
+	assert.Equal(t, 0, n)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = c.Write([]byte{0x00})
+	assert.Equal(t, io.ErrClosedPipe, err)
+}
+
+func TestConnReadsReturnEOFOnceTheStreamIsClosed(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	go s.Recv()
+
+	c := s.Conn()
+	s.Close()
+
+	_, err := c.Read(make([]byte, 1))
+
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestConnWritesMatchTheLastMessageRead(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NewWriter(buf, 2))
+	go s.Recv()
+	defer s.Close()
+
+	go func() { s.Chunks() <- newConnTestChunk(0x17, 0x01) }()
+
+	c := s.Conn()
+	c.Read(make([]byte, 2))
+
+	n, err := c.Write([]byte{0x27, 0x02, 0x03})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, 2).Write(newConnTestChunk(0x27, 0x02, 0x03))
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
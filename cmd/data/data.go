@@ -2,6 +2,7 @@ package data
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/WatchBeam/rtmp/chunk"
 )
@@ -10,14 +11,55 @@ var (
 	ErrControlMissing = errors.New("rtmp/data: missing control byte")
 )
 
+// Kind is the kind of a frame of Data: either audio, video, or script data
+// (such as metadata). It is derived from the message type ID of the chunk that
+// the Data was read from.
+type Kind byte
+
+const (
+	// AudioKind is the Kind of Data sent with message type ID 8.
+	AudioKind Kind = Kind(AudioTypeId)
+	// VideoKind is the Kind of Data sent with message type ID 9.
+	VideoKind Kind = Kind(VideoTypeId)
+	// ScriptKind is the Kind of Data sent with message type ID 18.
+	ScriptKind Kind = Kind(DataFrameTypeId)
+)
+
+// String implements fmt.Stringer.
+func (k Kind) String() string {
+	switch k {
+	case AudioKind:
+		return "audio"
+	case VideoKind:
+		return "video"
+	case ScriptKind:
+		return "script"
+	}
+
+	return fmt.Sprintf("unknown (%v)", byte(k))
+}
+
 // Data represents a single frame of data coming over the RTMP chunk stream.
 // A Data knows about its RTMP chunk's Type ID, as well as how to read itself
 // from a slice of bytes.
+//
+// Reading a Data does not copy the payload of the chunk that it was read from:
+// the Data retains the chunk's header and payload, so that chunk must not be
+// released (see chunk.Chunk.Release) while the Data is in use. Stream never
+// releases the chunks it parses, so Data read by it owns its payload, whether
+// or not the chunk.Reader pools payloads. Code that shares a Data between
+// goroutines which may modify it should hand each its own Clone.
 type Data interface {
 	// Id returns the type ID as a byte that is associated with this frame
 	// of Data. This should be equivalent to the ID found in
 	// Chunk.Header.MessageHeader.TypeId.
 	Id() byte
+	// Kind returns the Kind of this frame of Data, which corresponds to
+	// the message type ID returned by Id().
+	Kind() Kind
+	// IsKeyframe returns whether or not this frame of Data is a video
+	// keyframe. It is always false for audio and script data.
+	IsKeyframe() bool
 
 	// Read is a destructive operation which reads data into this chunk
 	// stream by feeding off of the given byte slice. In normal operation,
@@ -28,6 +70,11 @@ type Data interface {
 	// Data that this method belongs to in a writeable *chunk.Chunk, or an
 	// error if the data was unable to be marshaled.
 	Marshal() (*chunk.Chunk, error)
+
+	// Clone returns a deep copy of this frame of Data, whose header and
+	// payload share no memory with the original, so that either may be
+	// modified without affecting the other.
+	Clone() Data
 }
 
 // data is a simple implementation of part of the Data interface.
@@ -65,6 +112,37 @@ func (d *data) Control() byte { return d.data[0] }
 // Payload represents the actual data encoded in each Data frame.
 func (d *data) Payload() []byte { return d.data[1:] }
 
+// Timestamp returns the timestamp of the chunk that this frame was read from,
+// in milliseconds, or zero if it was read without a header.
+func (d *data) Timestamp() uint32 {
+	if d.header == nil {
+		return 0
+	}
+
+	return d.header.Timestamp()
+}
+
+// SetTimestamp sets the timestamp written when this frame is marshaled (see
+// Marshal) to `ts`, in milliseconds.
+func (d *data) SetTimestamp(ts uint32) {
+	if d.header == nil {
+		d.header = new(chunk.Header)
+	}
+
+	d.header.SetTimestamp(ts)
+}
+
+// clone returns a deep copy of this data, copying its header and payload.
+func (d *data) clone() data {
+	c := data{data: append([]byte(nil), d.data...)}
+	if d.header != nil {
+		h := *d.header
+		c.header = &h
+	}
+
+	return c
+}
+
 // Marshal implements the Data.Marshal, using the same header that was sent
 // during the original read.
 func (d *data) Marshal() (*chunk.Chunk, error) {
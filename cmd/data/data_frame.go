@@ -2,49 +2,165 @@ package data
 
 import (
 	"bytes"
+	"io"
 
-	"github.com/WatchBeam/amf0"
-	"github.com/WatchBeam/amf0/encoding"
+	amf "github.com/WatchBeam/rtmp/amf0"
 	"github.com/WatchBeam/rtmp/chunk"
 )
 
+const (
+	DataFrameTypeId byte = 0x12
+)
+
 // DataFrame encapsulates the "@setDataFrame" type sent over the Data stream.
 type DataFrame struct {
 	// Header contains the "@setDataFrame" keyword.
 	Header string
 	// Type is the sub-type of the data frame packet.
 	Type string
-	// Arguments are the arguments that were sent in the packet.
-	Arguments *amf0.Array
+	// Arguments are the arguments that were sent in the packet, keyed by
+	// their name. Their values are as decoded by the amf0 package of the
+	// github.com/WatchBeam/rtmp repository.
+	Arguments amf.ECMAArray
 }
 
 var _ Data = new(DataFrame)
 
 // Id implements Data.Id.
-func (d *DataFrame) Id() byte { return 0x12 }
+func (d *DataFrame) Id() byte { return DataFrameTypeId }
+
+// Kind implements Data.Kind.
+func (d *DataFrame) Kind() Kind { return ScriptKind }
+
+// IsKeyframe implements Data.IsKeyframe. A DataFrame is never a keyframe.
+func (d *DataFrame) IsKeyframe() bool { return false }
+
+// Clone implements Data.Clone. The clone is made by marshaling this DataFrame,
+// and reading the result into a new one, so its arguments share no values with
+// the original. If this DataFrame cannot be marshaled, the clone holds its
+// header and type, and no arguments.
+func (d *DataFrame) Clone() Data {
+	clone := &DataFrame{
+		Header:    d.Header,
+		Type:      d.Type,
+		Arguments: make(amf.ECMAArray),
+	}
+
+	if c, err := d.Marshal(); err == nil {
+		clone.Read(c)
+	}
+
+	return clone
+}
 
-// Read implements Data.Read. It uses the standard amf0-style procedure to
-// unmarshal the amf0 encoded data.
+// Metadata returns the *Metadata carried by this DataFrame, and whether or not
+// it carries any. Only "@setDataFrame" frames of the "onMetaData" type carry
+// metadata.
+func (d *DataFrame) Metadata() (*Metadata, bool) {
+	if d.Header != SetDataFrameHeader || d.Type != OnMetaDataType {
+		return nil, false
+	}
+
+	return NewMetadata(d.Arguments), true
+}
+
+// Read implements Data.Read. The data frame is decoded using this repository's
+// amf0 package, which reads the arguments whether they were sent as an ECMA
+// array (as OBS and FFmpeg do), or as an object. If no arguments were sent,
+// Arguments is left empty. Any data following the arguments is skipped.
 func (d *DataFrame) Read(c *chunk.Chunk) error {
-	return encoding.Unmarshal(bytes.NewReader(c.Data), d)
+	return d.read(c, false)
 }
 
-// Marshal implements the Data.Marshal function.
+// readStrict implements strictReader.readStrict. It reads the data frame as
+// Read does, but returns an error wrapping amf0.ErrTrailingData (from the
+// github.com/WatchBeam/rtmp/amf0 package) if any data follows its arguments.
+func (d *DataFrame) readStrict(c *chunk.Chunk) error {
+	return d.read(c, true)
+}
+
+// read reads the data frame held in the given chunk, rejecting any data
+// following its arguments if strict is true.
+func (d *DataFrame) read(c *chunk.Chunk, strict bool) error {
+	r := bytes.NewReader(c.Data)
+
+	dec := amf.NewDecoder(r)
+	if err := dec.Decode(&d.Header); err != nil {
+		return err
+	}
+	if err := dec.Decode(&d.Type); err != nil {
+		return err
+	}
+
+	var args interface{}
+	if err := dec.Decode(&args); err != nil && err != io.EOF {
+		return err
+	}
+
+	if strict && r.Len() > 0 {
+		offset := len(c.Data) - r.Len()
+
+		return &amf.ParseError{
+			Offset:   int64(offset),
+			Expected: "end of data",
+			Actual:   amf.Marker(c.Data[offset]).String(),
+			Err:      amf.ErrTrailingData,
+		}
+	}
+
+	d.Arguments = make(amf.ECMAArray)
+	if p, ok := pairs(args); ok {
+		for k, v := range p {
+			d.Arguments[k] = v
+		}
+	}
+
+	return nil
+}
+
+// Marshal implements the Data.Marshal function. The data frame is encoded using
+// this repository's amf0 package, so that strings longer than 65535 bytes are
+// written as long strings, rather than truncated. The arguments are written as
+// an ECMA array, in order of their keys, and are omitted if they are nil.
 func (d *DataFrame) Marshal() (*chunk.Chunk, error) {
-	m, err := encoding.Marshal(d)
-	if err != nil {
+	buf := new(bytes.Buffer)
+	enc := amf.NewEncoder(buf)
+
+	if err := enc.Encode(d.Header); err != nil {
 		return nil, err
 	}
+	if err := enc.Encode(d.Type); err != nil {
+		return nil, err
+	}
+	if d.Arguments != nil {
+		if err := enc.Encode(d.Arguments); err != nil {
+			return nil, err
+		}
+	}
+	m := buf.Bytes()
 
 	return &chunk.Chunk{
 		Header: &chunk.Header{
 			BasicHeader: chunk.BasicHeader{0, 4},
 			MessageHeader: chunk.MessageHeader{
 				Length:   uint32(len(m)),
-				TypeId:   0x12,
+				TypeId:   DataFrameTypeId,
 				StreamId: 1,
 			},
 		},
 		Data: m,
 	}, nil
 }
+
+// pairs returns the key-value pairs of the given decoded object or ECMA array,
+// and whether or not it was one.
+func pairs(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v, true
+	case amf.ECMAArray:
+		return v, true
+	}
+
+	return nil, false
+}
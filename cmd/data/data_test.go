@@ -44,3 +44,35 @@ func TestDataDoesNotReadWhenMissingControl(t *testing.T) {
 
 	assert.Equal(t, ErrControlMissing, err)
 }
+
+func TestClonesDoNotShareMemoryWithTheOriginal(t *testing.T) {
+	c := &chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{Timestamp: 10, TypeId: 0x09},
+		},
+		Data: []byte{0x17, 0x01, 0x02},
+	}
+	v := new(Video)
+	assert.Nil(t, v.Read(c))
+
+	clone := v.Clone().(*Video)
+	assert.Equal(t, v, clone)
+
+	clone.data.data[1] = 0xff
+	clone.SetTimestamp(20)
+
+	assert.Equal(t, []byte{0x17, 0x01, 0x02}, v.data.data)
+	assert.Equal(t, []byte{0x17, 0x01, 0x02}, c.Data)
+	assert.EqualValues(t, 10, v.Timestamp())
+	assert.EqualValues(t, 20, clone.Timestamp())
+}
+
+func TestClonesOfDataWithoutAHeaderHaveNoHeader(t *testing.T) {
+	a := new(Audio)
+	assert.Nil(t, a.Read(&chunk.Chunk{Data: []byte{0xaf, 0x01}}))
+
+	clone := a.Clone().(*Audio)
+
+	assert.Nil(t, clone.header)
+	assert.Equal(t, a.data.data, clone.data.data)
+}
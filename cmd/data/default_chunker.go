@@ -0,0 +1,21 @@
+package data
+
+import "github.com/WatchBeam/rtmp/chunk"
+
+// DefaultChunker provides a default implementation of the Chunker interface,
+// which chunks Data using its own Marshal function.
+type DefaultChunker struct{}
+
+var _ Chunker = new(DefaultChunker)
+
+// NewChunker returns a new instance of the Chunker type, using the
+// DefaultChunker as its implementation.
+func NewChunker() Chunker {
+	return &DefaultChunker{}
+}
+
+// Chunk implements the Chunk function in the Chunker interface, by returning
+// the result of calling Marshal on the given Data.
+func (c *DefaultChunker) Chunk(d Data) (*chunk.Chunk, error) {
+	return d.Marshal()
+}
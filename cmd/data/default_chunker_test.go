@@ -0,0 +1,25 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultChunkerMarshalsTheData(t *testing.T) {
+	ch := &chunk.Chunk{
+		Header: new(chunk.Header),
+		Data:   []byte{0x0, 0x1},
+	}
+
+	d := new(MockData)
+	d.On("Marshal").Return(ch, nil).Once()
+
+	c, err := data.NewChunker().Chunk(d)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ch, c)
+	d.AssertExpectations(t)
+}
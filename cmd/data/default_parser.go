@@ -3,20 +3,28 @@ package data
 import (
 	"fmt"
 
-	"github.com/WatchBeam/amf0"
+	amf "github.com/WatchBeam/rtmp/amf0"
 	"github.com/WatchBeam/rtmp/chunk"
 )
 
 var (
 	// DefaultParser is a singleton instance of the Parser type (using the
 	// SimpleParser type as implementation) that contains references to both
-	// Data implementations: Audio and Video.
-	DefaultParser = NewParser(
+	// Data implementations: Audio and Video. It is lenient (see
+	// SimpleParser.SetStrict).
+	DefaultParser = NewDefaultParser()
+)
+
+// NewDefaultParser returns a new, lenient *SimpleParser understanding the same
+// Data implementations as DefaultParser, so that its mode may be changed
+// without affecting DefaultParser.
+func NewDefaultParser() *SimpleParser {
+	return NewParser(
 		func() Data { return &Audio{} },
 		func() Data { return &Video{} },
-		func() Data { return &DataFrame{Arguments: amf0.NewArray()} },
+		func() Data { return &DataFrame{Arguments: make(amf.ECMAArray)} },
 	)
-)
+}
 
 // DataFactory is a factory type that produces new instances of a given Data
 // type.
@@ -25,10 +33,22 @@ type DataFactory func() Data
 // SimpleParser provides a default implementation of the Parser eype.
 type SimpleParser struct {
 	typs map[byte]DataFactory
+	// strict is true if the parser rejects Data which deviates from the
+	// specification (see SetStrict).
+	strict bool
+}
+
+// strictReader is implemented by Data which may deviate from the specification
+// in ways that are recoverable, such as by carrying trailing data. Strict
+// parsers read such Data with readStrict, rather than Read, which returns an
+// error for any deviation instead.
+type strictReader interface {
+	readStrict(c *chunk.Chunk) error
 }
 
 // NewParser creates and returns an instance of the *SimpleParser type. It is
-// initialized with the given Data implementations.
+// initialized with the given Data implementations, and is lenient (see
+// SetStrict).
 func NewParser(factories ...DataFactory) *SimpleParser {
 	p := &SimpleParser{
 		typs: make(map[byte]DataFactory),
@@ -43,7 +63,20 @@ func NewParser(factories ...DataFactory) *SimpleParser {
 
 var _ Parser = new(SimpleParser)
 
-// Parse implements the Parser.Parser function.
+// SetStrict sets whether the parser is strict, or lenient. Strict parsers return
+// an error for any Data which deviates from the specification, such as a
+// DataFrame carrying data after its arguments. Lenient parsers instead skip
+// such data, favoring interoperability with clients which send slightly
+// malformed Data over correctness.
+//
+// SetStrict is not safe to call while the parser is in use.
+func (p *SimpleParser) SetStrict(strict bool) {
+	p.strict = strict
+}
+
+// Parse implements the Parser.Parser function. The Data implementation is
+// chosen by the message type ID of the given chunk, so the Kind of the
+// returned Data always corresponds to it.
 func (p *SimpleParser) Parse(c *chunk.Chunk) (Data, error) {
 	d := p.New(c.Header.MessageHeader.TypeId)
 	if d == nil {
@@ -51,7 +84,13 @@ func (p *SimpleParser) Parse(c *chunk.Chunk) (Data, error) {
 			c.Header.MessageHeader.TypeId)
 	}
 
-	if err := d.Read(c); err != nil {
+	var err error
+	if r, ok := d.(strictReader); ok && p.strict {
+		err = r.readStrict(c)
+	} else {
+		err = d.Read(c)
+	}
+	if err != nil {
 		return nil, err
 	}
 
@@ -3,6 +3,7 @@ package data_test
 import (
 	"testing"
 
+	amf "github.com/WatchBeam/rtmp/amf0"
 	"github.com/WatchBeam/rtmp/chunk"
 	"github.com/WatchBeam/rtmp/cmd/data"
 	"github.com/stretchr/testify/assert"
@@ -28,6 +29,38 @@ func TestParseParsesChunks(t *testing.T) {
 	assert.Equal(t, byte(0x08), d.Id())
 }
 
+func TestParseSetsTheKindFromTheMessageTypeId(t *testing.T) {
+	for _, test := range []struct {
+		TypeId byte
+		Data   []byte
+		Kind   data.Kind
+	}{
+		{0x08, []byte{0xaf, 0x1}, data.AudioKind},
+		{0x09, []byte{0x17, 0x1}, data.VideoKind},
+		{0x12, OnMetaData, data.ScriptKind},
+	} {
+		d, err := data.DefaultParser.Parse(&chunk.Chunk{
+			Header: &chunk.Header{
+				MessageHeader: chunk.MessageHeader{
+					TypeId: test.TypeId,
+				},
+			},
+			Data: test.Data,
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, test.Kind, d.Kind())
+		assert.Equal(t, test.TypeId, byte(d.Kind()))
+	}
+}
+
+func TestKindsHaveStringRepresentations(t *testing.T) {
+	assert.Equal(t, "audio", data.AudioKind.String())
+	assert.Equal(t, "video", data.VideoKind.String())
+	assert.Equal(t, "script", data.ScriptKind.String())
+	assert.Equal(t, "unknown (1)", data.Kind(0x01).String())
+}
+
 func TestParseErrsChunksWithMismatchedTypeIds(t *testing.T) {
 	d, err := data.DefaultParser.Parse(&chunk.Chunk{
 		Header: &chunk.Header{
@@ -73,3 +106,34 @@ func TestParseReturnsNilWhenNoMatchingDataExists(t *testing.T) {
 
 	assert.Nil(t, d)
 }
+
+// TrailingOnMetaData is an "onMetaData" DataFrame followed by a null.
+var TrailingOnMetaData = append(append([]byte{}, OnMetaData...), 0x05)
+
+func TestLenientParsersSkipTrailingData(t *testing.T) {
+	d, err := data.DefaultParser.Parse(
+		newDataChunk(data.DataFrameTypeId, TrailingOnMetaData))
+
+	assert.Nil(t, err)
+	_, ok := d.(*data.DataFrame).Metadata()
+	assert.True(t, ok)
+}
+
+func TestStrictParsersRejectTrailingData(t *testing.T) {
+	p := data.NewDefaultParser()
+	p.SetStrict(true)
+
+	d, err := p.Parse(newDataChunk(data.DataFrameTypeId, TrailingOnMetaData))
+
+	assert.Nil(t, d)
+	assert.Equal(t, &amf.ParseError{
+		Offset:   int64(len(OnMetaData)),
+		Expected: "end of data",
+		Actual:   "null",
+		Err:      amf.ErrTrailingData,
+	}, err)
+
+	d, err = p.Parse(newDataChunk(data.DataFrameTypeId, OnMetaData))
+	assert.Nil(t, err)
+	assert.NotNil(t, d)
+}
@@ -0,0 +1,38 @@
+package data
+
+// FourCC is the four character code which identifies the codec of audio or
+// video sent with an Enhanced RTMP extended header, in place of the codec ID
+// of the FLV format.
+type FourCC string
+
+const (
+	// AV1FourCC identifies AV1 video.
+	AV1FourCC FourCC = "av01"
+	// VP9FourCC identifies VP9 video.
+	VP9FourCC FourCC = "vp09"
+	// HEVCFourCC identifies HEVC (H.265) video.
+	HEVCFourCC FourCC = "hvc1"
+
+	// OpusFourCC identifies Opus audio.
+	OpusFourCC FourCC = "Opus"
+	// FLACFourCC identifies FLAC audio.
+	FLACFourCC FourCC = "fLaC"
+	// AC3FourCC identifies AC-3 audio.
+	AC3FourCC FourCC = "ac-3"
+	// EAC3FourCC identifies Enhanced AC-3 audio.
+	EAC3FourCC FourCC = "ec-3"
+	// MP3FourCC identifies MP3 audio.
+	MP3FourCC FourCC = ".mp3"
+	// AACFourCC identifies AAC audio.
+	AACFourCC FourCC = "mp4a"
+)
+
+// fourCCAt returns the FourCC held at the given offset of `b`, or false if `b`
+// is too short to hold one there.
+func fourCCAt(b []byte, offset int) (FourCC, bool) {
+	if len(b) < offset+4 {
+		return "", false
+	}
+
+	return FourCC(b[offset : offset+4]), true
+}
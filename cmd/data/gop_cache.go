@@ -0,0 +1,108 @@
+package data
+
+import "sync"
+
+const (
+	// DefaultGOPCacheSize is the default maximum number of payload bytes
+	// held in a GOPCache.
+	DefaultGOPCacheSize = 8 * 1024 * 1024
+)
+
+// GOPCache holds the most recent group of pictures (GOP) of a stream: the
+// latest video sequence header, followed by the last video keyframe and every
+// Audio and Video frame received since. A new subscriber primed with the
+// contents of a GOPCache is able to start decoding immediately, instead of
+// waiting for the next keyframe.
+//
+// The cache is reset on each keyframe. If the payloads held in the cache grow
+// beyond its maximum size, the cache is emptied until the next keyframe
+// arrives, since a partial GOP cannot be decoded.
+//
+// GOPCache is safe for concurrent use.
+type GOPCache struct {
+	// maxBytes is the maximum total size of the payloads in frames.
+	maxBytes int
+
+	// mu guards header, frames, and bytes.
+	mu sync.Mutex
+	// header is the latest video sequence header, if any.
+	header Data
+	// frames holds the last keyframe, and every frame received since.
+	frames []Data
+	// bytes is the total size of the payloads in frames.
+	bytes int
+}
+
+// NewGOPCache returns a new, empty *GOPCache holding at most `maxBytes` bytes
+// of payload.
+func NewGOPCache(maxBytes int) *GOPCache {
+	return &GOPCache{maxBytes: maxBytes}
+}
+
+// Add adds the given Data to the cache. Video sequence headers replace the
+// previous sequence header, keyframes reset the cache, and all other Audio and
+// Video frames are appended to it, provided that a keyframe has been received.
+// Any other Data is ignored.
+func (g *GOPCache) Add(d Data) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch v := d.(type) {
+	case *Video:
+		if v.isSequenceHeader() {
+			g.header = v
+			return
+		}
+
+		if v.IsKeyframe() {
+			g.frames = g.frames[:0]
+			g.bytes = 0
+		}
+	case *Audio:
+	default:
+		return
+	}
+
+	if len(g.frames) == 0 && !d.IsKeyframe() {
+		return
+	}
+
+	g.bytes += len(d.(payloader).Payload())
+	if g.bytes > g.maxBytes {
+		g.frames = g.frames[:0]
+		g.bytes = 0
+		return
+	}
+
+	g.frames = append(g.frames, d)
+}
+
+// Frames returns the contents of the cache in the order in which they should
+// be sent to a new subscriber: the video sequence header (if any), followed by
+// the last keyframe and every frame received since.
+func (g *GOPCache) Frames() []Data {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	frames := make([]Data, 0, len(g.frames)+1)
+	if g.header != nil {
+		frames = append(frames, g.header)
+	}
+
+	return append(frames, g.frames...)
+}
+
+// Reset empties the cache, including its video sequence header.
+func (g *GOPCache) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.header = nil
+	g.frames = nil
+	g.bytes = 0
+}
+
+// payloader is implemented by the Audio and Video types.
+type payloader interface {
+	Payload() []byte
+}
@@ -0,0 +1,98 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// AVC sequence header, keyframe, and interframe.
+	SequenceHeader = []byte{0x17, 0x00, 0x00, 0x00, 0x00, 0x01}
+	Keyframe       = []byte{0x17, 0x01, 0x00, 0x00, 0x00, 0x02, 0x03}
+	Interframe     = []byte{0x27, 0x01, 0x00, 0x00, 0x00, 0x04}
+	// AAC raw frame.
+	AudioFrame = []byte{0xaf, 0x01, 0x05}
+)
+
+func newVideo(payload []byte) *data.Video {
+	v := new(data.Video)
+	v.Read(&chunk.Chunk{Data: payload})
+
+	return v
+}
+
+func newAudio(payload []byte) *data.Audio {
+	a := new(data.Audio)
+	a.Read(&chunk.Chunk{Data: payload})
+
+	return a
+}
+
+func TestGOPCacheIgnoresFramesBeforeTheFirstKeyframe(t *testing.T) {
+	g := data.NewGOPCache(data.DefaultGOPCacheSize)
+
+	g.Add(newVideo(Interframe))
+	g.Add(newAudio(AudioFrame))
+
+	assert.Empty(t, g.Frames())
+}
+
+func TestGOPCacheHoldsTheSequenceHeaderAndCurrentGOP(t *testing.T) {
+	g := data.NewGOPCache(data.DefaultGOPCacheSize)
+
+	header := newVideo(SequenceHeader)
+	key := newVideo(Keyframe)
+	audio := newAudio(AudioFrame)
+	inter := newVideo(Interframe)
+
+	g.Add(header)
+	g.Add(key)
+	g.Add(audio)
+	g.Add(inter)
+	g.Add(new(data.DataFrame))
+
+	assert.Equal(t, []data.Data{header, key, audio, inter}, g.Frames())
+}
+
+func TestGOPCacheResetsOnEachKeyframe(t *testing.T) {
+	g := data.NewGOPCache(data.DefaultGOPCacheSize)
+
+	g.Add(newVideo(Keyframe))
+	g.Add(newVideo(Interframe))
+
+	key := newVideo(Keyframe)
+	g.Add(key)
+
+	assert.Equal(t, []data.Data{key}, g.Frames())
+}
+
+func TestGOPCacheEmptiesWhenFull(t *testing.T) {
+	g := data.NewGOPCache(len(Keyframe) + len(Interframe) - 2)
+
+	g.Add(newVideo(Keyframe))
+	g.Add(newVideo(Interframe))
+	assert.Len(t, g.Frames(), 2)
+
+	g.Add(newVideo(Interframe))
+	assert.Empty(t, g.Frames())
+
+	g.Add(newVideo(Interframe))
+	assert.Empty(t, g.Frames())
+
+	key := newVideo(Keyframe)
+	g.Add(key)
+	assert.Equal(t, []data.Data{key}, g.Frames())
+}
+
+func TestGOPCacheReset(t *testing.T) {
+	g := data.NewGOPCache(data.DefaultGOPCacheSize)
+	g.Add(newVideo(SequenceHeader))
+	g.Add(newVideo(Keyframe))
+
+	g.Reset()
+
+	assert.Empty(t, g.Frames())
+}
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrAlreadyPublished is returned by Hub.Publish when a Stream is
+	// already being published under the given key.
+	ErrAlreadyPublished = errors.New("rtmp/data: stream key already published")
+)
+
+// Hub is a registry of the streams published to a server, mapping each stream
+// key to its publisher, and to the players of that stream, so that a call to
+// play may find the matching publish.
+//
+// Players may arrive before the publisher of their stream: they wait, and
+// start receiving Data as soon as a Stream is published under their key. Once
+// the publisher leaves (its Stream is closed), every player of its key is
+// stopped, and the key may be published again.
+//
+// New players of a published stream are primed with its latest metadata,
+// sequence headers, and GOP cache (see PlaybackStart), and, as with a Relay,
+// players that fall behind by more than the maximum lag (see SetMaxLag) are
+// dropped, so that a slow player does not stall the publisher, or any other
+// player.
+type Hub struct {
+	// mu guards maxLag, keys, and next.
+	mu sync.Mutex
+	// maxLag is the number of Data that a player may fall behind by before
+	// it is dropped.
+	maxLag int
+	// keys maps each stream key that is published, or waited on by a
+	// player, to its publication.
+	keys map[string]*publication
+	// next is the ID of the next player to be added.
+	next int
+}
+
+// publication is a single stream key registered with a Hub.
+type publication struct {
+	// src is the Stream published under the key, or nil if its players
+	// are waiting for a publisher.
+	src *Stream
+	// metadata is the latest "onMetaData" DataFrame received from src, if
+	// any.
+	metadata Data
+	// players maps player IDs to the channel that Data is sent over.
+	players map[int]chan Data
+}
+
+// NewHub returns a new *Hub with no published streams, and no players.
+func NewHub() *Hub {
+	return &Hub{
+		maxLag: DefaultMaxLag,
+		keys:   make(map[string]*publication),
+	}
+}
+
+// SetMaxLag sets the number of Data that players added after this call may fall
+// behind by before they are dropped.
+func (h *Hub) SetMaxLag(maxLag int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.maxLag = maxLag
+}
+
+// Publish publishes the given Stream under the given key, forwarding each Data
+// received from it to every player of the key, including those that were
+// already waiting, until the Stream is closed. Once it is, every player of the
+// key is stopped, and the key is unregistered. If a Stream is already published
+// under the key, ErrAlreadyPublished is returned instead.
+//
+// Once a Stream is published, its In() channel should not be read from
+// anywhere else.
+func (h *Hub) Publish(key string, src *Stream) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p, ok := h.keys[key]
+	if !ok {
+		p = &publication{players: make(map[int]chan Data)}
+		h.keys[key] = p
+	} else if p.src != nil {
+		return ErrAlreadyPublished
+	}
+	p.src = src
+
+	start := PlaybackStart(nil, src)
+	for id, frames := range p.players {
+		for _, d := range start {
+			if !h.send(p, id, frames, d) {
+				break
+			}
+		}
+	}
+
+	go h.forward(key, p)
+
+	return nil
+}
+
+// Play adds a player of the stream published under the given key, returning
+// the channel over which its Data is received, and a function which stops
+// playing. If the key is not yet published, the player waits until it is. The
+// channel is closed once the player is stopped, either by calling the returned
+// function, by falling too far behind, or because the publisher left.
+func (h *Hub) Play(key string) (<-chan Data, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p, ok := h.keys[key]
+	if !ok {
+		p = &publication{players: make(map[int]chan Data)}
+		h.keys[key] = p
+	}
+
+	var start []Data
+	if p.src != nil {
+		start = PlaybackStart(p.metadata, p.src)
+	}
+
+	frames := make(chan Data, len(start)+h.maxLag)
+	for _, d := range start {
+		frames <- d
+	}
+
+	id := h.next
+	h.next++
+	p.players[id] = frames
+
+	return frames, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.stop(key, p, id)
+	}
+}
+
+// Published returns whether or not a Stream is published under the given key.
+func (h *Hub) Published(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p, ok := h.keys[key]
+	return ok && p.src != nil
+}
+
+// Metadata returns the latest "onMetaData" DataFrame (see DataFrame.Metadata)
+// received from the Stream published under the given key, and whether or not
+// one has been received at all. It is sent to each new player before any media
+// (see PlaybackStart).
+func (h *Hub) Metadata(key string) (Data, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if p, ok := h.keys[key]; ok && p.metadata != nil {
+		return p.metadata, true
+	}
+	return nil, false
+}
+
+// Players returns the number of players of the given key, including those
+// waiting for it to be published.
+func (h *Hub) Players(key string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if p, ok := h.keys[key]; ok {
+		return len(p.players)
+	}
+	return 0
+}
+
+// send sends the given Data to the player with the given ID, dropping the
+// player if it has fallen too far behind. It returns whether or not the Data
+// was sent, and must be called while holding mu.
+func (h *Hub) send(p *publication, id int, frames chan Data, d Data) bool {
+	select {
+	case frames <- d:
+		return true
+	default:
+		delete(p.players, id)
+		close(frames)
+
+		return false
+	}
+}
+
+// stop stops the player with the given ID of the given publication, if it
+// exists, and unregisters the key if it has neither a publisher nor any
+// players left. It must be called while holding mu.
+func (h *Hub) stop(key string, p *publication, id int) {
+	if frames, ok := p.players[id]; ok {
+		delete(p.players, id)
+		close(frames)
+	}
+
+	if p.src == nil && len(p.players) == 0 && h.keys[key] == p {
+		delete(h.keys, key)
+	}
+}
+
+// forward forwards each Data received from the Stream of the given
+// publication to each of its players, caching its metadata, until the Stream is
+// closed. Every player is then stopped, and the key is unregistered.
+//
+// forward runs within its own goroutine.
+func (h *Hub) forward(key string, p *publication) {
+	for d := range p.src.In() {
+		h.mu.Lock()
+		if f, ok := d.(*DataFrame); ok &&
+			f.Header == SetDataFrameHeader && f.Type == OnMetaDataType {
+
+			p.metadata = f
+		}
+		for id, frames := range p.players {
+			h.send(p, id, frames, d)
+		}
+		h.mu.Unlock()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, frames := range p.players {
+		delete(p.players, id)
+		close(frames)
+	}
+	if h.keys[key] == p {
+		delete(h.keys, key)
+	}
+}
@@ -0,0 +1,153 @@
+package data_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+// receive returns the payload of the next Data received over the given
+// channel, failing the test if none is received in time.
+func receive(t *testing.T, frames <-chan data.Data) []byte {
+	select {
+	case d, ok := <-frames:
+		if !ok {
+			t.Fatal("rtmp/data: player was stopped")
+		}
+
+		c, err := d.Marshal()
+		assert.Nil(t, err)
+		return c.Data
+	case <-time.After(time.Second):
+		t.Fatal("rtmp/data: no Data was received")
+	}
+
+	return nil
+}
+
+// assertStopped asserts that the given channel is closed once any Data sent
+// over it is drained.
+func assertStopped(t *testing.T, frames <-chan data.Data) {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-frames:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("rtmp/data: player was not stopped")
+		}
+	}
+}
+
+func TestHubForwardsToPlayersWaitingForThePublisher(t *testing.T) {
+	h := data.NewHub()
+
+	frames, _ := h.Play("key")
+	assert.False(t, h.Published("key"))
+	assert.Equal(t, 1, h.Players("key"))
+
+	s := newRelayTestStream()
+	go s.Recv()
+	defer s.Close()
+
+	assert.Nil(t, h.Publish("key", s))
+	assert.True(t, h.Published("key"))
+
+	s.Chunks() <- newVideoChunk(Keyframe)
+
+	assert.Equal(t, Keyframe, receive(t, frames))
+}
+
+func TestHubPrimesPlayersWithTheGOP(t *testing.T) {
+	s := data.NewBufferedStream(make(chan *chunk.Chunk), chunk.NoopWriter, 0)
+	s.SetGOPCacheSize(data.DefaultGOPCacheSize)
+	go s.Recv()
+	defer s.Close()
+
+	h := data.NewHub()
+	waiting, _ := h.Play("key")
+	assert.Nil(t, h.Publish("key", s))
+
+	s.Chunks() <- newVideoChunk(SequenceHeader)
+	s.Chunks() <- newVideoChunk(Keyframe)
+	assert.Equal(t, SequenceHeader, receive(t, waiting))
+	assert.Equal(t, Keyframe, receive(t, waiting))
+
+	frames, _ := h.Play("key")
+
+	assert.Equal(t, SequenceHeader, receive(t, frames))
+	assert.Equal(t, Keyframe, receive(t, frames))
+}
+
+func TestHubRejectsPublishingAKeyTwice(t *testing.T) {
+	s := newRelayTestStream()
+	go s.Recv()
+	defer s.Close()
+
+	h := data.NewHub()
+
+	assert.Nil(t, h.Publish("key", s))
+	assert.Equal(t, data.ErrAlreadyPublished,
+		h.Publish("key", newRelayTestStream()))
+	assert.Nil(t, h.Publish("other", newRelayTestStream()))
+}
+
+func TestHubStopsPlayersWhenThePublisherLeaves(t *testing.T) {
+	s := newRelayTestStream()
+	go s.Recv()
+
+	h := data.NewHub()
+	assert.Nil(t, h.Publish("key", s))
+	frames, stop := h.Play("key")
+
+	s.Close()
+
+	assertStopped(t, frames)
+	assert.False(t, h.Published("key"))
+	assert.Equal(t, 0, h.Players("key"))
+
+	stop()
+
+	republished := newRelayTestStream()
+	go republished.Recv()
+	defer republished.Close()
+
+	assert.Nil(t, h.Publish("key", republished))
+}
+
+func TestHubStopsPlayers(t *testing.T) {
+	h := data.NewHub()
+
+	frames, stop := h.Play("key")
+	stop()
+	stop()
+
+	assertStopped(t, frames)
+	assert.Equal(t, 0, h.Players("key"))
+}
+
+func TestHubDropsSlowPlayers(t *testing.T) {
+	s := newRelayTestStream()
+	go s.Recv()
+	defer s.Close()
+
+	h := data.NewHub()
+	h.SetMaxLag(1)
+
+	slow, _ := h.Play("key")
+	assert.Nil(t, h.Publish("key", s))
+
+	for i := 0; i < 3; i++ {
+		s.Chunks() <- newVideoChunk(Keyframe)
+	}
+
+	assert.Eventually(t, func() bool { return h.Players("key") == 0 },
+		time.Second, time.Millisecond)
+	assertStopped(t, slow)
+	assert.True(t, h.Published("key"))
+}
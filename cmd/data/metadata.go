@@ -0,0 +1,96 @@
+package data
+
+import amf "github.com/WatchBeam/rtmp/amf0"
+
+const (
+	// SetDataFrameHeader is the Header of data frames sent by a publisher
+	// to set the data frame (usually the metadata) of its stream.
+	SetDataFrameHeader = "@setDataFrame"
+	// OnMetaDataType is the Type of data frames carrying the metadata of
+	// a stream.
+	OnMetaDataType = "onMetaData"
+)
+
+// Metadata describes the characteristics of a stream, as sent by its publisher
+// in an "onMetaData" data frame, before any media.
+//
+// Fields that were not sent, or were sent with an unexpected type, are left as
+// zero. All of the properties that were sent are available in Raw.
+type Metadata struct {
+	// Width is the width of the video, in pixels.
+	Width float64
+	// Height is the height of the video, in pixels.
+	Height float64
+	// VideoCodecID is the ID of the video codec, as defined in the FLV
+	// specification (7 for AVC).
+	VideoCodecID float64
+	// AudioCodecID is the ID of the audio codec, as defined in the FLV
+	// specification (10 for AAC).
+	AudioCodecID float64
+	// FrameRate is the number of video frames per second.
+	FrameRate float64
+
+	// Raw holds every property of the metadata, keyed by its name.
+	// Strings, numbers, and booleans are decoded into their Go
+	// counterparts, nested objects, typed objects, and ECMA arrays into
+	// map[string]interface{}, strict arrays into []interface{}, dates
+	// into time.Time, and null and undefined values into nil.
+	Raw map[string]interface{}
+}
+
+// NewMetadata returns the *Metadata described by the given arguments of an
+// "onMetaData" data frame.
+func NewMetadata(args amf.ECMAArray) *Metadata {
+	m := &Metadata{Raw: decodePairs(args)}
+
+	m.Width = m.number("width")
+	m.Height = m.number("height")
+	m.VideoCodecID = m.number("videocodecid")
+	m.AudioCodecID = m.number("audiocodecid")
+	m.FrameRate = m.number("framerate")
+
+	return m
+}
+
+// number returns the raw property with the given key, or zero if it is missing
+// or not a number.
+func (m *Metadata) number(key string) float64 {
+	n, _ := m.Raw[key].(float64)
+	return n
+}
+
+// decodePairs converts the key-value pairs of an AMF0 object or ECMA array into
+// a map of their Go counterparts, as described by Metadata.Raw.
+func decodePairs(p map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(p))
+	for key, v := range p {
+		m[key] = decodeValue(v)
+	}
+
+	return m
+}
+
+// decodeValue converts a single value decoded by the amf0 package of the
+// github.com/WatchBeam/rtmp repository into its Go counterpart, as described by
+// Metadata.Raw.
+func decodeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return decodePairs(v)
+	case amf.ECMAArray:
+		return decodePairs(v)
+	case amf.TypedObject:
+		return decodePairs(v.Properties)
+	case []interface{}:
+		elems := make([]interface{}, len(v))
+		for i, elem := range v {
+			elems[i] = decodeValue(elem)
+		}
+
+		return elems
+	case amf.Undefined:
+		return nil
+	}
+
+	return v
+}
@@ -0,0 +1,256 @@
+package data_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	amf "github.com/WatchBeam/rtmp/amf0"
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// OnMetaData is an "@setDataFrame" data frame of the "onMetaData" type,
+	// describing a 1280x720, 30fps AVC/AAC stream.
+	OnMetaData = []byte{
+		0x02, 0x00, 0x0d, 0x40, 0x73, 0x65, 0x74, 0x44, 0x61, 0x74,
+		0x61, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x02, 0x00, 0x0a, 0x6f,
+		0x6e, 0x4d, 0x65, 0x74, 0x61, 0x44, 0x61, 0x74, 0x61, 0x08,
+		0x00, 0x00, 0x00, 0x06, 0x00, 0x05, 0x77, 0x69, 0x64, 0x74,
+		0x68, 0x00, 0x40, 0x94, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x00, 0x40,
+		0x86, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x76,
+		0x69, 0x64, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x63, 0x69,
+		0x64, 0x00, 0x40, 0x1c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x0c, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x63, 0x6f, 0x64,
+		0x65, 0x63, 0x69, 0x64, 0x00, 0x40, 0x24, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x09, 0x66, 0x72, 0x61, 0x6d, 0x65,
+		0x72, 0x61, 0x74, 0x65, 0x00, 0x40, 0x3e, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x07, 0x65, 0x6e, 0x63, 0x6f, 0x64,
+		0x65, 0x72, 0x02, 0x00, 0x03, 0x6f, 0x62, 0x73, 0x00, 0x00,
+		0x09,
+	}
+
+	// OBSMetaData is the "onMetaData" data frame sent by OBS, whose
+	// arguments are an ECMA array of 20 properties.
+	OBSMetaData = []byte{
+		0x02, 0x00, 0x0d, 0x40, 0x73, 0x65, 0x74, 0x44, 0x61, 0x74,
+		0x61, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x02, 0x00, 0x0a, 0x6f,
+		0x6e, 0x4d, 0x65, 0x74, 0x61, 0x44, 0x61, 0x74, 0x61, 0x08,
+		0x00, 0x00, 0x00, 0x14, 0x00, 0x08, 0x64, 0x75, 0x72, 0x61,
+		0x74, 0x69, 0x6f, 0x6e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x53,
+		0x69, 0x7a, 0x65, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x00,
+		0x40, 0x94, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06,
+		0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x00, 0x40, 0x86, 0x80,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x76, 0x69, 0x64,
+		0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x63, 0x69, 0x64, 0x00,
+		0x40, 0x1c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0d,
+		0x76, 0x69, 0x64, 0x65, 0x6f, 0x64, 0x61, 0x74, 0x61, 0x72,
+		0x61, 0x74, 0x65, 0x00, 0x40, 0xa3, 0x88, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x09, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x72,
+		0x61, 0x74, 0x65, 0x00, 0x40, 0x3e, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x0c, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x63,
+		0x6f, 0x64, 0x65, 0x63, 0x69, 0x64, 0x00, 0x40, 0x24, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0d, 0x61, 0x75, 0x64,
+		0x69, 0x6f, 0x64, 0x61, 0x74, 0x61, 0x72, 0x61, 0x74, 0x65,
+		0x00, 0x40, 0x64, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x0f, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x73, 0x61, 0x6d, 0x70,
+		0x6c, 0x65, 0x72, 0x61, 0x74, 0x65, 0x00, 0x40, 0xe7, 0x70,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0f, 0x61, 0x75, 0x64,
+		0x69, 0x6f, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x69,
+		0x7a, 0x65, 0x00, 0x40, 0x30, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x0d, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x63, 0x68,
+		0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x73, 0x00, 0x40, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x73, 0x74, 0x65,
+		0x72, 0x65, 0x6f, 0x01, 0x01, 0x00, 0x03, 0x32, 0x2e, 0x31,
+		0x01, 0x00, 0x00, 0x03, 0x33, 0x2e, 0x31, 0x01, 0x00, 0x00,
+		0x03, 0x34, 0x2e, 0x30, 0x01, 0x00, 0x00, 0x03, 0x34, 0x2e,
+		0x31, 0x01, 0x00, 0x00, 0x03, 0x35, 0x2e, 0x31, 0x01, 0x00,
+		0x00, 0x03, 0x37, 0x2e, 0x31, 0x01, 0x00, 0x00, 0x07, 0x65,
+		0x6e, 0x63, 0x6f, 0x64, 0x65, 0x72, 0x02, 0x00, 0x29, 0x6f,
+		0x62, 0x73, 0x2d, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x20,
+		0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x20, 0x28, 0x6c, 0x69,
+		0x62, 0x6f, 0x62, 0x73, 0x20, 0x76, 0x65, 0x72, 0x73, 0x69,
+		0x6f, 0x6e, 0x20, 0x32, 0x37, 0x2e, 0x32, 0x2e, 0x34, 0x29,
+		0x00, 0x00, 0x09,
+	}
+)
+
+func TestDataFramesExposeTheirMetadata(t *testing.T) {
+	d, err := data.DefaultParser.Parse(&chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: 0x12},
+		},
+		Data: OnMetaData,
+	})
+	assert.Nil(t, err)
+
+	m, ok := d.(*data.DataFrame).Metadata()
+
+	assert.True(t, ok)
+	assert.Equal(t, &data.Metadata{
+		Width:        1280,
+		Height:       720,
+		VideoCodecID: 7,
+		AudioCodecID: 10,
+		FrameRate:    30,
+		Raw: map[string]interface{}{
+			"width":        float64(1280),
+			"height":       float64(720),
+			"videocodecid": float64(7),
+			"audiocodecid": float64(10),
+			"framerate":    float64(30),
+			"encoder":      "obs",
+		},
+	}, m)
+}
+
+func TestDataFramesOfOtherTypesHaveNoMetadata(t *testing.T) {
+	d := &data.DataFrame{
+		Header: data.SetDataFrameHeader,
+		Type:   "onCuePoint",
+	}
+
+	m, ok := d.Metadata()
+
+	assert.False(t, ok)
+	assert.Nil(t, m)
+}
+
+func TestMetadataWithoutArgumentsIsEmpty(t *testing.T) {
+	m := data.NewMetadata(nil)
+
+	assert.Equal(t, float64(0), m.Width)
+	assert.Empty(t, m.Raw)
+}
+
+func TestDataFramesDecodeECMAArraysFromOBS(t *testing.T) {
+	d, err := data.DefaultParser.Parse(&chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: 0x12},
+		},
+		Data: OBSMetaData,
+	})
+	assert.Nil(t, err)
+
+	m, ok := d.(*data.DataFrame).Metadata()
+
+	assert.True(t, ok)
+	assert.Equal(t, &data.Metadata{
+		Width:        1280,
+		Height:       720,
+		VideoCodecID: 7,
+		AudioCodecID: 10,
+		FrameRate:    30,
+		Raw: map[string]interface{}{
+			"duration":        float64(0),
+			"fileSize":        float64(0),
+			"width":           float64(1280),
+			"height":          float64(720),
+			"videocodecid":    float64(7),
+			"videodatarate":   float64(2500),
+			"framerate":       float64(30),
+			"audiocodecid":    float64(10),
+			"audiodatarate":   float64(160),
+			"audiosamplerate": float64(48000),
+			"audiosamplesize": float64(16),
+			"audiochannels":   float64(2),
+			"stereo":          true,
+			"2.1":             false,
+			"3.1":             false,
+			"4.0":             false,
+			"4.1":             false,
+			"5.1":             false,
+			"7.1":             false,
+			"encoder":         "obs-output module (libobs version 27.2.4)",
+		},
+	}, m)
+}
+
+func TestDataFramesDecodeObjectArguments(t *testing.T) {
+	d, err := data.DefaultParser.Parse(&chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: 0x12},
+		},
+		Data: []byte{
+			0x02, 0x00, 0x0d, 0x40, 0x73, 0x65, 0x74, 0x44, 0x61, 0x74,
+			0x61, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x02, 0x00, 0x0a, 0x6f,
+			0x6e, 0x4d, 0x65, 0x74, 0x61, 0x44, 0x61, 0x74, 0x61, 0x03,
+			0x00, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x00, 0x40, 0x94,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x09,
+		},
+	})
+	assert.Nil(t, err)
+
+	m, ok := d.(*data.DataFrame).Metadata()
+
+	assert.True(t, ok)
+	assert.Equal(t, float64(1280), m.Width)
+	assert.Len(t, m.Raw, 1)
+}
+
+func TestDataFrameClonesDoNotShareArguments(t *testing.T) {
+	d, err := data.DefaultParser.Parse(&chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: 0x12},
+		},
+		Data: OnMetaData,
+	})
+	assert.Nil(t, err)
+
+	clone := d.Clone().(*data.DataFrame)
+	assert.Equal(t, d, clone)
+
+	clone.Type = "onCuePoint"
+	clone.Arguments["width"] = float64(640)
+
+	m, ok := d.(*data.DataFrame).Metadata()
+	assert.True(t, ok)
+	assert.EqualValues(t, 1280, m.Width)
+}
+
+func TestDataFramesRoundTrip(t *testing.T) {
+	d, err := data.DefaultParser.Parse(&chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: 0x12},
+		},
+		Data: OnMetaData,
+	})
+	assert.Nil(t, err)
+
+	c, err := d.Marshal()
+	assert.Nil(t, err)
+
+	rt, err := data.DefaultParser.Parse(c)
+	assert.Nil(t, err)
+
+	assert.Equal(t, d, rt)
+}
+
+func TestDataFramesEncodeLongStrings(t *testing.T) {
+	description := strings.Repeat("a", 0x10000)
+
+	d := &data.DataFrame{
+		Header:    data.SetDataFrameHeader,
+		Type:      data.OnMetaDataType,
+		Arguments: amf.ECMAArray{"description": description},
+	}
+
+	c, err := d.Marshal()
+	assert.Nil(t, err)
+	assert.True(t, bytes.Contains(c.Data, []byte{
+		'd', 'e', 's', 'c', 'r', 'i', 'p', 't', 'i', 'o', 'n',
+		0x0c, 0x00, 0x01, 0x00, 0x00,
+	}))
+
+	rt, err := data.DefaultParser.Parse(c)
+	assert.Nil(t, err)
+
+	m, ok := rt.(*data.DataFrame).Metadata()
+	assert.True(t, ok)
+	assert.Equal(t, description, m.Raw["description"])
+}
@@ -0,0 +1,159 @@
+package data
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+)
+
+var (
+	// ErrPacerClosed is returned by Pacer.Write once the Pacer has been
+	// closed, including by writes which were waiting when it was.
+	ErrPacerClosed = errors.New("rtmp/data: pacer closed")
+)
+
+// Pacer writes Data to a chunk.Writer no sooner than the timestamps that it
+// carries allow, so that recorded content (for instance, read from an FLV file)
+// is served at the speed at which it was recorded, rather than as fast as the
+// connection allows.
+//
+// The first Data written after the Pacer is created (or Reset) is written
+// immediately, and its timestamp, and the time at which it was written, are
+// taken as the start of playback. Each subsequent Data is written once the
+// wall-clock time elapsed since then, multiplied by the speed of the Pacer,
+// catches up with the difference between its timestamp and the first. Since
+// players buffer the Data that they receive, the Pacer runs ahead of the
+// wall-clock by the buffer length requested by the player (see
+// SetBufferLength), if any.
+//
+// Data which carries no timestamp, such as DataFrames, and Data whose timestamp
+// is already due (or earlier than the first) is written immediately.
+//
+// Pacer is safe for concurrent use, although Data written concurrently is
+// written in no particular order.
+type Pacer struct {
+	// w is the chunk.Writer that Data is written to.
+	w chunk.Writer
+	// speed is the rate at which media time passes, relative to the
+	// wall-clock.
+	speed float64
+
+	// mu guards bufferLength, started, start, and base.
+	mu sync.Mutex
+	// bufferLength is the amount of media time that the Pacer may run
+	// ahead of the wall-clock by.
+	bufferLength time.Duration
+	// started is true once the start of playback is known.
+	started bool
+	// start is the wall-clock time at which playback started.
+	start time.Time
+	// base is the timestamp, in milliseconds, of the Data that started
+	// playback.
+	base uint32
+
+	// closeOnce ensures that closer is closed only once.
+	closeOnce sync.Once
+	// closer is closed once the Pacer is closed.
+	closer chan struct{}
+}
+
+// NewPacer returns a new *Pacer writing Data to the given chunk.Writer at the
+// given speed: a speed of 1 writes Data in real-time, and a speed of 2 twice as
+// fast, which is useful to quickly fill the buffer of a player when playback
+// starts. If the speed is not positive, Data is written as fast as possible.
+func NewPacer(w chunk.Writer, speed float64) *Pacer {
+	return &Pacer{
+		w:     w,
+		speed: speed,
+
+		closer: make(chan struct{}),
+	}
+}
+
+// SetBufferLength sets the amount of media time that the Pacer may run ahead of
+// the wall-clock by, typically to the buffer length requested by the player
+// (see control.Stream.BufferLength, from the github.com/WatchBeam/rtmp/control
+// package). It takes effect from the next call to Write.
+func (p *Pacer) SetBufferLength(length time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.bufferLength = length
+}
+
+// Reset forgets the start of playback, so that the next Data written starts it
+// again. It should be called when the timestamps of the Data being written jump,
+// such as after seeking.
+func (p *Pacer) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.started = false
+}
+
+// Write waits until the given Data is due (see Pacer), and then marshals it,
+// and writes it to the chunk.Writer, returning any error encountered while
+// doing so. If the Pacer is closed, ErrPacerClosed is returned instead.
+func (p *Pacer) Write(d Data) error {
+	select {
+	case <-p.closer:
+		return ErrPacerClosed
+	default:
+	}
+
+	if wait := p.wait(d); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-p.closer:
+			return ErrPacerClosed
+		}
+	}
+
+	c, err := d.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return p.w.Write(c)
+}
+
+// Close closes the Pacer, interrupting any writes waiting for their Data to be
+// due. It is safe to call more than once.
+func (p *Pacer) Close() {
+	p.closeOnce.Do(func() { close(p.closer) })
+}
+
+// wait returns how long to wait before the given Data is due, starting
+// playback if it has not started yet.
+func (p *Pacer) wait(d Data) time.Duration {
+	t, ok := d.(timestamped)
+	if !ok || p.speed <= 0 {
+		return 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	ts := t.Timestamp()
+
+	if !p.started {
+		p.started = true
+		p.start, p.base = now, ts
+
+		return 0
+	}
+
+	media := time.Duration(int64(ts)-int64(p.base)) * time.Millisecond
+	if media <= p.bufferLength {
+		return 0
+	}
+
+	due := p.start.Add(time.Duration(float64(media-p.bufferLength) / p.speed))
+	return due.Sub(now)
+}
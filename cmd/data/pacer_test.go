@@ -0,0 +1,108 @@
+package data_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePaced writes a video frame with each of the given timestamps to the
+// given Pacer, returning the time elapsed between the first write and each of
+// the others.
+func writePaced(t *testing.T, p *data.Pacer, timestamps ...uint32) []time.Duration {
+	var start time.Time
+	var elapsed []time.Duration
+
+	for i, ts := range timestamps {
+		assert.Nil(t, p.Write(newTimestampedVideo(ts)))
+
+		if i == 0 {
+			start = time.Now()
+		} else {
+			elapsed = append(elapsed, time.Since(start))
+		}
+	}
+
+	return elapsed
+}
+
+func TestPacerDelaysFramesByTheirTimestampDeltas(t *testing.T) {
+	p := data.NewPacer(chunk.NoopWriter, 1)
+
+	elapsed := writePaced(t, p, 0, 100, 200, 300)
+
+	for i, e := range elapsed {
+		expected := time.Duration(i+1) * 100 * time.Millisecond
+		assert.InDelta(t, expected, e, float64(30*time.Millisecond))
+	}
+}
+
+func TestPacerWritesFasterThanRealTime(t *testing.T) {
+	p := data.NewPacer(chunk.NoopWriter, 2)
+
+	elapsed := writePaced(t, p, 0, 100, 200, 300)
+
+	for i, e := range elapsed {
+		expected := time.Duration(i+1) * 50 * time.Millisecond
+		assert.InDelta(t, expected, e, float64(30*time.Millisecond))
+	}
+}
+
+func TestPacerRunsAheadByTheBufferLength(t *testing.T) {
+	p := data.NewPacer(chunk.NoopWriter, 1)
+	p.SetBufferLength(200 * time.Millisecond)
+
+	elapsed := writePaced(t, p, 0, 100, 200, 300)
+
+	assert.InDelta(t, 0, elapsed[0], float64(30*time.Millisecond))
+	assert.InDelta(t, 0, elapsed[1], float64(30*time.Millisecond))
+	assert.InDelta(t, 100*time.Millisecond, elapsed[2],
+		float64(30*time.Millisecond))
+}
+
+func TestPacerWritesDataFramesImmediately(t *testing.T) {
+	p := data.NewPacer(chunk.NoopWriter, 1)
+	assert.Nil(t, p.Write(newTimestampedVideo(0)))
+
+	f, err := data.DefaultParser.Parse(
+		newDataChunk(data.DataFrameTypeId, OnMetaData))
+	assert.Nil(t, err)
+
+	start := time.Now()
+	assert.Nil(t, p.Write(f))
+	assert.True(t, time.Since(start) < 30*time.Millisecond)
+}
+
+func TestPacerRestartsPlaybackWhenReset(t *testing.T) {
+	p := data.NewPacer(chunk.NoopWriter, 1)
+	assert.Nil(t, p.Write(newTimestampedVideo(0)))
+
+	p.Reset()
+
+	start := time.Now()
+	assert.Nil(t, p.Write(newTimestampedVideo(60000)))
+	assert.True(t, time.Since(start) < 30*time.Millisecond)
+}
+
+func TestPacerCloseInterruptsWaitingWrites(t *testing.T) {
+	p := data.NewPacer(chunk.NoopWriter, 1)
+	assert.Nil(t, p.Write(newTimestampedVideo(0)))
+
+	errs := make(chan error)
+	go func() { errs <- p.Write(newTimestampedVideo(60000)) }()
+
+	p.Close()
+	p.Close()
+
+	select {
+	case err := <-errs:
+		assert.Equal(t, data.ErrPacerClosed, err)
+	case <-time.After(time.Second):
+		t.Fatal("rtmp/data: write was not interrupted")
+	}
+
+	assert.Equal(t, data.ErrPacerClosed, p.Write(newTimestampedVideo(0)))
+}
@@ -0,0 +1,68 @@
+package data
+
+import "github.com/WatchBeam/rtmp/chunk"
+
+// PlaybackStart returns the Data that a new player of the given Stream should
+// receive before any other, in order for it to configure itself, and start
+// decoding immediately:
+//
+//  1. the given metadata (typically the "onMetaData" DataFrame cached by a Hub,
+//     see Hub.Metadata), unless it is nil,
+//  2. the latest video and audio sequence headers received by the Stream (see
+//     VideoSequenceHeader and AudioSequenceHeader), and
+//  3. the GOP cache of the Stream (see GOP), starting at its last keyframe.
+//
+// Sequence headers held in the GOP cache are not repeated.
+func PlaybackStart(metadata Data, src *Stream) []Data {
+	var start []Data
+	if metadata != nil {
+		start = append(start, metadata)
+	}
+
+	if v, ok := src.VideoSequenceHeader(); ok {
+		start = append(start, v)
+	}
+	if a, ok := src.AudioSequenceHeader(); ok {
+		start = append(start, a)
+	}
+
+	for _, d := range src.GOP() {
+		if isSequenceHeader(d) {
+			continue
+		}
+		start = append(start, d)
+	}
+
+	return start
+}
+
+// WritePlaybackStart writes the Data returned by PlaybackStart to the given
+// chunk.Writer, in order, returning the first error encountered while
+// marshaling or writing it, if any.
+func WritePlaybackStart(w chunk.Writer, metadata Data, src *Stream) error {
+	for _, d := range PlaybackStart(metadata, src) {
+		c, err := d.Marshal()
+		if err != nil {
+			return err
+		}
+
+		if err = w.Write(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isSequenceHeader returns whether or not the given Data is a video or audio
+// sequence header.
+func isSequenceHeader(d Data) bool {
+	switch v := d.(type) {
+	case *Video:
+		return v.isSequenceHeader()
+	case *Audio:
+		return v.isSequenceHeader()
+	}
+
+	return false
+}
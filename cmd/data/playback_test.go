@@ -0,0 +1,136 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// AudioSequenceHeader is an AAC sequence header, carrying the
+	// AudioSpecificConfig of 44.1kHz stereo AAC-LC.
+	AudioSequenceHeader = []byte{0xaf, 0x00, 0x12, 0x10}
+)
+
+// newPlaybackTestStream returns a Stream with GOP caching enabled, which has
+// received the given chunks, in order.
+func newPlaybackTestStream(chunks ...*chunk.Chunk) *data.Stream {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	s.SetGOPCacheSize(data.DefaultGOPCacheSize)
+	go s.Recv()
+
+	for _, c := range chunks {
+		s.Chunks() <- c
+		<-s.In()
+	}
+
+	return s
+}
+
+// assertPlaybackOrder asserts that the given Data are, in order, an
+// "onMetaData" DataFrame, the video sequence header, the audio sequence
+// header, the keyframe, and the audio frame following it.
+func assertPlaybackOrder(t *testing.T, start []data.Data) {
+	if !assert.Len(t, start, 5) {
+		return
+	}
+
+	f, ok := start[0].(*data.DataFrame)
+	assert.True(t, ok)
+	_, ok = f.Metadata()
+	assert.True(t, ok)
+
+	assert.Equal(t, SequenceHeader[1:], start[1].(*data.Video).Payload())
+	assert.Equal(t, AudioSequenceHeader[1:], start[2].(*data.Audio).Payload())
+	assert.Equal(t, Keyframe[1:], start[3].(*data.Video).Payload())
+	assert.Equal(t, AudioFrame[1:], start[4].(*data.Audio).Payload())
+}
+
+func TestPlaybackStartSendsMetadataThenSequenceHeadersThenTheGOP(t *testing.T) {
+	s := newPlaybackTestStream(
+		newDataChunk(data.AudioTypeId, AudioSequenceHeader),
+		newDataChunk(data.VideoTypeId, SequenceHeader),
+		newDataChunk(data.VideoTypeId, Keyframe),
+		newDataChunk(data.AudioTypeId, AudioFrame),
+	)
+	defer s.Close()
+
+	metadata, err := data.DefaultParser.Parse(
+		newDataChunk(data.DataFrameTypeId, OnMetaData))
+	assert.Nil(t, err)
+
+	assertPlaybackOrder(t, data.PlaybackStart(metadata, s))
+}
+
+func TestPlaybackStartWithoutMetadata(t *testing.T) {
+	s := newPlaybackTestStream(
+		newDataChunk(data.VideoTypeId, SequenceHeader),
+		newDataChunk(data.VideoTypeId, Keyframe),
+	)
+	defer s.Close()
+
+	start := data.PlaybackStart(nil, s)
+
+	if assert.Len(t, start, 2) {
+		assert.Equal(t, SequenceHeader[1:], start[0].(*data.Video).Payload())
+		assert.Equal(t, Keyframe[1:], start[1].(*data.Video).Payload())
+	}
+}
+
+func TestWritePlaybackStartWritesEachDataInOrder(t *testing.T) {
+	s := newPlaybackTestStream(
+		newDataChunk(data.VideoTypeId, SequenceHeader),
+		newDataChunk(data.AudioTypeId, AudioSequenceHeader),
+		newDataChunk(data.VideoTypeId, Keyframe),
+	)
+	defer s.Close()
+
+	w := newChanWriter(3)
+	assert.Nil(t, data.WritePlaybackStart(w, nil, s))
+
+	assert.Equal(t, SequenceHeader, (<-w.chunks).Data)
+	assert.Equal(t, AudioSequenceHeader, (<-w.chunks).Data)
+	assert.Equal(t, Keyframe, (<-w.chunks).Data)
+}
+
+func TestHubSendsCachedMetadataWhenPlaybackStarts(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	s.SetGOPCacheSize(data.DefaultGOPCacheSize)
+	go s.Recv()
+	defer s.Close()
+
+	h := data.NewHub()
+	assert.Nil(t, h.Publish("key", s))
+
+	_, ok := h.Metadata("key")
+	assert.False(t, ok)
+
+	// A player receives everything published while it plays, so the
+	// stream is published through it, to know once the Hub has.
+	published, _ := h.Play("key")
+	for _, c := range []*chunk.Chunk{
+		newDataChunk(data.DataFrameTypeId, OnMetaData),
+		newDataChunk(data.AudioTypeId, AudioSequenceHeader),
+		newDataChunk(data.VideoTypeId, SequenceHeader),
+		newDataChunk(data.VideoTypeId, Keyframe),
+		newDataChunk(data.AudioTypeId, AudioFrame),
+	} {
+		s.Chunks() <- c
+		<-published
+	}
+
+	metadata, ok := h.Metadata("key")
+	assert.True(t, ok)
+
+	frames, _ := h.Play("key")
+
+	start := make([]data.Data, 5)
+	for i := range start {
+		start[i] = <-frames
+	}
+
+	assert.Equal(t, metadata, start[0])
+	assertPlaybackOrder(t, start)
+}
@@ -0,0 +1,349 @@
+package data
+
+import (
+	"sync"
+
+	"github.com/WatchBeam/rtmp/chunk"
+)
+
+const (
+	// DefaultMaxLag is the default number of Data that a subscriber of a
+	// Relay may fall behind by before it is dropped.
+	DefaultMaxLag = 256
+)
+
+// Relay forwards each Data received from a single source Stream to a dynamic
+// set of subscribers, each represented by a chunk.Writer. It is typically used
+// to ingest a stream once, and play it back many times.
+//
+// New subscribers are primed with the start of playback of the source Stream
+// (see PlaybackStart), so that they may start decoding immediately. Each subscriber is
+// written to from its own goroutine, so a slow subscriber does not stall the
+// source, or any other subscriber. Instead, subscribers that fall behind by
+// more than the maximum lag (see SetMaxLag) are dropped.
+//
+// Subscribers may instead shed load by dropping video frames (see
+// SetDropFrames): once a subscriber is congested, the inter-frames forwarded to
+// it are dropped until the next keyframe, which keeps its audio and video in
+// sync, rather than letting both fall behind. Audio, sequence headers,
+// keyframes, and script data are never dropped, so a subscriber which falls
+// behind by the maximum lag regardless is still dropped.
+type Relay struct {
+	// src is the Stream whose Data is forwarded.
+	src *Stream
+
+	// smu guards maxLag, dropFrames, droppedFrames, subs, and next.
+	smu sync.Mutex
+	// maxLag is the number of Data that a subscriber may fall behind by
+	// before it is dropped.
+	maxLag int
+	// dropFrames is true if subscribers drop inter-frames when congested.
+	dropFrames bool
+	// droppedFrames is the number of frames dropped from all subscribers,
+	// including those which have since been removed.
+	droppedFrames uint64
+	// subs maps subscriber IDs to their subscriber.
+	subs map[int]*subscriber
+	// next is the ID of the next subscriber to be added.
+	next int
+
+	// dropped is written to with the ID of each subscriber that is dropped
+	// because it fell too far behind, or because writing to it failed.
+	dropped chan int
+	// closer is closed when the Run operation should halt.
+	closer chan struct{}
+	// closeOnce ensures that closer is closed only once.
+	closeOnce sync.Once
+	// done is closed once the Run operation has returned.
+	done chan struct{}
+
+	// rmu guards running.
+	rmu sync.Mutex
+	// running is whether or not the Run operation has been started.
+	running bool
+}
+
+// subscriber is a single chunk.Writer that a Relay forwards Data to.
+type subscriber struct {
+	// w is the chunk.Writer that Data is written to.
+	w chunk.Writer
+	// frames holds the Data that has not yet been written to w.
+	frames chan Data
+
+	// dropFrames is true if the subscriber drops inter-frames when
+	// congested.
+	dropFrames bool
+	// skipping is true while inter-frames are being dropped, until the
+	// next keyframe.
+	skipping bool
+	// droppedFrames is the number of frames dropped from the subscriber.
+	droppedFrames uint64
+}
+
+// NewRelay returns a new *Relay forwarding the Data received from the given
+// Stream, with no subscribers. The Run method is not called.
+//
+// Once a Stream is relayed, its In() channel should not be read from anywhere
+// else.
+func NewRelay(src *Stream) *Relay {
+	return &Relay{
+		src: src,
+
+		maxLag: DefaultMaxLag,
+		subs:   make(map[int]*subscriber),
+
+		dropped: make(chan int, 1),
+		closer:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// SetMaxLag sets the number of Data that subscribers added after this call may
+// fall behind by before they are dropped.
+func (r *Relay) SetMaxLag(maxLag int) {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+
+	r.maxLag = maxLag
+}
+
+// SetDropFrames sets whether subscribers added after this call drop inter-frames
+// when congested, rather than queueing them (see Relay). A subscriber is
+// congested once the Data that it has yet to write fills half of its queue,
+// whose size is its maximum lag, plus the number of Data that it was primed
+// with. It is disabled by default.
+func (r *Relay) SetDropFrames(drop bool) {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+
+	r.dropFrames = drop
+}
+
+// DroppedFrames returns the number of frames dropped from all subscribers (see
+// SetDropFrames), including those which have since been removed.
+func (r *Relay) DroppedFrames() uint64 {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+
+	return r.droppedFrames
+}
+
+// SubscriberDroppedFrames returns the number of frames dropped from the
+// subscriber with the given ID (see SetDropFrames), and whether or not it
+// exists.
+func (r *Relay) SubscriberDroppedFrames(id int) (uint64, bool) {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+
+	sub, ok := r.subs[id]
+	if !ok {
+		return 0, false
+	}
+	return sub.droppedFrames, true
+}
+
+// Dropped returns a channel which is written to with the ID of each subscriber
+// that is dropped, either because it fell too far behind, or because writing to
+// it failed. Sends on this channel never block, so IDs may be missed if it is
+// not read from.
+func (r *Relay) Dropped() <-chan int { return r.dropped }
+
+// Add adds the given chunk.Writer as a subscriber, returning its ID. The
+// subscriber is first primed with the latest sequence headers and the GOP cache
+// of the source Stream (see PlaybackStart), and then receives every Data
+// forwarded afterwards.
+func (r *Relay) Add(w chunk.Writer) (id int) {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+
+	start := PlaybackStart(nil, r.src)
+
+	sub := &subscriber{
+		w:          w,
+		frames:     make(chan Data, len(start)+r.maxLag),
+		dropFrames: r.dropFrames,
+	}
+	for _, d := range start {
+		sub.frames <- d
+	}
+
+	id = r.next
+	r.next++
+	r.subs[id] = sub
+
+	go r.write(id, sub)
+
+	return id
+}
+
+// Remove removes the subscriber with the given ID, if it exists. Data that has
+// already been forwarded to it may still be written.
+func (r *Relay) Remove(id int) {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+
+	r.remove(id)
+}
+
+// remove removes the subscriber with the given ID. It must be called while
+// holding smu.
+func (r *Relay) remove(id int) bool {
+	sub, ok := r.subs[id]
+	if !ok {
+		return false
+	}
+
+	delete(r.subs, id)
+	close(sub.frames)
+
+	return true
+}
+
+// drop removes the subscriber with the given ID, and reports it over the
+// Dropped() channel. It must be called while holding smu.
+func (r *Relay) drop(id int) {
+	if !r.remove(id) {
+		return
+	}
+
+	select {
+	case r.dropped <- id:
+	default:
+	}
+}
+
+// Len returns the number of subscribers.
+func (r *Relay) Len() int {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+
+	return len(r.subs)
+}
+
+// Close halts the Run operation, and removes all subscribers. If Run is
+// running, Close blocks until it has returned. Calling Close more than once, or
+// after Run has returned on its own, is a no-op.
+func (r *Relay) Close() {
+	r.closeOnce.Do(func() { close(r.closer) })
+
+	r.rmu.Lock()
+	running := r.running
+	r.rmu.Unlock()
+
+	if running {
+		<-r.done
+		return
+	}
+
+	r.removeAll()
+}
+
+// Run forwards each Data received from the source Stream to every subscriber,
+// until either the source Stream is closed, or Close is called. All subscribers
+// are removed once it returns.
+//
+// Run runs within its own goroutine.
+func (r *Relay) Run() {
+	r.rmu.Lock()
+	r.running = true
+	r.rmu.Unlock()
+
+	defer close(r.done)
+	defer r.removeAll()
+
+	for {
+		select {
+		case d, ok := <-r.src.In():
+			if !ok {
+				return
+			}
+
+			r.forward(d)
+		case <-r.closer:
+			return
+		}
+	}
+}
+
+// removeAll removes every subscriber.
+func (r *Relay) removeAll() {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+
+	for id := range r.subs {
+		r.remove(id)
+	}
+}
+
+// forward forwards the given Data to every subscriber, dropping those that have
+// fallen too far behind, and skipping those which drop it instead (see
+// subscriber.skip).
+func (r *Relay) forward(d Data) {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+
+	for id, sub := range r.subs {
+		if sub.skip(d) {
+			r.droppedFrames++
+			continue
+		}
+
+		select {
+		case sub.frames <- d:
+		default:
+			r.drop(id)
+		}
+	}
+}
+
+// write writes each Data forwarded to the given subscriber, until it is
+// removed. If a write fails, the subscriber is dropped.
+//
+// write runs within its own goroutine.
+func (r *Relay) write(id int, sub *subscriber) {
+	for d := range sub.frames {
+		c, err := d.Marshal()
+		if err == nil {
+			err = sub.w.Write(c)
+		}
+
+		if err != nil {
+			r.smu.Lock()
+			r.drop(id)
+			r.smu.Unlock()
+
+			for range sub.frames {
+			}
+			return
+		}
+	}
+}
+
+// skip returns whether or not the given Data should be dropped, rather than
+// forwarded to the subscriber, counting it if so. Once the subscriber is
+// congested, inter-frames are dropped until the next keyframe. It must be
+// called while holding the smu of the Relay.
+func (s *subscriber) skip(d Data) bool {
+	v, ok := d.(*Video)
+	if !ok || !s.dropFrames || v.isSequenceHeader() {
+		return false
+	}
+
+	if v.IsKeyframe() {
+		s.skipping = false
+		return false
+	}
+
+	if !v.isInterframe() {
+		return false
+	}
+
+	if !s.skipping && len(s.frames) < cap(s.frames)/2 {
+		return false
+	}
+
+	s.skipping = true
+	s.droppedFrames++
+
+	return true
+}
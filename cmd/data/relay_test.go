@@ -0,0 +1,301 @@
+package data_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+// chanWriter is a chunk.Writer which pushes each chunk written to it onto a
+// channel, or returns err if it is non-nil.
+type chanWriter struct {
+	chunks chan *chunk.Chunk
+	err    error
+}
+
+var _ chunk.Writer = new(chanWriter)
+
+func newChanWriter(size int) *chanWriter {
+	return &chanWriter{chunks: make(chan *chunk.Chunk, size)}
+}
+
+func (w *chanWriter) Write(c *chunk.Chunk) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.chunks <- c
+	return nil
+}
+
+func (w *chanWriter) WriteSize() int                 { return chunk.DefaultReadSize }
+func (w *chanWriter) SetWriteSize(int)               {}
+func (w *chanWriter) SetChunkSize(size uint32) error { return nil }
+
+func newVideoChunk(payload []byte) *chunk.Chunk {
+	return &chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: data.VideoTypeId},
+		},
+		Data: payload,
+	}
+}
+
+func newRelayTestStream() *data.Stream {
+	return data.NewBufferedStream(make(chan *chunk.Chunk), chunk.NoopWriter, 0)
+}
+
+func TestRelayForwardsToAllSubscribers(t *testing.T) {
+	s := newRelayTestStream()
+	r := data.NewRelay(s)
+
+	a, b := newChanWriter(1), newChanWriter(1)
+	r.Add(a)
+	r.Add(b)
+
+	go s.Recv()
+	go r.Run()
+
+	c := newVideoChunk(Keyframe)
+	s.Chunks() <- c
+
+	assert.Equal(t, c, <-a.chunks)
+	assert.Equal(t, c, <-b.chunks)
+	assert.Equal(t, 2, r.Len())
+}
+
+func TestRelayPrimesNewSubscribersWithTheGOP(t *testing.T) {
+	s := data.NewBufferedStream(make(chan *chunk.Chunk), chunk.NoopWriter, 2)
+	s.SetGOPCacheSize(data.DefaultGOPCacheSize)
+	go s.Recv()
+
+	header, key := newVideoChunk(SequenceHeader), newVideoChunk(Keyframe)
+	s.Chunks() <- header
+	s.Chunks() <- key
+	<-s.In()
+	<-s.In()
+
+	r := data.NewRelay(s)
+	w := newChanWriter(2)
+	r.Add(w)
+
+	assert.Equal(t, header, <-w.chunks)
+	assert.Equal(t, key, <-w.chunks)
+}
+
+func TestRelayPrimesNewSubscribersWithSequenceHeadersOutsideTheGOP(t *testing.T) {
+	s := newPlaybackTestStream(
+		newDataChunk(data.AudioTypeId, AudioSequenceHeader),
+		newDataChunk(data.VideoTypeId, SequenceHeader),
+		newDataChunk(data.VideoTypeId, Keyframe),
+		newDataChunk(data.VideoTypeId, Keyframe),
+	)
+	defer s.Close()
+
+	w := newChanWriter(3)
+	data.NewRelay(s).Add(w)
+
+	assert.Equal(t, SequenceHeader, (<-w.chunks).Data)
+	assert.Equal(t, AudioSequenceHeader, (<-w.chunks).Data)
+	assert.Equal(t, Keyframe, (<-w.chunks).Data)
+	assert.Len(t, w.chunks, 0)
+}
+
+func TestRelayDropsSlowSubscribers(t *testing.T) {
+	s := newRelayTestStream()
+	r := data.NewRelay(s)
+	r.SetMaxLag(1)
+
+	slow := newChanWriter(0)
+	id := r.Add(slow)
+
+	go s.Recv()
+	go r.Run()
+
+	for i := 0; i < 3; i++ {
+		s.Chunks() <- newVideoChunk(Keyframe)
+	}
+
+	assert.Equal(t, id, <-r.Dropped())
+	assert.Equal(t, 0, r.Len())
+}
+
+func TestRelayDropsSubscribersThatFailToWrite(t *testing.T) {
+	s := newRelayTestStream()
+	r := data.NewRelay(s)
+
+	w := newChanWriter(1)
+	w.err = errors.New("foo")
+	id := r.Add(w)
+
+	go s.Recv()
+	go r.Run()
+
+	s.Chunks() <- newVideoChunk(Keyframe)
+
+	assert.Equal(t, id, <-r.Dropped())
+	assert.Equal(t, 0, r.Len())
+}
+
+func TestRelayStopsForwardingToRemovedSubscribers(t *testing.T) {
+	s := newRelayTestStream()
+	r := data.NewRelay(s)
+
+	removed, kept := newChanWriter(1), newChanWriter(1)
+	r.Remove(r.Add(removed))
+	r.Add(kept)
+
+	go s.Recv()
+	go r.Run()
+
+	s.Chunks() <- newVideoChunk(Keyframe)
+	<-kept.chunks
+
+	assert.Empty(t, removed.chunks)
+	assert.Equal(t, 1, r.Len())
+}
+
+func TestRelayRemovesAllSubscribersWhenClosed(t *testing.T) {
+	r := data.NewRelay(newRelayTestStream())
+	r.Add(newChanWriter(0))
+
+	go r.Run()
+	r.Close()
+
+	assert.Eventually(t, func() bool { return r.Len() == 0 },
+		time.Second, time.Millisecond)
+}
+
+func TestRelayClosesAfterTheSourceStreamHasClosed(t *testing.T) {
+	s := newRelayTestStream()
+	go s.Recv()
+
+	r := data.NewRelay(s)
+	ran := make(chan struct{})
+	go func() {
+		r.Run()
+		close(ran)
+	}()
+
+	s.Close()
+	<-ran
+
+	closed := make(chan struct{})
+	go func() {
+		r.Close()
+		r.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("data: Close blocked after Run returned")
+	}
+}
+
+func TestRelayClosesWithoutRun(t *testing.T) {
+	r := data.NewRelay(newRelayTestStream())
+	r.Add(newChanWriter(0))
+
+	r.Close()
+
+	assert.Equal(t, 0, r.Len())
+}
+
+// numberedFrame returns a copy of the given frame, whose last byte is replaced
+// by n, so that it may be told apart from other copies.
+func numberedFrame(frame []byte, n byte) []byte {
+	numbered := append([]byte(nil), frame...)
+	numbered[len(numbered)-1] = n
+
+	return numbered
+}
+
+func TestRelayDropsInterframesForCongestedSubscribers(t *testing.T) {
+	s := newRelayTestStream()
+	r := data.NewRelay(s)
+	r.SetMaxLag(16)
+	r.SetDropFrames(true)
+
+	slow := newChanWriter(0)
+	id := r.Add(slow)
+
+	go s.Recv()
+	go r.Run()
+	defer r.Close()
+
+	const gop = 8
+	s.Chunks() <- newVideoChunk(SequenceHeader)
+	s.Chunks() <- newVideoChunk(Keyframe)
+	for i := 0; i < gop; i++ {
+		s.Chunks() <- newVideoChunk(numberedFrame(Interframe, byte(i)))
+		s.Chunks() <- newDataChunk(data.AudioTypeId,
+			numberedFrame(AudioFrame, byte(i)))
+	}
+	s.Chunks() <- newVideoChunk(numberedFrame(Keyframe, 0xff))
+	s.Chunks() <- newVideoChunk(numberedFrame(Interframe, gop))
+
+	// Run has forwarded the last frame once Recv reads the frame after it.
+	last := numberedFrame(AudioFrame, 0xff)
+	s.Chunks() <- newDataChunk(data.AudioTypeId, last)
+	s.Chunks() <- newDataChunk(data.AudioTypeId, AudioFrame)
+
+	var audio, keyframes, interframes []byte
+	for c := range slow.chunks {
+		payload := c.Data
+
+		switch {
+		case payload[0] == AudioFrame[0]:
+			audio = append(audio, payload[len(payload)-1])
+		case payload[0] == Interframe[0]:
+			interframes = append(interframes, payload[len(payload)-1])
+		case payload[1] == Keyframe[1]:
+			keyframes = append(keyframes, payload[len(payload)-1])
+		}
+
+		if assert.ObjectsAreEqual(last, payload) {
+			break
+		}
+	}
+
+	assert.Equal(t, 1, r.Len())
+	assert.Equal(t, []byte{0, 1, 2, 3, 4, 5, 6, 7, 0xff}, audio)
+	assert.Equal(t, []byte{Keyframe[len(Keyframe)-1], 0xff}, keyframes)
+
+	// Inter-frames are dropped from the first which the subscriber was too
+	// congested for, until the next keyframe.
+	if assert.True(t, len(interframes) < gop) {
+		for i, n := range interframes {
+			assert.Equal(t, byte(i), n)
+		}
+	}
+
+	dropped, ok := r.SubscriberDroppedFrames(id)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(gop+1-len(interframes)), dropped)
+	assert.Equal(t, dropped, r.DroppedFrames())
+}
+
+func TestRelayDoesNotDropFramesByDefault(t *testing.T) {
+	s := newRelayTestStream()
+	r := data.NewRelay(s)
+	r.SetMaxLag(4)
+
+	slow := newChanWriter(0)
+	id := r.Add(slow)
+
+	go s.Recv()
+	go r.Run()
+
+	for i := 0; i < 6; i++ {
+		s.Chunks() <- newVideoChunk(Interframe)
+	}
+
+	assert.Equal(t, id, <-r.Dropped())
+	assert.Zero(t, r.DroppedFrames())
+}
@@ -1,6 +1,14 @@
 package data
 
-import "github.com/WatchBeam/rtmp/chunk"
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/logging"
+	"github.com/WatchBeam/rtmp/tracing"
+)
 
 // Type Stream encapsulates a continuous stream of data messages coming over
 // an RTMP chunk stream. The Stream parses each full chunk that it receives and
@@ -13,33 +21,115 @@ type Stream struct {
 	// parser is the *Parser that is used to parse chunks from the
 	// `*chunk.Stream` into `Data`s.
 	parser Parser
+	// chunker is the Chunker that is used to turn written `Data`s into
+	// chunks.
+	chunker Chunker
 
 	// writer is the chunk.Writer that is used to write data back to the
-	// client in the RTMP chunk format.
-	writer chunk.Writer
+	// client in the RTMP chunk format. It counts the bytes written.
+	writer *chunk.CountingWriter
+	// bytesIn is the number of bytes received. It must be accessed
+	// atomically.
+	bytesIn uint64
 
 	// in holds each parsed Data token until it can be read somewhere else.
 	in chan Data
 	// errs holds all of the errors that were encountered during parsing.
 	errs chan error
-	// closer is written to when the Stream is told to close itself. When a
-	// message is read over this channel, the Stream is expected to clean up
-	// after itself.
+	// closer is closed when the Stream is told to close itself. Once it is
+	// closed, the Stream is expected to clean up after itself.
 	closer chan struct{}
+	// closeOnce ensures that closer is closed only once.
+	closeOnce sync.Once
+	// done is closed once the Recv goroutine has stopped.
+	done chan struct{}
+	// drainCtx is the context bounding the draining of buffered chunks
+	// requested by CloseAndDrain, or nil if no draining was requested. It
+	// is set before closer is closed, and read only after, so it needs no
+	// lock.
+	drainCtx context.Context
+	// firstMedia is the span covering the time between Recv being started,
+	// and the first audio or video frame being received, or nil once it has
+	// ended. It is only accessed by the Recv goroutine, so it needs no lock.
+	firstMedia tracing.Span
+
+	// rmu guards running.
+	rmu sync.Mutex
+	// running is whether or not the Recv goroutine has been started.
+	running bool
+
+	// dmu guards dropOldest and dropped.
+	dmu sync.Mutex
+	// dropOldest determines whether or not Data is dropped when the `in`
+	// channel is full, instead of blocking until it is read.
+	dropOldest bool
+	// dropped is the number of Data that have been dropped.
+	dropped uint64
+
+	// lmu guards log.
+	lmu sync.Mutex
+	// log is the Logger that internal events are reported to.
+	log logging.Logger
+
+	// xmu guards ctx.
+	xmu sync.Mutex
+	// ctx is the context of the connection that this Stream belongs to.
+	ctx context.Context
+
+	// hmu guards videoHeader and audioHeader.
+	hmu sync.Mutex
+	// videoHeader is the latest AVC sequence header received, if any.
+	videoHeader Data
+	// audioHeader is the latest AAC sequence header received, if any.
+	audioHeader Data
+
+	// tmu guards timestamps.
+	tmu sync.Mutex
+	// timestamps is the TimestampNormalizer applied to incoming Data, or
+	// nil if timestamps are passed along unchanged.
+	timestamps *TimestampNormalizer
+
+	// imu guards interceptor.
+	imu sync.Mutex
+	// interceptor is applied to each incoming Data before it is passed
+	// along, or nil if Data is passed along unchanged.
+	interceptor Interceptor
+
+	// gmu guards gop.
+	gmu sync.Mutex
+	// gop is the GOPCache maintained by this Stream, or nil if GOP caching
+	// is disabled.
+	gop *GOPCache
 }
 
 // NewStream creates and returns a pointer to a new instance of the Stream type.
 // The instance is initialized with the given chunk stream, and all of the
 // internal channels are `make()`-d.
 func NewStream(chunks chan *chunk.Chunk, writer chunk.Writer) *Stream {
+	return NewBufferedStream(chunks, writer, 0)
+}
+
+// NewBufferedStream creates and returns a pointer to a new instance of the
+// Stream type, like NewStream, except that up to `bufSize` parsed Data may be
+// held in the In() channel before the Recv goroutine blocks (or drops Data, see
+// SetDropOldest).
+func NewBufferedStream(chunks chan *chunk.Chunk, writer chunk.Writer,
+	bufSize int) *Stream {
+
 	return &Stream{
 		chunks: chunks,
-		writer: writer,
+		writer: chunk.NewCountingWriter(writer, nil),
 		parser: DefaultParser,
 
-		in:     make(chan Data),
+		chunker: NewChunker(),
+
+		log: logging.Noop,
+		ctx: context.Background(),
+
+		in:     make(chan Data, bufSize),
 		errs:   make(chan error),
 		closer: make(chan struct{}),
+		done:   make(chan struct{}),
 	}
 }
 
@@ -54,10 +144,64 @@ func (s *Stream) In() <-chan Data { return s.in }
 func (s *Stream) Errs() <-chan error { return s.errs }
 
 // Close closes the `*data.Stream`, causing it to stop listening as well as
-// close all internal channels.
-func (s *Stream) Close() { s.closer <- struct{}{} }
+// close all internal channels. If the Recv goroutine is running, Close blocks
+// until it has stopped. Otherwise, Close returns immediately, and Recv stops as
+// soon as it is started. Calling Close more than once is a no-op.
+func (s *Stream) Close() {
+	s.closeOnce.Do(func() { close(s.closer) })
+
+	if s.isRunning() {
+		<-s.done
+	}
+}
+
+// CloseContext closes the `*data.Stream`, as Close does, but blocks until the
+// Recv goroutine has stopped, even if it has not yet been started. If "ctx" is
+// done first (for instance, because Recv was never called), its error is
+// returned instead.
+func (s *Stream) CloseContext(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.closer) })
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CloseAndDrain closes the `*data.Stream` as CloseContext does, except that
+// the Recv goroutine first processes the chunks already buffered in its channel
+// of chunks, so that the tail of the stream is not lost. Draining stops early
+// once "ctx" is done, in which case its error is returned. As Data is still
+// pushed onto the In() channel while draining, it must continue to be read.
+//
+// If the `*data.Stream` has already been closed, CloseAndDrain behaves as
+// CloseContext.
+func (s *Stream) CloseAndDrain(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		s.drainCtx = ctx
+		close(s.closer)
+	})
+
+	select {
+	case <-s.done:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isRunning returns whether or not the Recv goroutine has been started.
+func (s *Stream) isRunning() bool {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
 
-// Write writes the given frame of data "f" our to the chunk stream. If any
+	return s.running
+}
+
+// Write writes the given frame of data "f" our to the chunk stream, once it has
+// been chunked by the Stream's Chunker (see SetChunker). If any
 // error occured during marshaling or writing, then it will be returned, and the
 // frame may not have been written correctly, indicating that the connection
 // should be terminated.
@@ -65,7 +209,7 @@ func (s *Stream) Close() { s.closer <- struct{}{} }
 // Successfully, a value of "nil" will be returned and the chunk can be assumed
 // to have been successfully written.
 func (s *Stream) Write(f Data) error {
-	c, err := f.Marshal()
+	c, err := s.chunker.Chunk(f)
 	if err != nil {
 		return err
 	}
@@ -77,14 +221,258 @@ func (s *Stream) Write(f Data) error {
 	return nil
 }
 
+// BytesIn returns the number of bytes of Data received by the Recv goroutine,
+// including the message header of each chunk. Since the chunk size used by the
+// peer is not known to the Stream, the headers of continuation chunks are not
+// counted.
+func (s *Stream) BytesIn() uint64 { return atomic.LoadUint64(&s.bytesIn) }
+
+// BytesOut returns the number of bytes written to the chunk stream by Write (or
+// by the io.ReadWriteCloser returned by Conn), as chunked on the wire,
+// including all headers (see chunk.CountingWriter).
+func (s *Stream) BytesOut() uint64 { return s.writer.BytesWritten() }
+
+// SetLogger sets the Logger that this Stream reports internal events to, such
+// as frames dropped because the In() channel was full and data chunks which
+// could not be parsed. Until a non-nil Logger is set, those events go
+// unreported.
+func (s *Stream) SetLogger(l logging.Logger) {
+	s.lmu.Lock()
+	defer s.lmu.Unlock()
+
+	s.log = logging.OrNoop(l)
+}
+
+// logger returns the Logger set by SetLogger.
+func (s *Stream) logger() logging.Logger {
+	s.lmu.Lock()
+	defer s.lmu.Unlock()
+
+	return s.log
+}
+
+// SetContext sets the context of the connection that this Stream belongs to,
+// carrying request-scoped values (such as the identity of an authenticated
+// client) to the handlers of its Data. If the context is done, Recv
+// stops, as if Close had been called. If it carries a Tracer (see
+// tracing.NewContext), the time until the first media frame is received is
+// recorded with it. It must be set before Recv is started, and defaults to
+// context.Background().
+func (s *Stream) SetContext(ctx context.Context) {
+	s.xmu.Lock()
+	defer s.xmu.Unlock()
+
+	s.ctx = ctx
+}
+
+// Context returns the context set by SetContext.
+func (s *Stream) Context() context.Context {
+	s.xmu.Lock()
+	defer s.xmu.Unlock()
+
+	return s.ctx
+}
+
+// SetDropOldest sets whether or not Data is dropped when the In() channel is
+// full, rather than blocking the Recv goroutine until it is read. This is
+// useful for live streams, where stale frames are useless to a slow consumer.
+//
+// When enabled, the oldest Data held in the In() channel is dropped to make
+// room for the newest. If the Stream is unbuffered (see NewBufferedStream),
+// there is no oldest Data to drop, and the newest Data is dropped whenever
+// nobody is ready to receive it. In either case, the number of dropped Data is
+// reported by Dropped().
+func (s *Stream) SetDropOldest(dropOldest bool) {
+	s.dmu.Lock()
+	defer s.dmu.Unlock()
+
+	s.dropOldest = dropOldest
+}
+
+// Dropped returns the number of Data that have been dropped because the In()
+// channel was full (see SetDropOldest).
+func (s *Stream) Dropped() uint64 {
+	s.dmu.Lock()
+	defer s.dmu.Unlock()
+
+	return s.dropped
+}
+
+// shouldDrop returns whether or not Data should be dropped when the In()
+// channel is full.
+func (s *Stream) shouldDrop() bool {
+	s.dmu.Lock()
+	defer s.dmu.Unlock()
+
+	return s.dropOldest
+}
+
+// drop records that a single Data was dropped.
+func (s *Stream) drop() {
+	s.dmu.Lock()
+	s.dropped++
+	dropped := s.dropped
+	s.dmu.Unlock()
+
+	s.logger().Printf("rtmp/data: dropped frame, %d dropped in total", dropped)
+}
+
+// push pushes the given Data onto the In() channel, dropping Data if the
+// channel is full and SetDropOldest is enabled, or blocking until it can be
+// pushed otherwise. A blocked push gives up once the Stream is closed, unless
+// it is being drained (see CloseAndDrain), or once its context is done.
+func (s *Stream) push(d Data) {
+	if !s.shouldDrop() {
+		select {
+		case s.in <- d:
+		case <-s.closer:
+			s.pushDraining(d)
+		case <-s.Context().Done():
+		}
+		return
+	}
+
+	for {
+		select {
+		case s.in <- d:
+			return
+		default:
+		}
+
+		if cap(s.in) == 0 {
+			s.drop()
+			return
+		}
+
+		select {
+		case <-s.in:
+			s.drop()
+		default:
+		}
+	}
+}
+
+// pushDraining pushes the given Data onto the In() channel once the Stream has
+// been closed, if it is being drained, blocking until it can be pushed, or the
+// drain context is done. Otherwise, the Data is discarded.
+func (s *Stream) pushDraining(d Data) {
+	ctx := s.drainCtx
+	if ctx == nil {
+		return
+	}
+
+	select {
+	case s.in <- d:
+	case <-ctx.Done():
+	case <-s.Context().Done():
+	}
+}
+
+// SetGOPCacheSize enables GOP caching on this Stream (see GOPCache), holding at
+// most `maxBytes` bytes of payload. A size of zero or less disables it.
+func (s *Stream) SetGOPCacheSize(maxBytes int) {
+	s.gmu.Lock()
+	defer s.gmu.Unlock()
+
+	if maxBytes <= 0 {
+		s.gop = nil
+	} else {
+		s.gop = NewGOPCache(maxBytes)
+	}
+}
+
+// GOP returns the contents of this Stream's GOPCache, which can be used to
+// prime a new subscriber. If GOP caching is disabled, nil is returned instead.
+func (s *Stream) GOP() []Data {
+	gop := s.gopCache()
+	if gop == nil {
+		return nil
+	}
+
+	return gop.Frames()
+}
+
+// gopCache returns the GOPCache maintained by this Stream, or nil if GOP
+// caching is disabled.
+func (s *Stream) gopCache() *GOPCache {
+	s.gmu.Lock()
+	defer s.gmu.Unlock()
+
+	return s.gop
+}
+
+// Interceptor is a function which inspects, and optionally modifies or drops,
+// each frame of Data received by a Stream (see SetInterceptor). It returns the
+// Data to pass along in place of the given one, and whether or not to pass it
+// along at all.
+type Interceptor func(Data) (Data, bool)
+
+// SetInterceptor sets the Interceptor applied to each incoming Data once its
+// timestamp has been normalized (see SetTimestampNormalizer), and before it is
+// cached (see VideoSequenceHeader, and SetGOPCacheSize) and passed along over
+// the In() channel. If the Interceptor returns false, the Data is dropped.
+// Otherwise, the Data it returns replaces the original, unless it is nil. This
+// allows frames to be modified (such as to insert SEI) or dropped (such as to
+// strip audio) without forking this package.
+//
+// The Interceptor runs on the hot path, within the Recv goroutine, so it
+// should return quickly: a slow Interceptor delays every frame after it. If it
+// is nil (as it is by default), Data is passed along unchanged.
+func (s *Stream) SetInterceptor(i Interceptor) {
+	s.imu.Lock()
+	defer s.imu.Unlock()
+
+	s.interceptor = i
+}
+
+// Interceptor returns the Interceptor set with SetInterceptor, or nil if there
+// is none.
+func (s *Stream) Interceptor() Interceptor {
+	s.imu.Lock()
+	defer s.imu.Unlock()
+
+	return s.interceptor
+}
+
+// SetTimestampNormalizer sets the TimestampNormalizer used to correct the
+// timestamps of incoming Data before it is passed along. If it is nil (as it is
+// by default), timestamps are passed along unchanged.
+func (s *Stream) SetTimestampNormalizer(n *TimestampNormalizer) {
+	s.tmu.Lock()
+	defer s.tmu.Unlock()
+
+	s.timestamps = n
+}
+
+// TimestampNormalizer returns the TimestampNormalizer set with
+// SetTimestampNormalizer, whose Corrections() report the number of timestamps
+// corrected so far, or nil if there is none.
+func (s *Stream) TimestampNormalizer() *TimestampNormalizer {
+	s.tmu.Lock()
+	defer s.tmu.Unlock()
+
+	return s.timestamps
+}
+
 // SetParser sets the intenral parser used by this Stream. This method is _not_
 // safe to use between multiple goroutines, and should be used with caution.
 func (s *Stream) SetParser(p Parser) { s.parser = p }
 
+// SetChunker sets the internal chunker used by this Stream to chunk written
+// Data. This method is _not_ safe to use between multiple goroutines, and
+// should be used with caution.
+func (s *Stream) SetChunker(c Chunker) { s.chunker = c }
+
 // Recv processes all incoming chunks off of the owned `*chunk.Stream` and
 // parses them into Data types. If that parsing was succesful, the resulting
 // Data type is passed to the appropriate channel. Otherwise, an error is pushed
-// onto the `errs` channel.
+// onto the `errs` channel. If that channel is full, the Data may be dropped
+// (see SetDropOldest). If GOP caching is enabled, the Data is added to the
+// GOPCache before it is passed along. AVC and AAC sequence headers are cached
+// as well (see VideoSequenceHeader and AudioSequenceHeader). If a
+// TimestampNormalizer has been set, the timestamp of the Data is corrected
+// first. Aggregate messages are split into a Data for each of their
+// sub-messages (see SplitAggregate).
 //
 // Recv also reads from the `out` channel when data is available on it, marshals
 // it using the Data.Marshal function, and then sends it over the chunk stream.
@@ -94,24 +482,191 @@ func (s *Stream) SetParser(p Parser) { s.parser = p }
 //
 // Recv runs within its own goroutine.
 func (s *Stream) Recv() {
+	s.rmu.Lock()
+	s.running = true
+	s.rmu.Unlock()
+
+	ctx := s.Context()
+	_, s.firstMedia = tracing.Start(ctx, tracing.SpanFirstMedia)
+
 	defer func() {
+		if s.firstMedia != nil {
+			s.firstMedia.End(nil)
+		}
+
 		close(s.in)
 		close(s.errs)
-		close(s.closer)
+		close(s.done)
 	}()
 
 	for {
 		select {
-		case chunk := <-s.chunks:
-			data, err := s.parser.Parse(chunk)
-			if err != nil {
-				s.errs <- err
-				continue
+		case chunk, ok := <-s.chunks:
+			if !ok {
+				return
 			}
 
-			s.in <- data
+			if err := s.process(chunk); err != nil {
+				s.logger().Printf("rtmp/data: %v", err)
+
+				select {
+				case s.errs <- err:
+				case <-s.closer:
+					s.drain()
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
 		case <-s.closer:
+			s.drain()
 			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// process parses the given chunk, and passes the resulting Data along (see
+// Recv), returning any error encountered while parsing it. Aggregate messages
+// are split into their sub-messages (see SplitAggregate), each of which is
+// passed along in order, stopping at the first error.
+func (s *Stream) process(chunk *chunk.Chunk) error {
+	atomic.AddUint64(&s.bytesIn, uint64(chunk.WireLength(0)))
+
+	if chunk.Header == nil || chunk.TypeId() != AggregateTypeId {
+		return s.processMessage(chunk)
+	}
+
+	chunks, err := SplitAggregate(chunk)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range chunks {
+		if err := s.processMessage(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// processMessage parses the given chunk, which carries a single message, and
+// passes the resulting Data along (see process).
+func (s *Stream) processMessage(chunk *chunk.Chunk) error {
+	data, err := s.parser.Parse(chunk)
+	if err != nil {
+		return err
+	}
+
+	s.endFirstMedia(data)
+
+	if tn := s.TimestampNormalizer(); tn != nil {
+		tn.Normalize(data)
+	}
+
+	if intercept := s.Interceptor(); intercept != nil {
+		d, ok := intercept(data)
+		if !ok {
+			return nil
+		}
+		if d != nil {
+			data = d
+		}
+	}
+
+	s.cacheSequenceHeader(data)
+
+	if gop := s.gopCache(); gop != nil {
+		gop.Add(data)
+	}
+
+	s.push(data)
+
+	return nil
+}
+
+// endFirstMedia ends the first media span (see Recv) if the given Data is the
+// first audio or video frame received.
+func (s *Stream) endFirstMedia(d Data) {
+	if s.firstMedia == nil {
+		return
+	}
+
+	switch d.(type) {
+	case *Video, *Audio:
+		s.firstMedia.End(nil)
+		s.firstMedia = nil
+	}
+}
+
+// cacheSequenceHeader replaces the cached video or audio sequence header with
+// the given Data, if it is one.
+func (s *Stream) cacheSequenceHeader(d Data) {
+	s.hmu.Lock()
+	defer s.hmu.Unlock()
+
+	switch v := d.(type) {
+	case *Video:
+		if v.isSequenceHeader() {
+			s.videoHeader = v
+		}
+	case *Audio:
+		if v.isSequenceHeader() {
+			s.audioHeader = v
+		}
+	}
+}
+
+// VideoSequenceHeader returns the latest AVC or HEVC sequence header (carrying
+// the decoder configuration record) received by the Recv goroutine, and whether
+// or not one has been received at all. A relay should send it to late joiners
+// before any other video, since they are unable to decode without it.
+func (s *Stream) VideoSequenceHeader() (Data, bool) {
+	s.hmu.Lock()
+	defer s.hmu.Unlock()
+
+	return s.videoHeader, s.videoHeader != nil
+}
+
+// AudioSequenceHeader returns the latest AAC sequence header (carrying the
+// AudioSpecificConfig) received by the Recv goroutine, and whether or not one
+// has been received at all, as VideoSequenceHeader does.
+func (s *Stream) AudioSequenceHeader() (Data, bool) {
+	s.hmu.Lock()
+	defer s.hmu.Unlock()
+
+	return s.audioHeader, s.audioHeader != nil
+}
+
+// drain processes the chunks remaining in the channel of chunks, if draining
+// was requested by CloseAndDrain, until it is empty (or closed), or the drain
+// context is done.
+func (s *Stream) drain() {
+	ctx := s.drainCtx
+	if ctx == nil {
+		return
+	}
+
+	for ctx.Err() == nil {
+		var chunk *chunk.Chunk
+		var ok bool
+
+		select {
+		case chunk, ok = <-s.chunks:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+
+		if err := s.process(chunk); err != nil {
+			select {
+			case s.errs <- err:
+			case <-ctx.Done():
+			}
 		}
 	}
 }
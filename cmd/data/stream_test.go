@@ -2,11 +2,18 @@ package data_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"log"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/chunk/chunktest"
 	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/WatchBeam/rtmp/tracing"
+	"github.com/WatchBeam/rtmp/tracing/tracingtest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -68,6 +75,38 @@ func TestRecvWritesToChunkWriter(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestWriteUsesTheChunker(t *testing.T) {
+	ch := &chunk.Chunk{
+		Header: new(chunk.Header),
+		Data:   []byte{0x0, 0x1, 0x2, 0x3},
+	}
+
+	d := new(MockData)
+	chunker := &MockChunker{}
+	chunker.On("Chunk", d).Return(ch, nil).Once()
+
+	w := chunktest.NewRecordingWriter()
+	s := data.NewStream(make(chan *chunk.Chunk), w)
+	s.SetChunker(chunker)
+
+	assert.Nil(t, s.Write(d))
+	assert.Equal(t, []*chunk.Chunk{ch}, w.Chunks())
+	chunker.AssertExpectations(t)
+}
+
+func TestWriteReturnsChunkerErrors(t *testing.T) {
+	d := new(MockData)
+	chunker := &MockChunker{}
+	chunker.On("Chunk", d).Return(nil, errors.New("foo")).Once()
+
+	w := chunktest.NewRecordingWriter()
+	s := data.NewStream(make(chan *chunk.Chunk), w)
+	s.SetChunker(chunker)
+
+	assert.Equal(t, "foo", s.Write(d).Error())
+	assert.Empty(t, w.Chunks())
+}
+
 type MockData struct {
 	mock.Mock
 }
@@ -78,6 +117,14 @@ func (d *MockData) Id() byte {
 	return d.Called().Get(0).(byte)
 }
 
+func (d *MockData) Kind() data.Kind {
+	return d.Called().Get(0).(data.Kind)
+}
+
+func (d *MockData) IsKeyframe() bool {
+	return d.Called().Bool(0)
+}
+
 func (d *MockData) Read(c *chunk.Chunk) error {
 	return d.Called(c).Error(0)
 }
@@ -87,3 +134,437 @@ func (d *MockData) Marshal() (*chunk.Chunk, error) {
 
 	return args.Get(0).(*chunk.Chunk), args.Error(1)
 }
+
+func (d *MockData) Clone() data.Data {
+	return d.Called().Get(0).(data.Data)
+}
+
+// newDroppingTestStream returns a *data.Stream buffering `bufSize` Data, whose
+// parser returns a new Data for each of the `n` chunks returned, and an error
+// for the final chunk.
+func newDroppingTestStream(bufSize, n int) (*data.Stream, []*chunk.Chunk, []data.Data) {
+	s := data.NewBufferedStream(make(chan *chunk.Chunk), chunk.NoopWriter, bufSize)
+
+	parser := &MockParser{}
+	chunks := make([]*chunk.Chunk, n+1)
+	datas := make([]data.Data, n)
+	for i := range chunks {
+		chunks[i] = &chunk.Chunk{Data: []byte{byte(i)}}
+		if i < n {
+			datas[i] = &data.Audio{}
+			parser.On("Parse", chunks[i]).Return(datas[i], nil).Once()
+		} else {
+			parser.On("Parse", chunks[i]).Return(nil, errors.New("foo")).Once()
+		}
+	}
+	s.SetParser(parser)
+
+	return s, chunks, datas
+}
+
+func TestBufferedStreamsHoldDataWithoutAConsumer(t *testing.T) {
+	s, chunks, datas := newDroppingTestStream(2, 2)
+
+	go s.Recv()
+	for _, c := range chunks {
+		s.Chunks() <- c
+	}
+	<-s.Errs()
+
+	assert.True(t, datas[0] == <-s.In())
+	assert.True(t, datas[1] == <-s.In())
+	assert.Equal(t, uint64(0), s.Dropped())
+}
+
+func TestFullBufferedStreamsDropTheOldestData(t *testing.T) {
+	s, chunks, datas := newDroppingTestStream(2, 3)
+	s.SetDropOldest(true)
+
+	go s.Recv()
+	for _, c := range chunks {
+		s.Chunks() <- c
+	}
+	<-s.Errs()
+
+	assert.Equal(t, uint64(1), s.Dropped())
+	assert.True(t, datas[1] == <-s.In())
+	assert.True(t, datas[2] == <-s.In())
+}
+
+func TestUnbufferedStreamsDropTheNewestData(t *testing.T) {
+	s, chunks, _ := newDroppingTestStream(0, 2)
+	s.SetDropOldest(true)
+
+	go s.Recv()
+	for _, c := range chunks {
+		s.Chunks() <- c
+	}
+	<-s.Errs()
+
+	assert.Equal(t, uint64(2), s.Dropped())
+}
+
+func TestDroppedDataIsLogged(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s, chunks, _ := newDroppingTestStream(0, 1)
+	s.SetDropOldest(true)
+	s.SetLogger(log.New(buf, "", 0))
+
+	go s.Recv()
+	for _, c := range chunks {
+		s.Chunks() <- c
+	}
+	<-s.Errs()
+
+	assert.Contains(t, buf.String(), "rtmp/data: dropped frame, 1 dropped in total")
+}
+
+func TestStreamsWithoutAGOPCacheReturnNoGOP(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+
+	assert.Nil(t, s.GOP())
+}
+
+func TestStreamsMaintainTheirGOPCache(t *testing.T) {
+	s := data.NewBufferedStream(make(chan *chunk.Chunk), chunk.NoopWriter, 2)
+	s.SetGOPCacheSize(data.DefaultGOPCacheSize)
+
+	go s.Recv()
+	for _, payload := range [][]byte{Interframe, Keyframe, Interframe} {
+		s.Chunks() <- &chunk.Chunk{
+			Header: &chunk.Header{
+				MessageHeader: chunk.MessageHeader{
+					TypeId: data.VideoTypeId,
+				},
+			},
+			Data: payload,
+		}
+	}
+	<-s.In()
+	<-s.In()
+	<-s.In()
+
+	assert.Equal(t, []data.Data{
+		newVideo(Keyframe), newVideo(Interframe),
+	}, stripHeaders(s.GOP()))
+}
+
+// stripHeaders removes the chunk headers from the given Video frames, so that
+// they may be compared against frames read without one.
+func stripHeaders(ds []data.Data) []data.Data {
+	out := make([]data.Data, len(ds))
+	for i, d := range ds {
+		c, _ := d.Marshal()
+		out[i] = newVideo(c.Data)
+	}
+
+	return out
+}
+
+func TestWriteReturnsErrWriteTimeoutWhenThePeerStopsReading(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	w := chunk.NewWriter(conn, chunk.DefaultReadSize).(*chunk.DefaultWriter)
+	w.SetWriteTimeout(conn, 10*time.Millisecond)
+
+	s := data.NewStream(make(chan *chunk.Chunk), w)
+
+	d := new(MockData)
+	d.On("Marshal").Return(&chunk.Chunk{
+		Header: new(chunk.Header),
+		Data:   []byte{0x0, 0x1, 0x2, 0x3},
+	}, nil).Once()
+
+	assert.Equal(t, chunk.ErrWriteTimeout, s.Write(d))
+}
+
+func TestStreamCloseIsIdempotent(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	go s.Recv()
+
+	s.Close()
+	s.Close()
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestStreamStopsWhenItsChunksAreClosed(t *testing.T) {
+	chunks := make(chan *chunk.Chunk)
+	s := data.NewStream(chunks, chunk.NoopWriter)
+	go s.Recv()
+
+	close(chunks)
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestStreamCloseContextDoesNotWaitForUnreadDataOrErrors(t *testing.T) {
+	for _, err := range []error{nil, errors.New("foo")} {
+		s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+
+		parser := &MockParser{}
+		parser.On("Parse", mock.Anything).Return(new(data.Audio), err)
+		s.SetParser(parser)
+
+		go s.Recv()
+		s.Chunks() <- new(chunk.Chunk)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		assert.Nil(t, s.CloseContext(ctx))
+		cancel()
+	}
+}
+
+func TestStreamCloseBeforeRecvDoesNotBlock(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+
+	s.Close()
+	go s.Recv()
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestStreamCloseContextWaitsForRecvToStop(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	go s.Recv()
+
+	assert.Nil(t, s.CloseContext(context.Background()))
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestStreamCloseContextTimesOutWithoutRecv(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, s.CloseContext(ctx))
+}
+
+func TestCloseAndDrainProcessesBufferedChunks(t *testing.T) {
+	chunks := make(chan *chunk.Chunk, 2)
+	chunks <- new(chunk.Chunk)
+	chunks <- new(chunk.Chunk)
+
+	s := data.NewBufferedStream(chunks, chunk.NoopWriter, 2)
+
+	parser := &MockParser{}
+	parser.On("Parse", mock.Anything).Return(new(data.Audio), nil).Twice()
+	s.SetParser(parser)
+
+	go s.Recv()
+
+	assert.Nil(t, s.CloseAndDrain(context.Background()))
+
+	var n int
+	for range s.In() {
+		n++
+	}
+
+	assert.Equal(t, 2, n)
+	parser.AssertExpectations(t)
+}
+
+func TestCloseAndDrainStopsWhenTheContextIsDone(t *testing.T) {
+	chunks := make(chan *chunk.Chunk, 2)
+	chunks <- new(chunk.Chunk)
+	chunks <- new(chunk.Chunk)
+
+	s := data.NewBufferedStream(chunks, chunk.NoopWriter, 2)
+
+	parser := &MockParser{}
+	parser.On("Parse", mock.Anything).Return(new(data.Audio), nil)
+	s.SetParser(parser)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	go s.Recv()
+
+	assert.Equal(t, context.Canceled, s.CloseAndDrain(ctx))
+}
+
+func TestRecvCachesTheLatestSequenceHeaders(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	go s.Recv()
+	defer s.Close()
+
+	_, ok := s.VideoSequenceHeader()
+	assert.False(t, ok)
+	_, ok = s.AudioSequenceHeader()
+	assert.False(t, ok)
+
+	video := []byte{0x17, 0x00, 0x00, 0x00, 0x00, 0x02}
+	audio := []byte{0xaf, 0x00, 0x12, 0x10}
+	for _, c := range []*chunk.Chunk{
+		newDataChunk(data.VideoTypeId, SequenceHeader),
+		newDataChunk(data.VideoTypeId, Keyframe),
+		newDataChunk(data.VideoTypeId, video),
+		newDataChunk(data.AudioTypeId, audio),
+		newDataChunk(data.AudioTypeId, AudioFrame),
+	} {
+		s.Chunks() <- c
+		<-s.In()
+	}
+
+	v, ok := s.VideoSequenceHeader()
+	assert.True(t, ok)
+	assert.Equal(t, video[1:], v.(*data.Video).Payload())
+
+	a, ok := s.AudioSequenceHeader()
+	assert.True(t, ok)
+	assert.Equal(t, audio[1:], a.(*data.Audio).Payload())
+}
+
+func newDataChunk(typeId byte, payload []byte) *chunk.Chunk {
+	return &chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{
+				Length: uint32(len(payload)),
+				TypeId: typeId,
+			},
+		},
+		Data: payload,
+	}
+}
+
+func TestRecvNormalizesTimestamps(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	n := data.NewTimestampNormalizer(data.DefaultMaxTimestampJump)
+	s.SetTimestampNormalizer(n)
+	go s.Recv()
+	defer s.Close()
+
+	first := newDataChunk(data.VideoTypeId, Interframe)
+	first.Header.SetTimestamp(100)
+	second := newDataChunk(data.VideoTypeId, Interframe)
+	second.Header.SetTimestamp(50)
+
+	s.Chunks() <- first
+	<-s.In()
+	s.Chunks() <- second
+	v := (<-s.In()).(*data.Video)
+
+	assert.EqualValues(t, 100, v.Timestamp())
+	assert.EqualValues(t, 1, s.TimestampNormalizer().Corrections())
+}
+
+func TestStreamCountsBytesInAndOut(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NewWriter(buf, 4))
+	go s.Recv()
+	defer s.Close()
+
+	c := newDataChunk(data.VideoTypeId, Interframe)
+	s.Chunks() <- c
+	v := <-s.In()
+
+	assert.EqualValues(t, c.WireLength(0), s.BytesIn())
+	assert.EqualValues(t, 0, s.BytesOut())
+
+	assert.Nil(t, s.Write(v))
+	assert.EqualValues(t, buf.Len(), s.BytesOut())
+}
+
+func TestStreamRecvStopsWhenTheContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	s.SetContext(ctx)
+	go s.Recv()
+
+	cancel()
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestRecvEndsTheFirstMediaSpanOnceMediaIsReceived(t *testing.T) {
+	r := tracingtest.NewRecorder()
+
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	s.SetContext(tracing.NewContext(context.Background(), r))
+	go s.Recv()
+	defer s.Close()
+
+	s.Chunks() <- newDataChunk(data.VideoTypeId, Keyframe)
+	<-s.In()
+
+	span := r.Wait(tracing.SpanFirstMedia, time.Second)
+	if assert.NotNil(t, span) {
+		assert.Len(t, r.Spans(), 1)
+	}
+}
+
+func TestRecvDropsFramesRejectedByTheInterceptor(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	s.SetInterceptor(func(d data.Data) (data.Data, bool) {
+		return d, d.Kind() != data.AudioKind
+	})
+	go s.Recv()
+	defer s.Close()
+
+	s.Chunks() <- newDataChunk(data.AudioTypeId, AudioFrame)
+	s.Chunks() <- newDataChunk(data.VideoTypeId, Keyframe)
+
+	assert.Equal(t, data.VideoKind, (<-s.In()).Kind())
+}
+
+func TestRecvPassesAlongDataReplacedByTheInterceptor(t *testing.T) {
+	replacement := newVideo(Interframe)
+
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	s.SetInterceptor(func(d data.Data) (data.Data, bool) {
+		return replacement, true
+	})
+	go s.Recv()
+	defer s.Close()
+
+	s.Chunks() <- newDataChunk(data.VideoTypeId, Keyframe)
+
+	assert.Equal(t, replacement, <-s.In())
+}
+
+func TestRecvPassesAlongTheOriginalWhenTheInterceptorReturnsNil(t *testing.T) {
+	s := data.NewStream(make(chan *chunk.Chunk), chunk.NoopWriter)
+	s.SetInterceptor(func(d data.Data) (data.Data, bool) { return nil, true })
+	go s.Recv()
+	defer s.Close()
+
+	s.Chunks() <- newDataChunk(data.VideoTypeId, Keyframe)
+
+	assert.True(t, (<-s.In()).IsKeyframe())
+}
+
+func TestRecvSplitsAggregateMessages(t *testing.T) {
+	chunks := make(chan *chunk.Chunk, 1)
+	s := data.NewStream(chunks, chunk.NoopWriter)
+	go s.Recv()
+	defer s.Close()
+
+	c := newDataChunk(data.AggregateTypeId, Aggregate)
+	c.Header.SetTimestamp(1000)
+	chunks <- c
+
+	for _, expected := range []struct {
+		Kind      data.Kind
+		Timestamp uint32
+	}{
+		{data.VideoKind, 1000},
+		{data.AudioKind, 1020},
+		{data.VideoKind, 1040},
+	} {
+		d := <-s.In()
+		c, err := d.Marshal()
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected.Kind, d.Kind())
+		assert.Equal(t, expected.Timestamp, c.Timestamp())
+	}
+}
@@ -0,0 +1,207 @@
+package data
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/WatchBeam/rtmp/chunk"
+)
+
+const (
+	// DefaultTeeBuffer is the default number of chunks that the Recorder
+	// of a Tee may fall behind by before chunks are dropped from the
+	// recording.
+	DefaultTeeBuffer = 1024
+)
+
+// Recorder records the chunks duplicated by a Tee, for instance to a file.
+// Record is only ever called from a single goroutine at a time.
+type Recorder interface {
+	// Record records the given chunk, returning any error encountered
+	// while doing so.
+	Record(c *chunk.Chunk) error
+}
+
+// rawRecorder is an implementation of the Recorder interface which writes each
+// chunk in the RTMP chunk format.
+type rawRecorder struct {
+	// w is the chunk.Writer that chunks are written to.
+	w chunk.Writer
+}
+
+var _ Recorder = new(rawRecorder)
+
+// NewRawRecorder returns a Recorder which writes each chunk to the given
+// io.Writer in the RTMP chunk format, split into chunks of
+// chunk.DefaultReadSize bytes, such that the recording may be read back with
+// chunk.NewReader. To record a stream as an FLV file instead, use an
+// *flv.FLVWriter (from the github.com/WatchBeam/rtmp/flv package) as the
+// Recorder.
+func NewRawRecorder(w io.Writer) Recorder {
+	return &rawRecorder{w: chunk.NewWriter(w, chunk.DefaultReadSize)}
+}
+
+// Record implements Recorder.Record.
+func (r *rawRecorder) Record(c *chunk.Chunk) error {
+	if err := r.w.Write(c); err != nil {
+		return err
+	}
+
+	if f, ok := r.w.(chunk.Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Tee duplicates each chunk read from a chunk stream to a Recorder, while
+// passing it along unchanged to the consumer of the stream, such as a Stream
+// (see Chunks). This allows a live stream to be recorded while it is relayed,
+// without changing the code which consumes it.
+//
+// The consumer is never held up by the Recorder: chunks are recorded from a
+// goroutine of their own, and chunks which the Recorder has fallen too far
+// behind to accept are dropped from the recording (see Dropped), rather than
+// delayed on their way to the consumer. Likewise, an error returned by the
+// Recorder stops the recording, and is reported over Errs(), but the chunks
+// continue to be passed along.
+type Tee struct {
+	// src is the channel of chunks that are passed along and recorded.
+	src <-chan *chunk.Chunk
+	// out is the channel over which chunks read from src are passed
+	// along.
+	out chan *chunk.Chunk
+
+	// rec is the Recorder that chunks are duplicated to.
+	rec Recorder
+	// recording holds the copies of chunks that have not yet been
+	// recorded.
+	recording chan *chunk.Chunk
+	// failed is non-zero once the Recorder has returned an error. It is
+	// accessed atomically.
+	failed uint32
+	// dropped is the number of chunks dropped from the recording. It is
+	// accessed atomically.
+	dropped uint64
+
+	// errs is written to with the error returned by the Recorder, if
+	// any.
+	errs chan error
+	// closer is closed when the Run operation should halt.
+	closer chan struct{}
+	// closeOnce ensures that closer is closed only once.
+	closeOnce sync.Once
+}
+
+// NewTee returns a new *Tee which passes each chunk received over the given
+// channel along over its Chunks() channel, and records it with the given
+// Recorder. Up to DefaultTeeBuffer chunks may be waiting to be recorded before
+// chunks are dropped from the recording. The Run method is not called.
+func NewTee(src <-chan *chunk.Chunk, rec Recorder) *Tee {
+	return NewBufferedTee(src, rec, DefaultTeeBuffer)
+}
+
+// NewBufferedTee returns a new *Tee, like NewTee, except that up to `bufSize`
+// chunks may be waiting to be recorded before chunks are dropped from the
+// recording.
+func NewBufferedTee(src <-chan *chunk.Chunk, rec Recorder, bufSize int) *Tee {
+	return &Tee{
+		src: src,
+		out: make(chan *chunk.Chunk),
+
+		rec:       rec,
+		recording: make(chan *chunk.Chunk, bufSize),
+
+		errs:   make(chan error, 1),
+		closer: make(chan struct{}),
+	}
+}
+
+// Chunks returns the channel over which each chunk is passed along, which is
+// given to the consumer in place of the channel that the Tee reads from, for
+// instance with NewStream.
+func (t *Tee) Chunks() chan *chunk.Chunk { return t.out }
+
+// Errs returns a channel which is written to with the error returned by the
+// Recorder, if any, once it fails. It is closed once the recording stops.
+func (t *Tee) Errs() <-chan error { return t.errs }
+
+// Dropped returns the number of chunks which were dropped from the recording,
+// because the Recorder fell too far behind.
+func (t *Tee) Dropped() uint64 { return atomic.LoadUint64(&t.dropped) }
+
+// Close halts the Run operation. Chunks which are waiting to be recorded are
+// still recorded. It is safe to call more than once.
+func (t *Tee) Close() { t.closeOnce.Do(func() { close(t.closer) }) }
+
+// Run passes each chunk received along, and records a copy of it, until either
+// the channel that chunks are received from is closed, or Close is called. The
+// Chunks() channel is not closed once it returns, since a Stream does not stop
+// receiving when it is.
+//
+// Run runs within its own goroutine.
+func (t *Tee) Run() {
+	go t.record()
+	defer close(t.recording)
+
+	for {
+		select {
+		case c, ok := <-t.src:
+			if !ok {
+				return
+			}
+
+			t.duplicate(c)
+
+			select {
+			case t.out <- c:
+			case <-t.closer:
+				return
+			}
+		case <-t.closer:
+			return
+		}
+	}
+}
+
+// duplicate queues a copy of the given chunk to be recorded, unless the
+// recording has failed, or fallen too far behind. The chunk is copied, rather
+// than shared, since the consumer may modify or release it (see
+// chunk.Chunk.Release) before it is recorded.
+func (t *Tee) duplicate(c *chunk.Chunk) {
+	if atomic.LoadUint32(&t.failed) != 0 {
+		return
+	}
+
+	cp := &chunk.Chunk{Data: append([]byte(nil), c.Data...)}
+	if c.Header != nil {
+		h := *c.Header
+		cp.Header = &h
+	}
+
+	select {
+	case t.recording <- cp:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+// record records each queued chunk, until the queue is closed. If the Recorder
+// fails, the error is reported over the Errs() channel, and the rest of the
+// queue is discarded.
+//
+// record runs within its own goroutine.
+func (t *Tee) record() {
+	defer close(t.errs)
+
+	for c := range t.recording {
+		if err := t.rec.Record(c); err != nil {
+			atomic.StoreUint32(&t.failed, 1)
+			t.errs <- err
+
+			for range t.recording {
+			}
+			return
+		}
+	}
+}
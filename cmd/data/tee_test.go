@@ -0,0 +1,135 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+// chanRecorder is a data.Recorder which pushes each chunk recorded onto a
+// channel, or returns err if it is non-nil.
+type chanRecorder struct {
+	chunks chan *chunk.Chunk
+	err    error
+}
+
+var _ data.Recorder = new(chanRecorder)
+
+func (r *chanRecorder) Record(c *chunk.Chunk) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.chunks <- c
+	return nil
+}
+
+// blockingRecorder is a data.Recorder which pushes each chunk recorded onto
+// the entered channel, and then blocks until release is closed.
+type blockingRecorder struct {
+	entered chan *chunk.Chunk
+	release chan struct{}
+}
+
+var _ data.Recorder = new(blockingRecorder)
+
+func (r *blockingRecorder) Record(c *chunk.Chunk) error {
+	r.entered <- c
+	<-r.release
+
+	return nil
+}
+
+func TestTeePassesChunksAlongAndRecordsThem(t *testing.T) {
+	src := make(chan *chunk.Chunk)
+	rec := &chanRecorder{chunks: make(chan *chunk.Chunk, 1)}
+
+	tee := data.NewTee(src, rec)
+	go tee.Run()
+	defer tee.Close()
+
+	c := newDataChunk(data.VideoTypeId, []byte{0x17, 0x01})
+	src <- c
+
+	assert.Equal(t, c, <-tee.Chunks())
+
+	recorded := <-rec.chunks
+	assert.Equal(t, c, recorded)
+	assert.False(t, c == recorded, "recorded chunk should be a copy")
+}
+
+func TestTeeRecordsAPrivateCopyOfEachChunk(t *testing.T) {
+	src := make(chan *chunk.Chunk)
+	rec := &chanRecorder{chunks: make(chan *chunk.Chunk, 1)}
+
+	tee := data.NewTee(src, rec)
+	go tee.Run()
+	defer tee.Close()
+
+	src <- newDataChunk(data.AudioTypeId, []byte{0xaf, 0x01})
+	c := <-tee.Chunks()
+	c.Data[1] = 0xff
+
+	assert.Equal(t, []byte{0xaf, 0x01}, (<-rec.chunks).Data)
+}
+
+func TestTeeKeepsPassingChunksAlongWhenRecordingFails(t *testing.T) {
+	err := errors.New("disk full")
+
+	src := make(chan *chunk.Chunk)
+	tee := data.NewTee(src, &chanRecorder{err: err})
+	go tee.Run()
+	defer tee.Close()
+
+	src <- newDataChunk(data.VideoTypeId, []byte{0x17, 0x01})
+	<-tee.Chunks()
+
+	assert.Equal(t, err, <-tee.Errs())
+
+	c := newDataChunk(data.VideoTypeId, []byte{0x27, 0x01})
+	src <- c
+	assert.Equal(t, c, <-tee.Chunks())
+}
+
+func TestTeeDropsChunksFromTheRecordingWhenItFallsBehind(t *testing.T) {
+	src := make(chan *chunk.Chunk)
+	rec := &blockingRecorder{
+		entered: make(chan *chunk.Chunk, 3),
+		release: make(chan struct{}),
+	}
+	defer close(rec.release)
+
+	tee := data.NewBufferedTee(src, rec, 1)
+	go tee.Run()
+	defer tee.Close()
+
+	src <- newDataChunk(data.VideoTypeId, []byte{0x27, 0x00})
+	<-tee.Chunks()
+	first := <-rec.entered
+
+	// The first chunk is held by the Recorder, so the second fills the
+	// buffer, and the third is dropped.
+	for i := 1; i < 3; i++ {
+		src <- newDataChunk(data.VideoTypeId, []byte{0x27, byte(i)})
+		<-tee.Chunks()
+	}
+
+	assert.Equal(t, byte(0), first.Data[1])
+	assert.Equal(t, uint64(1), tee.Dropped())
+}
+
+func TestRawRecorderWritesChunksInTheChunkFormat(t *testing.T) {
+	c := newDataChunk(data.VideoTypeId, []byte{0x17, 0x01})
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, data.NewRawRecorder(buf).Record(c))
+
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(c)
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
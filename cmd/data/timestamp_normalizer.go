@@ -0,0 +1,105 @@
+package data
+
+import "sync"
+
+const (
+	// DefaultMaxTimestampJump is the default largest difference, in
+	// milliseconds, between consecutive timestamps of the same Kind that a
+	// TimestampNormalizer treats as continuous.
+	DefaultMaxTimestampJump uint32 = 1000
+)
+
+// TimestampNormalizer corrects the timestamps of Audio and Video sent by
+// misbehaving encoders, which would otherwise cause players to freeze. The
+// timestamps of each Kind of Data are kept monotonic, as follows:
+//   - A timestamp that goes backwards by no more than the maximum jump is
+//     clamped to the previous timestamp of the same Kind.
+//   - A timestamp that goes backwards, or jumps forwards, by more than the
+//     maximum jump is treated as a discontinuity. The frame is given the
+//     previous timestamp of the same Kind, and all subsequent timestamps are
+//     shifted by the same amount, so that the gap is closed.
+//
+// Corrected timestamps are set on the Data itself (and are therefore written
+// when it is marshaled), and counted by Corrections(). Other Data, such as
+// DataFrames, is left untouched.
+//
+// TimestampNormalizer is safe for concurrent use.
+type TimestampNormalizer struct {
+	// maxJump is the largest difference between consecutive timestamps
+	// that is treated as continuous.
+	maxJump int64
+
+	// mu guards offset, last, and corrections.
+	mu sync.Mutex
+	// offset is added to each incoming timestamp to close the gaps left
+	// by previous discontinuities.
+	offset int64
+	// last maps each Kind to the last timestamp given to Data of that
+	// Kind.
+	last map[Kind]int64
+	// corrections is the number of timestamps that have been corrected.
+	corrections uint64
+}
+
+// NewTimestampNormalizer returns a new *TimestampNormalizer, which treats
+// differences of up to `maxJump` milliseconds between consecutive timestamps
+// as continuous.
+func NewTimestampNormalizer(maxJump uint32) *TimestampNormalizer {
+	return &TimestampNormalizer{
+		maxJump: int64(maxJump),
+		last:    make(map[Kind]int64),
+	}
+}
+
+// Normalize corrects the timestamp of the given Data, if necessary, and returns
+// whether or not it was corrected.
+func (n *TimestampNormalizer) Normalize(d Data) bool {
+	t, ok := d.(timestamped)
+	if !ok {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	in := int64(t.Timestamp())
+	ts := in + n.offset
+
+	if last, ok := n.last[d.Kind()]; ok {
+		delta := ts - last
+		if delta > n.maxJump || delta < -n.maxJump {
+			n.offset -= delta
+			ts = last
+		} else if delta < 0 {
+			ts = last
+		}
+	}
+
+	if ts < 0 {
+		ts = 0
+	}
+
+	n.last[d.Kind()] = ts
+	if ts == in {
+		return false
+	}
+
+	t.SetTimestamp(uint32(ts))
+	n.corrections++
+
+	return true
+}
+
+// Corrections returns the number of timestamps that have been corrected.
+func (n *TimestampNormalizer) Corrections() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.corrections
+}
+
+// timestamped is implemented by the Audio and Video types.
+type timestamped interface {
+	Timestamp() uint32
+	SetTimestamp(ts uint32)
+}
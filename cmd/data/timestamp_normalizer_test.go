@@ -0,0 +1,92 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTimestampedVideo(ts uint32) *data.Video {
+	h := new(chunk.Header)
+	h.SetTimestamp(ts)
+
+	v := new(data.Video)
+	v.Read(&chunk.Chunk{Header: h, Data: Interframe})
+
+	return v
+}
+
+func newTimestampedAudio(ts uint32) *data.Audio {
+	h := new(chunk.Header)
+	h.SetTimestamp(ts)
+
+	a := new(data.Audio)
+	a.Read(&chunk.Chunk{Header: h, Data: AudioFrame})
+
+	return a
+}
+
+func TestTimestampNormalizerPassesMonotonicTimestampsThrough(t *testing.T) {
+	n := data.NewTimestampNormalizer(data.DefaultMaxTimestampJump)
+
+	for _, ts := range []uint32{0, 33, 66, 100} {
+		v := newTimestampedVideo(ts)
+
+		assert.False(t, n.Normalize(v))
+		assert.Equal(t, ts, v.Timestamp())
+	}
+
+	assert.EqualValues(t, 0, n.Corrections())
+}
+
+func TestTimestampNormalizerClampsBackwardsTimestamps(t *testing.T) {
+	n := data.NewTimestampNormalizer(data.DefaultMaxTimestampJump)
+
+	n.Normalize(newTimestampedVideo(100))
+	v := newTimestampedVideo(90)
+
+	assert.True(t, n.Normalize(v))
+	assert.EqualValues(t, 100, v.Timestamp())
+	assert.EqualValues(t, 1, n.Corrections())
+}
+
+func TestTimestampNormalizerClosesLargeJumps(t *testing.T) {
+	n := data.NewTimestampNormalizer(data.DefaultMaxTimestampJump)
+
+	n.Normalize(newTimestampedVideo(100))
+	n.Normalize(newTimestampedAudio(110))
+
+	jump := newTimestampedVideo(60100)
+	assert.True(t, n.Normalize(jump))
+	assert.EqualValues(t, 100, jump.Timestamp())
+
+	audio := newTimestampedAudio(60120)
+	assert.True(t, n.Normalize(audio))
+	assert.EqualValues(t, 120, audio.Timestamp())
+
+	next := newTimestampedVideo(60133)
+	assert.True(t, n.Normalize(next))
+	assert.EqualValues(t, 133, next.Timestamp())
+}
+
+func TestTimestampNormalizerClosesLargeBackwardsJumps(t *testing.T) {
+	n := data.NewTimestampNormalizer(data.DefaultMaxTimestampJump)
+
+	n.Normalize(newTimestampedVideo(60000))
+
+	restart := newTimestampedVideo(0)
+	assert.True(t, n.Normalize(restart))
+	assert.EqualValues(t, 60000, restart.Timestamp())
+
+	next := newTimestampedVideo(33)
+	assert.True(t, n.Normalize(next))
+	assert.EqualValues(t, 60033, next.Timestamp())
+}
+
+func TestTimestampNormalizerIgnoresDataFrames(t *testing.T) {
+	n := data.NewTimestampNormalizer(data.DefaultMaxTimestampJump)
+
+	assert.False(t, n.Normalize(new(data.DataFrame)))
+}
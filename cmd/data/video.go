@@ -4,6 +4,23 @@ const (
 	VideoTypeId byte = 0x09
 )
 
+const (
+	// avcCodecId and hevcCodecId are the codec IDs carried in the low
+	// nibble of the control byte of AVC and (non-enhanced) HEVC payloads.
+	avcCodecId  byte = 7
+	hevcCodecId byte = 12
+
+	// exHeaderFlag is set on the control byte of Enhanced RTMP payloads,
+	// where the frame type occupies the following three bits and the codec
+	// is identified by the FourCC following the control byte.
+	exHeaderFlag byte = 0x80
+
+	// manyTracksManyCodecs is the multitrack type, carried in the high
+	// nibble of the byte following the control byte of multitrack
+	// payloads, of payloads whose tracks each carry their own FourCC.
+	manyTracksManyCodecs byte = 2
+)
+
 const (
 	SorensenH263VideoCodec VideoCodec = iota
 	ScreenVideoVideoCodec
@@ -14,13 +31,51 @@ const (
 )
 
 const (
-	KeyframeVideoType VideoType = iota
+	// HEVCVideoCodec is the codec of HEVC (H.265) video, whether it is
+	// signaled by its FLV codec ID (12), or by the "hvc1" FourCC of an
+	// Enhanced RTMP extended header.
+	HEVCVideoCodec VideoCodec = VideoCodec(hevcCodecId)
+	// UnknownVideoCodec is the codec of video sent with an Enhanced RTMP
+	// extended header whose FourCC is not recognized.
+	UnknownVideoCodec VideoCodec = 0xff
+)
+
+// The VideoType constants take the values of the frame type carried in the
+// high nibble of the control byte of FLV video (see Video.Type), where 0 is
+// reserved.
+const (
+	KeyframeVideoType VideoType = iota + 1
 	InterframeVideoType
 	DisposableInterframeVideoType
 	GeneratedKeyFrameVideoType
 	CommandFrameVideoType
 )
 
+const (
+	// SequenceStartVideoPacketType is the packet type of a sequence
+	// header, carrying the decoder configuration record.
+	SequenceStartVideoPacketType VideoPacketType = iota
+	// CodedFramesVideoPacketType is the packet type of coded frames,
+	// preceded by their composition time offset.
+	CodedFramesVideoPacketType
+	// SequenceEndVideoPacketType is the packet type marking the end of a
+	// sequence.
+	SequenceEndVideoPacketType
+	// CodedFramesXVideoPacketType is the packet type of coded frames
+	// whose composition time offset is zero, and omitted.
+	CodedFramesXVideoPacketType
+	// MetadataVideoPacketType is the packet type of video metadata, such
+	// as HDR information, encoded in AMF.
+	MetadataVideoPacketType
+	// MPEG2TSSequenceStartVideoPacketType is the packet type of an AV1
+	// sequence header as carried in MPEG-2 TS.
+	MPEG2TSSequenceStartVideoPacketType
+	// MultitrackVideoPacketType is the packet type of payloads carrying
+	// one or more tracks (see Video.IsMultitrack), whose packet type
+	// follows in the next byte.
+	MultitrackVideoPacketType
+)
+
 type (
 	// VideoType is a singleton representation of what type of video data is
 	// encoded in this frame of Video.
@@ -29,6 +84,11 @@ type (
 	// VideoCodec is a singleton representation of which codec was used to
 	// encoded the data contained in this frame of Video.
 	VideoCodec byte
+
+	// VideoPacketType is the kind of packet carried by a frame of Video:
+	// either a sequence header, coded frames, or the end of a sequence,
+	// and, for Enhanced RTMP payloads, metadata.
+	VideoPacketType byte
 )
 
 // Video is an implementation of the Data interface for video frames.
@@ -39,8 +99,166 @@ var _ Data = new(Video)
 // Id implements Data.Id.
 func (v *Video) Id() byte { return VideoTypeId }
 
-// Codec returns the VideoCodec assosciated with this frame of Video.
-func (v *Video) Codec() VideoCodec { return VideoCodec((v.Control() & 0x0f) >> 0) }
+// Kind implements Data.Kind.
+func (v *Video) Kind() Kind { return VideoKind }
+
+// Clone implements Data.Clone.
+func (v *Video) Clone() Data { return &Video{v.data.clone()} }
+
+// Codec returns the VideoCodec assosciated with this frame of Video. Frames
+// sent with an Enhanced RTMP extended header are identified by their FourCC
+// (see FourCC) instead, of which only HEVCFourCC has a VideoCodec
+// (HEVCVideoCodec); any other is reported as UnknownVideoCodec.
+func (v *Video) Codec() VideoCodec {
+	if v.IsEnhanced() {
+		if v.isHEVC() {
+			return HEVCVideoCodec
+		}
+		return UnknownVideoCodec
+	}
+
+	return VideoCodec((v.Control() & 0x0f) >> 0)
+}
+
+// Type returns the VideoType assosciated with this frame of Video. The flag
+// marking an Enhanced RTMP extended header is not part of the type.
+func (v *Video) Type() VideoType {
+	if v.IsEnhanced() {
+		return VideoType((v.Control() & 0x70) >> 4)
+	}
+
+	return VideoType((v.Control() & 0xf0) >> 4)
+}
+
+// IsEnhanced returns whether or not this frame of Video was sent with an
+// Enhanced RTMP extended header, in which case its codec is identified by its
+// FourCC, rather than by its codec ID.
+func (v *Video) IsEnhanced() bool {
+	return len(v.data.data) > 0 && v.Control()&exHeaderFlag != 0
+}
+
+// IsMultitrack returns whether or not this frame of Video was sent with an
+// Enhanced RTMP extended header carrying one or more tracks, rather than a
+// single, implicit track.
+func (v *Video) IsMultitrack() bool {
+	return v.IsEnhanced() &&
+		VideoPacketType(v.Control()&0x0f) == MultitrackVideoPacketType
+}
+
+// FourCC returns the FourCC identifying the codec of this frame of Video, and
+// whether or not it carries one at all: only frames sent with an Enhanced RTMP
+// extended header do (see IsEnhanced). Of multitrack frames whose tracks each
+// carry their own FourCC, the FourCC of the first track is returned.
+func (v *Video) FourCC() (FourCC, bool) {
+	if !v.IsEnhanced() {
+		return "", false
+	}
+
+	if v.IsMultitrack() {
+		// The FourCC follows the multitrack type and packet type, and
+		// is either shared by all tracks, or that of the first.
+		return fourCCAt(v.data.data, 2)
+	}
+
+	return fourCCAt(v.data.data, 1)
+}
+
+// PacketType returns the VideoPacketType of this frame of Video. Of frames sent
+// with an Enhanced RTMP extended header, it is carried in the low nibble of the
+// control byte, or, for multitrack frames, in the byte following it. Of AVC and
+// HEVC frames sent without one, it is the packet type following the control
+// byte, whose values for sequence headers, coded frames, and the end of a
+// sequence are the same. Frames encoded with any other codec carry only coded
+// frames.
+func (v *Video) PacketType() VideoPacketType {
+	switch {
+	case v.IsMultitrack():
+		if len(v.data.data) < 2 {
+			return MultitrackVideoPacketType
+		}
+		return VideoPacketType(v.data.data[1] & 0x0f)
+	case v.IsEnhanced():
+		return VideoPacketType(v.Control() & 0x0f)
+	case len(v.data.data) > 1 && (v.isAVC() || v.isHEVC()):
+		return VideoPacketType(v.data.data[1])
+	}
+
+	return CodedFramesVideoPacketType
+}
+
+// IsKeyframe implements Data.IsKeyframe. It returns whether or not this is an
+// AVC, HEVC, AV1, or VP9 keyframe, as indicated by its frame type (see Type).
+// Frames encoded with any other codec are never reported as keyframes.
+func (v *Video) IsKeyframe() bool {
+	if !v.isAVC() && !v.isHEVC() && !v.isEnhancedCodec() {
+		return false
+	}
+
+	return v.Type() == KeyframeVideoType
+}
+
+// isInterframe returns whether or not this is an AVC, HEVC, AV1, or VP9
+// inter-frame (disposable or not), which depends on the frames before it, as
+// indicated by its frame type (see Type). As with IsKeyframe, frames encoded
+// with any other codec are never reported as inter-frames.
+func (v *Video) isInterframe() bool {
+	if !v.isAVC() && !v.isHEVC() && !v.isEnhancedCodec() {
+		return false
+	}
+
+	switch v.Type() {
+	case InterframeVideoType, DisposableInterframeVideoType:
+		return true
+	}
+	return false
+}
+
+// isAVC returns whether or not this frame of Video is encoded with AVC.
+func (v *Video) isAVC() bool {
+	return !v.IsEnhanced() && len(v.data.data) > 0 &&
+		v.Control()&0x0f == avcCodecId
+}
+
+// isHEVC returns whether or not this frame of Video is encoded with HEVC,
+// signaled either by its codec ID, or by its FourCC.
+func (v *Video) isHEVC() bool {
+	if v.IsEnhanced() {
+		f, _ := v.FourCC()
+		return f == HEVCFourCC
+	}
+
+	return len(v.data.data) > 0 && v.Control()&0x0f == hevcCodecId
+}
+
+// isEnhancedCodec returns whether or not this frame of Video is sent with an
+// Enhanced RTMP extended header, and encoded with a recognized codec.
+func (v *Video) isEnhancedCodec() bool {
+	f, ok := v.FourCC()
+	if !ok {
+		return false
+	}
+
+	switch f {
+	case AV1FourCC, VP9FourCC, HEVCFourCC:
+		return true
+	}
+	return false
+}
+
+// isSequenceHeader returns whether or not this frame of Video is a sequence
+// header, carrying the decoder configuration record, of AVC or HEVC video, or
+// of video sent with an Enhanced RTMP extended header of a recognized codec
+// (see PacketType).
+func (v *Video) isSequenceHeader() bool {
+	if v.IsEnhanced() {
+		switch v.PacketType() {
+		case SequenceStartVideoPacketType,
+			MPEG2TSSequenceStartVideoPacketType:
+			return v.isEnhancedCodec()
+		}
+		return false
+	}
 
-// Type returns the VideoType assosciated with this frame of Video.
-func (v *Video) Type() VideoType { return VideoType((v.Control() & 0xf0) >> 4) }
+	return (v.isAVC() || v.isHEVC()) &&
+		v.PacketType() == SequenceStartVideoPacketType
+}
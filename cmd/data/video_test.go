@@ -36,11 +36,12 @@ func TestVideoProducesCorrectTypes(t *testing.T) {
 		Control   byte
 		VideoType VideoType
 	}{
-		{0x0, KeyframeVideoType},
-		{0x1, KeyframeVideoType},
-		{0x2, KeyframeVideoType},
-		{0x3, KeyframeVideoType},
-		{0x4, KeyframeVideoType},
+		{0x17, KeyframeVideoType},
+		{0x27, InterframeVideoType},
+		{0x37, DisposableInterframeVideoType},
+		{0x47, GeneratedKeyFrameVideoType},
+		{0x57, CommandFrameVideoType},
+		{0x90, KeyframeVideoType},
 	} {
 		d := new(Video)
 		d.data.data = []byte{c.Control}
@@ -48,3 +49,159 @@ func TestVideoProducesCorrectTypes(t *testing.T) {
 		assert.Equal(t, c.VideoType, d.Type())
 	}
 }
+
+func TestVideoDetectsKeyframes(t *testing.T) {
+	for _, c := range []struct {
+		Payload    []byte
+		IsKeyframe bool
+	}{
+		// AVC keyframe and interframe.
+		{[]byte{0x17, 0x01, 0x00, 0x00, 0x00, 0x65}, true},
+		{[]byte{0x27, 0x01, 0x00, 0x00, 0x00, 0x41}, false},
+		// HEVC keyframe and interframe.
+		{[]byte{0x1c, 0x01, 0x00, 0x00, 0x00, 0x26}, true},
+		{[]byte{0x2c, 0x01, 0x00, 0x00, 0x00, 0x02}, false},
+		// Enhanced RTMP HEVC keyframe and interframe.
+		{[]byte{0x91, 'h', 'v', 'c', '1', 0x00, 0x00, 0x00}, true},
+		{[]byte{0xa1, 'h', 'v', 'c', '1', 0x00, 0x00, 0x00}, false},
+		// On2 VP6 keyframe.
+		{[]byte{0x14, 0x00}, false},
+		{[]byte{}, false},
+	} {
+		d := new(Video)
+		d.data.data = c.Payload
+
+		assert.Equal(t, c.IsKeyframe, d.IsKeyframe(), "%x", c.Payload)
+	}
+}
+
+func TestNonVideoDataAreNeverKeyframes(t *testing.T) {
+	a := new(Audio)
+	a.data.data = []byte{0x17, 0x01}
+
+	assert.False(t, a.IsKeyframe())
+	assert.False(t, new(DataFrame).IsKeyframe())
+}
+
+var (
+	// HEVCSequenceHeader is an HEVC sequence header signaled by the
+	// legacy FLV codec ID (12), carrying a decoder configuration record
+	// for Main profile, level 3.1.
+	HEVCSequenceHeader = []byte{
+		0x1c, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x60, 0x00, 0x00,
+		0x00, 0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x5d, 0xf0, 0x00,
+		0xfc, 0xfd, 0xf8, 0xf8, 0x00, 0x00, 0x0f, 0x00,
+	}
+
+	// EnhancedHEVCSequenceHeader is the same sequence header, signaled
+	// by an Enhanced RTMP extended header with the "hvc1" FourCC.
+	EnhancedHEVCSequenceHeader = []byte{
+		0x90, 0x68, 0x76, 0x63, 0x31, 0x01, 0x01, 0x60, 0x00, 0x00,
+		0x00, 0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x5d, 0xf0, 0x00,
+		0xfc, 0xfd, 0xf8, 0xf8, 0x00, 0x00, 0x0f, 0x00,
+	}
+)
+
+func TestVideoRecognizesHEVC(t *testing.T) {
+	for _, c := range []struct {
+		Payload []byte
+		Codec   VideoCodec
+		Type    VideoType
+	}{
+		{HEVCSequenceHeader, HEVCVideoCodec, KeyframeVideoType},
+		{EnhancedHEVCSequenceHeader, HEVCVideoCodec, KeyframeVideoType},
+		{[]byte{0xa1, 'h', 'v', 'c', '1', 0x00, 0x00, 0x00}, HEVCVideoCodec, InterframeVideoType},
+		{[]byte{0x91, 'a', 'v', '0', '1'}, UnknownVideoCodec, KeyframeVideoType},
+	} {
+		d := new(Video)
+		d.data.data = c.Payload
+
+		assert.Equal(t, c.Codec, d.Codec(), "%x", c.Payload)
+		assert.Equal(t, c.Type, d.Type(), "%x", c.Payload)
+	}
+}
+
+func TestVideoDetectsSequenceHeaders(t *testing.T) {
+	for _, c := range []struct {
+		Payload          []byte
+		IsSequenceHeader bool
+	}{
+		{[]byte{0x17, 0x00, 0x00, 0x00, 0x00, 0x01}, true},
+		{[]byte{0x17, 0x01, 0x00, 0x00, 0x00, 0x65}, false},
+		{HEVCSequenceHeader, true},
+		{[]byte{0x1c, 0x01, 0x00, 0x00, 0x00, 0x26}, false},
+		{EnhancedHEVCSequenceHeader, true},
+		{[]byte{0x91, 'h', 'v', 'c', '1', 0x00, 0x00, 0x00}, false},
+		{[]byte{0x90, 'a', 'v', '0', '1', 0x81}, true},
+		{[]byte{0x90, 'v', 'p', '0', '9', 0x01}, true},
+		{[]byte{0x96, 0x00, 'h', 'v', 'c', '1', 0x00, 0x01}, true},
+		{[]byte{0x90, 'a', 'v', 'c', '2', 0x01}, false},
+		{[]byte{0x14, 0x00}, false},
+	} {
+		d := new(Video)
+		d.data.data = c.Payload
+
+		assert.Equal(t, c.IsSequenceHeader, d.isSequenceHeader(),
+			"%x", c.Payload)
+	}
+}
+
+func TestVideoDecodesEnhancedHeaders(t *testing.T) {
+	for _, c := range []struct {
+		Payload    []byte
+		FourCC     FourCC
+		PacketType VideoPacketType
+		IsKeyframe bool
+	}{
+		// AV1 sequence start, and coded keyframe.
+		{[]byte{0x90, 'a', 'v', '0', '1', 0x81}, AV1FourCC,
+			SequenceStartVideoPacketType, true},
+		{[]byte{0x91, 'a', 'v', '0', '1', 0x12}, AV1FourCC,
+			CodedFramesVideoPacketType, true},
+		// VP9 interframe, without a composition time offset.
+		{[]byte{0xa3, 'v', 'p', '0', '9', 0x86}, VP9FourCC,
+			CodedFramesXVideoPacketType, false},
+		// HEVC end of sequence.
+		{[]byte{0x92, 'h', 'v', 'c', '1'}, HEVCFourCC,
+			SequenceEndVideoPacketType, true},
+		// Multitrack HEVC sequence start, in one track.
+		{[]byte{0x96, 0x00, 'h', 'v', 'c', '1', 0x00, 0x01}, HEVCFourCC,
+			SequenceStartVideoPacketType, true},
+		// Unrecognized codec.
+		{[]byte{0x91, 'a', 'v', 'c', '2', 0x00}, FourCC("avc2"),
+			CodedFramesVideoPacketType, false},
+	} {
+		d := new(Video)
+		d.data.data = c.Payload
+
+		f, ok := d.FourCC()
+
+		assert.True(t, d.IsEnhanced(), "%x", c.Payload)
+		assert.True(t, ok, "%x", c.Payload)
+		assert.Equal(t, c.FourCC, f, "%x", c.Payload)
+		assert.Equal(t, c.PacketType, d.PacketType(), "%x", c.Payload)
+		assert.Equal(t, c.IsKeyframe, d.IsKeyframe(), "%x", c.Payload)
+	}
+}
+
+func TestVideoDecodesLegacyPacketTypes(t *testing.T) {
+	for _, c := range []struct {
+		Payload    []byte
+		PacketType VideoPacketType
+	}{
+		{[]byte{0x17, 0x00, 0x00, 0x00, 0x00}, SequenceStartVideoPacketType},
+		{[]byte{0x27, 0x01, 0x00, 0x00, 0x00}, CodedFramesVideoPacketType},
+		{[]byte{0x17, 0x02, 0x00, 0x00, 0x00}, SequenceEndVideoPacketType},
+		{HEVCSequenceHeader, SequenceStartVideoPacketType},
+		{[]byte{0x12, 0x00}, CodedFramesVideoPacketType},
+	} {
+		d := new(Video)
+		d.data.data = c.Payload
+
+		_, ok := d.FourCC()
+
+		assert.False(t, d.IsEnhanced(), "%x", c.Payload)
+		assert.False(t, ok, "%x", c.Payload)
+		assert.Equal(t, c.PacketType, d.PacketType(), "%x", c.Payload)
+	}
+}
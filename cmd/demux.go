@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/WatchBeam/rtmp/cmd/stream"
+)
+
+var (
+	// MediaGate filters chunks to only those carrying audio, video, or
+	// script data, or aggregate messages packing several of them.
+	MediaGate = NewAnyGate(
+		&TypeIdGate{0x08}, &TypeIdGate{0x09}, &TypeIdGate{0x12},
+		&TypeIdGate{0x16},
+	)
+
+	// ControlGate filters chunks to only those carrying protocol control
+	// messages: Set Chunk Size, Abort Message, Acknowledgement, User
+	// Control Message, Window Acknowledgement Size, and Set Peer Bandwidth.
+	ControlGate = NewAnyGate(
+		&TypeIdGate{0x01}, &TypeIdGate{0x02}, &TypeIdGate{0x03},
+		&TypeIdGate{0x04}, &TypeIdGate{0x05}, &TypeIdGate{0x06},
+	)
+
+	// CommandGate filters chunks to only those carrying AMF0 or AMF3
+	// commands.
+	CommandGate = NewAnyGate(
+		&TypeIdGate{stream.Amf0CmdTypeId},
+		&TypeIdGate{stream.AMF3CommandTypeId},
+	)
+)
+
+// Demux demultiplexes the chunks received over a single connection by their
+// message stream ID, such that many message streams may be multiplexed over
+// one connection.
+//
+// Each message stream is created the first time it is asked for, or the first
+// time a chunk is received over it. Media chunks (see MediaGate) are parsed by
+// a *data.Stream belonging to that message stream, and all other chunks are
+// passed along over the channel returned by Stream().
+//
+// Protocol control messages (see ControlGate) are the exception: they belong
+// to the connection as a whole, rather than to any message stream, so they are
+// passed along over Control() instead, whatever message stream ID they were
+// sent with. The RTMP specification requires them to be sent over message
+// stream 0 (and chunk stream 2), but some peers do not, and they must never
+// reach a *data.Stream, nor create a message stream.
+//
+// When a "closeStream" command is received over a message stream, or a
+// "deleteStream" command naming a message stream is received, the command is
+// passed along, and then that message stream is torn down. A "deleteStream"
+// command also releases the message stream's ID (see SetStreamIds).
+type Demux struct {
+	// chunks is the incoming chunk stream to demultiplex.
+	chunks chunk.Stream
+	// writer is the chunk.Writer given to each *data.Stream, which writes
+	// each of their tracks over a chunk stream of its own.
+	writer *chunk.AllocatingWriter
+	// control is the channel of protocol control messages.
+	control controlChunks
+
+	// smu guards streams.
+	smu sync.Mutex
+	// streams maps message stream IDs to their demultiplexed stream.
+	streams map[uint32]*demuxed
+
+	// imu guards ids.
+	imu sync.Mutex
+	// ids is the *stream.StreamIdAllocator that deleted message stream IDs
+	// are released to, if any.
+	ids *stream.StreamIdAllocator
+
+	// closer is written to when the Demux should stop receiving.
+	closer chan struct{}
+}
+
+// controlChunks is the chunk.Stream of protocol control messages returned by
+// Demux.Control.
+type controlChunks chan *chunk.Chunk
+
+var _ chunk.Stream = make(controlChunks)
+
+// In implements chunk.Stream.In.
+func (c controlChunks) In() <-chan *chunk.Chunk { return c }
+
+// demuxed is a single message stream demultiplexed by a Demux.
+type demuxed struct {
+	// chunks is the channel of non-media chunks.
+	chunks chan *chunk.Chunk
+	// data is the *data.Stream which parses media chunks.
+	data *data.Stream
+}
+
+// NewDemux returns a new *Demux which demultiplexes the given chunk stream,
+// writing any Data back over the given chunk.Writer. The Recv method is not
+// called.
+//
+// Data is written over the chunk streams allocated to each message stream and
+// type (see chunk.AllocatingWriter), which are released once the message stream
+// is torn down. If the given chunk.Writer is itself a *chunk.AllocatingWriter,
+// its chunk streams are shared, rather than wrapped again.
+func NewDemux(chunks chunk.Stream, writer chunk.Writer) *Demux {
+	w, ok := writer.(*chunk.AllocatingWriter)
+	if !ok {
+		w = chunk.NewAllocatingWriter(writer, nil)
+	}
+
+	return &Demux{
+		chunks:  chunks,
+		writer:  w,
+		control: make(controlChunks),
+		streams: make(map[uint32]*demuxed),
+		closer:  make(chan struct{}),
+	}
+}
+
+// Control returns the chunk.Stream of protocol control messages received over
+// any message stream (see ControlGate), which is typically given to a
+// *control.Stream. Its channel is closed once Recv returns.
+func (d *Demux) Control() chunk.Stream { return d.control }
+
+// Stream returns the channel of non-media chunks received over the message
+// stream with the given ID, creating the message stream if it does not yet
+// exist. The channel is closed when the message stream is torn down.
+func (d *Demux) Stream(id uint32) <-chan *chunk.Chunk {
+	return d.stream(id).chunks
+}
+
+// Data returns the *data.Stream parsing the media chunks received over the
+// message stream with the given ID, creating the message stream if it does not
+// yet exist. The *data.Stream is closed when the message stream is torn down.
+func (d *Demux) Data(id uint32) *data.Stream {
+	return d.stream(id).data
+}
+
+// Streams returns the IDs of all open message streams, in ascending order.
+func (d *Demux) Streams() []uint32 {
+	d.smu.Lock()
+	defer d.smu.Unlock()
+
+	ids := make([]uint32, 0, len(d.streams))
+	for id := range d.streams {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+// CloseStream tears down the message stream with the given ID, closing its
+// channel of chunks and its *data.Stream, and releasing the chunk streams
+// allocated to its tracks. It returns false if no such message stream was open.
+func (d *Demux) CloseStream(id uint32) bool {
+	d.smu.Lock()
+	s, ok := d.streams[id]
+	delete(d.streams, id)
+	d.smu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	s.data.Close()
+	close(s.chunks)
+	d.writer.ChunkStreams().ReleaseStream(id)
+
+	return true
+}
+
+// SetStreamIds sets the *stream.StreamIdAllocator that message stream IDs are
+// released to when a "deleteStream" command is received. This is typically the
+// allocator of the NetStream that created them (see stream.NetStream's
+// StreamIds).
+func (d *Demux) SetStreamIds(ids *stream.StreamIdAllocator) {
+	d.imu.Lock()
+	defer d.imu.Unlock()
+
+	d.ids = ids
+}
+
+// release releases the given message stream ID, if a
+// *stream.StreamIdAllocator has been set.
+func (d *Demux) release(id uint32) {
+	d.imu.Lock()
+	defer d.imu.Unlock()
+
+	if d.ids != nil {
+		d.ids.Release(id)
+	}
+}
+
+// Close halts the Recv operation, tearing down all open message streams.
+func (d *Demux) Close() { d.closer <- struct{}{} }
+
+// Recv routes each chunk received over the chunk stream to the message stream
+// that it was sent over, or to Control() if it is a protocol control message,
+// until either the chunk stream is closed, or Close is
+// called, at which point all message streams are torn down.
+//
+// Recv runs within its own goroutine.
+func (d *Demux) Recv() {
+	defer func() {
+		for _, id := range d.Streams() {
+			d.CloseStream(id)
+		}
+		close(d.control)
+	}()
+
+	for {
+		select {
+		case c, ok := <-d.chunks.In():
+			if !ok {
+				return
+			}
+
+			if ControlGate.Open(c) {
+				d.control <- c
+				continue
+			}
+
+			id := c.Header.MessageHeader.StreamId
+			s := d.stream(id)
+
+			if MediaGate.Open(c) {
+				s.data.Chunks() <- c
+				continue
+			}
+
+			// The chunk is parsed before it is passed along, since
+			// the NetStream releases it once it has been parsed.
+			closed, release, ok := teardown(c)
+
+			s.chunks <- c
+
+			if ok {
+				if release {
+					d.release(closed)
+				}
+				d.CloseStream(closed)
+			}
+		case <-d.closer:
+			return
+		}
+	}
+}
+
+// stream returns the message stream with the given ID, creating it (and
+// starting its *data.Stream) if it does not yet exist.
+func (d *Demux) stream(id uint32) *demuxed {
+	d.smu.Lock()
+	defer d.smu.Unlock()
+
+	s, ok := d.streams[id]
+	if !ok {
+		s = &demuxed{
+			chunks: make(chan *chunk.Chunk),
+			data: data.NewStream(
+				make(chan *chunk.Chunk), d.writer,
+			),
+		}
+		go s.data.Recv()
+
+		d.streams[id] = s
+	}
+
+	return s
+}
+
+// teardown returns the ID of the message stream that is torn down by the
+// given chunk, if it carries a "closeStream" or "deleteStream" command, and
+// whether or not that ID should be released.
+func teardown(c *chunk.Chunk) (id uint32, release, ok bool) {
+	if !CommandGate.Open(c) {
+		return 0, false, false
+	}
+
+	payload := c.Data
+	if c.Header.MessageHeader.TypeId == stream.AMF3CommandTypeId {
+		if len(payload) == 0 {
+			return 0, false, false
+		}
+		payload = payload[1:]
+	}
+
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader(payload))
+	if err != nil {
+		return 0, false, false
+	}
+
+	switch t := cmd.(type) {
+	case *stream.CommandCloseStream:
+		return c.Header.MessageHeader.StreamId, false, true
+	case *stream.CommandDeleteStream:
+		return uint32(t.StreamId), true, true
+	}
+
+	return 0, false, false
+}
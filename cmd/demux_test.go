@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// CloseStream is an AMF0-encoded "closeStream" command.
+	CloseStream = []byte{
+		0x02, 0x00, 0x0b, 0x63, 0x6c, 0x6f, 0x73, 0x65, 0x53, 0x74, 0x72,
+		0x65, 0x61, 0x6d, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x05,
+	}
+
+	// DeleteStream is an AMF0-encoded "deleteStream" command, deleting
+	// message stream 1.
+	DeleteStream = []byte{
+		0x02, 0x00, 0x0c, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x53, 0x74,
+		0x72, 0x65, 0x61, 0x6d, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x05, 0x00, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00,
+	}
+)
+
+func newMessage(streamId uint32, typeId byte, payload []byte) *chunk.Chunk {
+	return &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader: chunk.BasicHeader{0, 8},
+			MessageHeader: chunk.MessageHeader{
+				Length:   uint32(len(payload)),
+				TypeId:   typeId,
+				StreamId: streamId,
+			},
+		},
+		Data: payload,
+	}
+}
+
+func TestNewDemuxMakesNewDemuxes(t *testing.T) {
+	d := NewDemux(nil, nil)
+
+	assert.IsType(t, new(Demux), d)
+	assert.Empty(t, d.Streams())
+}
+
+func TestDemuxRoutesChunksByMessageStreamId(t *testing.T) {
+	cs := &MockChunkStream{make(chan *chunk.Chunk)}
+	d := NewDemux(cs, nil)
+
+	one, two := d.Stream(1), d.Stream(2)
+
+	go d.Recv()
+	defer d.Close()
+
+	c1 := newMessage(1, 0x14, []byte{0x05})
+	c2 := newMessage(2, 0x14, []byte{0x05})
+
+	cs.C <- c2
+	assert.Equal(t, c2, <-two)
+
+	cs.C <- c1
+	assert.Equal(t, c1, <-one)
+
+	assert.Equal(t, []uint32{1, 2}, d.Streams())
+}
+
+func TestDemuxParsesMediaPerMessageStream(t *testing.T) {
+	cs := &MockChunkStream{make(chan *chunk.Chunk)}
+	d := NewDemux(cs, nil)
+
+	go d.Recv()
+	defer d.Close()
+
+	cs.C <- newMessage(3, 0x09, []byte{0x17, 0x01})
+	cs.C <- newMessage(4, 0x08, []byte{0xaf, 0x01})
+
+	assert.Equal(t, data.VideoKind, (<-d.Data(3).In()).Kind())
+	assert.Equal(t, data.AudioKind, (<-d.Data(4).In()).Kind())
+}
+
+// chunkRecorder is a chunk.Writer which records the chunks written to it.
+type chunkRecorder struct {
+	chunk.Writer
+
+	// wmu guards chunks.
+	wmu    sync.Mutex
+	chunks []*chunk.Chunk
+}
+
+func (w *chunkRecorder) Write(c *chunk.Chunk) error {
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+
+	w.chunks = append(w.chunks, c)
+	return nil
+}
+
+// streamIds returns the chunk stream IDs of the recorded chunks, in order.
+func (w *chunkRecorder) streamIds() []uint32 {
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+
+	var ids []uint32
+	for _, c := range w.chunks {
+		ids = append(ids, c.StreamId())
+	}
+
+	return ids
+}
+
+func TestDemuxWritesEachTrackOverItsOwnChunkStream(t *testing.T) {
+	cs := &MockChunkStream{make(chan *chunk.Chunk)}
+	w := &chunkRecorder{Writer: chunk.NoopWriter}
+	d := NewDemux(cs, w)
+
+	go d.Recv()
+	defer d.Close()
+
+	// Every message claims chunk stream 8 (see newMessage).
+	for _, m := range []*chunk.Chunk{
+		newMessage(1, 0x09, []byte{0x17, 0x01}),
+		newMessage(1, 0x08, []byte{0xaf, 0x01}),
+		newMessage(2, 0x09, []byte{0x17, 0x01}),
+		newMessage(1, 0x09, []byte{0x27, 0x01}),
+	} {
+		f, err := data.DefaultParser.Parse(m)
+		assert.Nil(t, err)
+		assert.Nil(t, d.Data(m.Header.MessageHeader.StreamId).Write(f))
+	}
+
+	assert.Equal(t, []uint32{4, 5, 6, 4}, w.streamIds())
+
+	d.CloseStream(1)
+	id, err := d.writer.ChunkStreams().Allocate()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 4, id)
+}
+
+func TestDemuxTearsDownClosedStreams(t *testing.T) {
+	cs := &MockChunkStream{make(chan *chunk.Chunk)}
+	d := NewDemux(cs, nil)
+
+	s := d.Stream(1)
+	ds := d.Data(1)
+
+	go d.Recv()
+	defer d.Close()
+
+	closeStream := newMessage(1, 0x14, CloseStream)
+	cs.C <- closeStream
+
+	assert.Equal(t, closeStream, <-s)
+
+	_, ok := <-s
+	assert.False(t, ok)
+	_, ok = <-ds.In()
+	assert.False(t, ok)
+
+	assert.Empty(t, d.Streams())
+}
+
+func TestDemuxTearsDownStreamsWhoseChunksAreReleased(t *testing.T) {
+	cs := &MockChunkStream{make(chan *chunk.Chunk)}
+	d := NewDemux(cs, nil)
+
+	s := d.Stream(1)
+
+	go d.Recv()
+	defer d.Close()
+
+	cs.C <- newMessage(1, 0x14, CloseStream)
+
+	// Discard the payload as soon as it is received, as Release does.
+	(<-s).Data = nil
+
+	_, ok := <-s
+	assert.False(t, ok)
+}
+
+func TestDemuxTearsDownStreamsOnClose(t *testing.T) {
+	cs := &MockChunkStream{make(chan *chunk.Chunk)}
+	d := NewDemux(cs, nil)
+
+	s := d.Stream(1)
+
+	go d.Recv()
+	d.Close()
+
+	_, ok := <-s
+	assert.False(t, ok)
+}
+
+func TestCloseStreamReturnsFalseForUnknownStreams(t *testing.T) {
+	d := NewDemux(nil, nil)
+
+	assert.False(t, d.CloseStream(1))
+}
+
+func TestDemuxTearsDownDeletedStreams(t *testing.T) {
+	cs := &MockChunkStream{make(chan *chunk.Chunk)}
+	d := NewDemux(cs, nil)
+
+	ids := stream.NewStreamIdAllocator()
+	assert.Equal(t, uint32(1), ids.Allocate())
+	d.SetStreamIds(ids)
+
+	conn, s := d.Stream(0), d.Stream(1)
+
+	go d.Recv()
+	defer d.Close()
+
+	deleteStream := newMessage(0, 0x14, DeleteStream)
+	cs.C <- deleteStream
+
+	assert.Equal(t, deleteStream, <-conn)
+
+	_, ok := <-s
+	assert.False(t, ok)
+
+	assert.Equal(t, []uint32{0}, d.Streams())
+	assert.Equal(t, uint32(1), ids.Allocate())
+}
+
+func TestTeardownDetectsAMF3Commands(t *testing.T) {
+	id, release, ok := teardown(
+		newMessage(1, 0x11, append([]byte{0x00}, CloseStream...)))
+
+	assert.True(t, ok)
+	assert.False(t, release)
+	assert.Equal(t, uint32(1), id)
+
+	_, _, ok = teardown(newMessage(1, 0x12, CloseStream))
+	assert.False(t, ok)
+}
+
+func TestDemuxRoutesControlMessagesSeparatelyFromMedia(t *testing.T) {
+	cs := &MockChunkStream{make(chan *chunk.Chunk)}
+	d := NewDemux(cs, nil)
+
+	media, ctrl := d.Data(1), d.Control()
+
+	go d.Recv()
+	defer d.Close()
+
+	setChunkSize := newMessage(0, 0x01, []byte{0x00, 0x00, 0x10, 0x00})
+	setChunkSize.Header.BasicHeader.StreamId = 2
+	// Some peers send protocol control messages over the message stream
+	// carrying media, rather than message stream 0.
+	misrouted := newMessage(1, 0x01, []byte{0x00, 0x00, 0x20, 0x00})
+
+	for _, c := range []*chunk.Chunk{
+		newMessage(1, 0x09, []byte{0x17, 0x01}),
+		setChunkSize,
+		newMessage(1, 0x09, []byte{0x27, 0x01}),
+		misrouted,
+		newMessage(1, 0x09, []byte{0x27, 0x01}),
+	} {
+		go func(c *chunk.Chunk) { cs.C <- c }(c)
+
+		if c.Header.MessageHeader.TypeId == 0x09 {
+			assert.Equal(t, data.VideoKind, (<-media.In()).Kind())
+		} else {
+			assert.Equal(t, c, <-ctrl.In())
+		}
+	}
+
+	// Control messages do not create message streams of their own.
+	assert.Equal(t, []uint32{1}, d.Streams())
+}
+
+func TestDemuxClosesTheControlStreamOnClose(t *testing.T) {
+	cs := &MockChunkStream{make(chan *chunk.Chunk)}
+	d := NewDemux(cs, nil)
+
+	go d.Recv()
+	d.Close()
+
+	_, ok := <-d.Control().In()
+	assert.False(t, ok)
+}
@@ -130,12 +130,13 @@ var (
 				&MessageStreamGate{0x1},
 			),
 		),
-		&TypeIdGate{0x14},
+		NewAnyGate(&TypeIdGate{0x14}, &TypeIdGate{0x11}),
 	)
 
 	// DataStreamGate filters chunks to only those matching the DataStream
 	// type.
 	DataStreamGate = NewUnionGate(&StreamIdGate{4}, NewAnyGate(
 		&TypeIdGate{0x08}, &TypeIdGate{0x09}, &TypeIdGate{0x12},
+		&TypeIdGate{0x16},
 	))
 )
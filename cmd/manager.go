@@ -1,12 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"time"
+
 	"github.com/WatchBeam/rtmp/chunk"
 	"github.com/WatchBeam/rtmp/cmd/conn"
 	"github.com/WatchBeam/rtmp/cmd/data"
 	"github.com/WatchBeam/rtmp/cmd/stream"
 )
 
+// closeTimeout is the longest that cleanupChildren waits for each managed
+// child to stop.
+const closeTimeout = 5 * time.Second
+
 // Manager sits in front of all sub-packages of `cmd` and cleans up incoming
 // chunks coming over streams 3, 4, and 5 into their appropriate spots, and
 // uses the Gate mechanism to dispatch them appropriately to each sub-package.
@@ -17,6 +24,9 @@ type Manager struct {
 	// closer is a channel which is written to when it is time to close the
 	// Manager.
 	closer chan struct{}
+	// ctx is the context of the connection, set by SetContext, which stops
+	// Dispatch once it is done.
+	ctx context.Context
 
 	// channels maps Gates to the channel which they are gating.
 	channels map[Gate]chan<- *chunk.Chunk
@@ -50,6 +60,7 @@ func New(chunks chunk.Stream, writer chunk.Writer) *Manager {
 	return &Manager{
 		chunks: chunks,
 		closer: make(chan struct{}),
+		ctx:    context.Background(),
 
 		channels: map[Gate]chan<- *chunk.Chunk{
 			NetConnGate:    netConnChunks,
@@ -63,6 +74,17 @@ func New(chunks chunk.Stream, writer chunk.Writer) *Manager {
 	}
 }
 
+// SetContext sets the context of the connection on the NetStream and
+// DataStream (see stream.NetStream.SetContext and data.Stream.SetContext), so
+// that values carried by it reach their handlers, and their Listen and Recv
+// routines stop once it is done. Dispatch stops once it is done, as well. It
+// must be called before Dispatch.
+func (m *Manager) SetContext(ctx context.Context) {
+	m.ctx = ctx
+	m.netStream.SetContext(ctx)
+	m.dataStream.SetContext(ctx)
+}
+
 // NetConn returns the NetConnection that is associated with this client.
 func (m *Manager) NetConn() *conn.NetConn { return m.netConn }
 
@@ -96,29 +118,43 @@ func (m *Manager) Close() { m.closer <- struct{}{} }
 //
 // Dispatch runs within its own goroutine.
 func (m *Manager) Dispatch(manageChildren bool) {
-	if manageChildren {
-		m.startChildren()
-		defer m.cleanupChildren()
-	}
-
+	// The channels of the children are closed only once they have been
+	// stopped, so that they do not receive from a closed channel.
 	defer func() {
-		close(m.closer)
-
 		for _, ch := range m.channels {
 			close(ch)
 		}
 	}()
 
+	if manageChildren {
+		m.startChildren()
+		defer m.cleanupChildren()
+	}
+
 	for {
 		select {
-		case c := <-m.chunks.In():
+		case c, ok := <-m.chunks.In():
+			if !ok {
+				return
+			}
+
 			for gate, chunks := range m.channels {
-				if gate.Open(c) {
-					chunks <- c
+				if !gate.Open(c) {
+					continue
+				}
+
+				select {
+				case chunks <- c:
+				case <-m.closer:
+					return
+				case <-m.ctx.Done():
+					return
 				}
 			}
 		case <-m.closer:
-			break
+			return
+		case <-m.ctx.Done():
+			return
 		}
 	}
 }
@@ -130,9 +166,14 @@ func (m *Manager) startChildren() {
 	go m.dataStream.Recv()
 }
 
-// cleanupChildren stops all of the `Listen` subroutines for each managed child.
+// cleanupChildren stops all of the `Listen` subroutines for each managed child,
+// waiting for each to return, even if it has only just been started, for no
+// longer than the closeTimeout.
 func (m *Manager) cleanupChildren() {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
 	m.netConn.Close()
-	m.netStream.Close()
-	m.dataStream.Close()
+	m.netStream.CloseContext(ctx)
+	m.dataStream.CloseContext(ctx)
 }
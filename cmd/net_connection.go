@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/WatchBeam/rtmp/control"
+	"github.com/WatchBeam/rtmp/handshake"
+)
+
+// NetConnection ties together everything exchanged over a single RTMP
+// connection: it owns the chunk reader and writer of the connection, performs
+// the handshake, and then demultiplexes the chunks received into three
+// substreams, each of which is started for the caller:
+//
+//   - the *control.Stream, receiving protocol control messages (see
+//     ControlGate),
+//   - the *stream.NetStream, receiving commands (see CommandGate), and
+//   - the *data.Stream, receiving audio, video, and script data (see
+//     MediaGate).
+//
+// Chunks of any other kind are discarded. Each substream writes back over the
+// same chunk writer, and is stopped once the NetConnection is closed.
+//
+// NetConnection is meant for the common case of a connection carrying a single
+// stream. Callers multiplexing many message streams over one connection should
+// wire the lower-level pieces together themselves, for instance with a Demux.
+type NetConnection struct {
+	// conn is the connection that chunks are read from and written to.
+	conn io.ReadWriter
+
+	// reader reads chunks from conn.
+	reader *chunk.DefaultReader
+	// writer writes chunks to conn.
+	writer *chunk.DefaultWriter
+	// out writes to writer over the chunk stream allocated to each track,
+	// and is shared by the substreams.
+	out *chunk.AllocatingWriter
+
+	// controlChunks is the channel of chunks given to controls.
+	controlChunks controlChunks
+	// commandChunks is the channel of chunks given to netStream.
+	commandChunks chan *chunk.Chunk
+
+	// controls is the substream of protocol control messages.
+	controls *control.Stream
+	// netStream is the substream of commands.
+	netStream *stream.NetStream
+	// dataStream is the substream of audio, video, and script data.
+	dataStream *data.Stream
+
+	// hmu guards handshaken.
+	hmu sync.Mutex
+	// handshaken is true once the handshake has completed successfully,
+	// and the substreams have been started.
+	handshaken bool
+
+	// ctx is the context of the connection, which is canceled by cancel
+	// when the NetConnection is closed.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// closeOnce ensures that the connection is closed only once, and
+	// closeErr is the error encountered while doing so.
+	closeOnce sync.Once
+	closeErr  error
+	// done is closed once the chunks of the connection are no longer
+	// being demultiplexed.
+	done chan struct{}
+}
+
+// NewNetConnection returns a new *NetConnection over the given connection,
+// whose context is derived from the given context (see Context). Neither the
+// handshake, nor any of the substreams are started until Accept or Dial is
+// called.
+func NewNetConnection(ctx context.Context, conn io.ReadWriter) *NetConnection {
+	n := &NetConnection{
+		conn: conn,
+
+		controlChunks: make(controlChunks),
+		commandChunks: make(chan *chunk.Chunk),
+
+		done: make(chan struct{}),
+	}
+	n.ctx, n.cancel = context.WithCancel(ctx)
+
+	n.writer = chunk.NewWriter(chunk.NewNotifyingWriter(conn, func(size int) {
+		n.controls.Sent(size)
+	}), chunk.DefaultReadSize).(*chunk.DefaultWriter)
+	n.out = chunk.NewAllocatingWriter(n.writer, nil)
+
+	n.reader = chunk.NewReader(
+		chunk.NewCountingReader(conn, func(size int) error {
+			return n.controls.Received(size)
+		}),
+		chunk.DefaultReadSize, chunk.NewNormalizer(),
+	).(*chunk.DefaultReader)
+
+	n.controls = control.NewStream(n.controlChunks, n.out,
+		control.NewParser(), control.NewChunker())
+	n.controls.SetContext(n.ctx)
+
+	n.netStream = stream.New(n.commandChunks, n.out)
+	n.netStream.SetContext(n.ctx)
+
+	n.dataStream = data.NewStream(make(chan *chunk.Chunk), n.out)
+	n.dataStream.SetContext(n.ctx)
+
+	return n
+}
+
+// Accept performs the server side of the handshake over the connection, and
+// then starts reading chunks, and each of the substreams. If the handshake
+// fails, its error is returned, and nothing is started. Calling Accept (or
+// Dial) again after a successful handshake is a no-op.
+func (n *NetConnection) Accept() error {
+	return n.handshake(handshake.Accept)
+}
+
+// Dial performs the client side of the handshake over the connection, and then
+// starts reading chunks, and each of the substreams, as Accept does.
+func (n *NetConnection) Dial() error {
+	return n.handshake(handshake.Dial)
+}
+
+// handshake performs the handshake with the given function, and then starts
+// the NetConnection, unless it has already been started.
+func (n *NetConnection) handshake(fn func(io.ReadWriter) error) error {
+	n.hmu.Lock()
+	defer n.hmu.Unlock()
+
+	if n.handshaken {
+		return nil
+	}
+
+	if err := fn(n.conn); err != nil {
+		return err
+	}
+	n.handshaken = true
+
+	go n.reader.Recv()
+	go n.controls.Recv()
+	go n.netStream.Listen()
+	go n.dataStream.Recv()
+	go n.route()
+
+	return nil
+}
+
+// Controls returns the substream of protocol control messages. Its In() and
+// Errs() channels must be read from, or the NetConnection stalls.
+func (n *NetConnection) Controls() *control.Stream { return n.controls }
+
+// NetStream returns the substream of commands. Its In() and Errs() channels
+// must be read from, or the NetConnection stalls.
+func (n *NetConnection) NetStream() *stream.NetStream { return n.netStream }
+
+// DataStream returns the substream of audio, video, and script data. Its In()
+// and Errs() channels must be read from, or the NetConnection stalls.
+func (n *NetConnection) DataStream() *data.Stream { return n.dataStream }
+
+// Reader returns the *chunk.DefaultReader that chunks are read from, so that
+// it may be configured (for instance, with SetMaxMessageSize).
+func (n *NetConnection) Reader() *chunk.DefaultReader { return n.reader }
+
+// Writer returns the *chunk.DefaultWriter that the substreams write to, so that
+// it may be configured (for instance, with SetChunkSize), or written to
+// directly.
+func (n *NetConnection) Writer() *chunk.DefaultWriter { return n.writer }
+
+// ChunkStreams returns the *chunk.ChunkStreamAllocator that the chunk streams
+// written over by the substreams are allocated from. Callers writing to Writer
+// directly should allocate their chunk streams from it, so that they do not
+// collide with those of the substreams.
+func (n *NetConnection) ChunkStreams() *chunk.ChunkStreamAllocator {
+	return n.out.ChunkStreams()
+}
+
+// Conn returns the connection that chunks are read from and written to.
+func (n *NetConnection) Conn() io.ReadWriter { return n.conn }
+
+// Context returns the context of the connection. It carries the values of the
+// context given to NewNetConnection, and is canceled once the NetConnection is
+// closed.
+func (n *NetConnection) Context() context.Context { return n.ctx }
+
+// Errs returns the channel of errors encountered while reading chunks from the
+// connection. Errors encountered by each substream are reported over its own
+// Errs() channel.
+func (n *NetConnection) Errs() <-chan error { return n.reader.Errs() }
+
+// Done returns a channel which is closed once the chunks of the connection are
+// no longer being demultiplexed, either because the NetConnection was closed,
+// or because the connection was.
+func (n *NetConnection) Done() <-chan struct{} { return n.done }
+
+// Close cancels the context of the connection (see Context), stopping each of
+// the substreams, and stops reading chunks. The connection is then closed if it
+// is an io.Closer, and any error encountered while doing so is returned.
+// Subsequent calls return the same error.
+func (n *NetConnection) Close() error {
+	n.closeOnce.Do(func() {
+		n.cancel()
+
+		if closer, ok := n.conn.(io.Closer); ok {
+			n.closeErr = closer.Close()
+		}
+
+		n.hmu.Lock()
+		handshaken := n.handshaken
+		n.hmu.Unlock()
+
+		if handshaken {
+			n.reader.Close()
+		}
+	})
+
+	return n.closeErr
+}
+
+// route passes each chunk read from the connection along to the substream that
+// it belongs to, until either the reader stops, or the NetConnection is
+// closed.
+//
+// route runs within its own goroutine.
+func (n *NetConnection) route() {
+	defer close(n.done)
+
+	for {
+		select {
+		case c, ok := <-n.reader.Chunks():
+			if !ok {
+				return
+			}
+
+			var dest chan<- *chunk.Chunk
+			switch {
+			case c.Header == nil:
+				continue
+			case ControlGate.Open(c):
+				dest = n.controlChunks
+			case MediaGate.Open(c):
+				dest = n.dataStream.Chunks()
+			case CommandGate.Open(c):
+				dest = n.commandChunks
+			default:
+				continue
+			}
+
+			select {
+			case dest <- c:
+			case <-n.ctx.Done():
+				return
+			}
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
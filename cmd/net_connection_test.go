@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/WatchBeam/rtmp/control"
+	"github.com/WatchBeam/rtmp/handshake"
+	"github.com/stretchr/testify/assert"
+)
+
+func newConns(t *testing.T) (client, server net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+
+	client, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	return client, <-accepted
+}
+
+// acceptNetConnection returns a *NetConnection which has accepted the
+// handshake over one end of a loopback connection, and the other end, over
+// which the handshake was dialed, and whose responses are discarded.
+func acceptNetConnection(t *testing.T) (*NetConnection, net.Conn) {
+	client, server := newConns(t)
+	n := NewNetConnection(context.Background(), server)
+
+	dialed := make(chan error, 1)
+	go func() {
+		err := handshake.Dial(client)
+		dialed <- err
+		if err == nil {
+			io.Copy(ioutil.Discard, client)
+		}
+	}()
+
+	if err := n.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-dialed; err != nil {
+		t.Fatal(err)
+	}
+
+	return n, client
+}
+
+func TestNetConnectionRoutesChunksToEachSubstream(t *testing.T) {
+	n, client := acceptNetConnection(t)
+	defer n.Close()
+
+	w := chunk.NewWriter(client, chunk.DefaultReadSize)
+	go func() {
+		w.Write(newMessage(0, 0x05, []byte{0x00, 0x10, 0x00, 0x00}))
+		w.Write(newMessage(1, 0x14, CloseStream))
+		w.Write(newMessage(1, 0x08, []byte{0xaf, 0x01}))
+	}()
+
+	select {
+	case c := <-n.Controls().In():
+		assert.Equal(t, &control.WindowAckSize{WindowAckSize: 0x100000}, c)
+	case err := <-n.Controls().Errs():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("rtmp: control message was not routed")
+	}
+
+	select {
+	case c := <-n.NetStream().In():
+		assert.IsType(t, new(stream.CommandCloseStream), c)
+	case err := <-n.NetStream().Errs():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("rtmp: command was not routed")
+	}
+
+	select {
+	case d := <-n.DataStream().In():
+		assert.IsType(t, new(data.Audio), d)
+	case err := <-n.DataStream().Errs():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("rtmp: audio was not routed")
+	}
+}
+
+func TestNetConnectionReturnsHandshakeErrors(t *testing.T) {
+	client, server := newConns(t)
+	client.Close()
+
+	n := NewNetConnection(context.Background(), server)
+
+	assert.NotNil(t, n.Accept())
+	assert.Nil(t, n.Close())
+}
+
+func TestNetConnectionCloseStopsRouting(t *testing.T) {
+	n, _ := acceptNetConnection(t)
+
+	assert.Nil(t, n.Close())
+	assert.Equal(t, context.Canceled, n.Context().Err())
+
+	select {
+	case <-n.Done():
+	case <-time.After(time.Second):
+		t.Fatal("rtmp: routing did not stop after Close")
+	}
+
+	assert.Nil(t, n.Close())
+}
+
+func TestNetConnectionClosesWhileChunksAreUnread(t *testing.T) {
+	client, server := newConns(t)
+	defer client.Close()
+	defer server.Close()
+
+	// The connection is not an io.Closer, so that the reader is stopped by
+	// Close, rather than by the connection being closed.
+	n := NewNetConnection(context.Background(), struct{ io.ReadWriter }{server})
+
+	dialed := make(chan error, 1)
+	go func() { dialed <- handshake.Dial(client) }()
+
+	if err := n.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-dialed; err != nil {
+		t.Fatal(err)
+	}
+
+	w := chunk.NewWriter(client, chunk.DefaultReadSize)
+	for i := 0; i < 4; i++ {
+		w.Write(newMessage(1, 0x08, []byte{0xaf, 0x01}))
+	}
+
+	// Give the reader time to block on passing along chunks which are
+	// never read.
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		n.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("rtmp: Close blocked on an unread chunk")
+	}
+}
+
+func TestNetConnectionWritesEachTrackOverItsOwnChunkStream(t *testing.T) {
+	buf := new(bytes.Buffer)
+	n := NewNetConnection(context.Background(), buf)
+
+	for _, m := range []*chunk.Chunk{
+		newMessage(1, 0x09, []byte{0x17, 0x01}),
+		newMessage(1, 0x08, []byte{0xaf, 0x01}),
+	} {
+		f, err := data.DefaultParser.Parse(m)
+		assert.Nil(t, err)
+		assert.Nil(t, n.DataStream().Write(f))
+	}
+	assert.Nil(t, n.NetStream().WriteStatus(
+		stream.OnStatusMessageStreamId, stream.NewPublishStartStatus("foo")))
+
+	r := chunk.NewReader(bytes.NewReader(buf.Bytes()),
+		chunk.DefaultReadSize, chunk.NewNormalizer())
+	go r.Recv()
+	defer r.Close()
+
+	var ids []uint32
+	for i := 0; i < 3; i++ {
+		ids = append(ids, (<-r.Chunks()).StreamId())
+	}
+
+	// Neither track may share the chunk stream of the other, nor that of
+	// the status.
+	assert.Equal(t, []uint32{4, 5, chunk.CommandChunkStreamId}, ids)
+}
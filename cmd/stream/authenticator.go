@@ -0,0 +1,36 @@
+package stream
+
+import "errors"
+
+var (
+	// ErrBadName may be returned by an Authenticator to reject a stream
+	// because of its name, in which case the client is sent a
+	// "NetStream.Publish.BadName" or "NetStream.Play.StreamNotFound"
+	// status, rather than a rejection.
+	ErrBadName = errors.New("cmd/stream: bad stream name")
+)
+
+// Authenticator decides whether or not a client may publish or play a stream,
+// typically based on its name (or stream key), before any media flows.
+//
+// Implementations must be safe to call from multiple goroutines.
+type Authenticator interface {
+	// Authorize returns nil if the client may publish the stream
+	// requested by the given command, or an error describing why it may
+	// not.
+	Authorize(cmd CommandPublish) error
+	// AuthorizePlay returns nil if the client may play the stream
+	// requested by the given command, or an error describing why it may
+	// not.
+	AuthorizePlay(cmd CommandPlay) error
+}
+
+// ConnectAuthenticator may be implemented by an Authenticator to also decide
+// whether or not a client may connect at all, typically based on the app it
+// connects to, or the parameters of its tcUrl, before it publishes or plays any
+// stream (see NetStream.Connect).
+type ConnectAuthenticator interface {
+	// AuthorizeConnect returns nil if the client may connect as requested
+	// by the given command, or an error describing why it may not.
+	AuthorizeConnect(cmd CommandConnect) error
+}
@@ -0,0 +1,205 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuthenticator struct {
+	mock.Mock
+}
+
+var _ Authenticator = new(MockAuthenticator)
+
+func (a *MockAuthenticator) Authorize(cmd CommandPublish) error {
+	return a.Called(cmd).Error(0)
+}
+
+func (a *MockAuthenticator) AuthorizePlay(cmd CommandPlay) error {
+	return a.Called(cmd).Error(0)
+}
+
+type MockConnectAuthenticator struct {
+	MockAuthenticator
+}
+
+var _ ConnectAuthenticator = new(MockConnectAuthenticator)
+
+func (a *MockConnectAuthenticator) AuthorizeConnect(cmd CommandConnect) error {
+	return a.Called(cmd).Error(0)
+}
+
+// written returns the data of the status chunk that "st" is written as.
+func written(st *Status) []byte {
+	c, _ := st.AsChunk()
+	return c.Data
+}
+
+func newAuthenticatedStream(a Authenticator) (*NetStream, <-chan *chunk.Chunk) {
+	w := &chanWriter{chunk.NoopWriter, make(chan *chunk.Chunk, 2)}
+
+	s := New(make(chan *chunk.Chunk), w)
+	s.SetAuthenticator(a)
+
+	return s, w.chunks
+}
+
+func TestPublishWritesStartWithoutAnAuthenticator(t *testing.T) {
+	s, out := newAuthenticatedStream(nil)
+
+	assert.Nil(t, s.Publish(&CommandPublish{Name: "foo"}))
+	assert.Equal(t, written(NewPublishStartStatus("foo")), (<-out).Data)
+}
+
+func TestPublishWritesStartWhenAuthorized(t *testing.T) {
+	a := new(MockAuthenticator)
+	a.On("Authorize", CommandPublish{Name: "foo", Type: "live"}).
+		Return(nil).Once()
+
+	s, out := newAuthenticatedStream(a)
+
+	assert.Nil(t, s.Publish(&CommandPublish{Name: "foo", Type: "live"}))
+	assert.Equal(t, written(NewPublishStartStatus("foo")), (<-out).Data)
+	a.AssertExpectations(t)
+}
+
+func TestPublishWritesBadNameWhenTheNameIsRejected(t *testing.T) {
+	a := new(MockAuthenticator)
+	a.On("Authorize", CommandPublish{Name: "foo"}).Return(ErrBadName)
+
+	s, out := newAuthenticatedStream(a)
+
+	assert.Equal(t, ErrBadName, s.Publish(&CommandPublish{Name: "foo"}))
+	assert.Equal(t, written(NewPublishBadNameStatus("foo")), (<-out).Data)
+}
+
+func TestPublishWritesRejectedWhenUnauthorized(t *testing.T) {
+	err := errors.New("invalid token")
+
+	a := new(MockAuthenticator)
+	a.On("Authorize", CommandPublish{Name: "foo"}).Return(err)
+
+	s, out := newAuthenticatedStream(a)
+
+	assert.Equal(t, err, s.Publish(&CommandPublish{Name: "foo"}))
+	assert.Equal(t, written(NewPublishRejectedStatus(err)), (<-out).Data)
+}
+
+func TestPlayWritesStartWhenAuthorized(t *testing.T) {
+	a := new(MockAuthenticator)
+	a.On("AuthorizePlay", CommandPlay{PlayPath: "foo"}).Return(nil)
+
+	s, out := newAuthenticatedStream(a)
+
+	assert.Nil(t, s.Play(&CommandPlay{PlayPath: "foo"}))
+	assert.Equal(t, byte(0x04), (<-out).Header.MessageHeader.TypeId)
+	assert.Equal(t, written(NewPlayStartStatus("foo")), (<-out).Data)
+}
+
+func TestPlayWritesStatusesOverTheStreamOfTheCommand(t *testing.T) {
+	s, out := newAuthenticatedStream(nil)
+
+	assert.Nil(t, s.Play(&CommandPlay{PlayPath: "foo", StreamId: 3}))
+
+	begin := <-out
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x03}, begin.Data)
+
+	start := <-out
+	assert.Equal(t, uint32(3), start.Header.MessageHeader.StreamId)
+	assert.Equal(t, written(NewPlayStartStatus("foo")), start.Data)
+}
+
+func TestPublishWritesRejectionsOverTheStreamOfTheCommand(t *testing.T) {
+	a := new(MockAuthenticator)
+	a.On("Authorize", CommandPublish{Name: "foo", StreamId: 3}).
+		Return(ErrBadName)
+
+	s, out := newAuthenticatedStream(a)
+
+	assert.Equal(t, ErrBadName,
+		s.Publish(&CommandPublish{Name: "foo", StreamId: 3}))
+	assert.Equal(t, uint32(3), (<-out).Header.MessageHeader.StreamId)
+}
+
+func TestPlayWritesStreamNotFoundWhenTheNameIsRejected(t *testing.T) {
+	a := new(MockAuthenticator)
+	a.On("AuthorizePlay", CommandPlay{PlayPath: "foo"}).Return(ErrBadName)
+
+	s, out := newAuthenticatedStream(a)
+
+	assert.Equal(t, ErrBadName, s.Play(&CommandPlay{PlayPath: "foo"}))
+	assert.Equal(t, written(NewPlayStreamNotFoundStatus("foo")),
+		(<-out).Data)
+}
+
+func TestPlayWritesFailedWhenUnauthorized(t *testing.T) {
+	err := errors.New("invalid token")
+
+	a := new(MockAuthenticator)
+	a.On("AuthorizePlay", CommandPlay{PlayPath: "foo"}).Return(err)
+
+	s, out := newAuthenticatedStream(a)
+
+	assert.Equal(t, err, s.Play(&CommandPlay{PlayPath: "foo"}))
+	assert.Equal(t, written(NewPlayFailedStatus(err)), (<-out).Data)
+}
+
+// accepted returns the data of the _result accepting a connection with the
+// given transaction ID, and the AMF0 object encoding.
+func accepted(txnID float64) []byte {
+	c, _ := (&Invoke{
+		Name:          ResultName,
+		TransactionId: txnID,
+		Arguments: []interface{}{
+			&connectProperties{DefaultFMSVersion, DefaultCapabilities},
+			&connectInfo{"status", ConnectSuccessCode,
+				"Connection succeeded.", new(float64)},
+		},
+	}).AsChunk()
+
+	return c.Data
+}
+
+func TestConnectAcceptsWithoutAConnectAuthenticator(t *testing.T) {
+	s, out := newAuthenticatedStream(new(MockAuthenticator))
+
+	assert.Nil(t, s.Connect(&CommandConnect{TransactionId: 1}))
+	assert.Equal(t, accepted(1), (<-out).Data)
+}
+
+func TestConnectAcceptsWhenAuthorized(t *testing.T) {
+	c := CommandConnect{TransactionId: 1}
+
+	a := new(MockConnectAuthenticator)
+	a.On("AuthorizeConnect", c).Return(nil).Once()
+
+	s, out := newAuthenticatedStream(a)
+
+	assert.Nil(t, s.Connect(&c))
+	assert.Equal(t, accepted(1), (<-out).Data)
+	a.AssertExpectations(t)
+}
+
+func TestConnectWritesAnErrorWhenUnauthorized(t *testing.T) {
+	err := errors.New("invalid token")
+	c := CommandConnect{TransactionId: 1}
+
+	a := new(MockConnectAuthenticator)
+	a.On("AuthorizeConnect", c).Return(err)
+
+	s, out := newAuthenticatedStream(a)
+
+	assert.Equal(t, err, s.Connect(&c))
+
+	expected, _ := (&Invoke{
+		Name:          ErrorName,
+		TransactionId: 1,
+		Arguments: []interface{}{nil, connectStatus("error",
+			ConnectRejectedCode, "invalid token")},
+	}).AsChunk()
+	assert.Equal(t, expected.Data, (<-out).Data)
+}
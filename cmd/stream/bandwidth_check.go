@@ -0,0 +1,220 @@
+package stream
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// OnBWCheckName is the name of the command called on the client in
+	// order to measure the bandwidth available to it.
+	OnBWCheckName string = "onBWCheck"
+	// OnBWDoneName is the name of the command called on the client to
+	// report the results of a bandwidth check.
+	OnBWDoneName string = "onBWDone"
+
+	// DefaultBandwidthCheckPayloadSize is the default size, in bytes, of
+	// the payload sent to the client in order to measure its bandwidth.
+	DefaultBandwidthCheckPayloadSize = 32 * 1024
+)
+
+var (
+	// ErrBandwidthCheckStarted is returned by BandwidthCheck.Run when the
+	// check has already been started.
+	ErrBandwidthCheckStarted = errors.New(
+		"cmd/stream: bandwidth check already started")
+	// ErrBandwidthCheckAborted is returned by BandwidthCheck.Run when the
+	// NetStream stops listening before the client responds.
+	ErrBandwidthCheckAborted = errors.New(
+		"cmd/stream: bandwidth check aborted")
+	// ErrBandwidthCheckRejected is returned by BandwidthCheck.Run when the
+	// client responds to onBWCheck with an _error.
+	ErrBandwidthCheckRejected = errors.New(
+		"cmd/stream: bandwidth check rejected by client")
+)
+
+// BandwidthCheckState is the state of a BandwidthCheck.
+type BandwidthCheckState int
+
+const (
+	// BandwidthCheckPending is the state of a BandwidthCheck that has not
+	// yet been run.
+	BandwidthCheckPending BandwidthCheckState = iota
+	// BandwidthCheckLatency is the state of a BandwidthCheck that is
+	// awaiting the response to its first, empty, onBWCheck call.
+	BandwidthCheckLatency
+	// BandwidthCheckThroughput is the state of a BandwidthCheck that is
+	// awaiting the response to its second onBWCheck call, carrying the
+	// payload.
+	BandwidthCheckThroughput
+	// BandwidthCheckDone is the state of a BandwidthCheck that has sent
+	// its results to the client with onBWDone.
+	BandwidthCheckDone
+	// BandwidthCheckFailed is the state of a BandwidthCheck that was
+	// unable to complete.
+	BandwidthCheckFailed
+)
+
+// BandwidthResult holds the results of a BandwidthCheck, as sent to the client
+// in the onBWDone command.
+type BandwidthResult struct {
+	// KbitDown is the measured bandwidth to the client, in kilobits per
+	// second.
+	KbitDown float64
+	// DeltaDown is the amount of data sent to measure KbitDown, in
+	// kilobytes.
+	DeltaDown float64
+	// DeltaTime is the time taken to send DeltaDown, in milliseconds.
+	DeltaTime float64
+	// Latency is the round-trip time to the client, in milliseconds.
+	Latency float64
+}
+
+// Invoke returns the onBWDone command carrying the BandwidthResult.
+func (r *BandwidthResult) Invoke() *Invoke {
+	return &Invoke{
+		Name: OnBWDoneName,
+		Arguments: []interface{}{
+			nil, r.KbitDown, r.DeltaDown, r.DeltaTime, r.Latency,
+		},
+	}
+}
+
+// BandwidthCheck runs the legacy bandwidth check exchange expected by some
+// Flash-based clients after connecting, which will not begin publishing until
+// they have received onBWDone. Modern clients do not require it, so the check
+// is only run when requested.
+//
+// The exchange proceeds as follows:
+//  1. onBWCheck is called with no payload, to measure the latency.
+//  2. onBWCheck is called again, with a payload of PayloadSize bytes, to
+//     measure the throughput.
+//  3. onBWDone is called with the results (see BandwidthResult).
+type BandwidthCheck struct {
+	// PayloadSize is the size, in bytes, of the payload sent to measure
+	// the throughput. It is initialized to
+	// DefaultBandwidthCheckPayloadSize.
+	PayloadSize int
+
+	// n is the NetStream that the check is run over.
+	n *NetStream
+
+	// smu guards state.
+	smu sync.Mutex
+	// state is the current state of the check.
+	state BandwidthCheckState
+}
+
+// NewBandwidthCheck returns a new *BandwidthCheck, to be run over the given
+// NetStream. The NetStream must be listening in order for responses to be
+// received (see NetStream.Listen).
+func NewBandwidthCheck(n *NetStream) *BandwidthCheck {
+	return &BandwidthCheck{
+		PayloadSize: DefaultBandwidthCheckPayloadSize,
+		n:           n,
+	}
+}
+
+// State returns the current state of the check.
+func (b *BandwidthCheck) State() BandwidthCheckState {
+	b.smu.Lock()
+	defer b.smu.Unlock()
+
+	return b.state
+}
+
+// Respond acknowledges a checkBW (or _checkbw) command sent by the client with
+// an empty _result.
+func (b *BandwidthCheck) Respond(c *CommandCheckBandwidth) error {
+	return b.n.Invoke(ResultName, c.TransactionId, nil)
+}
+
+// Run runs the exchange, blocking until it is complete, and returns the results
+// sent to the client. If the check has already been run, or any step of the
+// exchange fails, an error is returned instead, and the check is marked as
+// failed.
+func (b *BandwidthCheck) Run() (*BandwidthResult, error) {
+	b.smu.Lock()
+	if b.state != BandwidthCheckPending {
+		b.smu.Unlock()
+		return nil, ErrBandwidthCheckStarted
+	}
+	b.state = BandwidthCheckLatency
+	b.smu.Unlock()
+
+	res, err := b.run()
+	if err != nil {
+		b.setState(BandwidthCheckFailed)
+		return nil, err
+	}
+
+	b.setState(BandwidthCheckDone)
+	return res, nil
+}
+
+func (b *BandwidthCheck) run() (*BandwidthResult, error) {
+	latency, err := b.call(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b.setState(BandwidthCheckThroughput)
+	elapsed, err := b.call(nil, strings.Repeat("\x00", b.PayloadSize))
+	if err != nil {
+		return nil, err
+	}
+
+	delta := elapsed - latency
+	if delta < time.Millisecond {
+		delta = time.Millisecond
+	}
+
+	res := &BandwidthResult{
+		KbitDown:  float64(b.PayloadSize*8) / 1000 / delta.Seconds(),
+		DeltaDown: float64(b.PayloadSize) / 1024,
+		DeltaTime: float64(delta / time.Millisecond),
+		Latency:   float64(latency / time.Millisecond),
+	}
+
+	c, err := res.Invoke().AsChunk()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.n.write(c); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// call calls onBWCheck with the given arguments, and returns the time taken for
+// the client to respond.
+func (b *BandwidthCheck) call(args ...interface{}) (time.Duration, error) {
+	start := time.Now()
+
+	res, err := b.n.Call(OnBWCheckName, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd, ok := <-res
+	if !ok {
+		return 0, ErrBandwidthCheckAborted
+	}
+
+	if _, ok := cmd.(*CommandError); ok {
+		return 0, ErrBandwidthCheckRejected
+	}
+
+	return time.Since(start), nil
+}
+
+func (b *BandwidthCheck) setState(state BandwidthCheckState) {
+	b.smu.Lock()
+	defer b.smu.Unlock()
+
+	b.state = state
+}
@@ -0,0 +1,137 @@
+package stream
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+)
+
+// chanWriter is a chunk.Writer which sends each written chunk over a channel.
+type chanWriter struct {
+	chunk.Writer
+
+	chunks chan *chunk.Chunk
+}
+
+func (w *chanWriter) Write(c *chunk.Chunk) error {
+	w.chunks <- c
+	return nil
+}
+
+func newBandwidthCheck(size int) (*BandwidthCheck, chan<- *chunk.Chunk, <-chan *chunk.Chunk) {
+	in := make(chan *chunk.Chunk)
+	out := &chanWriter{chunk.NoopWriter, make(chan *chunk.Chunk, 1)}
+
+	s := New(in, out)
+	go s.Listen()
+
+	b := NewBandwidthCheck(s)
+	b.PayloadSize = size
+
+	return b, in, out.chunks
+}
+
+func invokeData(name string, id float64, args ...interface{}) []byte {
+	c, _ := (&Invoke{
+		Name:          name,
+		TransactionId: id,
+		Arguments:     args,
+	}).AsChunk()
+
+	return c.Data
+}
+
+func invokeChunk(name string, id float64) *chunk.Chunk {
+	c, _ := (&Invoke{
+		Name:          name,
+		TransactionId: id,
+		Arguments:     []interface{}{nil},
+	}).AsChunk()
+
+	return c
+}
+
+func TestBandwidthChecksRunTheExchange(t *testing.T) {
+	b, in, out := newBandwidthCheck(16)
+	defer b.n.Close()
+
+	done := make(chan *BandwidthResult)
+	go func() {
+		res, err := b.Run()
+		assert.Nil(t, err)
+		done <- res
+	}()
+
+	assert.Equal(t, invokeData(OnBWCheckName, 1, nil), (<-out).Data)
+	assert.Equal(t, BandwidthCheckLatency, b.State())
+	in <- invokeChunk(ResultName, 1)
+
+	assert.Equal(t, invokeData(OnBWCheckName, 2, nil, strings.Repeat("\x00", 16)),
+		(<-out).Data)
+	assert.Equal(t, BandwidthCheckThroughput, b.State())
+	in <- invokeChunk(ResultName, 2)
+
+	bwDone := <-out
+	res := <-done
+
+	assert.Equal(t, BandwidthCheckDone, b.State())
+	assert.Equal(t, 16.0/1024, res.DeltaDown)
+	assert.True(t, res.KbitDown > 0)
+	assert.Equal(t, invokeData(OnBWDoneName, 0, res.Invoke().Arguments...),
+		bwDone.Data)
+}
+
+func TestBandwidthChecksFailWhenRejected(t *testing.T) {
+	b, in, out := newBandwidthCheck(16)
+	defer b.n.Close()
+
+	go func() {
+		<-out
+		in <- invokeChunk(ErrorName, 1)
+	}()
+
+	res, err := b.Run()
+
+	assert.Nil(t, res)
+	assert.Equal(t, ErrBandwidthCheckRejected, err)
+	assert.Equal(t, BandwidthCheckFailed, b.State())
+}
+
+func TestBandwidthChecksAreAbortedWhenTheNetStreamCloses(t *testing.T) {
+	b, _, out := newBandwidthCheck(16)
+
+	go func() {
+		<-out
+		b.n.Close()
+	}()
+
+	_, err := b.Run()
+
+	assert.Equal(t, ErrBandwidthCheckAborted, err)
+}
+
+func TestBandwidthChecksOnlyRunOnce(t *testing.T) {
+	b, _, _ := newBandwidthCheck(16)
+	defer b.n.Close()
+
+	b.setState(BandwidthCheckDone)
+
+	_, err := b.Run()
+
+	assert.Equal(t, ErrBandwidthCheckStarted, err)
+}
+
+func TestBandwidthChecksRespondToCheckBW(t *testing.T) {
+	b, _, out := newBandwidthCheck(16)
+	defer b.n.Close()
+
+	cmd, err := DefaultParser.Parse(bytes.NewReader(
+		invokeData("_checkbw", 3, nil)))
+	assert.Nil(t, err)
+
+	assert.Nil(t, b.Respond(cmd.(*CommandCheckBandwidth)))
+	assert.Equal(t, invokeData(ResultName, 3, nil), (<-out).Data)
+}
@@ -1,6 +1,9 @@
 package stream
 
-import "github.com/WatchBeam/amf0"
+import (
+	"io"
+	"io/ioutil"
+)
 
 // Type CommandHeader represents the command header belonging to commands shared
 // by the NetStream and NetConnection.
@@ -10,16 +13,40 @@ import "github.com/WatchBeam/amf0"
 // the NetStream, the values for TransactionId and Arguments are 0 and nil,
 // respectively.
 //
+// Arguments holds the properties of the command object, decoded as in
+// CommandConnect.Parameters, or nil if the command object is null.
+//
 // TODO(taylor): this sort of logic is shared between cmd/stream and cmd/conn,
 // and should probably live in cmd, but that abstraction is tricky between
 // reading and writing. Visit this later.
 type CommandHeader struct {
 	Name          string
 	TransactionId float64
-	Arguments     *amf0.Object
+	Arguments     map[string]interface{}
 }
 
 // Command is a tag-type for commands that may be received over the net stream.
 type Command interface {
 	IsCommand() bool
 }
+
+// Unmarshaler is implemented by Commands that decode themselves, rather than
+// being decoded field-by-field from the payload following the CommandHeader.
+//
+// UnmarshalCommand is called with the CommandHeader that has already been read,
+// and the io.Reader containing the remainder of the command. Its arguments are
+// AMF0-encoded, and are best decoded with the *amf0.Decoder (from the
+// github.com/WatchBeam/rtmp/amf0 package) which read the CommandHeader, as the
+// commands of this package do, so that references to the command object are
+// resolved.
+type Unmarshaler interface {
+	UnmarshalCommand(header *CommandHeader, r io.Reader) error
+}
+
+// skipOptionalArguments reads and discards the remainder of a command, holding
+// optional arguments which it ignores, so that strict parsers do not mistake
+// them for trailing data (see SimpleParser.SetStrict).
+func skipOptionalArguments(r io.Reader) error {
+	_, err := io.Copy(ioutil.Discard, r)
+	return err
+}
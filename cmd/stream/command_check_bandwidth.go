@@ -0,0 +1,25 @@
+package stream
+
+import "io"
+
+// CommandCheckBandwidth is sent by some clients (as either "checkBW" or
+// "_checkbw") to ask the server to perform the legacy bandwidth check (see
+// BandwidthCheck).
+type CommandCheckBandwidth struct {
+	// TransactionId is the transaction ID of the command, which must be
+	// echoed back in the response.
+	TransactionId float64
+}
+
+var _ Command = new(CommandCheckBandwidth)
+var _ Unmarshaler = new(CommandCheckBandwidth)
+
+// IsCommand implements Command.IsCommand.
+func (_ *CommandCheckBandwidth) IsCommand() bool { return true }
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand. The checkBW command
+// carries nothing beyond its CommandHeader.
+func (c *CommandCheckBandwidth) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	c.TransactionId = header.TransactionId
+	return nil
+}
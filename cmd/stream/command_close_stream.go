@@ -0,0 +1,21 @@
+package stream
+
+import "io"
+
+// CommandCloseStream is sent by the client over a message stream to stop
+// playing or publishing on it. Unlike deleteStream, the message stream itself
+// remains allocated, and may be used again.
+type CommandCloseStream struct{}
+
+var _ Command = new(CommandCloseStream)
+var _ Unmarshaler = new(CommandCloseStream)
+
+// IsCommand implements Command.IsCommand.
+func (_ *CommandCloseStream) IsCommand() bool { return true }
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand. The closeStream
+// command carries nothing beyond its CommandHeader, since the stream that it
+// closes is the message stream that it was sent over.
+func (c *CommandCloseStream) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	return nil
+}
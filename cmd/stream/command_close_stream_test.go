@@ -0,0 +1,34 @@
+package stream_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseStreamCommandsAreParsed(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x0b, 0x63, 0x6c, 0x6f, 0x73, 0x65, 0x53, 0x74,
+		0x72, 0x65, 0x61, 0x6d,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, new(stream.CommandCloseStream), cmd)
+}
+
+func TestDeleteStreamCommandsAreParsed(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x0c, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x53,
+		0x74, 0x72, 0x65, 0x61, 0x6d,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+		0x00, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandDeleteStream{StreamId: 1}, cmd)
+}
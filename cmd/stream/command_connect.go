@@ -0,0 +1,149 @@
+package stream
+
+import "io"
+
+// CommandConnect is sent by the client to connect to an application instance on
+// the server. The command object sent along with it describes the client, and
+// the application that it is connecting to.
+type CommandConnect struct {
+	// TransactionId is the transaction ID of the connect command, which
+	// must be echoed back in the response.
+	TransactionId float64
+	// Parameters holds the decoded properties of the command object, keyed
+	// by their name. Strings, numbers, and booleans are decoded into
+	// their Go counterparts, strict arrays into []interface{}, nested
+	// objects, typed objects, and ECMA arrays into
+	// map[string]interface{}, dates into numbers of milliseconds since
+	// the epoch, and null and undefined values into nil.
+	Parameters map[string]interface{}
+}
+
+var _ Command = new(CommandConnect)
+var _ Unmarshaler = new(CommandConnect)
+
+// IsCommand implements Command.IsCommand.
+func (_ *CommandConnect) IsCommand() bool { return true }
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand. The parameters of
+// the connect command are read from the command object in the CommandHeader.
+// Any optional user arguments following it are ignored.
+func (c *CommandConnect) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	c.TransactionId = header.TransactionId
+	c.Parameters = header.Arguments
+
+	if c.Parameters == nil {
+		c.Parameters = make(map[string]interface{})
+	}
+
+	return skipOptionalArguments(r)
+}
+
+// App returns the name of the server application that the client is
+// connecting to.
+func (c *CommandConnect) App() string { return c.string("app") }
+
+// FlashVer returns the Flash Player version of the client.
+func (c *CommandConnect) FlashVer() string { return c.string("flashVer") }
+
+// SwfUrl returns the URL of the SWF file that is making the connection.
+func (c *CommandConnect) SwfUrl() string { return c.string("swfUrl") }
+
+// TcUrl returns the URL of the server, in the form
+// "protocol://servername:port/appName/appInstance".
+func (c *CommandConnect) TcUrl() string { return c.string("tcUrl") }
+
+// PageUrl returns the URL of the web page from where the SWF file was loaded.
+func (c *CommandConnect) PageUrl() string { return c.string("pageUrl") }
+
+// Fpad returns whether or not a proxy is being used.
+func (c *CommandConnect) Fpad() bool {
+	b, _ := c.Parameters["fpad"].(bool)
+	return b
+}
+
+// Capabilities returns the capabilities of the client.
+func (c *CommandConnect) Capabilities() float64 { return c.number("capabilities") }
+
+// AudioCodecs returns a bitmask of the audio codecs supported by the client.
+func (c *CommandConnect) AudioCodecs() float64 { return c.number("audioCodecs") }
+
+// VideoCodecs returns a bitmask of the video codecs supported by the client.
+func (c *CommandConnect) VideoCodecs() float64 { return c.number("videoCodecs") }
+
+// VideoFunction returns a bitmask of the special video functions supported by
+// the client.
+func (c *CommandConnect) VideoFunction() float64 { return c.number("videoFunction") }
+
+// ObjectEncoding returns the AMF encoding method requested by the client,
+// either 0 (AMF0) or 3 (AMF3).
+func (c *CommandConnect) ObjectEncoding() float64 { return c.number("objectEncoding") }
+
+// Encoding returns the object encoding negotiated by the client, which is
+// ObjectEncodingAMF0 unless AMF3 was requested.
+func (c *CommandConnect) Encoding() ObjectEncoding {
+	if c.ObjectEncoding() == float64(ObjectEncodingAMF3) {
+		return ObjectEncodingAMF3
+	}
+
+	return ObjectEncodingAMF0
+}
+
+// string returns the parameter with the given key, or an empty string if it is
+// missing or not a string.
+func (c *CommandConnect) string(key string) string {
+	s, _ := c.Parameters[key].(string)
+	return s
+}
+
+// number returns the parameter with the given key, or zero if it is missing or
+// not a number.
+func (c *CommandConnect) number(key string) float64 {
+	n, _ := c.Parameters[key].(float64)
+	return n
+}
+
+const (
+	// DefaultFMSVersion is the server version sent in the properties of
+	// the _result response to a connect command (see
+	// NetStream.AcceptConnect). Some clients check that it names a
+	// version of Flash Media Server, so the version conventionally sent by
+	// RTMP servers is used.
+	DefaultFMSVersion string = "FMS/3,0,1,123"
+	// DefaultCapabilities is the capabilities bitmask sent alongside
+	// DefaultFMSVersion.
+	DefaultCapabilities float64 = 31
+)
+
+// connectProperties is the properties object sent in the _result response to
+// a connect command, describing the server.
+type connectProperties struct {
+	// FMSVer is the version of the server.
+	FMSVer string `amf0:"fmsVer"`
+	// Capabilities is the capabilities bitmask of the server.
+	Capabilities float64 `amf0:"capabilities"`
+}
+
+// connectInfo is the information object sent in the _result or _error
+// response to a connect command. Its fields are encoded in the order that
+// clients expect them in.
+type connectInfo struct {
+	// Level is either "status", or "error".
+	Level string `amf0:"level"`
+	// Code is the code of the response, such as ConnectSuccessCode.
+	Code string `amf0:"code"`
+	// Description is a human-readable description of the response.
+	Description string `amf0:"description"`
+	// ObjectEncoding is the negotiated object encoding, which is only sent
+	// with a successful response.
+	ObjectEncoding *float64 `amf0:"objectEncoding,omitempty"`
+}
+
+// connectStatus returns the information object sent in response to a connect
+// command, with the given level, code, and description.
+func connectStatus(level, code, description string) *connectInfo {
+	return &connectInfo{
+		Level:       level,
+		Code:        code,
+		Description: description,
+	}
+}
@@ -0,0 +1,81 @@
+package stream_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	ConnectCommand = []byte{
+		// "connect", 1
+		0x02, 0x00, 0x07, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+		0x00, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		// <CommandObject>
+		0x03,
+		// app: "live"
+		0x00, 0x03, 0x61, 0x70, 0x70,
+		0x02, 0x00, 0x04, 0x6c, 0x69, 0x76, 0x65,
+		// tcUrl: "rtmp://a/live"
+		0x00, 0x05, 0x74, 0x63, 0x55, 0x72, 0x6c,
+		0x02, 0x00, 0x0d, 0x72, 0x74, 0x6d, 0x70, 0x3a, 0x2f, 0x2f,
+		0x61, 0x2f, 0x6c, 0x69, 0x76, 0x65,
+		// fpad: false
+		0x00, 0x04, 0x66, 0x70, 0x61, 0x64, 0x01, 0x00,
+		// objectEncoding: 3
+		0x00, 0x0e, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x45, 0x6e,
+		0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67,
+		0x00, 0x40, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x09,
+		// </CommandObject>
+	}
+)
+
+func TestConnectCommandsAreParsed(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader(ConnectCommand))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandConnect{
+		TransactionId: 1,
+		Parameters: map[string]interface{}{
+			"app":            "live",
+			"tcUrl":          "rtmp://a/live",
+			"fpad":           false,
+			"objectEncoding": float64(3),
+		},
+	}, cmd)
+}
+
+func TestConnectCommandAccessors(t *testing.T) {
+	cmd, _ := stream.DefaultParser.Parse(bytes.NewReader(ConnectCommand))
+	connect := cmd.(*stream.CommandConnect)
+
+	assert.Equal(t, "live", connect.App())
+	assert.Equal(t, "rtmp://a/live", connect.TcUrl())
+	assert.Equal(t, "", connect.FlashVer())
+	assert.False(t, connect.Fpad())
+	assert.Equal(t, float64(3), connect.ObjectEncoding())
+	assert.Equal(t, stream.ObjectEncodingAMF3, connect.Encoding())
+	assert.Equal(t, float64(0), connect.AudioCodecs())
+}
+
+func TestConnectCommandsDefaultToAMF0(t *testing.T) {
+	connect := new(stream.CommandConnect)
+
+	assert.Equal(t, stream.ObjectEncodingAMF0, connect.Encoding())
+}
+
+func TestConnectCommandsWithoutACommandObjectHaveNoParameters(t *testing.T) {
+	c := new(stream.CommandConnect)
+
+	err := c.UnmarshalCommand(&stream.CommandHeader{
+		Name:          "connect",
+		TransactionId: 1,
+	}, new(bytes.Buffer))
+
+	assert.Nil(t, err)
+	assert.Empty(t, c.Parameters)
+	assert.Equal(t, "", c.App())
+}
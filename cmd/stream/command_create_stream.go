@@ -0,0 +1,86 @@
+package stream
+
+import (
+	"io"
+
+	"github.com/WatchBeam/rtmp/chunk"
+)
+
+const (
+	// ResultName is the name of the command sent in response to a
+	// successful command, as used in the CommandHeader type.
+	ResultName string = "_result"
+
+	// ResultChunkStreamId is the chunk stream ID that responses to
+	// NetConnection commands (such as createStream) are sent over.
+	ResultChunkStreamId uint32 = 3
+	// ResultMessageStreamId is the message stream ID that responses to
+	// NetConnection commands are sent over.
+	ResultMessageStreamId uint32 = 0
+)
+
+// CommandCreateStream is sent by the client to create a logical channel for
+// message communication. The server responds with a _result carrying the ID of
+// the newly created stream, correlated by TransactionId.
+type CommandCreateStream struct {
+	// TransactionId is the transaction ID of the createStream command,
+	// which must be echoed back in the response.
+	TransactionId float64
+}
+
+var _ Command = new(CommandCreateStream)
+var _ Unmarshaler = new(CommandCreateStream)
+
+// IsCommand implements Command.IsCommand.
+func (_ *CommandCreateStream) IsCommand() bool { return true }
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand. The createStream
+// command carries nothing beyond its CommandHeader.
+func (c *CommandCreateStream) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	c.TransactionId = header.TransactionId
+	return nil
+}
+
+// Result allocates a new message stream ID from the given *StreamIdAllocator,
+// and returns the _result response carrying it.
+func (c *CommandCreateStream) Result(ids *StreamIdAllocator) *CreateStreamResult {
+	return &CreateStreamResult{
+		TransactionId: c.TransactionId,
+		StreamId:      float64(ids.Allocate()),
+	}
+}
+
+// CreateStreamResult is the _result sent in response to a successful
+// createStream command.
+type CreateStreamResult struct {
+	// TransactionId is the transaction ID of the createStream command
+	// that this is in response to.
+	TransactionId float64
+	// StreamId is the ID of the newly created message stream.
+	StreamId float64
+}
+
+// AsChunk marshals the _result into a chunk, including its CommandHeader. If
+// the data was unable to be marshalled, then an error will be returned
+// instead.
+func (r *CreateStreamResult) AsChunk() (*chunk.Chunk, error) {
+	payload, err := marshalArguments(
+		ResultName, r.TransactionId, nil, r.StreamId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader: chunk.BasicHeader{
+				StreamId: ResultChunkStreamId,
+			},
+			MessageHeader: chunk.MessageHeader{
+				Length:   uint32(len(payload)),
+				TypeId:   Amf0CmdTypeId,
+				StreamId: ResultMessageStreamId,
+			},
+		},
+		Data: payload,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package stream_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateStreamCommandsAreParsed(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x0c, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53,
+		0x74, 0x72, 0x65, 0x61, 0x6d,
+		0x00, 0x40, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandCreateStream{TransactionId: 4}, cmd)
+}
+
+func TestCreateStreamResultsAllocateStreamIds(t *testing.T) {
+	ids := stream.NewStreamIdAllocator()
+	c := &stream.CommandCreateStream{TransactionId: 4}
+
+	first := c.Result(ids)
+	second := c.Result(ids)
+
+	assert.Equal(t, &stream.CreateStreamResult{
+		TransactionId: 4,
+		StreamId:      1,
+	}, first)
+	assert.Equal(t, float64(2), second.StreamId)
+}
+
+func TestCreateStreamResultsAreChunked(t *testing.T) {
+	r := &stream.CreateStreamResult{TransactionId: 4, StreamId: 1}
+
+	c, err := r.AsChunk()
+
+	assert.Nil(t, err)
+	assert.Equal(t, stream.ResultChunkStreamId, c.Header.BasicHeader.StreamId)
+	assert.Equal(t, stream.ResultMessageStreamId, c.Header.MessageHeader.StreamId)
+	assert.Equal(t, stream.Amf0CmdTypeId, c.Header.MessageHeader.TypeId)
+	assert.Equal(t, uint32(len(c.Data)), c.Header.MessageHeader.Length)
+	assert.Equal(t, []byte{
+		0x02, 0x00, 0x07, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74,
+		0x00, 0x40, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+		0x00, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}, c.Data)
+}
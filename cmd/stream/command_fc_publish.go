@@ -0,0 +1,83 @@
+package stream
+
+import "io"
+
+const (
+	// OnFCPublishName is the name of the command called on the client in
+	// response to an FCPublish command.
+	OnFCPublishName string = "onFCPublish"
+	// OnFCUnpublishName is the name of the command called on the client
+	// in response to an FCUnpublish command.
+	OnFCUnpublishName string = "onFCUnpublish"
+)
+
+type (
+	// CommandFCPublish is sent by some encoders (notably those derived
+	// from Flash Media Live Encoder) over the NetConnection before
+	// publishing a stream, announcing the name of the stream to be
+	// published. Such encoders may wait for an onFCPublish response
+	// before sending the publish command (see NetStream.FCPublish).
+	CommandFCPublish struct {
+		// TransactionId is the transaction ID of the command.
+		TransactionId float64
+		// Name is the name (or stream key) of the stream to be
+		// published.
+		Name string
+	}
+
+	// CommandFCUnpublish is sent by the same encoders as
+	// CommandFCPublish once they stop publishing a stream.
+	CommandFCUnpublish struct {
+		// TransactionId is the transaction ID of the command.
+		TransactionId float64
+		// Name is the name (or stream key) of the stream that is no
+		// longer published.
+		Name string
+	}
+)
+
+var _ Command = new(CommandFCPublish)
+var _ Unmarshaler = new(CommandFCPublish)
+var _ Command = new(CommandFCUnpublish)
+var _ Unmarshaler = new(CommandFCUnpublish)
+
+// IsCommand implements Command.IsCommand.
+func (_ *CommandFCPublish) IsCommand() bool { return true }
+
+// IsCommand implements Command.IsCommand.
+func (_ *CommandFCUnpublish) IsCommand() bool { return true }
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand. The name of the
+// stream follows the null command object consumed by the CommandHeader.
+func (c *CommandFCPublish) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	c.TransactionId = header.TransactionId
+	return unmarshalStreamName(r, &c.Name)
+}
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand, as
+// CommandFCPublish.UnmarshalCommand does.
+func (c *CommandFCUnpublish) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	c.TransactionId = header.TransactionId
+	return unmarshalStreamName(r, &c.Name)
+}
+
+// unmarshalStreamName decodes the stream name argument of a releaseStream,
+// FCPublish, FCUnpublish, or getStreamLength command into `name`.
+func unmarshalStreamName(r io.Reader, name *string) error {
+	args := new(struct{ Name string })
+	if err := unmarshalArguments(decoderFor(r), args); err != nil {
+		return err
+	}
+
+	*name = args.Name
+	return nil
+}
+
+// fcStatus returns the information object sent by onFCPublish and
+// onFCUnpublish.
+func fcStatus(code, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"code":        code,
+		"description": name,
+	}
+}
@@ -0,0 +1,41 @@
+package stream_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFCPublishCommandsAreParsed(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x09, 0x46, 0x43, 0x50, 0x75, 0x62, 0x6c, 0x69,
+		0x73, 0x68,
+		0x00, 0x40, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+		0x02, 0x00, 0x03, 0x6b, 0x65, 0x79,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandFCPublish{
+		TransactionId: 3,
+		Name:          "key",
+	}, cmd)
+}
+
+func TestFCUnpublishCommandsAreParsed(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x0b, 0x46, 0x43, 0x55, 0x6e, 0x70, 0x75, 0x62,
+		0x6c, 0x69, 0x73, 0x68,
+		0x00, 0x40, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+		0x02, 0x00, 0x03, 0x6b, 0x65, 0x79,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandFCUnpublish{
+		TransactionId: 5,
+		Name:          "key",
+	}, cmd)
+}
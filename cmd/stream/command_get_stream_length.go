@@ -0,0 +1,29 @@
+package stream
+
+import "io"
+
+// CommandGetStreamLength is sent by VOD clients before (or while) playing a
+// stream, to query its duration, so that they are able to show a seek bar. It
+// is sent as either "getStreamLength" or "getMovLen", depending on the client,
+// and is answered with the length of the stream in seconds (see
+// NetStream.RespondStreamLength).
+type CommandGetStreamLength struct {
+	// TransactionId is the transaction ID of the command, which the
+	// _result sent in response carries.
+	TransactionId float64
+	// PlayPath is the name of the stream whose length is queried.
+	PlayPath string
+}
+
+var _ Command = new(CommandGetStreamLength)
+var _ Unmarshaler = new(CommandGetStreamLength)
+
+// IsCommand implements Command.IsCommand.
+func (_ *CommandGetStreamLength) IsCommand() bool { return true }
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand. The name of the
+// stream follows the null command object consumed by the CommandHeader.
+func (c *CommandGetStreamLength) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	c.TransactionId = header.TransactionId
+	return unmarshalStreamName(r, &c.PlayPath)
+}
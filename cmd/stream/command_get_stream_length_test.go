@@ -0,0 +1,38 @@
+package stream_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStreamLengthCommandsAreParsed(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x0f, 0x67, 0x65, 0x74, 0x53, 0x74, 0x72, 0x65,
+		0x61, 0x6d, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x00, 0x40,
+		0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0x02, 0x00,
+		0x03, 0x76, 0x6f, 0x64,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandGetStreamLength{
+		TransactionId: 3,
+		PlayPath:      "vod",
+	}, cmd)
+}
+
+func TestGetMovLenCommandsAreParsedAsGetStreamLength(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x09, 0x67, 0x65, 0x74, 0x4d, 0x6f, 0x76, 0x4c,
+		0x65, 0x6e, 0x00, 0x40, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x05, 0x02, 0x00, 0x03, 0x76, 0x6f, 0x64,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandGetStreamLength{
+		TransactionId: 3,
+		PlayPath:      "vod",
+	}, cmd)
+}
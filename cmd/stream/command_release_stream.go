@@ -0,0 +1,29 @@
+package stream
+
+import "io"
+
+// CommandReleaseStream is sent by the same encoders as CommandFCPublish before
+// publishing a stream, asking the server to release the named stream should it
+// still be held by a previous (for instance, interrupted) publisher. Such
+// encoders expect a _result in response (see NetStream.ReleaseStream), though
+// most will publish without one.
+type CommandReleaseStream struct {
+	// TransactionId is the transaction ID of the command, which the
+	// _result sent in response carries.
+	TransactionId float64
+	// Name is the name (or stream key) of the stream to be released.
+	Name string
+}
+
+var _ Command = new(CommandReleaseStream)
+var _ Unmarshaler = new(CommandReleaseStream)
+
+// IsCommand implements Command.IsCommand.
+func (_ *CommandReleaseStream) IsCommand() bool { return true }
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand. The name of the
+// stream follows the null command object consumed by the CommandHeader.
+func (c *CommandReleaseStream) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	c.TransactionId = header.TransactionId
+	return unmarshalStreamName(r, &c.Name)
+}
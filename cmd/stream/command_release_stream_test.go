@@ -0,0 +1,63 @@
+package stream_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// FMLEPrePublishCommands are the command messages sent by an
+	// FMLE-style encoder (such as OBS, in its legacy mode) between
+	// connecting and publishing the stream "key": releaseStream,
+	// FCPublish, and createStream, in that order.
+	FMLEPrePublishCommands = [][]byte{
+		{
+			0x02, 0x00, 0x0d, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65,
+			0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x00, 0x40, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0x02, 0x00, 0x03, 0x6b,
+			0x65, 0x79,
+		},
+		{
+			0x02, 0x00, 0x09, 0x46, 0x43, 0x50, 0x75, 0x62, 0x6c, 0x69,
+			0x73, 0x68, 0x00, 0x40, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x05, 0x02, 0x00, 0x03, 0x6b, 0x65, 0x79,
+		},
+		{
+			0x02, 0x00, 0x0c, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53,
+			0x74, 0x72, 0x65, 0x61, 0x6d, 0x00, 0x40, 0x10, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x05,
+		},
+	}
+)
+
+func TestReleaseStreamCommandsAreParsed(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(
+		bytes.NewReader(FMLEPrePublishCommands[0]))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandReleaseStream{
+		TransactionId: 2,
+		Name:          "key",
+	}, cmd)
+}
+
+func TestFMLEPrePublishCommandsAreParsed(t *testing.T) {
+	var cmds []stream.Command
+	for _, payload := range FMLEPrePublishCommands {
+		cmd, err := stream.DefaultParser.Parse(bytes.NewReader(payload))
+		assert.Nil(t, err)
+
+		cmds = append(cmds, cmd)
+	}
+
+	assert.Len(t, cmds, 3)
+	assert.IsType(t, new(stream.CommandReleaseStream), cmds[0])
+	assert.Equal(t, &stream.CommandFCPublish{
+		TransactionId: 3,
+		Name:          "key",
+	}, cmds[1])
+	assert.IsType(t, new(stream.CommandCreateStream), cmds[2])
+}
@@ -0,0 +1,101 @@
+package stream
+
+import "io"
+
+const (
+	// ErrorName is the name of the command sent in response to a failed
+	// command, as used in the CommandHeader type.
+	ErrorName string = "_error"
+)
+
+// CommandResult is sent by the client in response to a successful call made by
+// the server (see NetStream.Call), correlated by TransactionId.
+type CommandResult struct {
+	// TransactionId is the transaction ID of the call that this is in
+	// response to.
+	TransactionId float64
+	// Properties holds the decoded properties of the command object, if
+	// any, as in CommandConnect.Parameters.
+	Properties map[string]interface{}
+	// Information holds the decoded values following the command object.
+	Information []interface{}
+}
+
+var _ Command = new(CommandResult)
+var _ Unmarshaler = new(CommandResult)
+
+// IsCommand implements Command.IsCommand.
+func (_ *CommandResult) IsCommand() bool { return true }
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand.
+func (c *CommandResult) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	var err error
+
+	c.TransactionId = header.TransactionId
+	c.Properties, c.Information, err = unmarshalResponse(header, r)
+
+	return err
+}
+
+// CommandError is sent by the client in response to a failed call made by the
+// server (see NetStream.Call), correlated by TransactionId.
+type CommandError struct {
+	// TransactionId is the transaction ID of the call that this is in
+	// response to.
+	TransactionId float64
+	// Properties holds the decoded properties of the command object, if
+	// any, as in CommandConnect.Parameters.
+	Properties map[string]interface{}
+	// Information holds the decoded values following the command object,
+	// which typically describe the error.
+	Information []interface{}
+}
+
+var _ Command = new(CommandError)
+var _ Unmarshaler = new(CommandError)
+
+// IsCommand implements Command.IsCommand.
+func (_ *CommandError) IsCommand() bool { return true }
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand.
+func (c *CommandError) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	var err error
+
+	c.TransactionId = header.TransactionId
+	c.Properties, c.Information, err = unmarshalResponse(header, r)
+
+	return err
+}
+
+// unmarshalResponse decodes the command object of a _result, _error, or unknown
+// command from its CommandHeader, and each of the values following it from "r".
+func unmarshalResponse(header *CommandHeader, r io.Reader) (map[string]interface{}, []interface{}, error) {
+	d := decoderFor(r)
+
+	var info []interface{}
+	for {
+		var v interface{}
+		if err := d.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		info = append(info, simplify(v))
+	}
+
+	return header.Arguments, info, nil
+}
+
+// transactionId returns the transaction ID of the given command, if it is a
+// response to a call.
+func transactionId(cmd Command) (float64, bool) {
+	switch c := cmd.(type) {
+	case *CommandResult:
+		return c.TransactionId, true
+	case *CommandError:
+		return c.TransactionId, true
+	}
+
+	return 0, false
+}
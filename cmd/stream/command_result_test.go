@@ -0,0 +1,52 @@
+package stream_test
+
+import (
+	"bytes"
+	"testing"
+
+	amf "github.com/WatchBeam/rtmp/amf0"
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func encode(vs ...interface{}) []byte {
+	buf := new(bytes.Buffer)
+	enc := amf.NewEncoder(buf)
+	for _, v := range vs {
+		enc.Encode(v)
+	}
+
+	return buf.Bytes()
+}
+
+func TestResultsAreParsed(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader(encode(
+		"_result", 2, map[string]interface{}{"fmsVer": "FMS/3,0,1,123"},
+		map[string]interface{}{"code": "NetConnection.Connect.Success"},
+	)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandResult{
+		TransactionId: 2,
+		Properties: map[string]interface{}{
+			"fmsVer": "FMS/3,0,1,123",
+		},
+		Information: []interface{}{
+			map[string]interface{}{
+				"code": "NetConnection.Connect.Success",
+			},
+		},
+	}, cmd)
+}
+
+func TestErrorsAreParsed(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader(encode(
+		"_error", 3, nil, "failed",
+	)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandError{
+		TransactionId: 3,
+		Information:   []interface{}{"failed"},
+	}, cmd)
+}
@@ -5,7 +5,6 @@ import (
 	"io"
 	"testing"
 
-	"github.com/WatchBeam/amf0/encoding"
 	"github.com/WatchBeam/rtmp/cmd/stream"
 	"github.com/stretchr/testify/assert"
 )
@@ -19,27 +18,25 @@ var (
 )
 
 func TestCommandHeaderRead(t *testing.T) {
-	var header stream.CommandHeader
 	buf := bytes.NewReader(ValidCommandHeader)
 
-	err := encoding.Unmarshal(buf, &header)
+	cmd, err := stream.DefaultParser.Parse(buf)
 
 	assert.Nil(t, err)
-	assert.Equal(t, stream.CommandHeader{
+	assert.Equal(t, &stream.UnknownCommand{
 		Name:          "onStatus",
 		TransactionId: 0,
-		Arguments:     nil,
-	}, header)
+		Properties:    nil,
+	}, cmd)
 }
 
 func TestInvalidCommandHeaderRead(t *testing.T) {
-	var header stream.CommandHeader
 	buf := bytes.NewReader([]byte{
 	// Invalid payload, empty
 	})
 
-	err := encoding.Unmarshal(buf, &header)
+	cmd, err := stream.DefaultParser.Parse(buf)
 
 	assert.Equal(t, io.EOF, err)
-	assert.Equal(t, stream.CommandHeader{}, header)
+	assert.Nil(t, cmd)
 }
@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// UnknownCommand is a command whose name is not known to the Parser that
+// received it, such as a vendor-specific RPC. A lenient *SimpleParser (see
+// SimpleParser.SetStrict) returns unknown commands as an UnknownCommand, rather
+// than failing to parse them, so that they are delivered over the In() channel
+// of a NetStream, and may be handled (or ignored) by the caller.
+type UnknownCommand struct {
+	// Name is the name of the command.
+	Name string
+	// TransactionId is the transaction ID of the command.
+	TransactionId float64
+	// Properties holds the decoded properties of the command object, if
+	// any, as in CommandConnect.Parameters.
+	Properties map[string]interface{}
+	// Arguments holds the decoded values following the command object.
+	Arguments []interface{}
+	// Payload holds the AMF0-encoded arguments following the command
+	// object, if any, so that they may be decoded into other types (for
+	// instance, with amf0.Unmarshal from the github.com/WatchBeam/rtmp/amf0
+	// package).
+	Payload []byte
+}
+
+var _ Command = new(UnknownCommand)
+var _ Unmarshaler = new(UnknownCommand)
+
+// IsCommand implements Command.IsCommand.
+func (_ *UnknownCommand) IsCommand() bool { return true }
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand. Every value
+// following the command object is decoded into Arguments.
+func (c *UnknownCommand) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	var payload []byte
+	if args, ok := r.(*argumentReader); ok {
+		payload = args.remaining()
+	} else {
+		var err error
+		if payload, err = ioutil.ReadAll(r); err != nil {
+			return err
+		}
+		r = bytes.NewReader(payload)
+	}
+
+	props, args, err := unmarshalResponse(header, r)
+	if err != nil {
+		return err
+	}
+
+	c.Name = header.Name
+	c.TransactionId = header.TransactionId
+	c.Properties, c.Arguments = props, args
+	c.Payload = payload
+
+	return nil
+}
@@ -1,32 +1,58 @@
 package stream
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"reflect"
+	"time"
 
-	"github.com/WatchBeam/amf0/encoding"
+	amf "github.com/WatchBeam/rtmp/amf0"
+	"github.com/WatchBeam/rtmp/amf3"
 )
 
 var (
 	// DefaultParser is the default, singleton instance of the Parser type.
 	// It uses the SimpleParser type for its implementation, and is capable
 	// of understanding all commands that are able to be sent over the
-	// NetStream connection.
+	// NetStream connection. It is lenient (see SimpleParser.SetStrict).
 	//
-	// For a complete list of commands that are supported, see the list
-	// below.
-	DefaultParser Parser = NewParser(map[string]CommandFactory{
-		"play":         func() Command { return new(CommandPlay) },
-		"play2":        func() Command { return new(CommandPlay2) },
-		"deleteStream": func() Command { return new(CommandDeleteStream) },
-		"receiveAudio": func() Command { return new(CommandReceiveAudio) },
-		"receiveVideo": func() Command { return new(CommandReceiveVideo) },
-		"publish":      func() Command { return new(CommandPublish) },
-		"seek":         func() Command { return new(CommandSeek) },
-		"pause":        func() Command { return new(CommandPause) },
-	})
+	// For a complete list of commands that are supported, see
+	// NewDefaultParser.
+	DefaultParser Parser = NewDefaultParser()
 )
 
+// NewDefaultParser returns a new, lenient *SimpleParser understanding the same
+// commands as DefaultParser, so that its mode may be changed without affecting
+// DefaultParser.
+func NewDefaultParser() *SimpleParser {
+	return NewParser(map[string]CommandFactory{
+		"connect":       func() Command { return new(CommandConnect) },
+		"createStream":  func() Command { return new(CommandCreateStream) },
+		"play":          func() Command { return new(CommandPlay) },
+		"play2":         func() Command { return new(CommandPlay2) },
+		"deleteStream":  func() Command { return new(CommandDeleteStream) },
+		"closeStream":   func() Command { return new(CommandCloseStream) },
+		"receiveAudio":  func() Command { return new(CommandReceiveAudio) },
+		"receiveVideo":  func() Command { return new(CommandReceiveVideo) },
+		"publish":       func() Command { return new(CommandPublish) },
+		"FCPublish":     func() Command { return new(CommandFCPublish) },
+		"releaseStream": func() Command { return new(CommandReleaseStream) },
+		"FCUnpublish":   func() Command { return new(CommandFCUnpublish) },
+		"seek":          func() Command { return new(CommandSeek) },
+		"pause":         func() Command { return new(CommandPause) },
+		"_result":       func() Command { return new(CommandResult) },
+		"_error":        func() Command { return new(CommandError) },
+		"checkBW":       func() Command { return new(CommandCheckBandwidth) },
+		"_checkbw":      func() Command { return new(CommandCheckBandwidth) },
+		"getMovLen":     func() Command { return new(CommandGetStreamLength) },
+		"getStreamLength": func() Command {
+			return new(CommandGetStreamLength)
+		},
+	})
+}
+
 // CommandFactory is a factory type capabale of producing new instances of
 // command types. By contract, the CommandFactory type should be pseudo-pure
 // function within its parameterized type. In other words, a CommandFactory that
@@ -41,6 +67,9 @@ type SimpleParser struct {
 	// typs is the internal table in which the assosciation between strings
 	// and CommandFactories is stored.
 	typs map[string]CommandFactory
+	// strict is true if the parser rejects commands which deviate from the
+	// specification (see SetStrict).
+	strict bool
 }
 
 var _ Parser = new(SimpleParser)
@@ -48,35 +77,257 @@ var _ Parser = new(SimpleParser)
 // NewParser returns a new instance of the Parser type by using the
 // *SimpleParser as its implementation.
 //
-// The returned parser is initialized with the given map[string]CommandFactory.
+// The returned parser is initialized with the given map[string]CommandFactory,
+// and is lenient (see SetStrict).
 func NewParser(typs map[string]CommandFactory) *SimpleParser {
 	return &SimpleParser{
 		typs: typs,
 	}
 }
 
+// SetStrict sets whether the parser is strict, or lenient. Strict parsers
+// return an error for any command whose name is unknown, or which carries data
+// after its last argument (commands which accept optional arguments, such as
+// connect and play, read them all). Lenient parsers instead return unknown
+// commands as an *UnknownCommand, and skip any trailing data, favoring
+// interoperability with clients which send slightly malformed commands over
+// correctness.
+//
+// SetStrict is not safe to call while the parser is in use.
+func (p *SimpleParser) SetStrict(strict bool) {
+	p.strict = strict
+}
+
 // Parse implements the Parse function in `type Parser interface`. It determines
 // first the CommandHeader assosciated with the io.Reader, then creates a new
-// instance of the corresponding command type and then parses into it.
+// instance of the corresponding command type and then parses into it. Commands
+// implementing the Unmarshaler interface are handed the CommandHeader and parse
+// themselves instead. Both are decoded with a single *amf0.Decoder (from the
+// github.com/WatchBeam/rtmp/amf0 package), so that references among the values
+// of the command are resolved.
+//
+// Commands sent by AMF3 clients may embed AMF3 values, prefixed by the AMF0
+// avmplus-object marker (0x11). When that marker may be present, those values
+// are transcoded into AMF0 before parsing (see amf3.Transcode). If the payload
+// cannot be transcoded, it is parsed as it is, since the byte may instead have
+// been part of an AMF0 value, or of trailing data that is never parsed. Should
+// parsing then fail on an AMF3 value, the transcoding error is reported as the
+// cause of the *amf0.ParseError. Typed objects, dates, and other AMF0 values
+// without a Go counterpart are simplified as they are decoded (see simplify).
 //
 // If an error is encountered in parsing, or if no matching command can be
-// found, then an error will be returned.
+// found and the parser is strict, then an error will be returned (see
+// SetStrict). An empty payload results in io.EOF.
+// Otherwise, errors encountered in parsing the command header or arguments are
+// returned as an *amf0.ParseError, whose offset is relative to the start of the
+// (transcoded) payload.
 func (p *SimpleParser) Parse(r io.Reader) (Command, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, terr := transcodeAMF3(raw)
+	if terr != nil {
+		data = raw
+	}
+
+	cmd, err := p.parse(data)
+	if perr, ok := err.(*amf.ParseError); ok && terr != nil &&
+		perr.Offset < int64(len(data)) &&
+		data[perr.Offset] == amf3.AVMPlusObjectMarker {
+
+		perr.Err = terr
+	}
+
+	return cmd, err
+}
+
+// parse parses the command held by the given AMF0 payload, as Parse does.
+func (p *SimpleParser) parse(data []byte) (Command, error) {
+	args := newArgumentReader(data)
+	br := args.Reader
+
 	meta := new(CommandHeader)
-	if err := encoding.Unmarshal(r, meta); err != nil {
+	if err := unmarshalArguments(args.dec, meta); err == io.EOF && len(data) == 0 {
 		return nil, err
+	} else if err != nil {
+		return nil, parseError(data, br, "command header", err)
 	}
 
 	factory, ok := p.typs[meta.Name]
-	if !ok {
+	if !ok && p.strict {
 		return nil, fmt.Errorf(
 			"cmd/stream: unknown NetStream command %s", meta.Name)
+	} else if !ok {
+		factory = func() Command { return new(UnknownCommand) }
 	}
 
 	cmd := factory()
-	if err := encoding.Unmarshal(r, cmd); err != nil {
-		return nil, err
+	expected := fmt.Sprintf("%s arguments", meta.Name)
+
+	if u, ok := cmd.(Unmarshaler); ok {
+		if err := u.UnmarshalCommand(meta, args); err != nil {
+			return nil, parseError(data, br, expected, err)
+		}
+	} else if err := unmarshalArguments(args.dec, cmd); err != nil {
+		return nil, parseError(data, br, expected, err)
+	}
+
+	if p.strict && br.Len() > 0 {
+		offset := len(data) - br.Len()
+
+		return nil, &amf.ParseError{
+			Offset:   int64(offset),
+			Expected: "end of data",
+			Actual:   amf.Marker(data[offset]).String(),
+			Err:      amf.ErrTrailingData,
+		}
 	}
 
 	return cmd, nil
 }
+
+// parseError wraps an error encountered while parsing the given part of a
+// command out of "data" in an *amf0.ParseError, located at the offset up to
+// which "r" had read. Errors that are already an *amf0.ParseError keep their
+// offset and cause, but are reported as expecting the given part.
+func parseError(data []byte, r *bytes.Reader, expected string, err error) error {
+	perr := &amf.ParseError{
+		Offset:   int64(len(data) - r.Len()),
+		Expected: expected,
+		Err:      err,
+	}
+
+	if inner, ok := err.(*amf.ParseError); ok {
+		perr.Offset, perr.Actual, perr.Err = inner.Offset, inner.Actual, inner.Err
+	} else if r.Len() == 0 {
+		perr.Actual = "end of data"
+	}
+
+	return perr
+}
+
+// transcodeAMF3 returns the given payload. If the payload may contain AMF3
+// values, they are transcoded into AMF0 first.
+func transcodeAMF3(data []byte) ([]byte, error) {
+	if bytes.IndexByte(data, amf3.AVMPlusObjectMarker) < 0 {
+		return data, nil
+	}
+
+	buf := new(bytes.Buffer)
+	if err := amf3.Transcode(buf, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// argumentReader is the io.Reader handed to Unmarshalers by the SimpleParser,
+// holding the remainder of a command. It carries the *amf0.Decoder that read
+// the CommandHeader, so that the arguments are decoded by it as well (see
+// decoderFor).
+type argumentReader struct {
+	*bytes.Reader
+
+	// data is the payload of the command, read by the Reader.
+	data []byte
+	// dec is the Decoder reading from the Reader.
+	dec *amf.Decoder
+}
+
+// newArgumentReader returns a new *argumentReader reading the given payload
+// from its start.
+func newArgumentReader(data []byte) *argumentReader {
+	r := bytes.NewReader(data)
+
+	return &argumentReader{Reader: r, data: data, dec: amf.NewDecoder(r)}
+}
+
+// remaining returns the part of the payload which has not yet been read, or nil
+// if all of it has.
+func (r *argumentReader) remaining() []byte {
+	if r.Len() == 0 {
+		return nil
+	}
+
+	return r.data[len(r.data)-r.Len():]
+}
+
+// decoderFor returns the *amf0.Decoder that the arguments held in "r" should be
+// decoded with: the one which decoded the CommandHeader, if "r" was handed to
+// an Unmarshaler by the SimpleParser, or a new one reading from "r" otherwise.
+func decoderFor(r io.Reader) *amf.Decoder {
+	if args, ok := r.(*argumentReader); ok {
+		return args.dec
+	}
+
+	return amf.NewDecoder(r)
+}
+
+// unmarshalArguments decodes consecutive AMF0 values from "d" into each
+// exported field of the struct pointed to by "v", in order, as the arguments
+// of a command are laid out. Values decoded into an empty interface, or a
+// map[string]interface{}, are simplified (see simplify).
+func unmarshalArguments(d *amf.Decoder, v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+
+	for i := 0; i < rv.NumField(); i++ {
+		if rv.Type().Field(i).PkgPath != "" {
+			continue
+		}
+
+		field := rv.Field(i).Addr().Interface()
+		if err := d.Decode(field); err != nil {
+			return err
+		}
+
+		switch f := field.(type) {
+		case *interface{}:
+			*f = simplify(*f)
+		case *map[string]interface{}:
+			simplifyPairs(*f)
+		}
+	}
+
+	return nil
+}
+
+// simplify returns the given decoded value with the AMF0 values that have no
+// counterpart among the values of other commands rewritten: typed objects as
+// the map of their properties (dropping their class name), ECMA arrays as maps,
+// dates as numbers of milliseconds since the epoch, and undefined as nil. Maps
+// and slices are simplified in place.
+//
+// A Decoder resolves each reference to the very map or slice that it refers
+// to, which is therefore simplified more than once, but never forms a cycle.
+func simplify(v interface{}) interface{} {
+	switch v := v.(type) {
+	case amf.TypedObject:
+		return simplifyPairs(v.Properties)
+	case amf.ECMAArray:
+		return simplifyPairs(v)
+	case map[string]interface{}:
+		return simplifyPairs(v)
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = simplify(elem)
+		}
+	case time.Time:
+		return float64(v.UnixNano() / int64(time.Millisecond))
+	case amf.Undefined:
+		return nil
+	}
+
+	return v
+}
+
+// simplifyPairs simplifies each value of the given map in place (see
+// simplify), returning it.
+func simplifyPairs(m map[string]interface{}) map[string]interface{} {
+	for k, v := range m {
+		m[k] = simplify(v)
+	}
+
+	return m
+}
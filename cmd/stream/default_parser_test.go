@@ -5,6 +5,7 @@ import (
 	"io"
 	"testing"
 
+	amf "github.com/WatchBeam/rtmp/amf0"
 	"github.com/WatchBeam/rtmp/cmd/stream"
 	"github.com/stretchr/testify/assert"
 )
@@ -44,7 +45,8 @@ func TestParserReturnsParsingErrors(t *testing.T) {
 }
 
 func TestParserReturnsErrorsWhenNoMatchingTypeIsFound(t *testing.T) {
-	p := stream.DefaultParser
+	p := stream.NewDefaultParser()
+	p.SetStrict(true)
 
 	cmd, err := p.Parse(bytes.NewReader([]byte{
 		0x02, 0x00, 0x03, 0x66, 0x6f, 0x6f, 0x00, 0x40, 0x14, 0x00,
@@ -67,5 +69,386 @@ func TestParserReturnsBodyErrors(t *testing.T) {
 	}))
 
 	assert.Nil(t, cmd)
-	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, &amf.ParseError{
+		Offset:   20,
+		Expected: "publish arguments",
+		Actual:   "end of data",
+		Err:      io.EOF,
+	}, err)
+}
+
+func TestParserReturnsHeaderErrorsWithOffsets(t *testing.T) {
+	p := stream.DefaultParser
+
+	cmd, err := p.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x07, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68,
+		0x00, 0x40, 0x14,
+	}))
+
+	assert.Nil(t, cmd)
+	assert.IsType(t, new(amf.ParseError), err)
+	assert.EqualValues(t, 13, err.(*amf.ParseError).Offset)
+	assert.Equal(t, "command header", err.(*amf.ParseError).Expected)
+}
+
+func TestParserParsesCommandsWithAMF3Values(t *testing.T) {
+	p := stream.DefaultParser
+
+	cmd, err := p.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x07, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68,
+		0x00, 0x40, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+		// <AMF3>
+		0x11, 0x06, 0x07, 0x66, 0x6f, 0x6f,
+		0x11, 0x06, 0x09, 0x6c, 0x69, 0x76, 0x65,
+		// </AMF3>
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandPublish{
+		Name: "foo",
+		Type: "live",
+	}, cmd)
+}
+
+// amf3ConnectPayload is a connect command laid out as Flash Player sends it
+// once NetConnection.objectEncoding is set to 3: the command object is AMF0,
+// and the user argument passed to NetConnection.connect follows it as an AMF3
+// object, behind the avmplus-object marker.
+var amf3ConnectPayload = []byte{
+	// "connect", 1, {
+	0x02, 0x00, 0x07, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+	0x00, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03,
+	// "app": "live"
+	0x00, 0x03, 0x61, 0x70, 0x70, 0x02, 0x00, 0x04, 0x6c, 0x69,
+	0x76, 0x65,
+	// "flashVer": "WIN 11,2,202,235"
+	0x00, 0x08, 0x66, 0x6c, 0x61, 0x73, 0x68, 0x56, 0x65, 0x72,
+	0x02, 0x00, 0x10, 0x57, 0x49, 0x4e, 0x20, 0x31, 0x31, 0x2c,
+	0x32, 0x2c, 0x32, 0x30, 0x32, 0x2c, 0x32, 0x33, 0x35,
+	// "swfUrl": "http://example.com/player.swf"
+	0x00, 0x06, 0x73, 0x77, 0x66, 0x55, 0x72, 0x6c, 0x02, 0x00,
+	0x1d, 0x68, 0x74, 0x74, 0x70, 0x3a, 0x2f, 0x2f, 0x65, 0x78,
+	0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x2e, 0x73, 0x77, 0x66,
+	// "tcUrl": "rtmp://example.com/live"
+	0x00, 0x05, 0x74, 0x63, 0x55, 0x72, 0x6c, 0x02, 0x00, 0x17,
+	0x72, 0x74, 0x6d, 0x70, 0x3a, 0x2f, 0x2f, 0x65, 0x78, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6c,
+	0x69, 0x76, 0x65,
+	// "fpad": false
+	0x00, 0x04, 0x66, 0x70, 0x61, 0x64, 0x01, 0x00,
+	// "capabilities": 239
+	0x00, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69,
+	0x74, 0x69, 0x65, 0x73, 0x00, 0x40, 0x6d, 0xe0, 0x00, 0x00,
+	0x00, 0x00, 0x00,
+	// "audioCodecs": 3575
+	0x00, 0x0b, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x43, 0x6f, 0x64,
+	0x65, 0x63, 0x73, 0x00, 0x40, 0xab, 0xee, 0x00, 0x00, 0x00,
+	0x00, 0x00,
+	// "videoCodecs": 252
+	0x00, 0x0b, 0x76, 0x69, 0x64, 0x65, 0x6f, 0x43, 0x6f, 0x64,
+	0x65, 0x63, 0x73, 0x00, 0x40, 0x6f, 0x80, 0x00, 0x00, 0x00,
+	0x00, 0x00,
+	// "videoFunction": 1
+	0x00, 0x0d, 0x76, 0x69, 0x64, 0x65, 0x6f, 0x46, 0x75, 0x6e,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x00, 0x3f, 0xf0, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+	// "pageUrl": "http://example.com/"
+	0x00, 0x07, 0x70, 0x61, 0x67, 0x65, 0x55, 0x72, 0x6c, 0x02,
+	0x00, 0x13, 0x68, 0x74, 0x74, 0x70, 0x3a, 0x2f, 0x2f, 0x65,
+	0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f,
+	// "objectEncoding": 3
+	0x00, 0x0e, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x45, 0x6e,
+	0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x00, 0x40, 0x08, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00,
+	// }
+	0x00, 0x00, 0x09,
+	// <AMF3> {"token": "abc123"} </AMF3>
+	0x11, 0x0a, 0x0b, 0x01, 0x0b, 0x74, 0x6f, 0x6b, 0x65, 0x6e,
+	0x06, 0x0d, 0x61, 0x62, 0x63, 0x31, 0x32, 0x33, 0x01,
+}
+
+func TestParserParsesConnectCommandsFromAMF3Clients(t *testing.T) {
+	p := stream.NewDefaultParser()
+	p.SetStrict(true)
+
+	cmd, err := p.Parse(bytes.NewReader(amf3ConnectPayload))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandConnect{
+		TransactionId: 1,
+		Parameters: map[string]interface{}{
+			"app":            "live",
+			"flashVer":       "WIN 11,2,202,235",
+			"swfUrl":         "http://example.com/player.swf",
+			"tcUrl":          "rtmp://example.com/live",
+			"fpad":           false,
+			"capabilities":   float64(239),
+			"audioCodecs":    float64(3575),
+			"videoCodecs":    float64(252),
+			"videoFunction":  float64(1),
+			"pageUrl":        "http://example.com/",
+			"objectEncoding": float64(3),
+		},
+	}, cmd)
+	assert.Equal(t, stream.ObjectEncodingAMF3,
+		cmd.(*stream.CommandConnect).Encoding())
+}
+
+func TestParserTranscodesAMF3ObjectsInConnectCommands(t *testing.T) {
+	p := stream.NewParser(map[string]stream.CommandFactory{})
+
+	cmd, err := p.Parse(bytes.NewReader(amf3ConnectPayload))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"token": "abc123"},
+	}, cmd.(*stream.UnknownCommand).Arguments)
+}
+
+func TestParserReturnsAMF3Errors(t *testing.T) {
+	p := stream.DefaultParser
+
+	cmd, err := p.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x07, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68,
+		0x00, 0x40, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+		0x11, 0x06, 0x02,
+	}))
+
+	assert.Nil(t, cmd)
+	if assert.IsType(t, &amf.ParseError{}, err) {
+		perr := err.(*amf.ParseError)
+
+		assert.EqualValues(t, 20, perr.Offset)
+		assert.Equal(t, "rtmp/amf3: invalid reference (1)", perr.Err.Error())
+	}
+}
+
+// createStreamWithTrailingData is a createStream command with the transaction
+// ID 4369, whose encoding contains the byte of the avmplus-object marker,
+// followed by data that is not valid AMF0.
+var createStreamWithTrailingData = []byte{
+	// "createStream", 4369, null
+	0x02, 0x00, 0x0c, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53,
+	0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x00, 0x40, 0xb1, 0x11, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x05,
+	// trailing data
+	0xff, 0xff,
+}
+
+func TestLenientParsersIgnoreMarkerBytesWithinAMF0Values(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(
+		bytes.NewReader(createStreamWithTrailingData))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandCreateStream{TransactionId: 4369}, cmd)
+}
+
+func TestStrictParsersRejectTrailingDataAfterMarkerBytes(t *testing.T) {
+	p := stream.NewDefaultParser()
+	p.SetStrict(true)
+
+	cmd, err := p.Parse(bytes.NewReader(createStreamWithTrailingData))
+
+	assert.Nil(t, cmd)
+	if assert.IsType(t, &amf.ParseError{}, err) {
+		assert.EqualValues(t, 25, err.(*amf.ParseError).Offset)
+	}
+}
+
+func TestParserParsesRecordingPublishCommands(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		0x02, 0x00, 0x07, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68,
+		0x00, 0x40, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+		0x02, 0x00, 0x03, 0x66, 0x6f, 0x6f,
+		0x02, 0x00, 0x06, 0x61, 0x70, 0x70, 0x65, 0x6e, 0x64,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, stream.PublishAppend,
+		cmd.(*stream.CommandPublish).PublishingType())
+}
+
+func TestParserParsesSeekCommands(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		// "seek", 0, null
+		0x02, 0x00, 0x04, 0x73, 0x65, 0x65, 0x6b,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+		// 1500
+		0x00, 0x40, 0x97, 0x70, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandSeek{OffsetMillis: 1500}, cmd)
+}
+
+func TestParserParsesPauseCommands(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		// "pause", 0, null
+		0x02, 0x00, 0x05, 0x70, 0x61, 0x75, 0x73, 0x65,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+		// true, 2000
+		0x01, 0x01,
+		0x00, 0x40, 0x9f, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandPause{
+		Paused:       true,
+		CutoffMillis: 2000,
+	}, cmd)
+}
+
+func TestParserParsesCommandsWithTypedObjectsAndDates(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		// "connect", 1
+		0x02, 0x00, 0x07, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+		0x00, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x03,
+		// "app": "live"
+		0x00, 0x03, 0x61, 0x70, 0x70,
+		0x02, 0x00, 0x04, 0x6c, 0x69, 0x76, 0x65,
+		// "client": Foo{"name": "bar"}
+		0x00, 0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74,
+		0x10, 0x00, 0x03, 0x46, 0x6f, 0x6f,
+		0x00, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+		0x02, 0x00, 0x03, 0x62, 0x61, 0x72,
+		0x00, 0x00, 0x09,
+		// "time": 2001-09-09T01:46:40Z, in UTC+8
+		0x00, 0x04, 0x74, 0x69, 0x6d, 0x65,
+		0x0b, 0x42, 0x6d, 0x1a, 0x94, 0xa2, 0x00, 0x00, 0x00,
+		0x01, 0xe0,
+		0x00, 0x00, 0x09,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandConnect{
+		TransactionId: 1,
+		Parameters: map[string]interface{}{
+			"app":    "live",
+			"client": map[string]interface{}{"name": "bar"},
+			"time":   float64(1000000000000),
+		},
+	}, cmd)
+}
+
+func TestParserParsesCommandsWithReferences(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		// "connect", 1
+		0x02, 0x00, 0x07, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+		0x00, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x03,
+		// "a": {"x": true}
+		0x00, 0x01, 0x61,
+		0x03, 0x00, 0x01, 0x78, 0x01, 0x01, 0x00, 0x00, 0x09,
+		// "b": a reference to "a"
+		0x00, 0x01, 0x62, 0x07, 0x00, 0x01,
+		0x00, 0x00, 0x09,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandConnect{
+		TransactionId: 1,
+		Parameters: map[string]interface{}{
+			"a": map[string]interface{}{"x": true},
+			"b": map[string]interface{}{"x": true},
+		},
+	}, cmd)
+}
+
+func TestParserResolvesReferencesToTheCommandObject(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		// "foo", 5, {"x": true}
+		0x02, 0x00, 0x03, 0x66, 0x6f, 0x6f,
+		0x00, 0x40, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x03, 0x00, 0x01, 0x78, 0x01, 0x01, 0x00, 0x00, 0x09,
+		// a reference to the command object
+		0x07, 0x00, 0x00,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.UnknownCommand{
+		Name:          "foo",
+		TransactionId: 5,
+		Properties:    map[string]interface{}{"x": true},
+		Arguments: []interface{}{
+			map[string]interface{}{"x": true},
+		},
+		Payload: []byte{0x07, 0x00, 0x00},
+	}, cmd)
+}
+
+func TestLenientParsersReturnUnknownCommands(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader([]byte{
+		// "foo", 5, null
+		0x02, 0x00, 0x03, 0x66, 0x6f, 0x6f,
+		0x00, 0x40, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+		// "bar"
+		0x02, 0x00, 0x03, 0x62, 0x61, 0x72,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.UnknownCommand{
+		Name:          "foo",
+		TransactionId: 5,
+		Arguments:     []interface{}{"bar"},
+		Payload:       []byte{0x02, 0x00, 0x03, 0x62, 0x61, 0x72},
+	}, cmd)
+}
+
+// TrailingPublish is a publish command carrying a null after its last argument.
+var TrailingPublish = []byte{
+	0x02, 0x00, 0x07, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68,
+	0x00, 0x40, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+	0x02, 0x00, 0x03, 0x66, 0x6f, 0x6f,
+	0x02, 0x00, 0x04, 0x6c, 0x69, 0x76, 0x65,
+	0x05,
+}
+
+func TestLenientParsersSkipTrailingData(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader(TrailingPublish))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandPublish{Name: "foo", Type: "live"}, cmd)
+}
+
+func TestStrictParsersRejectTrailingData(t *testing.T) {
+	p := stream.NewDefaultParser()
+	p.SetStrict(true)
+
+	cmd, err := p.Parse(bytes.NewReader(TrailingPublish))
+
+	assert.Nil(t, cmd)
+	assert.Equal(t, &amf.ParseError{
+		Offset:   33,
+		Expected: "end of data",
+		Actual:   "null",
+		Err:      amf.ErrTrailingData,
+	}, err)
+}
+
+func TestStrictParsersAcceptOptionalArguments(t *testing.T) {
+	p := stream.NewDefaultParser()
+	p.SetStrict(true)
+
+	cmd, err := p.Parse(bytes.NewReader([]byte{
+		// "play", 0, null
+		0x02, 0x00, 0x04, 0x70, 0x6c, 0x61, 0x79,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+		// "foo", -2 (start)
+		0x02, 0x00, 0x03, 0x66, 0x6f, 0x6f,
+		0x00, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		// -1 (duration), true (reset)
+		0x00, 0xbf, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x01, 0x01,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandPlay{PlayPath: "foo", Live: -2}, cmd)
 }
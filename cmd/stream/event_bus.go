@@ -0,0 +1,91 @@
+package stream
+
+import "sync"
+
+// EventBus holds callbacks invoked at points in the lifecycle of a connection,
+// as an alternative to receiving commands over a NetStream's In() channel. It
+// is additive: commands are still delivered over In() after the callbacks
+// registered for them have returned.
+//
+// Callbacks are invoked synchronously by the NetStream's Listen routine, in the
+// order that they were registered, so they should return promptly, and must
+// not call NetStream.Close.
+type EventBus struct {
+	// emu guards all of the callbacks below.
+	emu sync.Mutex
+
+	onConnect []func(*CommandConnect)
+	onPublish []func(*CommandPublish)
+	onPlay    []func(*CommandPlay)
+	onClose   []func()
+}
+
+// NewEventBus returns a new *EventBus with no callbacks registered.
+func NewEventBus() *EventBus {
+	return new(EventBus)
+}
+
+// OnConnect registers a callback invoked when a connect command is received.
+func (b *EventBus) OnConnect(fn func(*CommandConnect)) {
+	b.emu.Lock()
+	defer b.emu.Unlock()
+
+	b.onConnect = append(b.onConnect, fn)
+}
+
+// OnPublish registers a callback invoked when a publish command is received.
+func (b *EventBus) OnPublish(fn func(*CommandPublish)) {
+	b.emu.Lock()
+	defer b.emu.Unlock()
+
+	b.onPublish = append(b.onPublish, fn)
+}
+
+// OnPlay registers a callback invoked when a play command is received.
+func (b *EventBus) OnPlay(fn func(*CommandPlay)) {
+	b.emu.Lock()
+	defer b.emu.Unlock()
+
+	b.onPlay = append(b.onPlay, fn)
+}
+
+// OnClose registers a callback invoked when the NetStream stops listening.
+func (b *EventBus) OnClose(fn func()) {
+	b.emu.Lock()
+	defer b.emu.Unlock()
+
+	b.onClose = append(b.onClose, fn)
+}
+
+// dispatch invokes the callbacks registered for the given command, if any.
+func (b *EventBus) dispatch(cmd Command) {
+	b.emu.Lock()
+	onConnect, onPublish, onPlay := b.onConnect, b.onPublish, b.onPlay
+	b.emu.Unlock()
+
+	switch c := cmd.(type) {
+	case *CommandConnect:
+		for _, fn := range onConnect {
+			fn(c)
+		}
+	case *CommandPublish:
+		for _, fn := range onPublish {
+			fn(c)
+		}
+	case *CommandPlay:
+		for _, fn := range onPlay {
+			fn(c)
+		}
+	}
+}
+
+// close invokes the callbacks registered with OnClose.
+func (b *EventBus) close() {
+	b.emu.Lock()
+	onClose := b.onClose
+	b.emu.Unlock()
+
+	for _, fn := range onClose {
+		fn()
+	}
+}
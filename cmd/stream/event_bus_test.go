@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBusCallbacksAreInvokedBeforeCommandsAreDelivered(t *testing.T) {
+	chunks := make(chan *chunk.Chunk)
+	s := New(chunks, chunk.NoopWriter)
+
+	var seen []Command
+	s.Events().OnConnect(func(c *CommandConnect) { seen = append(seen, c) })
+	s.Events().OnPublish(func(c *CommandPublish) { seen = append(seen, c) })
+	s.Events().OnPlay(func(c *CommandPlay) { seen = append(seen, c) })
+
+	go s.Listen()
+	defer s.Close()
+
+	for _, c := range []*chunk.Chunk{
+		{Data: invokeData("connect", 1, map[string]interface{}{})},
+		{Data: invokeData("publish", 0, nil, "foo", "live")},
+		{Data: invokeData("play", 0, nil, "foo", -2)},
+	} {
+		chunks <- c
+
+		cmd := <-s.In()
+		assert.Equal(t, cmd, seen[len(seen)-1])
+	}
+
+	assert.Len(t, seen, 3)
+}
+
+func TestEventBusCallbacksAreInvokedInOrder(t *testing.T) {
+	b := NewEventBus()
+
+	var order []int
+	b.OnPublish(func(*CommandPublish) { order = append(order, 1) })
+	b.OnPublish(func(*CommandPublish) { order = append(order, 2) })
+
+	b.dispatch(new(CommandPublish))
+	b.dispatch(new(CommandSeek))
+
+	assert.Equal(t, []int{1, 2}, order)
+}
+
+func TestEventBusCloseCallbacksAreInvokedWhenListenStops(t *testing.T) {
+	s := New(make(chan *chunk.Chunk), chunk.NoopWriter)
+
+	closed := make(chan struct{})
+	s.Events().OnClose(func() { close(closed) })
+
+	go s.Listen()
+	s.Close()
+
+	<-closed
+}
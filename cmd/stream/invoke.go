@@ -0,0 +1,73 @@
+package stream
+
+import (
+	"bytes"
+
+	amf "github.com/WatchBeam/rtmp/amf0"
+	"github.com/WatchBeam/rtmp/chunk"
+)
+
+const (
+	// InvokeChunkStreamId is the chunk stream ID that server-originated
+	// commands are sent over.
+	InvokeChunkStreamId uint32 = 3
+	// InvokeMessageStreamId is the message stream ID that
+	// server-originated commands are sent over.
+	InvokeMessageStreamId uint32 = 0
+)
+
+// Invoke is a command sent by the server in order to call a method on the
+// client, such as "onBWDone" or "close".
+type Invoke struct {
+	// Name is the name of the method being called.
+	Name string
+	// TransactionId is the transaction ID of the call. It is 0 when no
+	// response is expected.
+	TransactionId float64
+	// Arguments are the arguments of the call, written after the
+	// TransactionId. By convention, the first argument is the command
+	// object, which is nil (encoded as null) when there is none.
+	Arguments []interface{}
+}
+
+// AsChunk marshals the Invoke into a chunk, including its header. Each argument
+// is encoded as by the amf0.Marshal function of the github.com/WatchBeam/rtmp/amf0
+// package. If any argument was unable to be marshalled, then an error will be
+// returned instead.
+func (i *Invoke) AsChunk() (*chunk.Chunk, error) {
+	payload, err := marshalArguments(append(
+		[]interface{}{i.Name, i.TransactionId}, i.Arguments...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader: chunk.BasicHeader{
+				StreamId: InvokeChunkStreamId,
+			},
+			MessageHeader: chunk.MessageHeader{
+				Length:   uint32(len(payload)),
+				TypeId:   Amf0CmdTypeId,
+				StreamId: InvokeMessageStreamId,
+			},
+		},
+		Data: payload,
+	}, nil
+}
+
+// marshalArguments encodes each of the given values in turn, as the name,
+// transaction ID, and arguments of a command are laid out, returning the
+// result.
+func marshalArguments(values ...interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := amf.NewEncoder(buf)
+
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,62 @@
+package stream_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvokeMarshalsIntoChunks(t *testing.T) {
+	c, err := (&stream.Invoke{
+		Name:          "close",
+		TransactionId: 0,
+		Arguments:     []interface{}{nil},
+	}).AsChunk()
+
+	assert.Nil(t, err)
+	assert.Equal(t, &chunk.Header{
+		BasicHeader: chunk.BasicHeader{
+			StreamId: stream.InvokeChunkStreamId,
+		},
+		MessageHeader: chunk.MessageHeader{
+			Length:   18,
+			TypeId:   stream.Amf0CmdTypeId,
+			StreamId: stream.InvokeMessageStreamId,
+		},
+	}, c.Header)
+	assert.Equal(t, []byte{
+		0x02, 0x00, 0x05, 0x63, 0x6c, 0x6f, 0x73, 0x65,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+	}, c.Data)
+}
+
+func TestInvokeReturnsMarshalingErrors(t *testing.T) {
+	c, err := (&stream.Invoke{
+		Name:      "onBWDone",
+		Arguments: []interface{}{make(chan int)},
+	}).AsChunk()
+
+	assert.Nil(t, c)
+	assert.NotNil(t, err)
+}
+
+func TestInvokedCommandsRoundTrip(t *testing.T) {
+	c, err := (&stream.Invoke{
+		Name:          "play",
+		TransactionId: 0,
+		Arguments:     []interface{}{nil, "foo", -2},
+	}).AsChunk()
+	assert.Nil(t, err)
+
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader(c.Data))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandPlay{
+		PlayPath: "foo",
+		Live:     -2,
+	}, cmd)
+}
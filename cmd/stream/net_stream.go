@@ -2,8 +2,22 @@ package stream
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"sync"
 
 	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/control"
+	"github.com/WatchBeam/rtmp/logging"
+	"github.com/WatchBeam/rtmp/tracing"
+)
+
+const (
+	// AMF3CommandTypeId is the message type ID of command messages sent
+	// by clients that have negotiated AMF3 object encoding. The body of
+	// these messages is prefixed by a single format byte, followed by the
+	// same AMF0 payload sent in ordinary command messages (0x14).
+	AMF3CommandTypeId byte = 0x11
 )
 
 // Type NetStream is an implementation of the NetStream type as described in the
@@ -30,9 +44,61 @@ type NetStream struct {
 	// writer is the chunk.Writer where `onStatus` commands are written to.
 	writer chunk.Writer
 
-	// closer is a channel written to when the Listen operation should be
+	// ids allocates the message stream IDs of streams created over this
+	// NetStream's connection.
+	ids *StreamIdAllocator
+
+	// emu guards encoding.
+	emu sync.Mutex
+	// encoding is the object encoding negotiated by the client, which
+	// determines how commands written to it are encoded.
+	encoding ObjectEncoding
+
+	// events holds the lifecycle callbacks invoked by Listen.
+	events *EventBus
+
+	// amu guards auth.
+	amu sync.Mutex
+	// auth is the Authenticator consulted by Publish and Play, if any.
+	auth Authenticator
+
+	// cmu guards txnId and calls.
+	cmu sync.Mutex
+	// txnId is the transaction ID of the last call made with Call.
+	txnId float64
+	// calls maps the transaction IDs of calls awaiting a response to the
+	// channel that the response is delivered on.
+	calls map[float64]chan Command
+
+	// fmu guards autoFCPublish.
+	fmu sync.Mutex
+	// autoFCPublish is whether or not Listen responds to FCPublish and
+	// FCUnpublish commands by itself (see SetAutoFCPublish).
+	autoFCPublish bool
+
+	// lmu guards log.
+	lmu sync.Mutex
+	// log is the Logger that internal events are reported to.
+	log logging.Logger
+
+	// xmu guards ctx.
+	xmu sync.Mutex
+	// ctx is the context of the connection that this NetStream belongs to.
+	ctx context.Context
+
+	// closer is a channel closed when the Listen operation should be
 	// closed.
 	closer chan struct{}
+	// closeOnce ensures that closer is closed only once.
+	closeOnce sync.Once
+	// done is closed once the Listen operation has stopped.
+	done chan struct{}
+
+	// rmu guards running.
+	rmu sync.Mutex
+	// running is whether or not the Listen operation has been started.
+	running bool
+
 	// errs is a chnanel written to whenever an error is encountered during
 	// the Listen goroutine.
 	errs chan error
@@ -50,8 +116,18 @@ func New(chunks <-chan *chunk.Chunk, writer chunk.Writer) *NetStream {
 
 		parser: DefaultParser,
 
+		ids: NewStreamIdAllocator(),
+
+		calls: make(map[float64]chan Command),
+
+		events: NewEventBus(),
+
+		log: logging.Noop,
+		ctx: context.Background(),
+
 		in:     make(chan Command),
 		closer: make(chan struct{}),
+		done:   make(chan struct{}),
 		errs:   make(chan error),
 	}
 }
@@ -64,30 +140,511 @@ func (n *NetStream) In() <-chan Command { return n.in }
 // operation.
 func (n *NetStream) Errs() <-chan error { return n.errs }
 
-// Close closes the Listen routine. Calling this function blocks until the
-// Listen routine has entered a closing state. Should this function be called
+// Events returns the *EventBus whose callbacks are invoked as commands are
+// received by the Listen routine, and when it stops.
+func (n *NetStream) Events() *EventBus { return n.events }
+
+// Close closes the Listen routine. If the Listen routine is running, calling
+// this function blocks until it has stopped. Should this function be called
 // while a parse or send operation is taking place, then that operation will
 // finish before the close operation takes place immediately afterwords.
-func (n *NetStream) Close() { n.closer <- struct{}{} }
+//
+// If the Listen routine has not been started, Close returns immediately, and
+// Listen stops as soon as it is started. Calling Close more than once is a
+// no-op.
+func (n *NetStream) Close() {
+	n.closeOnce.Do(func() { close(n.closer) })
+
+	if n.isRunning() {
+		<-n.done
+	}
+}
+
+// CloseContext closes the Listen routine, as Close does, but blocks until it
+// has stopped, even if it has not yet been started. If "ctx" is done first (for
+// instance, because Listen was never called), its error is returned instead.
+func (n *NetStream) CloseContext(ctx context.Context) error {
+	n.closeOnce.Do(func() { close(n.closer) })
+
+	select {
+	case <-n.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-// WriteStatus writes the status out to the chunk stream, returning any error
-// that it encountered during the marhsaling stage, or the network stage. If
-// neither of those processes failed, then the Status was written successfully
-// and a value of "nil" will be returned.
-func (n *NetStream) WriteStatus(s *Status) error {
+// isRunning returns whether or not the Listen routine has been started.
+func (n *NetStream) isRunning() bool {
+	n.rmu.Lock()
+	defer n.rmu.Unlock()
+
+	return n.running
+}
+
+// WriteStatus writes the status out to the chunk stream, over the message
+// stream with the given ID, returning any error that it encountered during the
+// marhsaling stage, or the network stage. If neither of those processes failed,
+// then the Status was written successfully and a value of "nil" will be
+// returned. The Status is encoded according to the negotiated object encoding
+// (see SetObjectEncoding).
+func (n *NetStream) WriteStatus(streamId uint32, s *Status) error {
 	c, err := s.AsChunk()
 	if err != nil {
 		return err
 	}
+	c.Header.MessageHeader.StreamId = streamId
+
+	switch s.Code() {
+	case PlayStartCode:
+		err = n.WriteStreamBegin(streamId)
+	case PlayStopCode:
+		err = n.WriteStreamEOF(streamId)
+	}
+	if err != nil {
+		return err
+	}
+
+	return n.write(c)
+}
+
+// WriteStreamBegin writes the StreamBegin user control event for the message
+// stream with the given ID, notifying the client that the stream has become
+// functional. Some players will not render a stream until it is received.
+//
+// It is written automatically before a "NetStream.Play.Start" status (see
+// WriteStatus), so it need only be called when beginning a stream otherwise.
+func (n *NetStream) WriteStreamBegin(streamId uint32) error {
+	return n.writeControl(&control.StreamBeginEvent{StreamId: streamId})
+}
+
+// WriteStreamEOF writes the StreamEOF user control event for the message stream
+// with the given ID, notifying the client that playback of it is over.
+//
+// It is written automatically before a "NetStream.Play.Stop" status (see
+// WriteStatus), so it need only be called when ending a stream otherwise.
+func (n *NetStream) WriteStreamEOF(streamId uint32) error {
+	return n.writeControl(&control.StreamEOFEvent{StreamId: streamId})
+}
+
+// writeControl chunks and writes the given control sequence, which is not a
+// command, and so is not subject to the negotiated object encoding.
+func (n *NetStream) writeControl(ctrl control.Control) error {
+	c, err := control.NewChunker().Chunk(ctrl)
+	if err != nil {
+		return err
+	}
 
 	return n.writer.Write(c)
 }
 
+// WritePublishStart writes a "NetStream.Publish.Start" status (see
+// NewPublishStartStatus) for the stream with the given name, over the message
+// stream with the given ID, returning any error encountered while doing so.
+func (n *NetStream) WritePublishStart(streamId uint32, name string) error {
+	return n.WriteStatus(streamId, NewPublishStartStatus(name))
+}
+
+// ObjectEncoding returns the object encoding negotiated by the client.
+func (n *NetStream) ObjectEncoding() ObjectEncoding {
+	n.emu.Lock()
+	defer n.emu.Unlock()
+
+	return n.encoding
+}
+
+// SetObjectEncoding sets the object encoding negotiated by the client, which
+// determines how all subsequently written commands are encoded (see
+// ObjectEncoding.Encode). It is set automatically by the Listen routine upon
+// receiving a connect command, but may be set explicitly when the connect
+// command is handled elsewhere.
+func (n *NetStream) SetObjectEncoding(e ObjectEncoding) {
+	n.emu.Lock()
+	defer n.emu.Unlock()
+
+	n.encoding = e
+}
+
+// SetParser sets the Parser used to parse the commands received by this
+// NetStream, which is DefaultParser unless set otherwise (for instance, to a
+// strict parser, see SimpleParser.SetStrict). This method is _not_ safe to use
+// once the NetStream is listening, and should be used with caution.
+func (n *NetStream) SetParser(p Parser) { n.parser = p }
+
+// write writes the given command chunk, encoded according to the negotiated
+// object encoding.
+func (n *NetStream) write(c *chunk.Chunk) error {
+	return n.writer.Write(n.ObjectEncoding().Encode(c))
+}
+
+// SetLogger sets the Logger that this NetStream reports internal events to,
+// such as commands which could not be parsed or handled, the object encoding
+// negotiated with the peer, and rejected connections and streams. Passing nil
+// restores the default, which is to log nothing.
+func (n *NetStream) SetLogger(l logging.Logger) {
+	n.lmu.Lock()
+	defer n.lmu.Unlock()
+
+	n.log = logging.OrNoop(l)
+}
+
+// logger returns the Logger set by SetLogger.
+func (n *NetStream) logger() logging.Logger {
+	n.lmu.Lock()
+	defer n.lmu.Unlock()
+
+	return n.log
+}
+
+// SetContext sets the context of the connection that this NetStream belongs to,
+// carrying request-scoped values (such as the identity of an authenticated
+// client) to the handlers of its commands. If the context is done, Listen
+// stops, as if Close had been called. If it carries a Tracer (see
+// tracing.NewContext), the time between the connect command and the stream
+// being published or played is recorded with it. It must be set before Listen
+// is started, and defaults to context.Background().
+func (n *NetStream) SetContext(ctx context.Context) {
+	n.xmu.Lock()
+	defer n.xmu.Unlock()
+
+	n.ctx = ctx
+}
+
+// Context returns the context set by SetContext.
+func (n *NetStream) Context() context.Context {
+	n.xmu.Lock()
+	defer n.xmu.Unlock()
+
+	return n.ctx
+}
+
+// SetAuthenticator sets the Authenticator consulted by Publish and Play. If it
+// is nil (as it is by default), all streams are authorized.
+func (n *NetStream) SetAuthenticator(a Authenticator) {
+	n.amu.Lock()
+	defer n.amu.Unlock()
+
+	n.auth = a
+}
+
+// authenticator returns the Authenticator set by SetAuthenticator.
+func (n *NetStream) authenticator() Authenticator {
+	n.amu.Lock()
+	defer n.amu.Unlock()
+
+	return n.auth
+}
+
+// Publish handles the given publish command. If the client is authorized to
+// publish the stream (see SetAuthenticator), the "NetStream.Publish.Start"
+// status is written (see WritePublishStart). Statuses are written over the
+// message stream that the command was received over (see
+// CommandPublish.StreamId).
+//
+// Otherwise, the client is sent the "NetStream.Publish.BadName" status if the
+// Authenticator returned ErrBadName, or the "NetStream.Publish.Rejected" status,
+// and the Authenticator's error is returned. The caller is then expected to
+// close the stream (for instance, with Demux.CloseStream from the cmd package).
+func (n *NetStream) Publish(c *CommandPublish) error {
+	if auth := n.authenticator(); auth != nil {
+		if err := auth.Authorize(*c); err != nil {
+			st := NewPublishRejectedStatus(err)
+			if errors.Is(err, ErrBadName) {
+				st = NewPublishBadNameStatus(c.Name)
+			}
+
+			return n.reject(c.StreamId, st, err)
+		}
+	}
+
+	return n.WritePublishStart(c.StreamId, c.Name)
+}
+
+// Play handles the given play command, as Publish does. If the client is
+// authorized to play the stream, the "NetStream.Play.Start" status is written.
+// Otherwise, it is sent the "NetStream.Play.StreamNotFound" status if the
+// Authenticator returned ErrBadName, or the "NetStream.Play.Failed" status,
+// and the Authenticator's error is returned.
+func (n *NetStream) Play(c *CommandPlay) error {
+	if auth := n.authenticator(); auth != nil {
+		if err := auth.AuthorizePlay(*c); err != nil {
+			st := NewPlayFailedStatus(err)
+			if errors.Is(err, ErrBadName) {
+				st = NewPlayStreamNotFoundStatus(c.PlayPath)
+			}
+
+			return n.reject(c.StreamId, st, err)
+		}
+	}
+
+	return n.WriteStatus(c.StreamId, NewPlayStartStatus(c.PlayPath))
+}
+
+// Connect handles the given connect command. If the Authenticator (see
+// SetAuthenticator) implements ConnectAuthenticator, and does not authorize the
+// client to connect, the client is sent an _error response with the
+// "NetConnection.Connect.Rejected" code (see RejectConnect), and the
+// Authenticator's error is returned. The caller is then expected to close the
+// connection, rather than leave the client waiting for a response.
+//
+// Otherwise, the client is sent the _result accepting the connection, echoing
+// its object encoding (see AcceptConnect).
+func (n *NetStream) Connect(c *CommandConnect) error {
+	auth, ok := n.authenticator().(ConnectAuthenticator)
+	if !ok {
+		return n.AcceptConnect(c.TransactionId, c.ObjectEncoding())
+	}
+
+	if err := auth.AuthorizeConnect(*c); err != nil {
+		n.logger().Printf("cmd/stream: rejected connection: %v", err)
+
+		if werr := n.RejectConnect(c.TransactionId, ConnectRejectedCode,
+			err.Error()); werr != nil {
+
+			return werr
+		}
+
+		return err
+	}
+
+	return n.AcceptConnect(c.TransactionId, c.ObjectEncoding())
+}
+
+// AcceptConnect responds to the connect command with the given transaction ID
+// with a _result, accepting the connection. The result carries a properties
+// object describing the server (see DefaultFMSVersion and DefaultCapabilities),
+// followed by an info object with the "NetConnection.Connect.Success" code,
+// echoing the given object encoding negotiated by the client (see
+// CommandConnect.ObjectEncoding). Many clients do not proceed without a
+// response of precisely this shape.
+func (n *NetStream) AcceptConnect(txnID float64, encoding float64) error {
+	info := connectStatus("status", ConnectSuccessCode,
+		"Connection succeeded.")
+	info.ObjectEncoding = &encoding
+
+	return n.Invoke(ResultName, txnID, &connectProperties{
+		FMSVer:       DefaultFMSVersion,
+		Capabilities: DefaultCapabilities,
+	}, info)
+}
+
+// RejectConnect responds to the connect command with the given transaction ID
+// with an _error, carrying an info object with the given code (typically
+// ConnectRejectedCode) and description. Clients treat the connection as failed
+// once they receive it, so the caller should close the connection afterwards.
+func (n *NetStream) RejectConnect(txnID float64, code, description string) error {
+	return n.Invoke(ErrorName, txnID, nil,
+		connectStatus("error", code, description))
+}
+
+// ReleaseStream handles the given releaseStream command, by responding with a
+// _result carrying its transaction ID. Nothing is released: a stream held by a
+// previous publisher is closed by the caller, for instance when the new
+// publisher is authorized (see SetAuthenticator).
+func (n *NetStream) ReleaseStream(c *CommandReleaseStream) error {
+	return n.Invoke(ResultName, c.TransactionId, nil, nil)
+}
+
+// RespondStreamLength responds to the getStreamLength command with the given
+// transaction ID (see CommandGetStreamLength) with a _result carrying the
+// length of the stream, in seconds. Players which are not sent a length are
+// unable to seek within the stream.
+func (n *NetStream) RespondStreamLength(txnID float64, seconds float64) error {
+	return n.Invoke(ResultName, txnID, nil, seconds)
+}
+
+// FCPublish handles the given FCPublish command, by calling onFCPublish on the
+// client with a "NetStream.Publish.Start" info object for the named stream.
+// Encoders which send FCPublish may wait for this response before publishing.
+func (n *NetStream) FCPublish(c *CommandFCPublish) error {
+	return n.Invoke(OnFCPublishName, 0, nil, fcStatus(PublishStartCode, c.Name))
+}
+
+// FCUnpublish handles the given FCUnpublish command, by calling onFCUnpublish
+// on the client with a "NetStream.Unpublish.Success" info object for the named
+// stream.
+func (n *NetStream) FCUnpublish(c *CommandFCUnpublish) error {
+	return n.Invoke(OnFCUnpublishName, 0, nil,
+		fcStatus(UnpublishSuccessCode, c.Name))
+}
+
+// SetAutoFCPublish sets whether or not Listen responds to the releaseStream,
+// FCPublish, and FCUnpublish commands by itself (see ReleaseStream, FCPublish,
+// and FCUnpublish), before delivering them over In(). It is disabled by
+// default, since newer encoders do not send these commands, but older ones
+// stall without a response.
+func (n *NetStream) SetAutoFCPublish(auto bool) {
+	n.fmu.Lock()
+	defer n.fmu.Unlock()
+
+	n.autoFCPublish = auto
+}
+
+// AutoFCPublish returns whether or not Listen responds to the releaseStream,
+// FCPublish, and FCUnpublish commands by itself (see SetAutoFCPublish).
+func (n *NetStream) AutoFCPublish() bool {
+	n.fmu.Lock()
+	defer n.fmu.Unlock()
+
+	return n.autoFCPublish
+}
+
+// respondPrePublish responds to the given releaseStream, FCPublish, or
+// FCUnpublish command, if SetAutoFCPublish is enabled, reporting any error
+// encountered while doing so.
+func (n *NetStream) respondPrePublish(cmd Command) {
+	if !n.AutoFCPublish() {
+		return
+	}
+
+	var err error
+	switch c := cmd.(type) {
+	case *CommandReleaseStream:
+		err = n.ReleaseStream(c)
+	case *CommandFCPublish:
+		err = n.FCPublish(c)
+	case *CommandFCUnpublish:
+		err = n.FCUnpublish(c)
+	default:
+		return
+	}
+
+	if err != nil {
+		n.logger().Printf("cmd/stream: %v", err)
+		n.send(err)
+	}
+}
+
+// send passes the given error along over the Errs() channel, returning whether
+// or not it was received before the NetStream was closed, or its context done.
+func (n *NetStream) send(err error) bool {
+	select {
+	case n.errs <- err:
+		return true
+	case <-n.closer:
+		return false
+	case <-n.Context().Done():
+		return false
+	}
+}
+
+// reject writes the given status over the message stream with the given ID,
+// sent when a stream was not authorized for the reason given by err, which is
+// returned unless the status could not be written.
+func (n *NetStream) reject(streamId uint32, st *Status, err error) error {
+	n.logger().Printf("cmd/stream: rejected stream: %v", err)
+
+	if werr := n.WriteStatus(streamId, st); werr != nil {
+		return werr
+	}
+
+	return err
+}
+
+// Invoke calls the named method on the client, by writing a command with the
+// given transaction ID and arguments (see type Invoke), encoded according to
+// the negotiated object encoding (see SetObjectEncoding). It returns any error
+// encountered while marshaling or writing the command.
+func (n *NetStream) Invoke(name string, txnID float64, args ...interface{}) error {
+	c, err := (&Invoke{
+		Name:          name,
+		TransactionId: txnID,
+		Arguments:     args,
+	}).AsChunk()
+	if err != nil {
+		return err
+	}
+
+	return n.write(c)
+}
+
+// Call calls the named method on the client with the given arguments (see
+// Invoke), using a newly allocated transaction ID. It returns a channel on
+// which the client's response, either a *CommandResult or a *CommandError, is
+// delivered once received by the Listen routine, after which the channel is
+// closed. If the Listen routine exits before a response is received, the
+// channel is closed without delivering one.
+//
+// If the call could not be written, an error is returned instead.
+func (n *NetStream) Call(name string, args ...interface{}) (<-chan Command, error) {
+	res := make(chan Command, 1)
+
+	n.cmu.Lock()
+	n.txnId++
+	id := n.txnId
+	n.calls[id] = res
+	n.cmu.Unlock()
+
+	if err := n.Invoke(name, id, args...); err != nil {
+		n.cmu.Lock()
+		delete(n.calls, id)
+		n.cmu.Unlock()
+
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// resolve delivers the given command to the caller awaiting it, if it is a
+// response to a call made with Call. It returns whether or not the command was
+// delivered.
+func (n *NetStream) resolve(cmd Command) bool {
+	id, ok := transactionId(cmd)
+	if !ok {
+		return false
+	}
+
+	n.cmu.Lock()
+	res, ok := n.calls[id]
+	delete(n.calls, id)
+	n.cmu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	res <- cmd
+	close(res)
+
+	return true
+}
+
+// StreamIds returns the *StreamIdAllocator used to allocate message stream IDs
+// in response to createStream commands.
+func (n *NetStream) StreamIds() *StreamIdAllocator { return n.ids }
+
+// CreateStream responds to the given createStream command with a _result
+// carrying a newly allocated message stream ID, and returns that ID. If the
+// response could not be written, the ID is released and an error is returned
+// instead.
+func (n *NetStream) CreateStream(c *CommandCreateStream) (uint32, error) {
+	res := c.Result(n.ids)
+
+	ch, err := res.AsChunk()
+	if err == nil {
+		err = n.write(ch)
+	}
+
+	if err != nil {
+		n.ids.Release(uint32(res.StreamId))
+		return 0, err
+	}
+
+	return uint32(res.StreamId), nil
+}
+
 // Listen loops infinitely, managing the incoming and outgoing channel of chunks
 // on the chunk stream shared between the server and client.
 //
 // Listen has three main goals:
 //  - Parse incoming chunks, returning errors when they are unparsable.
+//    Responses to calls made with Call are delivered to their caller, rather
+//    than over the In() channel. Otherwise, the callbacks registered with
+//    Events() are invoked before the command is delivered over In(). Each
+//    chunk is released (see chunk.Chunk.Release) once it has been parsed.
+//    The releaseStream, FCPublish, and FCUnpublish commands are responded
+//    to first, if SetAutoFCPublish is enabled.
 //  - Serialize outgoing `onStatus` commands, returning an error when they are
 //    either unserializable, or unwriteable.
 //  - Respond to the `Close()` operation by closing all output channels.
@@ -96,25 +653,115 @@ func (n *NetStream) WriteStatus(s *Status) error {
 // running are sent over the internal errs channel, accessible from the `Errs()`
 // function.
 func (n *NetStream) Listen() {
+	n.rmu.Lock()
+	n.running = true
+	n.rmu.Unlock()
+
 	defer func() {
 		close(n.in)
 		close(n.errs)
-		close(n.closer)
+
+		n.cmu.Lock()
+		for id, res := range n.calls {
+			close(res)
+			delete(n.calls, id)
+		}
+		n.cmu.Unlock()
+
+		n.events.close()
+
+		close(n.done)
+	}()
+
+	ctx := n.Context()
+
+	// connect is the span covering the time between the connect command
+	// being received, and the client publishing or playing a stream.
+	var connect tracing.Span
+	defer func() {
+		if connect != nil {
+			connect.End(nil)
+		}
 	}()
 
 L:
 	for {
 		select {
-		case chunk := <-n.chunks:
-			cmd, err := n.parser.Parse(bytes.NewReader(chunk.Data))
+		case chunk, ok := <-n.chunks:
+			if !ok {
+				break L
+			}
+
+			data := chunk.Data
+			if chunk.Header != nil && len(data) > 0 &&
+				chunk.Header.MessageHeader.TypeId == AMF3CommandTypeId {
+				data = data[1:]
+			}
+
+			var streamId uint32
+			if chunk.Header != nil {
+				streamId = chunk.Header.MessageHeader.StreamId
+			}
+
+			cmd, err := n.parser.Parse(bytes.NewReader(data))
+			chunk.Release()
 			if err != nil {
-				n.errs <- err
+				n.logger().Printf("cmd/stream: %v", err)
+				if !n.send(err) {
+					break L
+				}
+				continue
+			}
+
+			switch c := cmd.(type) {
+			case *CommandConnect:
+				n.logger().Printf("cmd/stream: negotiated object encoding %d",
+					c.Encoding())
+				n.SetObjectEncoding(c.Encoding())
+
+				if connect == nil {
+					_, connect = tracing.Start(ctx, tracing.SpanConnect)
+					connect.SetAttribute(tracing.AttributeApp, c.App())
+				}
+			case *CommandPublish:
+				c.StreamId = streamId
+				connect = endConnectSpan(connect, c.Name)
+			case *CommandPlay:
+				c.StreamId = streamId
+				connect = endConnectSpan(connect, c.PlayPath)
+			case *CommandReleaseStream, *CommandFCPublish,
+				*CommandFCUnpublish:
+				n.respondPrePublish(c)
+			}
+
+			if n.resolve(cmd) {
 				continue
 			}
 
-			n.in <- cmd
+			n.events.dispatch(cmd)
+
+			select {
+			case n.in <- cmd:
+			case <-n.closer:
+				break L
+			case <-ctx.Done():
+				break L
+			}
 		case <-n.closer:
 			break L
+		case <-ctx.Done():
+			break L
 		}
 	}
 }
+
+// endConnectSpan records the given stream key on the connect span, and ends it,
+// returning nil. If there is no connect span, nil is returned immediately.
+func endConnectSpan(connect tracing.Span, streamKey string) tracing.Span {
+	if connect != nil {
+		connect.SetAttribute(tracing.AttributeStreamKey, streamKey)
+		connect.End(nil)
+	}
+
+	return nil
+}
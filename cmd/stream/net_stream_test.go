@@ -2,10 +2,16 @@ package stream
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/chunk/chunktest"
+	"github.com/WatchBeam/rtmp/tracing"
+	"github.com/WatchBeam/rtmp/tracing/tracingtest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -50,6 +56,68 @@ func TestNetStreamPropogatesChunkParsingErrors(t *testing.T) {
 	assert.Equal(t, "foo", (<-s.Errs()).Error())
 }
 
+func TestNetStreamParsesAMF3CommandMessages(t *testing.T) {
+	chunks := make(chan *chunk.Chunk)
+	s := New(chunks, chunk.NoopWriter)
+
+	go s.Listen()
+	chunks <- &chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{
+				TypeId: AMF3CommandTypeId,
+			},
+		},
+		Data: []byte{
+			// format byte
+			0x00,
+			// "play", 0, null
+			0x02, 0x00, 0x04, 0x70, 0x6c, 0x61, 0x79,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x05,
+			// AMF3 "stream", -2
+			0x11, 0x06, 0x0d, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
+			0x11, 0x04, 0xff, 0xff, 0xff, 0xfe,
+		},
+	}
+
+	cmd := <-s.In()
+
+	assert.Equal(t, &CommandPlay{PlayPath: "stream", Live: -2}, cmd)
+}
+
+func TestNetStreamWritesPublishStart(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := New(make(chan *chunk.Chunk), chunk.NewWriter(buf, chunk.DefaultReadSize))
+
+	err := s.WritePublishStart(OnStatusMessageStreamId, "foo")
+
+	c, _ := NewPublishStartStatus("foo").AsChunk()
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(c)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestNetStreamRespondsToCreateStream(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := New(make(chan *chunk.Chunk), chunk.NewWriter(buf, chunk.DefaultReadSize))
+
+	first, err := s.CreateStream(&CommandCreateStream{TransactionId: 4})
+	assert.Nil(t, err)
+
+	second, err := s.CreateStream(&CommandCreateStream{TransactionId: 5})
+	assert.Nil(t, err)
+
+	res, _ := (&CreateStreamResult{TransactionId: 4, StreamId: 1}).AsChunk()
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(res)
+
+	assert.Equal(t, uint32(1), first)
+	assert.Equal(t, uint32(2), second)
+	assert.Equal(t, expected.Bytes(), buf.Bytes()[:expected.Len()])
+}
+
 func TestStreamSendsOnStatusUpdates(t *testing.T) {
 	buf := new(bytes.Buffer)
 	writer := chunk.NewWriter(buf, chunk.DefaultReadSize)
@@ -59,8 +127,537 @@ func TestStreamSendsOnStatusUpdates(t *testing.T) {
 
 	go s.Listen()
 
-	err := s.WriteStatus(NewStatus())
+	err := s.WriteStatus(OnStatusMessageStreamId,
+		NewPublishStartStatus("foo"))
 
 	assert.Nil(t, err)
 	assert.NotEmpty(t, buf.Bytes())
 }
+
+func TestNetStreamInvokesClientMethods(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := New(make(chan *chunk.Chunk), chunk.NewWriter(buf, chunk.DefaultReadSize))
+
+	err := s.Invoke("onBWDone", 0, nil)
+	assert.Nil(t, err)
+
+	c, _ := (&Invoke{
+		Name:      "onBWDone",
+		Arguments: []interface{}{nil},
+	}).AsChunk()
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(c)
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestNetStreamRespondsToFCPublish(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := New(make(chan *chunk.Chunk), chunk.NewWriter(buf, chunk.DefaultReadSize))
+
+	err := s.FCPublish(&CommandFCPublish{TransactionId: 3, Name: "key"})
+	assert.Nil(t, err)
+
+	c, _ := (&Invoke{
+		Name: OnFCPublishName,
+		Arguments: []interface{}{nil, map[string]interface{}{
+			"code":        PublishStartCode,
+			"description": "key",
+		}},
+	}).AsChunk()
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(c)
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestNetStreamRespondsToReleaseStream(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := New(make(chan *chunk.Chunk), chunk.NewWriter(buf, chunk.DefaultReadSize))
+
+	err := s.ReleaseStream(&CommandReleaseStream{TransactionId: 2, Name: "key"})
+	assert.Nil(t, err)
+
+	c, _ := (&Invoke{
+		Name:          ResultName,
+		TransactionId: 2,
+		Arguments:     []interface{}{nil, nil},
+	}).AsChunk()
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(c)
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestNetStreamRespondsWithTheStreamLength(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := New(make(chan *chunk.Chunk), chunk.NewWriter(buf, chunk.DefaultReadSize))
+
+	assert.Nil(t, s.RespondStreamLength(3, 120.5))
+
+	c, _ := (&Invoke{
+		Name:          ResultName,
+		TransactionId: 3,
+		Arguments:     []interface{}{nil, 120.5},
+	}).AsChunk()
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(c)
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestNetStreamRejectsConnections(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := New(make(chan *chunk.Chunk), chunk.NewWriter(buf, chunk.DefaultReadSize))
+
+	assert.Nil(t, s.RejectConnect(1, ConnectRejectedCode, "invalid token"))
+
+	c, _ := (&Invoke{
+		Name:          ErrorName,
+		TransactionId: 1,
+		Arguments: []interface{}{nil, connectStatus("error",
+			ConnectRejectedCode, "invalid token")},
+	}).AsChunk()
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(c)
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+	// The command is named "_error", and carries transaction ID 1.
+	assert.Equal(t, []byte{
+		0x02, 0x00, 0x06, '_', 'e', 'r', 'r', 'o', 'r',
+		0x00, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05,
+	}, c.Data[:19])
+}
+
+// ConnectSuccessResult is the _result sent by Flash Media Server in response
+// to a connect command with transaction ID 1, negotiating AMF0 object encoding.
+var ConnectSuccessResult = []byte{
+	0x02, 0x00, 0x07, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x00,
+	0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x06,
+	0x66, 0x6d, 0x73, 0x56, 0x65, 0x72, 0x02, 0x00, 0x0d, 0x46, 0x4d,
+	0x53, 0x2f, 0x33, 0x2c, 0x30, 0x2c, 0x31, 0x2c, 0x31, 0x32, 0x33,
+	0x00, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74,
+	0x69, 0x65, 0x73, 0x00, 0x40, 0x3f, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x09, 0x03, 0x00, 0x05, 0x6c, 0x65, 0x76, 0x65,
+	0x6c, 0x02, 0x00, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x00,
+	0x04, 0x63, 0x6f, 0x64, 0x65, 0x02, 0x00, 0x1d, 0x4e, 0x65, 0x74,
+	0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x2e,
+	0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x2e, 0x53, 0x75, 0x63,
+	0x63, 0x65, 0x73, 0x73, 0x00, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72,
+	0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x02, 0x00, 0x15, 0x43, 0x6f,
+	0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x20, 0x73, 0x75,
+	0x63, 0x63, 0x65, 0x65, 0x64, 0x65, 0x64, 0x2e, 0x00, 0x0e, 0x6f,
+	0x62, 0x6a, 0x65, 0x63, 0x74, 0x45, 0x6e, 0x63, 0x6f, 0x64, 0x69,
+	0x6e, 0x67, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x09,
+}
+
+func TestNetStreamAcceptsConnections(t *testing.T) {
+	chunks := make(chan *chunk.Chunk, 1)
+	s := New(make(chan *chunk.Chunk), &chanWriter{chunk.NoopWriter, chunks})
+
+	assert.Nil(t, s.AcceptConnect(1, 0))
+
+	c := <-chunks
+	assert.Equal(t, InvokeChunkStreamId, c.Header.BasicHeader.StreamId)
+	assert.Equal(t, Amf0CmdTypeId, c.Header.MessageHeader.TypeId)
+	assert.Equal(t, ConnectSuccessResult, c.Data)
+}
+
+func TestNetStreamAcceptsConnectionsEchoingTheObjectEncoding(t *testing.T) {
+	chunks := make(chan *chunk.Chunk, 1)
+	s := New(make(chan *chunk.Chunk), &chanWriter{chunk.NoopWriter, chunks})
+
+	assert.Nil(t, s.AcceptConnect(1, 3))
+
+	data := (<-chunks).Data
+	// The object encoding is the last number of the info object.
+	assert.Equal(t, []byte{0x00, 0x40, 0x08, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x09}, data[len(data)-12:])
+}
+
+func fcChunk(name string, id float64) *chunk.Chunk {
+	c, _ := (&Invoke{
+		Name:          name,
+		TransactionId: id,
+		Arguments:     []interface{}{nil, "key"},
+	}).AsChunk()
+
+	return c
+}
+
+func TestNetStreamDoesNotAutoRespondToFCPublishByDefault(t *testing.T) {
+	buf := new(bytes.Buffer)
+	chunks := make(chan *chunk.Chunk)
+	s := New(chunks, chunk.NewWriter(buf, chunk.DefaultReadSize))
+
+	go s.Listen()
+	defer s.Close()
+
+	chunks <- fcChunk("FCPublish", 3)
+
+	assert.Equal(t, &CommandFCPublish{TransactionId: 3, Name: "key"}, <-s.In())
+	assert.False(t, s.AutoFCPublish())
+	assert.Empty(t, buf.Bytes())
+}
+
+func TestNetStreamAutoRespondsToPrePublishCommandsWhenEnabled(t *testing.T) {
+	buf := new(bytes.Buffer)
+	chunks := make(chan *chunk.Chunk)
+	s := New(chunks, chunk.NewWriter(buf, chunk.DefaultReadSize))
+	s.SetAutoFCPublish(true)
+
+	go s.Listen()
+	defer s.Close()
+
+	chunks <- fcChunk("releaseStream", 2)
+	assert.IsType(t, new(CommandReleaseStream), <-s.In())
+
+	chunks <- fcChunk("FCPublish", 3)
+	assert.IsType(t, new(CommandFCPublish), <-s.In())
+
+	chunks <- fcChunk("FCUnpublish", 4)
+	assert.IsType(t, new(CommandFCUnpublish), <-s.In())
+
+	expected := new(bytes.Buffer)
+	w := chunk.NewWriter(expected, chunk.DefaultReadSize)
+
+	c, _ := (&Invoke{
+		Name:          ResultName,
+		TransactionId: 2,
+		Arguments:     []interface{}{nil, nil},
+	}).AsChunk()
+	w.Write(c)
+
+	for _, res := range []struct{ name, code string }{
+		{OnFCPublishName, PublishStartCode},
+		{OnFCUnpublishName, UnpublishSuccessCode},
+	} {
+		c, _ := (&Invoke{
+			Name: res.name,
+			Arguments: []interface{}{nil, map[string]interface{}{
+				"code":        res.code,
+				"description": "key",
+			}},
+		}).AsChunk()
+		w.Write(c)
+	}
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestNetStreamResolvesCallsWithTheirResponses(t *testing.T) {
+	buf := new(bytes.Buffer)
+	chunks := make(chan *chunk.Chunk)
+	s := New(chunks, chunk.NewWriter(buf, chunk.DefaultReadSize))
+
+	go s.Listen()
+	defer s.Close()
+
+	first, err := s.Call("checkBandwidth", nil)
+	assert.Nil(t, err)
+	second, err := s.Call("checkBandwidth", nil)
+	assert.Nil(t, err)
+
+	c, _ := (&Invoke{
+		Name:          "_error",
+		TransactionId: 2,
+		Arguments:     []interface{}{nil},
+	}).AsChunk()
+	chunks <- c
+
+	assert.Equal(t, &CommandError{TransactionId: 2}, <-second)
+	_, ok := <-second
+	assert.False(t, ok)
+
+	c, _ = (&Invoke{
+		Name:          "_result",
+		TransactionId: 1,
+		Arguments:     []interface{}{nil},
+	}).AsChunk()
+	chunks <- c
+
+	assert.Equal(t, &CommandResult{TransactionId: 1}, <-first)
+}
+
+func TestNetStreamDeliversUnmatchedResponsesOnIn(t *testing.T) {
+	chunks := make(chan *chunk.Chunk)
+	s := New(chunks, chunk.NoopWriter)
+
+	go s.Listen()
+	defer s.Close()
+
+	c, _ := (&Invoke{
+		Name:          "_result",
+		TransactionId: 7,
+		Arguments:     []interface{}{nil},
+	}).AsChunk()
+	chunks <- c
+
+	assert.Equal(t, &CommandResult{TransactionId: 7}, <-s.In())
+}
+
+func TestNetStreamClosesPendingCallsWhenClosed(t *testing.T) {
+	s := New(make(chan *chunk.Chunk), chunk.NoopWriter)
+	go s.Listen()
+
+	res, err := s.Call("onBWDone", nil)
+	assert.Nil(t, err)
+
+	s.Close()
+
+	_, ok := <-res
+	assert.False(t, ok)
+}
+
+func TestNetStreamRecordsTheStreamOfPublishAndPlayCommands(t *testing.T) {
+	chunks := make(chan *chunk.Chunk)
+	s := New(chunks, chunk.NoopWriter)
+
+	go s.Listen()
+	defer s.Close()
+
+	receive := func(data []byte) Command {
+		chunks <- &chunk.Chunk{
+			Header: &chunk.Header{
+				MessageHeader: chunk.MessageHeader{
+					TypeId:   Amf0CmdTypeId,
+					StreamId: 3,
+				},
+			},
+			Data: data,
+		}
+
+		return <-s.In()
+	}
+
+	publish := receive(invokeData("publish", 0, nil, "foo", "live"))
+	play := receive(invokeData("play", 0, nil, "foo", -2))
+
+	assert.Equal(t, uint32(3), publish.(*CommandPublish).StreamId)
+	assert.Equal(t, uint32(3), play.(*CommandPlay).StreamId)
+}
+
+func TestNetStreamRespondsInAMF3WhenNegotiated(t *testing.T) {
+	chunks := make(chan *chunk.Chunk)
+	w := &chanWriter{chunk.NoopWriter, make(chan *chunk.Chunk, 1)}
+	s := New(chunks, w)
+
+	go s.Listen()
+	defer s.Close()
+
+	chunks <- &chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{
+				TypeId: AMF3CommandTypeId,
+			},
+		},
+		Data: append([]byte{0x00}, invokeData("connect", 1,
+			map[string]interface{}{"objectEncoding": 3})...),
+	}
+	<-s.In()
+
+	assert.Equal(t, ObjectEncodingAMF3, s.ObjectEncoding())
+
+	assert.Nil(t, s.WriteStatus(OnStatusMessageStreamId,
+		NewPublishStartStatus("foo")))
+	c := <-w.chunks
+
+	status, _ := NewPublishStartStatus("foo").AsChunk()
+	assert.Equal(t, AMF3CommandTypeId, c.Header.MessageHeader.TypeId)
+	assert.Equal(t, uint32(len(status.Data)+1), c.Header.MessageHeader.Length)
+	assert.Equal(t, append([]byte{0x00}, status.Data...), c.Data)
+
+	assert.Nil(t, s.Invoke("onBWDone", 0, nil))
+	c = <-w.chunks
+
+	assert.Equal(t, AMF3CommandTypeId, c.Header.MessageHeader.TypeId)
+	assert.Equal(t, append([]byte{0x00}, invokeData("onBWDone", 0, nil)...),
+		c.Data)
+}
+
+func TestNetStreamWritesTimeOutWhenThePeerStopsReading(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	w := chunk.NewWriter(conn, chunk.DefaultReadSize).(*chunk.DefaultWriter)
+	w.SetWriteTimeout(conn, 10*time.Millisecond)
+
+	s := New(make(chan *chunk.Chunk), w)
+
+	assert.Equal(t, chunk.ErrWriteTimeout, s.WritePublishStart(OnStatusMessageStreamId, "foo"))
+}
+
+func TestNetStreamCloseIsIdempotent(t *testing.T) {
+	s := New(make(chan *chunk.Chunk), chunk.NoopWriter)
+	go s.Listen()
+
+	s.Close()
+	s.Close()
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestNetStreamStopsWhenItsChunksAreClosed(t *testing.T) {
+	chunks := make(chan *chunk.Chunk)
+	s := New(chunks, chunk.NoopWriter)
+	go s.Listen()
+
+	close(chunks)
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestNetStreamCloseBeforeListenDoesNotBlock(t *testing.T) {
+	s := New(make(chan *chunk.Chunk), chunk.NoopWriter)
+
+	s.Close()
+	go s.Listen()
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestNetStreamCloseContextWaitsForListenToStop(t *testing.T) {
+	s := New(make(chan *chunk.Chunk), chunk.NoopWriter)
+	go s.Listen()
+
+	assert.Nil(t, s.CloseContext(context.Background()))
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestNetStreamCloseContextTimesOutWithoutListen(t *testing.T) {
+	s := New(make(chan *chunk.Chunk), chunk.NoopWriter)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, s.CloseContext(ctx))
+}
+
+func TestNetStreamListenStopsWhenTheContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := New(make(chan *chunk.Chunk), chunk.NoopWriter)
+	s.SetContext(ctx)
+	go s.Listen()
+
+	cancel()
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestNetStreamRecordsTheConnectSpan(t *testing.T) {
+	parser := &MockParser{}
+	parser.On("Parse", mock.Anything).Return(&CommandConnect{
+		Parameters: map[string]interface{}{"app": "live"},
+	}, nil).Once()
+	parser.On("Parse", mock.Anything).
+		Return(&CommandPublish{Name: "foo"}, nil).Once()
+
+	r := tracingtest.NewRecorder()
+
+	chunks := make(chan *chunk.Chunk)
+	s := New(chunks, chunk.NoopWriter)
+	s.parser = parser
+	s.SetContext(tracing.NewContext(context.Background(), r))
+
+	go s.Listen()
+	defer s.Close()
+
+	chunks <- new(chunk.Chunk)
+	<-s.In()
+	chunks <- new(chunk.Chunk)
+	<-s.In()
+
+	span := r.Wait(tracing.SpanConnect, time.Second)
+	if assert.NotNil(t, span) {
+		app, _ := span.Attribute(tracing.AttributeApp)
+		key, _ := span.Attribute(tracing.AttributeStreamKey)
+
+		assert.Equal(t, "live", app)
+		assert.Equal(t, "foo", key)
+	}
+}
+
+func TestNetStreamWritesStreamBeginBeforePlayStart(t *testing.T) {
+	w := chunktest.NewRecordingWriter()
+	s := New(make(chan *chunk.Chunk), w)
+
+	assert.Nil(t, s.WriteStatus(2, NewPlayStartStatus("foo")))
+
+	chunks := w.Chunks()
+	if assert.Len(t, chunks, 2) {
+		assert.Equal(t, byte(0x04), chunks[0].Header.MessageHeader.TypeId)
+		assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x02},
+			chunks[0].Data)
+		assert.Equal(t, written(NewPlayStartStatus("foo")), chunks[1].Data)
+		assert.Equal(t, uint32(2), chunks[1].Header.MessageHeader.StreamId)
+	}
+}
+
+func TestNetStreamWritesStreamEOFBeforePlayStop(t *testing.T) {
+	w := chunktest.NewRecordingWriter()
+	s := New(make(chan *chunk.Chunk), w)
+
+	assert.Nil(t, s.WriteStatus(2, NewPlayStopStatus("foo")))
+
+	chunks := w.Chunks()
+	if assert.Len(t, chunks, 2) {
+		assert.Equal(t, byte(0x04), chunks[0].Header.MessageHeader.TypeId)
+		assert.Equal(t, []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x02},
+			chunks[0].Data)
+		assert.Equal(t, written(NewPlayStopStatus("foo")), chunks[1].Data)
+		assert.Equal(t, uint32(2), chunks[1].Header.MessageHeader.StreamId)
+	}
+}
+
+func TestNetStreamDoesNotEncodeStreamBeginAsAMF3(t *testing.T) {
+	w := chunktest.NewRecordingWriter()
+	s := New(make(chan *chunk.Chunk), w)
+	s.SetObjectEncoding(ObjectEncodingAMF3)
+
+	assert.Nil(t, s.WriteStreamBegin(2))
+
+	chunks := w.Chunks()
+	if assert.Len(t, chunks, 1) {
+		assert.Equal(t, byte(0x04), chunks[0].Header.MessageHeader.TypeId)
+		assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x02},
+			chunks[0].Data)
+	}
+}
+
+func TestNetStreamDeliversUnknownCommandsOnIn(t *testing.T) {
+	chunks := make(chan *chunk.Chunk)
+	s := New(chunks, chunk.NoopWriter)
+
+	go s.Listen()
+	defer s.Close()
+
+	c, _ := (&Invoke{
+		Name:          "vendorRefreshToken",
+		TransactionId: 4,
+		Arguments: []interface{}{
+			map[string]interface{}{"vendor": "acme"},
+			"token",
+			float64(3600),
+		},
+	}).AsChunk()
+	chunks <- c
+
+	cmd, ok := (<-s.In()).(*UnknownCommand)
+	if !ok {
+		t.Fatal("cmd/stream: expected an *UnknownCommand")
+	}
+
+	assert.Equal(t, "vendorRefreshToken", cmd.Name)
+	assert.Equal(t, float64(4), cmd.TransactionId)
+	assert.Equal(t, map[string]interface{}{"vendor": "acme"}, cmd.Properties)
+	assert.Equal(t, []interface{}{"token", float64(3600)}, cmd.Arguments)
+}
@@ -0,0 +1,38 @@
+package stream
+
+import "github.com/WatchBeam/rtmp/chunk"
+
+// ObjectEncoding is the AMF version that a client negotiates in its connect
+// command (see CommandConnect.Encoding), which determines how the commands sent
+// to it must be encoded.
+type ObjectEncoding uint8
+
+const (
+	// ObjectEncodingAMF0 is the default object encoding, used by clients
+	// that send commands in AMF0 command messages (0x14).
+	ObjectEncodingAMF0 ObjectEncoding = 0
+	// ObjectEncodingAMF3 is the object encoding used by clients that send
+	// commands in AMF3 command messages (see AMF3CommandTypeId).
+	ObjectEncodingAMF3 ObjectEncoding = 3
+)
+
+// Encode returns the command held in the given chunk, encoded for a client
+// that negotiated this object encoding. Commands sent to AMF3 clients are sent
+// as AMF3 command messages, prefixed by the format byte. Since AMF0 values may
+// be embedded in AMF3 command messages, the payload itself is left as-is.
+//
+// The given chunk is not modified.
+func (e ObjectEncoding) Encode(c *chunk.Chunk) *chunk.Chunk {
+	if e != ObjectEncodingAMF3 || c.Header == nil {
+		return c
+	}
+
+	header := *c.Header
+	header.MessageHeader.TypeId = AMF3CommandTypeId
+	header.MessageHeader.Length = uint32(len(c.Data) + 1)
+
+	return &chunk.Chunk{
+		Header: &header,
+		Data:   append([]byte{0x00}, c.Data...),
+	}
+}
@@ -0,0 +1,45 @@
+package stream_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAMF0EncodingLeavesChunksAsIs(t *testing.T) {
+	c, _ := stream.NewPublishStartStatus("foo").AsChunk()
+
+	assert.Equal(t, c, stream.ObjectEncodingAMF0.Encode(c))
+}
+
+func TestAMF3EncodingSendsAMF3CommandMessages(t *testing.T) {
+	c := &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader: chunk.BasicHeader{StreamId: 3},
+			MessageHeader: chunk.MessageHeader{
+				Length:   2,
+				TypeId:   stream.Amf0CmdTypeId,
+				StreamId: 1,
+			},
+		},
+		Data: []byte{0x05, 0x05},
+	}
+
+	encoded := stream.ObjectEncodingAMF3.Encode(c)
+
+	assert.Equal(t, &chunk.Chunk{
+		Header: &chunk.Header{
+			BasicHeader: chunk.BasicHeader{StreamId: 3},
+			MessageHeader: chunk.MessageHeader{
+				Length:   3,
+				TypeId:   stream.AMF3CommandTypeId,
+				StreamId: 1,
+			},
+		},
+		Data: []byte{0x00, 0x05, 0x05},
+	}, encoded)
+	assert.Equal(t, stream.Amf0CmdTypeId, c.Header.MessageHeader.TypeId)
+	assert.Equal(t, []byte{0x05, 0x05}, c.Data)
+}
@@ -1,9 +1,15 @@
 package stream
 
 import (
-	"github.com/WatchBeam/amf0"
-	"github.com/WatchBeam/amf0/encoding"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	amf "github.com/WatchBeam/rtmp/amf0"
 	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/spec"
 )
 
 const (
@@ -20,47 +26,277 @@ const (
 	// Amf0CmdTypeId is the message type ID used to send the OnStatus
 	// command in the chunk.
 	Amf0CmdTypeId byte = 0x14
+
+	// PublishStartCode is the code of the Status sent once a client has
+	// started publishing a stream (see NewPublishStartStatus). It is also
+	// the code of the info object sent by onFCPublish.
+	PublishStartCode string = "NetStream.Publish.Start"
+	// UnpublishSuccessCode is the code of the info object sent by
+	// onFCUnpublish once a client has stopped publishing a stream.
+	UnpublishSuccessCode string = "NetStream.Unpublish.Success"
+
+	// PlayStartCode is the code of the Status sent once a client has
+	// started playing a stream (see NewPlayStartStatus).
+	PlayStartCode string = "NetStream.Play.Start"
+	// PlayStopCode is the code of the Status sent once a client has
+	// stopped playing a stream (see NewPlayStopStatus).
+	PlayStopCode string = "NetStream.Play.Stop"
+
+	// ConnectSuccessCode is the code of the info object sent with the
+	// _result response to a connect command which was accepted (see
+	// NetStream.AcceptConnect).
+	ConnectSuccessCode string = "NetConnection.Connect.Success"
+	// ConnectRejectedCode is the code of the info object sent with the
+	// _error response to a connect command which was not authorized (see
+	// NetStream.RejectConnect).
+	ConnectRejectedCode string = "NetConnection.Connect.Rejected"
 )
 
 var (
 	// OnStatusCommandHeader is a []byte containing a marshalled version of
 	// the CommandHeader attached to all outgoing onStatus commands.
-	OnStatusCommandHeader, _ = encoding.Marshal(&CommandHeader{
-		Name: OnStatusName,
-	})
+	OnStatusCommandHeader, _ = marshalArguments(OnStatusName, float64(0), nil)
+)
+
+var (
+	// ReservedStatusProperties are the properties of the info object that
+	// must be set through the Arguments of a Status, and may not be given
+	// as additional Properties.
+	ReservedStatusProperties = []string{"level", "code", "description"}
+
+	// ErrStatusPropertyTooLong is returned when marshalling a Status with a
+	// property whose key is too long to be encoded in AMF0.
+	ErrStatusPropertyTooLong = errors.New(
+		"cmd/stream: status property key is longer than 65535 bytes")
 )
 
+// ReservedPropertyError is returned when marshalling a Status whose Properties
+// contain one of the ReservedStatusProperties.
+type ReservedPropertyError string
+
+var _ error = new(ReservedPropertyError)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e ReservedPropertyError) Error() string {
+	return fmt.Sprintf(
+		"cmd/stream: status property %q must be set in Arguments",
+		string(e))
+}
+
+// MissingArgumentError is returned when marshalling a Status whose Arguments
+// leave out one of the required "level" and "code" arguments.
+type MissingArgumentError string
+
+var _ error = new(MissingArgumentError)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e MissingArgumentError) Error() string {
+	return fmt.Sprintf("cmd/stream: status argument %q is required",
+		string(e))
+}
+
+// StatusArguments are the properties which lead the info object of a Status, in
+// the order that clients expect them. The Level and Code are required, while an
+// empty Description is left out of the info object.
+type StatusArguments struct {
+	// Level is the level of the Status, either "status" or "error".
+	Level string `amf0:"level"`
+	// Code identifies the Status, such as "NetStream.Publish.Start".
+	Code string `amf0:"code"`
+	// Description is a human-readable description of the Status.
+	Description string `amf0:"description,omitempty"`
+}
+
 // Status encapsulates the data contained in the body of an OnStatus command.
 type Status struct {
 	// Arguments correspond to the "arguments" field in the body of an
 	// OnStatus command (as defined by the RTMP specification).
-	Arguments amf0.Object
+	Arguments StatusArguments
+	// Properties holds any additional properties (such as "clientid", or
+	// "details") which are merged into the info object after Arguments,
+	// in order of their keys. Each is encoded as by the amf0.Marshal
+	// function of the github.com/WatchBeam/rtmp/amf0 package.
+	Properties map[string]interface{}
 }
 
 // NewStatus returns a new instance of the *Status type.
 func NewStatus() *Status {
 	return &Status{
-		Arguments: *amf0.NewObject(),
+		Properties: make(map[string]interface{}),
+	}
+}
+
+// NewPublishStartStatus returns a new *Status with the "NetStream.Publish.Start"
+// code, sent to the client once it has successfully started publishing the
+// stream with the given name.
+func NewPublishStartStatus(name string) *Status {
+	return newInfoStatus(PublishStartCode,
+		fmt.Sprintf("%s is now published.", name))
+}
+
+// NewPublishBadNameStatus returns a new *Status with the
+// "NetStream.Publish.BadName" code, sent to the client when it may not publish
+// a stream with the given name.
+func NewPublishBadNameStatus(name string) *Status {
+	return newErrorStatus("NetStream.Publish.BadName",
+		fmt.Sprintf("%s is not a valid stream name.", name))
+}
+
+// NewPublishRejectedStatus returns a new *Status with the
+// "NetStream.Publish.Rejected" code, sent to the client when it is not
+// authorized to publish a stream, for the given reason.
+func NewPublishRejectedStatus(reason error) *Status {
+	return newErrorStatus("NetStream.Publish.Rejected", reason.Error())
+}
+
+// NewPlayStartStatus returns a new *Status with the "NetStream.Play.Start"
+// code, sent to the client once it has successfully started playing the stream
+// with the given name.
+func NewPlayStartStatus(name string) *Status {
+	return newInfoStatus(PlayStartCode,
+		fmt.Sprintf("Started playing %s.", name))
+}
+
+// NewPlayStopStatus returns a new *Status with the "NetStream.Play.Stop" code,
+// sent to the client once it has stopped playing the stream with the given
+// name.
+func NewPlayStopStatus(name string) *Status {
+	return newInfoStatus(PlayStopCode,
+		fmt.Sprintf("Stopped playing %s.", name))
+}
+
+// NewPlayStreamNotFoundStatus returns a new *Status with the
+// "NetStream.Play.StreamNotFound" code, sent to the client when there is no
+// stream with the given name for it to play.
+func NewPlayStreamNotFoundStatus(name string) *Status {
+	return newErrorStatus("NetStream.Play.StreamNotFound",
+		fmt.Sprintf("%s was not found.", name))
+}
+
+// NewPlayFailedStatus returns a new *Status with the "NetStream.Play.Failed"
+// code, sent to the client when it is not able to play a stream, for the given
+// reason.
+func NewPlayFailedStatus(reason error) *Status {
+	return newErrorStatus("NetStream.Play.Failed", reason.Error())
+}
+
+// NewSeekNotifyStatus returns a new *Status with the "NetStream.Seek.Notify"
+// code, sent to the client in response to a successful CommandSeek.
+func NewSeekNotifyStatus(offsetMillis float64) *Status {
+	return newInfoStatus("NetStream.Seek.Notify",
+		fmt.Sprintf("Seeking %v.", offsetMillis))
+}
+
+// NewPauseNotifyStatus returns a new *Status sent to the client in response to
+// the given CommandPause. Its code is "NetStream.Pause.Notify" if the stream was
+// paused, or "NetStream.Unpause.Notify" if it was resumed.
+func NewPauseNotifyStatus(c *CommandPause) *Status {
+	if c.Paused {
+		return newInfoStatus("NetStream.Pause.Notify",
+			"Pausing stream.")
+	}
+
+	return newInfoStatus("NetStream.Unpause.Notify",
+		"Unpausing stream.")
+}
+
+// newInfoStatus returns a new *Status with a level of "status", and the given
+// code and description.
+func newInfoStatus(code, description string) *Status {
+	s := NewStatus()
+	s.Arguments = StatusArguments{
+		Level:       "status",
+		Code:        code,
+		Description: description,
+	}
+
+	return s
+}
+
+// newErrorStatus returns a new *Status with a level of "error", and the given
+// code and description.
+func newErrorStatus(code, description string) *Status {
+	s := NewStatus()
+	s.Arguments = StatusArguments{
+		Level:       "error",
+		Code:        code,
+		Description: description,
 	}
+
+	return s
+}
+
+// Code returns the "code" argument of the Status, or an empty string if it has
+// none.
+func (s *Status) Code() string {
+	return s.Arguments.Code
 }
 
 // Data marshals the data contained in the *Status type, returning either a
-// []byte containing that data, or an error if it was unmarshallable.
+// []byte containing that data, or an error if it was unmarshallable. If the
+// Level or Code is empty, a MissingArgumentError is returned instead, as is a
+// ReservedPropertyError if any of the Properties are one of the
+// ReservedStatusProperties, and ErrStatusPropertyTooLong if any of their keys
+// are longer than 65535 bytes.
 func (s *Status) Data() ([]byte, error) {
-	return encoding.Marshal(s)
+	if s.Arguments.Level == "" {
+		return nil, MissingArgumentError("level")
+	}
+	if s.Arguments.Code == "" {
+		return nil, MissingArgumentError("code")
+	}
+
+	data, err := amf.Marshal(s.Arguments)
+	if err != nil || len(s.Properties) == 0 {
+		return data, err
+	}
+
+	keys := make([]string, 0, len(s.Properties))
+	for key := range s.Properties {
+		for _, reserved := range ReservedStatusProperties {
+			if key == reserved {
+				return nil, ReservedPropertyError(key)
+			}
+		}
+		if len(key) > math.MaxUint16 {
+			return nil, ErrStatusPropertyTooLong
+		}
+
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Splice the properties in before the object-end marker.
+	body, end := data[:len(data)-3], data[len(data)-3:]
+
+	buf := new(bytes.Buffer)
+	buf.Write(body)
+	for _, key := range keys {
+		spec.PutUint16(uint16(len(key)), buf)
+		buf.WriteString(key)
+
+		if err := amf.NewEncoder(buf).Encode(s.Properties[key]); err != nil {
+			return nil, err
+		}
+	}
+	buf.Write(end)
+
+	return buf.Bytes(), nil
 }
 
 // AsChunk formats the data contained in the entirety of the OnStatus command
-// into a chunk, including the header. If the data was unable to be marshalled,
-// then an error will be returned, otherwise a chunk will be returned in the
-// happy case.
+// into a chunk, including the header. If the data was unable to be marshalled
+// (see Data), then an error will be returned, otherwise a chunk will be
+// returned in the happy case.
 func (s *Status) AsChunk() (*chunk.Chunk, error) {
 	body, err := s.Data()
 	if err != nil {
 		return nil, err
 	}
 
-	payload := append(OnStatusCommandHeader, body...)
+	payload := make([]byte, 0, len(OnStatusCommandHeader)+len(body))
+	payload = append(payload, OnStatusCommandHeader...)
+	payload = append(payload, body...)
 
 	return &chunk.Chunk{
 		Header: &chunk.Header{
@@ -1,9 +1,10 @@
 package stream_test
 
 import (
+	"errors"
 	"testing"
 
-	"github.com/WatchBeam/amf0"
+	amf "github.com/WatchBeam/rtmp/amf0"
 	"github.com/WatchBeam/rtmp/chunk"
 	"github.com/WatchBeam/rtmp/cmd/stream"
 	"github.com/stretchr/testify/assert"
@@ -27,30 +28,66 @@ func TestNewStatusMakesNewStatuses(t *testing.T) {
 	assert.IsType(t, new(stream.Status), st)
 }
 
-func TestDataMarshalsTheStatusesData(t *testing.T) {
+// newTestStatus returns a *Status with the given property, and the required
+// arguments set to a level of "status" and a code of "Ok".
+func newTestStatus(key string, value interface{}) *stream.Status {
 	st := stream.NewStatus()
-	st.Arguments.Add("foo", amf0.NewString("bar"))
+	st.Arguments = stream.StatusArguments{Level: "status", Code: "Ok"}
+	st.Properties[key] = value
 
-	data, err := st.Data()
+	return st
+}
+
+func TestDataMarshalsTheStatusesData(t *testing.T) {
+	data, err := newTestStatus("foo", "bar").Data()
 
 	assert.Nil(t, err)
 	assert.Equal(t, []byte{
-		0x03, 0x00, 0x03, 0x66, 0x6f, 0x6f, 0x02, 0x00, 0x03, 0x62,
-		0x61, 0x72, 0x00, 0x00, 0x09,
+		0x03, 0x00, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x02, 0x00,
+		0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x00, 0x04, 0x63,
+		0x6f, 0x64, 0x65, 0x02, 0x00, 0x02, 0x4f, 0x6b, 0x00, 0x03,
+		0x66, 0x6f, 0x6f, 0x02, 0x00, 0x03, 0x62, 0x61, 0x72, 0x00,
+		0x00, 0x09,
 	}, data)
 }
 
-func TestAsChunkMarshalsTheStatusToChunks(t *testing.T) {
+func TestDataRequiresTheLevelAndCode(t *testing.T) {
 	st := stream.NewStatus()
-	st.Arguments.Add("foo", amf0.NewString("bar"))
+
+	_, err := st.Data()
+	assert.Equal(t, stream.MissingArgumentError("level"), err)
+
+	st.Arguments.Level = "status"
+	_, err = st.Data()
+	assert.Equal(t, stream.MissingArgumentError("code"), err)
+	assert.Equal(t, `cmd/stream: status argument "code" is required`,
+		err.Error())
+
+	st.Arguments.Code = "Ok"
+	_, err = st.Data()
+	assert.Nil(t, err)
+}
+
+func TestDataRejectsPropertyKeysThatAreTooLong(t *testing.T) {
+	st := newTestStatus(string(make([]byte, 65536)), "bar")
 
 	c, err := st.AsChunk()
 
+	assert.Nil(t, c)
+	assert.Equal(t, stream.ErrStatusPropertyTooLong, err)
+}
+
+func TestAsChunkMarshalsTheStatusToChunks(t *testing.T) {
+	c, err := newTestStatus("foo", "bar").AsChunk()
+
 	expected := []byte{
 		0x02, 0x00, 0x08, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75,
 		0x73, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x05, 0x03, 0x00, 0x03, 0x66, 0x6f, 0x6f, 0x02, 0x00, 0x03,
-		0x62, 0x61, 0x72, 0x00, 0x00, 0x09,
+		0x05, 0x03, 0x00, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x02,
+		0x00, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x00, 0x04,
+		0x63, 0x6f, 0x64, 0x65, 0x02, 0x00, 0x02, 0x4f, 0x6b, 0x00,
+		0x03, 0x66, 0x6f, 0x6f, 0x02, 0x00, 0x03, 0x62, 0x61, 0x72,
+		0x00, 0x00, 0x09,
 	}
 
 	assert.Nil(t, err)
@@ -68,3 +105,139 @@ func TestAsChunkMarshalsTheStatusToChunks(t *testing.T) {
 		Data: expected,
 	}, *c)
 }
+
+func TestNewPublishStartStatusDescribesThePublish(t *testing.T) {
+	expected := stream.NewStatus()
+	expected.Arguments = stream.StatusArguments{
+		Level:       "status",
+		Code:        "NetStream.Publish.Start",
+		Description: "foo is now published.",
+	}
+
+	st := stream.NewPublishStartStatus("foo")
+
+	assert.Equal(t, expected, st)
+}
+
+func TestNewPublishBadNameStatusIsAnError(t *testing.T) {
+	expected := stream.NewStatus()
+	expected.Arguments = stream.StatusArguments{
+		Level:       "error",
+		Code:        "NetStream.Publish.BadName",
+		Description: "foo is not a valid stream name.",
+	}
+
+	st := stream.NewPublishBadNameStatus("foo")
+
+	assert.Equal(t, expected, st)
+}
+
+func TestNewPlayFailedStatusDescribesTheReason(t *testing.T) {
+	expected := stream.NewStatus()
+	expected.Arguments = stream.StatusArguments{
+		Level:       "error",
+		Code:        "NetStream.Play.Failed",
+		Description: "expired token",
+	}
+
+	st := stream.NewPlayFailedStatus(errors.New("expired token"))
+
+	assert.Equal(t, expected, st)
+}
+
+func TestNewPlayStopStatusDescribesTheStop(t *testing.T) {
+	expected := stream.NewStatus()
+	expected.Arguments = stream.StatusArguments{
+		Level:       "status",
+		Code:        "NetStream.Play.Stop",
+		Description: "Stopped playing foo.",
+	}
+
+	st := stream.NewPlayStopStatus("foo")
+
+	assert.Equal(t, expected, st)
+}
+
+func TestStatusCodeReturnsTheCode(t *testing.T) {
+	assert.Equal(t, stream.PlayStartCode, stream.NewPlayStartStatus("foo").Code())
+	assert.Equal(t, "", stream.NewStatus().Code())
+}
+
+func TestNewSeekNotifyStatusDescribesTheSeek(t *testing.T) {
+	expected := stream.NewStatus()
+	expected.Arguments = stream.StatusArguments{
+		Level:       "status",
+		Code:        "NetStream.Seek.Notify",
+		Description: "Seeking 1500.",
+	}
+
+	st := stream.NewSeekNotifyStatus(1500)
+
+	assert.Equal(t, expected, st)
+}
+
+func TestNewPauseNotifyStatusDescribesThePause(t *testing.T) {
+	for _, c := range []struct {
+		Paused      bool
+		Code        string
+		Description string
+	}{
+		{true, "NetStream.Pause.Notify", "Pausing stream."},
+		{false, "NetStream.Unpause.Notify", "Unpausing stream."},
+	} {
+		expected := stream.NewStatus()
+		expected.Arguments = stream.StatusArguments{
+			Level:       "status",
+			Code:        c.Code,
+			Description: c.Description,
+		}
+
+		st := stream.NewPauseNotifyStatus(&stream.CommandPause{
+			Paused: c.Paused,
+		})
+
+		assert.Equal(t, expected, st)
+	}
+}
+
+func TestStatusPropertiesAreMergedIntoTheInfoObject(t *testing.T) {
+	st := stream.NewPublishStartStatus("foo")
+	st.Properties["details"] = "foo"
+	st.Properties["clientid"] = "bar"
+
+	c, err := st.AsChunk()
+	assert.Nil(t, err)
+
+	expected, _ := amf.Marshal(struct {
+		Level       string `amf0:"level"`
+		Code        string `amf0:"code"`
+		Description string `amf0:"description"`
+		ClientId    string `amf0:"clientid"`
+		Details     string `amf0:"details"`
+	}{"status", "NetStream.Publish.Start", "foo is now published.", "bar", "foo"})
+
+	assert.Equal(t, expected, c.Data[len(stream.OnStatusCommandHeader):])
+}
+
+func TestAsChunkDoesNotShareTheOnStatusCommandHeader(t *testing.T) {
+	a, err := stream.NewPlayStartStatus("foo").AsChunk()
+	assert.Nil(t, err)
+	data := append([]byte(nil), a.Data...)
+
+	_, err = stream.NewPlayStopStatus("bar").AsChunk()
+	assert.Nil(t, err)
+
+	assert.Equal(t, data, a.Data)
+	assert.Equal(t, ValidOnStatusHeader, stream.OnStatusCommandHeader)
+}
+
+func TestStatusPropertiesMayNotOverrideReservedFields(t *testing.T) {
+	st := newTestStatus("code", "NetStream.Publish.Start")
+
+	c, err := st.AsChunk()
+
+	assert.Nil(t, c)
+	assert.Equal(t, stream.ReservedPropertyError("code"), err)
+	assert.Equal(t, `cmd/stream: status property "code" must be set in Arguments`,
+		err.Error())
+}
@@ -1,17 +1,34 @@
 package stream
 
-import "github.com/WatchBeam/amf0"
+import (
+	"io"
+	"net/url"
+	"strings"
+)
 
 type (
 	CommandPlay struct {
 		PlayPath string
 		Live     float64
+		// Query holds the query parameters appended to the stream
+		// name, such as a token for an Authenticator to validate, or
+		// nil if there were none. They are removed from PlayPath.
+		Query url.Values
+		// StreamId is the ID of the message stream that the command
+		// was received over, which the statuses written in response
+		// are sent over as well (see NetStream.Play).
+		StreamId uint32
 	}
 
 	CommandPlay2 struct {
-		Parameters *amf0.Object
+		// Parameters holds the decoded properties of the parameters
+		// object, as in CommandConnect.Parameters.
+		Parameters map[string]interface{}
 	}
 
+	// CommandDeleteStream is sent by the client over the NetConnection to
+	// delete the message stream identified by StreamId, after which its
+	// ID may be allocated again.
 	CommandDeleteStream struct {
 		StreamId float64
 	}
@@ -24,17 +41,46 @@ type (
 		Successful bool
 	}
 
+	// CommandPublish is sent by the client to publish a named stream to
+	// the server. The leading null command object is consumed by the
+	// CommandHeader.
 	CommandPublish struct {
+		// Name is the name (or stream key) of the stream being
+		// published.
 		Name string
+		// Type is the publishing type, one of "live", "record", or
+		// "append" (see PublishingType).
 		Type string
+		// Query holds the query parameters appended to the stream
+		// name (as in "key?sign=...&expiry=..."), or nil if there
+		// were none. They are removed from Name.
+		Query url.Values
+		// StreamId is the ID of the message stream that the command
+		// was received over, which the statuses written in response
+		// are sent over as well (see NetStream.Publish).
+		StreamId uint32
 	}
 
+	// CommandSeek is sent by the client to seek to a particular offset
+	// within a (recorded) stream. The server is expected to respond with
+	// a "NetStream.Seek.Notify" status (see NewSeekNotifyStatus) if the
+	// seek was successful.
 	CommandSeek struct {
+		// OffsetMillis is the offset, in milliseconds, to seek to.
 		OffsetMillis float64
 	}
 
+	// CommandPause is sent by the client to pause or resume playback of
+	// a stream. The server is expected to respond with a
+	// "NetStream.Pause.Notify" status when the stream is paused, or a
+	// "NetStream.Unpause.Notify" status when it is resumed (see
+	// NewPauseNotifyStatus).
 	CommandPause struct {
-		Paused       bool
+		// Paused is true when playback should be paused, and false
+		// when it should be resumed.
+		Paused bool
+		// CutoffMillis is the position of the stream, in
+		// milliseconds, at which the stream was paused or resumed.
 		CutoffMillis float64
 	}
 )
@@ -47,3 +93,94 @@ func (_ *CommandReceiveVideo) IsCommand() bool { return true }
 func (_ *CommandPublish) IsCommand() bool      { return true }
 func (_ *CommandSeek) IsCommand() bool         { return true }
 func (_ *CommandPause) IsCommand() bool        { return true }
+
+var _ Unmarshaler = new(CommandPlay)
+var _ Unmarshaler = new(CommandPublish)
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand. The play path is
+// split from its query parameters (see Query). The optional duration and reset
+// arguments following the start (Live) argument are ignored.
+func (c *CommandPlay) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	args := new(struct {
+		PlayPath string
+		Live     float64
+	})
+	if err := unmarshalArguments(decoderFor(r), args); err != nil {
+		return err
+	}
+
+	path, query, err := splitQuery(args.PlayPath)
+	if err != nil {
+		return err
+	}
+
+	c.PlayPath, c.Live, c.Query = path, args.Live, query
+	return skipOptionalArguments(r)
+}
+
+// UnmarshalCommand implements Unmarshaler.UnmarshalCommand. The name of the
+// stream is split from its query parameters (see Query).
+func (c *CommandPublish) UnmarshalCommand(header *CommandHeader, r io.Reader) error {
+	args := new(struct {
+		Name string
+		Type string
+	})
+	if err := unmarshalArguments(decoderFor(r), args); err != nil {
+		return err
+	}
+
+	name, query, err := splitQuery(args.Name)
+	if err != nil {
+		return err
+	}
+
+	c.Name, c.Type, c.Query = name, args.Type, query
+	return nil
+}
+
+// splitQuery splits the given stream name from the query string appended to
+// it, if any, returning the name and parsed query. If there is no query
+// string, the query is nil.
+func splitQuery(name string) (string, url.Values, error) {
+	i := strings.IndexByte(name, '?')
+	if i < 0 {
+		return name, nil, nil
+	}
+
+	query, err := url.ParseQuery(name[i+1:])
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name[:i], query, nil
+}
+
+// PublishingType is the type of publishing requested in a CommandPublish.
+type PublishingType string
+
+const (
+	// PublishLive publishes live data, without recording it.
+	PublishLive PublishingType = "live"
+	// PublishRecord publishes the stream and records it, replacing any
+	// existing recording.
+	PublishRecord PublishingType = "record"
+	// PublishAppend publishes the stream and appends it to any existing
+	// recording, creating one if it does not exist.
+	PublishAppend PublishingType = "append"
+)
+
+// PublishingType returns the publishing type requested by the client.
+func (c *CommandPublish) PublishingType() PublishingType {
+	return PublishingType(c.Type)
+}
+
+// Records returns whether or not the published stream should be persisted,
+// which is the case for the "record" and "append" publishing types.
+func (c *CommandPublish) Records() bool {
+	switch c.PublishingType() {
+	case PublishRecord, PublishAppend:
+		return true
+	}
+
+	return false
+}
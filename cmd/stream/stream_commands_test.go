@@ -1,6 +1,8 @@
 package stream_test
 
 import (
+	"bytes"
+	"net/url"
 	"testing"
 
 	"github.com/WatchBeam/rtmp/cmd/stream"
@@ -17,6 +19,9 @@ func TestStreamCommandsImplementCommand(t *testing.T) {
 		new(stream.CommandPublish),
 		new(stream.CommandSeek),
 		new(stream.CommandPause),
+		new(stream.CommandConnect),
+		new(stream.CommandCreateStream),
+		new(stream.CommandCloseStream),
 	} {
 		if cmd, ok := c.(stream.Command); ok {
 			assert.True(t, cmd.IsCommand(),
@@ -29,3 +34,72 @@ func TestStreamCommandsImplementCommand(t *testing.T) {
 		}
 	}
 }
+
+func TestPublishCommandsExposeThePublishingType(t *testing.T) {
+	for _, c := range []struct {
+		Type    string
+		Records bool
+	}{
+		{"live", false},
+		{"record", true},
+		{"append", true},
+	} {
+		cmd := &stream.CommandPublish{Name: "key", Type: c.Type}
+
+		assert.Equal(t, stream.PublishingType(c.Type), cmd.PublishingType())
+		assert.Equal(t, c.Records, cmd.Records())
+	}
+}
+
+func TestPublishStreamKeysAreSplitFromTheirQuery(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader(encode(
+		"publish", 5, nil,
+		"live_8675309?sign=1712345678-a1b2c3d4e5f6-0-9f86d081884c7d659a2feaa0c55ad015"+
+			"&expiry=1712345678&txSecret=e3b0c44298fc1c14",
+		"live",
+	)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandPublish{
+		Name: "live_8675309",
+		Type: "live",
+		Query: url.Values{
+			"sign": []string{
+				"1712345678-a1b2c3d4e5f6-0-9f86d081884c7d659a2feaa0c55ad015",
+			},
+			"expiry":   []string{"1712345678"},
+			"txSecret": []string{"e3b0c44298fc1c14"},
+		},
+	}, cmd)
+}
+
+func TestPlayPathsAreSplitFromTheirQuery(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader(encode(
+		"play", 0, nil, "mp4:video.mp4?token=abc%2Fdef%3D", -2,
+	)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stream.CommandPlay{
+		PlayPath: "mp4:video.mp4",
+		Live:     -2,
+		Query:    url.Values{"token": []string{"abc/def="}},
+	}, cmd)
+}
+
+func TestStreamKeysWithoutAQueryHaveNone(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader(encode(
+		"publish", 5, nil, "key", "live",
+	)))
+
+	assert.Nil(t, err)
+	assert.Nil(t, cmd.(*stream.CommandPublish).Query)
+}
+
+func TestMalformedStreamKeyQueriesAreRejected(t *testing.T) {
+	cmd, err := stream.DefaultParser.Parse(bytes.NewReader(encode(
+		"publish", 5, nil, "key?sign=%zz", "live",
+	)))
+
+	assert.Nil(t, cmd)
+	assert.NotNil(t, err)
+}
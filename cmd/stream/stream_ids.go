@@ -0,0 +1,51 @@
+package stream
+
+import "sync"
+
+// StreamIdAllocator allocates message stream IDs for the streams created over
+// a single connection. It is safe for concurrent use, and never hands out the
+// same ID twice unless that ID has been released.
+//
+// Message stream ID 0 is reserved for the NetConnection, so allocated IDs start
+// at 1.
+type StreamIdAllocator struct {
+	// mu guards used
+	mu sync.Mutex
+	// used is the set of IDs that are currently allocated.
+	used map[uint32]struct{}
+}
+
+// NewStreamIdAllocator returns a new *StreamIdAllocator with no allocated IDs.
+func NewStreamIdAllocator() *StreamIdAllocator {
+	return &StreamIdAllocator{
+		used: make(map[uint32]struct{}),
+	}
+}
+
+// Allocate returns the lowest message stream ID that is not currently
+// allocated, and marks it as allocated.
+func (a *StreamIdAllocator) Allocate() uint32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id := uint32(1)
+	for {
+		if _, ok := a.used[id]; !ok {
+			break
+		}
+		id++
+	}
+
+	a.used[id] = struct{}{}
+
+	return id
+}
+
+// Release marks the given message stream ID as no longer allocated, so that it
+// may be handed out again.
+func (a *StreamIdAllocator) Release(id uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.used, id)
+}
@@ -0,0 +1,51 @@
+package stream_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/cmd/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamIdAllocatorStartsAtOne(t *testing.T) {
+	ids := stream.NewStreamIdAllocator()
+
+	assert.Equal(t, uint32(1), ids.Allocate())
+	assert.Equal(t, uint32(2), ids.Allocate())
+}
+
+func TestStreamIdAllocatorReusesReleasedIds(t *testing.T) {
+	ids := stream.NewStreamIdAllocator()
+	ids.Allocate()
+	ids.Allocate()
+	ids.Allocate()
+
+	ids.Release(2)
+
+	assert.Equal(t, uint32(2), ids.Allocate())
+	assert.Equal(t, uint32(4), ids.Allocate())
+}
+
+func TestStreamIdAllocatorDoesNotCollideWhenConcurrent(t *testing.T) {
+	ids := stream.NewStreamIdAllocator()
+
+	var wg sync.WaitGroup
+	allocated := make(chan uint32, 100)
+	for i := 0; i < cap(allocated); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			allocated <- ids.Allocate()
+		}()
+	}
+	wg.Wait()
+	close(allocated)
+
+	seen := make(map[uint32]bool)
+	for id := range allocated {
+		assert.False(t, seen[id], "id %v allocated twice", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, 100)
+}
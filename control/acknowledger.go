@@ -0,0 +1,60 @@
+package control
+
+import "sync"
+
+// Acknowledger keeps track of the number of bytes received from the peer, and
+// determines when an Acknowledgement must be sent, according to the window size
+// given by the peer in its Window Acknowledgement Size control sequence.
+type Acknowledger struct {
+	// mu guards all fields below.
+	mu sync.Mutex
+	// window is the number of bytes that may be received before an
+	// Acknowledgement must be sent. If zero, no Acknowledgements are sent.
+	window uint32
+	// received is the total number of bytes received, which wraps around
+	// at 2^32, as per the RTMP specification.
+	received uint32
+	// acked is the value of received at the time that the last
+	// Acknowledgement was sent.
+	acked uint32
+}
+
+// NewAcknowledger returns a new *Acknowledger with no window set.
+func NewAcknowledger() *Acknowledger {
+	return new(Acknowledger)
+}
+
+// Window returns the current acknowledgement window size, in bytes.
+func (a *Acknowledger) Window() uint32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.window
+}
+
+// SetWindow sets the acknowledgement window size, in bytes.
+func (a *Acknowledger) SetWindow(size uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.window = size
+}
+
+// Received records that `n` more bytes have been received from the peer. If
+// at least a full window of bytes has been received since the last
+// Acknowledgement, a new Acknowledgement is returned, which should be sent to
+// the peer. Otherwise, nil is returned.
+func (a *Acknowledger) Received(n int) *Acknowledgement {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.received += uint32(n)
+
+	if a.window == 0 || a.received-a.acked < a.window {
+		return nil
+	}
+
+	a.acked = a.received
+
+	return &Acknowledgement{SequenceNumber: a.received}
+}
@@ -0,0 +1,36 @@
+package control_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/control"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcknowledgerDoesNotAcknowledgeWithoutAWindow(t *testing.T) {
+	a := control.NewAcknowledger()
+
+	assert.Nil(t, a.Received(1<<20))
+}
+
+func TestAcknowledgerAcknowledgesOnceWindowIsFull(t *testing.T) {
+	a := control.NewAcknowledger()
+	a.SetWindow(10)
+
+	assert.Nil(t, a.Received(6))
+	assert.Equal(t, &control.Acknowledgement{SequenceNumber: 12},
+		a.Received(6))
+	assert.Nil(t, a.Received(6))
+	assert.Equal(t, &control.Acknowledgement{SequenceNumber: 24},
+		a.Received(6))
+}
+
+func TestAcknowledgerHandlesSequenceNumberWrapAround(t *testing.T) {
+	a := control.NewAcknowledger()
+	a.SetWindow(10)
+
+	a.Received(0xfffffffa)
+
+	assert.Equal(t, &control.Acknowledgement{SequenceNumber: 4},
+		a.Received(10))
+}
@@ -0,0 +1,155 @@
+package control
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// bandwidthSmoothing is the weight given to each new throughput sample
+	// in the exponentially weighted moving average kept by a
+	// BandwidthEstimator.
+	bandwidthSmoothing = 0.25
+
+	// sampleGranularity is the interval within which consecutive writes are
+	// coalesced into a single sample.
+	sampleGranularity = 10 * time.Millisecond
+	// maxSamples is the number of samples kept while waiting for an
+	// Acknowledgement, beyond which the oldest ones are discarded.
+	maxSamples = 4096
+)
+
+// sample records the time at which a range of outgoing bytes began being sent.
+type sample struct {
+	// end is the total number of bytes sent once the bytes of this
+	// sample were.
+	end uint64
+	// at is the time at which the first byte of this sample was sent.
+	at time.Time
+}
+
+// BandwidthEstimator estimates the throughput at which the peer receives the
+// bytes sent to it, by correlating the time at which those bytes were sent with
+// the time at which the peer acknowledges them in an Acknowledgement. Its
+// estimate is useful for adaptive bitrate decisions, such as picking which
+// rendition of a stream to serve.
+//
+// The estimate is only as accurate as the Acknowledgements sent by the peer
+// are frequent: a peer acknowledges once per window of bytes received (see
+// WindowAckSize), so a large window, or a peer which seldom (or never) sends
+// Acknowledgements, yields stale (or no) estimates. It also measures the rate
+// at which bytes were actually delivered, which is lower than the capacity of
+// the link whenever less is sent than the link could carry.
+type BandwidthEstimator struct {
+	// mu guards all fields below.
+	mu sync.Mutex
+	// now returns the current time.
+	now func() time.Time
+
+	// sent is the total number of bytes sent.
+	sent uint64
+	// samples holds the samples of bytes sent which have not yet been
+	// acknowledged, oldest first.
+	samples []sample
+
+	// seq is the sequence number of the last Acknowledgement received,
+	// which wraps around at 2^32, as per the RTMP specification.
+	seq uint32
+	// acked is the total number of bytes sent that have been acknowledged.
+	acked uint64
+
+	// kbps is the smoothed estimate of throughput, in kilobits per
+	// second, or zero if there is none yet.
+	kbps float64
+}
+
+// NewBandwidthEstimator returns a new *BandwidthEstimator, with no bytes sent
+// and no estimate.
+func NewBandwidthEstimator() *BandwidthEstimator {
+	return &BandwidthEstimator{now: time.Now}
+}
+
+// Sent records that `n` more bytes have been sent to the peer.
+func (b *BandwidthEstimator) Sent(n int) {
+	if n <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.sent += uint64(n)
+
+	if last := len(b.samples) - 1; last >= 0 &&
+		now.Sub(b.samples[last].at) < sampleGranularity {
+
+		b.samples[last].end = b.sent
+		return
+	}
+
+	if len(b.samples) == maxSamples {
+		b.samples = b.samples[1:]
+	}
+	b.samples = append(b.samples, sample{end: b.sent, at: now})
+}
+
+// Acknowledged records that the peer has acknowledged receiving bytes up to
+// the given sequence number, and updates the estimate with the throughput at
+// which the bytes acknowledged since the previous Acknowledgement were
+// delivered: their number, over the time elapsed since the first of them was
+// sent.
+func (b *BandwidthEstimator) Acknowledged(seq uint32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+
+	n := uint64(seq - b.seq)
+	b.seq = seq
+	if n == 0 {
+		return
+	}
+
+	// The peer may count bytes which were not recorded by Sent (such as
+	// those of the handshake), so no more bytes are considered
+	// acknowledged than were sent.
+	from := b.acked
+	b.acked += n
+	if b.acked > b.sent {
+		b.acked = b.sent
+	}
+
+	var start time.Time
+	for i, s := range b.samples {
+		if s.end > from {
+			start = s.at
+			b.samples = b.samples[i:]
+			break
+		}
+	}
+	for len(b.samples) > 0 && b.samples[0].end <= b.acked {
+		b.samples = b.samples[1:]
+	}
+
+	elapsed := now.Sub(start)
+	if start.IsZero() || elapsed <= 0 || b.acked <= from {
+		return
+	}
+
+	kbps := float64(b.acked-from) * 8 / 1000 / elapsed.Seconds()
+	if b.kbps == 0 {
+		b.kbps = kbps
+	} else {
+		b.kbps += bandwidthSmoothing * (kbps - b.kbps)
+	}
+}
+
+// Kbps returns the estimated throughput at which the peer receives bytes, in
+// kilobits per second, or zero if no estimate has been made yet.
+func (b *BandwidthEstimator) Kbps() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.kbps
+}
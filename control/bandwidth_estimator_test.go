@@ -0,0 +1,117 @@
+package control
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeEstimator returns a *BandwidthEstimator whose clock is advanced only by
+// the returned function.
+func newFakeEstimator() (*BandwidthEstimator, func(d time.Duration)) {
+	now := time.Unix(0, 0)
+
+	b := NewBandwidthEstimator()
+	b.now = func() time.Time { return now }
+
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestBandwidthEstimatorHasNoEstimateWithoutAcknowledgements(t *testing.T) {
+	b, advance := newFakeEstimator()
+
+	b.Sent(1 << 20)
+	advance(time.Second)
+
+	assert.Zero(t, b.Kbps())
+}
+
+func TestBandwidthEstimatorEstimatesFromAcknowledgementTiming(t *testing.T) {
+	b, advance := newFakeEstimator()
+
+	b.Sent(125000)
+	advance(time.Second)
+	b.Acknowledged(125000)
+
+	assert.InDelta(t, 1000, b.Kbps(), 0.001)
+}
+
+func TestBandwidthEstimatorMeasuresFromTheFirstUnacknowledgedByte(t *testing.T) {
+	b, advance := newFakeEstimator()
+
+	b.Sent(1000)
+	advance(time.Second)
+	b.Sent(1000)
+	advance(time.Second)
+	b.Acknowledged(1000)
+
+	assert.InDelta(t, 4, b.Kbps(), 0.001)
+
+	advance(time.Second)
+	b.Acknowledged(2000)
+
+	assert.InDelta(t, 4, b.Kbps(), 0.001)
+}
+
+func TestBandwidthEstimatorSmoothsEstimates(t *testing.T) {
+	b, advance := newFakeEstimator()
+
+	b.Sent(125000)
+	advance(time.Second)
+	b.Acknowledged(125000)
+
+	b.Sent(250000)
+	advance(time.Second)
+	b.Acknowledged(375000)
+
+	assert.InDelta(t, 1000+0.25*(2000-1000), b.Kbps(), 0.001)
+}
+
+func TestBandwidthEstimatorCoalescesWrites(t *testing.T) {
+	b, advance := newFakeEstimator()
+
+	for i := 0; i < 10; i++ {
+		b.Sent(100)
+		advance(time.Millisecond)
+	}
+
+	assert.Len(t, b.samples, 1)
+	assert.Equal(t, uint64(1000), b.samples[0].end)
+}
+
+func TestBandwidthEstimatorIgnoresBytesItDidNotSend(t *testing.T) {
+	b, advance := newFakeEstimator()
+
+	b.Sent(1000)
+	advance(time.Second)
+	// The peer counts the 3073 bytes of the handshake as well.
+	b.Acknowledged(4073)
+
+	assert.InDelta(t, 8, b.Kbps(), 0.001)
+	assert.Empty(t, b.samples)
+}
+
+func TestBandwidthEstimatorHandlesSequenceNumberWrapAround(t *testing.T) {
+	b, advance := newFakeEstimator()
+	seq := uint32(0xffffff00)
+	b.seq = seq
+
+	b.Sent(1000)
+	advance(time.Second)
+	b.Acknowledged(seq + 1000)
+
+	assert.InDelta(t, 8, b.Kbps(), 0.001)
+}
+
+func TestBandwidthEstimatorIgnoresRepeatedAcknowledgements(t *testing.T) {
+	b, advance := newFakeEstimator()
+
+	b.Sent(1000)
+	advance(time.Second)
+	b.Acknowledged(1000)
+	advance(time.Second)
+	b.Acknowledged(1000)
+
+	assert.InDelta(t, 8, b.Kbps(), 0.001)
+}
@@ -6,7 +6,13 @@ import (
 	"github.com/WatchBeam/rtmp/spec"
 )
 
+// AbortMessage is sent to inform the peer that the partially sent message on
+// the chunk stream identified by ChunkStreamId will not be completed, and that
+// the chunks received so far should be discarded. The chunk reader discards
+// them as soon as the AbortMessage is read, before it is parsed here.
 type AbortMessage struct {
+	// ChunkStreamId is the ID of the chunk stream whose message is
+	// aborted.
 	ChunkStreamId uint32
 }
 
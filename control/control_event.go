@@ -18,10 +18,9 @@ const (
 
 // Event encapsulates any event that is sent over the control stream.
 //
-// NOTE: this is a temporary type, eventually it will be replaced with
-// individual types implementing both Control and Event interfaces. The current
-// structure of the parser/identifier does not easily allow for this, so some
-// more heavy lifting will have to occur before this gets further attention.
+// Events whose type is listed in the Events variable are parsed into their own
+// UserControlEvent types instead. Event is only used for the remaining event
+// types, which are passed along with their raw body.
 type Event struct {
 	// Type is the event type of the event.
 	Type EventType
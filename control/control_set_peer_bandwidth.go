@@ -1,19 +1,44 @@
 package control
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/WatchBeam/rtmp/spec"
 )
 
+// LimitType is the type of limit that a SetPeerBandwidth control sequence
+// places on the peer's output bandwidth, as defined in the RTMP specification.
 type LimitType byte
 
 const (
+	// LimitTypeHard tells the peer to limit its output bandwidth to the
+	// given window size.
 	LimitTypeHard LimitType = iota
+	// LimitTypeSoft tells the peer to limit its output bandwidth to the
+	// given window size, or the limit already in effect, whichever is
+	// smaller.
 	LimitTypeSoft
+	// LimitTypeDynamic is treated as a hard limit if the previous limit
+	// was hard, and is ignored otherwise.
 	LimitTypeDynamic
 )
 
+// UnknownLimitType is an Error representing a scenario where a LimitType not
+// defined in the RTMP specification was read from an io.Reader.
+type UnknownLimitType byte
+
+var _ error = new(UnknownLimitType)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e UnknownLimitType) Error() string {
+	return fmt.Sprintf("control: unknown peer bandwidth limit type (%v)",
+		byte(e))
+}
+
+// SetPeerBandwidth is sent to limit the output bandwidth of the peer. The
+// peer is expected to respond with a WindowAckSize control sequence if the
+// window size differs from the last one it was sent.
 type SetPeerBandwidth struct {
 	AckWindowSize uint32
 	LimitType     LimitType
@@ -29,6 +54,10 @@ func (c *SetPeerBandwidth) Read(r io.Reader) error {
 		return err
 	}
 
+	if buf[4] > byte(LimitTypeDynamic) {
+		return UnknownLimitType(buf[4])
+	}
+
 	c.AckWindowSize = spec.Uint32(buf[:4])
 	c.LimitType = LimitType(buf[4])
 
@@ -112,3 +112,30 @@ func (c *ControlSequenceTestCase) assertWrite(t *testing.T) {
 	assert.True(t, bytes.Equal(c.Data, buf.Bytes()), fmt.Sprintf(
 		"control: payloads should be equal (%v, %v)", c.Data, buf.Bytes()))
 }
+
+func TestSetPeerBandwidthRoundTripsAllLimitTypes(t *testing.T) {
+	for _, l := range []control.LimitType{
+		control.LimitTypeHard,
+		control.LimitTypeSoft,
+		control.LimitTypeDynamic,
+	} {
+		n := rand.Uint32()
+		sent := &control.SetPeerBandwidth{n, l}
+
+		c, err := control.NewChunker().Chunk(sent)
+		assert.Nil(t, err)
+
+		received, err := control.NewParser().Parse(c)
+		assert.Nil(t, err)
+
+		assert.Equal(t, sent, received)
+	}
+}
+
+func TestSetPeerBandwidthRejectsUnknownLimitTypes(t *testing.T) {
+	ctrl := new(control.SetPeerBandwidth)
+
+	err := ctrl.Read(bytes.NewBuffer([]byte{0x00, 0x00, 0x00, 0x01, 0x03}))
+
+	assert.Equal(t, control.UnknownLimitType(3), err)
+}
@@ -0,0 +1,238 @@
+package control
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/WatchBeam/rtmp/spec"
+)
+
+const (
+	StreamEOF        EventType = 1
+	StreamDry        EventType = 2
+	StreamIsRecorded EventType = 4
+	PingRequest      EventType = 6
+	PingResponse     EventType = 7
+)
+
+var (
+	// Events is a list of all User Control Message event types that are
+	// parsed into their own types, rather than a generic *Event.
+	Events []UserControlEvent = []UserControlEvent{
+		&StreamBeginEvent{},
+		&StreamEOFEvent{},
+		&StreamDryEvent{},
+		&SetBufferLengthEvent{},
+		&StreamIsRecordedEvent{},
+		&PingRequestEvent{},
+		&PingResponseEvent{},
+	}
+)
+
+// UserControlEvent is a Control sequence sent as a User Control Message
+// (message type 4). Each UserControlEvent is identified by its EventType, which
+// is written as the first two bytes of the message, followed by the
+// event-specific payload.
+type UserControlEvent interface {
+	Control
+
+	// EventType returns the EventType that identifies this event, as
+	// defined by the RTMP specification.
+	EventType() EventType
+}
+
+// MismatchedEventType is an error returned when a UserControlEvent is read
+// from an io.Reader containing an event of a different type.
+type MismatchedEventType struct {
+	// Expected is the EventType of the UserControlEvent being read into.
+	Expected EventType
+	// Actual is the EventType that was read.
+	Actual EventType
+}
+
+var _ error = new(MismatchedEventType)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e *MismatchedEventType) Error() string {
+	return fmt.Sprintf("control: expected event type %v, got %v",
+		uint16(e.Expected), uint16(e.Actual))
+}
+
+// readEvent reads the event type and a single uint32 payload from "r" into
+// "v", ensuring that the event type read matches "expected".
+func readEvent(r io.Reader, expected EventType, v *uint32) error {
+	buf, err := spec.ReadBytes(r, 6)
+	if err != nil {
+		return err
+	}
+
+	if actual := EventType(spec.Uint16(buf[:2])); actual != expected {
+		return &MismatchedEventType{expected, actual}
+	}
+
+	*v = spec.Uint32(buf[2:])
+
+	return nil
+}
+
+// writeEvent writes the event type "typ" followed by each of the uint32s in
+// "vs" to "w".
+func writeEvent(w io.Writer, typ EventType, vs ...uint32) error {
+	if _, err := spec.PutUint16(uint16(typ), w); err != nil {
+		return err
+	}
+
+	for _, v := range vs {
+		if _, err := spec.PutUint32(v, w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StreamBeginEvent is sent by the server to notify the client that a stream
+// has become functional and can be used for communication.
+type StreamBeginEvent struct {
+	StreamId uint32
+}
+
+var _ UserControlEvent = new(StreamBeginEvent)
+
+func (e *StreamBeginEvent) TypeId() byte         { return 0x04 }
+func (e *StreamBeginEvent) EventType() EventType { return StreamBegin }
+
+func (e *StreamBeginEvent) Read(r io.Reader) error {
+	return readEvent(r, StreamBegin, &e.StreamId)
+}
+
+func (e *StreamBeginEvent) Write(w io.Writer) error {
+	return writeEvent(w, StreamBegin, e.StreamId)
+}
+
+// StreamEOFEvent is sent by the server to notify the client that the playback
+// of data on a stream is over.
+type StreamEOFEvent struct {
+	StreamId uint32
+}
+
+var _ UserControlEvent = new(StreamEOFEvent)
+
+func (e *StreamEOFEvent) TypeId() byte         { return 0x04 }
+func (e *StreamEOFEvent) EventType() EventType { return StreamEOF }
+
+func (e *StreamEOFEvent) Read(r io.Reader) error {
+	return readEvent(r, StreamEOF, &e.StreamId)
+}
+
+func (e *StreamEOFEvent) Write(w io.Writer) error {
+	return writeEvent(w, StreamEOF, e.StreamId)
+}
+
+// StreamDryEvent is sent by the server to notify the client that there is no
+// more data on a stream.
+type StreamDryEvent struct {
+	StreamId uint32
+}
+
+var _ UserControlEvent = new(StreamDryEvent)
+
+func (e *StreamDryEvent) TypeId() byte         { return 0x04 }
+func (e *StreamDryEvent) EventType() EventType { return StreamDry }
+
+func (e *StreamDryEvent) Read(r io.Reader) error {
+	return readEvent(r, StreamDry, &e.StreamId)
+}
+
+func (e *StreamDryEvent) Write(w io.Writer) error {
+	return writeEvent(w, StreamDry, e.StreamId)
+}
+
+// SetBufferLengthEvent is sent by the client to inform the server of the
+// buffer size (in milliseconds) that is used to buffer any data coming over a
+// stream.
+type SetBufferLengthEvent struct {
+	StreamId     uint32
+	BufferLength uint32
+}
+
+var _ UserControlEvent = new(SetBufferLengthEvent)
+
+func (e *SetBufferLengthEvent) TypeId() byte         { return 0x04 }
+func (e *SetBufferLengthEvent) EventType() EventType { return SetBufferLength }
+
+func (e *SetBufferLengthEvent) Read(r io.Reader) error {
+	if err := readEvent(r, SetBufferLength, &e.StreamId); err != nil {
+		return err
+	}
+
+	buf, err := spec.ReadBytes(r, 4)
+	if err != nil {
+		return err
+	}
+
+	e.BufferLength = spec.Uint32(buf)
+
+	return nil
+}
+
+func (e *SetBufferLengthEvent) Write(w io.Writer) error {
+	return writeEvent(w, SetBufferLength, e.StreamId, e.BufferLength)
+}
+
+// StreamIsRecordedEvent is sent by the server to notify the client that a
+// stream is a recorded stream.
+type StreamIsRecordedEvent struct {
+	StreamId uint32
+}
+
+var _ UserControlEvent = new(StreamIsRecordedEvent)
+
+func (e *StreamIsRecordedEvent) TypeId() byte         { return 0x04 }
+func (e *StreamIsRecordedEvent) EventType() EventType { return StreamIsRecorded }
+
+func (e *StreamIsRecordedEvent) Read(r io.Reader) error {
+	return readEvent(r, StreamIsRecorded, &e.StreamId)
+}
+
+func (e *StreamIsRecordedEvent) Write(w io.Writer) error {
+	return writeEvent(w, StreamIsRecorded, e.StreamId)
+}
+
+// PingRequestEvent is sent to test whether the peer is reachable. The peer is
+// expected to respond with a PingResponseEvent carrying the same Timestamp.
+type PingRequestEvent struct {
+	Timestamp uint32
+}
+
+var _ UserControlEvent = new(PingRequestEvent)
+
+func (e *PingRequestEvent) TypeId() byte         { return 0x04 }
+func (e *PingRequestEvent) EventType() EventType { return PingRequest }
+
+func (e *PingRequestEvent) Read(r io.Reader) error {
+	return readEvent(r, PingRequest, &e.Timestamp)
+}
+
+func (e *PingRequestEvent) Write(w io.Writer) error {
+	return writeEvent(w, PingRequest, e.Timestamp)
+}
+
+// PingResponseEvent is sent in response to a PingRequestEvent, echoing back
+// the Timestamp that was received.
+type PingResponseEvent struct {
+	Timestamp uint32
+}
+
+var _ UserControlEvent = new(PingResponseEvent)
+
+func (e *PingResponseEvent) TypeId() byte         { return 0x04 }
+func (e *PingResponseEvent) EventType() EventType { return PingResponse }
+
+func (e *PingResponseEvent) Read(r io.Reader) error {
+	return readEvent(r, PingResponse, &e.Timestamp)
+}
+
+func (e *PingResponseEvent) Write(w io.Writer) error {
+	return writeEvent(w, PingResponse, e.Timestamp)
+}
@@ -0,0 +1,56 @@
+package control_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/control"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserControlEventsRoundTrip(t *testing.T) {
+	for _, e := range []control.UserControlEvent{
+		&control.StreamBeginEvent{StreamId: 1},
+		&control.StreamEOFEvent{StreamId: 2},
+		&control.StreamDryEvent{StreamId: 3},
+		&control.SetBufferLengthEvent{StreamId: 4, BufferLength: 3000},
+		&control.StreamIsRecordedEvent{StreamId: 5},
+		&control.PingRequestEvent{Timestamp: 6},
+		&control.PingResponseEvent{Timestamp: 7},
+	} {
+		c, err := control.NewChunker().Chunk(e)
+		assert.Nil(t, err)
+		assert.Equal(t, byte(0x04), c.Header.MessageHeader.TypeId)
+
+		parsed, err := control.NewParser().Parse(c)
+		assert.Nil(t, err)
+
+		assert.Equal(t, e, parsed)
+	}
+}
+
+func TestUserControlEventsWriteTheirEventType(t *testing.T) {
+	buf := new(bytes.Buffer)
+	e := &control.SetBufferLengthEvent{StreamId: 1, BufferLength: 2}
+
+	err := e.Write(buf)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0x00, 0x03,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x02,
+	}, buf.Bytes())
+}
+
+func TestUserControlEventsRejectMismatchedEventTypes(t *testing.T) {
+	e := new(control.PingRequestEvent)
+
+	err := e.Read(bytes.NewReader([]byte{0x00, 0x07, 0x00, 0x00, 0x00, 0x01}))
+
+	assert.Equal(t, &control.MismatchedEventType{
+		Expected: control.PingRequest,
+		Actual:   control.PingResponse,
+	}, err)
+	assert.Equal(t, "control: expected event type 6, got 7", err.Error())
+}
@@ -19,29 +19,71 @@ func (e UnknownControlType) Error() string {
 	return fmt.Sprintf("control: unknown control type (%v)", byte(e))
 }
 
+// TrailingData is an Error representing a scenario where a strict Parser read a
+// control message carrying more data than its type holds. Its value is the
+// number of bytes left over.
+type TrailingData int
+
+var _ error = new(TrailingData)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e TrailingData) Error() string {
+	return fmt.Sprintf("control: %v bytes of trailing data", int(e))
+}
+
 // DefaultParser provides a default implementation of the Parser type.
 type DefaultParser struct {
 	// controls maps control sequence IDs to their respective reflect.Type
 	controls map[byte]reflect.Type
+	// events maps User Control Message event types to their respective
+	// reflect.Type
+	events map[EventType]reflect.Type
+	// lenient is true if the parser skips recoverable deviations from the
+	// specification (see SetStrict).
+	lenient bool
 }
 
 var _ Parser = new(DefaultParser)
 
 // NewParser returns a new instance of the Parser type (using the DefaultParser
-// implementation) initialized with the Controls variable.
+// implementation) initialized with the Controls and Events variables. It is
+// strict (see SetStrict).
 func NewParser() *DefaultParser {
 	p := &DefaultParser{
 		controls: make(map[byte]reflect.Type),
+		events:   make(map[EventType]reflect.Type),
 	}
 
 	for _, c := range Controls {
 		p.controls[c.TypeId()] = reflect.TypeOf(c).Elem()
 	}
 
+	for _, e := range Events {
+		p.events[e.EventType()] = reflect.TypeOf(e).Elem()
+	}
+
 	return p
 }
 
+// SetStrict sets whether the parser is strict, or lenient. Strict parsers return
+// a TrailingData error for any control message carrying more data than its type
+// holds. Lenient parsers instead skip the trailing data, favoring
+// interoperability with peers which send slightly malformed control messages
+// over correctness.
+//
+// SetStrict is not safe to call while the parser is in use.
+func (p *DefaultParser) SetStrict(strict bool) {
+	p.lenient = !strict
+}
+
 // Parse implements the Parse function as defined in the Parser interface.
+//
+// User Control Messages are first read as an *Event. If the event's type has
+// a matching UserControlEvent in the Events variable, that type is returned
+// instead.
+//
+// The returned Control copies what it needs out of the chunk's payload, so the
+// chunk may be released (see chunk.Chunk.Release) once it has been parsed.
 func (p *DefaultParser) Parse(chunk *chunk.Chunk) (Control, error) {
 	id := chunk.Header.MessageHeader.TypeId
 
@@ -50,11 +92,37 @@ func (p *DefaultParser) Parse(chunk *chunk.Chunk) (Control, error) {
 		return nil, UnknownControlType(id)
 	}
 
+	c, err := p.read(t, chunk.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if e, ok := c.(*Event); ok {
+		if t := p.EventTypeFor(e.Type); t != nil {
+			if c, err = p.read(t, chunk.Data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// read reads a new Control of the given type out of the given payload,
+// returning a TrailingData error if the parser is strict, and the payload holds
+// more than the Control.
+func (p *DefaultParser) read(t reflect.Type, payload []byte) (Control, error) {
+	buf := bytes.NewBuffer(payload)
+
 	c := reflect.New(t).Interface().(Control)
-	if err := c.Read(bytes.NewBuffer(chunk.Data)); err != nil {
+	if err := c.Read(buf); err != nil {
 		return nil, err
 	}
 
+	if !p.lenient && buf.Len() > 0 {
+		return nil, TrailingData(buf.Len())
+	}
+
 	return c, nil
 }
 
@@ -63,3 +131,10 @@ func (p *DefaultParser) Parse(chunk *chunk.Chunk) (Control, error) {
 func (p *DefaultParser) TypeFor(id byte) reflect.Type {
 	return p.controls[id]
 }
+
+// EventTypeFor returns the de-referenced reflect.Type associated with a given
+// User Control Message event type. If no matching type is found, nil is
+// returned instead.
+func (p *DefaultParser) EventTypeFor(typ EventType) reflect.Type {
+	return p.events[typ]
+}
@@ -63,3 +63,107 @@ func TestParsingForValidChunks(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, &control.Acknowledgement{n}, ctrl)
 }
+
+func TestParsingUnknownEventTypesReturnsAnEvent(t *testing.T) {
+	p := control.NewParser()
+
+	ctrl, err := p.Parse(&chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: 4},
+		},
+		Data: []byte{0x00, 0x1f, 0x01, 0x02},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &control.Event{
+		Type: control.EventType(0x1f),
+		Body: []byte{0x01, 0x02},
+	}, ctrl)
+}
+
+func TestEventTypeLookupForValidTypes(t *testing.T) {
+	p := control.NewParser()
+	expected := reflect.TypeOf(control.PingRequestEvent{})
+
+	typ := p.EventTypeFor(control.PingRequest)
+
+	assert.True(t, typ.AssignableTo(expected))
+}
+
+func TestParsingSetBufferLengthEvents(t *testing.T) {
+	p := control.NewParser()
+
+	ctrl, err := p.Parse(&chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: 4},
+		},
+		Data: []byte{
+			0x00, 0x03,
+			0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x0b, 0xb8,
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &control.SetBufferLengthEvent{
+		StreamId:     1,
+		BufferLength: 3000,
+	}, ctrl)
+}
+
+// TrailingAcknowledgement is an Acknowledgement chunk carrying two bytes more
+// than an Acknowledgement holds.
+var TrailingAcknowledgement = &chunk.Chunk{
+	Header: &chunk.Header{
+		MessageHeader: chunk.MessageHeader{TypeId: 3},
+	},
+	Data: []byte{0x00, 0x00, 0x00, 0x01, 0xff, 0xff},
+}
+
+func TestStrictParsersRejectTrailingData(t *testing.T) {
+	p := control.NewParser()
+
+	ctrl, err := p.Parse(TrailingAcknowledgement)
+
+	assert.Nil(t, ctrl)
+	assert.Equal(t, control.TrailingData(2), err)
+	assert.Equal(t, "control: 2 bytes of trailing data", err.Error())
+}
+
+func TestLenientParsersSkipTrailingData(t *testing.T) {
+	p := control.NewParser()
+	p.SetStrict(false)
+
+	ctrl, err := p.Parse(TrailingAcknowledgement)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &control.Acknowledgement{1}, ctrl)
+}
+
+func BenchmarkDefaultParserParse(b *testing.B) {
+	c, err := control.NewChunker().Chunk(&control.SetBufferLengthEvent{
+		StreamId: 1, BufferLength: 3000,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	r, w := chunk.Pipe()
+	defer r.Close()
+
+	for i := 0; i < b.N; i++ {
+		w.Write(c)
+	}
+
+	p := control.NewParser()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	go r.Recv()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Parse(<-r.Chunks()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
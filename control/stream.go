@@ -1,6 +1,20 @@
 package control
 
-import "github.com/WatchBeam/rtmp/chunk"
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/logging"
+)
+
+var (
+	// ErrPingTimeout is returned by Stream.Ping when no matching
+	// PingResponseEvent is received before the timeout elapses.
+	ErrPingTimeout = errors.New("rtmp/control: ping timed out")
+)
 
 // Stream represents an RTMP-compliant bi-directional transfer of RTMP control
 // sequences. It parses control sequences out of a chunk.Stream, and writes them
@@ -12,9 +26,59 @@ type Stream struct {
 	in     chan Control
 	errs   chan error
 	closer chan struct{}
+	// closeOnce ensures that closer is closed only once.
+	closeOnce sync.Once
+	// done is closed once the Recv goroutine has stopped.
+	done chan struct{}
+
+	// rmu guards running.
+	rmu sync.Mutex
+	// running is whether or not the Recv goroutine has been started.
+	running bool
 
 	parser  Parser
 	chunker Chunker
+
+	// ack keeps track of the bytes received from the peer, and determines
+	// when to send an Acknowledgement.
+	ack *Acknowledger
+	// bandwidth estimates the throughput at which the peer receives the
+	// bytes sent to it, from the timing of its Acknowledgements.
+	bandwidth *BandwidthEstimator
+
+	// epoch is the time at which this Stream was created, from which the
+	// timestamps of PingRequestEvents are measured.
+	epoch time.Time
+	// pmu guards pings
+	pmu sync.Mutex
+	// pings maps the timestamps of outstanding PingRequestEvents to the
+	// channel that is written to when their PingResponseEvent is received.
+	pings map[uint32]chan struct{}
+
+	// amu guards autoPong
+	amu sync.Mutex
+	// autoPong determines whether or not PingRequestEvents are answered
+	// automatically by Recv.
+	autoPong bool
+
+	// bmu guards bufferLengths and onBufferLength.
+	bmu sync.Mutex
+	// bufferLengths maps the ID of each message stream to the latest
+	// buffer length requested for it by a SetBufferLengthEvent.
+	bufferLengths map[uint32]time.Duration
+	// onBufferLength is called with each SetBufferLengthEvent received, if
+	// non-nil.
+	onBufferLength func(streamId uint32, length time.Duration)
+
+	// lmu guards log.
+	lmu sync.Mutex
+	// log is the Logger that internal events are reported to.
+	log logging.Logger
+
+	// xmu guards ctx.
+	xmu sync.Mutex
+	// ctx is the context of the connection that this Stream belongs to.
+	ctx context.Context
 }
 
 // NewStream returns a new instance of the Stream type initialized with the
@@ -29,9 +93,23 @@ func NewStream(chunks chunk.Stream, writer chunk.Writer,
 		in:     make(chan Control),
 		errs:   make(chan error),
 		closer: make(chan struct{}),
+		done:   make(chan struct{}),
 
 		parser:  parser,
 		chunker: chunker,
+
+		ack:       NewAcknowledger(),
+		bandwidth: NewBandwidthEstimator(),
+
+		epoch: time.Now(),
+		pings: make(map[uint32]chan struct{}),
+
+		autoPong: true,
+
+		bufferLengths: make(map[uint32]time.Duration),
+
+		log: logging.Noop,
+		ctx: context.Background(),
 	}
 }
 
@@ -42,8 +120,121 @@ func (s *Stream) In() <-chan Control { return s.in }
 // error is encountered in chunking or parsing.
 func (s *Stream) Errs() <-chan error { return s.errs }
 
-// Close stops the Recv goroutine.
-func (s *Stream) Close() { s.closer <- struct{}{} }
+// Close stops the Recv goroutine. If it is running, Close blocks until it has
+// stopped. Otherwise, Close returns immediately, and Recv stops as soon as it
+// is started. Calling Close more than once is a no-op.
+func (s *Stream) Close() {
+	s.closeOnce.Do(func() { close(s.closer) })
+
+	if s.isRunning() {
+		<-s.done
+	}
+}
+
+// CloseContext closes the Stream, as Close does, but blocks until the Recv
+// goroutine has stopped, even if it has not yet been started. If "ctx" is done
+// first (for instance, because Recv was never called), its error is returned
+// instead.
+func (s *Stream) CloseContext(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.closer) })
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isRunning returns whether or not the Recv goroutine has been started.
+func (s *Stream) isRunning() bool {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+
+	return s.running
+}
+
+// SetLogger sets the Logger that this Stream reports internal events to, such
+// as control chunks which could not be parsed and PingRequestEvents which could
+// not be answered. A nil Logger (the default) silences the Stream.
+func (s *Stream) SetLogger(l logging.Logger) {
+	s.lmu.Lock()
+	defer s.lmu.Unlock()
+
+	s.log = logging.OrNoop(l)
+}
+
+// logger returns the Logger set by SetLogger.
+func (s *Stream) logger() logging.Logger {
+	s.lmu.Lock()
+	defer s.lmu.Unlock()
+
+	return s.log
+}
+
+// SetContext sets the context of the connection that this Stream belongs to,
+// carrying request-scoped values (such as the identity of an authenticated
+// client) to the handlers of its control sequences. If the context is done, Recv
+// stops, as if Close had been called. It must be set before Recv is
+// started, and defaults to context.Background().
+func (s *Stream) SetContext(ctx context.Context) {
+	s.xmu.Lock()
+	defer s.xmu.Unlock()
+
+	s.ctx = ctx
+}
+
+// Context returns the context set by SetContext.
+func (s *Stream) Context() context.Context {
+	s.xmu.Lock()
+	defer s.xmu.Unlock()
+
+	return s.ctx
+}
+
+// SetParser sets the internal parser used by this Stream to parse incoming
+// control sequences. This method is _not_ safe to use between multiple
+// goroutines, and should be used with caution.
+func (s *Stream) SetParser(p Parser) { s.parser = p }
+
+// SetChunker sets the internal chunker used by this Stream to chunk outgoing
+// control sequences. This method is _not_ safe to use between multiple
+// goroutines, and should be used with caution.
+func (s *Stream) SetChunker(c Chunker) { s.chunker = c }
+
+// Acknowledger returns the *Acknowledger used by this Stream to determine when
+// to send Acknowledgements to the peer.
+func (s *Stream) Acknowledger() *Acknowledger { return s.ack }
+
+// Received records that `n` bytes have been received from the peer over the
+// connection that this Stream belongs to. If a full acknowledgement window of
+// bytes (as given by the peer in its Window Acknowledgement Size control
+// sequence) has been received since the last Acknowledgement, a new one is
+// sent, and any error encountered while sending it is returned.
+//
+// Received is typically passed as the notify function to a
+// chunk.CountingReader wrapping the connection.
+func (s *Stream) Received(n int) error {
+	if ack := s.ack.Received(n); ack != nil {
+		return s.Send(ack)
+	}
+
+	return nil
+}
+
+// BandwidthEstimator returns the *BandwidthEstimator used by this Stream to
+// estimate the throughput at which the peer receives the bytes sent to it.
+// Recv feeds it each Acknowledgement received, but the bytes sent must be
+// recorded with Sent.
+func (s *Stream) BandwidthEstimator() *BandwidthEstimator { return s.bandwidth }
+
+// Sent records that `n` bytes have been sent to the peer over the connection
+// that this Stream belongs to, so that they may be correlated with the
+// Acknowledgements that the peer sends in return (see BandwidthEstimator).
+//
+// Sent is typically called by an io.Writer wrapping the connection, as each
+// write to it completes.
+func (s *Stream) Sent(n int) { s.bandwidth.Sent(n) }
 
 // Send sends the given control "c", returning any errors that it encountered
 // along the way.
@@ -60,29 +251,249 @@ func (s *Stream) Send(c Control) error {
 	return nil
 }
 
+// SendPeerBandwidth sends a SetPeerBandwidth control sequence limiting the
+// output bandwidth of the peer to `size` bytes, using the given LimitType. It
+// is typically sent during connection setup, right after the peer connects.
+func (s *Stream) SendPeerBandwidth(size uint32, limit LimitType) error {
+	return s.Send(&SetPeerBandwidth{
+		AckWindowSize: size,
+		LimitType:     limit,
+	})
+}
+
+// SendAbort sends an AbortMessage informing the peer that the partially sent
+// message on the given chunk stream will not be completed.
+func (s *Stream) SendAbort(chunkStreamId uint32) error {
+	return s.Send(&AbortMessage{ChunkStreamId: chunkStreamId})
+}
+
+// SetAutoPong sets whether or not Recv automatically answers incoming
+// PingRequestEvents with a PingResponseEvent echoing their timestamp. When
+// enabled (the default), those PingRequestEvents are not passed along over
+// In(). When disabled, they are passed along like any other control sequence,
+// and it is up to the caller to respond.
+func (s *Stream) SetAutoPong(autoPong bool) {
+	s.amu.Lock()
+	defer s.amu.Unlock()
+
+	s.autoPong = autoPong
+}
+
+// AutoPong returns whether or not PingRequestEvents are answered automatically.
+func (s *Stream) AutoPong() bool {
+	s.amu.Lock()
+	defer s.amu.Unlock()
+
+	return s.autoPong
+}
+
+// BufferLength returns the buffer length most recently requested by the peer
+// for the message stream with the given ID (see SetBufferLengthEvent), and
+// whether or not one has been requested at all. Players send it to inform the
+// server of how much data they intend to buffer, which the server may use to
+// pace delivery, or decide how much to send up front.
+func (s *Stream) BufferLength(streamId uint32) (time.Duration, bool) {
+	s.bmu.Lock()
+	defer s.bmu.Unlock()
+
+	length, ok := s.bufferLengths[streamId]
+	return length, ok
+}
+
+// OnBufferLength sets a function called by Recv with the message stream ID and
+// buffer length of each SetBufferLengthEvent received, once BufferLength
+// reflects it. It runs within the Recv goroutine, so it should not block. If it
+// is nil (as it is by default), no function is called.
+//
+// SetBufferLengthEvents are passed along over In() regardless.
+func (s *Stream) OnBufferLength(fn func(streamId uint32, length time.Duration)) {
+	s.bmu.Lock()
+	defer s.bmu.Unlock()
+
+	s.onBufferLength = fn
+}
+
+// setBufferLength records the buffer length requested by the given
+// SetBufferLengthEvent, and calls the function set by OnBufferLength, if any.
+func (s *Stream) setBufferLength(e *SetBufferLengthEvent) {
+	length := time.Duration(e.BufferLength) * time.Millisecond
+
+	s.bmu.Lock()
+	s.bufferLengths[e.StreamId] = length
+	fn := s.onBufferLength
+	s.bmu.Unlock()
+
+	if fn != nil {
+		fn(e.StreamId, length)
+	}
+}
+
+// Ping sends a PingRequestEvent to the peer and waits for the corresponding
+// PingResponseEvent, returning the round-trip time. If no response is received
+// within the given timeout, ErrPingTimeout is returned instead.
+//
+// Ping relies on Recv running in order to receive the response.
+func (s *Stream) Ping(timeout time.Duration) (time.Duration, error) {
+	ts, pong := s.expectPong()
+	defer s.forgetPong(ts)
+
+	start := time.Now()
+	if err := s.Send(&PingRequestEvent{Timestamp: ts}); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-pong:
+		return time.Since(start), nil
+	case <-time.After(timeout):
+		return 0, ErrPingTimeout
+	}
+}
+
+// expectPong reserves a timestamp for a new PingRequestEvent, and returns it
+// along with the channel that is written to when its response is received.
+func (s *Stream) expectPong() (uint32, <-chan struct{}) {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+
+	ts := uint32(time.Since(s.epoch) / time.Millisecond)
+	for {
+		if _, ok := s.pings[ts]; !ok {
+			break
+		}
+		ts++
+	}
+
+	pong := make(chan struct{}, 1)
+	s.pings[ts] = pong
+
+	return ts, pong
+}
+
+// forgetPong stops waiting for the response to the PingRequestEvent sent with
+// the given timestamp.
+func (s *Stream) forgetPong(ts uint32) {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+
+	delete(s.pings, ts)
+}
+
+// pong notifies the caller of Ping waiting on the given timestamp, if any. It
+// returns whether or not such a caller existed.
+func (s *Stream) pong(ts uint32) bool {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+
+	pong, ok := s.pings[ts]
+	if !ok {
+		return false
+	}
+
+	delete(s.pings, ts)
+	pong <- struct{}{}
+
+	return true
+}
+
 // Recv processes input from all channels, as well as the incoming chunk
 // streams.
 //
+// Upon receiving a Window Acknowledgement Size control sequence, the window of
+// this Stream's Acknowledger is updated before the control sequence is passed
+// along, as is the BufferLength of the message stream named by a
+// SetBufferLengthEvent, and the estimate of the BandwidthEstimator is updated
+// with each Acknowledgement. PingResponseEvents answering a call to Ping are
+// consumed, and are not passed along. If AutoPong is enabled,
+// PingRequestEvents are answered and consumed as well.
+//
+// Each chunk is released (see chunk.Chunk.Release) once it has been parsed.
+// Recv returns once the Stream is closed, its context is done, or the channel
+// of incoming chunks is closed.
+//
 // Recv runs within its own goroutine.
 func (s *Stream) Recv() {
+	s.rmu.Lock()
+	s.running = true
+	s.rmu.Unlock()
+
 	defer func() {
 		close(s.in)
 		close(s.errs)
-		close(s.closer)
+		close(s.done)
 	}()
 
+	ctx := s.Context()
 	for {
 		select {
 		case <-s.closer:
 			return
-		case c := <-s.chunks.In():
+		case <-ctx.Done():
+			return
+		case c, ok := <-s.chunks.In():
+			if !ok {
+				return
+			}
+
 			control, err := s.parser.Parse(c)
+			c.Release()
 			if err != nil {
-				s.errs <- err
+				s.logger().Printf("rtmp/control: %v", err)
+				if !s.report(err) {
+					return
+				}
 				continue
 			}
 
-			s.in <- control
+			if w, ok := control.(*WindowAckSize); ok {
+				s.ack.SetWindow(w.WindowAckSize)
+			}
+
+			if a, ok := control.(*Acknowledgement); ok {
+				s.bandwidth.Acknowledged(a.SequenceNumber)
+			}
+
+			if b, ok := control.(*SetBufferLengthEvent); ok {
+				s.setBufferLength(b)
+			}
+
+			if p, ok := control.(*PingResponseEvent); ok && s.pong(p.Timestamp) {
+				continue
+			}
+
+			if p, ok := control.(*PingRequestEvent); ok && s.AutoPong() {
+				if err := s.Send(&PingResponseEvent{
+					Timestamp: p.Timestamp,
+				}); err != nil {
+					s.logger().Printf("rtmp/control: unable to answer ping: %v", err)
+					if !s.report(err) {
+						return
+					}
+				}
+				continue
+			}
+
+			select {
+			case s.in <- control:
+			case <-s.closer:
+				return
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
 }
+
+// report passes the given error along over the Errs() channel, returning
+// whether or not it was received before the Stream was closed, or its context
+// done.
+func (s *Stream) report(err error) bool {
+	select {
+	case s.errs <- err:
+		return true
+	case <-s.closer:
+		return false
+	case <-s.Context().Done():
+		return false
+	}
+}
@@ -2,11 +2,16 @@ package control_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"io"
 	"io/ioutil"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/chunk/chunktest"
 	"github.com/WatchBeam/rtmp/control"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -93,6 +98,36 @@ func TestWritingAControlChunksIt(t *testing.T) {
 	chunker.AssertExpectations(t)
 }
 
+func TestSetParserReplacesTheParser(t *testing.T) {
+	parser := &MockParser{}
+	parser.On("Parse", mock.Anything).Return(&control.Acknowledgement{}, nil)
+
+	stream := control.NewStream(newStreamWithChunk(2, TestChunk), nil,
+		control.NewParser(), nil)
+	stream.SetParser(parser)
+	go stream.Recv()
+
+	ctrl := <-stream.In()
+
+	assert.Equal(t, &control.Acknowledgement{}, ctrl)
+	parser.AssertExpectations(t)
+}
+
+func TestSetChunkerReplacesTheChunker(t *testing.T) {
+	ctrl := new(control.Acknowledgement)
+
+	chunker := &MockChunker{}
+	chunker.On("Chunk", ctrl).Return(TestChunk, nil)
+
+	w := chunktest.NewRecordingWriter()
+	stream := control.NewStream(nil, w, nil, control.NewChunker())
+	stream.SetChunker(chunker)
+
+	assert.Nil(t, stream.Send(ctrl))
+	assert.Equal(t, []*chunk.Chunk{TestChunk}, w.Chunks())
+	chunker.AssertExpectations(t)
+}
+
 func TestWritingAControlErrorsWhenErrored(t *testing.T) {
 	ctrl := new(control.Acknowledgement)
 
@@ -108,3 +143,338 @@ func TestWritingAControlErrorsWhenErrored(t *testing.T) {
 	assert.Equal(t, "test", err.Error())
 	chunker.AssertExpectations(t)
 }
+
+func TestWindowAckSizeConfiguresAcknowledgements(t *testing.T) {
+	chunker := control.NewChunker()
+	window, _ := chunker.Chunk(&control.WindowAckSize{WindowAckSize: 16})
+
+	buf := new(bytes.Buffer)
+	stream := control.NewStream(
+		newStreamWithChunk(2, window),
+		chunk.NewWriter(buf, chunk.DefaultReadSize),
+		control.NewParser(), chunker,
+	)
+	go stream.Recv()
+
+	<-stream.In()
+
+	assert.Nil(t, stream.Received(10))
+	assert.Empty(t, buf.Bytes())
+
+	assert.Nil(t, stream.Received(10))
+
+	ack, _ := chunker.Chunk(&control.Acknowledgement{SequenceNumber: 20})
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(ack)
+
+	assert.Equal(t, uint32(16), stream.Acknowledger().Window())
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestSendPeerBandwidthSendsTheControl(t *testing.T) {
+	buf := new(bytes.Buffer)
+	stream := control.NewStream(
+		newStreamWithChunk(2),
+		chunk.NewWriter(buf, chunk.DefaultReadSize),
+		nil, control.NewChunker(),
+	)
+
+	err := stream.SendPeerBandwidth(2500000, control.LimitTypeDynamic)
+
+	c, _ := control.NewChunker().Chunk(&control.SetPeerBandwidth{
+		AckWindowSize: 2500000,
+		LimitType:     control.LimitTypeDynamic,
+	})
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(c)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+// chanStream is a chunk.Stream whose chunks are pushed in by the test.
+type chanStream chan *chunk.Chunk
+
+func (s chanStream) In() <-chan *chunk.Chunk { return s }
+
+// answerPings reads PingRequestEvents written to "r", and answers each of
+// them by pushing a matching PingResponseEvent onto "in".
+func answerPings(r io.Reader, in chanStream) {
+	reader := chunk.NewReader(r, chunk.DefaultReadSize, chunk.NoopNormalizer)
+	go reader.Recv()
+
+	for c := range reader.Chunks() {
+		ctrl, err := control.NewParser().Parse(c)
+		if err != nil {
+			continue
+		}
+
+		if req, ok := ctrl.(*control.PingRequestEvent); ok {
+			res, _ := control.NewChunker().Chunk(
+				&control.PingResponseEvent{Timestamp: req.Timestamp})
+			in <- res
+		}
+	}
+}
+
+func TestPingCorrelatesThePingResponse(t *testing.T) {
+	r, w := io.Pipe()
+	in := make(chanStream)
+	go answerPings(r, in)
+
+	stream := control.NewStream(in,
+		chunk.NewWriter(w, chunk.DefaultReadSize),
+		control.NewParser(), control.NewChunker())
+	go stream.Recv()
+
+	rtt, err := stream.Ping(time.Second)
+
+	assert.Nil(t, err)
+	assert.True(t, rtt < time.Second)
+}
+
+func TestPingTimesOutWithoutAResponse(t *testing.T) {
+	stream := control.NewStream(make(chanStream),
+		chunk.NewWriter(ioutil.Discard, chunk.DefaultReadSize),
+		control.NewParser(), control.NewChunker())
+	go stream.Recv()
+
+	_, err := stream.Ping(10 * time.Millisecond)
+
+	assert.Equal(t, control.ErrPingTimeout, err)
+}
+
+func TestPingRequestsAreAnsweredAutomatically(t *testing.T) {
+	chunker := control.NewChunker()
+	req, _ := chunker.Chunk(&control.PingRequestEvent{Timestamp: 1234})
+	window, _ := chunker.Chunk(&control.WindowAckSize{WindowAckSize: 16})
+
+	buf := new(bytes.Buffer)
+	stream := control.NewStream(
+		newStreamWithChunk(2, req, window),
+		chunk.NewWriter(buf, chunk.DefaultReadSize),
+		control.NewParser(), chunker,
+	)
+	go stream.Recv()
+
+	ctrl := <-stream.In()
+
+	res, _ := chunker.Chunk(&control.PingResponseEvent{Timestamp: 1234})
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(res)
+
+	assert.True(t, stream.AutoPong())
+	assert.Equal(t, &control.WindowAckSize{WindowAckSize: 16}, ctrl)
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestPingRequestsArePassedAlongWithoutAutoPong(t *testing.T) {
+	req, _ := control.NewChunker().Chunk(
+		&control.PingRequestEvent{Timestamp: 1234})
+
+	buf := new(bytes.Buffer)
+	stream := control.NewStream(
+		newStreamWithChunk(2, req),
+		chunk.NewWriter(buf, chunk.DefaultReadSize),
+		control.NewParser(), control.NewChunker(),
+	)
+	stream.SetAutoPong(false)
+	go stream.Recv()
+
+	ctrl := <-stream.In()
+
+	assert.Equal(t, &control.PingRequestEvent{Timestamp: 1234}, ctrl)
+	assert.Empty(t, buf.Bytes())
+}
+
+func TestPingRequestsAreAnsweredFromAnotherGoroutine(t *testing.T) {
+	chunker := control.NewChunker()
+	req, _ := chunker.Chunk(&control.PingRequestEvent{Timestamp: 1234})
+
+	in := chunktest.NewFakeStream(1)
+	w := chunktest.NewRecordingWriter()
+	stream := control.NewStream(in, w, control.NewParser(), chunker)
+	go stream.Recv()
+
+	in.Send(req)
+
+	res, _ := chunker.Chunk(&control.PingResponseEvent{Timestamp: 1234})
+	assert.Equal(t, []*chunk.Chunk{res}, w.Wait(1, time.Second))
+}
+
+func TestSendAbortSendsTheControl(t *testing.T) {
+	buf := new(bytes.Buffer)
+	stream := control.NewStream(
+		newStreamWithChunk(2),
+		chunk.NewWriter(buf, chunk.DefaultReadSize),
+		nil, control.NewChunker(),
+	)
+
+	err := stream.SendAbort(18)
+
+	c, _ := control.NewChunker().Chunk(&control.AbortMessage{
+		ChunkStreamId: 18,
+	})
+	expected := new(bytes.Buffer)
+	chunk.NewWriter(expected, chunk.DefaultReadSize).Write(c)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestSendReturnsErrWriteTimeoutWhenThePeerStopsReading(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	w := chunk.NewWriter(conn, chunk.DefaultReadSize).(*chunk.DefaultWriter)
+	w.SetWriteTimeout(conn, 10*time.Millisecond)
+
+	s := control.NewStream(newStreamWithChunk(2), w,
+		control.NewParser(), control.NewChunker())
+
+	err := s.Send(control.NewSetChunkSize(4096))
+
+	assert.Equal(t, chunk.ErrWriteTimeout, err)
+}
+
+func TestStreamCloseIsIdempotent(t *testing.T) {
+	s := control.NewStream(chunktest.NewFakeStream(0), nil, nil, nil)
+	go s.Recv()
+
+	s.Close()
+	s.Close()
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestStreamCloseContextDoesNotWaitForUnreadControlsOrErrors(t *testing.T) {
+	for _, payload := range [][]byte{
+		{0x00, 0x10, 0x00, 0x00},
+		{0x00},
+	} {
+		in := chunktest.NewFakeStream(1)
+		s := control.NewStream(in, nil, control.NewParser(), nil)
+		go s.Recv()
+
+		in.Send(&chunk.Chunk{
+			Header: &chunk.Header{
+				BasicHeader: chunk.BasicHeader{0, 2},
+				MessageHeader: chunk.MessageHeader{
+					Length: uint32(len(payload)), TypeId: 5,
+				},
+			},
+			Data: payload,
+		})
+
+		// Give Recv time to block on passing the control, or error, along.
+		time.Sleep(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		assert.Nil(t, s.CloseContext(ctx))
+		cancel()
+	}
+}
+
+func TestStreamCloseBeforeRecvDoesNotBlock(t *testing.T) {
+	s := control.NewStream(chunktest.NewFakeStream(0), nil, nil, nil)
+
+	s.Close()
+	go s.Recv()
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestStreamCloseContextWaitsForRecvToStop(t *testing.T) {
+	s := control.NewStream(chunktest.NewFakeStream(0), nil, nil, nil)
+	go s.Recv()
+
+	assert.Nil(t, s.CloseContext(context.Background()))
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestStreamCloseContextTimesOutWithoutRecv(t *testing.T) {
+	s := control.NewStream(chunktest.NewFakeStream(0), nil, nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, s.CloseContext(ctx))
+}
+
+func TestStreamRecvStopsWhenTheContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := control.NewStream(chunktest.NewFakeStream(0), nil, nil, nil)
+	s.SetContext(ctx)
+	go s.Recv()
+
+	cancel()
+
+	_, ok := <-s.In()
+	assert.False(t, ok)
+}
+
+func TestSetBufferLengthEventsAreRecordedPerMessageStream(t *testing.T) {
+	chunker := control.NewChunker()
+	first, _ := chunker.Chunk(&control.SetBufferLengthEvent{
+		StreamId: 1, BufferLength: 3000})
+	second, _ := chunker.Chunk(&control.SetBufferLengthEvent{
+		StreamId: 2, BufferLength: 100})
+
+	type request struct {
+		streamId uint32
+		length   time.Duration
+	}
+	requests := make(chan request, 2)
+
+	in := chunktest.NewFakeStream(2)
+	stream := control.NewStream(in, chunktest.NewRecordingWriter(),
+		control.NewParser(), chunker)
+	stream.OnBufferLength(func(streamId uint32, length time.Duration) {
+		requests <- request{streamId, length}
+	})
+	go stream.Recv()
+	defer stream.Close()
+
+	_, ok := stream.BufferLength(1)
+	assert.False(t, ok)
+
+	in.Send(first, second)
+	<-stream.In()
+	<-stream.In()
+
+	assert.Equal(t, request{1, 3 * time.Second}, <-requests)
+	assert.Equal(t, request{2, 100 * time.Millisecond}, <-requests)
+
+	length, ok := stream.BufferLength(1)
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, length)
+
+	length, ok = stream.BufferLength(2)
+	assert.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, length)
+}
+
+func TestAcknowledgementsUpdateTheBandwidthEstimate(t *testing.T) {
+	chunker := control.NewChunker()
+	ack, _ := chunker.Chunk(&control.Acknowledgement{SequenceNumber: 1000})
+
+	in := chunktest.NewFakeStream(2)
+	stream := control.NewStream(in, chunktest.NewRecordingWriter(),
+		control.NewParser(), chunker)
+	go stream.Recv()
+	defer stream.Close()
+
+	stream.Sent(1000)
+	time.Sleep(10 * time.Millisecond)
+
+	in.Send(ack)
+
+	assert.Equal(t, &control.Acknowledgement{SequenceNumber: 1000},
+		<-stream.In())
+	assert.True(t, stream.BandwidthEstimator().Kbps() > 0)
+}
@@ -0,0 +1,211 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/WatchBeam/rtmp/spec"
+)
+
+var (
+	ErrInvalidSignature = errors.New("rtmp/flv: invalid FLV signature")
+	ErrEncryptedTag     = errors.New("rtmp/flv: encrypted tags are unsupported")
+)
+
+// InvalidDataOffset is returned when the data offset in an FLV header is
+// shorter than the header itself.
+type InvalidDataOffset uint32
+
+var _ error = new(InvalidDataOffset)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e InvalidDataOffset) Error() string {
+	return fmt.Sprintf("rtmp/flv: invalid data offset %v", uint32(e))
+}
+
+// MismatchedTagSize is returned when the previous-tag-size field following a
+// tag does not match the size of the tag that was read.
+type MismatchedTagSize struct {
+	// Offset is the offset of the tag within the FLV file.
+	Offset int64
+	// Expected is the size of the tag that was read, including its header.
+	Expected uint32
+	// Actual is the size in the previous-tag-size field.
+	Actual uint32
+}
+
+var _ error = new(MismatchedTagSize)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e *MismatchedTagSize) Error() string {
+	return fmt.Sprintf(
+		"rtmp/flv: tag at offset %v has size %v, but previous-tag-size is %v",
+		e.Offset, e.Expected, e.Actual)
+}
+
+// FLVReader reads Data frames from an FLV file, such as one written by the
+// FLVWriter. Each frame is timestamped with the timestamp of the tag that it
+// was read from, and script tags are returned as "@setDataFrame" data frames,
+// so that recordings can be replayed as if they were sent over RTMP.
+type FLVReader struct {
+	// src is the io.Reader that the FLV file is read from.
+	src io.Reader
+	// parser is the data.Parser used to turn tags into Data.
+	parser data.Parser
+	// readHeader is whether or not the FLV header has been read yet.
+	readHeader bool
+	// offset is the number of bytes read from src.
+	offset int64
+}
+
+// NewFLVReader returns a new *FLVReader which reads from the given io.Reader.
+func NewFLVReader(src io.Reader) *FLVReader {
+	return &FLVReader{
+		src:    src,
+		parser: data.DefaultParser,
+	}
+}
+
+// Read reads the next tag from the FLV file and returns it as Data, reading
+// and validating the FLV header first if it has not yet been read. When there
+// are no more tags, io.EOF is returned. If the file ends within a tag,
+// io.ErrUnexpectedEOF is returned instead.
+func (r *FLVReader) Read() (data.Data, error) {
+	if err := r.readFileHeader(); err != nil {
+		return nil, err
+	}
+
+	offset := r.offset
+
+	h, err := r.read(int(TagHeaderLength))
+	if err != nil {
+		return nil, err
+	}
+
+	if h[0]&0x20 != 0 {
+		return nil, ErrEncryptedTag
+	}
+
+	var (
+		typ  = h[0] & 0x1f
+		size = uint32(h[1])<<16 | uint32(h[2])<<8 | uint32(h[3])
+		ts   = uint32(h[7])<<24 | uint32(h[4])<<16 | uint32(h[5])<<8 |
+			uint32(h[6])
+	)
+
+	payload, err := r.readFull(int(size))
+	if err != nil {
+		return nil, err
+	}
+
+	trailer, err := r.readFull(4)
+	if err != nil {
+		return nil, err
+	}
+
+	if prev := spec.Uint32(trailer); prev != TagHeaderLength+size {
+		return nil, &MismatchedTagSize{
+			Offset:   offset,
+			Expected: TagHeaderLength + size,
+			Actual:   prev,
+		}
+	}
+
+	if typ == data.DataFrameTypeId {
+		payload = withDataFrameHeader(payload)
+	}
+
+	header := &chunk.Header{
+		MessageHeader: chunk.MessageHeader{
+			Length:   uint32(len(payload)),
+			TypeId:   typ,
+			StreamId: 1,
+		},
+	}
+	header.SetTimestamp(ts)
+
+	d, err := r.parser.Parse(&chunk.Chunk{
+		Header: header,
+		Data:   payload,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rtmp/flv: malformed tag at offset %v: %v",
+			offset, err)
+	}
+
+	return d, nil
+}
+
+// readFileHeader reads and validates the FLV header, along with the first
+// previous-tag-size field, if they have not yet been read.
+func (r *FLVReader) readFileHeader() error {
+	if r.readHeader {
+		return nil
+	}
+
+	h, err := r.readFull(int(HeaderLength))
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h[:3], Signature) {
+		return ErrInvalidSignature
+	}
+
+	offset := spec.Uint32(h[5:9])
+	if offset < HeaderLength {
+		return InvalidDataOffset(offset)
+	}
+
+	// Skip any data between the header and the first previous-tag-size,
+	// as well as the first previous-tag-size itself, which is always
+	// zero.
+	if _, err := r.readFull(int(offset-HeaderLength) + 4); err != nil {
+		return err
+	}
+
+	r.readHeader = true
+
+	return nil
+}
+
+// read reads exactly n bytes from the source, returning io.EOF only if no
+// bytes were read.
+func (r *FLVReader) read(n int) ([]byte, error) {
+	b := make([]byte, n)
+
+	read, err := io.ReadFull(r.src, b)
+	r.offset += int64(read)
+
+	return b, err
+}
+
+// readFull reads exactly n bytes from the source, returning
+// io.ErrUnexpectedEOF if fewer bytes were read.
+func (r *FLVReader) readFull(n int) ([]byte, error) {
+	b, err := r.read(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return b, err
+}
+
+// withDataFrameHeader returns the given script tag body prefixed with the
+// amf0-encoded "@setDataFrame" keyword, unless it is already present.
+func withDataFrameHeader(payload []byte) []byte {
+	header := new(bytes.Buffer)
+	header.WriteByte(0x02)
+	spec.PutUint16(uint16(len(data.SetDataFrameHeader)), header)
+	header.WriteString(data.SetDataFrameHeader)
+
+	if bytes.HasPrefix(payload, header.Bytes()) {
+		return payload
+	}
+
+	return append(header.Bytes(), payload...)
+}
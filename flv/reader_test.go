@@ -0,0 +1,134 @@
+package flv_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/WatchBeam/rtmp/flv"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRecording(t *testing.T, ds ...data.Data) []byte {
+	buf := new(bytes.Buffer)
+	w := flv.NewFLVWriter(buf)
+	for _, d := range ds {
+		assert.Nil(t, w.WriteData(d))
+	}
+	assert.Nil(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestNewFLVReaderConstructsFLVReaders(t *testing.T) {
+	r := flv.NewFLVReader(new(bytes.Buffer))
+
+	assert.IsType(t, new(flv.FLVReader), r)
+}
+
+func TestFLVReaderReadsRecordings(t *testing.T) {
+	r := flv.NewFLVReader(bytes.NewReader(newRecording(t,
+		newMetadata(),
+		newVideo(1000, []byte{0x17, 0x01, 0x00, 0x00, 0x00, 0x65}),
+		newAudio(1040, []byte{0xaf, 0x01, 0x05}),
+	)))
+
+	d, err := r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, data.ScriptKind, d.Kind())
+	assert.Equal(t, data.SetDataFrameHeader, d.(*data.DataFrame).Header)
+	assert.Equal(t, data.OnMetaDataType, d.(*data.DataFrame).Type)
+
+	d, err = r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, data.VideoKind, d.Kind())
+	c, _ := d.Marshal()
+	assert.Equal(t, uint32(0), c.Header.Timestamp())
+	assert.Equal(t, []byte{0x17, 0x01, 0x00, 0x00, 0x00, 0x65}, c.Data)
+
+	d, err = r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, data.AudioKind, d.Kind())
+	c, _ = d.Marshal()
+	assert.Equal(t, uint32(40), c.Header.Timestamp())
+	assert.Equal(t, []byte{0xaf, 0x01, 0x05}, c.Data)
+
+	d, err = r.Read()
+	assert.Nil(t, d)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFLVReaderReadsExtendedTimestamps(t *testing.T) {
+	r := flv.NewFLVReader(bytes.NewReader(newRecording(t,
+		newAudio(0, []byte{0xaf, 0x01}),
+		newAudio(0x01020304, []byte{0xaf, 0x01}),
+	)))
+
+	r.Read()
+	d, err := r.Read()
+	assert.Nil(t, err)
+
+	c, _ := d.Marshal()
+	assert.Equal(t, uint32(0x01020304), c.Header.Timestamp())
+}
+
+func TestFLVReaderErrsOnInvalidSignatures(t *testing.T) {
+	b := newRecording(t)
+	b[0] = 'X'
+
+	d, err := flv.NewFLVReader(bytes.NewReader(b)).Read()
+
+	assert.Nil(t, d)
+	assert.Equal(t, flv.ErrInvalidSignature, err)
+}
+
+func TestFLVReaderErrsOnInvalidDataOffsets(t *testing.T) {
+	b := newRecording(t)
+	b[8] = 0x08
+
+	_, err := flv.NewFLVReader(bytes.NewReader(b)).Read()
+
+	assert.Equal(t, "rtmp/flv: invalid data offset 8", err.Error())
+}
+
+func TestFLVReaderErrsOnMismatchedTagSizes(t *testing.T) {
+	b := newRecording(t, newAudio(0, []byte{0xaf, 0x01}))
+	b[len(b)-1] = 0x0c
+
+	_, err := flv.NewFLVReader(bytes.NewReader(b)).Read()
+
+	assert.Equal(t, &flv.MismatchedTagSize{
+		Offset:   13,
+		Expected: 13,
+		Actual:   12,
+	}, err)
+}
+
+func TestFLVReaderErrsOnTruncatedTags(t *testing.T) {
+	b := newRecording(t, newAudio(0, []byte{0xaf, 0x01}))
+
+	_, err := flv.NewFLVReader(bytes.NewReader(b[:len(b)-6])).Read()
+
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestFLVReaderErrsOnEncryptedTags(t *testing.T) {
+	b := newRecording(t, newAudio(0, []byte{0xaf, 0x01}))
+	b[len(Header)] |= 0x20
+
+	_, err := flv.NewFLVReader(bytes.NewReader(b)).Read()
+
+	assert.Equal(t, flv.ErrEncryptedTag, err)
+}
+
+func TestFLVReaderErrsOnMalformedTags(t *testing.T) {
+	b := newRecording(t, newAudio(0, []byte{0xaf, 0x01}))
+	b[len(Header)] = 0x07
+
+	_, err := flv.NewFLVReader(bytes.NewReader(b)).Read()
+
+	assert.Equal(t,
+		"rtmp/flv: malformed tag at offset 13: rtmp/data: unknown type ID 7",
+		err.Error())
+}
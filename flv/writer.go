@@ -0,0 +1,277 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+
+	amf "github.com/WatchBeam/rtmp/amf0"
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/WatchBeam/rtmp/spec"
+)
+
+const (
+	// Version is the version of the FLV format written by the FLVWriter.
+	Version byte = 0x01
+
+	// HeaderLength is the length of the FLV file header, in bytes.
+	HeaderLength uint32 = 9
+	// TagHeaderLength is the length of the header preceding each FLV tag,
+	// in bytes.
+	TagHeaderLength uint32 = 11
+
+	// AudioFlag and VideoFlag are set in the FLV header to indicate that
+	// audio and video tags are present in the file.
+	AudioFlag byte = 0x04
+	VideoFlag byte = 0x01
+)
+
+var (
+	// Signature is the three byte signature that every FLV file begins
+	// with.
+	Signature = []byte("FLV")
+
+	ErrClosed = errors.New("rtmp/flv: writer closed")
+)
+
+// FLVWriter writes Data frames to an io.Writer as an FLV file. The FLV header
+// is written before the first tag, and each tag is followed by its
+// previous-tag-size field. Tag timestamps are made relative to the first
+// audio or video tag written, so that recordings always start at zero. Tags
+// timestamped before the first are written at zero.
+//
+// "@setDataFrame" data frames are written as script tags without the
+// "@setDataFrame" keyword, so that "onMetaData" is written as FLV players
+// expect.
+type FLVWriter struct {
+	// mu guards the fields below it.
+	mu sync.Mutex
+	// dest is the io.Writer that the FLV file is written to.
+	dest io.Writer
+	// wroteHeader is whether or not the FLV header has been written yet.
+	wroteHeader bool
+	// hasBase is whether or not base has been set by the first audio or
+	// video tag written.
+	hasBase bool
+	// base is the absolute timestamp of the first audio or video tag.
+	base uint32
+	// last is the relative timestamp of the last tag written.
+	last uint32
+	// closed is whether or not Close has been called.
+	closed bool
+}
+
+var _ data.Recorder = new(FLVWriter)
+
+// NewFLVWriter returns a new *FLVWriter which writes to the given io.Writer.
+func NewFLVWriter(dest io.Writer) *FLVWriter {
+	return &FLVWriter{dest: dest}
+}
+
+// WriteData writes the given Data as a single FLV tag, writing the FLV header
+// first if it has not yet been written. Audio and video tags are timestamped
+// with the timestamp of the chunk they were read from, and script tags are
+// timestamped with the timestamp of the last tag written.
+func (w *FLVWriter) WriteData(d data.Data) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	var (
+		payload []byte
+		ts      = w.last
+	)
+
+	switch t := d.(type) {
+	case *data.DataFrame:
+		p, err := marshalScript(t)
+		if err != nil {
+			return err
+		}
+
+		payload = p
+	default:
+		c, err := d.Marshal()
+		if err != nil {
+			return err
+		}
+
+		payload = c.Data
+		if c.Header != nil {
+			ts = w.relative(c.Header.Timestamp())
+		}
+	}
+
+	if err := w.writeTag(d.Id(), ts, payload); err != nil {
+		return err
+	}
+
+	w.last = ts
+
+	return nil
+}
+
+// Consume writes every Data received over the given channel, such as the one
+// returned by data.Stream.In(), until it is closed or a write fails.
+func (w *FLVWriter) Consume(in <-chan data.Data) error {
+	for d := range in {
+		if err := w.WriteData(d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Record implements data.Recorder.Record, so that a stream may be recorded to
+// an FLV file by a data.Tee. Audio, video, and script data messages are parsed
+// with data.DefaultParser and written as by WriteData, and aggregate messages
+// are split into their sub-messages first (see data.SplitAggregate). Other
+// messages, such as commands, are not part of an FLV file, and are ignored.
+func (w *FLVWriter) Record(c *chunk.Chunk) error {
+	if c.Header == nil {
+		return nil
+	}
+
+	chunks := []*chunk.Chunk{c}
+	if c.TypeId() == data.AggregateTypeId {
+		var err error
+		if chunks, err = data.SplitAggregate(c); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range chunks {
+		if data.DefaultParser.New(c.TypeId()) == nil {
+			continue
+		}
+
+		d, err := data.DefaultParser.Parse(c)
+		if err != nil {
+			return err
+		}
+
+		if err = w.WriteData(d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close writes the FLV header if no tags were written, so that the output is
+// always a valid FLV file, and then closes the underlying io.Writer if it is
+// an io.Closer. Subsequent calls to WriteData return ErrClosed.
+func (w *FLVWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	if c, ok := w.dest.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// writeHeader writes the FLV header and the first previous-tag-size field,
+// if they have not yet been written.
+func (w *FLVWriter) writeHeader() error {
+	if w.wroteHeader {
+		return nil
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(Signature)
+	buf.WriteByte(Version)
+	buf.WriteByte(AudioFlag | VideoFlag)
+	spec.PutUint32(HeaderLength, buf)
+	spec.PutUint32(0, buf)
+
+	if _, err := w.dest.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	w.wroteHeader = true
+
+	return nil
+}
+
+// writeTag writes a single FLV tag of the given type, followed by its
+// previous-tag-size field.
+func (w *FLVWriter) writeTag(typ byte, ts uint32, payload []byte) error {
+	size := uint32(len(payload))
+
+	buf := bytes.NewBuffer(make([]byte, 0, TagHeaderLength+size+4))
+	buf.WriteByte(typ)
+	spec.PutUint24(size, buf)
+	spec.PutUint24(ts&0xffffff, buf)
+	buf.WriteByte(byte(ts >> 24))
+	spec.PutUint24(0, buf)
+	buf.Write(payload)
+	spec.PutUint32(TagHeaderLength+size, buf)
+
+	_, err := w.dest.Write(buf.Bytes())
+	return err
+}
+
+// relative returns the given absolute timestamp relative to the first audio
+// or video tag written. Timestamps earlier than that of the first tag, such as
+// those of audio trailing the first video frame, are clamped to zero.
+func (w *FLVWriter) relative(ts uint32) uint32 {
+	if !w.hasBase {
+		w.base = ts
+		w.hasBase = true
+	}
+
+	if ts < w.base {
+		return 0
+	}
+
+	return ts - w.base
+}
+
+// marshalScript marshals the given DataFrame as the body of an FLV script
+// tag, omitting the "@setDataFrame" keyword when present. It is otherwise
+// encoded as DataFrame.Marshal encodes it.
+func marshalScript(d *data.DataFrame) ([]byte, error) {
+	if d.Header != data.SetDataFrameHeader {
+		c, err := d.Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		return c.Data, nil
+	}
+
+	buf := new(bytes.Buffer)
+	enc := amf.NewEncoder(buf)
+
+	if err := enc.Encode(d.Type); err != nil {
+		return nil, err
+	}
+	if d.Arguments != nil {
+		if err := enc.Encode(d.Arguments); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
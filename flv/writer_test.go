@@ -0,0 +1,186 @@
+package flv_test
+
+import (
+	"bytes"
+	"testing"
+
+	amf "github.com/WatchBeam/rtmp/amf0"
+	"github.com/WatchBeam/rtmp/chunk"
+	"github.com/WatchBeam/rtmp/cmd/data"
+	"github.com/WatchBeam/rtmp/flv"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// Header is the FLV header, followed by the first previous-tag-size.
+	Header = []byte{
+		0x46, 0x4c, 0x56, 0x01, 0x05, 0x00, 0x00, 0x00, 0x09,
+		0x00, 0x00, 0x00, 0x00,
+	}
+
+	// OnMetaData is the body of an "onMetaData" script tag with a single
+	// "width" property.
+	OnMetaData = []byte{
+		0x02, 0x00, 0x0a, 0x6f, 0x6e, 0x4d, 0x65, 0x74, 0x61, 0x44,
+		0x61, 0x74, 0x61, 0x08, 0x00, 0x00, 0x00, 0x01, 0x00, 0x05,
+		0x77, 0x69, 0x64, 0x74, 0x68, 0x00, 0x40, 0x94, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x09,
+	}
+)
+
+type closingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closingBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func newVideo(ts uint32, payload []byte) data.Data {
+	return newData(new(data.Video), data.VideoTypeId, ts, payload)
+}
+
+func newAudio(ts uint32, payload []byte) data.Data {
+	return newData(new(data.Audio), data.AudioTypeId, ts, payload)
+}
+
+func newData(d data.Data, typeId byte, ts uint32, payload []byte) data.Data {
+	h := &chunk.Header{
+		MessageHeader: chunk.MessageHeader{
+			Length: uint32(len(payload)),
+			TypeId: typeId,
+		},
+	}
+	h.SetTimestamp(ts)
+
+	d.Read(&chunk.Chunk{Header: h, Data: payload})
+
+	return d
+}
+
+func newMetadata() data.Data {
+	return &data.DataFrame{
+		Header:    data.SetDataFrameHeader,
+		Type:      data.OnMetaDataType,
+		Arguments: amf.ECMAArray{"width": float64(1280)},
+	}
+}
+
+func TestNewFLVWriterConstructsFLVWriters(t *testing.T) {
+	w := flv.NewFLVWriter(new(bytes.Buffer))
+
+	assert.IsType(t, new(flv.FLVWriter), w)
+}
+
+func TestFLVWriterWritesTags(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := flv.NewFLVWriter(buf)
+
+	assert.Nil(t, w.WriteData(newMetadata()))
+	assert.Nil(t, w.WriteData(newVideo(1000, []byte{0x17, 0x01, 0x00, 0x00, 0x00, 0x65})))
+	assert.Nil(t, w.WriteData(newAudio(1040, []byte{0xaf, 0x01, 0x05})))
+
+	expected := append([]byte{}, Header...)
+	// onMetaData, without "@setDataFrame".
+	expected = append(expected, 0x12, 0x00, 0x00, 0x25, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00)
+	expected = append(expected, OnMetaData...)
+	expected = append(expected, 0x00, 0x00, 0x00, 0x30)
+	// Video at a relative timestamp of 0ms.
+	expected = append(expected, 0x09, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x17, 0x01, 0x00, 0x00, 0x00, 0x65, 0x00,
+		0x00, 0x00, 0x11)
+	// Audio at a relative timestamp of 40ms.
+	expected = append(expected, 0x08, 0x00, 0x00, 0x03, 0x00, 0x00, 0x28,
+		0x00, 0x00, 0x00, 0x00, 0xaf, 0x01, 0x05, 0x00, 0x00, 0x00, 0x0e)
+
+	assert.Equal(t, expected, buf.Bytes())
+}
+
+func TestFLVWriterWritesExtendedTimestamps(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := flv.NewFLVWriter(buf)
+
+	assert.Nil(t, w.WriteData(newAudio(0, []byte{0xaf, 0x01})))
+	assert.Nil(t, w.WriteData(newAudio(0x01020304, []byte{0xaf, 0x01})))
+
+	tag := buf.Bytes()[len(Header)+11+2+4:]
+
+	assert.Equal(t, []byte{0x02, 0x03, 0x04, 0x01}, tag[4:8])
+}
+
+func TestFLVWriterClampsTimestampsEarlierThanTheFirstTag(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := flv.NewFLVWriter(buf)
+
+	assert.Nil(t, w.WriteData(newVideo(1000, []byte{0x17, 0x01})))
+	assert.Nil(t, w.WriteData(newAudio(990, []byte{0xaf, 0x01})))
+
+	tag := buf.Bytes()[len(Header)+11+2+4:]
+
+	assert.Equal(t, byte(0x08), tag[0])
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x00}, tag[4:8])
+}
+
+func TestFLVWriterWritesTheHeaderOnCloseWhenEmpty(t *testing.T) {
+	buf := new(closingBuffer)
+	w := flv.NewFLVWriter(buf)
+
+	assert.Nil(t, w.Close())
+	assert.Equal(t, Header, buf.Bytes())
+	assert.True(t, buf.closed)
+}
+
+func TestFLVWriterErrsAfterBeingClosed(t *testing.T) {
+	w := flv.NewFLVWriter(new(bytes.Buffer))
+	w.Close()
+
+	assert.Equal(t, flv.ErrClosed, w.WriteData(newAudio(0, []byte{0xaf})))
+	assert.Equal(t, flv.ErrClosed, w.Close())
+}
+
+func TestFLVWriterConsumesChannels(t *testing.T) {
+	in := make(chan data.Data, 2)
+	in <- newVideo(0, []byte{0x17, 0x01})
+	in <- newAudio(0, []byte{0xaf, 0x01})
+	close(in)
+
+	buf := new(bytes.Buffer)
+	w := flv.NewFLVWriter(buf)
+
+	assert.Nil(t, w.Consume(in))
+	assert.Len(t, buf.Bytes(), len(Header)+2*(11+2+4))
+}
+
+func TestFLVWriterRecordsMediaChunks(t *testing.T) {
+	video, _ := newVideo(1000, []byte{0x17, 0x01}).Marshal()
+	audio, _ := newAudio(1040, []byte{0xaf, 0x01}).Marshal()
+
+	buf := new(bytes.Buffer)
+	w := flv.NewFLVWriter(buf)
+
+	assert.Nil(t, w.Record(data.PackAggregate(video, audio)))
+
+	expected := new(bytes.Buffer)
+	ew := flv.NewFLVWriter(expected)
+	ew.WriteData(newVideo(1000, []byte{0x17, 0x01}))
+	ew.WriteData(newAudio(1040, []byte{0xaf, 0x01}))
+
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+}
+
+func TestFLVWriterDoesNotRecordOtherMessages(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := flv.NewFLVWriter(buf)
+
+	err := w.Record(&chunk.Chunk{
+		Header: &chunk.Header{
+			MessageHeader: chunk.MessageHeader{TypeId: 0x14},
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Empty(t, buf.Bytes())
+}
@@ -9,9 +9,21 @@ import (
 // sequence sent by the client during a RTMP handshake. It is responsible for
 // reading and responding to the C1 packet (with the C2 packet), and sending the
 // server challenge in S1.
+//
+// If C1 carries a valid digest, the "complex" handshake is preformed instead:
+// S1 carries a digest of its own, and S2 is signed with a key derived from the
+// digest in C1.
 type ClientAckSequence struct {
 	C1 *AckPacket
 	S1 *AckPacket
+
+	// digest is the digest found in C1, or nil if the client preformed
+	// the simple handshake.
+	digest []byte
+	// scheme is the DigestScheme that digest was found with.
+	scheme DigestScheme
+	// s1Digest is the digest embedded in S1, if any.
+	s1Digest []byte
 }
 
 var _ Sequence = new(ClientAckSequence)
@@ -33,21 +45,42 @@ func NewClientAckSequence() *ClientAckSequence {
 // Read implements the Sequence.Read function. It reads the C1 packet and
 // returns any read error, if there was one. Otherwise, a value of "nil" is
 // returned instead.
+//
+// If C1 advertises a version and carries a valid digest, then the complex
+// handshake will be preformed.
 func (c *ClientAckSequence) Read(r io.Reader) error {
 	if err := c.C1.Read(r); err != nil {
 		return err
 	}
 
+	if c.C1.Time2 != 0 {
+		if digest, scheme, ok := c.C1.FindDigest(clientKey); ok {
+			c.digest = digest
+			c.scheme = scheme
+		}
+	}
+
 	return nil
 }
 
+// Complex returns whether or not the client initiated the complex handshake.
+func (c *ClientAckSequence) Complex() bool { return c.digest != nil }
+
 // WriteTo implements the Sequence.WriteTo function. It writes the S1 packet
 // first (returning any errors if there is one), and then writes the S2 packet
 // with the same data as was sent in the C1 packet (returning any error that was
 // encountered).
 //
+// During the complex handshake, S1 is signed instead, and S2 is a response to
+// the digest in C1.
+//
 // A successful call to Write constitutes a value of `nil` being returned.
 func (c *ClientAckSequence) WriteTo(w io.Writer) error {
+	if c.Complex() {
+		c.S1.Time2 = ServerVersion
+		c.s1Digest = c.S1.Sign(c.scheme, serverKey)
+	}
+
 	if err := c.S1.Write(w); err != nil {
 		return err
 	}
@@ -56,6 +89,9 @@ func (c *ClientAckSequence) WriteTo(w io.Writer) error {
 		Time1:   c.C1.Time1,
 		Payload: c.C1.Payload,
 	}
+	if c.Complex() {
+		s2 = newResponse(c.digest, GenuineFMSKey)
+	}
 
 	if err := s2.Write(w); err != nil {
 		return err
@@ -66,5 +102,8 @@ func (c *ClientAckSequence) WriteTo(w io.Writer) error {
 
 // Nex implements the Sequence.Next function.
 func (c *ClientAckSequence) Next() Sequence {
-	return NewServerAckSequence(c.S1)
+	s := NewServerAckSequence(c.S1)
+	s.Digest = c.s1Digest
+
+	return s
 }
@@ -0,0 +1,157 @@
+package handshake
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// InvalidDigestErr is returned when S1 advertises a server version,
+	// but does not carry a valid digest.
+	InvalidDigestErr = errors.New("rtmp/handshake: invalid digest")
+)
+
+// InitiateSequence is the first sequence of the client side of the RTMP
+// handshake. It reads nothing, and writes the C0 and C1 packets, where C1 is
+// signed for the complex handshake.
+type InitiateSequence struct {
+	// Version is the RTMP version written in C0.
+	Version byte
+	// C1 is the challenge sent to the server.
+	C1 *AckPacket
+
+	// digest is the digest embedded in C1.
+	digest []byte
+}
+
+var _ Sequence = new(InitiateSequence)
+
+// NewInitiateSequence returns a new *InitiateSequence, initialized with the
+// SupportedRTMPVersion and a C1 packet advertising the ClientVersion.
+func NewInitiateSequence() *InitiateSequence {
+	return &InitiateSequence{
+		Version: SupportedRTMPVersion,
+		C1:      &AckPacket{Time2: ClientVersion},
+	}
+}
+
+// Read implements the Sequence.Read function. Since the client speaks first,
+// there is nothing to read, and a value of nil is always returned.
+func (i *InitiateSequence) Read(r io.Reader) error { return nil }
+
+// WriteTo implements the Sequence.WriteTo function. It signs and writes C1,
+// preceded by the version byte (C0).
+func (i *InitiateSequence) WriteTo(w io.Writer) error {
+	i.digest = i.C1.Sign(DigestFirstScheme, clientKey)
+
+	if _, err := w.Write([]byte{i.Version}); err != nil {
+		return err
+	}
+
+	return i.C1.Write(w)
+}
+
+// Next implements the Sequence.Next function.
+func (i *InitiateSequence) Next() Sequence {
+	return NewServerChallengeSequence(i.Version, i.C1, i.digest)
+}
+
+// ServerChallengeSequence is the second and final sequence of the client side
+// of the RTMP handshake. It reads and verifies the S0, S1, and S2 packets, and
+// then acknowledges S1 with C2.
+//
+// If S1 advertises a server version, then it must carry a valid digest, and
+// C2 is written as a response to it. Otherwise, C2 echoes S1.
+type ServerChallengeSequence struct {
+	// Version is the RTMP version that S0 must match.
+	Version byte
+	// C1 is the packet which S2 should acknowledge.
+	C1 *AckPacket
+	// Digest is the digest embedded in C1.
+	Digest []byte
+	// S1 is the challenge read from the server.
+	S1 *AckPacket
+
+	// s1Digest is the digest embedded in S1, if any.
+	s1Digest []byte
+}
+
+var _ Sequence = new(ServerChallengeSequence)
+
+// NewServerChallengeSequence returns a new *ServerChallengeSequence which
+// verifies the server's response to the given C1 packet.
+func NewServerChallengeSequence(version byte, C1 *AckPacket,
+	digest []byte) *ServerChallengeSequence {
+
+	return &ServerChallengeSequence{
+		Version: version,
+		C1:      C1,
+		Digest:  digest,
+		S1:      new(AckPacket),
+	}
+}
+
+// Read implements the Sequence.Read function. It reads S0, S1, and S2,
+// returning an error if the version in S0 is unsupported, if S1 advertises a
+// version without a valid digest, or if S2 neither echoes C1 nor responds to
+// its digest.
+func (s *ServerChallengeSequence) Read(r io.Reader) error {
+	var s0 [1]byte
+	if _, err := io.ReadFull(r, s0[:]); err != nil {
+		return err
+	}
+
+	if s0[0] != s.Version {
+		return fmt.Errorf(
+			"rtmp/handshake: unsupported version %v", s0[0])
+	}
+
+	if err := s.S1.Read(r); err != nil {
+		return err
+	}
+
+	s2 := new(AckPacket)
+	if err := s2.Read(r); err != nil {
+		return err
+	}
+
+	if s.S1.Time2 != 0 {
+		digest, _, ok := s.S1.FindDigest(serverKey)
+		if !ok {
+			return InvalidDigestErr
+		}
+
+		s.s1Digest = digest
+	}
+
+	if bytes.Equal(s.C1.Payload[:], s2.Payload[:]) {
+		return nil
+	}
+
+	if s.Digest == nil || !s2.IsResponse(s.Digest, GenuineFMSKey) {
+		return MismatchedChallengeErr
+	}
+
+	return nil
+}
+
+// WriteTo implements the Sequence.WriteTo function. It writes C2, which
+// either responds to the digest in S1, or echoes S1.
+func (s *ServerChallengeSequence) WriteTo(w io.Writer) error {
+	if s.s1Digest != nil {
+		return newResponse(s.s1Digest, GenuineFPKey).Write(w)
+	}
+
+	c2 := &AckPacket{
+		Time1:   s.S1.Time1,
+		Payload: s.S1.Payload,
+	}
+
+	return c2.Write(w)
+}
+
+// Next implements the Sequence.Next function. Since this is the final
+// sequence, it always returns nil.
+func (s *ServerChallengeSequence) Next() Sequence { return nil }
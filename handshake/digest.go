@@ -0,0 +1,186 @@
+package handshake
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+const (
+	// DigestLen is the length of the HMAC-SHA256 digests embedded in the
+	// packets of the "complex" handshake.
+	DigestLen int = sha256.Size
+
+	// PacketLen is the length of an entire AckPacket, including both of
+	// its time fields.
+	PacketLen int = 4 + 4 + PayloadLen
+
+	// ClientVersion and ServerVersion are written into the Time2 field of
+	// the C1 and S1 packets of a complex handshake. A non-zero version
+	// signals that the packet carries a digest.
+	ClientVersion uint32 = 0x09007c02
+	ServerVersion uint32 = 0x04050001
+)
+
+var (
+	// GenuineFPKey is the key used to sign packets sent by clients. Only
+	// the first 30 bytes (the text) are used to sign C1, while the entire
+	// key is used to derive the key that signs C2.
+	GenuineFPKey = []byte{
+		'G', 'e', 'n', 'u', 'i', 'n', 'e', ' ', 'A', 'd', 'o', 'b', 'e',
+		' ', 'F', 'l', 'a', 's', 'h', ' ', 'P', 'l', 'a', 'y', 'e', 'r',
+		' ', '0', '0', '1',
+		0xf0, 0xee, 0xc2, 0x4a, 0x80, 0x68, 0xbe, 0xe8, 0x2e, 0x00, 0xd0,
+		0xd1, 0x02, 0x9e, 0x7e, 0x57, 0x6e, 0xec, 0x5d, 0x2d, 0x29, 0x80,
+		0x6f, 0xab, 0x93, 0xb8, 0xe6, 0x36, 0xcf, 0xeb, 0x31, 0xae,
+	}
+
+	// GenuineFMSKey is the key used to sign packets sent by servers. Only
+	// the first 36 bytes (the text) are used to sign S1, while the entire
+	// key is used to derive the key that signs S2.
+	GenuineFMSKey = []byte{
+		'G', 'e', 'n', 'u', 'i', 'n', 'e', ' ', 'A', 'd', 'o', 'b', 'e',
+		' ', 'F', 'l', 'a', 's', 'h', ' ', 'M', 'e', 'd', 'i', 'a', ' ',
+		'S', 'e', 'r', 'v', 'e', 'r', ' ', '0', '0', '1',
+		0xf0, 0xee, 0xc2, 0x4a, 0x80, 0x68, 0xbe, 0xe8, 0x2e, 0x00, 0xd0,
+		0xd1, 0x02, 0x9e, 0x7e, 0x57, 0x6e, 0xec, 0x5d, 0x2d, 0x29, 0x80,
+		0x6f, 0xab, 0x93, 0xb8, 0xe6, 0x36, 0xcf, 0xeb, 0x31, 0xae,
+	}
+
+	// clientKey and serverKey are the keys used to sign C1 and S1.
+	clientKey = GenuineFPKey[:30]
+	serverKey = GenuineFMSKey[:36]
+)
+
+// DigestScheme is the layout of the digest within a C1 or S1 packet. Either
+// the digest precedes the key block (scheme 0), or follows it (scheme 1). In
+// both cases the position of the digest within its block is given by the sum
+// of the four bytes preceding it.
+type DigestScheme int
+
+const (
+	DigestFirstScheme DigestScheme = iota
+	KeyFirstScheme
+)
+
+// Offset returns the offset of the digest within the given packet, which is
+// PacketLen bytes long.
+func (s DigestScheme) Offset(b []byte) int {
+	base := 8
+	if s == KeyFirstScheme {
+		base = 772
+	}
+
+	sum := int(b[base]) + int(b[base+1]) + int(b[base+2]) + int(b[base+3])
+
+	return base + 4 + sum%728
+}
+
+// bytes returns the AckPacket encoded as a slice of PacketLen bytes.
+func (a *AckPacket) bytes() []byte {
+	b := make([]byte, PacketLen)
+	binary.BigEndian.PutUint32(b[0:4], a.Time1)
+	binary.BigEndian.PutUint32(b[4:8], a.Time2)
+	copy(b[8:], a.Payload[:])
+
+	return b
+}
+
+// setBytes decodes the given slice of PacketLen bytes into the AckPacket.
+func (a *AckPacket) setBytes(b []byte) {
+	a.Time1 = binary.BigEndian.Uint32(b[0:4])
+	a.Time2 = binary.BigEndian.Uint32(b[4:8])
+	copy(a.Payload[:], b[8:])
+}
+
+// Digest returns the digest embedded in the AckPacket according to the given
+// DigestScheme, and whether or not it is valid when signed with the given key.
+func (a *AckPacket) Digest(scheme DigestScheme, key []byte) ([]byte, bool) {
+	b := a.bytes()
+	offset := scheme.Offset(b)
+
+	digest := b[offset : offset+DigestLen]
+
+	return digest, hmac.Equal(digest, sign(b, offset, key))
+}
+
+// FindDigest searches the AckPacket for a digest signed with the given key
+// using either DigestScheme, returning the digest and the scheme that it was
+// found with. If the AckPacket does not carry a valid digest, false is
+// returned.
+func (a *AckPacket) FindDigest(key []byte) ([]byte, DigestScheme, bool) {
+	for _, scheme := range []DigestScheme{
+		KeyFirstScheme, DigestFirstScheme,
+	} {
+		if digest, ok := a.Digest(scheme, key); ok {
+			return digest, scheme, true
+		}
+	}
+
+	return nil, DigestFirstScheme, false
+}
+
+// Sign fills the AckPacket's payload with random data and embeds a digest of
+// it, signed with the given key, according to the given DigestScheme. It
+// returns the digest.
+func (a *AckPacket) Sign(scheme DigestScheme, key []byte) []byte {
+	rand.Read(a.Payload[:])
+
+	b := a.bytes()
+	offset := scheme.Offset(b)
+	copy(b[offset:], sign(b, offset, key))
+
+	a.setBytes(b)
+
+	return b[offset : offset+DigestLen]
+}
+
+// newResponse returns a new AckPacket responding to the given digest (from C1
+// or S1) as C2 or S2 are expected to: its payload is random, except for the
+// last DigestLen bytes, which are a digest of the rest of the packet signed
+// with a key derived from the peer's digest.
+func newResponse(digest, key []byte) *AckPacket {
+	a := new(AckPacket)
+	rand.Read(a.Payload[:])
+
+	b := a.bytes()
+	copy(b[PacketLen-DigestLen:], responseDigest(b, digest, key))
+
+	a.setBytes(b)
+
+	return a
+}
+
+// IsResponse returns whether or not the AckPacket is a valid response (as in
+// newResponse) to the given digest, signed with a key derived from the given
+// key.
+func (a *AckPacket) IsResponse(digest, key []byte) bool {
+	b := a.bytes()
+
+	return hmac.Equal(b[PacketLen-DigestLen:],
+		responseDigest(b, digest, key))
+}
+
+// responseDigest returns the digest of all but the last DigestLen bytes of the
+// given packet, signed with a key derived from the given digest.
+func responseDigest(b, digest, key []byte) []byte {
+	return hmacSHA256(hmacSHA256(key, digest), b[:PacketLen-DigestLen])
+}
+
+// sign returns the digest of the given packet, excluding the DigestLen bytes
+// at the given offset, signed with the given key.
+func sign(b []byte, offset int, key []byte) []byte {
+	return hmacSHA256(key, b[:offset], b[offset+DigestLen:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the given parts, signed with the given
+// key.
+func hmacSHA256(key []byte, parts ...[]byte) []byte {
+	h := hmac.New(sha256.New, key)
+	for _, p := range parts {
+		h.Write(p)
+	}
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,51 @@
+package handshake_test
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/rtmp/handshake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestSchemesComputeOffsets(t *testing.T) {
+	b := make([]byte, handshake.PacketLen)
+	b[8], b[9], b[10], b[11] = 0xff, 0xff, 0xff, 0xff
+	b[772], b[773] = 0x01, 0x02
+
+	assert.Equal(t, 12+(4*0xff)%728, handshake.DigestFirstScheme.Offset(b))
+	assert.Equal(t, 776+3, handshake.KeyFirstScheme.Offset(b))
+}
+
+func TestAckPacketsFindTheirOwnDigests(t *testing.T) {
+	for _, scheme := range []handshake.DigestScheme{
+		handshake.DigestFirstScheme, handshake.KeyFirstScheme,
+	} {
+		a := &handshake.AckPacket{Time2: handshake.ClientVersion}
+		digest := a.Sign(scheme, handshake.GenuineFPKey[:30])
+
+		found, s, ok := a.FindDigest(handshake.GenuineFPKey[:30])
+
+		assert.True(t, ok)
+		assert.Equal(t, scheme, s)
+		assert.Equal(t, digest, found)
+	}
+}
+
+func TestAckPacketsRejectTamperedDigests(t *testing.T) {
+	a := &handshake.AckPacket{Time2: handshake.ClientVersion}
+	a.Sign(handshake.DigestFirstScheme, handshake.GenuineFPKey[:30])
+	a.Time1 = 1
+
+	_, _, ok := a.FindDigest(handshake.GenuineFPKey[:30])
+
+	assert.False(t, ok)
+}
+
+func TestAckPacketsRejectDigestsSignedWithOtherKeys(t *testing.T) {
+	a := &handshake.AckPacket{Time2: handshake.ClientVersion}
+	a.Sign(handshake.DigestFirstScheme, handshake.GenuineFPKey[:30])
+
+	_, _, ok := a.FindDigest(handshake.GenuineFMSKey[:36])
+
+	assert.False(t, ok)
+}
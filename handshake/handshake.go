@@ -0,0 +1,20 @@
+package handshake
+
+import "io"
+
+// Accept preforms the server side of the RTMP handshake over the given
+// io.ReadWriter. Both the simple handshake and the digest-based "complex"
+// handshake are supported, depending on what the client initiates.
+func Accept(rw io.ReadWriter) error {
+	return With(&Param{Conn: rw}).Handshake()
+}
+
+// Dial preforms the client side of the RTMP handshake over the given
+// io.ReadWriter. It initiates the complex handshake, falling back to the simple
+// handshake if the server does not sign its response.
+func Dial(rw io.ReadWriter) error {
+	return With(&Param{
+		Conn:    rw,
+		Initial: NewInitiateSequence(),
+	}).Handshake()
+}
@@ -0,0 +1,129 @@
+package handshake_test
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/handshake"
+	"github.com/stretchr/testify/assert"
+)
+
+func newConns(t *testing.T) (client, server net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+
+	client, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	return client, <-accepted
+}
+
+func TestDialAndAcceptPreformTheComplexHandshake(t *testing.T) {
+	client, server := newConns(t)
+	defer client.Close()
+	defer server.Close()
+
+	errs := make(chan error, 1)
+	go func() { errs <- handshake.Accept(server) }()
+
+	assert.Nil(t, handshake.Dial(client))
+	assert.Nil(t, <-errs)
+}
+
+func TestAcceptPreformsTheSimpleHandshake(t *testing.T) {
+	client, server := newConns(t)
+	defer client.Close()
+	defer server.Close()
+
+	errs := make(chan error, 1)
+	go func() { errs <- handshake.Accept(server) }()
+
+	c0c1 := make([]byte, 1+handshake.PacketLen)
+	c0c1[0] = handshake.SupportedRTMPVersion
+	client.Write(c0c1)
+
+	s0s1s2 := make([]byte, 1+2*handshake.PacketLen)
+	_, err := io.ReadFull(client, s0s1s2)
+	assert.Nil(t, err)
+
+	// S2 echoes C1.
+	assert.Equal(t, c0c1[9:], s0s1s2[1+handshake.PacketLen+8:])
+
+	client.Write(s0s1s2[1 : 1+handshake.PacketLen])
+
+	assert.Nil(t, <-errs)
+}
+
+func TestDialFallsBackToTheSimpleHandshake(t *testing.T) {
+	client, server := newConns(t)
+	defer client.Close()
+	defer server.Close()
+
+	errs := make(chan error, 1)
+	go func() { errs <- handshake.Dial(client) }()
+
+	c0c1 := make([]byte, 1+handshake.PacketLen)
+	_, err := io.ReadFull(server, c0c1)
+	assert.Nil(t, err)
+
+	s1 := new(handshake.AckPacket)
+	s1.Payload[0] = 0x1
+
+	server.Write([]byte{handshake.SupportedRTMPVersion})
+	s1.Write(server)
+	server.Write(c0c1[1:])
+
+	c2 := new(handshake.AckPacket)
+	assert.Nil(t, c2.Read(server))
+	assert.Equal(t, s1.Payload, c2.Payload)
+
+	assert.Nil(t, <-errs)
+}
+
+func TestDialRejectsUnsignedServerVersions(t *testing.T) {
+	client, server := newConns(t)
+	defer client.Close()
+	defer server.Close()
+
+	errs := make(chan error, 1)
+	go func() { errs <- handshake.Dial(client) }()
+
+	c0c1 := make([]byte, 1+handshake.PacketLen)
+	_, err := io.ReadFull(server, c0c1)
+	assert.Nil(t, err)
+
+	s1 := &handshake.AckPacket{Time2: handshake.ServerVersion}
+
+	server.Write([]byte{handshake.SupportedRTMPVersion})
+	s1.Write(server)
+	server.Write(c0c1[1:])
+
+	assert.Equal(t, handshake.InvalidDigestErr, <-errs)
+}
+
+func TestDialRejectsMismatchedChallenges(t *testing.T) {
+	client, server := newConns(t)
+	defer client.Close()
+	defer server.Close()
+
+	errs := make(chan error, 1)
+	go func() { errs <- handshake.Dial(client) }()
+
+	c0c1 := make([]byte, 1+handshake.PacketLen)
+	_, err := io.ReadFull(server, c0c1)
+	assert.Nil(t, err)
+
+	server.Write([]byte{handshake.SupportedRTMPVersion})
+	new(handshake.AckPacket).Write(server)
+	new(handshake.AckPacket).Write(server)
+
+	assert.Equal(t, handshake.MismatchedChallengeErr, <-errs)
+}
@@ -19,6 +19,10 @@ var (
 type ServerAckSequence struct {
 	// S1 is the packet which C2 should acknowledge.
 	S1 *AckPacket
+	// Digest is the digest embedded in S1 during the complex handshake,
+	// or nil otherwise. If non-nil, C2 may acknowledge S1 by responding to
+	// its digest instead of echoing its payload.
+	Digest []byte
 }
 
 var _ Sequence = new(ServerAckSequence)
@@ -26,21 +30,25 @@ var _ Sequence = new(ServerAckSequence)
 // NewServerAckSequence returns a new *ServerAckSequence initialized with the
 // given S1 packet.
 func NewServerAckSequence(S1 *AckPacket) *ServerAckSequence {
-	return &ServerAckSequence{S1}
+	return &ServerAckSequence{S1: S1}
 }
 
 // Read implements the Handshake.Read method by reading the C2 packet and
 // comparing it to the stored S1 packet. If a read error occured while reading
-// C2, then it will be returned. If the payloads were not equal, then
-// MismatchedChallengeErr will be returned. Otherwise, in the successful case,
-// a value of nil will be returned.
+// C2, then it will be returned. If the payloads were not equal (and C2 is not a
+// valid response to the Digest in S1), then MismatchedChallengeErr will be
+// returned. Otherwise, in the successful case, a value of nil will be returned.
 func (s *ServerAckSequence) Read(r io.Reader) error {
 	c2 := new(AckPacket)
 	if err := c2.Read(r); err != nil {
 		return err
 	}
 
-	if !bytes.Equal(s.S1.Payload[:], c2.Payload[:]) {
+	if bytes.Equal(s.S1.Payload[:], c2.Payload[:]) {
+		return nil
+	}
+
+	if s.Digest == nil || !c2.IsResponse(s.Digest, GenuineFPKey) {
 		return MismatchedChallengeErr
 	}
 
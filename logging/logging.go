@@ -0,0 +1,36 @@
+// Package logging defines the minimal Logger interface through which the
+// server and stream types of this module report internal events, such as state
+// transitions and dropped frames, without depending on any particular logging
+// library.
+package logging
+
+// Logger is implemented by types capable of logging formatted messages. It is
+// satisfied by the standard library's *log.Logger, and is easily adapted to
+// most other logging libraries.
+type Logger interface {
+	// Printf logs a message formatted according to the given format
+	// specifier, as by fmt.Printf.
+	Printf(format string, v ...interface{})
+}
+
+var (
+	// Noop is a singleton implementation of the Logger interface which
+	// discards all messages. It is the default Logger of each type which
+	// accepts one.
+	Noop Logger = new(noopLogger)
+)
+
+// noopLogger is the internal implementation of the Noop Logger (see above).
+type noopLogger struct{}
+
+// Printf implements Logger.Printf.
+func (n *noopLogger) Printf(format string, v ...interface{}) {}
+
+// OrNoop returns the given Logger, or Noop if it is nil.
+func OrNoop(l Logger) Logger {
+	if l == nil {
+		return Noop
+	}
+
+	return l
+}
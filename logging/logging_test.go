@@ -0,0 +1,25 @@
+package logging_test
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ logging.Logger = new(log.Logger)
+
+func TestOrNoopReturnsNoopForNilLoggers(t *testing.T) {
+	assert.Equal(t, logging.Noop, logging.OrNoop(nil))
+}
+
+func TestOrNoopReturnsTheGivenLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := log.New(buf, "", 0)
+
+	logging.OrNoop(l).Printf("hello %s", "world")
+
+	assert.Equal(t, "hello world\n", buf.String())
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var (
+	// ErrMissingPort is wrapped by the *BindError returned by New and
+	// NewWithOptions when the bind address has no port, as in "localhost"
+	// or "[::1]", rather than "localhost:1935" or "[::1]:1935".
+	ErrMissingPort = errors.New("missing port (as in \"host:1935\", or \":1935\" for all interfaces)")
+	// ErrUnbracketedIPv6 is wrapped by the *BindError returned by New and
+	// NewWithOptions when the bind address is an IPv6 literal that is not
+	// enclosed in brackets, as in "::1:1935", rather than "[::1]:1935".
+	ErrUnbracketedIPv6 = errors.New("IPv6 addresses must be enclosed in brackets (as in \"[::1]:1935\")")
+)
+
+// BindError is returned by New and NewWithOptions when the server cannot be
+// bound to the given address, either because it is malformed, or because the
+// network is not able to be bound.
+type BindError struct {
+	// Bind is the address that the server was to be bound to.
+	Bind string
+	// Err is the underlying error.
+	Err error
+}
+
+var _ error = new(BindError)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e *BindError) Error() string {
+	return fmt.Sprintf("rtmp/server: cannot bind to %q: %v", e.Bind, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *BindError) Unwrap() error { return e.Err }
+
+// validateBind checks that `bind` is of the form "host:port", "[ipv6]:port", or
+// ":port", returning a *BindError describing what is missing otherwise.
+func validateBind(bind string) error {
+	if _, _, err := net.SplitHostPort(bind); err != nil {
+		if !strings.HasPrefix(bind, "[") && strings.Count(bind, ":") > 1 {
+			err = ErrUnbracketedIPv6
+		} else if aerr, ok := err.(*net.AddrError); ok &&
+			aerr.Err == "missing port in address" {
+
+			err = ErrMissingPort
+		}
+
+		return &BindError{Bind: bind, Err: err}
+	}
+
+	return nil
+}
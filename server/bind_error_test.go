@@ -0,0 +1,82 @@
+package server_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBindsBracketedIPv6Addresses(t *testing.T) {
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("server: IPv6 loopback is unavailable:", err)
+	}
+	l.Close()
+
+	s, err := server.New("[::1]:1935")
+	assert.Nil(t, err)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", "[::1]:1935")
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func TestNewBindsAllInterfacesWithoutAHost(t *testing.T) {
+	s, err := server.New(":1935")
+	assert.Nil(t, err)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:1935")
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func TestNewBindsAllIPv4Interfaces(t *testing.T) {
+	s, err := server.New("0.0.0.0:1935")
+	assert.Nil(t, err)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:1935")
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func TestNewRejectsAddressesWithoutAPort(t *testing.T) {
+	for _, bind := range []string{"localhost", "127.0.0.1", "[::1]", ""} {
+		s, err := server.New(bind)
+
+		assert.Nil(t, s, bind)
+		assert.True(t, errors.Is(err, server.ErrMissingPort), "%q: %v", bind, err)
+	}
+}
+
+func TestNewRejectsUnbracketedIPv6Addresses(t *testing.T) {
+	for _, bind := range []string{"::1", "::1:1935", "fe80::1:1935"} {
+		s, err := server.New(bind)
+
+		assert.Nil(t, s, bind)
+		assert.True(t, errors.Is(err, server.ErrUnbracketedIPv6), "%q: %v", bind, err)
+	}
+}
+
+func TestNewWrapsErrorsBindingTheNetwork(t *testing.T) {
+	s, err := server.New("256.256.256.256:1935")
+
+	assert.Nil(t, s)
+
+	var berr *server.BindError
+	if assert.True(t, errors.As(err, &berr)) {
+		assert.Equal(t, "256.256.256.256:1935", berr.Bind)
+		assert.NotNil(t, berr.Err)
+	}
+}
+
+func TestBindErrorIncludesTheAddress(t *testing.T) {
+	err := &server.BindError{Bind: "localhost", Err: errors.New("foo")}
+
+	assert.Equal(t, `rtmp/server: cannot bind to "localhost": foo`, err.Error())
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+
+	"github.com/WatchBeam/rtmp/tracing"
+)
+
+// Option configures a Server constructed by NewWithOptions or
+// NewListenerWithOptions.
+type Option func(*options)
+
+// options holds the values configured by Options.
+type options struct {
+	// clientBufferSize is the capacity of the clients channel.
+	clientBufferSize int
+	// baseContext is the context that the context of each connection is
+	// derived from.
+	baseContext context.Context
+	// tracer is the Tracer carried by the context of each connection, if
+	// any.
+	tracer tracing.Tracer
+}
+
+// ClientBufferSize sets the capacity of the channel returned by Clients() to
+// `size` clients, such that a momentary stall in the consumer of that channel
+// does not stall the Accept routine. By default, the channel is unbuffered.
+//
+// Once the buffer is full, the Accept routine blocks until there is room in
+// it, applying backpressure: further connections wait in the kernel's listen
+// backlog, rather than being dropped.
+//
+// A size of less than zero is treated as zero.
+func ClientBufferSize(size int) Option {
+	return func(o *options) {
+		if size < 0 {
+			size = 0
+		}
+		o.clientBufferSize = size
+	}
+}
+
+// BaseContext sets the context that the context of each accepted connection is
+// derived from (see client.NewWithContext), such that values carried by it
+// reach the handlers of every connection, and every connection is stopped once
+// it is done. It defaults to context.Background().
+func BaseContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.baseContext = ctx
+	}
+}
+
+// Tracer sets the Tracer that spans covering the lifecycle of each accepted
+// connection are started with: from its acceptance, through the handshake and
+// connect command, to the first media frame received over it (see the tracing
+// package). The Tracer is carried by the context of each connection (see
+// BaseContext), and, by default, none is used.
+func Tracer(t tracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = t
+	}
+}
@@ -1,63 +1,368 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/WatchBeam/rtmp/client"
+	"github.com/WatchBeam/rtmp/logging"
+	"github.com/WatchBeam/rtmp/tracing"
+	"golang.org/x/time/rate"
 )
 
+var (
+	// errDroppedByLimit is the error that the accept span of a connection
+	// dropped by the accept limit is ended with.
+	errDroppedByLimit = errors.New("rtmp/server: connection dropped by the accept limit")
+	// errStopped is the error that the accept span of a connection is
+	// ended with when the server is released or closed before the
+	// connection could be handed off.
+	errStopped = errors.New("rtmp/server: server released or closed")
+)
+
+const (
+	// DefaultReleaseDeadline is the maximum amount of time that Release()
+	// will wait for the Accept() routine to stop before returning. In the
+	// typical case, the Accept() routine stops immediately.
+	DefaultReleaseDeadline time.Duration = time.Second
+)
+
+// deadliner is implemented by net.Listeners that support setting a deadline on
+// their Accept() call, such as *net.TCPListener and *net.UnixListener.
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
 // A Server represents a TCP server capable of accepting connections, and
 // pushing them into the Clients() channel.
 //
-// Underneath the hood, type `Server` uses a net.Listener (of the TCP-variety)
-// to listen for connections, and maintains a channel for errors, as well as a
-// channel for clients.
+// Underneath the hood, type `Server` uses a net.Listener to listen for
+// connections, and maintains a channel for errors, as well as a channel for
+// clients.
 type Server struct {
 	// socket is the net.Listener which enables the `Server` type to listen
-	// for TCP connections.
+	// for connections. It is usually (but need not be) a TCP listener.
 	socket net.Listener
 
-	// clients is a non-buffered channel of *client.Client, which is
-	// populated each time a client connects.
+	// clients is a channel of *client.Client, which is populated each
+	// time a client connects. It is unbuffered unless configured otherwise
+	// (see ClientBufferSize).
 	clients chan *client.Client
 	// errs is a channel of errors that is written to every time an error is
-	// encountered in the Accept routine.
+	// encountered in the Accept routine. Each error is a *ServerError.
 	errs chan error
+
+	// deadline is the maximum amount of time given to the Accept routine
+	// to stop once Release() has been called.
+	deadline time.Duration
+
+	// smu guards state.
+	smu sync.Mutex
+	// state is the current lifecycle state of the Server.
+	state state
+	// released is closed by the Accept routine once it has returned as a
+	// result of a call to Release().
+	released chan struct{}
+	// stopped is canceled by stop once Release() or Close() has been
+	// called.
+	stopped context.Context
+	stop    context.CancelFunc
+
+	// lmu guards limiter and dropOnLimit.
+	lmu sync.Mutex
+	// limiter throttles the rate at which accepted connections are handed
+	// off to the clients channel. If nil, no limit is applied.
+	limiter *rate.Limiter
+	// dropOnLimit determines whether connections in excess of the limit
+	// are closed immediately (true), or held until the limiter allows them
+	// through (false).
+	dropOnLimit bool
+
+	// hmu guards handshakeTimeout.
+	hmu sync.Mutex
+	// handshakeTimeout is the maximum amount of time a connecting client
+	// is given to complete the RTMP handshake. If zero, the server does not
+	// handshake with clients itself.
+	handshakeTimeout time.Duration
+
+	// kmu guards keepAlive.
+	kmu sync.Mutex
+	// keepAlive is the TCP keepalive period applied to accepted
+	// connections. If zero, the listener's default is left in place, and
+	// if negative, keepalive is disabled.
+	keepAlive time.Duration
+
+	// nmu guards noDelay.
+	nmu sync.Mutex
+	// noDelay determines whether Nagle's algorithm is disabled (true) on
+	// accepted TCP connections.
+	noDelay bool
+
+	// gmu guards log.
+	gmu sync.Mutex
+	// log is the Logger that internal events are reported to.
+	log logging.Logger
+
+	// ctx is the context that the context of each connection is derived
+	// from.
+	ctx context.Context
 }
 
 // New instantiates and returns a new server, bound to the `bind` address given.
-// Semantics for `bind` follow those set forth in the `net` package. Calling
-// `New()` does in-fact create a TCP Listener on that address, and returns an
-// error if the address is non-parsable, or the network is not able to be bound.
+// Semantics for `bind` follow those set forth in the `net` package: it is of
+// the form "host:port", "[ipv6]:port" (such as "[::1]:1935"), or ":port" to
+// bind to all interfaces. Calling `New()` does in-fact create a TCP Listener on
+// that address, and returns a *BindError if the address is non-parsable (for
+// instance, wrapping ErrMissingPort if it has no port), or the network is not
+// able to be bound.
 //
 // Otherwise, a server is returned.
 func New(bind string) (*Server, error) {
+	return NewWithOptions(bind)
+}
+
+// NewWithOptions behaves the same as New, but configures the server with the
+// given Options.
+func NewWithOptions(bind string, opts ...Option) (*Server, error) {
+	if err := validateBind(bind); err != nil {
+		return nil, err
+	}
+
 	socket, err := net.Listen("tcp", bind)
 	if err != nil {
-		return nil, err
+		return nil, &BindError{Bind: bind, Err: err}
 	}
 
-	return &Server{
-		socket:  socket,
-		clients: make(chan *client.Client),
-		errs:    make(chan error),
-	}, nil
+	return NewListenerWithOptions(socket, opts...), nil
+}
+
+// NewListener instantiates and returns a new server which accepts connections
+// from the given net.Listener. This allows the server to be used with Unix
+// sockets, or any other custom listener.
+func NewListener(l net.Listener) *Server {
+	return NewListenerWithOptions(l)
+}
+
+// NewListenerWithOptions behaves the same as NewListener, but configures the
+// server with the given Options.
+func NewListenerWithOptions(l net.Listener, opts ...Option) *Server {
+	o := &options{
+		baseContext: context.Background(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.tracer != nil {
+		o.baseContext = tracing.NewContext(o.baseContext, o.tracer)
+	}
+
+	s := &Server{
+		socket:   l,
+		clients:  make(chan *client.Client, o.clientBufferSize),
+		errs:     make(chan error),
+		deadline: DefaultReleaseDeadline,
+		state:    idleState,
+		released: make(chan struct{}),
+		noDelay:  true,
+		log:      logging.Noop,
+		ctx:      o.baseContext,
+	}
+	s.stopped, s.stop = context.WithCancel(context.Background())
+
+	return s
 }
 
 // Close closes the network socket, terminating the processof accepting new
-// connections immediately..
+// connections immediately.. Connections which have yet to be handed off to the
+// Clients() channel are closed.
 func (s *Server) Close() error {
+	s.setState(closingState)
+	s.stop()
+
 	return s.socket.Close()
 }
 
+// Release stops the Accept routine without closing the underlying socket, and
+// returns the socket so that it may be handed off elsewhere.
+//
+// To unblock a pending Accept() call immediately, the socket's deadline is set
+// to the current time, and then reset once the Accept routine has returned.
+// Release waits no longer than the server's release deadline for that to
+// happen, which is only reached if the Accept routine is blocked handing off a
+// client or an error.
+//
+// Listeners which do not support SetDeadline are closed instead, in which case
+// the returned net.Listener will no longer accept connections. Connections
+// which have yet to be handed off to the Clients() channel are closed.
+func (s *Server) Release() net.Listener {
+	s.stop()
+	if s.setState(releasingState) != acceptingState {
+		return s.socket
+	}
+
+	d, ok := s.socket.(deadliner)
+	if !ok {
+		s.socket.Close()
+		s.awaitReleased()
+
+		return s.socket
+	}
+
+	d.SetDeadline(time.Now())
+	s.awaitReleased()
+	d.SetDeadline(time.Time{})
+
+	return s.socket
+}
+
+// ReleaseTCP behaves the same as Release, but returns the socket as a
+// *net.TCPListener. If the socket is not a TCP listener, nil is returned.
+//
+// Deprecated: use Release instead.
+func (s *Server) ReleaseTCP() *net.TCPListener {
+	l, _ := s.Release().(*net.TCPListener)
+	return l
+}
+
+// SetAcceptLimit limits the rate at which new connections are handed off to the
+// Clients() channel to `perSecond` connections per second, allowing bursts of
+// up to `burst` connections at once. A `perSecond` value of zero or less
+// removes the limit, and a `burst` of less than one is treated as one.
+//
+// What happens to connections in excess of the limit is governed by
+// SetDropOnLimit.
+func (s *Server) SetAcceptLimit(perSecond int, burst int) {
+	s.lmu.Lock()
+	defer s.lmu.Unlock()
+
+	if perSecond <= 0 {
+		s.limiter = nil
+		return
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	s.limiter = rate.NewLimiter(rate.Limit(perSecond), burst)
+}
+
+// SetDropOnLimit determines what happens to connections accepted in excess of
+// the limit set by SetAcceptLimit. If `drop` is true, those connections are
+// closed immediately. Otherwise (the default), the Accept routine waits until
+// the limiter allows the connection through.
+func (s *Server) SetDropOnLimit(drop bool) {
+	s.lmu.Lock()
+	defer s.lmu.Unlock()
+
+	s.dropOnLimit = drop
+}
+
+// SetHandshakeTimeout causes the server to preform the RTMP handshake with each
+// connecting client before it is written to the Clients() channel. A deadline
+// of `d` is applied to the connection for the duration of the handshake (C0,
+// C1, and C2). Connections that fail to complete the handshake within that
+// time are closed, and the error is written to the Errs() channel instead.
+//
+// Clients received after setting a timeout have already been handshaked, so
+// there is no need to call Handshake() on them. A value of zero (the default)
+// disables this behavior.
+func (s *Server) SetHandshakeTimeout(d time.Duration) {
+	s.hmu.Lock()
+	defer s.hmu.Unlock()
+
+	s.handshakeTimeout = d
+}
+
+// HandshakeTimeout returns the handshake timeout set by SetHandshakeTimeout.
+func (s *Server) HandshakeTimeout() time.Duration {
+	s.hmu.Lock()
+	defer s.hmu.Unlock()
+
+	return s.handshakeTimeout
+}
+
+// SetKeepAlive configures TCP keepalive on each subsequently accepted
+// connection, so that dead peers are detected at the TCP layer. A positive `d`
+// enables keepalive with a period of `d`, and a negative `d` disables it. A
+// value of zero (the default) leaves the listener's default in place.
+//
+// Connections which are not a *net.TCPConn (such as those accepted from a Unix
+// socket) are unaffected.
+func (s *Server) SetKeepAlive(d time.Duration) {
+	s.kmu.Lock()
+	defer s.kmu.Unlock()
+
+	s.keepAlive = d
+}
+
+// KeepAlive returns the keepalive period set by SetKeepAlive.
+func (s *Server) KeepAlive() time.Duration {
+	s.kmu.Lock()
+	defer s.kmu.Unlock()
+
+	return s.keepAlive
+}
+
+// SetNoDelay determines whether Nagle's algorithm is disabled on each
+// subsequently accepted TCP connection (see net.TCPConn.SetNoDelay). It is
+// disabled (`noDelay` is true) by default, since RTMP already batches data into
+// chunks, and delaying small chunks (such as control messages, or audio
+// frames) only adds latency.
+//
+// Enabling Nagle's algorithm trades that latency for fewer, larger packets,
+// which may be preferable for bandwidth-constrained, non-interactive streams.
+// Connections which are not a *net.TCPConn are unaffected.
+func (s *Server) SetNoDelay(noDelay bool) {
+	s.nmu.Lock()
+	defer s.nmu.Unlock()
+
+	s.noDelay = noDelay
+}
+
+// NoDelay returns the value set by SetNoDelay.
+func (s *Server) NoDelay() bool {
+	s.nmu.Lock()
+	defer s.nmu.Unlock()
+
+	return s.noDelay
+}
+
+// SetLogger sets the Logger that the server reports internal events to, such as
+// state transitions, errors (including those which are not written to the
+// Errs() channel because they are expected), and connections dropped by the
+// accept limit. If it is nil, events are discarded, as they are by default.
+func (s *Server) SetLogger(l logging.Logger) {
+	s.gmu.Lock()
+	defer s.gmu.Unlock()
+
+	s.log = logging.OrNoop(l)
+}
+
+// logger returns the Logger set by SetLogger.
+func (s *Server) logger() logging.Logger {
+	s.gmu.Lock()
+	defer s.gmu.Unlock()
+
+	return s.log
+}
+
 // Clients returns a read-only channel of *client.Client, written to when a new
-// connection is obtained into the server.
+// connection is obtained into the server. If the channel is full (or, by
+// default, unbuffered and not being read from), the Accept routine blocks until
+// it is read from (see ClientBufferSize).
 func (s *Server) Clients() <-chan *client.Client {
 	return s.clients
 }
 
 // Errs returns a read-only channel of `error`s, written to when accepting
-// a socket connection returns an error.
+// a socket connection returns an error. Each error is a *ServerError, carrying
+// the state of the server and, where available, the remote address of the
+// connection in question.
 func (s *Server) Errs() <-chan error {
 	return s.errs
 }
@@ -71,15 +376,249 @@ func (s *Server) Errs() <-chan error {
 // In the successful case, the client is written to the internal `clients`
 // channel, which is readable from the Clients() method.
 //
+// If an accept limit has been set (see SetAcceptLimit), connections are handed
+// off no faster than that limit allows, and are either waited on or dropped
+// when it is exceeded.
+//
+// If a keepalive period has been set (see SetKeepAlive), it is applied to each
+// TCP connection before it is handed off, as is the setting of SetNoDelay.
+//
+// If a handshake timeout has been set (see SetHandshakeTimeout), each client is
+// handshaked within its own goroutine before being written to the `clients`
+// channel.
+//
+// If Release() is called, Accept returns without closing the socket.
+//
 // Accept runs within its own goroutine.
 func (s *Server) Accept() {
-	for {
+	if !s.startAccepting() {
+		return
+	}
+	defer close(s.released)
+
+	for s.getState() == acceptingState {
 		conn, err := s.socket.Accept()
 		if err != nil {
-			s.errs <- err
+			if s.handleError(err, nil) {
+				return
+			}
+
 			continue
 		}
 
-		s.clients <- client.New(conn)
+		ctx, span := tracing.Start(s.ctx, tracing.SpanAccept)
+		span.SetAttribute(tracing.AttributeRemoteAddr, conn.RemoteAddr().String())
+
+		if !s.allow(conn) {
+			if s.stopped.Err() != nil {
+				span.End(errStopped)
+			} else {
+				span.End(errDroppedByLimit)
+			}
+			continue
+		}
+
+		if err := s.applyKeepAlive(conn); err != nil {
+			s.handleError(err, conn.RemoteAddr())
+		}
+
+		if tc, ok := conn.(*net.TCPConn); ok {
+			if err := tc.SetNoDelay(s.NoDelay()); err != nil {
+				s.handleError(err, conn.RemoteAddr())
+			}
+		}
+
+		if timeout := s.HandshakeTimeout(); timeout > 0 {
+			go s.handshake(ctx, span, conn, timeout)
+		} else {
+			s.handOff(span, client.NewWithContext(ctx, conn))
+		}
+	}
+}
+
+// startAccepting moves the server into the accepting state, returning false if
+// the server has already been released or closed.
+func (s *Server) startAccepting() bool {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	if s.state != idleState {
+		return false
+	}
+
+	s.state = acceptingState
+	s.logger().Printf("rtmp/server: state %s -> %s", idleState, acceptingState)
+
+	return true
+}
+
+// awaitReleased waits for the Accept routine to return after a call to
+// Release(), or for the release deadline to pass, whichever comes first.
+func (s *Server) awaitReleased() {
+	select {
+	case <-s.released:
+	case <-time.After(s.deadline):
+	}
+}
+
+// getState returns the current state of the server.
+func (s *Server) getState() state {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	return s.state
+}
+
+// setState moves the server into the given state, returning the state that it
+// was in before.
+func (s *Server) setState(st state) state {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	prev := s.state
+	s.state = st
+
+	s.logger().Printf("rtmp/server: state %s -> %s", prev, st)
+
+	return prev
+}
+
+// handleError processes an error encountered while accepting or setting up a
+// connection from `addr` (which may be nil). It returns true if the Accept
+// routine should be killed as a result of the error.
+//
+// Errors that are expected given the state of the server, such as the socket
+// being closed after a call to Close(), kill the Accept routine silently. All
+// other errors are wrapped in a *ServerError and written to the errs channel,
+// unless the server is released or closed before they are read.
+func (s *Server) handleError(err error, addr net.Addr) (kill bool) {
+	st := s.getState()
+
+	serr := &ServerError{
+		State: string(st),
+		Addr:  addr,
+		Err:   err,
+	}
+	s.logger().Printf("%v", serr)
+
+	switch st {
+	case releasingState:
+		return true
+	case closingState:
+		if isNetCloseError(err) {
+			return true
+		}
+	}
+
+	select {
+	case s.errs <- serr:
+	case <-s.stopped.Done():
+	}
+
+	return false
+}
+
+// isNetCloseError returns whether or not the given error was caused by
+// operating on a closed network connection. Errors which do not wrap
+// net.ErrClosed (e.g., those returned by custom listeners) are matched against
+// its message as a fallback.
+func isNetCloseError(err error) bool {
+	if errors.Is(err, net.ErrClosed) {
+		return true
+	}
+
+	return strings.Contains(err.Error(), net.ErrClosed.Error())
+}
+
+// allow applies the accept limit (if any) to the given connection. It returns
+// true if the connection may be handed off, or false if it was dropped, in
+// which case the connection has already been closed. Waiting on the limiter is
+// abandoned once the server is released or closed.
+func (s *Server) allow(conn net.Conn) bool {
+	s.lmu.Lock()
+	limiter, drop := s.limiter, s.dropOnLimit
+	s.lmu.Unlock()
+
+	if limiter == nil {
+		return true
+	}
+
+	if drop {
+		if !limiter.Allow() {
+			s.logger().Printf("rtmp/server: dropped connection from %v: accept limit exceeded",
+				conn.RemoteAddr())
+
+			conn.Close()
+			return false
+		}
+
+		return true
+	}
+
+	if err := limiter.Wait(s.stopped); err != nil {
+		conn.Close()
+		if s.stopped.Err() == nil {
+			s.handleError(err, conn.RemoteAddr())
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// applyKeepAlive applies the keepalive period set by SetKeepAlive (if any) to
+// the given connection, if it is a *net.TCPConn.
+func (s *Server) applyKeepAlive(conn net.Conn) error {
+	d := s.KeepAlive()
+	tc, ok := conn.(*net.TCPConn)
+	if d == 0 || !ok {
+		return nil
+	}
+
+	if d < 0 {
+		return tc.SetKeepAlive(false)
+	}
+
+	if err := tc.SetKeepAlive(true); err != nil {
+		return err
+	}
+
+	return tc.SetKeepAlivePeriod(d)
+}
+
+// handshake preforms the RTMP handshake with the client on the other end of
+// `conn`, bounded by the given timeout. If the handshake succeeds, the client
+// is handed off to the clients channel. Otherwise, the client is closed and
+// the error is written to the errs channel. Either way, the accept span of the
+// connection is ended once done.
+func (s *Server) handshake(ctx context.Context, span tracing.Span,
+	conn net.Conn, timeout time.Duration) {
+
+	c := client.NewWithContext(ctx, conn)
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := c.Handshake(); err != nil {
+		c.Close()
+		s.handleError(err, conn.RemoteAddr())
+		span.End(err)
+
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	s.handOff(span, c)
+}
+
+// handOff writes `c` to the clients channel, unless the server is released or
+// closed first, in which case `c` is closed instead. Either way, the accept
+// span of the connection is ended once done.
+func (s *Server) handOff(span tracing.Span, c *client.Client) {
+	select {
+	case s.clients <- c:
+		span.End(nil)
+	case <-s.stopped.Done():
+		c.Close()
+		span.End(errStopped)
 	}
 }
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"fmt"
+	"net"
+)
+
+// ServerError wraps an error encountered by the Server with information about
+// what the Server was doing at the time, and which connection (if any) the
+// error is associated with. All errors written to the Errs() channel are of
+// this type.
+type ServerError struct {
+	// State is the state that the Server was in when the error occurred,
+	// e.g., "accepting".
+	State string
+	// Addr is the remote address of the connection that caused the error,
+	// or nil if the error was not associated with any one connection.
+	Addr net.Addr
+	// Err is the underlying error.
+	Err error
+}
+
+var _ error = new(ServerError)
+
+// Error implements the `func Error` in the `type error interface`.
+func (e *ServerError) Error() string {
+	if e.Addr == nil {
+		return fmt.Sprintf("rtmp/server: %s: %v", e.State, e.Err)
+	}
+
+	return fmt.Sprintf("rtmp/server: %s (%v): %v", e.State, e.Addr, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ServerError) Unwrap() error { return e.Err }
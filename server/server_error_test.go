@@ -0,0 +1,36 @@
+package server_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerErrorIncludesStateAndAddr(t *testing.T) {
+	err := &server.ServerError{
+		State: "accepting",
+		Addr:  &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1935},
+		Err:   errors.New("foo"),
+	}
+
+	assert.Equal(t, "rtmp/server: accepting (127.0.0.1:1935): foo", err.Error())
+}
+
+func TestServerErrorOmitsMissingAddr(t *testing.T) {
+	err := &server.ServerError{
+		State: "accepting",
+		Err:   errors.New("foo"),
+	}
+
+	assert.Equal(t, "rtmp/server: accepting: foo", err.Error())
+}
+
+func TestServerErrorUnwrapsTheUnderlyingError(t *testing.T) {
+	cause := errors.New("foo")
+	err := &server.ServerError{Err: cause}
+
+	assert.Equal(t, cause, err.Unwrap())
+}
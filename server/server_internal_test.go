@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsNetCloseErrorMatchesWrappedErrClosed(t *testing.T) {
+	err := fmt.Errorf("accept: %w", net.ErrClosed)
+
+	assert.True(t, isNetCloseError(err))
+}
+
+func TestIsNetCloseErrorFallsBackToTheErrorMessage(t *testing.T) {
+	err := errors.New("accept tcp: use of closed network connection")
+
+	assert.True(t, isNetCloseError(err))
+}
+
+func TestIsNetCloseErrorIgnoresOtherErrors(t *testing.T) {
+	assert.False(t, isNetCloseError(errors.New("foo")))
+}
+
+func TestHandleErrorKillsOnCloseWhenClosing(t *testing.T) {
+	s := NewListener(nil)
+	s.setState(closingState)
+
+	kill := s.handleError(fmt.Errorf("accept: %w", net.ErrClosed), nil)
+
+	assert.True(t, kill)
+}
+
+func TestHandleErrorReportsOtherErrorsWhenClosing(t *testing.T) {
+	s := NewListener(nil)
+	s.setState(closingState)
+
+	go s.handleError(errors.New("foo"), nil)
+
+	err := (<-s.Errs()).(*ServerError)
+
+	assert.Equal(t, "closing", err.State)
+	assert.Equal(t, "foo", err.Err.Error())
+}
+
+func TestStateTransitionsAreLogged(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := NewListener(nil)
+	s.SetLogger(log.New(buf, "", 0))
+
+	s.setState(closingState)
+
+	assert.Equal(t, "rtmp/server: state idle -> closing\n", buf.String())
+}
+
+func TestExpectedErrorsAreLogged(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := NewListener(nil)
+	s.setState(releasingState)
+	s.SetLogger(log.New(buf, "", 0))
+
+	kill := s.handleError(errors.New("foo"), nil)
+
+	assert.True(t, kill)
+	assert.Equal(t, "rtmp/server: releasing: foo\n", buf.String())
+}
@@ -1,12 +1,22 @@
 package server_test
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/WatchBeam/rtmp/client"
+	"github.com/WatchBeam/rtmp/handshake"
 	"github.com/WatchBeam/rtmp/server"
+	"github.com/WatchBeam/rtmp/tracing"
+	"github.com/WatchBeam/rtmp/tracing/tracingtest"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
 )
 
 func TestNewServerConstructsServerWithValidBind(t *testing.T) {
@@ -36,3 +46,430 @@ func TestListenGetsNewClients(t *testing.T) {
 
 	assert.IsType(t, &client.Client{}, <-s.Clients())
 }
+
+func TestNewListenerAcceptsClientsFromAnyListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rtmp")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	l, err := net.Listen("unix", filepath.Join(dir, "rtmp.sock"))
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	go s.Accept()
+	defer s.Close()
+
+	_, err = net.Dial("unix", filepath.Join(dir, "rtmp.sock"))
+	assert.Nil(t, err)
+
+	assert.IsType(t, &client.Client{}, <-s.Clients())
+}
+
+func TestReleaseReturnsTheListenerAndStopsAccepting(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	s := server.NewListener(l)
+
+	done := make(chan struct{})
+	go func() {
+		s.Accept()
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	assert.Equal(t, l, s.Release())
+	<-done
+
+	go func() { net.Dial("tcp", l.Addr().String()) }()
+
+	conn, err := l.Accept()
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func TestReleaseTCPReturnsTheTCPListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	s := server.NewListener(l)
+
+	assert.Equal(t, l, s.ReleaseTCP())
+}
+
+func TestReleaseClosesListenersWithoutDeadlines(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(&noDeadlineListener{l})
+
+	done := make(chan struct{})
+	go func() {
+		s.Accept()
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	s.Release()
+	<-done
+
+	_, err = l.Accept()
+	assert.NotNil(t, err)
+}
+
+type noDeadlineListener struct {
+	l net.Listener
+}
+
+func (n *noDeadlineListener) Accept() (net.Conn, error) { return n.l.Accept() }
+func (n *noDeadlineListener) Close() error              { return n.l.Close() }
+func (n *noDeadlineListener) Addr() net.Addr            { return n.l.Addr() }
+
+func TestAcceptLimitThrottlesNewClients(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	s.SetAcceptLimit(1, 1)
+
+	go s.Accept()
+	defer s.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := net.Dial("tcp", l.Addr().String())
+		assert.Nil(t, err)
+	}
+
+	received := 0
+	timeout := time.After(200 * time.Millisecond)
+L:
+	for {
+		select {
+		case <-s.Clients():
+			received++
+		case <-timeout:
+			break L
+		}
+	}
+
+	assert.Equal(t, 1, received)
+}
+
+func TestAcceptLimitClampsBurstToOne(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	s.SetAcceptLimit(1, 0)
+
+	go s.Accept()
+	defer s.Close()
+
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	select {
+	case <-s.Clients():
+	case err := <-s.Errs():
+		t.Fatalf("rtmp/server: unexpected error: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("rtmp/server: client was not accepted")
+	}
+}
+
+func TestReleaseInterruptsAcceptLimitWaits(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	s := server.NewListener(l)
+	s.SetAcceptLimit(1, 1)
+
+	done := make(chan struct{})
+	go func() {
+		s.Accept()
+		close(done)
+	}()
+
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	<-s.Clients()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	s.Release()
+	<-done
+
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestAcceptLimitDropsExtraClientsWhenDropOnLimitIsSet(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	s.SetAcceptLimit(1, 1)
+	s.SetDropOnLimit(true)
+
+	go s.Accept()
+	defer s.Close()
+
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	<-s.Clients()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestHandshakeTimeoutClosesSlowClients(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	s.SetHandshakeTimeout(50 * time.Millisecond)
+
+	go s.Accept()
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	select {
+	case err := <-s.Errs():
+		serr := err.(*server.ServerError)
+
+		assert.Equal(t, "accepting", serr.State)
+		assert.Equal(t, conn.LocalAddr().String(), serr.Addr.String())
+		assert.True(t, serr.Err.(net.Error).Timeout())
+	case <-s.Clients():
+		t.Fatal("rtmp/server: slow client should not be accepted")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestHandshakeTimeoutAcceptsHandshakedClients(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	s.SetHandshakeTimeout(time.Second)
+
+	go s.Accept()
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	c0c1 := make([]byte, 1+1536)
+	c0c1[0] = handshake.SupportedRTMPVersion
+	_, err = conn.Write(c0c1)
+	assert.Nil(t, err)
+
+	s0s1s2 := make([]byte, 1+1536+1536)
+	_, err = io.ReadFull(conn, s0s1s2)
+	assert.Nil(t, err)
+
+	_, err = conn.Write(s0s1s2[1 : 1+1536])
+	assert.Nil(t, err)
+
+	assert.IsType(t, &client.Client{}, <-s.Clients())
+}
+
+func TestCloseClosesClientsWhichWereNotHandedOff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	go s.Accept()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+	s.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReleaseClosesClientsWhichWereNotHandedOff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	s := server.NewListener(l)
+	go s.Accept()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+	s.Release()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestCloseDoesNotLeakPendingHandshakes(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	s.SetHandshakeTimeout(50 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		s.Accept()
+		close(done)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	time.Sleep(10 * time.Millisecond)
+	s.Close()
+	<-done
+}
+
+func TestReleaseUnblocksAcceptImmediately(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	s := server.NewListener(l)
+
+	done := make(chan struct{})
+	go func() {
+		s.Accept()
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	start := time.Now()
+	s.Release()
+	<-done
+
+	assert.True(t, time.Since(start) < server.DefaultReleaseDeadline/2)
+}
+
+func TestClientBufferSizeBuffersTheClientsChannel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListenerWithOptions(l, server.ClientBufferSize(2))
+	go s.Accept()
+	defer s.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := net.Dial("tcp", l.Addr().String())
+		assert.Nil(t, err)
+	}
+
+	// The buffer fills without the channel being read from.
+	for len(s.Clients()) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 2, cap(s.Clients()))
+
+	// The connection that did not fit is held, rather than dropped.
+	for i := 0; i < 3; i++ {
+		select {
+		case c := <-s.Clients():
+			assert.NotNil(t, c)
+		case <-time.After(time.Second):
+			t.Fatalf("server: client %d was dropped", i)
+		}
+	}
+}
+
+func TestNegativeClientBufferSizesLeaveTheChannelUnbuffered(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListenerWithOptions(l, server.ClientBufferSize(-1))
+	defer s.Close()
+
+	assert.Equal(t, 0, cap(s.Clients()))
+}
+
+func TestNewWithOptionsBindsTheAddress(t *testing.T) {
+	s, err := server.NewWithOptions("127.0.0.1:0", server.ClientBufferSize(1))
+	assert.Nil(t, err)
+	defer s.Close()
+
+	assert.Equal(t, 1, cap(s.Clients()))
+}
+
+type contextKey struct{}
+
+func TestBaseContextIsTheParentOfClientContexts(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(
+		context.WithValue(context.Background(), contextKey{}, "foo"))
+
+	s := server.NewListenerWithOptions(l, server.BaseContext(ctx))
+	go s.Accept()
+	defer s.Close()
+
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	c := <-s.Clients()
+	assert.Equal(t, "foo", c.Context().Value(contextKey{}))
+
+	cancel()
+	<-c.Context().Done()
+}
+
+func TestTracerRecordsTheAcceptSpan(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	r := tracingtest.NewRecorder()
+
+	s := server.NewListenerWithOptions(l, server.Tracer(r))
+	go s.Accept()
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	c := <-s.Clients()
+	assert.Equal(t, r, tracing.FromContext(c.Context()))
+
+	span := r.Wait(tracing.SpanAccept, time.Second)
+	if assert.NotNil(t, span) {
+		addr, _ := span.Attribute(tracing.AttributeRemoteAddr)
+		assert.Equal(t, conn.LocalAddr().String(), addr)
+	}
+}
@@ -0,0 +1,95 @@
+package server_test
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// sockopt returns the value of the given socket option on the connection.
+func sockopt(t *testing.T, conn net.Conn, level, opt int) int {
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	assert.Nil(t, err)
+
+	var v int
+	raw.Control(func(fd uintptr) {
+		v, err = syscall.GetsockoptInt(int(fd), level, opt)
+	})
+	assert.Nil(t, err)
+
+	return v
+}
+
+func TestKeepAliveIsEnabledOnAcceptedConnections(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	s.SetKeepAlive(42 * time.Second)
+	go s.Accept()
+	defer s.Close()
+
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	conn := (<-s.Clients()).Conn()
+
+	assert.Equal(t, 42*time.Second, s.KeepAlive())
+	assert.Equal(t, 1, sockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE))
+	assert.Equal(t, 42, sockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE))
+}
+
+func TestKeepAliveMayBeDisabled(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	s.SetKeepAlive(-1)
+	go s.Accept()
+	defer s.Close()
+
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	conn := (<-s.Clients()).Conn()
+
+	assert.Equal(t, 0, sockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE))
+}
+
+func TestNoDelayIsEnabledByDefault(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	go s.Accept()
+	defer s.Close()
+
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	conn := (<-s.Clients()).Conn()
+
+	assert.True(t, s.NoDelay())
+	assert.Equal(t, 1, sockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY))
+}
+
+func TestNoDelayMayBeDisabled(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := server.NewListener(l)
+	s.SetNoDelay(false)
+	go s.Accept()
+	defer s.Close()
+
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	conn := (<-s.Clients()).Conn()
+
+	assert.Equal(t, 0, sockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY))
+}
@@ -0,0 +1,18 @@
+package server
+
+// state represents the point in its lifecycle that a Server is in.
+type state string
+
+const (
+	// idleState is the state of a Server before the Accept routine has
+	// been started.
+	idleState state = "idle"
+	// acceptingState is the state of a Server while the Accept routine is
+	// running.
+	acceptingState state = "accepting"
+	// releasingState is the state of a Server once Release() has been
+	// called.
+	releasingState state = "releasing"
+	// closingState is the state of a Server once Close() has been called.
+	closingState state = "closing"
+)
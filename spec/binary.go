@@ -53,6 +53,13 @@ func PutUint32(n uint32, w io.Writer) (int, error) {
 	return w.Write(buf)
 }
 
+func PutUint64(n uint64, w io.Writer) (int, error) {
+	buf := make([]byte, 8)
+	DefaultEndianness.PutUint64(buf, n)
+
+	return w.Write(buf)
+}
+
 func LittleEndianPutUint32(n uint32, w io.Writer) (int, error) {
 	buf := make([]byte, 4)
 	binary.LittleEndian.PutUint32(buf, n)
@@ -0,0 +1,106 @@
+// Package tracing defines the minimal Tracer interface through which the server
+// and stream types of this module report spans covering the lifecycle of a
+// connection (accept, handshake, connect, and first media), without depending
+// on any particular tracing library, such as OpenTelemetry.
+//
+// The Tracer is carried by the context of each connection (see NewContext, and
+// client.NewWithContext), so that it reaches every type which records spans.
+package tracing
+
+import "context"
+
+const (
+	// SpanAccept is the name of the span covering the time between a
+	// connection being accepted by the server, and the client being handed
+	// off to the Clients() channel, including the handshake, if the server
+	// performs it.
+	SpanAccept string = "rtmp.accept"
+	// SpanHandshake is the name of the span covering the RTMP handshake.
+	SpanHandshake string = "rtmp.handshake"
+	// SpanConnect is the name of the span covering the time between the
+	// connect command being received, and the client publishing or playing
+	// a stream.
+	SpanConnect string = "rtmp.connect"
+	// SpanFirstMedia is the name of the span covering the time between the
+	// DataStream starting to receive, and the first audio or video frame
+	// arriving over it.
+	SpanFirstMedia string = "rtmp.first_media"
+)
+
+const (
+	// AttributeRemoteAddr is the key of the attribute holding the remote
+	// address of the connection.
+	AttributeRemoteAddr string = "rtmp.remote_addr"
+	// AttributeApp is the key of the attribute holding the name of the
+	// application that the client connected to.
+	AttributeApp string = "rtmp.app"
+	// AttributeStreamKey is the key of the attribute holding the name of
+	// the stream that the client published or played.
+	AttributeStreamKey string = "rtmp.stream_key"
+)
+
+// Tracer is implemented by types capable of starting spans. It is easily
+// adapted to the Tracer of OpenTelemetry, or most other tracing libraries.
+type Tracer interface {
+	// Start starts a span with the given name, as a child of the span
+	// carried by `ctx` (if any), returning the span, and a context carrying
+	// it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single operation within a trace, started by a Tracer.
+type Span interface {
+	// SetAttribute records an attribute with the given key and value on
+	// the span.
+	SetAttribute(key, value string)
+	// End completes the span. If `err` is non-nil, the operation that the
+	// span covers is recorded as having failed with it.
+	End(err error)
+}
+
+var (
+	// Noop is a singleton implementation of the Tracer interface whose
+	// spans record nothing. It is the Tracer used when none is carried by
+	// the context.
+	Noop Tracer = new(noopTracer)
+)
+
+// noopTracer is the internal implementation of the Noop Tracer (see above).
+type noopTracer struct{}
+
+// Start implements Tracer.Start.
+func (n *noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+// noopSpan is the Span started by the Noop Tracer.
+type noopSpan struct{}
+
+// SetAttribute implements Span.SetAttribute.
+func (noopSpan) SetAttribute(key, value string) {}
+
+// End implements Span.End.
+func (noopSpan) End(err error) {}
+
+// tracerKey is the key that the Tracer is stored under in a context.
+type tracerKey struct{}
+
+// NewContext returns a copy of `ctx` carrying the given Tracer.
+func NewContext(ctx context.Context, t Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// FromContext returns the Tracer carried by `ctx`, or Noop if it carries none.
+func FromContext(ctx context.Context) Tracer {
+	if t, ok := ctx.Value(tracerKey{}).(Tracer); ok && t != nil {
+		return t
+	}
+
+	return Noop
+}
+
+// Start starts a span with the given name using the Tracer carried by `ctx`
+// (see FromContext).
+func Start(ctx context.Context, name string) (context.Context, Span) {
+	return FromContext(ctx).Start(ctx, name)
+}
@@ -0,0 +1,42 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/WatchBeam/rtmp/tracing"
+	"github.com/WatchBeam/rtmp/tracing/tracingtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContextReturnsNoopWithoutATracer(t *testing.T) {
+	assert.Equal(t, tracing.Noop, tracing.FromContext(context.Background()))
+}
+
+func TestFromContextReturnsTheCarriedTracer(t *testing.T) {
+	r := tracingtest.NewRecorder()
+	ctx := tracing.NewContext(context.Background(), r)
+
+	assert.Equal(t, r, tracing.FromContext(ctx))
+}
+
+func TestStartStartsSpansWithTheCarriedTracer(t *testing.T) {
+	r := tracingtest.NewRecorder()
+	ctx := tracing.NewContext(context.Background(), r)
+
+	_, span := tracing.Start(ctx, "foo")
+	span.SetAttribute("bar", "baz")
+	span.End(nil)
+
+	spans := r.Spans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "foo", spans[0].Name)
+
+	v, ok := spans[0].Attribute("bar")
+	assert.True(t, ok)
+	assert.Equal(t, "baz", v)
+
+	ended, err := spans[0].Ended()
+	assert.True(t, ended)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,123 @@
+// Package tracingtest provides an implementation of the tracing.Tracer
+// interface which records the spans started with it, for unit-testing the
+// instrumentation of code that reports spans.
+package tracingtest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/WatchBeam/rtmp/tracing"
+)
+
+// Span is a span started by a Recorder. It is safe for concurrent use.
+type Span struct {
+	// Name is the name that the span was started with.
+	Name string
+
+	// mu guards attributes, ended and err.
+	mu sync.Mutex
+	// attributes are the attributes recorded on the span, by key.
+	attributes map[string]string
+	// ended is true once End has been called.
+	ended bool
+	// err is the error that the span was ended with, if any.
+	err error
+}
+
+var _ tracing.Span = new(Span)
+
+// SetAttribute implements the tracing.Span.SetAttribute function.
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attributes[key] = value
+}
+
+// End implements the tracing.Span.End function.
+func (s *Span) End(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ended = true
+	s.err = err
+}
+
+// Attribute returns the value of the attribute with the given key, and whether
+// or not it was recorded.
+func (s *Span) Attribute(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.attributes[key]
+	return v, ok
+}
+
+// Ended returns whether or not the span has been ended, and the error it was
+// ended with.
+func (s *Span) Ended() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ended, s.err
+}
+
+// Recorder is an implementation of the tracing.Tracer interface which records
+// each span started with it. It is safe for concurrent use.
+type Recorder struct {
+	// mu guards spans.
+	mu sync.Mutex
+	// spans are the spans that have been started, in order.
+	spans []*Span
+}
+
+var _ tracing.Tracer = new(Recorder)
+
+// NewRecorder returns a new *Recorder.
+func NewRecorder() *Recorder {
+	return new(Recorder)
+}
+
+// Start implements the tracing.Tracer.Start function.
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, tracing.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &Span{
+		Name:       name,
+		attributes: make(map[string]string),
+	}
+	r.spans = append(r.spans, s)
+
+	return ctx, s
+}
+
+// Spans returns the spans that have been started so far, in order.
+func (r *Recorder) Spans() []*Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*Span(nil), r.spans...)
+}
+
+// Wait blocks until a span with the given name has been started and ended, and
+// returns it. If no such span has ended once the timeout elapses, nil is
+// returned instead. It is useful when spans are ended from another goroutine.
+func (r *Recorder) Wait(name string, timeout time.Duration) *Span {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		for _, s := range r.Spans() {
+			if ended, _ := s.Ended(); ended && s.Name == name {
+				return s
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
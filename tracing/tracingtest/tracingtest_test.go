@@ -0,0 +1,33 @@
+package tracingtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/rtmp/tracing/tracingtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorderRecordsEndedSpans(t *testing.T) {
+	r := tracingtest.NewRecorder()
+
+	_, span := r.Start(context.Background(), "foo")
+	span.End(errors.New("bar"))
+
+	s := r.Wait("foo", time.Second)
+	if assert.NotNil(t, s) {
+		ended, err := s.Ended()
+
+		assert.True(t, ended)
+		assert.EqualError(t, err, "bar")
+	}
+}
+
+func TestRecorderWaitTimesOutWithoutEndedSpans(t *testing.T) {
+	r := tracingtest.NewRecorder()
+	r.Start(context.Background(), "foo")
+
+	assert.Nil(t, r.Wait("foo", 10*time.Millisecond))
+}